@@ -0,0 +1,54 @@
+// Command hashrefreshtokens hashes the refresh tokens of sessions saved before
+// refresh tokens were hashed, in batches. Sessions are marked as migrated as their
+// token is hashed, so the command can be run again at any time, e.g. after an
+// interruption, and skips sessions already migrated.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os/signal"
+	"syscall"
+
+	"sso/internal/services/auth"
+	"sso/internal/storage/sqlite"
+)
+
+func main() {
+	var storagePath string
+	var batchSize int
+
+	flag.StringVar(&storagePath, "storage-path", "", "path to storage")
+	flag.IntVar(&batchSize, "batch-size", 500, "sessions to migrate per transaction")
+	flag.Parse()
+
+	if storagePath == "" {
+		panic("storage-path is required")
+	}
+	if batchSize <= 0 {
+		panic("batch-size must be positive")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// Refresh tokens aren't encrypted, so no keyring is needed.
+	storage, err := sqlite.New(storagePath, nil)
+	if err != nil {
+		panic(err)
+	}
+
+	hashed, err := auth.HashLegacyRefreshTokens(ctx, storage, batchSize)
+	if err != nil {
+		panic(err)
+	}
+
+	if hashed == 0 {
+		fmt.Println("no refresh tokens to migrate")
+
+		return
+	}
+
+	fmt.Printf("hashed %d refresh tokens\n", hashed)
+}
@@ -0,0 +1,77 @@
+// Command pwnedbloom builds the offline breached password filter from a Have I Been
+// Pwned SHA-1 dump, one HASH:COUNT per line.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"sso/internal/lib/pwned"
+)
+
+func main() {
+	var inPath, outPath string
+	var expected, minCount int
+	var fp float64
+
+	flag.StringVar(&inPath, "in", "", "path to the HIBP SHA-1 dump")
+	flag.StringVar(&outPath, "out", "", "path to write the bloom filter to")
+	flag.IntVar(&expected, "n", 0, "number of hashes in the dump, to size the filter")
+	flag.Float64Var(&fp, "fp", 0.001, "false positive rate")
+	flag.IntVar(&minCount, "min-count", 1, "skip passwords seen in fewer breaches than this")
+	flag.Parse()
+
+	if inPath == "" {
+		panic("in is required")
+	}
+	if outPath == "" {
+		panic("out is required")
+	}
+	if expected <= 0 {
+		panic("n is required")
+	}
+
+	in, err := os.Open(inPath)
+	if err != nil {
+		panic(err)
+	}
+	defer in.Close()
+
+	bloom := pwned.NewBloom(expected, fp)
+
+	added := 0
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		hash, count, _ := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if hash == "" {
+			continue
+		}
+		if n, err := strconv.Atoi(count); err == nil && n < minCount {
+			continue
+		}
+		if err := bloom.AddHash(hash); err != nil {
+			panic(err)
+		}
+		added++
+	}
+	if err := scanner.Err(); err != nil {
+		panic(err)
+	}
+
+	out, err := os.Create(outPath)
+	if err != nil {
+		panic(err)
+	}
+	if _, err := bloom.WriteTo(out); err != nil {
+		panic(err)
+	}
+	if err := out.Close(); err != nil {
+		panic(err)
+	}
+
+	fmt.Printf("added %d hashes\n", added)
+}
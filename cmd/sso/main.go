@@ -1,9 +1,13 @@
 package main
 
 import (
+	"context"
 	"log/slog"
 	"os"
+	"os/signal"
 	"sso/config"
+	"sso/internal/app"
+	"syscall"
 )
 
 const (
@@ -18,10 +22,27 @@ func main() {
 	log := setupLogger(cfg.Env)
 
 	log.Info("sso", "env", cfg.Env)
+	log.Debug("effective config", slog.String("config", cfg.Redacted()))
 
-	// TODO: initialize app
+	application := app.New(log, cfg)
 
-	// TODO: run GRPC server
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	go application.GRPCServer.MustRun()
+	if application.HTTPServer != nil {
+		go application.HTTPServer.MustRun()
+	}
+
+	application.MustWaitReady(ctx, cfg.StartupTimeout)
+
+	application.StartWorkers(ctx)
+
+	<-ctx.Done()
+
+	application.Stop()
+
+	log.Info("application stopped")
 }
 
 func setupLogger(env string) *slog.Logger {
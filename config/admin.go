@@ -0,0 +1,65 @@
+package config
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+)
+
+// AdminAccessConfig restricts admin RPCs by client IP. Entries are CIDRs or single
+// IPs. Denied wins over Allowed; an empty Allowed admits every IP not denied. With
+// both empty the restriction is off. Methods lists the full method names to guard
+// and defaults to the admin RPCs.
+type AdminAccessConfig struct {
+	Allowed []string `yaml:"allowed"`
+	Denied  []string `yaml:"denied"`
+	Methods []string `yaml:"methods"`
+}
+
+func (c AdminAccessConfig) Enabled() bool {
+	return len(c.Allowed) > 0 || len(c.Denied) > 0
+}
+
+// Prefixes parses the allow and deny lists.
+func (c AdminAccessConfig) Prefixes() (allowed []netip.Prefix, denied []netip.Prefix, err error) {
+	if allowed, err = parsePrefixes(c.Allowed); err != nil {
+		return nil, nil, fmt.Errorf("admin_access.allowed: %w", err)
+	}
+	if denied, err = parsePrefixes(c.Denied); err != nil {
+		return nil, nil, fmt.Errorf("admin_access.denied: %w", err)
+	}
+
+	return allowed, denied, nil
+}
+
+// TrustedProxyPrefixes parses the trusted proxy list.
+func (c GRPCConfig) TrustedProxyPrefixes() ([]netip.Prefix, error) {
+	prefixes, err := parsePrefixes(c.TrustedProxies)
+	if err != nil {
+		return nil, fmt.Errorf("trusted_proxies: %w", err)
+	}
+
+	return prefixes, nil
+}
+
+func parsePrefixes(entries []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(entries))
+	for _, entry := range entries {
+		if !strings.Contains(entry, "/") {
+			addr, err := netip.ParseAddr(entry)
+			if err != nil {
+				return nil, err
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+
+		prefix, err := netip.ParsePrefix(entry)
+		if err != nil {
+			return nil, err
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+
+	return prefixes, nil
+}
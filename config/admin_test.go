@@ -0,0 +1,51 @@
+package config
+
+import (
+	"net/netip"
+	"slices"
+	"testing"
+)
+
+func TestAdminAccessPrefixes(t *testing.T) {
+	tests := []struct {
+		name        string
+		cfg         AdminAccessConfig
+		wantAllowed []netip.Prefix
+		wantDenied  []netip.Prefix
+		wantErr     bool
+	}{
+		{name: "empty", wantAllowed: []netip.Prefix{}, wantDenied: []netip.Prefix{}},
+		{
+			name:        "single addresses",
+			cfg:         AdminAccessConfig{Allowed: []string{"10.0.0.1", "2001:db8::1"}, Denied: []string{"::ffff:192.0.2.1"}},
+			wantAllowed: []netip.Prefix{netip.MustParsePrefix("10.0.0.1/32"), netip.MustParsePrefix("2001:db8::1/128")},
+			wantDenied:  []netip.Prefix{netip.MustParsePrefix("192.0.2.1/32")},
+		},
+		{
+			name:        "cidrs are masked",
+			cfg:         AdminAccessConfig{Allowed: []string{"10.1.2.3/8"}},
+			wantAllowed: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")},
+			wantDenied:  []netip.Prefix{},
+		},
+		{name: "malformed allowed", cfg: AdminAccessConfig{Allowed: []string{"10.0.0"}}, wantErr: true},
+		{name: "malformed denied", cfg: AdminAccessConfig{Denied: []string{"10.0.0.0/33"}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			allowed, denied, err := tt.cfg.Prefixes()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Prefixes error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if !slices.Equal(allowed, tt.wantAllowed) {
+				t.Errorf("allowed = %v, want %v", allowed, tt.wantAllowed)
+			}
+			if !slices.Equal(denied, tt.wantDenied) {
+				t.Errorf("denied = %v, want %v", denied, tt.wantDenied)
+			}
+		})
+	}
+}
@@ -2,23 +2,557 @@ package config
 
 import (
 	"flag"
+	"fmt"
 	"github.com/ilyakaznacheev/cleanenv"
 	"os"
+	"slices"
 	"time"
 )
 
 type Config struct {
-	Env            string     `yaml:"env" env-default:"local"`
-	StoragePath    string     `yaml:"storage_path" env-required:"true"`
-	GRPC           GRPCConfig `yaml:"grpc"`
-	MigrationsPath string
-	TokenTTL       time.Duration `yaml:"token_ttl" env-default:"1h"`
-	RefreshTTL     time.Duration `yaml:"refresh_ttl" env-default:"24h"`
+	Env                string     `yaml:"env" env-default:"local"`
+	StorageDriver      string     `yaml:"storage_driver" env-default:"sqlite"`
+	StoragePath        string     `yaml:"storage_path" env-required:"true"`
+	GRPC               GRPCConfig `yaml:"grpc"`
+	MigrationsPath     string
+	StartupTimeout     time.Duration        `yaml:"startup_timeout" env-default:"30s"`
+	TokenTTL           time.Duration        `yaml:"token_ttl" env-default:"1h"`
+	TokenTTLJitter     time.Duration        `yaml:"token_ttl_jitter"`
+	RefreshTTL         time.Duration        `yaml:"refresh_ttl" env-default:"24h"`
+	RefreshMaxAge      time.Duration        `yaml:"refresh_max_age"`
+	SSOTicketTTL       time.Duration        `yaml:"sso_ticket_ttl" env-default:"1m"`
+	RenewWindow        time.Duration        `yaml:"renew_window"`
+	HashConcurrency    int                  `yaml:"hash_concurrency"`
+	SingleSession      bool                 `yaml:"single_session"`
+	NewIPRefresh       string               `yaml:"new_ip_refresh" env-default:"allow"`
+	LenientStatusCheck bool                 `yaml:"lenient_status_check"`
+	InstantRoleChange  bool                 `yaml:"instant_role_change"`
+	RolePermissions    map[int32][]string   `yaml:"role_permissions"`
+	IdentifierScope    string               `yaml:"identifier_scope" env-default:"global-unique"`
+	TokenSubject       TokenSubjectConfig   `yaml:"token_subject"`
+	Sessions           SessionLimitConfig   `yaml:"sessions"`
+	SessionIdle        SessionIdleConfig    `yaml:"session_idle"`
+	RateLimit          RateLimitConfig      `yaml:"rate_limit"`
+	Dormancy           DormancyConfig       `yaml:"dormancy"`
+	SessionCleanup     SessionCleanupConfig `yaml:"session_cleanup"`
+	Encryption         EncryptionConfig     `yaml:"encryption"`
+	Provisioning       ProvisioningConfig   `yaml:"provisioning"`
+	PasswordReset      PasswordResetConfig  `yaml:"password_reset"`
+	PasswordPolicy     PasswordPolicyConfig `yaml:"password_policy"`
+	PasswordHash       PasswordHashConfig   `yaml:"password_hash"`
+	Tarpit             TarpitConfig         `yaml:"tarpit"`
+	AuditLog           bool                 `yaml:"audit_log"`
+	BreachCheck        BreachCheckConfig    `yaml:"breach_check"`
+	NewDevice          NewDeviceConfig      `yaml:"new_device"`
+	GeoIP              GeoIPConfig          `yaml:"geoip"`
+	LoginRisk          LoginRiskConfig      `yaml:"login_risk"`
+	Reauth             ReauthConfig         `yaml:"reauth"`
+	Captcha            CaptchaConfig        `yaml:"captcha"`
+	Deletion           DeletionConfig       `yaml:"account_deletion"`
+	SMS                SMSConfig            `yaml:"sms"`
+	Profile            ProfileConfig        `yaml:"profile"`
+	EmailChange        EmailChangeConfig    `yaml:"email_change"`
+	Invites            InviteConfig         `yaml:"invites"`
+	// PasswordHistory is how many of an account's most recent passwords, the
+	// current one included, a password change or reset may not reuse. Zero allows any.
+	PasswordHistory int `yaml:"password_history"`
+	// RememberMeRefreshTTL is the refresh token TTL of logins that ask to be
+	// remembered, RefreshTTL being that of the rest. Zero treats them as any other.
+	RememberMeRefreshTTL time.Duration        `yaml:"remember_me_refresh_ttl" env-default:"720h"`
+	SessionBinding       SessionBindingConfig `yaml:"session_binding"`
+	JWKS                 JWKSConfig           `yaml:"jwks"`
+	SigningKeys          SigningKeysConfig    `yaml:"signing_keys"`
+	JWTClaims            JWTClaimsConfig      `yaml:"jwt_claims"`
+	// TokenRevocationList keeps a list of access tokens revoked before their
+	// expiry by jti, which validation checks, so logouts and disabled accounts cut
+	// off their tokens even for services verifying tokens themselves.
+	TokenRevocationList bool `yaml:"token_revocation_list"`
+	// JWTRefreshTokens issues refresh tokens as signed JWTs carrying the session
+	// and refresh family IDs, checked before the session lookup, instead of
+	// opaque strings. Sessions are still looked up, so revocation is unchanged.
+	JWTRefreshTokens bool `yaml:"jwt_refresh_tokens"`
 }
 
+// NewIPRefresh is what a refresh from an IP outside the session's subnet does.
+const (
+	NewIPRefreshAllow  = "allow"
+	NewIPRefreshAlert  = "alert"
+	NewIPRefreshStepUp = "step_up"
+	NewIPRefreshDeny   = "deny"
+)
+
+// IdentifierScope decides whether an email may register once in total or once per app.
+const (
+	IdentifierGlobalUnique = "global-unique"
+	IdentifierPerAppUnique = "per-app-unique"
+)
+
+const (
+	ProvisioningAsync    = "async"
+	ProvisioningBlocking = "blocking"
+)
+
+// ProvisioningConfig sends AccountRegistered to WebhookURL for every new account.
+// In blocking mode registration fails and is rolled back if the webhook does;
+// in async mode the webhook is called in the background.
+type ProvisioningConfig struct {
+	WebhookURL string        `yaml:"webhook_url"`
+	Mode       string        `yaml:"mode" env-default:"async"`
+	Timeout    time.Duration `yaml:"timeout" env-default:"5s"`
+}
+
+// PasswordResetConfig enables the password reset flow: reset tokens valid for TTL
+// are posted to WebhookURL, which delivers them to the account holder. Requests
+// caps reset requests per email. An empty WebhookURL disables password reset.
+type PasswordResetConfig struct {
+	WebhookURL string        `yaml:"webhook_url"`
+	Timeout    time.Duration `yaml:"timeout" env-default:"5s"`
+	TTL        time.Duration `yaml:"ttl" env-default:"30m"`
+	Requests   LimitConfig   `yaml:"requests"`
+}
+
+// PasswordPolicyConfig sets the rules new passwords must meet on registration,
+// password change and reset. Apps replace the default rules per app ID.
+// BannedFile lists common passwords to refuse, one per line, matched
+// case-insensitively.
+type PasswordPolicyConfig struct {
+	Default    PasswordRules           `yaml:"default"`
+	Apps       map[int32]PasswordRules `yaml:"apps"`
+	BannedFile string                  `yaml:"banned_file"`
+}
+
+// PasswordRules are the requirements for a password. Zero lengths are unbounded.
+type PasswordRules struct {
+	MinLength     int  `yaml:"min_length" env-default:"8"`
+	MaxLength     int  `yaml:"max_length"`
+	RequireUpper  bool `yaml:"require_upper"`
+	RequireLower  bool `yaml:"require_lower"`
+	RequireDigit  bool `yaml:"require_digit"`
+	RequireSymbol bool `yaml:"require_symbol"`
+}
+
+// PasswordHashConfig picks the algorithm for new password hashes: "bcrypt" or
+// "argon2id". Hashes made with the other algorithm or other parameters keep
+// verifying and are replaced on the account's next successful login.
+//
+// Pepper is an optional secret mixed into every password before hashing, kept out
+// of the database. It can come inline or, preferably, from PepperFile, e.g. a
+// mounted secret. Once hashes are peppered the pepper must not change.
+type PasswordHashConfig struct {
+	Algorithm  string         `yaml:"algorithm" env-default:"bcrypt"`
+	Bcrypt     BcryptConfig   `yaml:"bcrypt"`
+	Argon2id   Argon2idConfig `yaml:"argon2id"`
+	Pepper     string         `yaml:"pepper" env:"PASSWORD_PEPPER"`
+	PepperFile string         `yaml:"pepper_file" env:"PASSWORD_PEPPER_FILE"`
+}
+
+const (
+	PasswordHashBcrypt   = "bcrypt"
+	PasswordHashArgon2id = "argon2id"
+)
+
+// BcryptConfig tunes bcrypt. Cost is between 4 and 31.
+type BcryptConfig struct {
+	Cost int `yaml:"cost" env:"BCRYPT_COST" env-default:"10"`
+}
+
+// Argon2idConfig tunes argon2id. Memory is in KiB.
+type Argon2idConfig struct {
+	Time    uint32 `yaml:"time" env-default:"3"`
+	Memory  uint32 `yaml:"memory" env-default:"65536"`
+	Threads uint8  `yaml:"threads" env-default:"4"`
+	KeyLen  uint32 `yaml:"key_len" env-default:"32"`
+	SaltLen uint32 `yaml:"salt_len" env-default:"16"`
+}
+
+// TokenSubjectConfig sets the format of the sub claim: "raw" for the bare account
+// ID, "uuid" for the ID encoded as a UUID, or "prefixed" for Prefix followed by
+// the ID. Changing it stops earlier tokens' sub from resolving to an account.
+type TokenSubjectConfig struct {
+	Format string `yaml:"format" env-default:"raw"`
+	Prefix string `yaml:"prefix"`
+}
+
+const (
+	SubjectRaw      = "raw"
+	SubjectUUID     = "uuid"
+	SubjectPrefixed = "prefixed"
+)
+
+const (
+	SessionLimitEvict  = "evict"
+	SessionLimitReject = "reject"
+)
+
+// SessionLimitConfig caps concurrent sessions per account. MaxSessions is the default
+// for accounts without their own quota (zero means unlimited). OnLimit is "evict" to
+// revoke the oldest sessions or "reject" to refuse the new login.
+type SessionLimitConfig struct {
+	MaxSessions int    `yaml:"max_sessions"`
+	OnLimit     string `yaml:"on_limit" env-default:"evict"`
+}
+
+// SessionIdleConfig expires sessions unused for longer than Timeout, even within
+// their TTL. Apps and Roles override Timeout per app ID and per role; a session
+// matching both gets the shorter limit. Zero means no idle timeout. If every
+// session has one, session cleanup also revokes those idle past the longest.
+type SessionIdleConfig struct {
+	Timeout time.Duration           `yaml:"timeout"`
+	Apps    map[int32]time.Duration `yaml:"apps"`
+	Roles   map[int32]time.Duration `yaml:"roles"`
+}
+
+// BreachCheckConfig looks new passwords up in known breaches on registration,
+// password change and reset: offline in BloomFile if set (see cmd/pwnedbloom),
+// otherwise through the k-anonymity range API at RangeURL, whose answers are
+// cached per hash prefix. Mode "warn" only logs breached passwords, "enforce"
+// refuses them, "off" skips the check. Lookups that fail or exceed Timeout let
+// the password through.
+type BreachCheckConfig struct {
+	Mode      string        `yaml:"mode" env-default:"off"`
+	RangeURL  string        `yaml:"range_url" env-default:"https://api.pwnedpasswords.com/range/"`
+	BloomFile string        `yaml:"bloom_file"`
+	Timeout   time.Duration `yaml:"timeout" env-default:"2s"`
+	CacheTTL  time.Duration `yaml:"cache_ttl" env-default:"24h"`
+	CacheSize int           `yaml:"cache_size" env-default:"1024"`
+}
+
+const (
+	BreachCheckOff     = "off"
+	BreachCheckWarn    = "warn"
+	BreachCheckEnforce = "enforce"
+)
+
+// NewDeviceConfig notifies account holders of logins from a device and IP their
+// account hasn't used before. Notify "webhook" posts NewDeviceLogin to WebhookURL,
+// which delivers it, e.g. by mail; "log" only logs it, revoke token included, and
+// is meant for development; "off" disables detection. The revoke token in the
+// notification signs the device out and is valid for RevokeTTL.
+type NewDeviceConfig struct {
+	Notify     string        `yaml:"notify" env-default:"off"`
+	WebhookURL string        `yaml:"webhook_url"`
+	Timeout    time.Duration `yaml:"timeout" env-default:"5s"`
+	RevokeTTL  time.Duration `yaml:"revoke_ttl" env-default:"168h"`
+}
+
+const (
+	NewDeviceNotifyOff     = "off"
+	NewDeviceNotifyLog     = "log"
+	NewDeviceNotifyWebhook = "webhook"
+)
+
+// GeoIPConfig locates login IPs with the MaxMind City or Country database at File,
+// for new-device notifications and login risk checks. Empty disables geolocation.
+type GeoIPConfig struct {
+	File string `yaml:"file" env:"GEOIP_FILE"`
+}
+
+// LoginRiskConfig compares where a password login comes from with the account's
+// sessions seen within Window. A login that would have meant travelling faster
+// than MaxSpeedKmh over more than MinDistanceKm, or with NewCountry one from a
+// country none of them was in, is published as LoginRiskDetected and, by Action,
+// only logged ("log"), let through only with a verified second factor or passkey
+// ("step_up") or a solved CAPTCHA ("captcha"), or refused ("deny"). "off"
+// disables the check. It needs GeoIP, and "captcha" a CAPTCHA provider.
+type LoginRiskConfig struct {
+	Action        string        `yaml:"action" env-default:"off"`
+	Window        time.Duration `yaml:"window" env-default:"24h"`
+	MaxSpeedKmh   float64       `yaml:"max_speed_kmh" env-default:"900"`
+	MinDistanceKm float64       `yaml:"min_distance_km" env-default:"300"`
+	NewCountry    bool          `yaml:"new_country"`
+}
+
+const (
+	LoginRiskOff     = "off"
+	LoginRiskLog     = "log"
+	LoginRiskStepUp  = "step_up"
+	LoginRiskDeny    = "deny"
+	LoginRiskCaptcha = "captcha"
+)
+
+// ReauthConfig controls sudo mode. Reauthenticating elevates the session for
+// Window, letting it through methods listed in grpc.fresh_auth however long ago it
+// logged in. With SensitiveMaxAge set, signing out everywhere and revoking devices
+// also need a login within it or an elevated session.
+type ReauthConfig struct {
+	Window          time.Duration `yaml:"window" env-default:"10m"`
+	SensitiveMaxAge time.Duration `yaml:"sensitive_max_age"`
+}
+
+// CaptchaConfig verifies CAPTCHAs with Provider "recaptcha", "hcaptcha" or
+// "turnstile", or "off". Identifiers and IPs that go over the failed-login limit
+// must solve one for FlagTTL; with OnRegister every registration must. VerifyURL
+// overrides the provider's siteverify endpoint, and reCAPTCHA v3 tokens scoring
+// under MinScore are rejected.
+type CaptchaConfig struct {
+	Provider   string        `yaml:"provider" env-default:"off"`
+	Secret     string        `yaml:"secret" env:"CAPTCHA_SECRET"`
+	VerifyURL  string        `yaml:"verify_url"`
+	MinScore   float64       `yaml:"min_score" env-default:"0.5"`
+	Timeout    time.Duration `yaml:"timeout" env-default:"5s"`
+	FlagTTL    time.Duration `yaml:"flag_ttl" env-default:"15m"`
+	OnRegister bool          `yaml:"on_register"`
+}
+
+const (
+	CaptchaOff       = "off"
+	CaptchaReCAPTCHA = "recaptcha"
+	CaptchaHCaptcha  = "hcaptcha"
+	CaptchaTurnstile = "turnstile"
+)
+
+// TarpitConfig holds logins that look like credential stuffing for Delay and then
+// fails them. BreachedPairsFile lists SHA-256 digests of known-breached
+// identifier/password pairs, one per line; UserAgents are substrings of attack tool
+// user agents. With neither set the tarpit is off.
+type TarpitConfig struct {
+	Delay             time.Duration `yaml:"delay" env-default:"10s"`
+	BreachedPairsFile string        `yaml:"breached_pairs_file"`
+	UserAgents        []string      `yaml:"user_agents"`
+}
+
+// GRPCConfig configures the gRPC server. MaxConnectionAge closes connections after
+// that long so clients reconnect and rebalance; MaxConnectionAgeGrace is how long
+// in-flight calls get to finish before the close is forced.
 type GRPCConfig struct {
-	Port    int           `yaml:"port"`
-	Timeout time.Duration `yaml:"timeout"`
+	Port                  int           `yaml:"port"`
+	Timeout               time.Duration `yaml:"timeout"`
+	MaxConcurrentStreams  uint32        `yaml:"max_concurrent_streams" env-default:"100"`
+	MaxConnectionAge      time.Duration `yaml:"max_connection_age" env-default:"30m"`
+	MaxConnectionAgeGrace time.Duration `yaml:"max_connection_age_grace" env-default:"5m"`
+	TLS                   TLSConfig     `yaml:"tls"`
+	// FreshAuth maps full method names (e.g. /auth.Auth/ChangePassword) to how
+	// recently the caller must have authenticated to call them.
+	FreshAuth   map[string]time.Duration `yaml:"fresh_auth"`
+	AdminAccess AdminAccessConfig        `yaml:"admin_access"`
+	// TrustedProxies are the CIDRs or IPs of proxies whose x-forwarded-for header
+	// is believed when deriving the client IP. Empty uses the peer address.
+	TrustedProxies []string `yaml:"trusted_proxies"`
+}
+
+type RateLimitConfig struct {
+	Login         LimitConfig        `yaml:"login"`
+	LoginFailures LoginFailureConfig `yaml:"login_failures"`
+	Lockout       LockoutConfig      `yaml:"lockout"`
+	Refresh       RefreshLimitConfig `yaml:"refresh"`
+}
+
+// RefreshLimitConfig throttles session refreshes per client IP and per account.
+// Leave headroom for clients that legitimately refresh a few times in a row.
+type RefreshLimitConfig struct {
+	PerIP      LimitConfig `yaml:"per_ip"`
+	PerAccount LimitConfig `yaml:"per_account"`
+}
+
+// LoginFailureConfig throttles failed logins per identifier. Every failure is delayed
+// by Delay. With ThrottleUnknownAccounts, logins for unknown identifiers are counted
+// and delayed the same way, so they can't be told apart from real accounts.
+type LoginFailureConfig struct {
+	Requests                int           `yaml:"requests"`
+	Window                  time.Duration `yaml:"window" env-default:"15m"`
+	Delay                   time.Duration `yaml:"delay"`
+	ThrottleUnknownAccounts bool          `yaml:"throttle_unknown_accounts"`
+}
+
+// LockoutConfig locks an identifier out after repeated failed logins, escalating
+// through Tiers (e.g. 1m after 5 failures, 15m after 10, 1h after 15). A successful
+// login resets the escalation after CoolDown. No tiers disables lockout. Conceal
+// answers locked identifiers with the same error and timing as a wrong password.
+// With a Window, failures further apart than that start the count over. IPTiers
+// lock client IPs out the same way, across all identifiers. Store is "memory" for
+// per-instance state or "storage" to share it through the database.
+type LockoutConfig struct {
+	Tiers    []LockoutTier `yaml:"tiers"`
+	CoolDown time.Duration `yaml:"cool_down" env-default:"1h"`
+	Conceal  bool          `yaml:"conceal"`
+	Window   time.Duration `yaml:"window"`
+	IPTiers  []LockoutTier `yaml:"ip_tiers"`
+	Store    string        `yaml:"store" env-default:"memory"`
+}
+
+const (
+	LockoutStoreMemory  = "memory"
+	LockoutStoreStorage = "storage"
+)
+
+type LockoutTier struct {
+	Failures int           `yaml:"failures"`
+	Duration time.Duration `yaml:"duration"`
+}
+
+// LimitConfig is a fixed-window limit. Zero Requests disables the limiter.
+type LimitConfig struct {
+	Requests int           `yaml:"requests"`
+	Window   time.Duration `yaml:"window" env-default:"1m"`
+}
+
+// DormancyConfig moves accounts idle for Threshold to DORMANT. With Enabled, a
+// background job checks every Interval, BatchSize accounts at a time. Dormant
+// accounts can only log in again after reactivation, so Reactivation is required
+// with Enabled.
+type DormancyConfig struct {
+	Enabled      bool               `yaml:"enabled"`
+	Threshold    time.Duration      `yaml:"threshold" env-default:"2160h"`
+	Interval     time.Duration      `yaml:"interval" env-default:"1h"`
+	BatchSize    int                `yaml:"batch_size" env-default:"500"`
+	Reactivation ReactivationConfig `yaml:"reactivation"`
+}
+
+// ReactivationConfig enables reactivation of dormant accounts: reactivation tokens
+// valid for TTL are posted to WebhookURL, which delivers them to the account
+// holder. An empty WebhookURL disables reactivation.
+type ReactivationConfig struct {
+	WebhookURL string        `yaml:"webhook_url"`
+	Timeout    time.Duration `yaml:"timeout" env-default:"5s"`
+	TTL        time.Duration `yaml:"ttl" env-default:"24h"`
+}
+
+// DeletionConfig keeps soft-deleted accounts restorable for Retention. With Enabled,
+// a background job runs every Interval and purges accounts deleted longer ago,
+// BatchSize at a time.
+type DeletionConfig struct {
+	Retention time.Duration `yaml:"retention" env-default:"720h"`
+	Enabled   bool          `yaml:"enabled"`
+	Interval  time.Duration `yaml:"interval" env-default:"1h"`
+	BatchSize int           `yaml:"batch_size" env-default:"100"`
+}
+
+// SMSConfig enables login with codes texted to the account's phone number, valid
+// for CodeTTL. Provider is "webhook", which posts {"to", "body"} to WebhookURL,
+// "twilio", which sends From a number or messaging service SID of the
+// TwilioAccountSID account, or "off". Requests caps code requests per number.
+type SMSConfig struct {
+	Provider         string        `yaml:"provider" env-default:"off"`
+	WebhookURL       string        `yaml:"webhook_url"`
+	TwilioAccountSID string        `yaml:"twilio_account_sid"`
+	TwilioAuthToken  string        `yaml:"twilio_auth_token" env:"TWILIO_AUTH_TOKEN"`
+	From             string        `yaml:"from"`
+	Timeout          time.Duration `yaml:"timeout" env-default:"5s"`
+	CodeTTL          time.Duration `yaml:"code_ttl" env-default:"5m"`
+	Requests         LimitConfig   `yaml:"requests"`
+}
+
+// EmailChangeConfig enables changing the account email: confirmation tokens valid
+// for TTL are posted to WebhookURL, which delivers them to the new address and
+// notifies the old one. Requests caps change requests per account. An empty
+// WebhookURL disables email change.
+type EmailChangeConfig struct {
+	WebhookURL string        `yaml:"webhook_url"`
+	Timeout    time.Duration `yaml:"timeout" env-default:"5s"`
+	TTL        time.Duration `yaml:"ttl" env-default:"24h"`
+	Requests   LimitConfig   `yaml:"requests"`
+}
+
+const (
+	SessionBindingOff    = "off"
+	SessionBindingFlag   = "flag"
+	SessionBindingReject = "reject"
+)
+
+// SessionBindingConfig binds sessions to the client they were created from.
+// Policy is "off", "flag" to report validations and refreshes from another IP
+// subnet or device, or "reject" to refuse them; IP and UserAgent pick what is
+// compared. Validation callers pass the client's IP and user agent in the
+// x-client-ip and x-client-user-agent headers.
+type SessionBindingConfig struct {
+	Policy    string `yaml:"policy" env-default:"off"`
+	IP        bool   `yaml:"ip"`
+	UserAgent bool   `yaml:"user_agent"`
+}
+
+// JWKSConfig serves the public keys apps sign their tokens with as JWK Sets over
+// HTTP on Port, at /apps/{app_id}/jwks.json and /.well-known/jwks.json?app_id=.
+// Responses may be cached for CacheMaxAge. Zero Port turns the listener off.
+type JWKSConfig struct {
+	Port        int           `yaml:"port"`
+	CacheMaxAge time.Duration `yaml:"cache_max_age" env-default:"5m"`
+}
+
+// SigningKeysConfig rotates the keys of apps that sign with a key pair. A
+// background job checks every CheckInterval and gives apps whose key is older
+// than RotationInterval a new one; zero RotationInterval leaves rotation to
+// admins. Rotated out keys keep verifying for Overlap, and at least TokenTTL.
+// KeyProvider holds the keys apps sign with that the service doesn't store.
+type SigningKeysConfig struct {
+	RotationInterval time.Duration     `yaml:"rotation_interval"`
+	CheckInterval    time.Duration     `yaml:"check_interval" env-default:"1h"`
+	Overlap          time.Duration     `yaml:"overlap" env-default:"24h"`
+	KeyProvider      KeyProviderConfig `yaml:"key_provider"`
+}
+
+// KeyProviderConfig resolves signing keys held outside the database, which admins
+// register for apps by reference. Provider is "file", reading PEM keys from files
+// under Dir; "env", reading them from environment variables; "vault", reading
+// them from the VaultField of secrets in the KV v2 engine at VaultMount; "kms",
+// signing with AWS KMS keys that never leave KMS; or "off". KMSEndpoint
+// overrides the region's endpoint.
+type KeyProviderConfig struct {
+	Provider           string        `yaml:"provider" env-default:"off"`
+	Dir                string        `yaml:"dir"`
+	VaultAddr          string        `yaml:"vault_addr" env:"VAULT_ADDR"`
+	VaultToken         string        `yaml:"vault_token" env:"VAULT_TOKEN"`
+	VaultNamespace     string        `yaml:"vault_namespace" env:"VAULT_NAMESPACE"`
+	VaultMount         string        `yaml:"vault_mount" env-default:"secret"`
+	VaultField         string        `yaml:"vault_field" env-default:"private_key"`
+	KMSRegion          string        `yaml:"kms_region" env:"AWS_REGION"`
+	KMSEndpoint        string        `yaml:"kms_endpoint"`
+	AWSAccessKeyID     string        `yaml:"aws_access_key_id" env:"AWS_ACCESS_KEY_ID"`
+	AWSSecretAccessKey string        `yaml:"aws_secret_access_key" env:"AWS_SECRET_ACCESS_KEY"`
+	AWSSessionToken    string        `yaml:"aws_session_token" env:"AWS_SESSION_TOKEN"`
+	Timeout            time.Duration `yaml:"timeout" env-default:"5s"`
+}
+
+const (
+	KeyProviderOff   = "off"
+	KeyProviderFile  = "file"
+	KeyProviderEnv   = "env"
+	KeyProviderVault = "vault"
+	KeyProviderKMS   = "kms"
+)
+
+// JWTClaimsConfig sets the iss claim of issued tokens, none when Issuer is empty,
+// and the clock skew Leeway allowed past a token's expiry when validating it.
+// Apps' tokens carry their audience, or their ID, as aud.
+type JWTClaimsConfig struct {
+	Issuer string        `yaml:"issuer"`
+	Leeway time.Duration `yaml:"leeway"`
+}
+
+// InviteConfig sets how long invites stay valid when admins don't pick an expiry.
+type InviteConfig struct {
+	TTL time.Duration `yaml:"ttl" env-default:"168h"`
+}
+
+// ProfileConfig lists the profile claims tokens carry, out of name, given_name,
+// family_name, picture, locale and zoneinfo, and caps the size of the free-form
+// profile metadata in bytes.
+type ProfileConfig struct {
+	TokenClaims     []string `yaml:"token_claims"`
+	MaxMetadataSize int      `yaml:"max_metadata_size" env-default:"4096"`
+}
+
+const (
+	SMSOff     = "off"
+	SMSWebhook = "webhook"
+	SMSTwilio  = "twilio"
+)
+
+// SessionCleanupConfig runs a background job every Interval that deletes expired
+// sessions, sessions revoked more than RevokedRetention ago, and expired one-time
+// codes and SSO tickets, BatchSize rows per statement.
+type SessionCleanupConfig struct {
+	Enabled          bool          `yaml:"enabled"`
+	Interval         time.Duration `yaml:"interval" env-default:"1h"`
+	BatchSize        int           `yaml:"batch_size" env-default:"500"`
+	RevokedRetention time.Duration `yaml:"revoked_retention" env-default:"24h"`
+}
+
+// EncryptionConfig holds the master keys for encrypting sensitive columns at rest.
+// Keys are base64-encoded 32-byte AES keys indexed by version; new values are
+// encrypted with CurrentKeyVersion, older versions stay readable.
+type EncryptionConfig struct {
+	CurrentKeyVersion uint8            `yaml:"current_key_version" env:"ENCRYPTION_CURRENT_KEY_VERSION"`
+	Keys              map[uint8]string `yaml:"keys" env:"ENCRYPTION_KEYS"`
 }
 
 func MustLoad() *Config {
@@ -37,9 +571,64 @@ func MustLoad() *Config {
 		panic("domain path is empty: " + err.Error())
 	}
 
+	if err := validateStorage(cfg.StorageDriver, cfg.StoragePath); err != nil {
+		panic("invalid storage config: " + err.Error())
+	}
+
+	if _, err := cfg.GRPC.TLS.ServerConfig(); err != nil {
+		panic("invalid grpc tls config: " + err.Error())
+	}
+
+	if _, _, err := cfg.GRPC.AdminAccess.Prefixes(); err != nil {
+		panic("invalid grpc admin access config: " + err.Error())
+	}
+
+	if _, err := cfg.GRPC.TrustedProxyPrefixes(); err != nil {
+		panic("invalid grpc trusted proxies: " + err.Error())
+	}
+
+	if err := validateTokenTTLJitter(cfg.TokenTTL, cfg.TokenTTLJitter); err != nil {
+		panic("invalid token_ttl_jitter: " + err.Error())
+	}
+
+	if err := validateOneOf(cfg.NewIPRefresh, NewIPRefreshAllow, NewIPRefreshAlert, NewIPRefreshStepUp, NewIPRefreshDeny); err != nil {
+		panic("invalid new_ip_refresh: " + err.Error())
+	}
+
+	if err := validateOneOf(cfg.Provisioning.Mode, ProvisioningAsync, ProvisioningBlocking); err != nil {
+		panic("invalid provisioning mode: " + err.Error())
+	}
+
+	if err := validateOneOf(cfg.SessionBinding.Policy, SessionBindingOff, SessionBindingFlag, SessionBindingReject); err != nil {
+		panic("invalid session binding policy: " + err.Error())
+	}
+
 	return &cfg
 }
 
+// validateTokenTTLJitter checks that jitter shortens token lifetimes by less than
+// the whole TTL. Zero disables jitter.
+func validateTokenTTLJitter(ttl, jitter time.Duration) error {
+	if jitter < 0 {
+		return fmt.Errorf("jitter %v is negative", jitter)
+	}
+	if jitter > 0 && jitter >= ttl {
+		return fmt.Errorf("jitter %v is not below token_ttl %v", jitter, ttl)
+	}
+
+	return nil
+}
+
+// validateOneOf checks that value is one of allowed, so a typo fails at load
+// instead of silently falling back to some default behavior.
+func validateOneOf(value string, allowed ...string) error {
+	if !slices.Contains(allowed, value) {
+		return fmt.Errorf("unknown value %q, want one of %q", value, allowed)
+	}
+
+	return nil
+}
+
 // fetchConfigPath fetches domain path from command line flag or environment variable.
 // Priority: flag > env > default.
 // Default value is empty string.
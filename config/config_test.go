@@ -0,0 +1,51 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestValidateOneOf(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		wantErr bool
+	}{
+		{name: "allowed", value: NewIPRefreshAlert},
+		{name: "unknown", value: "block", wantErr: true},
+		{name: "wrong case", value: "Deny", wantErr: true},
+		{name: "empty", value: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateOneOf(tt.value, NewIPRefreshAllow, NewIPRefreshAlert, NewIPRefreshStepUp, NewIPRefreshDeny)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateOneOf(%q) error = %v, want error %v", tt.value, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateTokenTTLJitter(t *testing.T) {
+	tests := []struct {
+		name    string
+		jitter  time.Duration
+		wantErr bool
+	}{
+		{name: "off", jitter: 0},
+		{name: "below ttl", jitter: 5 * time.Minute},
+		{name: "negative", jitter: -time.Minute, wantErr: true},
+		{name: "equal to ttl", jitter: time.Hour, wantErr: true},
+		{name: "above ttl", jitter: 2 * time.Hour, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateTokenTTLJitter(time.Hour, tt.jitter)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateTokenTTLJitter(1h, %v) error = %v, want error %v", tt.jitter, err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package config
+
+import (
+	"net/url"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const redacted = "REDACTED"
+
+// Redacted renders the effective config as YAML for diagnostics, with secrets
+// masked: encryption keys, the password pepper, the CAPTCHA secret, the Twilio auth
+// token, credentials in the storage DSN and in the webhook URLs.
+func (c Config) Redacted() string {
+	c.StoragePath = redactDSN(c.StorageDriver, c.StoragePath)
+	c.Provisioning.WebhookURL = redactURL(c.Provisioning.WebhookURL)
+	c.PasswordReset.WebhookURL = redactURL(c.PasswordReset.WebhookURL)
+	c.NewDevice.WebhookURL = redactURL(c.NewDevice.WebhookURL)
+	c.SMS.WebhookURL = redactURL(c.SMS.WebhookURL)
+	c.EmailChange.WebhookURL = redactURL(c.EmailChange.WebhookURL)
+
+	if c.PasswordHash.Pepper != "" {
+		c.PasswordHash.Pepper = redacted
+	}
+	if c.Captcha.Secret != "" {
+		c.Captcha.Secret = redacted
+	}
+	if c.SMS.TwilioAuthToken != "" {
+		c.SMS.TwilioAuthToken = redacted
+	}
+
+	if len(c.Encryption.Keys) > 0 {
+		keys := make(map[uint8]string, len(c.Encryption.Keys))
+		for version := range c.Encryption.Keys {
+			keys[version] = redacted
+		}
+		c.Encryption.Keys = keys
+	}
+
+	out, err := yaml.Marshal(c)
+	if err != nil {
+		return "config: " + err.Error()
+	}
+
+	return string(out)
+}
+
+// String is Redacted, so printing a Config never leaks secrets.
+func (c Config) String() string {
+	return c.Redacted()
+}
+
+// redactDSN masks the password of a postgres DSN, in URL or key/value form. SQLite
+// paths hold no credentials and are returned as is.
+func redactDSN(driver, dsn string) string {
+	if driver != StorageDriverPostgres || dsn == "" {
+		return dsn
+	}
+
+	if strings.Contains(dsn, "://") {
+		return redactURL(dsn)
+	}
+
+	fields := strings.Fields(dsn)
+	for i, field := range fields {
+		if key, _, ok := strings.Cut(field, "="); ok && key == "password" {
+			fields[i] = key + "=" + redacted
+		}
+	}
+
+	return strings.Join(fields, " ")
+}
+
+// redactURL masks the password in a URL's user info and the values of its query
+// parameters, which commonly carry tokens.
+func redactURL(raw string) string {
+	if raw == "" {
+		return raw
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		return redacted
+	}
+
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), redacted)
+	}
+
+	if u.RawQuery != "" {
+		query := u.Query()
+		for key := range query {
+			query.Set(key, redacted)
+		}
+		u.RawQuery = query.Encode()
+	}
+
+	return u.String()
+}
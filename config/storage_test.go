@@ -0,0 +1,63 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestValidateStorage(t *testing.T) {
+	dir := t.TempDir()
+
+	existing := filepath.Join(dir, "existing.db")
+	if err := os.WriteFile(existing, nil, 0o600); err != nil {
+		t.Fatalf("create db file: %v", err)
+	}
+	subdir := filepath.Join(dir, "subdir")
+	if err := os.Mkdir(subdir, 0o700); err != nil {
+		t.Fatalf("create dir: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		driver  string
+		path    string
+		wantErr bool
+	}{
+		{name: "sqlite new file", driver: StorageDriverSQLite, path: filepath.Join(dir, "new.db")},
+		{name: "sqlite existing file", driver: StorageDriverSQLite, path: existing},
+		{name: "sqlite uri with params", driver: StorageDriverSQLite, path: "file:" + existing + "?_journal_mode=WAL"},
+		{name: "sqlite in memory", driver: StorageDriverSQLite, path: ":memory:"},
+		{name: "sqlite empty", driver: StorageDriverSQLite, path: "", wantErr: true},
+		{name: "sqlite missing directory", driver: StorageDriverSQLite, path: filepath.Join(dir, "missing", "sso.db"), wantErr: true},
+		{name: "sqlite directory as file", driver: StorageDriverSQLite, path: subdir, wantErr: true},
+		{name: "sqlite file as directory", driver: StorageDriverSQLite, path: filepath.Join(existing, "sso.db"), wantErr: true},
+		{name: "postgres url", driver: StorageDriverPostgres, path: "postgres://sso:secret@db:5432/sso"},
+		{name: "postgresql url", driver: StorageDriverPostgres, path: "postgresql://db/sso?sslmode=disable"},
+		{name: "postgres key value", driver: StorageDriverPostgres, path: "host=db port=5432 dbname=sso user=sso"},
+		{name: "postgres empty", driver: StorageDriverPostgres, path: "", wantErr: true},
+		{name: "postgres other scheme", driver: StorageDriverPostgres, path: "mysql://db/sso", wantErr: true},
+		{name: "postgres url without host", driver: StorageDriverPostgres, path: "postgres:///sso", wantErr: true},
+		{name: "postgres url without database", driver: StorageDriverPostgres, path: "postgres://db:5432/", wantErr: true},
+		{name: "postgres key value without dbname", driver: StorageDriverPostgres, path: "host=db user=sso", wantErr: true},
+		{name: "postgres malformed pair", driver: StorageDriverPostgres, path: "host=db dbname", wantErr: true},
+		{name: "unknown driver", driver: "mysql", path: "db", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateStorage(tt.driver, tt.path)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateStorage(%q, %q) error = %v, want error %v", tt.driver, tt.path, err, tt.wantErr)
+			}
+		})
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("read dir: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("validation left files behind: %v", entries)
+	}
+}
@@ -0,0 +1,101 @@
+package config
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+)
+
+// TLSConfig enables TLS on the gRPC server when CertFile and KeyFile are set.
+// MinVersion is "1.2" or "1.3"; older versions are rejected. CipherSuites is an
+// optional allow-list of Go cipher suite names for TLS 1.2 connections (TLS 1.3
+// suites aren't configurable); empty keeps Go's secure defaults.
+type TLSConfig struct {
+	CertFile     string   `yaml:"cert_file"`
+	KeyFile      string   `yaml:"key_file"`
+	MinVersion   string   `yaml:"min_version" env-default:"1.2"`
+	CipherSuites []string `yaml:"cipher_suites"`
+}
+
+func (c TLSConfig) Enabled() bool {
+	return c.CertFile != "" || c.KeyFile != ""
+}
+
+// ServerConfig builds the server's tls.Config, loading the key pair from disk. It
+// returns nil when TLS is disabled and an error for any insecure or unknown setting.
+func (c TLSConfig) ServerConfig() (*tls.Config, error) {
+	if !c.Enabled() {
+		return nil, nil
+	}
+	if c.CertFile == "" || c.KeyFile == "" {
+		return nil, errors.New("tls: both cert_file and key_file must be set")
+	}
+
+	minVersion, err := tlsVersion(c.MinVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	suites, err := cipherSuites(c.CipherSuites)
+	if err != nil {
+		return nil, err
+	}
+	if len(suites) > 0 && minVersion == tls.VersionTLS13 {
+		return nil, errors.New("tls: cipher_suites has no effect with min_version 1.3")
+	}
+
+	cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("tls: %w", err)
+	}
+
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   minVersion,
+		CipherSuites: suites,
+	}, nil
+}
+
+func tlsVersion(v string) (uint16, error) {
+	switch v {
+	case "", "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	case "1.0", "1.1":
+		return 0, fmt.Errorf("tls: min_version %s is insecure, use 1.2 or 1.3", v)
+	default:
+		return 0, fmt.Errorf("tls: unknown min_version %q", v)
+	}
+}
+
+// cipherSuites resolves suite names against Go's secure suites. Names from
+// tls.InsecureCipherSuites are rejected rather than silently accepted.
+func cipherSuites(names []string) ([]uint16, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	secure := make(map[string]uint16)
+	for _, s := range tls.CipherSuites() {
+		secure[s.Name] = s.ID
+	}
+	insecure := make(map[string]struct{})
+	for _, s := range tls.InsecureCipherSuites() {
+		insecure[s.Name] = struct{}{}
+	}
+
+	ids := make([]uint16, 0, len(names))
+	for _, name := range names {
+		if _, ok := insecure[name]; ok {
+			return nil, fmt.Errorf("tls: cipher suite %s is insecure", name)
+		}
+		id, ok := secure[name]
+		if !ok {
+			return nil, fmt.Errorf("tls: unknown cipher suite %q", name)
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
@@ -0,0 +1,126 @@
+package config
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeKeyPair writes a self-signed certificate for localhost and its key to dir.
+func writeKeyPair(t *testing.T, dir string) (certFile, keyFile string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("create certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("marshal key: %v", err)
+	}
+
+	certFile = filepath.Join(dir, "cert.pem")
+	keyFile = filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatalf("write cert: %v", err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatalf("write key: %v", err)
+	}
+
+	return certFile, keyFile
+}
+
+func TestTLSServerConfigRejectsInsecureSettings(t *testing.T) {
+	certFile, keyFile := writeKeyPair(t, t.TempDir())
+
+	tests := []struct {
+		name    string
+		cfg     TLSConfig
+		wantErr bool
+	}{
+		{name: "default", cfg: TLSConfig{}},
+		{name: "tls 1.3", cfg: TLSConfig{MinVersion: "1.3"}},
+		{name: "tls 1.1", cfg: TLSConfig{MinVersion: "1.1"}, wantErr: true},
+		{name: "unknown version", cfg: TLSConfig{MinVersion: "2"}, wantErr: true},
+		{name: "approved suite", cfg: TLSConfig{CipherSuites: []string{"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256"}}},
+		{name: "insecure suite", cfg: TLSConfig{CipherSuites: []string{"TLS_RSA_WITH_RC4_128_SHA"}}, wantErr: true},
+		{name: "suites with tls 1.3", cfg: TLSConfig{MinVersion: "1.3", CipherSuites: []string{"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256"}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.cfg.CertFile, tt.cfg.KeyFile = certFile, keyFile
+			_, err := tt.cfg.ServerConfig()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ServerConfig() error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTLSMinVersionRejectsOlderClients(t *testing.T) {
+	certFile, keyFile := writeKeyPair(t, t.TempDir())
+
+	tests := []struct {
+		name          string
+		minVersion    string
+		clientVersion uint16
+		wantErr       bool
+	}{
+		{name: "1.2 client on 1.2 server", minVersion: "1.2", clientVersion: tls.VersionTLS12},
+		{name: "1.1 client on 1.2 server", minVersion: "1.2", clientVersion: tls.VersionTLS11, wantErr: true},
+		{name: "1.3 client on 1.3 server", minVersion: "1.3", clientVersion: tls.VersionTLS13},
+		{name: "1.2 client on 1.3 server", minVersion: "1.3", clientVersion: tls.VersionTLS12, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			serverCfg, err := TLSConfig{CertFile: certFile, KeyFile: keyFile, MinVersion: tt.minVersion}.ServerConfig()
+			if err != nil {
+				t.Fatalf("server config: %v", err)
+			}
+
+			serverConn, clientConn := net.Pipe()
+			defer serverConn.Close()
+			defer clientConn.Close()
+
+			go func() {
+				_ = tls.Server(serverConn, serverCfg).Handshake()
+				serverConn.Close()
+			}()
+
+			client := tls.Client(clientConn, &tls.Config{
+				InsecureSkipVerify: true,
+				MinVersion:         tt.clientVersion,
+				MaxVersion:         tt.clientVersion,
+			})
+			err = client.Handshake()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("handshake error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -1,35 +1,538 @@
 package app
 
 import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
 	"log/slog"
+	"os"
+	"sync"
 	"time"
 
+	"sso/config"
 	grpcapp "sso/internal/app/grpc"
+	httpapp "sso/internal/app/http"
+	"sso/internal/app/worker"
+	"sso/internal/domain/models"
+	"sso/internal/lib/captcha"
+	"sso/internal/lib/encryption"
+	"sso/internal/lib/events"
+	"sso/internal/lib/geoip"
+	"sso/internal/lib/jwt"
+	"sso/internal/lib/keyprovider"
+	"sso/internal/lib/lockout"
+	"sso/internal/lib/passhash"
+	"sso/internal/lib/passwordpolicy"
+	"sso/internal/lib/pwned"
+	"sso/internal/lib/ratelimit"
+	"sso/internal/lib/sms"
+	"sso/internal/lib/tarpit"
 	"sso/internal/services/auth"
 	"sso/internal/storage/sqlite"
+
+	"golang.org/x/crypto/bcrypt"
 )
 
 type App struct {
 	GRPCServer *grpcapp.App
+	// HTTPServer serves the JWK Sets of the apps; nil when it is off.
+	HTTPServer *httpapp.App
+	Workers    []*worker.Worker
+	log        *slog.Logger
+	storage    pinger
+
+	stopWorkers context.CancelFunc
+	workersDone sync.WaitGroup
 }
 
-func New(
-	log *slog.Logger,
-	grpcPort int,
-	storagePath string,
-	tokenTTL time.Duration,
-	refreshTokenTTL time.Duration,
-) *App {
-	storage, err := sqlite.New(storagePath)
+func New(log *slog.Logger, cfg *config.Config) *App {
+	if cfg.StorageDriver != config.StorageDriverSQLite {
+		panic("unsupported storage driver: " + cfg.StorageDriver)
+	}
+
+	for _, claim := range cfg.Profile.TokenClaims {
+		if !auth.IsProfileClaim(claim) {
+			panic("unsupported profile token claim: " + claim)
+		}
+	}
+
+	keyring, err := newKeyring(cfg.Encryption)
+	if err != nil {
+		panic(err)
+	}
+
+	storage, err := sqlite.New(cfg.StoragePath, keyring)
+	if err != nil {
+		panic(err)
+	}
+
+	var publisher auth.EventPublisher = events.NewLogPublisher(log)
+	if cfg.AuditLog {
+		publisher = events.Fanout{publisher, events.NewAuditPublisher(storage)}
+	}
+
+	authOpts := []auth.Option{
+		auth.WithRenewalWindow(cfg.RenewWindow),
+		auth.WithTokenTTLJitter(cfg.TokenTTLJitter),
+		auth.WithEventPublisher(publisher),
+		auth.WithTOTPStore(storage),
+		auth.WithPasskeyStore(storage),
+		auth.WithHashConcurrency(cfg.HashConcurrency),
+		auth.WithSingleSession(cfg.SingleSession),
+		auth.WithRefreshFamilyMaxAge(cfg.RefreshMaxAge),
+		auth.WithRememberMe(cfg.RememberMeRefreshTTL),
+		auth.WithSSOTicketTTL(cfg.SSOTicketTTL),
+		auth.WithLenientStatusCheck(cfg.LenientStatusCheck),
+		auth.WithInstantRoleChange(cfg.InstantRoleChange),
+		auth.WithNewIPRefreshPolicy(auth.NewIPRefreshPolicy(cfg.NewIPRefresh)),
+		auth.WithUniformUnknownAccountThrottle(cfg.RateLimit.LoginFailures.ThrottleUnknownAccounts),
+		auth.WithPerAppIdentifiers(cfg.IdentifierScope == config.IdentifierPerAppUnique),
+		auth.WithMaxSessions(cfg.Sessions.MaxSessions, cfg.Sessions.OnLimit == config.SessionLimitReject),
+		auth.WithSessionQuotaProvider(storage, storage),
+		auth.WithPasswordHistory(storage, cfg.PasswordHistory),
+		auth.WithReauthWindow(cfg.Reauth.Window),
+		auth.WithRecentAuthRequired(cfg.Reauth.SensitiveMaxAge),
+		auth.WithAccountDeletion(storage, cfg.Deletion.Retention),
+		auth.WithAccountDataExport(storage),
+		auth.WithProfiles(storage, cfg.Profile.TokenClaims, cfg.Profile.MaxMetadataSize),
+		auth.WithInvites(storage, cfg.Invites.TTL),
+		auth.WithSessionBinding(auth.SessionBinding{
+			Policy:    auth.SessionBindingPolicy(cfg.SessionBinding.Policy),
+			IP:        cfg.SessionBinding.IP,
+			UserAgent: cfg.SessionBinding.UserAgent,
+		}),
+		auth.WithDeviceNameStore(storage),
+		auth.WithSigningKeyOverlap(cfg.SigningKeys.Overlap),
+	}
+	if cfg.Provisioning.WebhookURL != "" {
+		authOpts = append(authOpts, auth.WithProvisioner(
+			events.NewWebhookPublisher(cfg.Provisioning.WebhookURL, cfg.Provisioning.Timeout),
+			cfg.Provisioning.Mode == config.ProvisioningBlocking,
+		))
+	}
+	if cfg.RateLimit.LoginFailures.Requests > 0 {
+		authOpts = append(authOpts, auth.WithFailedLoginThrottle(
+			ratelimit.New(cfg.RateLimit.LoginFailures.Requests, cfg.RateLimit.LoginFailures.Window),
+			cfg.RateLimit.LoginFailures.Delay,
+		))
+	}
+
+	if cfg.AuditLog {
+		authOpts = append(authOpts, auth.WithAuditProvider(storage))
+	}
+
+	if cfg.PasswordReset.WebhookURL != "" {
+		authOpts = append(authOpts, auth.WithPasswordReset(
+			events.NewWebhookPublisher(cfg.PasswordReset.WebhookURL, cfg.PasswordReset.Timeout),
+			cfg.PasswordReset.TTL,
+			newLimiter(cfg.PasswordReset.Requests),
+		))
+	}
+
+	if cfg.Dormancy.Reactivation.WebhookURL != "" {
+		authOpts = append(authOpts, auth.WithReactivation(
+			events.NewWebhookPublisher(cfg.Dormancy.Reactivation.WebhookURL, cfg.Dormancy.Reactivation.Timeout),
+			cfg.Dormancy.Reactivation.TTL,
+		))
+	}
+
+	if cfg.EmailChange.WebhookURL != "" {
+		authOpts = append(authOpts, auth.WithEmailChange(
+			events.NewWebhookPublisher(cfg.EmailChange.WebhookURL, cfg.EmailChange.Timeout),
+			storage,
+			cfg.EmailChange.TTL,
+			newLimiter(cfg.EmailChange.Requests),
+		))
+	}
+
+	var bannedPasswords []string
+	if cfg.PasswordPolicy.BannedFile != "" {
+		bannedPasswords, err = passwordpolicy.LoadBanned(cfg.PasswordPolicy.BannedFile)
+		if err != nil {
+			panic(err)
+		}
+	}
+	appPasswordRules := make(map[int32]passwordpolicy.Rules, len(cfg.PasswordPolicy.Apps))
+	for appID, rules := range cfg.PasswordPolicy.Apps {
+		appPasswordRules[appID] = passwordRules(rules)
+	}
+	authOpts = append(authOpts, auth.WithPasswordPolicy(
+		passwordpolicy.New(passwordRules(cfg.PasswordPolicy.Default), appPasswordRules, bannedPasswords),
+	))
+
+	switch cfg.PasswordHash.Algorithm {
+	case config.PasswordHashBcrypt:
+		if cfg.PasswordHash.Bcrypt.Cost < bcrypt.MinCost || cfg.PasswordHash.Bcrypt.Cost > bcrypt.MaxCost {
+			panic(fmt.Sprintf("bcrypt cost %d out of range", cfg.PasswordHash.Bcrypt.Cost))
+		}
+		authOpts = append(authOpts, auth.WithPasswordHasher(passhash.Bcrypt{Cost: cfg.PasswordHash.Bcrypt.Cost}))
+	case config.PasswordHashArgon2id:
+		authOpts = append(authOpts, auth.WithPasswordHasher(passhash.Argon2id{
+			Time:    cfg.PasswordHash.Argon2id.Time,
+			Memory:  cfg.PasswordHash.Argon2id.Memory,
+			Threads: cfg.PasswordHash.Argon2id.Threads,
+			KeyLen:  cfg.PasswordHash.Argon2id.KeyLen,
+			SaltLen: cfg.PasswordHash.Argon2id.SaltLen,
+		}))
+	default:
+		panic("unsupported password hash algorithm: " + cfg.PasswordHash.Algorithm)
+	}
+
+	pepper, err := loadPepper(cfg.PasswordHash)
+	if err != nil {
+		panic(err)
+	}
+	if pepper != nil {
+		authOpts = append(authOpts, auth.WithPasswordPepper(pepper))
+	}
+
+	switch cfg.NewDevice.Notify {
+	case config.NewDeviceNotifyOff:
+	case config.NewDeviceNotifyLog:
+		authOpts = append(authOpts, auth.WithNewDeviceNotifier(events.NewLogPublisher(log), storage, cfg.NewDevice.RevokeTTL))
+	case config.NewDeviceNotifyWebhook:
+		if cfg.NewDevice.WebhookURL == "" {
+			panic("new device webhook_url is required")
+		}
+		authOpts = append(authOpts, auth.WithNewDeviceNotifier(
+			events.NewWebhookPublisher(cfg.NewDevice.WebhookURL, cfg.NewDevice.Timeout), storage, cfg.NewDevice.RevokeTTL,
+		))
+	default:
+		panic("unsupported new device notify mode: " + cfg.NewDevice.Notify)
+	}
+
+	if cfg.GeoIP.File != "" {
+		resolver, err := geoip.OpenMaxMind(cfg.GeoIP.File)
+		if err != nil {
+			panic(err)
+		}
+		authOpts = append(authOpts, auth.WithGeoIP(resolver))
+	}
+
+	verifier := newCaptchaVerifier(cfg.Captcha)
+	if verifier != nil {
+		authOpts = append(authOpts, auth.WithCaptcha(verifier, cfg.Captcha.FlagTTL, cfg.Captcha.OnRegister))
+	}
+
+	if sender := newSMSSender(cfg.SMS); sender != nil {
+		authOpts = append(authOpts, auth.WithSMSLogin(sender, cfg.SMS.CodeTTL, newLimiter(cfg.SMS.Requests)))
+	}
+
+	switch cfg.LoginRisk.Action {
+	case config.LoginRiskOff:
+	case config.LoginRiskLog, config.LoginRiskStepUp, config.LoginRiskDeny, config.LoginRiskCaptcha:
+		if cfg.GeoIP.File == "" {
+			panic("login risk checks need a geoip file")
+		}
+		if cfg.LoginRisk.Action == config.LoginRiskCaptcha && verifier == nil {
+			panic("login risk action captcha needs a captcha provider")
+		}
+		authOpts = append(authOpts, auth.WithLoginRiskPolicy(auth.LoginRiskPolicy{
+			Action:        auth.RiskAction(cfg.LoginRisk.Action),
+			Window:        cfg.LoginRisk.Window,
+			MaxSpeedKmh:   cfg.LoginRisk.MaxSpeedKmh,
+			MinDistanceKm: cfg.LoginRisk.MinDistanceKm,
+			NewCountry:    cfg.LoginRisk.NewCountry,
+		}))
+	default:
+		panic("unsupported login risk action: " + cfg.LoginRisk.Action)
+	}
+
+	switch cfg.BreachCheck.Mode {
+	case config.BreachCheckOff:
+	case config.BreachCheckWarn, config.BreachCheckEnforce:
+		var checker pwned.Checker
+		if cfg.BreachCheck.BloomFile != "" {
+			checker, err = pwned.LoadBloom(cfg.BreachCheck.BloomFile)
+			if err != nil {
+				panic(err)
+			}
+		} else {
+			checker = pwned.NewRangeClient(cfg.BreachCheck.RangeURL, cfg.BreachCheck.Timeout, cfg.BreachCheck.CacheTTL, cfg.BreachCheck.CacheSize)
+		}
+		authOpts = append(authOpts, auth.WithBreachedPasswordCheck(
+			checker, cfg.BreachCheck.Mode == config.BreachCheckEnforce, cfg.BreachCheck.Timeout,
+		))
+	default:
+		panic("unsupported breach check mode: " + cfg.BreachCheck.Mode)
+	}
+
+	if cfg.Tarpit.BreachedPairsFile != "" || len(cfg.Tarpit.UserAgents) > 0 {
+		var breached []string
+		if cfg.Tarpit.BreachedPairsFile != "" {
+			breached, err = tarpit.LoadBreachedPairs(cfg.Tarpit.BreachedPairsFile)
+			if err != nil {
+				panic(err)
+			}
+		}
+		authOpts = append(authOpts, auth.WithTarpit(tarpit.New(breached, cfg.Tarpit.UserAgents), cfg.Tarpit.Delay))
+	}
+
+	lockoutCfg := cfg.RateLimit.Lockout
+	var lockoutStore lockout.Store
+	if lockoutCfg.Store == config.LockoutStoreStorage {
+		lockoutStore = storage
+	}
+	if len(lockoutCfg.Tiers) > 0 {
+		tracker := lockout.New(lockoutTiers(lockoutCfg.Tiers), lockoutCfg.CoolDown, lockoutCfg.Window, lockoutStore)
+		authOpts = append(authOpts, auth.WithLockout(tracker, lockoutCfg.Conceal))
+	}
+	if len(lockoutCfg.IPTiers) > 0 {
+		tracker := lockout.New(lockoutTiers(lockoutCfg.IPTiers), lockoutCfg.CoolDown, lockoutCfg.Window, lockoutStore)
+		authOpts = append(authOpts, auth.WithIPLockout(tracker))
+	}
+
+	if len(cfg.RolePermissions) > 0 {
+		permissions := make(map[models.AccountRole][]string, len(cfg.RolePermissions))
+		for role, perms := range cfg.RolePermissions {
+			permissions[models.AccountRole(role)] = perms
+		}
+		authOpts = append(authOpts, auth.WithRolePermissions(permissions))
+	}
+
+	switch cfg.TokenSubject.Format {
+	case config.SubjectUUID:
+		authOpts = append(authOpts, auth.WithSubjectFormat(jwt.UUIDSubject{}))
+	case config.SubjectPrefixed:
+		authOpts = append(authOpts, auth.WithSubjectFormat(jwt.PrefixedSubject{Prefix: cfg.TokenSubject.Prefix}))
+	}
+
+	if cfg.JWTClaims.Issuer != "" {
+		authOpts = append(authOpts, auth.WithTokenIssuer(cfg.JWTClaims.Issuer))
+	}
+	if cfg.JWTClaims.Leeway > 0 {
+		authOpts = append(authOpts, auth.WithClockSkewLeeway(cfg.JWTClaims.Leeway))
+	}
+	if cfg.TokenRevocationList {
+		authOpts = append(authOpts, auth.WithTokenRevocationList(storage))
+	}
+	if cfg.JWTRefreshTokens {
+		authOpts = append(authOpts, auth.WithJWTRefreshTokens())
+	}
+	if keyProvider := newKeyProvider(cfg.SigningKeys.KeyProvider); keyProvider != nil {
+		authOpts = append(authOpts, auth.WithKeyProvider(keyProvider))
+	}
+
+	idlePolicy := auth.IdlePolicy{Timeout: cfg.SessionIdle.Timeout, Apps: cfg.SessionIdle.Apps}
+	if len(cfg.SessionIdle.Roles) > 0 {
+		idlePolicy.Roles = make(map[models.AccountRole]time.Duration, len(cfg.SessionIdle.Roles))
+		for role, timeout := range cfg.SessionIdle.Roles {
+			idlePolicy.Roles[models.AccountRole(role)] = timeout
+		}
+	}
+	authOpts = append(authOpts, auth.WithIdleTimeout(idlePolicy))
+
+	authService := auth.New(log, storage, storage, storage, storage, storage, storage, storage, storage, storage, storage, storage, cfg.TokenTTL, cfg.RefreshTTL, authOpts...)
+
+	tlsCfg, err := cfg.GRPC.TLS.ServerConfig()
 	if err != nil {
 		panic(err)
 	}
 
-	authService := auth.New(log, storage, storage, storage, storage, storage, storage, tokenTTL, refreshTokenTTL)
+	grpcApp := grpcapp.New(log, authService, cfg.GRPC, tlsCfg, grpcapp.Limiters{
+		Login:             newLimiter(cfg.RateLimit.Login),
+		RefreshPerIP:      newLimiter(cfg.RateLimit.Refresh.PerIP),
+		RefreshPerAccount: newLimiter(cfg.RateLimit.Refresh.PerAccount),
+	})
 
-	grpcApp := grpcapp.New(log, authService, grpcPort)
+	var workers []*worker.Worker
+	if cfg.Dormancy.Enabled {
+		if cfg.Dormancy.Reactivation.WebhookURL == "" {
+			panic("dormancy requires a reactivation webhook: dormant accounts could never log in again")
+		}
+		workers = append(workers, worker.New(log, "dormancy", cfg.Dormancy.Interval, func(ctx context.Context) error {
+			_, err := authService.DeactivateDormantAccounts(ctx, cfg.Dormancy.Threshold, cfg.Dormancy.BatchSize)
+			return err
+		}))
+	}
+	if cfg.Deletion.Enabled {
+		workers = append(workers, worker.New(log, "account_purge", cfg.Deletion.Interval, func(ctx context.Context) error {
+			_, err := authService.PurgeDeletedAccounts(ctx, cfg.Deletion.BatchSize)
+			return err
+		}))
+	}
+	if cfg.SessionCleanup.Enabled {
+		workers = append(workers, worker.New(log, "session_cleanup", cfg.SessionCleanup.Interval, func(ctx context.Context) error {
+			_, err := authService.Cleanup(ctx, cfg.SessionCleanup.BatchSize, cfg.SessionCleanup.RevokedRetention)
+			return err
+		}))
+	}
+
+	if cfg.SigningKeys.RotationInterval > 0 {
+		workers = append(workers, worker.New(log, "signing_key_rotation", cfg.SigningKeys.CheckInterval, func(ctx context.Context) error {
+			_, err := authService.RotateSigningKeys(ctx, cfg.SigningKeys.RotationInterval)
+			return err
+		}))
+	}
+
+	var httpApp *httpapp.App
+	if cfg.JWKS.Port > 0 {
+		httpApp = httpapp.New(log, authService, cfg.JWKS)
+	}
 
 	return &App{
 		GRPCServer: grpcApp,
+		HTTPServer: httpApp,
+		Workers:    workers,
+		log:        log,
+		storage:    storage,
+	}
+}
+
+// newKeyring builds the at-rest encryption keyring. It returns nil when no keys are
+// configured, leaving encrypted columns unavailable.
+func newKeyring(cfg config.EncryptionConfig) (*encryption.Keyring, error) {
+	if len(cfg.Keys) == 0 {
+		return nil, nil
+	}
+
+	keys := make(map[byte][]byte, len(cfg.Keys))
+	for version, encoded := range cfg.Keys {
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("encryption key version %d: %w", version, err)
+		}
+		keys[version] = key
+	}
+
+	return encryption.NewKeyring(cfg.CurrentKeyVersion, keys)
+}
+
+// loadPepper returns the password pepper from the configured file, or inline from
+// the config or environment, and nil if neither is set.
+func loadPepper(cfg config.PasswordHashConfig) ([]byte, error) {
+	if cfg.PepperFile == "" {
+		if cfg.Pepper == "" {
+			return nil, nil
+		}
+		return []byte(cfg.Pepper), nil
+	}
+
+	pepper, err := os.ReadFile(cfg.PepperFile)
+	if err != nil {
+		return nil, fmt.Errorf("password pepper: %w", err)
+	}
+
+	pepper = bytes.TrimSpace(pepper)
+	if len(pepper) == 0 {
+		return nil, fmt.Errorf("password pepper: %s is empty", cfg.PepperFile)
+	}
+
+	return pepper, nil
+}
+
+// newCaptchaVerifier returns the verifier for the configured CAPTCHA provider, nil
+// if it is off. It panics on unknown providers and a missing secret.
+func newCaptchaVerifier(cfg config.CaptchaConfig) captcha.Verifier {
+	if cfg.Provider == config.CaptchaOff {
+		return nil
+	}
+	if cfg.Secret == "" {
+		panic("captcha secret is required")
+	}
+
+	var (
+		verifyURL string
+		minScore  float64
+	)
+	switch cfg.Provider {
+	case config.CaptchaReCAPTCHA:
+		verifyURL, minScore = captcha.ReCAPTCHAURL, cfg.MinScore
+	case config.CaptchaHCaptcha:
+		verifyURL = captcha.HCaptchaURL
+	case config.CaptchaTurnstile:
+		verifyURL = captcha.TurnstileURL
+	default:
+		panic("unsupported captcha provider: " + cfg.Provider)
+	}
+	if cfg.VerifyURL != "" {
+		verifyURL = cfg.VerifyURL
+	}
+
+	return captcha.NewSiteVerify(verifyURL, cfg.Secret, minScore, cfg.Timeout)
+}
+
+func newSMSSender(cfg config.SMSConfig) auth.SMSSender {
+	switch cfg.Provider {
+	case config.SMSOff:
+		return nil
+	case config.SMSWebhook:
+		if cfg.WebhookURL == "" {
+			panic("sms webhook url is required")
+		}
+		return sms.NewWebhook(cfg.WebhookURL, cfg.Timeout)
+	case config.SMSTwilio:
+		if cfg.TwilioAccountSID == "" || cfg.TwilioAuthToken == "" || cfg.From == "" {
+			panic("twilio account sid, auth token and sender are required")
+		}
+		return sms.NewTwilio(cfg.TwilioAccountSID, cfg.TwilioAuthToken, cfg.From, cfg.Timeout)
+	default:
+		panic("unsupported sms provider: " + cfg.Provider)
 	}
 }
+
+// newKeyProvider returns the configured signing key provider, nil if it is off.
+// It panics on unknown providers and missing settings.
+func newKeyProvider(cfg config.KeyProviderConfig) auth.KeyProvider {
+	switch cfg.Provider {
+	case config.KeyProviderOff:
+		return nil
+	case config.KeyProviderFile:
+		if cfg.Dir == "" {
+			panic("key provider dir is required")
+		}
+		return keyprovider.NewFile(cfg.Dir)
+	case config.KeyProviderEnv:
+		return keyprovider.NewEnv()
+	case config.KeyProviderVault:
+		if cfg.VaultAddr == "" || cfg.VaultToken == "" {
+			panic("vault address and token are required")
+		}
+		return keyprovider.NewVault(cfg.VaultAddr, cfg.VaultToken, cfg.VaultNamespace, cfg.VaultMount, cfg.VaultField, cfg.Timeout)
+	case config.KeyProviderKMS:
+		if cfg.KMSRegion == "" || cfg.AWSAccessKeyID == "" || cfg.AWSSecretAccessKey == "" {
+			panic("kms region and aws credentials are required")
+		}
+		endpoint := cfg.KMSEndpoint
+		if endpoint == "" {
+			endpoint = keyprovider.KMSEndpoint(cfg.KMSRegion)
+		}
+		return keyprovider.NewKMS(endpoint, cfg.KMSRegion, keyprovider.Credentials{
+			AccessKeyID:     cfg.AWSAccessKeyID,
+			SecretAccessKey: cfg.AWSSecretAccessKey,
+			SessionToken:    cfg.AWSSessionToken,
+		}, cfg.Timeout)
+	default:
+		panic("unsupported key provider: " + cfg.Provider)
+	}
+}
+
+// newLimiter returns nil for a limit without requests, which disables it.
+func newLimiter(cfg config.LimitConfig) *ratelimit.Limiter {
+	if cfg.Requests <= 0 {
+		return nil
+	}
+
+	return ratelimit.New(cfg.Requests, cfg.Window)
+}
+
+func passwordRules(cfg config.PasswordRules) passwordpolicy.Rules {
+	return passwordpolicy.Rules{
+		MinLength:     cfg.MinLength,
+		MaxLength:     cfg.MaxLength,
+		RequireUpper:  cfg.RequireUpper,
+		RequireLower:  cfg.RequireLower,
+		RequireDigit:  cfg.RequireDigit,
+		RequireSymbol: cfg.RequireSymbol,
+	}
+}
+
+func lockoutTiers(cfg []config.LockoutTier) []lockout.Tier {
+	tiers := make([]lockout.Tier, 0, len(cfg))
+	for _, tier := range cfg {
+		tiers = append(tiers, lockout.Tier{Failures: tier.Failures, Duration: tier.Duration})
+	}
+
+	return tiers
+}
@@ -0,0 +1,72 @@
+package grpcapp
+
+import (
+	"context"
+	"net/netip"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	ssov1 "github.com/dariasmyr/protos/gen/go/sso"
+
+	"sso/internal/lib/peerip"
+)
+
+// defaultAdminMethods are the RPCs guarded when no methods are configured.
+var defaultAdminMethods = []string{
+	ssov1.Auth_RegisterClient_FullMethodName,
+	ssov1.Auth_ChangeStatus_FullMethodName,
+}
+
+// adminAccessInterceptor rejects calls to admin methods from client IPs that are
+// denied or, with a non-empty allow-list, not allowed. It runs before any role
+// check, so even admins are refused from untrusted networks.
+func adminAccessInterceptor(allowed, denied []netip.Prefix, methods []string) grpc.UnaryServerInterceptor {
+	if len(methods) == 0 {
+		methods = defaultAdminMethods
+	}
+
+	guarded := make(map[string]struct{}, len(methods))
+	for _, method := range methods {
+		guarded[method] = struct{}{}
+	}
+
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if _, ok := guarded[info.FullMethod]; !ok {
+			return handler(ctx, req)
+		}
+
+		if !ipAdmitted(peerip.FromContext(ctx), allowed, denied) {
+			return nil, status.Error(codes.PermissionDenied, "admin operations are not allowed from this address")
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+func ipAdmitted(ip string, allowed, denied []netip.Prefix) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+
+	for _, prefix := range denied {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+
+	if len(allowed) == 0 {
+		return true
+	}
+
+	for _, prefix := range allowed {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,36 @@
+package grpcapp
+
+import (
+	"net/netip"
+	"testing"
+)
+
+func TestIPAdmitted(t *testing.T) {
+	office := []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}
+	blocked := []netip.Prefix{netip.MustParsePrefix("10.6.6.0/24")}
+
+	tests := []struct {
+		name    string
+		ip      string
+		allowed []netip.Prefix
+		denied  []netip.Prefix
+		want    bool
+	}{
+		{name: "no lists", ip: "198.51.100.1", want: true},
+		{name: "allowed", ip: "10.1.2.3", allowed: office, want: true},
+		{name: "outside allow-list", ip: "198.51.100.1", allowed: office, want: false},
+		{name: "denied", ip: "10.6.6.6", denied: blocked, want: false},
+		{name: "deny wins over allow", ip: "10.6.6.6", allowed: office, denied: blocked, want: false},
+		{name: "not denied", ip: "10.1.2.3", denied: blocked, want: true},
+		{name: "ipv4-mapped ipv6", ip: "::ffff:10.1.2.3", allowed: office, want: true},
+		{name: "unknown ip", ip: "", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ipAdmitted(tt.ip, tt.allowed, tt.denied); got != tt.want {
+				t.Errorf("ipAdmitted(%q) = %v, want %v", tt.ip, got, tt.want)
+			}
+		})
+	}
+}
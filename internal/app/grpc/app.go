@@ -2,14 +2,24 @@ package grpcapp
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
 	"github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/logging"
 	"github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/recovery"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/keepalive"
 	"google.golang.org/grpc/status"
 	"log/slog"
 	"net"
+	"sso/config"
 	authgrpc "sso/internal/grpc/auth"
+	"strings"
+	"sync/atomic"
+
+	ssov1 "github.com/dariasmyr/protos/gen/go/sso"
 
 	"google.golang.org/grpc"
 )
@@ -17,6 +27,8 @@ import (
 type App struct {
 	log        *slog.Logger
 	gRPCServer *grpc.Server
+	health     *health.Server
+	ready      *atomic.Bool
 	port       int
 }
 
@@ -27,7 +39,8 @@ func InterceptorLogger(l *slog.Logger) logging.Logger {
 	})
 }
 
-func New(log *slog.Logger, authService authgrpc.Auth, port int) *App {
+// New builds the gRPC server. A nil tlsCfg serves plaintext.
+func New(log *slog.Logger, authService authgrpc.Auth, cfg config.GRPCConfig, tlsCfg *tls.Config, limiters Limiters) *App {
 	loggingOpts := []logging.Option{
 		logging.WithLogOnEvents(
 			logging.PayloadReceived, logging.PayloadSent,
@@ -43,17 +56,101 @@ func New(log *slog.Logger, authService authgrpc.Auth, port int) *App {
 		}),
 	}
 
-	gRPCServer := grpc.NewServer(grpc.ChainUnaryInterceptor(
+	ready := &atomic.Bool{}
+
+	interceptors := []grpc.UnaryServerInterceptor{
 		recovery.UnaryServerInterceptor(recoveryOpts...),
 		logging.UnaryServerInterceptor(InterceptorLogger(log), loggingOpts...),
-	))
+		readinessInterceptor(ready),
+	}
+
+	// Derive the client IP first, so IP checks and rate limits see the client
+	// rather than the proxy in front of it.
+	if len(cfg.TrustedProxies) > 0 {
+		trusted, err := cfg.TrustedProxyPrefixes()
+		if err != nil {
+			panic("invalid trusted proxies config: " + err.Error())
+		}
+		interceptors = append(interceptors, clientIPInterceptor(trusted))
+	}
+
+	if cfg.AdminAccess.Enabled() {
+		allowed, denied, err := cfg.AdminAccess.Prefixes()
+		if err != nil {
+			panic("invalid admin access config: " + err.Error())
+		}
+		interceptors = append(interceptors, adminAccessInterceptor(allowed, denied, cfg.AdminAccess.Methods))
+	}
+
+	interceptors = append(interceptors, limiters.interceptors()...)
+
+	if len(cfg.FreshAuth) > 0 {
+		interceptors = append(interceptors, freshAuthInterceptor(authService, cfg.FreshAuth))
+	}
+
+	gRPCServer := grpc.NewServer(serverOptions(cfg, tlsCfg, interceptors)...)
 
 	authgrpc.Register(gRPCServer, authService)
 
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus("", healthpb.HealthCheckResponse_NOT_SERVING)
+	healthServer.SetServingStatus(ssov1.Auth_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_NOT_SERVING)
+	healthpb.RegisterHealthServer(gRPCServer, healthServer)
+
 	return &App{
 		log:        log,
 		gRPCServer: gRPCServer,
-		port:       port,
+		health:     healthServer,
+		ready:      ready,
+		port:       cfg.Port,
+	}
+}
+
+// serverOptions applies the stream and connection-age limits from cfg. Zero values
+// keep the gRPC defaults.
+func serverOptions(cfg config.GRPCConfig, tlsCfg *tls.Config, interceptors []grpc.UnaryServerInterceptor) []grpc.ServerOption {
+	opts := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(interceptors...),
+		grpc.KeepaliveParams(keepalive.ServerParameters{
+			MaxConnectionAge:      cfg.MaxConnectionAge,
+			MaxConnectionAgeGrace: cfg.MaxConnectionAgeGrace,
+		}),
+	}
+
+	if cfg.MaxConcurrentStreams > 0 {
+		opts = append(opts, grpc.MaxConcurrentStreams(cfg.MaxConcurrentStreams))
+	}
+
+	if tlsCfg != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsCfg)))
+	}
+
+	return opts
+}
+
+// SetServing opens the server for Auth calls and reports SERVING on health. Until
+// then Auth calls fail with Unavailable and health reports NOT_SERVING.
+func (a *App) SetServing() {
+	a.ready.Store(true)
+	a.health.SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
+	a.health.SetServingStatus(ssov1.Auth_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+
+	a.log.Info("grpc server is serving")
+}
+
+// Serving reports whether SetServing opened the server.
+func (a *App) Serving() bool {
+	return a.ready.Load()
+}
+
+// readinessInterceptor rejects everything but health checks until ready is set.
+func readinessInterceptor(ready *atomic.Bool) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if ready.Load() || strings.HasPrefix(info.FullMethod, "/"+healthpb.Health_ServiceDesc.ServiceName+"/") {
+			return handler(ctx, req)
+		}
+
+		return nil, status.Error(codes.Unavailable, "server is starting")
 	}
 }
 
@@ -79,3 +176,14 @@ func (a *App) Run() error {
 
 	return nil
 }
+
+// Stop stops the gRPC server, waiting for in-flight requests to finish.
+func (a *App) Stop() {
+	const op = "grpcapp.Stop"
+
+	a.log.With(slog.String("op", op)).
+		Info("stopping gRPC server", slog.Int("port", a.port))
+
+	a.health.Shutdown()
+	a.gRPCServer.GracefulStop()
+}
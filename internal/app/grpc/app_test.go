@@ -0,0 +1,102 @@
+package grpcapp
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"sso/config"
+)
+
+// startServer serves every method with handler under the options from cfg and
+// returns a client connection to it.
+func startServer(t *testing.T, cfg config.GRPCConfig, handler grpc.StreamHandler) *grpc.ClientConn {
+	t.Helper()
+
+	lis := bufconn.Listen(1 << 20)
+	server := grpc.NewServer(append(serverOptions(cfg, nil, nil), grpc.UnknownServiceHandler(handler))...)
+	go func() { _ = server.Serve(lis) }()
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	return conn
+}
+
+func TestServerOptionsMaxConcurrentStreams(t *testing.T) {
+	tests := []struct {
+		name       string
+		maxStreams uint32
+		wantSecond codes.Code
+	}{
+		{name: "gRPC default", maxStreams: 0, wantSecond: codes.OK},
+		{name: "one stream", maxStreams: 1, wantSecond: codes.DeadlineExceeded},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entered := make(chan struct{}, 2)
+			release := make(chan struct{})
+			conn := startServer(t, config.GRPCConfig{MaxConcurrentStreams: tt.maxStreams}, func(any, grpc.ServerStream) error {
+				entered <- struct{}{}
+				<-release
+				return nil
+			})
+			defer close(release)
+
+			go func() { _ = conn.Invoke(context.Background(), "/test.Blocking/Call", nil, nil) }()
+			<-entered
+
+			ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+			defer cancel()
+			go func() { _ = conn.Invoke(ctx, "/test.Blocking/Call", nil, nil) }()
+
+			select {
+			case <-entered:
+				if tt.wantSecond != codes.OK {
+					t.Errorf("second call got a stream beyond the limit of %d", tt.maxStreams)
+				}
+			case <-ctx.Done():
+				if tt.wantSecond == codes.OK {
+					t.Errorf("second call didn't get a stream: %v", status.Code(ctx.Err()))
+				}
+			}
+		})
+	}
+}
+
+func TestServerOptionsMaxConnectionAge(t *testing.T) {
+	conn := startServer(t, config.GRPCConfig{MaxConnectionAge: 100 * time.Millisecond, MaxConnectionAgeGrace: 100 * time.Millisecond}, func(any, grpc.ServerStream) error {
+		return nil
+	})
+
+	conn.Connect()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	for state := conn.GetState(); state != connectivity.Ready; state = conn.GetState() {
+		if !conn.WaitForStateChange(ctx, state) {
+			t.Fatalf("connection never became ready, stuck in %v", state)
+		}
+	}
+
+	// The server closes the connection once it is older than the max age.
+	if !conn.WaitForStateChange(ctx, connectivity.Ready) {
+		t.Fatal("connection outlived the max connection age")
+	}
+}
@@ -0,0 +1,19 @@
+package grpcapp
+
+import (
+	"context"
+	"net/netip"
+
+	"google.golang.org/grpc"
+
+	"sso/internal/lib/peerip"
+)
+
+// clientIPInterceptor derives the client IP from the x-forwarded-for header of
+// trusted proxies, see peerip.ClientIP, for everything after it that reads it
+// with peerip.FromContext.
+func clientIPInterceptor(trusted []netip.Prefix) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		return handler(peerip.NewContext(ctx, peerip.ClientIP(ctx, trusted)), req)
+	}
+}
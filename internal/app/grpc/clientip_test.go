@@ -0,0 +1,50 @@
+package grpcapp
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	ssov1 "github.com/dariasmyr/protos/gen/go/sso"
+)
+
+// TestAdminAccessBehindProxy checks that the admin allow-list sees the client
+// behind a trusted proxy, not the proxy.
+func TestAdminAccessBehindProxy(t *testing.T) {
+	proxies := []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}
+	office := []netip.Prefix{netip.MustParsePrefix("203.0.113.0/24")}
+
+	tests := []struct {
+		name         string
+		forwardedFor string
+		want         codes.Code
+	}{
+		{name: "office client", forwardedFor: "203.0.113.9", want: codes.OK},
+		{name: "outside client", forwardedFor: "198.51.100.1", want: codes.PermissionDenied},
+		{name: "spoofed office client", forwardedFor: "203.0.113.9, 198.51.100.1", want: codes.PermissionDenied},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP("10.0.0.2"), Port: 4242}})
+			ctx = metadata.NewIncomingContext(ctx, metadata.Pairs("x-forwarded-for", tt.forwardedFor))
+			info := &grpc.UnaryServerInfo{FullMethod: ssov1.Auth_ChangeStatus_FullMethodName}
+			handler := func(context.Context, any) (any, error) { return "ok", nil }
+
+			admin := adminAccessInterceptor(office, nil, nil)
+			_, err := clientIPInterceptor(proxies)(ctx, nil, info, func(ctx context.Context, req any) (any, error) {
+				return admin(ctx, req, info, handler)
+			})
+			if code := status.Code(err); code != tt.want {
+				t.Errorf("code = %v, want %v", code, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package grpcapp
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"sso/internal/services/auth"
+)
+
+type freshAuthChecker interface {
+	RequireRecentAuth(ctx context.Context, token string, maxAge time.Duration) error
+}
+
+// freshAuthInterceptor rejects calls to the configured methods unless the bearer
+// token in the authorization header was issued for an authentication no older than
+// the method's max age, or its session was elevated by reauthenticating.
+func freshAuthInterceptor(checker freshAuthChecker, maxAges map[string]time.Duration) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		maxAge, ok := maxAges[info.FullMethod]
+		if !ok {
+			return handler(ctx, req)
+		}
+
+		token := bearerToken(ctx)
+		if token == "" {
+			return nil, status.Error(codes.Unauthenticated, "access token is required")
+		}
+
+		if err := checker.RequireRecentAuth(ctx, token, maxAge); err != nil {
+			if errors.Is(err, auth.ErrReauthRequired) {
+				return nil, status.Error(codes.Unauthenticated, "reauthentication required")
+			}
+			return nil, status.Error(codes.Unauthenticated, "invalid access token")
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+func bearerToken(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+
+	values := md.Get("authorization")
+	if len(values) == 0 {
+		return ""
+	}
+
+	token, ok := strings.CutPrefix(values[0], "Bearer ")
+	if !ok {
+		return ""
+	}
+
+	return token
+}
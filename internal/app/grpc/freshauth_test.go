@@ -0,0 +1,63 @@
+package grpcapp
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	ssov1 "github.com/dariasmyr/protos/gen/go/sso"
+
+	"sso/internal/services/auth"
+)
+
+// authAge answers freshness checks as if the token's login was age ago.
+type authAge time.Duration
+
+func (a authAge) RequireRecentAuth(_ context.Context, _ string, maxAge time.Duration) error {
+	if time.Duration(a) > maxAge {
+		return auth.ErrReauthRequired
+	}
+	return nil
+}
+
+func TestFreshAuthInterceptor(t *testing.T) {
+	maxAges := map[string]time.Duration{
+		ssov1.Auth_ChangePassword_FullMethodName: 10 * time.Minute,
+	}
+
+	tests := []struct {
+		name    string
+		method  string
+		age     time.Duration
+		md      metadata.MD
+		want    codes.Code
+		wantMsg string
+	}{
+		{name: "just inside", method: ssov1.Auth_ChangePassword_FullMethodName, age: 10 * time.Minute, md: metadata.Pairs("authorization", "Bearer token"), want: codes.OK},
+		{name: "just outside", method: ssov1.Auth_ChangePassword_FullMethodName, age: 10*time.Minute + time.Second, md: metadata.Pairs("authorization", "Bearer token"), want: codes.Unauthenticated, wantMsg: "reauthentication required"},
+		{name: "no token", method: ssov1.Auth_ChangePassword_FullMethodName, want: codes.Unauthenticated, wantMsg: "access token is required"},
+		{name: "unprotected method", method: ssov1.Auth_Login_FullMethodName, age: time.Hour, want: codes.OK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			interceptor := freshAuthInterceptor(authAge(tt.age), maxAges)
+			ctx := metadata.NewIncomingContext(context.Background(), tt.md)
+			handler := func(context.Context, any) (any, error) { return "ok", nil }
+
+			_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: tt.method}, handler)
+			st := status.Convert(err)
+			if st.Code() != tt.want {
+				t.Fatalf("code = %v, want %v", st.Code(), tt.want)
+			}
+			if tt.wantMsg != "" && st.Message() != tt.wantMsg {
+				t.Errorf("message = %q, want %q", st.Message(), tt.wantMsg)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package grpcapp
+
+import (
+	"context"
+	"math"
+	"strconv"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	ssov1 "github.com/dariasmyr/protos/gen/go/sso"
+
+	"sso/internal/lib/peerip"
+	"sso/internal/lib/ratelimit"
+)
+
+// Limiters holds the optional per-endpoint rate limiters. Nil limiters are skipped.
+type Limiters struct {
+	Login             *ratelimit.Limiter
+	RefreshPerIP      *ratelimit.Limiter
+	RefreshPerAccount *ratelimit.Limiter
+}
+
+func (l Limiters) interceptors() []grpc.UnaryServerInterceptor {
+	var interceptors []grpc.UnaryServerInterceptor
+
+	if l.Login != nil {
+		interceptors = append(interceptors, rateLimitInterceptor(l.Login, peerIPKey, ssov1.Auth_Login_FullMethodName))
+	}
+	if l.RefreshPerIP != nil {
+		interceptors = append(interceptors, rateLimitInterceptor(l.RefreshPerIP, peerIPKey, ssov1.Sessions_RefreshSession_FullMethodName))
+	}
+	if l.RefreshPerAccount != nil {
+		interceptors = append(interceptors, rateLimitInterceptor(l.RefreshPerAccount, refreshAccountKey, ssov1.Sessions_RefreshSession_FullMethodName))
+	}
+
+	return interceptors
+}
+
+// keyFunc picks the rate limit bucket for a call.
+type keyFunc func(ctx context.Context, req any) string
+
+func peerIPKey(ctx context.Context, _ any) string {
+	return peerip.FromContext(ctx)
+}
+
+func refreshAccountKey(_ context.Context, req any) string {
+	if r, ok := req.(*ssov1.RefreshAccountSessionRequest); ok {
+		return strconv.FormatInt(r.GetAccountId(), 10)
+	}
+
+	return ""
+}
+
+// rateLimitInterceptor throttles the given methods per key. Throttled calls fail
+// with ResourceExhausted and carry retry-after and quota info in trailing metadata.
+// The service speaks gRPC only, so there are no HTTP response headers to set; a
+// gateway in front of it has to map the trailers itself.
+func rateLimitInterceptor(limiter *ratelimit.Limiter, key keyFunc, methods ...string) grpc.UnaryServerInterceptor {
+	limited := make(map[string]struct{}, len(methods))
+	for _, method := range methods {
+		limited[method] = struct{}{}
+	}
+
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if _, ok := limited[info.FullMethod]; !ok {
+			return handler(ctx, req)
+		}
+
+		res := limiter.Allow(key(ctx, req))
+		if res.Allowed {
+			return handler(ctx, req)
+		}
+
+		_ = grpc.SetTrailer(ctx, metadata.Pairs(
+			"retry-after", strconv.FormatInt(int64(math.Ceil(res.RetryAfter.Seconds())), 10),
+			"x-ratelimit-limit", strconv.Itoa(res.Limit),
+			"x-ratelimit-remaining", strconv.Itoa(res.Remaining),
+			"x-ratelimit-reset", strconv.FormatInt(res.ResetAt.Unix(), 10),
+		))
+
+		return nil, status.Error(codes.ResourceExhausted, "too many requests")
+	}
+}
@@ -0,0 +1,146 @@
+package grpcapp
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	ssov1 "github.com/dariasmyr/protos/gen/go/sso"
+
+	"sso/internal/lib/ratelimit"
+)
+
+// trailerStream records the trailers a handler sets.
+type trailerStream struct {
+	trailer metadata.MD
+}
+
+func (s *trailerStream) Method() string { return ssov1.Auth_Login_FullMethodName }
+
+func (s *trailerStream) SetHeader(metadata.MD) error { return nil }
+
+func (s *trailerStream) SendHeader(metadata.MD) error { return nil }
+
+func (s *trailerStream) SetTrailer(md metadata.MD) error {
+	s.trailer = metadata.Join(s.trailer, md)
+	return nil
+}
+
+func TestRateLimitInterceptorTrailers(t *testing.T) {
+	interceptor := rateLimitInterceptor(ratelimit.New(1, time.Minute), peerIPKey, ssov1.Auth_Login_FullMethodName)
+	info := &grpc.UnaryServerInfo{FullMethod: ssov1.Auth_Login_FullMethodName}
+	handler := func(context.Context, any) (any, error) { return "ok", nil }
+
+	call := func() (*trailerStream, error) {
+		stream := &trailerStream{}
+		ctx := grpc.NewContextWithServerTransportStream(context.Background(), stream)
+		ctx = peer.NewContext(ctx, &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP("198.51.100.1"), Port: 4242}})
+		_, err := interceptor(ctx, nil, info, handler)
+		return stream, err
+	}
+
+	stream, err := call()
+	if err != nil {
+		t.Fatalf("first call: %v", err)
+	}
+	if len(stream.trailer) != 0 {
+		t.Errorf("allowed call got trailers %v", stream.trailer)
+	}
+
+	stream, err = call()
+	if code := status.Code(err); code != codes.ResourceExhausted {
+		t.Fatalf("second call code = %v, want ResourceExhausted", code)
+	}
+
+	retryAfter := trailerInt(t, stream.trailer, "retry-after")
+	if retryAfter < 1 || retryAfter > 60 {
+		t.Errorf("retry-after = %d, want within the 60s window", retryAfter)
+	}
+	if limit := trailerInt(t, stream.trailer, "x-ratelimit-limit"); limit != 1 {
+		t.Errorf("x-ratelimit-limit = %d, want 1", limit)
+	}
+	if remaining := trailerInt(t, stream.trailer, "x-ratelimit-remaining"); remaining != 0 {
+		t.Errorf("x-ratelimit-remaining = %d, want 0", remaining)
+	}
+	reset := trailerInt(t, stream.trailer, "x-ratelimit-reset")
+	if until := time.Until(time.Unix(int64(reset), 0)); until < -time.Second || until > time.Minute {
+		t.Errorf("x-ratelimit-reset is %v away, want within the window", until)
+	}
+}
+
+func trailerInt(t *testing.T, md metadata.MD, key string) int {
+	t.Helper()
+
+	values := md.Get(key)
+	if len(values) != 1 {
+		t.Fatalf("%s trailer = %v, want one value", key, values)
+	}
+
+	n, err := strconv.Atoi(values[0])
+	if err != nil {
+		t.Fatalf("%s trailer %q: %v", key, values[0], err)
+	}
+
+	return n
+}
+
+func TestRefreshLimiters(t *testing.T) {
+	tests := []struct {
+		name     string
+		limiters Limiters
+		// calls are the account IDs refreshing from the same IP, in order.
+		calls []int64
+		want  []codes.Code
+	}{
+		{
+			name:     "per account",
+			limiters: Limiters{RefreshPerAccount: ratelimit.New(2, time.Minute)},
+			calls:    []int64{1, 1, 2, 1},
+			want:     []codes.Code{codes.OK, codes.OK, codes.OK, codes.ResourceExhausted},
+		},
+		{
+			name:     "per ip",
+			limiters: Limiters{RefreshPerIP: ratelimit.New(2, time.Minute)},
+			calls:    []int64{1, 2, 3},
+			want:     []codes.Code{codes.OK, codes.OK, codes.ResourceExhausted},
+		},
+		{
+			name:     "login limiter doesn't apply",
+			limiters: Limiters{Login: ratelimit.New(1, time.Minute)},
+			calls:    []int64{1, 1, 1},
+			want:     []codes.Code{codes.OK, codes.OK, codes.OK},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			interceptors := tt.limiters.interceptors()
+			info := &grpc.UnaryServerInfo{FullMethod: ssov1.Sessions_RefreshSession_FullMethodName}
+
+			for i, accountID := range tt.calls {
+				ctx := grpc.NewContextWithServerTransportStream(context.Background(), &trailerStream{})
+				ctx = peer.NewContext(ctx, &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP("198.51.100.1"), Port: 4242}})
+				req := &ssov1.RefreshAccountSessionRequest{AccountId: accountID, RefreshToken: "refresh-token"}
+
+				handler := func(context.Context, any) (any, error) { return "ok", nil }
+				for j := len(interceptors) - 1; j >= 0; j-- {
+					interceptor, next := interceptors[j], handler
+					handler = func(ctx context.Context, req any) (any, error) { return interceptor(ctx, req, info, next) }
+				}
+
+				_, err := handler(ctx, req)
+				if code := status.Code(err); code != tt.want[i] {
+					t.Errorf("call %d for account %d: code = %v, want %v", i+1, accountID, code, tt.want[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package httpapp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"sso/config"
+	"sso/internal/lib/jwt"
+	"sso/internal/lib/logger/sl"
+	"sso/internal/services/auth"
+)
+
+const readHeaderTimeout = 5 * time.Second
+
+// KeySets provides the JWK Sets served by the listener.
+type KeySets interface {
+	AppJWKS(ctx context.Context, appID int32) (jwt.JWKS, error)
+}
+
+// App is the HTTP listener publishing the apps' token verification keys, for
+// services that verify tokens themselves.
+type App struct {
+	log         *slog.Logger
+	server      *http.Server
+	keySets     KeySets
+	cacheMaxAge time.Duration
+	port        int
+}
+
+func New(log *slog.Logger, keySets KeySets, cfg config.JWKSConfig) *App {
+	a := &App{
+		log:         log,
+		keySets:     keySets,
+		cacheMaxAge: cfg.CacheMaxAge,
+		port:        cfg.Port,
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /apps/{app_id}/jwks.json", func(w http.ResponseWriter, r *http.Request) {
+		a.serveJWKS(w, r, r.PathValue("app_id"))
+	})
+	mux.HandleFunc("GET /.well-known/jwks.json", func(w http.ResponseWriter, r *http.Request) {
+		a.serveJWKS(w, r, r.URL.Query().Get("app_id"))
+	})
+
+	a.server = &http.Server{
+		Addr:              fmt.Sprintf(":%d", cfg.Port),
+		Handler:           mux,
+		ReadHeaderTimeout: readHeaderTimeout,
+	}
+
+	return a
+}
+
+func (a *App) serveJWKS(w http.ResponseWriter, r *http.Request, rawAppID string) {
+	appID, err := strconv.ParseInt(rawAppID, 10, 32)
+	if err != nil || appID <= 0 {
+		http.Error(w, "app_id is required", http.StatusBadRequest)
+		return
+	}
+
+	set, err := a.keySets.AppJWKS(r.Context(), int32(appID))
+	if err != nil {
+		if errors.Is(err, auth.ErrAppNotFound) {
+			http.Error(w, "app not found", http.StatusNotFound)
+			return
+		}
+		a.log.Error("failed to serve jwks", sl.Err(err), slog.Int64("app_id", appID))
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/jwk-set+json")
+	if a.cacheMaxAge > 0 {
+		w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(a.cacheMaxAge.Seconds())))
+	}
+
+	if err := json.NewEncoder(w).Encode(set); err != nil {
+		a.log.Warn("failed to write jwks", sl.Err(err))
+	}
+}
+
+func (a *App) MustRun() {
+	if err := a.Run(); err != nil {
+		panic(err)
+	}
+}
+
+func (a *App) Run() error {
+	const op = "httpapp.Run"
+
+	l, err := net.Listen("tcp", a.server.Addr)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	a.log.Info("http server started", slog.String("addr", l.Addr().String()))
+
+	if err := a.server.Serve(l); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// Stop stops the HTTP server, waiting up to timeout for in-flight requests.
+func (a *App) Stop(timeout time.Duration) {
+	const op = "httpapp.Stop"
+
+	log := a.log.With(slog.String("op", op))
+
+	log.Info("stopping http server", slog.Int("port", a.port))
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if err := a.server.Shutdown(ctx); err != nil {
+		log.Warn("http server did not stop cleanly", sl.Err(err))
+	}
+}
@@ -0,0 +1,57 @@
+package app
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"sso/internal/app/worker"
+)
+
+// workerStopTimeout bounds how long Stop waits for in-flight worker jobs.
+const workerStopTimeout = 10 * time.Second
+
+// StartWorkers runs each background worker in its own goroutine until ctx is done
+// or Stop is called.
+func (a *App) StartWorkers(ctx context.Context) {
+	ctx, a.stopWorkers = context.WithCancel(ctx)
+
+	for _, w := range a.Workers {
+		a.workersDone.Add(1)
+		go func(w *worker.Worker) {
+			defer a.workersDone.Done()
+			w.Run(ctx)
+		}(w)
+	}
+}
+
+// Stop cancels the background workers, gracefully stops the gRPC and HTTP servers
+// and then waits up to workerStopTimeout for the workers to return.
+func (a *App) Stop() {
+	const op = "app.Stop"
+
+	log := a.log.With(slog.String("op", op))
+
+	if a.stopWorkers != nil {
+		a.stopWorkers()
+	}
+
+	a.GRPCServer.Stop()
+
+	if a.HTTPServer != nil {
+		a.HTTPServer.Stop(workerStopTimeout)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		a.workersDone.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		log.Info("workers stopped")
+	case <-time.After(workerStopTimeout):
+		log.Warn("workers did not stop in time", slog.Duration("timeout", workerStopTimeout))
+	}
+}
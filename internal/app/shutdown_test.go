@@ -0,0 +1,54 @@
+package app
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"sso/internal/app/worker"
+)
+
+func TestStopCancelsWorkers(t *testing.T) {
+	a := newStartupApp(&slowStorage{up: make(chan struct{})})
+
+	running := make(chan struct{})
+	aborted := make(chan struct{})
+	a.Workers = []*worker.Worker{
+		worker.New(slog.New(slog.NewTextHandler(io.Discard, nil)), "test", time.Millisecond, func(ctx context.Context) error {
+			select {
+			case running <- struct{}{}:
+			default:
+			}
+			<-ctx.Done()
+			close(aborted)
+			return ctx.Err()
+		}),
+	}
+
+	a.StartWorkers(context.Background())
+	select {
+	case <-running:
+	case <-time.After(time.Second):
+		t.Fatal("worker never ran")
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		a.Stop()
+	}()
+
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("Stop still waiting for workers")
+	}
+
+	select {
+	case <-aborted:
+	default:
+		t.Error("Stop returned before the in-flight job was cancelled")
+	}
+}
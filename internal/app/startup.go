@@ -0,0 +1,57 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"sso/internal/lib/logger/sl"
+)
+
+const (
+	readyInitialBackoff = 100 * time.Millisecond
+	readyMaxBackoff     = 5 * time.Second
+)
+
+// pinger is the storage as startup sees it.
+type pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// MustWaitReady pings storage with exponential backoff and opens the gRPC server
+// once it answers. It panics if storage isn't reachable within timeout.
+func (a *App) MustWaitReady(ctx context.Context, timeout time.Duration) {
+	if err := a.WaitReady(ctx, timeout); err != nil {
+		panic(err)
+	}
+}
+
+func (a *App) WaitReady(ctx context.Context, timeout time.Duration) error {
+	const op = "app.WaitReady"
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	backoff := readyInitialBackoff
+	for {
+		err := a.storage.Ping(ctx)
+		if err == nil {
+			break
+		}
+
+		a.log.Warn("storage not ready", sl.Err(err), slog.Duration("retry_in", backoff))
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%s: storage not ready: %w", op, err)
+		case <-time.After(backoff):
+		}
+
+		backoff = min(backoff*2, readyMaxBackoff)
+	}
+
+	a.GRPCServer.SetServing()
+
+	return nil
+}
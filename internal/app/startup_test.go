@@ -0,0 +1,81 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"sso/config"
+	grpcapp "sso/internal/app/grpc"
+)
+
+// slowStorage fails pings until up is closed.
+type slowStorage struct {
+	up    chan struct{}
+	pings int
+}
+
+func (s *slowStorage) Ping(context.Context) error {
+	s.pings++
+	select {
+	case <-s.up:
+		return nil
+	default:
+		return errors.New("connection refused")
+	}
+}
+
+func newStartupApp(storage pinger) *App {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	return &App{
+		GRPCServer: grpcapp.New(log, nil, config.GRPCConfig{}, nil, grpcapp.Limiters{}),
+		log:        log,
+		storage:    storage,
+	}
+}
+
+func TestWaitReadyServesOnceStorageIsUp(t *testing.T) {
+	storage := &slowStorage{up: make(chan struct{})}
+	a := newStartupApp(storage)
+
+	done := make(chan error, 1)
+	go func() { done <- a.WaitReady(context.Background(), 5*time.Second) }()
+
+	time.Sleep(300 * time.Millisecond)
+	if a.GRPCServer.Serving() {
+		t.Fatal("serving before storage is up")
+	}
+	close(storage.up)
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("wait ready: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("still waiting after storage came up")
+	}
+
+	if !a.GRPCServer.Serving() {
+		t.Error("not serving after storage came up")
+	}
+	if storage.pings < 2 {
+		t.Errorf("storage pinged %d times, want retries", storage.pings)
+	}
+}
+
+func TestWaitReadyFailsWhenStorageNeverComesUp(t *testing.T) {
+	a := newStartupApp(&slowStorage{up: make(chan struct{})})
+
+	if err := a.WaitReady(context.Background(), 200*time.Millisecond); err == nil {
+		t.Fatal("wait ready succeeded without storage")
+	}
+
+	if a.GRPCServer.Serving() {
+		t.Error("serving without storage")
+	}
+}
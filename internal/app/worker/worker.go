@@ -0,0 +1,56 @@
+package worker
+
+import (
+	"context"
+	"log/slog"
+	"sso/internal/lib/logger/sl"
+	"time"
+)
+
+// Job is a unit of periodic background work.
+type Job func(ctx context.Context) error
+
+// Worker runs a Job on a fixed interval until its context is cancelled.
+type Worker struct {
+	log      *slog.Logger
+	interval time.Duration
+	job      Job
+}
+
+func New(log *slog.Logger, name string, interval time.Duration, job Job) *Worker {
+	return &Worker{
+		log:      log.With(slog.String("worker", name)),
+		interval: interval,
+		job:      job,
+	}
+}
+
+// Run blocks, executing the job once per interval, until ctx is done. The job gets
+// the same ctx, so cancelling it also aborts an in-flight run; jobs are expected to
+// stop between batches and leave the rest to the next run.
+func (w *Worker) Run(ctx context.Context) {
+	w.log.Info("worker started", slog.Duration("interval", w.interval))
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			w.log.Info("worker stopped")
+			return
+		case <-ticker.C:
+			// Both cases may be ready at once; don't start a run after cancellation.
+			if ctx.Err() != nil {
+				continue
+			}
+			if err := w.job(ctx); err != nil {
+				if ctx.Err() != nil {
+					w.log.Info("job aborted", sl.Err(err))
+					continue
+				}
+				w.log.Error("job failed", sl.Err(err))
+			}
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package worker
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+const stopBound = 200 * time.Millisecond
+
+func TestWorkerStopsOnCancel(t *testing.T) {
+	tests := []struct {
+		name string
+		// job is what the worker runs; started is closed once it runs.
+		job func(started chan<- struct{}) Job
+	}{
+		{
+			name: "between runs",
+			job: func(started chan<- struct{}) Job {
+				return func(context.Context) error {
+					select {
+					case started <- struct{}{}:
+					default:
+					}
+					return nil
+				}
+			},
+		},
+		{
+			name: "during a run",
+			job: func(started chan<- struct{}) Job {
+				return func(ctx context.Context) error {
+					select {
+					case started <- struct{}{}:
+					default:
+					}
+					<-ctx.Done()
+					return ctx.Err()
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			started := make(chan struct{}, 1)
+			w := New(slog.New(slog.NewTextHandler(io.Discard, nil)), "test", 10*time.Millisecond, tt.job(started))
+
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				w.Run(ctx)
+			}()
+
+			select {
+			case <-started:
+			case <-time.After(time.Second):
+				t.Fatal("job never ran")
+			}
+			cancel()
+
+			select {
+			case <-done:
+			case <-time.After(stopBound):
+				t.Fatalf("worker still running %v after cancellation", stopBound)
+			}
+		})
+	}
+}
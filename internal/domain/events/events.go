@@ -0,0 +1,282 @@
+package events
+
+import (
+	"sso/internal/domain/models"
+	"time"
+)
+
+// AccountStatusChanged is published whenever an account actually moves to a
+// different status. ActorID is zero when the change wasn't attributed to an account.
+type AccountStatusChanged struct {
+	AccountID  int64
+	OldStatus  models.AccountStatus
+	NewStatus  models.AccountStatus
+	ActorID    int64
+	Reason     string
+	OccurredAt time.Time
+}
+
+// RefreshFromNewIP is published when a session is refreshed from an IP outside the
+// subnet it was last used from.
+type RefreshFromNewIP struct {
+	SessionID  int64
+	AccountID  int64
+	PreviousIP string
+	IPAddress  string
+	Denied     bool
+	OccurredAt time.Time
+}
+
+// AccountRegistered is published when a new account is created, for downstream
+// provisioning.
+type AccountRegistered struct {
+	AccountID  int64
+	Email      string
+	AppID      int32
+	OccurredAt time.Time
+}
+
+// PasswordResetRequested carries a password reset token to the channel that
+// delivers it to the account holder. It holds a secret, so it only ever goes to
+// the password reset publisher, never to the regular event publisher.
+type PasswordResetRequested struct {
+	AccountID  int64
+	Email      string
+	AppID      int32
+	Token      string
+	ExpiresAt  time.Time
+	OccurredAt time.Time
+}
+
+// ReactivationRequested carries the token that returns a dormant account to ACTIVE
+// to the channel that delivers it to the account holder. It holds a secret, so it
+// only ever goes to the reactivation publisher, never to the regular event
+// publisher.
+type ReactivationRequested struct {
+	AccountID  int64
+	Email      string
+	AppID      int32
+	Token      string
+	ExpiresAt  time.Time
+	OccurredAt time.Time
+}
+
+// PasswordReset is published when an account's password was reset with a token.
+type PasswordReset struct {
+	AccountID  int64
+	OccurredAt time.Time
+}
+
+// PasswordResetByAdmin is published when an admin set a new password for an
+// account, which then has to change it.
+type PasswordResetByAdmin struct {
+	AccountID  int64
+	ActorID    int64
+	OccurredAt time.Time
+}
+
+// PasswordChangeRequirementChanged is published when an admin requires an account
+// to change its password at next login, or lifts the requirement.
+type PasswordChangeRequirementChanged struct {
+	AccountID  int64
+	ActorID    int64
+	Required   bool
+	OccurredAt time.Time
+}
+
+// SessionRevoked is published when a session is revoked for a reason the client
+// should be told about, e.g. being logged out by a login elsewhere.
+type SessionRevoked struct {
+	SessionID  int64
+	AccountID  int64
+	Reason     models.RevocationReason
+	OccurredAt time.Time
+}
+
+// NewDeviceLogin tells the account holder about a login from a device and IP the
+// account hasn't used before. RevokeToken signs that device out again through
+// RevokeDeviceByToken. It holds a secret, so it only ever goes to the new-device
+// notifier, never to the regular event publisher.
+type NewDeviceLogin struct {
+	AccountID int64
+	Email     string
+	AppID     int32
+	SessionID string
+	DeviceID  string
+	IPAddress string
+	// Location is where IPAddress is, e.g. "Berlin, DE". Empty without GeoIP.
+	Location        string
+	UserAgent       string
+	OS              string
+	Browser         string
+	RevokeToken     string
+	RevokeExpiresAt time.Time
+	OccurredAt      time.Time
+}
+
+// LoginRiskDetected is published when a login looks anomalous compared with the
+// account's recent sessions, e.g. impossible travel. Action is what was done
+// about it: log, step_up or deny.
+type LoginRiskDetected struct {
+	AccountID         int64
+	IPAddress         string
+	Location          string
+	PreviousIPAddress string
+	PreviousLocation  string
+	Reason            string
+	Action            string
+	OccurredAt        time.Time
+}
+
+// SessionElevated is published when a session reauthenticates and may perform
+// sensitive operations until Until.
+type SessionElevated struct {
+	SessionID  int64
+	AccountID  int64
+	Until      time.Time
+	OccurredAt time.Time
+}
+
+// AccountDeleted is published when an account is soft-deleted, by its holder or,
+// with ActorID set to someone else, an admin. It is purged after PurgeAfter.
+type AccountDeleted struct {
+	AccountID  int64
+	ActorID    int64
+	PurgeAfter time.Time
+	OccurredAt time.Time
+}
+
+// AccountRestored is published when an admin restores a soft-deleted account.
+type AccountRestored struct {
+	AccountID  int64
+	ActorID    int64
+	OccurredAt time.Time
+}
+
+// UsernameChanged is published when an account sets, changes or removes its
+// username. Either username is empty when there was or is none.
+type UsernameChanged struct {
+	AccountID   int64
+	OldUsername string
+	NewUsername string
+	OccurredAt  time.Time
+}
+
+// PhoneChanged is published when an account sets, changes or removes its phone
+// number. The numbers themselves are left out.
+type PhoneChanged struct {
+	AccountID  int64
+	Removed    bool
+	OccurredAt time.Time
+}
+
+// ProfileUpdated is published when an account updates its profile. The profile
+// itself is left out.
+type ProfileUpdated struct {
+	AccountID  int64
+	OccurredAt time.Time
+}
+
+// EmailChangeRequested carries an email change confirmation token to the channel
+// that delivers it to NewEmail; OldEmail should be told about the request too, so
+// its owner notices a change they didn't ask for. It holds a secret, so it only
+// ever goes to the email change publisher, never to the regular event publisher.
+type EmailChangeRequested struct {
+	AccountID  int64
+	OldEmail   string
+	NewEmail   string
+	AppID      int32
+	Token      string
+	ExpiresAt  time.Time
+	OccurredAt time.Time
+}
+
+// EmailChanged is published when an account confirmed a change of its email. It
+// also goes to the email change publisher, to notify OldEmail.
+type EmailChanged struct {
+	AccountID  int64
+	OldEmail   string
+	NewEmail   string
+	OccurredAt time.Time
+}
+
+// InviteCreated is published when an admin invites an email to register with an
+// app. The invite token is left out.
+type InviteCreated struct {
+	InviteID   int64
+	Email      string
+	AppID      int32
+	Role       models.AccountRole
+	ActorID    int64
+	ExpiresAt  time.Time
+	OccurredAt time.Time
+}
+
+// InviteAccepted is published when an account registered with an invite.
+type InviteAccepted struct {
+	InviteID   int64
+	AccountID  int64
+	AppID      int32
+	OccurredAt time.Time
+}
+
+// InviteRevoked is published when an admin revoked a pending invite.
+type InviteRevoked struct {
+	InviteID   int64
+	ActorID    int64
+	OccurredAt time.Time
+}
+
+// SessionBindingMismatch is published when a session is validated or refreshed
+// from a client that doesn't match the one it is bound to. Mismatches lists the
+// differing attributes, "ip" and "user_agent"; Rejected is set if the call was
+// refused for it.
+type SessionBindingMismatch struct {
+	SessionID  int64
+	AccountID  int64
+	Mismatches []string
+	PreviousIP string
+	IPAddress  string
+	UserAgent  string
+	Rejected   bool
+	OccurredAt time.Time
+}
+
+// SigningKeyRotated is published when an app got a new signing key, by an admin
+// or on schedule, in which case ActorID is zero. The previous key, if any,
+// verifies tokens until RetiresAt.
+type SigningKeyRotated struct {
+	AppID     int32
+	KID       string
+	Algorithm string
+	// KeyRef names the key provider's key, empty for keys the service generated.
+	KeyRef     string
+	ActorID    int64
+	RetiresAt  time.Time
+	OccurredAt time.Time
+}
+
+// TokenExchanged is published when the client app ClientID exchanged an access
+// token of the session for a token for the app AppID, see Auth.TokenExchange.
+type TokenExchanged struct {
+	AccountID  int64
+	SessionID  string
+	ClientID   int32
+	AppID      int32
+	Scopes     []string
+	OccurredAt time.Time
+}
+
+// AccountDataExported is published when an admin exports everything stored about
+// an account, e.g. for a data subject access request.
+type AccountDataExported struct {
+	AccountID  int64
+	ActorID    int64
+	OccurredAt time.Time
+}
+
+// AccountPurged is published when a deleted account is removed for good.
+type AccountPurged struct {
+	AccountID  int64
+	OccurredAt time.Time
+}
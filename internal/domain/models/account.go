@@ -11,6 +11,23 @@ type Account struct {
 	Role      AccountRole
 	Status    AccountStatus
 	AppId     int32
+	// LastLoginAt is nil until the first successful login.
+	LastLoginAt *time.Time
+	// TokenVersion is embedded in issued tokens; bumping it invalidates all of them.
+	TokenVersion int64
+	// ExternalID is the account's ID in an upstream system (IdP, CRM). Empty if unset.
+	ExternalID string
+	// Username is an alternative identifier to log in with. Empty if unset.
+	Username string
+	// Phone is the account's phone number in E.164 form. Empty if unset.
+	Phone string
+	// RequiresPasswordChange restricts the account's tokens to changing the
+	// password until it does.
+	RequiresPasswordChange bool
+	// Scopes are the scopes granted in the app a token is being issued for.
+	Scopes []string
+	// ProfileClaims are the profile fields a token being issued carries, by claim name.
+	ProfileClaims map[string]string
 }
 
 type AccountRole int32
@@ -26,4 +43,26 @@ const (
 	ACTIVE   AccountStatus = 0
 	INACTIVE AccountStatus = 1
 	DELETED  AccountStatus = 2
+	// DORMANT accounts were deactivated after a long period without logins.
+	DORMANT AccountStatus = 3
+	// SUSPENDED accounts are blocked from logging in until an admin reinstates them.
+	SUSPENDED AccountStatus = 4
+	// BANNED accounts are blocked from logging in for good.
+	BANNED AccountStatus = 5
 )
+
+// StatusChangeResult is the outcome of a status change for one account in a batch.
+// Err is nil on success; Changed is false when the account already had the status.
+type StatusChangeResult struct {
+	AccountID int64
+	OldStatus AccountStatus
+	Changed   bool
+	Err       error
+}
+
+// AccountStats holds aggregate account counts for admin dashboards.
+type AccountStats struct {
+	Total    int64
+	ByStatus map[AccountStatus]int64
+	ByRole   map[AccountRole]int64
+}
@@ -1,6 +1,9 @@
 package models
 
-import "time"
+import (
+	"crypto"
+	"time"
+)
 
 type App struct {
 	ID          int64
@@ -9,4 +12,74 @@ type App struct {
 	Name        string
 	Secret      string
 	RedirectUrl string
+	MFAPolicy   MFAPolicy
+	// TokenVersion is embedded in tokens issued for the app; bumping it invalidates all of them.
+	TokenVersion int64
+	// RPID is the WebAuthn relying party ID for passkeys; empty disables them.
+	RPID string
+	// RPOrigins are the origins passkey responses may come from. Empty allows
+	// only https://<RPID>.
+	RPOrigins []string
+	// RequireUsername makes accounts registering with the app pick a username.
+	RequireUsername bool
+	// SelfRegistration lets accounts register with the app on their own; without
+	// it they need an invite.
+	SelfRegistration bool
+	// SigningAlgorithm is what the app's tokens are signed with. Empty means
+	// SigningHS256.
+	SigningAlgorithm SigningAlgorithm
+	// SigningKeys are the keys verifying the app's tokens, the current one, which
+	// new tokens are signed with, first. Previous keys follow until they retire.
+	// Empty for SigningHS256, which signs with Secret.
+	SigningKeys []SigningKey
+	// Audience is the aud claim of the app's tokens. Empty means the app ID.
+	Audience string
+	// OIDCEnabled gets logins to the app an OpenID Connect ID token.
+	OIDCEnabled bool
 }
+
+// SigningKey is a key pair an app signs its tokens with.
+type SigningKey struct {
+	// KID names the key in the kid header of the tokens it signs.
+	KID       string
+	Algorithm SigningAlgorithm
+	// PrivateKey is PKCS #8 encoded. Empty for keys held by a key provider.
+	PrivateKey []byte
+	// KeyRef names a key held outside the database by a key provider, such as a
+	// file, a Vault secret or a KMS key. Empty for keys stored with the app.
+	KeyRef string
+	// Signer is the key provider's signer of a KeyRef key, set when the app is
+	// loaded. Its private key may never leave the provider.
+	Signer    crypto.Signer
+	CreatedAt time.Time
+	// RetiresAt is when a rotated out key stops verifying, nil for the current key.
+	RetiresAt *time.Time
+}
+
+// SigningAlgorithm is a JWS algorithm an app's tokens are signed with.
+type SigningAlgorithm string
+
+func (a SigningAlgorithm) String() string {
+	return string(a)
+}
+
+const (
+	// SigningHS256 is HMAC with the app secret, so only holders of the secret can
+	// verify tokens.
+	SigningHS256 SigningAlgorithm = "HS256"
+	// SigningRS256 is RSASSA-PKCS1-v1_5 with a 2048-bit RSA key.
+	SigningRS256 SigningAlgorithm = "RS256"
+	// SigningES256 is ECDSA on P-256.
+	SigningES256 SigningAlgorithm = "ES256"
+	// SigningEdDSA is Ed25519.
+	SigningEdDSA SigningAlgorithm = "EdDSA"
+)
+
+// MFAPolicy says whether accounts must have a second factor enrolled to log in to an app.
+type MFAPolicy int32
+
+const (
+	MFA_OFF      MFAPolicy = 0
+	MFA_OPTIONAL MFAPolicy = 1
+	MFA_REQUIRED MFAPolicy = 2
+)
@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+// AuditEvent is a domain event as recorded in the audit log. Payload is the full
+// event as JSON; the other fields are extracted from it for querying. Entries are
+// never changed once written.
+type AuditEvent struct {
+	ID         int64
+	Type       string
+	AccountID  int64
+	ActorID    int64
+	IPAddress  string
+	Payload    string
+	OccurredAt time.Time
+}
+
+// AuditFilter selects audit events. Zero fields don't filter. Results are ordered
+// by ID; AfterID continues a previous page.
+type AuditFilter struct {
+	AccountID int64
+	ActorID   int64
+	IPAddress string
+	Type      string
+	From      time.Time
+	To        time.Time
+	AfterID   int64
+	Limit     int
+}
+
+// AuditPage is one page of audit events. NextAfterID is zero on the last page.
+type AuditPage struct {
+	Events      []AuditEvent
+	NextAfterID int64
+}
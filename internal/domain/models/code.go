@@ -0,0 +1,17 @@
+package models
+
+// CodePurpose tells apart single-use codes issued for different flows.
+type CodePurpose string
+
+const (
+	CodePurposeOTP           CodePurpose = "otp"
+	CodePurposeMagicLink     CodePurpose = "magic_link"
+	CodePurposePasswordReset CodePurpose = "password_reset"
+	CodePurposeSMSLogin      CodePurpose = "sms_login"
+	CodePurposeEmailChange   CodePurpose = "email_change"
+	CodePurposeReactivation  CodePurpose = "reactivation"
+
+	// Passkey challenges are stored as codes so each ceremony can finish once.
+	CodePurposePasskeyRegistration CodePurpose = "passkey_registration"
+	CodePurposePasskeyLogin        CodePurpose = "passkey_login"
+)
@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// Invite lets the holder of its token register Email with AppID in Role. It is
+// pending until AcceptedAt or RevokedAt is set or it expires.
+type Invite struct {
+	ID    int64
+	Email string
+	Role  AccountRole
+	AppID int32
+	// CreatedBy is the admin who created the invite, zero if they were deleted since.
+	CreatedBy int64
+	ExpiresAt time.Time
+	CreatedAt time.Time
+	// AcceptedAt is nil until the invite is accepted; AccountID is the account
+	// registered with it then.
+	AcceptedAt *time.Time
+	AccountID  int64
+	RevokedAt  *time.Time
+}
@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// AppMembership links an account to an app with the role it holds in that app.
+type AppMembership struct {
+	AccountID int64
+	AppID     int32
+	Role      AccountRole
+	// Scopes are the scopes the account may be granted in the app.
+	Scopes    []string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
@@ -0,0 +1,23 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Profile is the personal data of an account, kept apart from the auth fields of
+// Account. Fields are empty if unset.
+type Profile struct {
+	AccountID   int64
+	DisplayName string
+	GivenName   string
+	FamilyName  string
+	AvatarURL   string
+	// Locale is a BCP 47 language tag, e.g. "en-US".
+	Locale string
+	// Timezone is an IANA time zone name, e.g. "Europe/Berlin".
+	Timezone string
+	// Metadata is a free-form JSON object set by clients. Nil if unset.
+	Metadata  json.RawMessage
+	UpdatedAt time.Time
+}
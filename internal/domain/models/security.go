@@ -0,0 +1,59 @@
+package models
+
+import "time"
+
+// SecurityFactors is a read-only view of the account's enrolled MFA methods
+// and trusted devices. It never carries secret material.
+type SecurityFactors struct {
+	TOTP                   *TOTPFactor
+	Passkeys               []PasskeyFactor
+	RecoveryCodesRemaining int
+	TrustedDevices         []TrustedDevice
+}
+
+type TOTPFactor struct {
+	EnrolledAt time.Time
+	LastUsedAt *time.Time
+}
+
+type PasskeyFactor struct {
+	ID         int64
+	Name       string
+	CreatedAt  time.Time
+	LastUsedAt *time.Time
+}
+
+// Passkey is a registered WebAuthn credential with its public key.
+type Passkey struct {
+	ID           int64
+	AccountID    int64
+	CredentialID []byte
+	// PublicKey is the credential's COSE_Key.
+	PublicKey  []byte
+	SignCount  uint32
+	Name       string
+	CreatedAt  time.Time
+	LastUsedAt *time.Time
+}
+
+// TrustedDevice is a device the account has signed in from, see
+// useragent.Fingerprint. UserAgent comes from its latest session, IPAddress from
+// its most recent sign-in, and CreatedAt is when it was first seen.
+type TrustedDevice struct {
+	ID         string
+	Name       string
+	UserAgent  string
+	IPAddress  string
+	CreatedAt  time.Time
+	LastUsedAt *time.Time
+}
+
+// LockoutState is the failed-login record of one lockout key, e.g. an identifier
+// or a client IP. Zero times are unset.
+type LockoutState struct {
+	Failures    int
+	LastFailure time.Time
+	LockedUntil time.Time
+	// ResetAt is when the failures are forgotten after a successful login.
+	ResetAt time.Time
+}
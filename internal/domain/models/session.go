@@ -3,9 +3,17 @@ package models
 import "time"
 
 type Session struct {
-	ID               int64
-	AccountID        int64
-	Token            string
+	ID int64
+	// SID is the public session ID, e.g. a ULID. Access tokens name their session by
+	// it in the sid claim.
+	SID       string
+	AccountID int64
+	AppID     int32
+	// Token is the access token of a newly issued session. Access tokens aren't
+	// stored, so it is empty for sessions read back from storage.
+	Token string
+	// RefreshToken is only stored hashed; read back from storage it holds the
+	// hash, or the plaintext token of sessions saved before hashing.
 	RefreshToken     string
 	UserAgent        string
 	IPAddress        string
@@ -14,4 +22,133 @@ type Session struct {
 	CreatedAt        time.Time
 	UpdatedAt        time.Time
 	Revoked          bool
+	RevokedReason    RevocationReason
+	// FamilyStartedAt is when the login that started this refresh chain happened.
+	FamilyStartedAt time.Time
+	// FamilyID is shared by every session rotated from the same login.
+	FamilyID string
+	// Scopes narrow the session below what the account may be granted. Nil means
+	// the session isn't narrowed (sessions from before per-session scopes).
+	Scopes []string
+	// LastSeenAt is when the session was last validated, CreatedAt if never.
+	LastSeenAt time.Time
+	// DeviceID fingerprints the device the session was created from, see
+	// useragent.Fingerprint. OS and Browser are parsed from UserAgent.
+	DeviceID string
+	OS       string
+	Browser  string
+	// ElevatedUntil is until when the session may perform sensitive operations
+	// after reauthenticating. Zero if it never reauthenticated.
+	ElevatedUntil time.Time
+	// RememberMe is whether the login that started the refresh chain asked to be
+	// remembered, selecting the long refresh token TTL.
+	RememberMe bool
+}
+
+// SessionDevice is the device a new session is created from.
+type SessionDevice struct {
+	ID      string
+	OS      string
+	Browser string
+}
+
+// Device groups an account's active sessions created from the same device.
+type Device struct {
+	ID string
+	// Name is what the user called the device, empty if they haven't.
+	Name    string
+	OS      string
+	Browser string
+	// IPAddress and LastSeenAt come from the device's most recently seen session.
+	IPAddress  string
+	LastSeenAt time.Time
+	// Current is set for the device the request came from.
+	Current  bool
+	Sessions []Session
+}
+
+// DeviceLogin is a device and IP an account has signed in from.
+type DeviceLogin struct {
+	DeviceID    string
+	IPAddress   string
+	FirstSeenAt time.Time
+	LastSeenAt  time.Time
+}
+
+// RevocationReason tells a client why its session stopped being valid. It is empty
+// for sessions revoked without a specific reason.
+type RevocationReason string
+
+const (
+	RevokedLoggedOutElsewhere  RevocationReason = "logged_out_elsewhere"
+	RevokedAccountDormant      RevocationReason = "account_dormant"
+	RevokedReauthRequired      RevocationReason = "reauth_required"
+	RevokedAppRevoked          RevocationReason = "app_revoked"
+	RevokedSignedOutEverywhere RevocationReason = "signed_out_everywhere"
+	RevokedAccountDisabled     RevocationReason = "account_disabled"
+	RevokedSessionLimit        RevocationReason = "session_limit"
+	RevokedSessionIdle         RevocationReason = "idle_timeout"
+	RevokedSignedOut           RevocationReason = "signed_out"
+	RevokedPasswordReset       RevocationReason = "password_reset"
+	RevokedDeviceRejected      RevocationReason = "device_rejected"
+	RevokedAccountDeleted      RevocationReason = "account_deleted"
+	RevokedEmailChanged        RevocationReason = "email_changed"
+	// RevokedRotated marks a session replaced by a refresh: its refresh token must
+	// not be presented again.
+	RevokedRotated      RevocationReason = "rotated"
+	RevokedRefreshReuse RevocationReason = "refresh_token_reused"
+)
+
+// SessionValidation is the outcome of validating an access token. RenewedToken is
+// set only when a near-expiry token was proactively replaced.
+type SessionValidation struct {
+	Valid                 bool
+	ExpiresAt             time.Time
+	RevokedReason         RevocationReason
+	RenewedToken          string
+	RenewedTokenExpiresAt time.Time
+	// Permissions are resolved from the account's current role in the session's app.
+	// Nil when no role permissions are configured.
+	Permissions []string
+	// PasswordChangeRequired tells why an otherwise live session isn't valid: the
+	// account must change its password first.
+	PasswordChangeRequired bool
+	// BindingMismatch flags a valid session used from a client other than the one
+	// it is bound to, see auth.SessionBinding.
+	BindingMismatch bool
+}
+
+// Token types, as introspection reports them and takes them as hints.
+const (
+	TokenTypeAccess  = "access_token"
+	TokenTypeRefresh = "refresh_token"
+)
+
+// ExchangedToken is an access token issued by token exchange, for the app AppID
+// with Scopes.
+type ExchangedToken struct {
+	Token     string
+	AppID     int32
+	Scopes    []string
+	ExpiresAt time.Time
+}
+
+// TokenIntrospection describes a token the way OAuth 2.0 token introspection
+// (RFC 7662) does. Tokens that aren't active have only Active set.
+type TokenIntrospection struct {
+	Active bool
+	// TokenType is TokenTypeAccess or TokenTypeRefresh.
+	TokenType string
+	Subject   string
+	Audience  []string
+	Scopes    []string
+	// ClientID is the ID of the app the token was issued for.
+	ClientID  string
+	Issuer    string
+	SessionID string
+	// TokenID is the jti of access tokens, empty for refresh tokens.
+	TokenID   string
+	ExpiresAt time.Time
+	IssuedAt  time.Time
+	NotBefore time.Time
 }
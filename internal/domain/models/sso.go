@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// SSOTicket lets an account logged in to SourceAppID start a session in TargetAppID
+// without entering credentials again. Tickets are short-lived and single-use.
+type SSOTicket struct {
+	AccountID   int64
+	SourceAppID int32
+	TargetAppID int32
+	ExpiresAt   time.Time
+}
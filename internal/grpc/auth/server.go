@@ -3,16 +3,44 @@ package authgrpc
 import (
 	"context"
 	"errors"
+	"sso/internal/domain/models"
+	"sso/internal/lib/peerip"
 	"sso/internal/services/auth"
 	"sso/internal/storage"
+	"strconv"
+	"strings"
+	"time"
 
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
 
 	ssov1 "github.com/dariasmyr/protos/gen/go/sso"
 )
 
+const (
+	renewedTokenHeader          = "x-renewed-token"
+	renewedTokenExpiresAtHeader = "x-renewed-token-expires-at"
+	revokedReasonHeader         = "x-session-revoked-reason"
+	requestedScopesHeader       = "x-requested-scopes"
+	secondFactorHeader          = "x-second-factor"
+	captchaTokenHeader          = "x-captcha-token"
+	usernameHeader              = "x-username"
+	phoneHeader                 = "x-phone"
+	rememberMeHeader            = "x-remember-me"
+	clientIPHeader              = "x-client-ip"
+	clientUserAgentHeader       = "x-client-user-agent"
+	bindingMismatchHeader       = "x-session-binding-mismatch"
+	permissionsHeader           = "x-permissions"
+	nonceHeader                 = "x-oidc-nonce"
+	idTokenHeader               = "x-id-token"
+	refreshTokenHeader          = "x-refresh-token"
+	statusReasonHeader          = "x-status-reason"
+)
+
 type serverAPI struct {
 	ssov1.UnimplementedAuthServer
 	ssov1.UnimplementedSessionsServer
@@ -22,6 +50,13 @@ type serverAPI struct {
 type Auth interface {
 	ssov1.AuthServer
 	ssov1.SessionsServer
+	ValidateAccountSessionFrom(ctx context.Context, token string, userAgent string, ipAddress string) (models.SessionValidation, error)
+	LoginWithOptions(ctx context.Context, request *ssov1.LoginRequest, opts auth.LoginOptions) (*ssov1.LoginResponse, string, error)
+	RegisterWithOptions(ctx context.Context, request *ssov1.RegisterRequest, opts auth.RegisterOptions) (*ssov1.RegisterResponse, error)
+	RefreshAccountSession(ctx context.Context, accountID int64, refreshToken string, userAgent string, ipAddress string) (string, string, int64, error)
+	RequireRecentAuth(ctx context.Context, token string, maxAge time.Duration) error
+	CurrentSession(ctx context.Context, token string) (models.Session, error)
+	ChangeAccountStatus(ctx context.Context, actorID int64, accountID int64, status models.AccountStatus, reason string) error
 }
 
 func Register(gRPCServer *grpc.Server, auth Auth) {
@@ -42,18 +77,75 @@ func (s *serverAPI) Login(ctx context.Context, in *ssov1.LoginRequest) (*ssov1.L
 		AppId:     in.GetAppId(),
 	}
 
-	loginResponse, err := s.auth.Login(ctx, &loginRequest)
+	// The request message has no scopes, second factor, captcha, remember-me or
+	// nonce fields, so a narrowed login, a TOTP or recovery code, a captcha token,
+	// the remember-me flag and the OIDC nonce come via metadata. The refresh token
+	// goes back in the x-refresh-token header, as well as in the response, and the
+	// ID token of OIDC-enabled apps in the x-id-token header.
+	scopes, _ := requestedScopes(ctx)
+	loginResponse, idToken, err := s.auth.LoginWithOptions(ctx, &loginRequest, auth.LoginOptions{
+		Scopes:       scopes,
+		SecondFactor: secondFactor(ctx),
+		CaptchaToken: captchaToken(ctx),
+		RememberMe:   rememberMe(ctx),
+		Nonce:        metadataValue(ctx, nonceHeader),
+	})
 	if err != nil {
+		if errors.Is(err, auth.ErrCaptchaRequired) {
+			return nil, captchaRequiredStatus()
+		}
 		if errors.Is(err, auth.ErrInvalidCredentials) {
 			return nil, status.Error(codes.InvalidArgument, "invalid email or password")
 		}
+		if errors.Is(err, auth.ErrAppNotFound) {
+			return nil, status.Error(codes.InvalidArgument, "unknown app")
+		}
+		if errors.Is(err, auth.ErrLoginThrottled) {
+			return nil, status.Error(codes.ResourceExhausted, "too many failed login attempts")
+		}
+		if errors.Is(err, auth.ErrAccountLocked) {
+			return nil, status.Error(codes.ResourceExhausted, "account temporarily locked")
+		}
+		if errors.Is(err, auth.ErrSessionLimitReached) {
+			return nil, status.Error(codes.ResourceExhausted, "session limit reached")
+		}
+		if errors.Is(err, auth.ErrAccountDormant) {
+			return nil, status.Error(codes.FailedPrecondition, "account is dormant, reactivation required")
+		}
+		if errors.Is(err, auth.ErrAccountSuspended) {
+			return nil, status.Error(codes.PermissionDenied, "account is suspended")
+		}
+		if errors.Is(err, auth.ErrAccountBanned) {
+			return nil, status.Error(codes.PermissionDenied, "account is banned")
+		}
+		if errors.Is(err, auth.ErrMFAEnrollmentRequired) {
+			return nil, status.Error(codes.FailedPrecondition, "mfa enrollment required")
+		}
+		if errors.Is(err, auth.ErrSecondFactorRequired) {
+			return nil, status.Error(codes.FailedPrecondition, "second factor required")
+		}
+		if errors.Is(err, auth.ErrNoAppMembership) {
+			return nil, status.Error(codes.PermissionDenied, "account has no access to this app")
+		}
+		if errors.Is(err, auth.ErrLoginDenied) {
+			return nil, status.Error(codes.PermissionDenied, "login denied")
+		}
+		if errors.Is(err, auth.ErrLoginVerificationRequired) {
+			return nil, status.Error(codes.FailedPrecondition, "additional verification required")
+		}
 		return nil, status.Error(codes.Internal, "failed to login")
 	}
 
+	_ = grpc.SetHeader(ctx, metadata.Pairs(refreshTokenHeader, loginResponse.GetRefreshToken()))
+	if idToken != "" {
+		_ = grpc.SetHeader(ctx, metadata.Pairs(idTokenHeader, idToken))
+	}
+
 	return &ssov1.LoginResponse{
-		AccountId:    loginResponse.AccountId,
-		Token:        loginResponse.RefreshToken, /// ??????? TODO: access token
-		RefreshToken: loginResponse.RefreshToken}, nil
+		AccountId:    loginResponse.GetAccountId(),
+		Token:        loginResponse.GetToken(),
+		RefreshToken: loginResponse.GetRefreshToken(),
+	}, nil
 }
 
 func (s *serverAPI) Register(ctx context.Context, in *ssov1.RegisterRequest) (*ssov1.RegisterResponse, error) {
@@ -64,15 +156,51 @@ func (s *serverAPI) Register(ctx context.Context, in *ssov1.RegisterRequest) (*s
 	registerReq := ssov1.RegisterRequest{
 		Email:    in.GetEmail(),
 		Password: in.GetPassword(),
-		Role:     in.GetRole(),
 		AppId:    in.GetAppId(),
 	}
 
-	registerResp, err := s.auth.Register(ctx, &registerReq)
+	// The request message has no username or phone fields, so they come via metadata.
+	registerResp, err := s.auth.RegisterWithOptions(ctx, &registerReq, auth.RegisterOptions{
+		Username:     metadataValue(ctx, usernameHeader),
+		PhoneNumber:  metadataValue(ctx, phoneHeader),
+		IPAddress:    peerip.FromContext(ctx),
+		CaptchaToken: captchaToken(ctx),
+	})
 	if err != nil {
+		if errors.Is(err, auth.ErrCaptchaRequired) {
+			return nil, captchaRequiredStatus()
+		}
+		if errors.Is(err, auth.ErrSelfRegistrationDisabled) {
+			return nil, status.Error(codes.PermissionDenied, "registration with this app is by invite only")
+		}
 		if errors.Is(err, storage.ErrAccountExists) {
 			return nil, status.Error(codes.AlreadyExists, "account already exists")
 		}
+		if errors.Is(err, storage.ErrUsernameExists) {
+			return nil, status.Error(codes.AlreadyExists, "username already taken")
+		}
+		if errors.Is(err, auth.ErrUsernameRequired) {
+			return nil, status.Error(codes.InvalidArgument, "username is required")
+		}
+		if errors.Is(err, auth.ErrInvalidUsername) {
+			return nil, status.Error(codes.InvalidArgument, "invalid username")
+		}
+		if errors.Is(err, storage.ErrPhoneExists) {
+			return nil, status.Error(codes.AlreadyExists, "phone number already registered")
+		}
+		if errors.Is(err, auth.ErrInvalidPhone) {
+			return nil, status.Error(codes.InvalidArgument, "phone number must be in international format")
+		}
+		if errors.Is(err, auth.ErrProvisioningFailed) {
+			return nil, status.Error(codes.Unavailable, "account provisioning failed, try again later")
+		}
+		var policyErr *auth.PasswordPolicyError
+		if errors.As(err, &policyErr) {
+			return nil, passwordPolicyStatus("password", policyErr)
+		}
+		if errors.Is(err, auth.ErrBreachedPassword) {
+			return nil, status.Error(codes.InvalidArgument, "password appears in a known data breach")
+		}
 		return nil, status.Error(codes.Internal, "failed to register account")
 	}
 
@@ -103,6 +231,16 @@ func (s *serverAPI) ChangePassword(ctx context.Context, in *ssov1.ChangePassword
 		NewPassword: in.GetNewPassword(),
 	})
 	if err != nil {
+		var policyErr *auth.PasswordPolicyError
+		if errors.As(err, &policyErr) {
+			return nil, passwordPolicyStatus("new_password", policyErr)
+		}
+		if errors.Is(err, auth.ErrPasswordReused) {
+			return nil, status.Error(codes.InvalidArgument, "new password was used recently")
+		}
+		if errors.Is(err, auth.ErrBreachedPassword) {
+			return nil, status.Error(codes.InvalidArgument, "new password appears in a known data breach")
+		}
 		return nil, status.Error(codes.Internal, "failed to change password")
 	}
 
@@ -114,15 +252,38 @@ func (s *serverAPI) ChangeStatus(ctx context.Context, in *ssov1.ChangeStatusRequ
 		return nil, status.Error(codes.InvalidArgument, "account_id is required")
 	}
 
-	updatedStatus, err := s.auth.ChangeStatus(ctx, &ssov1.ChangeStatusRequest{
-		AccountId: in.GetAccountId(),
-		Status:    in.GetStatus(),
-	})
+	actorID, err := s.caller(ctx)
 	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, "invalid access token")
+	}
+
+	// The request message carries no reason, so take it from the x-status-reason header.
+	err = s.auth.ChangeAccountStatus(ctx, actorID, in.GetAccountId(), models.AccountStatus(in.GetStatus()), metadataValue(ctx, statusReasonHeader))
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidStatusTransition) {
+			return nil, status.Error(codes.FailedPrecondition, "status change not allowed")
+		}
 		return nil, status.Error(codes.Internal, "failed to change status")
 	}
 
-	return &ssov1.ChangeStatusResponse{AccountId: in.GetAccountId(), Status: updatedStatus.Status}, nil
+	return &ssov1.ChangeStatusResponse{AccountId: in.GetAccountId(), Status: in.GetStatus()}, nil
+}
+
+// caller returns the account of the bearer token in the authorization header, or
+// zero for calls without one, such as those from trusted networks only guarded
+// by the admin access allow-list.
+func (s *serverAPI) caller(ctx context.Context) (int64, error) {
+	token, ok := strings.CutPrefix(metadataValue(ctx, "authorization"), "Bearer ")
+	if !ok || token == "" {
+		return 0, nil
+	}
+
+	session, err := s.auth.CurrentSession(ctx, token)
+	if err != nil {
+		return 0, err
+	}
+
+	return session.AccountID, nil
 }
 
 func (s *serverAPI) GetActiveSessions(ctx context.Context, in *ssov1.GetActiveAccountSessionsRequest) (*ssov1.GetActiveAccountSessionsResponse, error) {
@@ -143,17 +304,169 @@ func (s *serverAPI) RefreshSession(ctx context.Context, in *ssov1.RefreshAccount
 		return nil, status.Error(codes.InvalidArgument, "account_id and refresh_token are required")
 	}
 
-	req := ssov1.RefreshAccountSessionRequest{
-		AccountId:    in.GetAccountId(),
-		RefreshToken: in.GetRefreshToken(),
+	// The request message carries no client info, so take it from the connection.
+	token, refreshToken, expiresAt, err := s.auth.RefreshAccountSession(ctx, in.GetAccountId(), in.GetRefreshToken(), userAgent(ctx), peerip.FromContext(ctx))
+	if err != nil {
+		if errors.Is(err, auth.ErrAppNotFound) {
+			return nil, status.Error(codes.FailedPrecondition, "session app no longer exists")
+		}
+		if errors.Is(err, auth.ErrStepUpRequired) {
+			return nil, status.Error(codes.Unauthenticated, "re-authentication required")
+		}
+		if errors.Is(err, auth.ErrRefreshDenied) {
+			return nil, status.Error(codes.PermissionDenied, "refresh from this location is not allowed")
+		}
+		if errors.Is(err, auth.ErrSessionBindingMismatch) {
+			return nil, status.Error(codes.PermissionDenied, "session is bound to another client")
+		}
+		if errors.Is(err, storage.ErrSessionNotFound) {
+			return nil, status.Error(codes.Unauthenticated, "invalid refresh token")
+		}
+		if errors.Is(err, auth.ErrRefreshTokenReused) {
+			return nil, status.Error(codes.Unauthenticated, "refresh token reused, log in again")
+		}
+		if errors.Is(err, auth.ErrSessionRevoked) {
+			return nil, status.Error(codes.Unauthenticated, "session revoked, log in again")
+		}
+		if errors.Is(err, auth.ErrRefreshFamilyExpired) || errors.Is(err, auth.ErrSessionExpired) {
+			return nil, status.Error(codes.Unauthenticated, "session expired, log in again")
+		}
+		if errors.Is(err, auth.ErrSessionIdle) {
+			return nil, status.Error(codes.Unauthenticated, "session idle, log in again")
+		}
+		if errors.Is(err, auth.ErrAccountSuspended) {
+			return nil, status.Error(codes.PermissionDenied, "account is suspended")
+		}
+		if errors.Is(err, auth.ErrAccountBanned) {
+			return nil, status.Error(codes.PermissionDenied, "account is banned")
+		}
+		return nil, status.Error(codes.Internal, "failed to refresh session")
+	}
+
+	return &ssov1.RefreshAccountSessionResponse{Token: token, RefreshToken: refreshToken, ExpiresAt: expiresAt}, nil
+}
+
+// requestedScopes reads the space-separated x-requested-scopes header.
+func requestedScopes(ctx context.Context) ([]string, bool) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return nil, false
+	}
+
+	values := md.Get(requestedScopesHeader)
+	if len(values) == 0 {
+		return nil, false
+	}
+
+	return strings.Fields(strings.Join(values, " ")), true
+}
+
+// passwordPolicyStatus is InvalidArgument with a BadRequest detail holding one
+// field violation per broken rule, and an ErrorInfo per rule whose reason is the
+// rule name in upper case (e.g. MIN_LENGTH) for clients that localize messages.
+func passwordPolicyStatus(field string, err *auth.PasswordPolicyError) error {
+	badRequest := &errdetails.BadRequest{}
+	details := make([]protoadapt.MessageV1, 0, len(err.Violations)+1)
+	for _, v := range err.Violations {
+		badRequest.FieldViolations = append(badRequest.FieldViolations, &errdetails.BadRequest_FieldViolation{
+			Field:       field,
+			Description: v.Message,
+		})
+	}
+	details = append(details, badRequest)
+	for _, v := range err.Violations {
+		details = append(details, &errdetails.ErrorInfo{
+			Reason: strings.ToUpper(v.Rule),
+			Domain: "sso",
+			Metadata: map[string]string{
+				"field": field,
+			},
+		})
+	}
+
+	st, detailsErr := status.New(codes.InvalidArgument, "password does not meet the password policy").WithDetails(details...)
+	if detailsErr != nil {
+		return status.Error(codes.InvalidArgument, "password does not meet the password policy")
+	}
+
+	return st.Err()
+}
+
+// secondFactor reads the x-second-factor header: a TOTP code or a recovery code.
+func secondFactor(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+
+	if values := md.Get(secondFactorHeader); len(values) > 0 {
+		return values[0]
+	}
+
+	return ""
+}
+
+// captchaToken reads the x-captcha-token header: the token of a solved CAPTCHA.
+func captchaToken(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+
+	if values := md.Get(captchaTokenHeader); len(values) > 0 {
+		return values[0]
 	}
 
-	resp, err := s.auth.RefreshSession(ctx, &req)
+	return ""
+}
+
+// rememberMe reads the x-remember-me header: a true boolean such as "true" or "1"
+// asks for a remembered login.
+func rememberMe(ctx context.Context) bool {
+	remember, err := strconv.ParseBool(metadataValue(ctx, rememberMeHeader))
+	return err == nil && remember
+}
+
+// metadataValue reads the first value of a metadata header, empty if it is absent.
+func metadataValue(ctx context.Context, header string) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+
+	if values := md.Get(header); len(values) > 0 {
+		return values[0]
+	}
+
+	return ""
+}
+
+// captchaRequiredStatus asks the client to solve a CAPTCHA and retry with its token
+// in the x-captcha-token header. The ErrorInfo reason CAPTCHA_REQUIRED tells it
+// apart from other failed preconditions.
+func captchaRequiredStatus() error {
+	st, err := status.New(codes.FailedPrecondition, "captcha required").WithDetails(&errdetails.ErrorInfo{
+		Reason: "CAPTCHA_REQUIRED",
+		Domain: "sso",
+	})
 	if err != nil {
-		return nil, status.Error(codes.Internal, "failed to refresh session")
+		return status.Error(codes.FailedPrecondition, "captcha required")
 	}
 
-	return &ssov1.RefreshAccountSessionResponse{Token: resp.Token, RefreshToken: resp.RefreshToken, ExpiresAt: resp.ExpiresAt}, nil
+	return st.Err()
+}
+
+func userAgent(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+
+	if values := md.Get("user-agent"); len(values) > 0 {
+		return values[0]
+	}
+
+	return ""
 }
 
 func (s *serverAPI) ValidateSession(ctx context.Context, in *ssov1.ValidateAccountSessionRequest) (*ssov1.ValidateAccountSessionResponse, error) {
@@ -161,12 +474,32 @@ func (s *serverAPI) ValidateSession(ctx context.Context, in *ssov1.ValidateAccou
 		return nil, status.Error(codes.InvalidArgument, "token is required")
 	}
 
-	resp, err := s.auth.ValidateSession(ctx, &ssov1.ValidateAccountSessionRequest{Token: in.GetToken()})
+	// The token is usually validated by a service on its client's behalf, so the
+	// client's IP and user agent are forwarded in headers rather than taken from
+	// the connection.
+	resp, err := s.auth.ValidateAccountSessionFrom(ctx, in.GetToken(), metadataValue(ctx, clientUserAgentHeader), metadataValue(ctx, clientIPHeader))
 	if err != nil {
 		return nil, status.Error(codes.Internal, "failed to validate session")
 	}
 
-	return &ssov1.ValidateAccountSessionResponse{Valid: resp.Valid, ExpiresAt: resp.ExpiresAt}, nil
+	// The response message has no fields for these, so they travel in headers.
+	if resp.RevokedReason != "" {
+		_ = grpc.SetHeader(ctx, metadata.Pairs(revokedReasonHeader, string(resp.RevokedReason)))
+	}
+	if resp.BindingMismatch {
+		_ = grpc.SetHeader(ctx, metadata.Pairs(bindingMismatchHeader, "true"))
+	}
+	if resp.Permissions != nil {
+		_ = grpc.SetHeader(ctx, metadata.Pairs(permissionsHeader, strings.Join(resp.Permissions, " ")))
+	}
+	if resp.RenewedToken != "" {
+		_ = grpc.SetHeader(ctx, metadata.Pairs(
+			renewedTokenHeader, resp.RenewedToken,
+			renewedTokenExpiresAtHeader, strconv.FormatInt(resp.RenewedTokenExpiresAt.Unix(), 10),
+		))
+	}
+
+	return &ssov1.ValidateAccountSessionResponse{Valid: resp.Valid, ExpiresAt: resp.ExpiresAt.Unix()}, nil
 }
 
 func (s *serverAPI) RevokeSession(ctx context.Context, in *ssov1.RevokeAccountSessionRequest) (*ssov1.RevokeAccountSessionResponse, error) {
@@ -0,0 +1,154 @@
+package authgrpc
+
+import (
+	"context"
+	"slices"
+	"sso/internal/domain/models"
+	"sso/internal/services/auth"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	ssov1 "github.com/dariasmyr/protos/gen/go/sso"
+)
+
+// loginAuth answers logins with response and idToken. Every other method panics.
+type loginAuth struct {
+	Auth
+	response *ssov1.LoginResponse
+	idToken  string
+}
+
+func (a *loginAuth) LoginWithOptions(context.Context, *ssov1.LoginRequest, auth.LoginOptions) (*ssov1.LoginResponse, string, error) {
+	return a.response, a.idToken, nil
+}
+
+// headerStream records the headers a handler sets.
+type headerStream struct {
+	header metadata.MD
+}
+
+func (s *headerStream) Method() string { return ssov1.Auth_Login_FullMethodName }
+
+func (s *headerStream) SetHeader(md metadata.MD) error {
+	s.header = metadata.Join(s.header, md)
+	return nil
+}
+
+func (s *headerStream) SendHeader(md metadata.MD) error { return s.SetHeader(md) }
+
+func (s *headerStream) SetTrailer(metadata.MD) error { return nil }
+
+func TestLoginReturnsTokens(t *testing.T) {
+	tests := []struct {
+		name        string
+		idToken     string
+		wantIDToken []string
+	}{
+		{name: "plain app"},
+		{name: "oidc app", idToken: "id-token", wantIDToken: []string{"id-token"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := &serverAPI{auth: &loginAuth{
+				response: &ssov1.LoginResponse{AccountId: 7, Token: "access-token", RefreshToken: "refresh-token"},
+				idToken:  tt.idToken,
+			}}
+			stream := &headerStream{}
+			ctx := grpc.NewContextWithServerTransportStream(context.Background(), stream)
+
+			resp, err := server.Login(ctx, &ssov1.LoginRequest{Email: "user@example.com", Password: "password", AppId: 1})
+			if err != nil {
+				t.Fatalf("login: %v", err)
+			}
+
+			if resp.GetAccountId() != 7 {
+				t.Errorf("account ID = %d, want 7", resp.GetAccountId())
+			}
+			if resp.GetToken() != "access-token" {
+				t.Errorf("token = %q, want the access token", resp.GetToken())
+			}
+			if resp.GetRefreshToken() != "refresh-token" {
+				t.Errorf("refresh token = %q, want the refresh token", resp.GetRefreshToken())
+			}
+			if got := stream.header.Get(refreshTokenHeader); !slices.Equal(got, []string{"refresh-token"}) {
+				t.Errorf("%s header = %v, want the refresh token", refreshTokenHeader, got)
+			}
+			if got := stream.header.Get(idTokenHeader); !slices.Equal(got, tt.wantIDToken) {
+				t.Errorf("%s header = %v, want %v", idTokenHeader, got, tt.wantIDToken)
+			}
+		})
+	}
+}
+
+// statusAuth records status changes and knows the session of one access token.
+// Every other method panics.
+type statusAuth struct {
+	Auth
+	token     string
+	sessionOf int64
+
+	actorID int64
+	reason  string
+}
+
+func (a *statusAuth) CurrentSession(_ context.Context, token string) (models.Session, error) {
+	if token != a.token {
+		return models.Session{}, auth.ErrSessionRevoked
+	}
+	return models.Session{AccountID: a.sessionOf}, nil
+}
+
+func (a *statusAuth) ChangeAccountStatus(_ context.Context, actorID int64, _ int64, _ models.AccountStatus, reason string) error {
+	a.actorID = actorID
+	a.reason = reason
+	return nil
+}
+
+func TestChangeStatusAttribution(t *testing.T) {
+	tests := []struct {
+		name       string
+		md         metadata.MD
+		wantCode   codes.Code
+		wantActor  int64
+		wantReason string
+	}{
+		{name: "anonymous", wantCode: codes.OK},
+		{
+			name:       "admin with reason",
+			md:         metadata.Pairs("authorization", "Bearer admin-token", statusReasonHeader, "chargeback"),
+			wantCode:   codes.OK,
+			wantActor:  3,
+			wantReason: "chargeback",
+		},
+		{
+			name:     "invalid token",
+			md:       metadata.Pairs("authorization", "Bearer stolen-token"),
+			wantCode: codes.Unauthenticated,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &statusAuth{token: "admin-token", sessionOf: 3}
+			server := &serverAPI{auth: a}
+			ctx := metadata.NewIncomingContext(context.Background(), tt.md)
+
+			_, err := server.ChangeStatus(ctx, &ssov1.ChangeStatusRequest{AccountId: 7, Status: ssov1.AccountStatus(models.SUSPENDED)})
+			if code := status.Code(err); code != tt.wantCode {
+				t.Fatalf("code = %v, want %v", code, tt.wantCode)
+			}
+
+			if a.actorID != tt.wantActor {
+				t.Errorf("actor = %d, want %d", a.actorID, tt.wantActor)
+			}
+			if a.reason != tt.wantReason {
+				t.Errorf("reason = %q, want %q", a.reason, tt.wantReason)
+			}
+		})
+	}
+}
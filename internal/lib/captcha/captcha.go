@@ -0,0 +1,70 @@
+// Package captcha verifies CAPTCHA tokens solved by clients and remembers which
+// identifiers and IPs have to solve one before they may try again.
+package captcha
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Verifier checks a token a client got by solving a CAPTCHA. remoteIP is the
+// client's address, passed on to the provider if known. ok is false for tokens the
+// provider rejects; err is for failures to ask it.
+type Verifier interface {
+	Verify(ctx context.Context, token string, remoteIP string) (ok bool, err error)
+}
+
+// Flags remembers keys, e.g. identifiers and client IPs, that must solve a CAPTCHA,
+// each for ttl after it was last flagged. It lives in memory, so flags are per
+// instance and lost on restart.
+type Flags struct {
+	ttl time.Duration
+
+	mu        sync.Mutex
+	until     map[string]time.Time
+	nextSweep time.Time
+}
+
+func NewFlags(ttl time.Duration) *Flags {
+	return &Flags{
+		ttl:   ttl,
+		until: make(map[string]time.Time),
+	}
+}
+
+// Flag requires a CAPTCHA from key for the next ttl.
+func (f *Flags) Flag(key string) {
+	now := time.Now()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.sweep(now)
+	f.until[key] = now.Add(f.ttl)
+}
+
+// Flagged reports whether key must solve a CAPTCHA.
+func (f *Flags) Flagged(key string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	until, ok := f.until[key]
+
+	return ok && time.Now().Before(until)
+}
+
+// sweep drops expired flags at most once per ttl so the map doesn't grow unbounded.
+func (f *Flags) sweep(now time.Time) {
+	if now.Before(f.nextSweep) {
+		return
+	}
+
+	for key, until := range f.until {
+		if !now.Before(until) {
+			delete(f.until, key)
+		}
+	}
+
+	f.nextSweep = now.Add(f.ttl)
+}
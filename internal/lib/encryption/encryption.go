@@ -0,0 +1,153 @@
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+)
+
+const (
+	keySize   = 32
+	nonceSize = 12
+	// wrappedKeySize is a nonce plus an AES-GCM sealed data key.
+	wrappedKeySize = nonceSize + keySize + 16
+	headerSize     = 1 + wrappedKeySize + nonceSize
+)
+
+var (
+	ErrUnknownKeyVersion = errors.New("unknown encryption key version")
+	ErrMalformed         = errors.New("malformed ciphertext")
+)
+
+// Keyring does envelope encryption of sensitive values: each value is sealed with a
+// fresh data key, and the data key is sealed with a versioned master key. The
+// master key version is stored with the value so old keys keep decrypting after
+// rotation while new writes use the current key.
+//
+// Layout: version (1) | wrapped data key (60) | nonce (12) | ciphertext.
+type Keyring struct {
+	current byte
+	keys    map[byte]cipher.AEAD
+}
+
+// NewKeyring builds a keyring from 32-byte master keys indexed by version. current
+// selects the key new values are encrypted with.
+func NewKeyring(current byte, keys map[byte][]byte) (*Keyring, error) {
+	if _, ok := keys[current]; !ok {
+		return nil, fmt.Errorf("current key version %d: %w", current, ErrUnknownKeyVersion)
+	}
+
+	k := &Keyring{
+		current: current,
+		keys:    make(map[byte]cipher.AEAD, len(keys)),
+	}
+
+	for version, key := range keys {
+		aead, err := newAEAD(key)
+		if err != nil {
+			return nil, fmt.Errorf("key version %d: %w", version, err)
+		}
+		k.keys[version] = aead
+	}
+
+	return k, nil
+}
+
+func (k *Keyring) Encrypt(plaintext []byte) ([]byte, error) {
+	dataKey := make([]byte, keySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, err
+	}
+
+	wrapped, err := seal(k.keys[k.current], dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	dataAEAD, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	sealed, err := seal(dataAEAD, plaintext)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]byte, 0, 1+len(wrapped)+len(sealed))
+	out = append(out, k.current)
+	out = append(out, wrapped...)
+	out = append(out, sealed...)
+
+	return out, nil
+}
+
+func (k *Keyring) Decrypt(data []byte) ([]byte, error) {
+	if len(data) < headerSize {
+		return nil, ErrMalformed
+	}
+
+	master, ok := k.keys[data[0]]
+	if !ok {
+		return nil, fmt.Errorf("key version %d: %w", data[0], ErrUnknownKeyVersion)
+	}
+
+	dataKey, err := open(master, data[1:1+wrappedKeySize])
+	if err != nil {
+		return nil, err
+	}
+
+	dataAEAD, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return open(dataAEAD, data[1+wrappedKeySize:])
+}
+
+// KeyVersion reports which master key version encrypted data, so callers can find
+// values that still need re-encryption after a rotation.
+func KeyVersion(data []byte) (byte, error) {
+	if len(data) < headerSize {
+		return 0, ErrMalformed
+	}
+
+	return data[0], nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) != keySize {
+		return nil, fmt.Errorf("key must be %d bytes, got %d", keySize, len(key))
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+func seal(aead cipher.AEAD, plaintext []byte) ([]byte, error) {
+	nonce := make([]byte, nonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	return aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func open(aead cipher.AEAD, data []byte) ([]byte, error) {
+	if len(data) < nonceSize {
+		return nil, ErrMalformed
+	}
+
+	plaintext, err := aead.Open(nil, data[:nonceSize], data[nonceSize:], nil)
+	if err != nil {
+		return nil, ErrMalformed
+	}
+
+	return plaintext, nil
+}
@@ -0,0 +1,148 @@
+package encryption
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+var (
+	oldKey = bytes.Repeat([]byte{1}, keySize)
+	newKey = bytes.Repeat([]byte{2}, keySize)
+)
+
+func TestKeyringRoundTrip(t *testing.T) {
+	tests := []struct {
+		name      string
+		plaintext []byte
+	}{
+		{name: "empty", plaintext: []byte{}},
+		{name: "totp secret", plaintext: []byte("JBSWY3DPEHPK3PXP")},
+		{name: "large", plaintext: bytes.Repeat([]byte("x"), 1<<16)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k, err := NewKeyring(1, map[byte][]byte{1: oldKey})
+			if err != nil {
+				t.Fatalf("keyring: %v", err)
+			}
+
+			a, err := k.Encrypt(tt.plaintext)
+			if err != nil {
+				t.Fatalf("encrypt: %v", err)
+			}
+			b, err := k.Encrypt(tt.plaintext)
+			if err != nil {
+				t.Fatalf("encrypt: %v", err)
+			}
+			if bytes.Equal(a, b) {
+				t.Error("encrypting twice gave the same ciphertext")
+			}
+
+			got, err := k.Decrypt(a)
+			if err != nil {
+				t.Fatalf("decrypt: %v", err)
+			}
+			if !bytes.Equal(got, tt.plaintext) {
+				t.Errorf("decrypt = %q, want %q", got, tt.plaintext)
+			}
+		})
+	}
+}
+
+func TestKeyringRotation(t *testing.T) {
+	before, err := NewKeyring(1, map[byte][]byte{1: oldKey})
+	if err != nil {
+		t.Fatalf("keyring: %v", err)
+	}
+	after, err := NewKeyring(2, map[byte][]byte{1: oldKey, 2: newKey})
+	if err != nil {
+		t.Fatalf("keyring: %v", err)
+	}
+
+	old, err := before.Encrypt([]byte("secret"))
+	if err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+	current, err := after.Encrypt([]byte("secret"))
+	if err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		keyring     *Keyring
+		data        []byte
+		wantVersion byte
+		wantErr     error
+	}{
+		{name: "old value after rotation", keyring: after, data: old, wantVersion: 1},
+		{name: "new value after rotation", keyring: after, data: current, wantVersion: 2},
+		{name: "new value without the new key", keyring: before, data: current, wantVersion: 2, wantErr: ErrUnknownKeyVersion},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			version, err := KeyVersion(tt.data)
+			if err != nil {
+				t.Fatalf("key version: %v", err)
+			}
+			if version != tt.wantVersion {
+				t.Errorf("key version = %d, want %d", version, tt.wantVersion)
+			}
+
+			got, err := tt.keyring.Decrypt(tt.data)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("decrypt error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && string(got) != "secret" {
+				t.Errorf("decrypt = %q, want %q", got, "secret")
+			}
+		})
+	}
+}
+
+func TestKeyringRejectsTampering(t *testing.T) {
+	k, err := NewKeyring(1, map[byte][]byte{1: oldKey})
+	if err != nil {
+		t.Fatalf("keyring: %v", err)
+	}
+	data, err := k.Encrypt([]byte("secret"))
+	if err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		data func() []byte
+	}{
+		{name: "truncated", data: func() []byte { return data[:headerSize-1] }},
+		{name: "flipped wrapped key", data: func() []byte { return flip(data, 1) }},
+		{name: "flipped ciphertext", data: func() []byte { return flip(data, len(data)-1) }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := k.Decrypt(tt.data()); !errors.Is(err, ErrMalformed) {
+				t.Errorf("decrypt error = %v, want ErrMalformed", err)
+			}
+		})
+	}
+}
+
+func TestNewKeyringRejectsBadKeys(t *testing.T) {
+	if _, err := NewKeyring(2, map[byte][]byte{1: oldKey}); !errors.Is(err, ErrUnknownKeyVersion) {
+		t.Errorf("missing current key: error = %v, want ErrUnknownKeyVersion", err)
+	}
+	if _, err := NewKeyring(1, map[byte][]byte{1: oldKey[:16]}); err == nil {
+		t.Error("short key accepted")
+	}
+}
+
+// flip returns a copy of data with the byte at i inverted.
+func flip(data []byte, i int) []byte {
+	out := bytes.Clone(data)
+	out[i] ^= 0xff
+	return out
+}
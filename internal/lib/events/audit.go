@@ -0,0 +1,64 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sso/internal/domain/models"
+	"time"
+)
+
+type AuditAppender interface {
+	AppendAuditEvent(ctx context.Context, event models.AuditEvent) (int64, error)
+}
+
+// AuditPublisher records events in the audit log. AccountID, ActorID, IPAddress and
+// OccurredAt are taken from the event's fields of those names when present; the
+// whole event is stored as the JSON payload.
+type AuditPublisher struct {
+	store AuditAppender
+}
+
+func NewAuditPublisher(store AuditAppender) *AuditPublisher {
+	return &AuditPublisher{store: store}
+}
+
+func (p *AuditPublisher) Publish(ctx context.Context, event any) error {
+	const op = "events.AuditPublisher.Publish"
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	entry := models.AuditEvent{
+		Type:       reflect.TypeOf(event).Name(),
+		Payload:    string(payload),
+		OccurredAt: time.Now(),
+	}
+
+	v := reflect.Indirect(reflect.ValueOf(event))
+	if v.Kind() == reflect.Struct {
+		if f := v.FieldByName("AccountID"); f.IsValid() && f.CanInt() {
+			entry.AccountID = f.Int()
+		}
+		if f := v.FieldByName("ActorID"); f.IsValid() && f.CanInt() {
+			entry.ActorID = f.Int()
+		}
+		if f := v.FieldByName("IPAddress"); f.IsValid() && f.Kind() == reflect.String {
+			entry.IPAddress = f.String()
+		}
+		if f := v.FieldByName("OccurredAt"); f.IsValid() {
+			if t, ok := f.Interface().(time.Time); ok && !t.IsZero() {
+				entry.OccurredAt = t
+			}
+		}
+	}
+
+	if _, err := p.store.AppendAuditEvent(ctx, entry); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
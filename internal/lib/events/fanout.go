@@ -0,0 +1,25 @@
+package events
+
+import (
+	"context"
+	"errors"
+)
+
+type Publisher interface {
+	Publish(ctx context.Context, event any) error
+}
+
+// Fanout publishes every event to all of its publishers, even if some fail, and
+// returns their errors joined.
+type Fanout []Publisher
+
+func (f Fanout) Publish(ctx context.Context, event any) error {
+	var errs []error
+	for _, p := range f {
+		if err := p.Publish(ctx, event); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
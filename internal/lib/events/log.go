@@ -0,0 +1,26 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+)
+
+// LogPublisher writes events to the log. It is the default publisher when no
+// message broker is configured.
+type LogPublisher struct {
+	log *slog.Logger
+}
+
+func NewLogPublisher(log *slog.Logger) *LogPublisher {
+	return &LogPublisher{log: log}
+}
+
+func (p *LogPublisher) Publish(ctx context.Context, event any) error {
+	p.log.InfoContext(ctx, "event published",
+		slog.String("type", fmt.Sprintf("%T", event)),
+		slog.Any("event", event),
+	)
+
+	return nil
+}
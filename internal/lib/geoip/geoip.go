@@ -0,0 +1,114 @@
+// Package geoip locates IP addresses, for telling account holders and risk checks
+// where a login came from.
+package geoip
+
+import (
+	"fmt"
+	"math"
+	"net"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// Location is where an IP address is. Coordinates is false when the database only
+// knows the country, in which case Latitude and Longitude are meaningless.
+type Location struct {
+	Country     string
+	City        string
+	Latitude    float64
+	Longitude   float64
+	Coordinates bool
+}
+
+// String renders the location for people, e.g. "Berlin, DE".
+func (l Location) String() string {
+	if l.City == "" {
+		return l.Country
+	}
+
+	return l.City + ", " + l.Country
+}
+
+// Resolver locates IP addresses. ok is false for addresses the database doesn't
+// know, such as private ones.
+type Resolver interface {
+	Resolve(ip string) (loc Location, ok bool, err error)
+}
+
+const earthRadiusKm = 6371
+
+// Distance is the great-circle distance between two locations in kilometers.
+func Distance(a, b Location) float64 {
+	lat1, lat2 := a.Latitude*math.Pi/180, b.Latitude*math.Pi/180
+	dLat := lat2 - lat1
+	dLon := (b.Longitude - a.Longitude) * math.Pi / 180
+
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(h))
+}
+
+// MaxMind resolves addresses with a MaxMind GeoIP2 or GeoLite2 City or Country
+// database.
+type MaxMind struct {
+	reader *maxminddb.Reader
+}
+
+// OpenMaxMind opens the .mmdb database at path.
+func OpenMaxMind(path string) (*MaxMind, error) {
+	const op = "geoip.OpenMaxMind"
+
+	reader, err := maxminddb.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &MaxMind{reader: reader}, nil
+}
+
+type maxMindRecord struct {
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+	City struct {
+		Names map[string]string `maxminddb:"names"`
+	} `maxminddb:"city"`
+	Location struct {
+		Latitude  *float64 `maxminddb:"latitude"`
+		Longitude *float64 `maxminddb:"longitude"`
+	} `maxminddb:"location"`
+}
+
+func (m *MaxMind) Resolve(ip string) (Location, bool, error) {
+	const op = "geoip.MaxMind.Resolve"
+
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return Location{}, false, nil
+	}
+
+	var record maxMindRecord
+	_, ok, err := m.reader.LookupNetwork(addr, &record)
+	if err != nil {
+		return Location{}, false, fmt.Errorf("%s: %w", op, err)
+	}
+	if !ok || record.Country.ISOCode == "" {
+		return Location{}, false, nil
+	}
+
+	loc := Location{
+		Country: record.Country.ISOCode,
+		City:    record.City.Names["en"],
+	}
+	if record.Location.Latitude != nil && record.Location.Longitude != nil {
+		loc.Latitude = *record.Location.Latitude
+		loc.Longitude = *record.Location.Longitude
+		loc.Coordinates = true
+	}
+
+	return loc, true, nil
+}
+
+func (m *MaxMind) Close() error {
+	return m.reader.Close()
+}
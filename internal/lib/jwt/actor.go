@@ -0,0 +1,58 @@
+package jwt
+
+import (
+	"context"
+	"time"
+
+	"sso/internal/domain/models"
+)
+
+// Actor is a party acting on behalf of a token's subject, as the act claim of
+// token exchange (RFC 8693) names it. Actor is the party that acted before it,
+// when the token was exchanged from a token that was itself exchanged.
+type Actor struct {
+	Subject  string
+	ClientID string
+	Actor    *Actor
+}
+
+func (a *Actor) claim() map[string]any {
+	claim := map[string]any{"sub": a.Subject}
+	if a.ClientID != "" {
+		claim["client_id"] = a.ClientID
+	}
+	if a.Actor != nil {
+		claim["act"] = a.Actor.claim()
+	}
+
+	return claim
+}
+
+// parseActor reads an act claim, returning nil for tokens without one.
+func parseActor(claim any) *Actor {
+	fields, ok := claim.(map[string]any)
+	if !ok {
+		return nil
+	}
+
+	sub, _ := fields["sub"].(string)
+	clientID, _ := fields["client_id"].(string)
+
+	return &Actor{
+		Subject:  sub,
+		ClientID: clientID,
+		Actor:    parseActor(fields["act"]),
+	}
+}
+
+// NewDelegatedToken is NewToken for a token the account delegated to actor, which
+// the token names in its act claim. Enrichers may not set act themselves.
+func NewDelegatedToken(ctx context.Context, user models.Account, app models.App, duration time.Duration, authTime time.Time, subject SubjectFormat, issuer string, sid string, actor Actor, enricher ClaimsEnricher) (string, error) {
+	claims, err := BuildClaims(ctx, user, app, duration, authTime, subject, issuer, sid, enricher)
+	if err != nil {
+		return "", err
+	}
+	claims["act"] = actor.claim()
+
+	return sign(app, claims)
+}
@@ -0,0 +1,77 @@
+package jwt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"sso/internal/domain/models"
+)
+
+var ErrReservedClaim = errors.New("claim is reserved")
+
+// ClaimsContext is what a token is being issued for.
+type ClaimsContext struct {
+	// Account carries the role and scopes of the account in App, and the
+	// profile claims the token gets.
+	Account models.Account
+	App     models.App
+	// SessionID is the sid of the token's session, empty for claims previews.
+	SessionID string
+	// AuthTime is when the account last actually authenticated.
+	AuthTime time.Time
+}
+
+// ClaimsEnricher adds deployment-specific claims, such as org IDs, feature flags
+// or extra roles, to tokens as they are issued. The claims it returns may not
+// reuse the name of a claim the token already carries; doing so fails issuance
+// with ErrReservedClaim. An error fails issuance too, so enrichers that can live
+// without their data should return no claims instead.
+type ClaimsEnricher interface {
+	EnrichClaims(ctx context.Context, issue ClaimsContext) (map[string]any, error)
+}
+
+// ClaimsEnricherFunc adapts a function to ClaimsEnricher.
+type ClaimsEnricherFunc func(ctx context.Context, issue ClaimsContext) (map[string]any, error)
+
+func (f ClaimsEnricherFunc) EnrichClaims(ctx context.Context, issue ClaimsContext) (map[string]any, error) {
+	return f(ctx, issue)
+}
+
+// reservedClaims are registered claims enrichers may not set even on tokens that
+// don't carry them, such as iss when no issuer is configured.
+var reservedClaims = []string{"iss", "aud", "jti", "nbf", "iat", "act", "token_use"}
+
+// enrich merges the claims of enricher into claims.
+func enrich(ctx context.Context, claims map[string]any, enricher ClaimsEnricher, issue ClaimsContext) error {
+	if enricher == nil {
+		return nil
+	}
+
+	extra, err := enricher.EnrichClaims(ctx, issue)
+	if err != nil {
+		return fmt.Errorf("enrich claims: %w", err)
+	}
+
+	for name := range extra {
+		if _, taken := claims[name]; taken || isReservedClaim(name) {
+			return fmt.Errorf("%w: %s", ErrReservedClaim, name)
+		}
+	}
+	for name, value := range extra {
+		claims[name] = value
+	}
+
+	return nil
+}
+
+func isReservedClaim(name string) bool {
+	for _, reserved := range reservedClaims {
+		if name == reserved {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,84 @@
+package jwt
+
+import (
+	"time"
+
+	"sso/internal/domain/models"
+)
+
+// Authentication method references (RFC 8176) for the amr claim of ID tokens.
+const (
+	AMRPassword    = "pwd"
+	AMROTP         = "otp"
+	AMRSMS         = "sms"
+	AMRHardwareKey = "hwk"
+	// AMRMultiFactor marks an authentication that took more than one factor.
+	AMRMultiFactor = "mfa"
+)
+
+// IDToken is what an OpenID Connect ID token tells the app about how and when
+// the account authenticated.
+type IDToken struct {
+	Account models.Account
+	App     models.App
+	// Issuer is the iss claim, left out when empty.
+	Issuer    string
+	Subject   SubjectFormat
+	SessionID string
+	AuthTime  time.Time
+	// Nonce is the value the app passed to the login, echoed back so it can tie
+	// the token to its request. Left out when empty.
+	Nonce string
+	// Methods are the amr claim, how the account authenticated.
+	Methods []string
+	// Profile are standard claims about the account, such as name or locale, by
+	// claim name. Empty values are left out.
+	Profile map[string]string
+}
+
+// NewIDToken creates an ID token valid for duration, signed like the app's
+// access tokens. Its aud is the app's, see Audience.
+func NewIDToken(token IDToken, duration time.Duration) (string, error) {
+	jti, err := newJTI()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+
+	claims := make(map[string]any)
+	if token.Issuer != "" {
+		claims["iss"] = token.Issuer
+	}
+	claims["sub"] = token.Subject.Subject(token.Account.ID)
+	claims["aud"] = Audience(token.App)
+	claims["jti"] = jti
+	claims["iat"] = now.Unix()
+	claims["exp"] = now.Add(duration).Unix()
+	claims["auth_time"] = token.AuthTime.Unix()
+	if token.SessionID != "" {
+		claims["sid"] = token.SessionID
+	}
+	if token.Nonce != "" {
+		claims["nonce"] = token.Nonce
+	}
+	if len(token.Methods) > 0 {
+		claims["amr"] = token.Methods
+	}
+	if token.Account.Email != "" {
+		claims["email"] = token.Account.Email
+	}
+	if token.Account.Phone != "" {
+		claims["phone_number"] = token.Account.Phone
+	}
+	if token.Account.Username != "" {
+		claims["preferred_username"] = token.Account.Username
+	}
+	for name, value := range token.Profile {
+		if value != "" {
+			claims[name] = value
+		}
+	}
+
+	return sign(token.App, claims)
+}
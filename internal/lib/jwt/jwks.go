@@ -0,0 +1,87 @@
+package jwt
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+
+	"sso/internal/domain/models"
+)
+
+// JWK is the public part of a signing key as a JSON Web Key (RFC 7517).
+type JWK struct {
+	KeyType   string `json:"kty"`
+	Use       string `json:"use"`
+	KeyID     string `json:"kid"`
+	Algorithm string `json:"alg"`
+	// N and E are the RSA modulus and exponent.
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// Curve, X and Y describe EC and OKP keys; OKP keys have no Y.
+	Curve string `json:"crv,omitempty"`
+	X     string `json:"x,omitempty"`
+	Y     string `json:"y,omitempty"`
+}
+
+// JWKS is a JWK Set document.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// PublicJWKS returns the public keys verifying the app's tokens, the current one
+// first, as a JWK Set. Apps signing with their secret have none to publish, so
+// their set is empty.
+func PublicJWKS(app models.App) (JWKS, error) {
+	set := JWKS{Keys: make([]JWK, 0, len(app.SigningKeys))}
+
+	for _, key := range app.SigningKeys {
+		jwk, err := publicJWK(key)
+		if err != nil {
+			return JWKS{}, err
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+
+	return set, nil
+}
+
+func publicJWK(key models.SigningKey) (JWK, error) {
+	signer, err := keySigner(key)
+	if err != nil {
+		return JWK{}, err
+	}
+
+	jwk := JWK{
+		Use:       "sig",
+		KeyID:     key.KID,
+		Algorithm: key.Algorithm.String(),
+	}
+
+	switch public := signer.Public().(type) {
+	case *rsa.PublicKey:
+		jwk.KeyType = "RSA"
+		jwk.N = encodeBase64URL(public.N.Bytes())
+		jwk.E = encodeBase64URL(big.NewInt(int64(public.E)).Bytes())
+	case *ecdsa.PublicKey:
+		size := (public.Curve.Params().BitSize + 7) / 8
+		jwk.KeyType = "EC"
+		jwk.Curve = public.Curve.Params().Name
+		jwk.X = encodeBase64URL(public.X.FillBytes(make([]byte, size)))
+		jwk.Y = encodeBase64URL(public.Y.FillBytes(make([]byte, size)))
+	case ed25519.PublicKey:
+		jwk.KeyType = "OKP"
+		jwk.Curve = "Ed25519"
+		jwk.X = encodeBase64URL(public)
+	default:
+		return JWK{}, fmt.Errorf("signing key %s: unsupported public key %T", key.KID, public)
+	}
+
+	return jwk, nil
+}
+
+func encodeBase64URL(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -1,26 +1,266 @@
 package jwt
 
 import (
+	"context"
+	"errors"
 	"sso/internal/domain/models"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
-// NewToken creates new JWT token for given user and app.
-func NewToken(user models.Account, app models.App, duration time.Duration) (string, error) {
-	token := jwt.New(jwt.SigningMethodHS256)
+var (
+	// ErrTokenExpired reports a token past its expiry.
+	ErrTokenExpired = jwt.ErrTokenExpired
+	// ErrTokenInvalidClaims reports a token ParseAndValidate rejects for its
+	// claims: expired, not yet valid, or from the wrong issuer or audience.
+	ErrTokenInvalidClaims = jwt.ErrTokenInvalidClaims
+)
+
+// NewToken creates new JWT token for given user and app. authTime is when the user
+// last actually authenticated, carried over unchanged through refreshes. subject
+// renders the account ID as the sub claim. issuer is the iss claim, left out when
+// empty; the aud claim is the app's, see Audience. sid is the public ID of the
+// session the token belongs to; it also keeps tokens minted in the same second
+// distinct. The token is signed with the app's signing algorithm, see
+// models.SigningAlgorithm, and names the key it is signed with in its kid header.
+// A non-nil enricher adds its claims, see ClaimsEnricher. Every token gets a
+// unique jti and is valid from the moment it is issued.
+func NewToken(ctx context.Context, user models.Account, app models.App, duration time.Duration, authTime time.Time, subject SubjectFormat, issuer string, sid string, enricher ClaimsEnricher) (string, error) {
+	claims, err := BuildClaims(ctx, user, app, duration, authTime, subject, issuer, sid, enricher)
+	if err != nil {
+		return "", err
+	}
+
+	return sign(app, claims)
+}
+
+// sign signs claims with the app's current signing key.
+func sign(app models.App, claims map[string]any) (string, error) {
+	method, signKey, kid, err := signingKey(app)
+	if err != nil {
+		return "", err
+	}
+
+	token := jwt.NewWithClaims(method, jwt.MapClaims(claims))
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+
+	tokenString, err := token.SignedString(signKey)
+	if err != nil {
+		return "", err
+	}
+
+	return tokenString, nil
+}
+
+// BuildClaims returns the claims NewToken signs for user and app, without signing.
+func BuildClaims(ctx context.Context, user models.Account, app models.App, duration time.Duration, authTime time.Time, subject SubjectFormat, issuer string, sid string, enricher ClaimsEnricher) (map[string]any, error) {
+	jti, err := newJTI()
+	if err != nil {
+		return nil, err
+	}
 
-	claims := token.Claims.(jwt.MapClaims)
+	now := time.Now()
+
+	claims := make(map[string]any)
+	if issuer != "" {
+		claims["iss"] = issuer
+	}
+	claims["sub"] = subject.Subject(user.ID)
+	claims["aud"] = Audience(app)
+	claims["jti"] = jti
+	claims["iat"] = now.Unix()
+	claims["nbf"] = now.Unix()
 	claims["uid"] = user.ID
 	claims["email"] = user.Email
-	claims["exp"] = time.Now().Add(duration).Unix()
+	claims["role"] = user.Role
+	claims["exp"] = now.Add(duration).Unix()
 	claims["app_id"] = app.ID
+	claims["ver"] = user.TokenVersion
+	claims["app_ver"] = app.TokenVersion
+	claims["auth_time"] = authTime.Unix()
+	if sid != "" {
+		claims["sid"] = sid
+	}
+	if len(user.Scopes) > 0 {
+		claims["scope"] = strings.Join(user.Scopes, " ")
+	}
+	if user.RequiresPasswordChange {
+		claims["pwd_change"] = true
+	}
+	for name, value := range user.ProfileClaims {
+		claims[name] = value
+	}
 
-	tokenString, err := token.SignedString([]byte(app.Secret))
+	err = enrich(ctx, claims, enricher, ClaimsContext{
+		Account:   user,
+		App:       app,
+		SessionID: sid,
+		AuthTime:  authTime,
+	})
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	return tokenString, nil
+	return claims, nil
+}
+
+// Claims are the fields NewToken embeds into a token.
+type Claims struct {
+	UID             int64
+	Subject         string
+	Email           string
+	Role            models.AccountRole
+	AppID           int64
+	TokenVersion    int64
+	AppTokenVersion int64
+	Scopes          []string
+	ExpiresAt       time.Time
+	AuthTime        time.Time
+	// Issuer is the iss claim, empty for tokens issued without one.
+	Issuer   string
+	Audience []string
+	// ID is the jti claim, unique per token.
+	ID        string
+	IssuedAt  time.Time
+	NotBefore time.Time
+	// Actor is the act claim of exchanged tokens, nil for tokens issued to the
+	// account itself.
+	Actor *Actor
+	// SessionID is the sid claim, the public ID of the token's session.
+	SessionID string
+	// PasswordChangeRequired marks a token only good for changing the password.
+	PasswordChangeRequired bool
+}
+
+// Parse verifies the token signature with the app's key named by its kid header
+// and returns its claims. Retired keys verify until they retire. Expired tokens
+// are rejected.
+func Parse(tokenString string, app models.App) (Claims, error) {
+	return parse(tokenString, app)
+}
+
+// ParseIgnoringExpiry is Parse for tokens that may be past their expiry, e.g. to
+// read the claims of a token whose session is checked separately.
+func ParseIgnoringExpiry(tokenString string, app models.App) (Claims, error) {
+	return parse(tokenString, app, jwt.WithoutClaimsValidation())
+}
+
+// AppID reads the app_id claim of a token without verifying it, to find the app
+// whose key the token must then be verified with.
+func AppID(tokenString string) (int64, error) {
+	var claims jwt.MapClaims
+	if _, _, err := jwt.NewParser().ParseUnverified(tokenString, &claims); err != nil {
+		return 0, err
+	}
+
+	appID, ok := claims["app_id"].(float64)
+	if !ok {
+		return 0, errors.New("token has no app_id")
+	}
+
+	return int64(appID), nil
+}
+
+// TokenID reads the jti and expiry of a token without verifying it, for tokens
+// the caller has just issued.
+func TokenID(tokenString string) (string, time.Time, error) {
+	var claims jwt.MapClaims
+	if _, _, err := jwt.NewParser().ParseUnverified(tokenString, &claims); err != nil {
+		return "", time.Time{}, err
+	}
+
+	jti, _ := claims["jti"].(string)
+	exp, err := claims.GetExpirationTime()
+	if jti == "" || err != nil || exp == nil {
+		return "", time.Time{}, errors.New("token has no jti or expiration")
+	}
+
+	return jti, exp.Time, nil
+}
+
+func parse(tokenString string, app models.App, opts ...jwt.ParserOption) (Claims, error) {
+	claims, err := parseClaims(tokenString, app, opts...)
+	if err != nil {
+		return Claims{}, err
+	}
+
+	if use, _ := claims["token_use"].(string); use == refreshTokenUse {
+		return Claims{}, errors.New("refresh token used as access token")
+	}
+
+	exp, err := claims.GetExpirationTime()
+	if err != nil || exp == nil {
+		return Claims{}, errors.New("token has no expiration")
+	}
+
+	uid, _ := claims["uid"].(float64)
+	sub, _ := claims["sub"].(string)
+	appID, _ := claims["app_id"].(float64)
+	email, _ := claims["email"].(string)
+	role, _ := claims["role"].(float64)
+	version, _ := claims["ver"].(float64)
+	appVersion, _ := claims["app_ver"].(float64)
+	scope, _ := claims["scope"].(string)
+	authTime, _ := claims["auth_time"].(float64)
+	pwdChange, _ := claims["pwd_change"].(bool)
+	sid, _ := claims["sid"].(string)
+	iss, _ := claims["iss"].(string)
+	aud, _ := claims.GetAudience()
+	jti, _ := claims["jti"].(string)
+	iat, _ := claims["iat"].(float64)
+	nbf, _ := claims["nbf"].(float64)
+
+	return Claims{
+		UID:                    int64(uid),
+		Subject:                sub,
+		Email:                  email,
+		Role:                   models.AccountRole(role),
+		AppID:                  int64(appID),
+		TokenVersion:           int64(version),
+		AppTokenVersion:        int64(appVersion),
+		Scopes:                 strings.Fields(scope),
+		ExpiresAt:              exp.Time,
+		AuthTime:               unixTime(authTime),
+		Issuer:                 iss,
+		Audience:               aud,
+		ID:                     jti,
+		IssuedAt:               unixTime(iat),
+		NotBefore:              unixTime(nbf),
+		Actor:                  parseActor(claims["act"]),
+		SessionID:              sid,
+		PasswordChangeRequired: pwdChange,
+	}, nil
+}
+
+// parseClaims verifies the token signature with the app's key named by its kid
+// header and returns its raw claims.
+func parseClaims(tokenString string, app models.App, opts ...jwt.ParserOption) (jwt.MapClaims, error) {
+	opts = append(opts, jwt.WithValidMethods(verificationMethods(app)))
+
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		return verificationKey(app, token)
+	}, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, errors.New("unexpected claims type")
+	}
+
+	return claims, nil
+}
+
+// unixTime converts a numeric claim to a time, keeping zero for a missing claim.
+func unixTime(sec float64) time.Time {
+	if sec == 0 {
+		return time.Time{}
+	}
+
+	return time.Unix(int64(sec), 0)
 }
@@ -0,0 +1,254 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"time"
+
+	"sso/internal/domain/models"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	ErrUnsupportedAlgorithm = errors.New("unsupported signing algorithm")
+	// ErrSymmetricAlgorithm reports an app whose tokens are signed with its secret,
+	// so it has no public key to hand out.
+	ErrSymmetricAlgorithm = errors.New("app signs with a shared secret")
+	ErrUnknownKeyID       = errors.New("unknown signing key")
+)
+
+const (
+	rsaKeyBits = 2048
+	kidBytes   = 8
+)
+
+// GenerateSigningKey generates a key pair for alg under a random kid.
+// SigningHS256 has no key of its own and is unsupported.
+func GenerateSigningKey(alg models.SigningAlgorithm) (models.SigningKey, error) {
+	var (
+		key any
+		err error
+	)
+
+	switch alg {
+	case models.SigningRS256:
+		key, err = rsa.GenerateKey(rand.Reader, rsaKeyBits)
+	case models.SigningES256:
+		key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case models.SigningEdDSA:
+		_, key, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return models.SigningKey{}, fmt.Errorf("%w: %q", ErrUnsupportedAlgorithm, alg)
+	}
+	if err != nil {
+		return models.SigningKey{}, err
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return models.SigningKey{}, err
+	}
+
+	kid, err := NewKeyID()
+	if err != nil {
+		return models.SigningKey{}, err
+	}
+
+	return models.SigningKey{
+		KID:        kid,
+		Algorithm:  alg,
+		PrivateKey: der,
+		CreatedAt:  time.Now(),
+	}, nil
+}
+
+// NewKeyID returns a random kid for a new signing key.
+func NewKeyID() (string, error) {
+	kid := make([]byte, kidBytes)
+	if _, err := rand.Read(kid); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(kid), nil
+}
+
+// PublicKeyPEM returns the PEM encoded public key of the app's current signing
+// key, for services that verify its tokens without the app secret. Apps signing
+// with SigningHS256 return ErrSymmetricAlgorithm.
+func PublicKeyPEM(app models.App) (string, error) {
+	if isSymmetric(app) {
+		return "", ErrSymmetricAlgorithm
+	}
+	if len(app.SigningKeys) == 0 {
+		return "", ErrUnknownKeyID
+	}
+
+	signer, err := keySigner(app.SigningKeys[0])
+	if err != nil {
+		return "", err
+	}
+
+	der, err := x509.MarshalPKIXPublicKey(signer.Public())
+	if err != nil {
+		return "", err
+	}
+
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})), nil
+}
+
+func isSymmetric(app models.App) bool {
+	return app.SigningAlgorithm == "" || app.SigningAlgorithm == models.SigningHS256
+}
+
+// signingKey returns the signing method of new tokens of the app, the key they
+// are signed with and its kid, empty for the app secret.
+func signingKey(app models.App) (jwt.SigningMethod, any, string, error) {
+	if isSymmetric(app) {
+		return jwt.SigningMethodHS256, []byte(app.Secret), "", nil
+	}
+
+	if len(app.SigningKeys) == 0 || app.SigningKeys[0].Algorithm != app.SigningAlgorithm {
+		return nil, nil, "", fmt.Errorf("app has no %s signing key", app.SigningAlgorithm)
+	}
+
+	key := app.SigningKeys[0]
+
+	method, err := signingMethod(key.Algorithm)
+	if err != nil {
+		return nil, nil, "", err
+	}
+
+	signer, err := keySigner(key)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	if key.Signer != nil {
+		method = signerMethod{method}
+	}
+
+	return method, signer, key.KID, nil
+}
+
+// verificationMethods lists the algorithms the app's tokens may be signed with:
+// its secret if it signs with it, and the algorithm of each of its keys.
+func verificationMethods(app models.App) []string {
+	var methods []string
+	if isSymmetric(app) {
+		methods = append(methods, models.SigningHS256.String())
+	}
+	for _, key := range app.SigningKeys {
+		methods = append(methods, key.Algorithm.String())
+	}
+
+	return methods
+}
+
+// verificationKey returns the key that verifies token for the app: the key named
+// by its kid, which must be of the token's algorithm, or for tokens without a
+// kid the app secret, or the current key for tokens signed before keys had IDs.
+func verificationKey(app models.App, token *jwt.Token) (any, error) {
+	alg := token.Method.Alg()
+
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		if alg == models.SigningHS256.String() {
+			return []byte(app.Secret), nil
+		}
+		if len(app.SigningKeys) == 0 {
+			return nil, ErrUnknownKeyID
+		}
+		kid = app.SigningKeys[0].KID
+	}
+
+	for _, key := range app.SigningKeys {
+		if key.KID != kid {
+			continue
+		}
+		if key.Algorithm.String() != alg {
+			return nil, fmt.Errorf("%w: %s is not a %s key", ErrUnknownKeyID, kid, alg)
+		}
+
+		signer, err := keySigner(key)
+		if err != nil {
+			return nil, err
+		}
+
+		return signer.Public(), nil
+	}
+
+	return nil, fmt.Errorf("%w: %s", ErrUnknownKeyID, kid)
+}
+
+func signingMethod(alg models.SigningAlgorithm) (jwt.SigningMethod, error) {
+	switch alg {
+	case models.SigningRS256:
+		return jwt.SigningMethodRS256, nil
+	case models.SigningES256:
+		return jwt.SigningMethodES256, nil
+	case models.SigningEdDSA:
+		return jwt.SigningMethodEdDSA, nil
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedAlgorithm, alg)
+	}
+}
+
+// keySigner returns the signer of key: the key provider's for keys held by one,
+// the parsed private key for the rest.
+func keySigner(key models.SigningKey) (crypto.Signer, error) {
+	if key.KeyRef != "" {
+		if key.Signer == nil {
+			return nil, fmt.Errorf("signing key %s: no key provider for %s", key.KID, key.KeyRef)
+		}
+		if alg, err := KeyAlgorithm(key.Signer); err != nil || alg != key.Algorithm {
+			return nil, fmt.Errorf("signing key %s doesn't fit %s", key.KID, key.Algorithm)
+		}
+
+		return key.Signer, nil
+	}
+
+	return parseSigningKey(key)
+}
+
+func parseSigningKey(key models.SigningKey) (crypto.Signer, error) {
+	parsed, err := x509.ParsePKCS8PrivateKey(key.PrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("signing key %s: %w", key.KID, err)
+	}
+
+	signer, ok := parsed.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("signing key %s doesn't fit %s", key.KID, key.Algorithm)
+	}
+	if alg, err := KeyAlgorithm(signer); err != nil || alg != key.Algorithm {
+		return nil, fmt.Errorf("signing key %s doesn't fit %s", key.KID, key.Algorithm)
+	}
+
+	return signer, nil
+}
+
+// KeyAlgorithm returns the algorithm tokens signed by signer use, told by its
+// public key: RS256 for RSA, ES256 for P-256 and EdDSA for Ed25519 keys.
+func KeyAlgorithm(signer crypto.Signer) (models.SigningAlgorithm, error) {
+	switch public := signer.Public().(type) {
+	case *rsa.PublicKey:
+		return models.SigningRS256, nil
+	case *ecdsa.PublicKey:
+		if public.Curve == elliptic.P256() {
+			return models.SigningES256, nil
+		}
+	case ed25519.PublicKey:
+		return models.SigningEdDSA, nil
+	}
+
+	return "", fmt.Errorf("%w: %T key", ErrUnsupportedAlgorithm, signer.Public())
+}
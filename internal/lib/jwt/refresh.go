@@ -0,0 +1,101 @@
+package jwt
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"sso/internal/domain/models"
+)
+
+// refreshTokenUse is the token_use claim of refresh tokens issued as JWTs. It
+// keeps them from passing for access tokens, which are signed with the same key.
+const refreshTokenUse = "refresh"
+
+// ErrNotRefreshToken reports a token ParseRefreshToken rejects for not being a
+// refresh token.
+var ErrNotRefreshToken = errors.New("not a refresh token")
+
+// RefreshClaims are the fields NewRefreshToken embeds into a refresh token.
+type RefreshClaims struct {
+	Subject string
+	AppID   int64
+	// SessionID is the sid claim, the public ID of the session the token
+	// refreshes.
+	SessionID string
+	// FamilyID is the fam claim, shared by every refresh token rotated from the
+	// same login.
+	FamilyID  string
+	ID        string
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+}
+
+// NewRefreshToken creates a refresh token for the session sid of user and app,
+// expiring at expiresAt, signed like the app's access tokens. familyID names the
+// refresh family the session belongs to. The token is only a pre-check: the
+// session it names must still be looked up to refresh it.
+func NewRefreshToken(user models.Account, app models.App, expiresAt time.Time, subject SubjectFormat, issuer string, sid string, familyID string) (string, error) {
+	jti, err := newJTI()
+	if err != nil {
+		return "", err
+	}
+
+	claims := make(map[string]any)
+	if issuer != "" {
+		claims["iss"] = issuer
+	}
+	claims["sub"] = subject.Subject(user.ID)
+	claims["aud"] = Audience(app)
+	claims["jti"] = jti
+	claims["iat"] = time.Now().Unix()
+	claims["exp"] = expiresAt.Unix()
+	claims["app_id"] = app.ID
+	claims["sid"] = sid
+	claims["fam"] = familyID
+	claims["token_use"] = refreshTokenUse
+
+	return sign(app, claims)
+}
+
+// ParseRefreshToken verifies a refresh token issued by NewRefreshToken with the
+// app's key and returns its claims. Expired tokens are rejected, and so are
+// access and ID tokens with ErrNotRefreshToken.
+func ParseRefreshToken(tokenString string, app models.App) (RefreshClaims, error) {
+	claims, err := parseClaims(tokenString, app)
+	if err != nil {
+		return RefreshClaims{}, err
+	}
+
+	if use, _ := claims["token_use"].(string); use != refreshTokenUse {
+		return RefreshClaims{}, ErrNotRefreshToken
+	}
+
+	exp, err := claims.GetExpirationTime()
+	if err != nil || exp == nil {
+		return RefreshClaims{}, errors.New("token has no expiration")
+	}
+
+	sub, _ := claims["sub"].(string)
+	appID, _ := claims["app_id"].(float64)
+	sid, _ := claims["sid"].(string)
+	fam, _ := claims["fam"].(string)
+	jti, _ := claims["jti"].(string)
+	iat, _ := claims["iat"].(float64)
+
+	return RefreshClaims{
+		Subject:   sub,
+		AppID:     int64(appID),
+		SessionID: sid,
+		FamilyID:  fam,
+		ID:        jti,
+		IssuedAt:  unixTime(iat),
+		ExpiresAt: exp.Time,
+	}, nil
+}
+
+// IsJWT reports whether token has the shape of a JWT, telling refresh tokens
+// issued by NewRefreshToken apart from opaque ones.
+func IsJWT(token string) bool {
+	return strings.Count(token, ".") == 2
+}
@@ -0,0 +1,62 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/asn1"
+	"math/big"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// signerMethod signs with any crypto.Signer, such as one whose private key stays
+// in a KMS, where the standard methods only sign with in-memory keys of their
+// own types. Verification is left to the standard method of the algorithm.
+type signerMethod struct {
+	jwt.SigningMethod
+}
+
+func (m signerMethod) Sign(signingString string, key interface{}) ([]byte, error) {
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, jwt.ErrInvalidKeyType
+	}
+
+	if _, ok := signer.Public().(ed25519.PublicKey); ok {
+		return signer.Sign(rand.Reader, []byte(signingString), crypto.Hash(0))
+	}
+
+	digest := sha256.Sum256([]byte(signingString))
+
+	sig, err := signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+	if err != nil {
+		return nil, err
+	}
+
+	if public, ok := signer.Public().(*ecdsa.PublicKey); ok {
+		return rawECDSASignature(sig, public)
+	}
+
+	return sig, nil
+}
+
+// rawECDSASignature converts an ASN.1 ECDSA signature, which crypto.Signer
+// returns, to the fixed size r || s form JWS uses.
+func rawECDSASignature(sig []byte, public *ecdsa.PublicKey) ([]byte, error) {
+	var parsed struct {
+		R, S *big.Int
+	}
+	if _, err := asn1.Unmarshal(sig, &parsed); err != nil {
+		return nil, err
+	}
+
+	size := (public.Curve.Params().BitSize + 7) / 8
+	raw := make([]byte, 2*size)
+	parsed.R.FillBytes(raw[:size])
+	parsed.S.FillBytes(raw[size:])
+
+	return raw, nil
+}
@@ -0,0 +1,106 @@
+package jwt
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalidSubject = errors.New("invalid subject")
+
+// SubjectFormat renders account IDs as the sub claim and maps a sub claim back to
+// the account ID. Both directions must be stable across restarts, so tokens issued
+// before a restart still resolve.
+type SubjectFormat interface {
+	Subject(accountID int64) string
+	AccountID(sub string) (int64, error)
+}
+
+// RawSubject is the account ID in decimal, e.g. "42".
+type RawSubject struct{}
+
+func (RawSubject) Subject(accountID int64) string {
+	return strconv.FormatInt(accountID, 10)
+}
+
+func (RawSubject) AccountID(sub string) (int64, error) {
+	id, err := strconv.ParseInt(sub, 10, 64)
+	if err != nil || id <= 0 {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidSubject, sub)
+	}
+
+	return id, nil
+}
+
+// PrefixedSubject is the account ID in decimal after Prefix, e.g. "acct_42".
+type PrefixedSubject struct {
+	Prefix string
+}
+
+func (p PrefixedSubject) Subject(accountID int64) string {
+	return p.Prefix + strconv.FormatInt(accountID, 10)
+}
+
+func (p PrefixedSubject) AccountID(sub string) (int64, error) {
+	raw, ok := strings.CutPrefix(sub, p.Prefix)
+	if !ok {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidSubject, sub)
+	}
+
+	return RawSubject{}.AccountID(raw)
+}
+
+// maxUUIDAccountID is the largest ID that fits the 48 bits UUIDSubject carries.
+const maxUUIDAccountID = 1<<48 - 1
+
+// UUIDSubject encodes the account ID into an RFC 9562 version 8 UUID, e.g.
+// "00000000-0000-8000-8000-00000000002a". The mapping needs no lookup table: the
+// ID sits in the last 48 bits and everything else is fixed.
+type UUIDSubject struct{}
+
+func (UUIDSubject) Subject(accountID int64) string {
+	var u [16]byte
+	u[6] = 0x80 // version 8
+	u[8] = 0x80 // RFC 9562 variant
+
+	var id [8]byte
+	binary.BigEndian.PutUint64(id[:], uint64(accountID)&maxUUIDAccountID)
+	copy(u[10:], id[2:])
+
+	h := hex.EncodeToString(u[:])
+	return h[0:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:32]
+}
+
+func (UUIDSubject) AccountID(sub string) (int64, error) {
+	if len(sub) != 36 || sub[8] != '-' || sub[13] != '-' || sub[18] != '-' || sub[23] != '-' {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidSubject, sub)
+	}
+
+	u, err := hex.DecodeString(strings.ReplaceAll(sub, "-", ""))
+	if err != nil {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidSubject, sub)
+	}
+
+	for i, b := range u[:10] {
+		want := byte(0)
+		if i == 6 || i == 8 {
+			want = 0x80
+		}
+		if b != want {
+			return 0, fmt.Errorf("%w: %q", ErrInvalidSubject, sub)
+		}
+	}
+
+	var id [8]byte
+	copy(id[2:], u[10:])
+
+	accountID := int64(binary.BigEndian.Uint64(id[:]))
+	if accountID <= 0 {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidSubject, sub)
+	}
+
+	return accountID, nil
+}
@@ -0,0 +1,63 @@
+package jwt
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSubjectFormats(t *testing.T) {
+	tests := []struct {
+		name      string
+		format    SubjectFormat
+		accountID int64
+		subject   string
+	}{
+		{name: "raw", format: RawSubject{}, accountID: 42, subject: "42"},
+		{name: "prefixed", format: PrefixedSubject{Prefix: "acct_"}, accountID: 42, subject: "acct_42"},
+		{name: "uuid", format: UUIDSubject{}, accountID: 42, subject: "00000000-0000-8000-8000-00000000002a"},
+		{name: "uuid largest id", format: UUIDSubject{}, accountID: maxUUIDAccountID, subject: "00000000-0000-8000-8000-ffffffffffff"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.format.Subject(tt.accountID); got != tt.subject {
+				t.Errorf("Subject(%d) = %q, want %q", tt.accountID, got, tt.subject)
+			}
+
+			got, err := tt.format.AccountID(tt.subject)
+			if err != nil {
+				t.Fatalf("AccountID(%q): %v", tt.subject, err)
+			}
+			if got != tt.accountID {
+				t.Errorf("AccountID(%q) = %d, want %d", tt.subject, got, tt.accountID)
+			}
+		})
+	}
+}
+
+func TestSubjectFormatsRejectInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  SubjectFormat
+		subject string
+	}{
+		{name: "raw not a number", format: RawSubject{}, subject: "abc"},
+		{name: "raw zero", format: RawSubject{}, subject: "0"},
+		{name: "raw negative", format: RawSubject{}, subject: "-1"},
+		{name: "prefixed without prefix", format: PrefixedSubject{Prefix: "acct_"}, subject: "42"},
+		{name: "prefixed other prefix", format: PrefixedSubject{Prefix: "acct_"}, subject: "user_42"},
+		{name: "uuid raw id", format: UUIDSubject{}, subject: "42"},
+		{name: "uuid other version", format: UUIDSubject{}, subject: "00000000-0000-4000-8000-00000000002a"},
+		{name: "uuid random", format: UUIDSubject{}, subject: "9f1c2b3a-4d5e-8f60-8a1b-2c3d4e5f6a7b"},
+		{name: "uuid zero id", format: UUIDSubject{}, subject: "00000000-0000-8000-8000-000000000000"},
+		{name: "uuid not hex", format: UUIDSubject{}, subject: "00000000-0000-8000-8000-00000000002z"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.format.AccountID(tt.subject); !errors.Is(err, ErrInvalidSubject) {
+				t.Errorf("AccountID(%q) error = %v, want ErrInvalidSubject", tt.subject, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package keyprovider
+
+import (
+	"context"
+	"crypto"
+	"fmt"
+	"os"
+)
+
+// Env reads PEM encoded private keys from environment variables. A key's ref is
+// the name of its variable.
+type Env struct {
+	cache cache
+}
+
+func NewEnv() *Env {
+	return &Env{}
+}
+
+func (e *Env) Signer(ctx context.Context, ref string) (crypto.Signer, error) {
+	const op = "keyprovider.Env.Signer"
+
+	signer, err := e.cache.signer(ctx, ref, e.load)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return signer, nil
+}
+
+func (e *Env) load(_ context.Context, ref string) (crypto.Signer, error) {
+	value, ok := os.LookupEnv(ref)
+	if !ok || value == "" {
+		return nil, fmt.Errorf("environment variable %s is not set", ref)
+	}
+
+	signer, err := ParsePrivateKeyPEM([]byte(value))
+	if err != nil {
+		return nil, fmt.Errorf("environment variable %s: %w", ref, err)
+	}
+
+	return signer, nil
+}
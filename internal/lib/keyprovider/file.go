@@ -0,0 +1,49 @@
+package keyprovider
+
+import (
+	"context"
+	"crypto"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// File reads PEM encoded private keys from files under a directory, such as a
+// mounted secret volume. A key's ref is its path relative to the directory.
+type File struct {
+	dir   string
+	cache cache
+}
+
+func NewFile(dir string) *File {
+	return &File{dir: dir}
+}
+
+func (f *File) Signer(ctx context.Context, ref string) (crypto.Signer, error) {
+	const op = "keyprovider.File.Signer"
+
+	signer, err := f.cache.signer(ctx, ref, f.load)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return signer, nil
+}
+
+func (f *File) load(_ context.Context, ref string) (crypto.Signer, error) {
+	if !filepath.IsLocal(ref) {
+		return nil, fmt.Errorf("key file %q is outside %s", ref, f.dir)
+	}
+
+	data, err := os.ReadFile(filepath.Join(f.dir, ref))
+	if err != nil {
+		return nil, err
+	}
+
+	signer, err := ParsePrivateKeyPEM(data)
+	if err != nil {
+		return nil, fmt.Errorf("key file %s: %w", ref, err)
+	}
+
+	return signer, nil
+}
@@ -0,0 +1,168 @@
+package keyprovider
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// KMS signs with asymmetric AWS KMS keys, whose private keys never leave KMS: the
+// signer sends digests to KMS to be signed. A key's ref is its key ID, ARN or
+// alias. RSA and NIST P-256 keys are supported.
+type KMS struct {
+	endpoint    string
+	region      string
+	credentials Credentials
+	client      *http.Client
+	cache       cache
+}
+
+// Credentials authenticate requests to AWS. SessionToken is only set for
+// temporary credentials.
+type Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+// KMSEndpoint returns the KMS endpoint of region.
+func KMSEndpoint(region string) string {
+	return fmt.Sprintf("https://kms.%s.amazonaws.com", region)
+}
+
+// NewKMS signs with the keys of region's KMS at endpoint, see KMSEndpoint.
+func NewKMS(endpoint string, region string, credentials Credentials, timeout time.Duration) *KMS {
+	return &KMS{
+		endpoint:    strings.TrimSuffix(endpoint, "/"),
+		region:      region,
+		credentials: credentials,
+		client:      &http.Client{Timeout: timeout},
+	}
+}
+
+// Signer returns the signer of the KMS key ref, after fetching its public key.
+func (k *KMS) Signer(ctx context.Context, ref string) (crypto.Signer, error) {
+	const op = "keyprovider.KMS.Signer"
+
+	signer, err := k.cache.signer(ctx, ref, k.load)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return signer, nil
+}
+
+func (k *KMS) load(ctx context.Context, ref string) (crypto.Signer, error) {
+	var out struct {
+		PublicKey []byte `json:"PublicKey"`
+		KeyUsage  string `json:"KeyUsage"`
+	}
+	if err := k.call(ctx, "GetPublicKey", map[string]any{"KeyId": ref}, &out); err != nil {
+		return nil, err
+	}
+
+	if out.KeyUsage != "SIGN_VERIFY" {
+		return nil, fmt.Errorf("kms key %s is not a signing key", ref)
+	}
+
+	public, err := x509.ParsePKIXPublicKey(out.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("kms key %s: %w", ref, err)
+	}
+
+	var algorithm string
+	switch public.(type) {
+	case *rsa.PublicKey:
+		algorithm = "RSASSA_PKCS1_V1_5_SHA_256"
+	case *ecdsa.PublicKey:
+		algorithm = "ECDSA_SHA_256"
+	default:
+		return nil, fmt.Errorf("kms key %s: unsupported %T key", ref, public)
+	}
+
+	return &kmsSigner{kms: k, keyID: ref, public: public, algorithm: algorithm}, nil
+}
+
+// kmsSigner is a crypto.Signer whose signatures are made by KMS. ECDSA signatures
+// are ASN.1 encoded, like those of ecdsa.PrivateKey.
+type kmsSigner struct {
+	kms       *KMS
+	keyID     string
+	public    crypto.PublicKey
+	algorithm string
+}
+
+func (s *kmsSigner) Public() crypto.PublicKey {
+	return s.public
+}
+
+func (s *kmsSigner) Sign(_ io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	if opts.HashFunc() != crypto.SHA256 {
+		return nil, fmt.Errorf("kms key %s only signs SHA-256 digests", s.keyID)
+	}
+	if _, ok := opts.(*rsa.PSSOptions); ok {
+		return nil, errors.New("kms signer does not sign RSA-PSS")
+	}
+
+	in := map[string]any{
+		"KeyId":            s.keyID,
+		"Message":          digest,
+		"MessageType":      "DIGEST",
+		"SigningAlgorithm": s.algorithm,
+	}
+
+	var out struct {
+		Signature []byte `json:"Signature"`
+	}
+	// crypto.Signer takes no context; the client timeout bounds the call.
+	if err := s.kms.call(context.Background(), "Sign", in, &out); err != nil {
+		return nil, err
+	}
+
+	return out.Signature, nil
+}
+
+// call makes a KMS API request for action with the JSON body in, decoding the
+// response into out.
+func (k *KMS) call(ctx context.Context, action string, in any, out any) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, k.endpoint+"/", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "TrentService."+action)
+
+	signV4(req, body, "kms", k.region, k.credentials, time.Now())
+
+	resp, err := k.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var failure struct {
+			Type    string `json:"__type"`
+			Message string `json:"message"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&failure)
+		return fmt.Errorf("kms %s: unexpected status %d: %s %s", action, resp.StatusCode, failure.Type, failure.Message)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,98 @@
+package keyprovider
+
+import (
+	"context"
+	"crypto"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Vault reads PEM encoded private keys from secrets of HashiCorp Vault's KV
+// version 2 engine. A key's ref is the path of its secret within the engine's
+// mount, and the key is the secret's value under the configured field.
+type Vault struct {
+	addr      string
+	token     string
+	namespace string
+	mount     string
+	field     string
+	client    *http.Client
+	cache     cache
+}
+
+// NewVault reads keys from the KV engine mounted at mount of the Vault server at
+// addr, authenticating with token. namespace is the Vault Enterprise namespace,
+// empty for none.
+func NewVault(addr string, token string, namespace string, mount string, field string, timeout time.Duration) *Vault {
+	return &Vault{
+		addr:      strings.TrimSuffix(addr, "/"),
+		token:     token,
+		namespace: namespace,
+		mount:     strings.Trim(mount, "/"),
+		field:     field,
+		client:    &http.Client{Timeout: timeout},
+	}
+}
+
+func (v *Vault) Signer(ctx context.Context, ref string) (crypto.Signer, error) {
+	const op = "keyprovider.Vault.Signer"
+
+	signer, err := v.cache.signer(ctx, ref, v.load)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return signer, nil
+}
+
+func (v *Vault) load(ctx context.Context, ref string) (crypto.Signer, error) {
+	segments := strings.Split(strings.Trim(ref, "/"), "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	endpoint := fmt.Sprintf("%s/v1/%s/data/%s", v.addr, v.mount, strings.Join(segments, "/"))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Vault-Token", v.token)
+	if v.namespace != "" {
+		req.Header.Set("X-Vault-Namespace", v.namespace)
+	}
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("secret %s: unexpected status %d", ref, resp.StatusCode)
+	}
+
+	var secret struct {
+		Data struct {
+			Data map[string]any `json:"data"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&secret); err != nil {
+		return nil, fmt.Errorf("secret %s: %w", ref, err)
+	}
+
+	value, _ := secret.Data.Data[v.field].(string)
+	if value == "" {
+		return nil, fmt.Errorf("secret %s has no %s", ref, v.field)
+	}
+
+	signer, err := ParsePrivateKeyPEM([]byte(value))
+	if err != nil {
+		return nil, fmt.Errorf("secret %s: %w", ref, err)
+	}
+
+	return signer, nil
+}
@@ -0,0 +1,229 @@
+package lockout
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"sso/internal/domain/models"
+)
+
+// staleAfter drops keys with no failure for this long, so keys that never log in
+// successfully don't pile up.
+const staleAfter = 24 * time.Hour
+
+// Tier locks a key for Duration once it has accumulated Failures failed attempts.
+type Tier struct {
+	Failures int
+	Duration time.Duration
+}
+
+// Store keeps the lockout state of every key. MemoryStore keeps it per process;
+// a store backed by shared storage makes all instances enforce the same lockout.
+type Store interface {
+	LockoutState(ctx context.Context, key string) (models.LockoutState, bool, error)
+	SaveLockoutState(ctx context.Context, key string, state models.LockoutState) error
+	DeleteLockoutState(ctx context.Context, key string) error
+}
+
+// Tracker applies an escalating lockout schedule per key (login identifier, client
+// IP). Every failure at or past a tier's threshold locks the key for the highest
+// tier reached, so repeated failures lead to longer locks. With a window, failures
+// more than window apart start the count over. A successful login resets the key's
+// escalation after coolDown, unless it fails again in the meantime.
+//
+// Updates are serialized within the process only. With a shared store, concurrent
+// failures on different instances may lose an increment.
+type Tracker struct {
+	mu       sync.Mutex
+	tiers    []Tier
+	coolDown time.Duration
+	window   time.Duration
+	store    Store
+}
+
+// New returns a Tracker keeping its state in store, or in a new MemoryStore if
+// store is nil. A zero window counts failures until the key goes stale.
+func New(tiers []Tier, coolDown time.Duration, window time.Duration, store Store) *Tracker {
+	sorted := make([]Tier, len(tiers))
+	copy(sorted, tiers)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Failures < sorted[j].Failures })
+
+	if store == nil {
+		store = NewMemoryStore(coolDown)
+	}
+
+	return &Tracker{
+		tiers:    sorted,
+		coolDown: coolDown,
+		window:   window,
+		store:    store,
+	}
+}
+
+// Locked reports whether key is locked and for how much longer.
+func (t *Tracker) Locked(ctx context.Context, key string) (time.Duration, bool, error) {
+	now := time.Now()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	state, ok, err := t.state(ctx, key, now)
+	if err != nil || !ok || !now.Before(state.LockedUntil) {
+		return 0, false, err
+	}
+
+	return state.LockedUntil.Sub(now), true, nil
+}
+
+// Failure records a failed attempt for key and returns how long it is now locked
+// for, zero if it hasn't reached the first tier.
+func (t *Tracker) Failure(ctx context.Context, key string) (time.Duration, error) {
+	now := time.Now()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	state, _, err := t.state(ctx, key, now)
+	if err != nil {
+		return 0, err
+	}
+
+	if t.window > 0 && now.Sub(state.LastFailure) > t.window {
+		state.Failures = 0
+	}
+
+	state.Failures++
+	state.LastFailure = now
+	state.ResetAt = time.Time{}
+
+	var lock time.Duration
+	for _, tier := range t.tiers {
+		if state.Failures < tier.Failures {
+			break
+		}
+		lock = tier.Duration
+	}
+
+	if lock > 0 {
+		state.LockedUntil = now.Add(lock)
+	}
+
+	if err := t.store.SaveLockoutState(ctx, key, state); err != nil {
+		return 0, err
+	}
+
+	return lock, nil
+}
+
+// Success schedules the reset of key's escalation coolDown from now.
+func (t *Tracker) Success(ctx context.Context, key string) error {
+	now := time.Now()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	state, ok, err := t.state(ctx, key, now)
+	if err != nil || !ok || !state.ResetAt.IsZero() {
+		return err
+	}
+
+	state.ResetAt = now.Add(t.coolDown)
+
+	return t.store.SaveLockoutState(ctx, key, state)
+}
+
+// Unlock lifts any lock on key and forgets its failures.
+func (t *Tracker) Unlock(ctx context.Context, key string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.store.DeleteLockoutState(ctx, key)
+}
+
+// state returns the live state of key, dropping it if its reset is due.
+func (t *Tracker) state(ctx context.Context, key string, now time.Time) (models.LockoutState, bool, error) {
+	state, ok, err := t.store.LockoutState(ctx, key)
+	if err != nil || !ok {
+		return models.LockoutState{}, false, err
+	}
+
+	if expired(state, now) {
+		if err := t.store.DeleteLockoutState(ctx, key); err != nil {
+			return models.LockoutState{}, false, err
+		}
+		return models.LockoutState{}, false, nil
+	}
+
+	return state, true, nil
+}
+
+// expired reports whether state's reset is due or it went stale without a lock.
+func expired(state models.LockoutState, now time.Time) bool {
+	if !state.ResetAt.IsZero() && !now.Before(state.ResetAt) {
+		return true
+	}
+
+	return now.After(state.LockedUntil) && now.Sub(state.LastFailure) > staleAfter
+}
+
+// MemoryStore is a Store local to the process.
+type MemoryStore struct {
+	mu          sync.Mutex
+	states      map[string]models.LockoutState
+	sweepPeriod time.Duration
+	nextSweep   time.Time
+}
+
+// NewMemoryStore returns an empty MemoryStore that drops expired states at most
+// once per sweepPeriod.
+func NewMemoryStore(sweepPeriod time.Duration) *MemoryStore {
+	return &MemoryStore{
+		states:      make(map[string]models.LockoutState),
+		sweepPeriod: sweepPeriod,
+	}
+}
+
+func (m *MemoryStore) LockoutState(_ context.Context, key string) (models.LockoutState, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	state, ok := m.states[key]
+
+	return state, ok, nil
+}
+
+func (m *MemoryStore) SaveLockoutState(_ context.Context, key string, state models.LockoutState) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.sweep(time.Now())
+	m.states[key] = state
+
+	return nil
+}
+
+func (m *MemoryStore) DeleteLockoutState(_ context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.states, key)
+
+	return nil
+}
+
+// sweep drops expired states so keys that are never seen again don't pile up.
+func (m *MemoryStore) sweep(now time.Time) {
+	if now.Before(m.nextSweep) {
+		return
+	}
+
+	for key, state := range m.states {
+		if expired(state, now) {
+			delete(m.states, key)
+		}
+	}
+
+	m.nextSweep = now.Add(m.sweepPeriod)
+}
@@ -0,0 +1,143 @@
+package lockout
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"sso/internal/domain/models"
+)
+
+var testTiers = []Tier{
+	{Failures: 8, Duration: time.Hour},
+	{Failures: 3, Duration: time.Minute},
+	{Failures: 5, Duration: 10 * time.Minute},
+}
+
+func TestFailureEscalates(t *testing.T) {
+	tests := []struct {
+		failures int
+		wantLock time.Duration
+	}{
+		{failures: 1},
+		{failures: 2},
+		{failures: 3, wantLock: time.Minute},
+		{failures: 4, wantLock: time.Minute},
+		{failures: 5, wantLock: 10 * time.Minute},
+		{failures: 7, wantLock: 10 * time.Minute},
+		{failures: 8, wantLock: time.Hour},
+		{failures: 12, wantLock: time.Hour},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%d failures", tt.failures), func(t *testing.T) {
+			ctx := context.Background()
+			tracker := New(testTiers, time.Hour, 0, nil)
+
+			var lock time.Duration
+			for range tt.failures {
+				var err error
+				if lock, err = tracker.Failure(ctx, "user@example.com"); err != nil {
+					t.Fatalf("failure: %v", err)
+				}
+			}
+			if lock != tt.wantLock {
+				t.Errorf("lock after %d failures = %v, want %v", tt.failures, lock, tt.wantLock)
+			}
+
+			remaining, locked, err := tracker.Locked(ctx, "user@example.com")
+			if err != nil {
+				t.Fatalf("locked: %v", err)
+			}
+			if locked != (tt.wantLock > 0) || remaining > tt.wantLock {
+				t.Errorf("locked = %v for %v, want lock of %v", locked, remaining, tt.wantLock)
+			}
+
+			if _, locked, _ := tracker.Locked(ctx, "other@example.com"); locked {
+				t.Error("failures of one key locked another")
+			}
+		})
+	}
+}
+
+func TestResets(t *testing.T) {
+	tests := []struct {
+		name string
+		// reset acts on a key that just reached the first tier.
+		reset func(t *testing.T, tracker *Tracker, store *MemoryStore)
+		// wantLock is the lock from the next failure after reset.
+		wantLock time.Duration
+	}{
+		{
+			name: "unlock",
+			reset: func(t *testing.T, tracker *Tracker, _ *MemoryStore) {
+				if err := tracker.Unlock(context.Background(), "key"); err != nil {
+					t.Fatalf("unlock: %v", err)
+				}
+			},
+		},
+		{
+			name: "success past cool-down",
+			reset: func(t *testing.T, tracker *Tracker, store *MemoryStore) {
+				if err := tracker.Success(context.Background(), "key"); err != nil {
+					t.Fatalf("success: %v", err)
+				}
+				shiftState(t, store, func(s *models.LockoutState) { s.ResetAt = time.Now().Add(-time.Second) })
+			},
+		},
+		{
+			name: "success within cool-down",
+			reset: func(t *testing.T, tracker *Tracker, _ *MemoryStore) {
+				if err := tracker.Success(context.Background(), "key"); err != nil {
+					t.Fatalf("success: %v", err)
+				}
+			},
+			wantLock: time.Minute,
+		},
+		{
+			name: "failures outside the window",
+			reset: func(t *testing.T, _ *Tracker, store *MemoryStore) {
+				shiftState(t, store, func(s *models.LockoutState) { s.LastFailure = s.LastFailure.Add(-2 * time.Hour) })
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			store := NewMemoryStore(time.Hour)
+			tracker := New(testTiers, time.Hour, time.Hour, store)
+
+			for range 3 {
+				if _, err := tracker.Failure(ctx, "key"); err != nil {
+					t.Fatalf("failure: %v", err)
+				}
+			}
+
+			tt.reset(t, tracker, store)
+
+			lock, err := tracker.Failure(ctx, "key")
+			if err != nil {
+				t.Fatalf("failure: %v", err)
+			}
+			if lock != tt.wantLock {
+				t.Errorf("lock = %v, want %v", lock, tt.wantLock)
+			}
+		})
+	}
+}
+
+// shiftState edits the stored state of "key".
+func shiftState(t *testing.T, store *MemoryStore, edit func(*models.LockoutState)) {
+	t.Helper()
+
+	state, ok, err := store.LockoutState(context.Background(), "key")
+	if err != nil || !ok {
+		t.Fatalf("state: %v, %v", ok, err)
+	}
+	edit(&state)
+	if err := store.SaveLockoutState(context.Background(), "key", state); err != nil {
+		t.Fatalf("save state: %v", err)
+	}
+}
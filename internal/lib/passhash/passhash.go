@@ -0,0 +1,221 @@
+// Package passhash hashes passwords with bcrypt or argon2id and verifies hashes of
+// either kind, so accounts can move between algorithms one login at a time.
+package passhash
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	ErrMismatch      = errors.New("password does not match hash")
+	ErrUnknownFormat = errors.New("unrecognized password hash format")
+	ErrMalformedHash = errors.New("malformed password hash")
+	ErrPepperMissing = errors.New("hash is peppered but no pepper is configured")
+)
+
+// pepperPrefix marks hashes made by Peppered, ahead of the inner hash.
+const pepperPrefix = "$pepper$"
+
+// Hasher produces password hashes in one configured format.
+type Hasher interface {
+	// Hash returns a self-describing hash of password.
+	Hash(password string) ([]byte, error)
+	// NeedsRehash reports whether hash was made with another algorithm or other
+	// parameters than Hash would use now.
+	NeedsRehash(hash []byte) bool
+}
+
+// Compare checks password against a bcrypt or argon2id hash, either plain or made
+// by Peppered with pepper. A wrong password returns ErrMismatch.
+func Compare(hash []byte, password string, pepper []byte) error {
+	if inner, ok := strings.CutPrefix(string(hash), pepperPrefix); ok {
+		if len(pepper) == 0 {
+			return ErrPepperMissing
+		}
+		hash, password = []byte(inner), applyPepper(pepper, password)
+	}
+
+	switch {
+	case isArgon2id(hash):
+		return compareArgon2id(hash, password)
+	case isBcrypt(hash):
+		err := bcrypt.CompareHashAndPassword(hash, []byte(password))
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return ErrMismatch
+		}
+		return err
+	default:
+		return ErrUnknownFormat
+	}
+}
+
+// Peppered mixes a server-side secret into passwords before Hasher sees them, so a
+// leaked database alone is not enough to crack them. The password is replaced by
+// its HMAC-SHA256 under Pepper, which also keeps it within bcrypt's 72-byte limit.
+// The pepper can't be changed or dropped without resetting peppered passwords.
+type Peppered struct {
+	Hasher Hasher
+	Pepper []byte
+}
+
+func (p Peppered) Hash(password string) ([]byte, error) {
+	hash, err := p.Hasher.Hash(applyPepper(p.Pepper, password))
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]byte(pepperPrefix), hash...), nil
+}
+
+func (p Peppered) NeedsRehash(hash []byte) bool {
+	inner, ok := strings.CutPrefix(string(hash), pepperPrefix)
+	if !ok {
+		return true
+	}
+
+	return p.Hasher.NeedsRehash([]byte(inner))
+}
+
+func applyPepper(pepper []byte, password string) string {
+	mac := hmac.New(sha256.New, pepper)
+	mac.Write([]byte(password))
+
+	return base64.RawStdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Bcrypt hashes with bcrypt at Cost.
+type Bcrypt struct {
+	Cost int
+}
+
+func (b Bcrypt) Hash(password string) ([]byte, error) {
+	return bcrypt.GenerateFromPassword([]byte(password), b.cost())
+}
+
+func (b Bcrypt) NeedsRehash(hash []byte) bool {
+	if !isBcrypt(hash) {
+		return true
+	}
+
+	cost, err := bcrypt.Cost(hash)
+	return err != nil || cost != b.cost()
+}
+
+func (b Bcrypt) cost() int {
+	if b.Cost == 0 {
+		return bcrypt.DefaultCost
+	}
+
+	return b.Cost
+}
+
+// Argon2id hashes with argon2id. Memory is in KiB. Hashes are encoded in the PHC
+// string format, e.g. $argon2id$v=19$m=65536,t=3,p=4$<salt>$<key>.
+type Argon2id struct {
+	Time    uint32
+	Memory  uint32
+	Threads uint8
+	KeyLen  uint32
+	SaltLen uint32
+}
+
+// DefaultArgon2id follows the second recommended option of RFC 9106 for
+// memory-constrained environments.
+var DefaultArgon2id = Argon2id{Time: 3, Memory: 64 * 1024, Threads: 4, KeyLen: 32, SaltLen: 16}
+
+func (p Argon2id) Hash(password string) ([]byte, error) {
+	salt := make([]byte, p.SaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, p.Time, p.Memory, p.Threads, p.KeyLen)
+
+	return []byte(fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, p.Memory, p.Time, p.Threads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	)), nil
+}
+
+func (p Argon2id) NeedsRehash(hash []byte) bool {
+	if !isArgon2id(hash) {
+		return true
+	}
+
+	params, salt, key, err := parseArgon2id(hash)
+	if err != nil {
+		return true
+	}
+
+	return params.Time != p.Time || params.Memory != p.Memory || params.Threads != p.Threads ||
+		uint32(len(key)) != p.KeyLen || uint32(len(salt)) != p.SaltLen
+}
+
+func compareArgon2id(hash []byte, password string) error {
+	params, salt, key, err := parseArgon2id(hash)
+	if err != nil {
+		return err
+	}
+
+	other := argon2.IDKey([]byte(password), salt, params.Time, params.Memory, params.Threads, uint32(len(key)))
+	if subtle.ConstantTimeCompare(key, other) != 1 {
+		return ErrMismatch
+	}
+
+	return nil
+}
+
+func parseArgon2id(hash []byte) (Argon2id, []byte, []byte, error) {
+	// "", "argon2id", "v=19", "m=...,t=...,p=...", salt, key
+	parts := strings.Split(string(hash), "$")
+	if len(parts) != 6 {
+		return Argon2id{}, nil, nil, ErrMalformedHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return Argon2id{}, nil, nil, ErrMalformedHash
+	}
+	if version != argon2.Version {
+		return Argon2id{}, nil, nil, ErrUnknownFormat
+	}
+
+	var params Argon2id
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Time, &params.Threads); err != nil {
+		return Argon2id{}, nil, nil, ErrMalformedHash
+	}
+	if params.Time == 0 || params.Threads == 0 {
+		return Argon2id{}, nil, nil, ErrMalformedHash
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return Argon2id{}, nil, nil, ErrMalformedHash
+	}
+
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return Argon2id{}, nil, nil, ErrMalformedHash
+	}
+
+	return params, salt, key, nil
+}
+
+func isArgon2id(hash []byte) bool {
+	return strings.HasPrefix(string(hash), "$argon2id$")
+}
+
+func isBcrypt(hash []byte) bool {
+	return strings.HasPrefix(string(hash), "$2")
+}
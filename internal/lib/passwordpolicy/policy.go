@@ -0,0 +1,152 @@
+package passwordpolicy
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Rule names reported in violations.
+const (
+	RuleMinLength     = "min_length"
+	RuleMaxLength     = "max_length"
+	RuleRequireUpper  = "require_upper"
+	RuleRequireLower  = "require_lower"
+	RuleRequireDigit  = "require_digit"
+	RuleRequireSymbol = "require_symbol"
+	RuleBanned        = "banned"
+)
+
+// Rules are the requirements for a password. Lengths count characters, not bytes;
+// zero lengths are unbounded.
+type Rules struct {
+	MinLength     int
+	MaxLength     int
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+}
+
+// Violation is one rule a password broke, with a message fit to show the user.
+type Violation struct {
+	Rule    string
+	Message string
+}
+
+// Policy checks passwords against default rules, rules overridden per app and a
+// list of banned common passwords.
+type Policy struct {
+	defaults Rules
+	apps     map[int32]Rules
+	banned   map[string]struct{}
+}
+
+// New builds a policy. Apps replace defaults entirely for their app ID. Banned
+// passwords are matched case-insensitively.
+func New(defaults Rules, apps map[int32]Rules, banned []string) *Policy {
+	p := &Policy{
+		defaults: defaults,
+		apps:     apps,
+		banned:   make(map[string]struct{}, len(banned)),
+	}
+
+	for _, password := range banned {
+		p.banned[strings.ToLower(password)] = struct{}{}
+	}
+
+	return p
+}
+
+// LoadBanned reads one banned password per line, skipping blank lines and lines
+// starting with #.
+func LoadBanned(path string) ([]string, error) {
+	const op = "passwordpolicy.LoadBanned"
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer f.Close()
+
+	var passwords []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		passwords = append(passwords, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return passwords, nil
+}
+
+// Rules returns the rules in force for the app.
+func (p *Policy) Rules(appID int32) Rules {
+	if rules, ok := p.apps[appID]; ok {
+		return rules
+	}
+
+	return p.defaults
+}
+
+// Check returns every rule password breaks in the app, or nil if it meets them all.
+func (p *Policy) Check(appID int32, password string) []Violation {
+	rules := p.Rules(appID)
+
+	var violations []Violation
+
+	length := utf8.RuneCountInString(password)
+	if rules.MinLength > 0 && length < rules.MinLength {
+		violations = append(violations, Violation{
+			Rule:    RuleMinLength,
+			Message: fmt.Sprintf("must be at least %d characters long", rules.MinLength),
+		})
+	}
+	if rules.MaxLength > 0 && length > rules.MaxLength {
+		violations = append(violations, Violation{
+			Rule:    RuleMaxLength,
+			Message: fmt.Sprintf("must be at most %d characters long", rules.MaxLength),
+		})
+	}
+
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r):
+			symbol = true
+		}
+	}
+
+	if rules.RequireUpper && !upper {
+		violations = append(violations, Violation{Rule: RuleRequireUpper, Message: "must contain an uppercase letter"})
+	}
+	if rules.RequireLower && !lower {
+		violations = append(violations, Violation{Rule: RuleRequireLower, Message: "must contain a lowercase letter"})
+	}
+	if rules.RequireDigit && !digit {
+		violations = append(violations, Violation{Rule: RuleRequireDigit, Message: "must contain a digit"})
+	}
+	if rules.RequireSymbol && !symbol {
+		violations = append(violations, Violation{Rule: RuleRequireSymbol, Message: "must contain a symbol"})
+	}
+
+	if _, ok := p.banned[strings.ToLower(password)]; ok {
+		violations = append(violations, Violation{Rule: RuleBanned, Message: "is too common"})
+	}
+
+	return violations
+}
@@ -0,0 +1,94 @@
+package peerip
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"strings"
+
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+// forwardedForHeader lists the client and the proxies a call passed through,
+// each proxy appending the address it received the call from.
+const forwardedForHeader = "x-forwarded-for"
+
+type clientIPKey struct{}
+
+// NewContext returns a copy of ctx that carries ip as the client IP.
+func NewContext(ctx context.Context, ip string) context.Context {
+	return context.WithValue(ctx, clientIPKey{}, ip)
+}
+
+// FromContext returns the client IP set by NewContext, else the IP of the gRPC
+// peer, or "" if it's unknown.
+func FromContext(ctx context.Context) string {
+	if ip, ok := ctx.Value(clientIPKey{}).(string); ok {
+		return ip
+	}
+
+	return peerIP(ctx)
+}
+
+// ClientIP derives the client IP of a call. When the gRPC peer is one of the
+// trusted proxies, the x-forwarded-for header is walked from the right, skipping
+// trusted proxies, and the first other address is the client. Addresses forwarded
+// by untrusted peers are ignored, as anyone can set the header.
+func ClientIP(ctx context.Context, trusted []netip.Prefix) string {
+	ip := peerIP(ctx)
+	if !isTrusted(ip, trusted) {
+		return ip
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	var hops []string
+	for _, value := range md.Get(forwardedForHeader) {
+		hops = append(hops, strings.Split(value, ",")...)
+	}
+
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if _, err := netip.ParseAddr(hop); err != nil {
+			// Whatever is left of a malformed entry can't be trusted either.
+			break
+		}
+
+		ip = hop
+		if !isTrusted(ip, trusted) {
+			break
+		}
+	}
+
+	return ip
+}
+
+func peerIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+
+	return host
+}
+
+func isTrusted(ip string, trusted []netip.Prefix) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+
+	for _, prefix := range trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+
+	return false
+}
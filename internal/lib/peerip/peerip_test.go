@@ -0,0 +1,59 @@
+package peerip
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"testing"
+
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+func TestClientIP(t *testing.T) {
+	trusted := []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}
+
+	tests := []struct {
+		name         string
+		peer         string
+		forwardedFor []string
+		want         string
+	}{
+		{name: "direct client", peer: "198.51.100.1", want: "198.51.100.1"},
+		{name: "untrusted peer forging header", peer: "198.51.100.1", forwardedFor: []string{"203.0.113.9"}, want: "198.51.100.1"},
+		{name: "trusted proxy", peer: "10.0.0.2", forwardedFor: []string{"203.0.113.9"}, want: "203.0.113.9"},
+		{name: "trusted proxy without header", peer: "10.0.0.2", want: "10.0.0.2"},
+		{name: "proxy chain", peer: "10.0.0.2", forwardedFor: []string{"203.0.113.9, 10.0.0.3"}, want: "203.0.113.9"},
+		{name: "client spoofing before proxy", peer: "10.0.0.2", forwardedFor: []string{"192.0.2.1, 203.0.113.9"}, want: "203.0.113.9"},
+		{name: "repeated header", peer: "10.0.0.2", forwardedFor: []string{"203.0.113.9", "10.0.0.3"}, want: "203.0.113.9"},
+		{name: "malformed hop", peer: "10.0.0.2", forwardedFor: []string{"203.0.113.9, garbage"}, want: "10.0.0.2"},
+		{name: "all hops trusted", peer: "10.0.0.2", forwardedFor: []string{"10.0.0.4, 10.0.0.3"}, want: "10.0.0.4"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP(tt.peer), Port: 4242}})
+			md := metadata.MD{}
+			for _, value := range tt.forwardedFor {
+				md.Append(forwardedForHeader, value)
+			}
+			ctx = metadata.NewIncomingContext(ctx, md)
+
+			if got := ClientIP(ctx, trusted); got != tt.want {
+				t.Errorf("ClientIP = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFromContextPrefersDerivedIP(t *testing.T) {
+	ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP("10.0.0.2"), Port: 4242}})
+	if got := FromContext(ctx); got != "10.0.0.2" {
+		t.Errorf("FromContext = %q, want the peer IP", got)
+	}
+
+	ctx = NewContext(ctx, "203.0.113.9")
+	if got := FromContext(ctx); got != "203.0.113.9" {
+		t.Errorf("FromContext = %q, want the derived IP", got)
+	}
+}
@@ -0,0 +1,52 @@
+// Package phone normalizes phone numbers to E.164, the form accounts store them in
+// and SMS providers expect.
+package phone
+
+import (
+	"errors"
+	"strings"
+)
+
+var ErrInvalidNumber = errors.New("invalid phone number")
+
+const (
+	minDigits = 8
+	maxDigits = 15
+)
+
+// Normalize returns number in E.164 form: a + followed by the country code and the
+// subscriber number, 8 to 15 digits in all. Spaces, dashes, dots and parentheses
+// are dropped and an international 00 prefix is read as +. Numbers in national
+// format are rejected, since their country can't be told.
+func Normalize(number string) (string, error) {
+	number = strings.TrimSpace(number)
+
+	switch {
+	case strings.HasPrefix(number, "+"):
+		number = number[1:]
+	case strings.HasPrefix(number, "00"):
+		number = number[2:]
+	default:
+		return "", ErrInvalidNumber
+	}
+
+	var b strings.Builder
+	b.WriteByte('+')
+	for _, r := range number {
+		switch {
+		case r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case r == ' ' || r == '-' || r == '.' || r == '(' || r == ')':
+		default:
+			return "", ErrInvalidNumber
+		}
+	}
+
+	normalized := b.String()
+	digits := len(normalized) - 1
+	if digits < minDigits || digits > maxDigits || normalized[1] == '0' {
+		return "", ErrInvalidNumber
+	}
+
+	return normalized, nil
+}
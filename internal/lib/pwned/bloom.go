@@ -0,0 +1,127 @@
+package pwned
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+)
+
+var ErrBadBloomFile = errors.New("not a breached password bloom filter")
+
+// bloomMagic starts every bloom filter file, followed by the number of hash
+// functions as a big-endian uint32, the number of bits as a big-endian uint64, and
+// the bits.
+const bloomMagic = "PWB1"
+
+// Bloom is an offline set of breached passwords: a bloom filter over their SHA-1
+// digests. It never misses a breached password, but reports a clean one as
+// breached at the false positive rate it was sized for.
+type Bloom struct {
+	bits []byte
+	k    uint32
+}
+
+// NewBloom returns an empty filter sized for n passwords at false positive rate fp.
+func NewBloom(n int, fp float64) *Bloom {
+	m := math.Ceil(-float64(n) * math.Log(fp) / (math.Ln2 * math.Ln2))
+	k := math.Round(m / float64(n) * math.Ln2)
+
+	return &Bloom{
+		bits: make([]byte, (uint64(m)+7)/8),
+		k:    uint32(max(k, 1)),
+	}
+}
+
+// LoadBloom reads a filter written by WriteTo.
+func LoadBloom(path string) (*Bloom, error) {
+	const op = "pwned.LoadBloom"
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if len(data) < len(bloomMagic)+12 || string(data[:len(bloomMagic)]) != bloomMagic {
+		return nil, fmt.Errorf("%s: %w", op, ErrBadBloomFile)
+	}
+	data = data[len(bloomMagic):]
+
+	k := binary.BigEndian.Uint32(data)
+	m := binary.BigEndian.Uint64(data[4:])
+	bits := data[12:]
+	if k == 0 || m == 0 || uint64(len(bits)) != (m+7)/8 {
+		return nil, fmt.Errorf("%s: %w", op, ErrBadBloomFile)
+	}
+
+	return &Bloom{bits: bits, k: k}, nil
+}
+
+// AddHash adds a breached password by its hex-encoded SHA-1, the form HIBP
+// publishes them in.
+func (b *Bloom) AddHash(hash string) error {
+	var sum [20]byte
+	if hex.DecodedLen(len(hash)) != len(sum) {
+		return fmt.Errorf("pwned.Bloom.AddHash: bad hash %q", hash)
+	}
+	if _, err := hex.Decode(sum[:], []byte(hash)); err != nil {
+		return fmt.Errorf("pwned.Bloom.AddHash: %w", err)
+	}
+
+	for _, i := range b.positions(sum) {
+		b.bits[i/8] |= 1 << (i % 8)
+	}
+
+	return nil
+}
+
+func (b *Bloom) Breached(_ context.Context, password string) (bool, error) {
+	for _, i := range b.positions(digest(password)) {
+		if b.bits[i/8]&(1<<(i%8)) == 0 {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// WriteTo writes the filter in the format LoadBloom reads.
+func (b *Bloom) WriteTo(w io.Writer) (int64, error) {
+	bw := bufio.NewWriter(w)
+
+	header := make([]byte, 0, len(bloomMagic)+12)
+	header = append(header, bloomMagic...)
+	header = binary.BigEndian.AppendUint32(header, b.k)
+	header = binary.BigEndian.AppendUint64(header, uint64(len(b.bits))*8)
+
+	n, err := bw.Write(header)
+	if err != nil {
+		return int64(n), err
+	}
+	m, err := bw.Write(b.bits)
+	if err != nil {
+		return int64(n + m), err
+	}
+
+	return int64(n + m), bw.Flush()
+}
+
+// positions derives the filter's k bit positions from a SHA-1 digest by double
+// hashing; the digest is already uniform, so no further hashing is needed.
+func (b *Bloom) positions(sum [20]byte) []uint64 {
+	m := uint64(len(b.bits)) * 8
+	h1 := binary.BigEndian.Uint64(sum[:8])
+	h2 := binary.BigEndian.Uint64(sum[8:16]) | 1
+
+	positions := make([]uint64, b.k)
+	for i := range positions {
+		positions[i] = (h1 + uint64(i)*h2) % m
+	}
+
+	return positions
+}
@@ -0,0 +1,18 @@
+// Package pwned checks passwords against known data breaches, online through the
+// Have I Been Pwned range API or offline against a bloom filter of breached hashes.
+package pwned
+
+import (
+	"context"
+	"crypto/sha1"
+)
+
+// Checker reports whether a password appears in a known breach.
+type Checker interface {
+	Breached(ctx context.Context, password string) (bool, error)
+}
+
+// digest is the SHA-1 breached passwords are published under.
+func digest(password string) [sha1.Size]byte {
+	return sha1.Sum([]byte(password))
+}
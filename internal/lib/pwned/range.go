@@ -0,0 +1,142 @@
+package pwned
+
+import (
+	"bufio"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultRangeURL is the Have I Been Pwned passwords range API.
+const DefaultRangeURL = "https://api.pwnedpasswords.com/range/"
+
+// RangeClient queries a k-anonymity range API: only the first five hex characters
+// of the password's SHA-1 leave the process, and the match against the returned
+// suffixes happens locally. Responses are cached per prefix for the cache TTL.
+type RangeClient struct {
+	url       string
+	client    *http.Client
+	cacheTTL  time.Duration
+	cacheSize int
+
+	mu    sync.Mutex
+	cache map[string]rangeEntry
+}
+
+type rangeEntry struct {
+	suffixes map[string]struct{}
+	expires  time.Time
+}
+
+// NewRangeClient returns a client for the range API at url, to which the prefix is
+// appended. At most cacheSize prefixes are cached; zero disables the cache.
+func NewRangeClient(url string, timeout, cacheTTL time.Duration, cacheSize int) *RangeClient {
+	return &RangeClient{
+		url:       url,
+		client:    &http.Client{Timeout: timeout},
+		cacheTTL:  cacheTTL,
+		cacheSize: cacheSize,
+		cache:     make(map[string]rangeEntry),
+	}
+}
+
+func (c *RangeClient) Breached(ctx context.Context, password string) (bool, error) {
+	const op = "pwned.RangeClient.Breached"
+
+	sum := digest(password)
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	prefix, suffix := hash[:5], hash[5:]
+
+	suffixes, ok := c.cached(prefix)
+	if !ok {
+		var err error
+		suffixes, err = c.fetch(ctx, prefix)
+		if err != nil {
+			return false, fmt.Errorf("%s: %w", op, err)
+		}
+		c.store(prefix, suffixes)
+	}
+
+	_, breached := suffixes[suffix]
+
+	return breached, nil
+}
+
+func (c *RangeClient) fetch(ctx context.Context, prefix string) (map[string]struct{}, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url+prefix, nil)
+	if err != nil {
+		return nil, err
+	}
+	// Padding makes every response about the same size, so the prefix can't be
+	// guessed from the length of the answer on the wire.
+	req.Header.Set("Add-Padding", "true")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	// Lines are SUFFIX:COUNT; padding entries have a count of zero.
+	suffixes := make(map[string]struct{})
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		suffix, count, ok := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if !ok || count == "0" {
+			continue
+		}
+		suffixes[strings.ToUpper(suffix)] = struct{}{}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return suffixes, nil
+}
+
+func (c *RangeClient) cached(prefix string) (map[string]struct{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.cache[prefix]
+	if !ok || time.Now().After(entry.expires) {
+		return nil, false
+	}
+
+	return entry.suffixes, true
+}
+
+func (c *RangeClient) store(prefix string, suffixes map[string]struct{}) {
+	if c.cacheSize <= 0 || c.cacheTTL <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if len(c.cache) >= c.cacheSize {
+		for p, entry := range c.cache {
+			if now.After(entry.expires) {
+				delete(c.cache, p)
+			}
+		}
+	}
+	// Still full: drop an arbitrary entry.
+	for p := range c.cache {
+		if len(c.cache) < c.cacheSize {
+			break
+		}
+		delete(c.cache, p)
+	}
+
+	c.cache[prefix] = rangeEntry{suffixes: suffixes, expires: now.Add(c.cacheTTL)}
+}
@@ -0,0 +1,88 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// Limiter is a fixed-window request counter keyed by an arbitrary string
+// (client IP, account ID, etc.).
+type Limiter struct {
+	mu        sync.Mutex
+	limit     int
+	window    time.Duration
+	buckets   map[string]*bucket
+	nextSweep time.Time
+}
+
+type bucket struct {
+	count   int
+	resetAt time.Time
+}
+
+// Result describes the window state after a call to Allow.
+type Result struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	RetryAfter time.Duration
+	ResetAt    time.Time
+}
+
+func New(limit int, window time.Duration) *Limiter {
+	return &Limiter{
+		limit:   limit,
+		window:  window,
+		buckets: make(map[string]*bucket),
+	}
+}
+
+// Allow records a hit for key and reports whether it fits into the current window.
+func (l *Limiter) Allow(key string) Result {
+	now := time.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok || !now.Before(b.resetAt) {
+		b = &bucket{resetAt: now.Add(l.window)}
+		l.buckets[key] = b
+	}
+
+	if b.count >= l.limit {
+		return Result{
+			Allowed:    false,
+			Limit:      l.limit,
+			Remaining:  0,
+			RetryAfter: b.resetAt.Sub(now),
+			ResetAt:    b.resetAt,
+		}
+	}
+
+	b.count++
+
+	return Result{
+		Allowed:   true,
+		Limit:     l.limit,
+		Remaining: l.limit - b.count,
+		ResetAt:   b.resetAt,
+	}
+}
+
+// sweep drops expired buckets at most once per window so the map doesn't grow unbounded.
+func (l *Limiter) sweep(now time.Time) {
+	if now.Before(l.nextSweep) {
+		return
+	}
+
+	for key, b := range l.buckets {
+		if !now.Before(b.resetAt) {
+			delete(l.buckets, key)
+		}
+	}
+
+	l.nextSweep = now.Add(l.window)
+}
@@ -0,0 +1,102 @@
+package sessionid
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// Generator makes session IDs. Implementations must be safe for concurrent use and
+// never return the same ID twice.
+type Generator interface {
+	NewID() string
+}
+
+// crockford is the ULID alphabet: Crockford's base32, which sorts like the values.
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// ULID generates 26-character ULIDs: a 48-bit millisecond timestamp followed by 80
+// random bits. IDs from one generator are strictly increasing, also within the same
+// millisecond, so they sort by creation order.
+type ULID struct {
+	mu      sync.Mutex
+	lastMS  uint64
+	lastRnd [10]byte
+}
+
+func NewULID() *ULID {
+	return &ULID{}
+}
+
+func (g *ULID) NewID() string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	ms := uint64(time.Now().UnixMilli())
+	if ms <= g.lastMS {
+		// Same (or a skewed earlier) millisecond: keep the last timestamp and bump
+		// the random part so the ID still sorts after the previous one.
+		ms = g.lastMS
+		if !increment(&g.lastRnd) {
+			ms++
+			g.fillRandom()
+		}
+	} else {
+		g.fillRandom()
+	}
+	g.lastMS = ms
+
+	var b [16]byte
+	binary.BigEndian.PutUint16(b[0:2], uint16(ms>>32))
+	binary.BigEndian.PutUint32(b[2:6], uint32(ms))
+	copy(b[6:], g.lastRnd[:])
+
+	return encode(b)
+}
+
+func (g *ULID) fillRandom() {
+	if _, err := rand.Read(g.lastRnd[:]); err != nil {
+		panic(err)
+	}
+}
+
+// increment adds one to the big-endian number in b and reports false on overflow.
+func increment(b *[10]byte) bool {
+	for i := len(b) - 1; i >= 0; i-- {
+		b[i]++
+		if b[i] != 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// encode writes the 128 bits of b as 26 base32 characters, most significant first.
+func encode(b [16]byte) string {
+	hi := binary.BigEndian.Uint64(b[0:8])
+	lo := binary.BigEndian.Uint64(b[8:16])
+
+	var out [26]byte
+	for i := 25; i >= 0; i-- {
+		out[i] = crockford[lo&0x1f]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+
+	return string(out[:])
+}
+
+// Random generates opaque 32-character hex IDs with no ordering.
+type Random struct{}
+
+func (Random) NewID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err)
+	}
+
+	return hex.EncodeToString(b[:])
+}
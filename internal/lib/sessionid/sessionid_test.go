@@ -0,0 +1,76 @@
+package sessionid
+
+import (
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestGenerators(t *testing.T) {
+	tests := []struct {
+		name     string
+		gen      Generator
+		length   int
+		alphabet string
+		sorted   bool
+	}{
+		{name: "ulid", gen: NewULID(), length: 26, alphabet: crockford, sorted: true},
+		{name: "random", gen: Random{}, length: 32, alphabet: "0123456789abcdef"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			const n = 10000
+
+			ids := make([]string, n)
+			seen := make(map[string]bool, n)
+			for i := range ids {
+				id := tt.gen.NewID()
+				if len(id) != tt.length {
+					t.Fatalf("id %q has length %d, want %d", id, len(id), tt.length)
+				}
+				if strings.Trim(id, tt.alphabet) != "" {
+					t.Fatalf("id %q has characters outside %q", id, tt.alphabet)
+				}
+				if seen[id] {
+					t.Fatalf("id %q generated twice", id)
+				}
+				seen[id] = true
+				ids[i] = id
+			}
+
+			if tt.sorted && !sort.StringsAreSorted(ids) {
+				t.Error("ids don't sort in creation order")
+			}
+		})
+	}
+}
+
+func TestIncrement(t *testing.T) {
+	tests := []struct {
+		name   string
+		in     [10]byte
+		want   [10]byte
+		wantOK bool
+	}{
+		{name: "zero", in: [10]byte{}, want: [10]byte{9: 1}, wantOK: true},
+		{name: "carry", in: [10]byte{8: 1, 9: 0xff}, want: [10]byte{8: 2}, wantOK: true},
+		{
+			name: "overflow",
+			in:   [10]byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+			want: [10]byte{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := tt.in
+			if ok := increment(&b); ok != tt.wantOK {
+				t.Errorf("increment reported %v, want %v", ok, tt.wantOK)
+			}
+			if b != tt.want {
+				t.Errorf("increment = %x, want %x", b, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package sms
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// TwilioURL is the base URL of the Twilio REST API.
+const TwilioURL = "https://api.twilio.com"
+
+// Twilio sends messages with Twilio's Programmable Messaging API.
+type Twilio struct {
+	baseURL    string
+	accountSID string
+	authToken  string
+	from       string
+	client     *http.Client
+}
+
+// NewTwilio sends messages from the number or messaging service SID from on behalf
+// of the Twilio account accountSID.
+func NewTwilio(accountSID string, authToken string, from string, timeout time.Duration) *Twilio {
+	return &Twilio{
+		baseURL:    TwilioURL,
+		accountSID: accountSID,
+		authToken:  authToken,
+		from:       from,
+		client:     &http.Client{Timeout: timeout},
+	}
+}
+
+func (t *Twilio) SendSMS(ctx context.Context, to string, body string) error {
+	const op = "sms.Twilio.SendSMS"
+
+	form := url.Values{
+		"To":   {to},
+		"Body": {body},
+	}
+	if strings.HasPrefix(t.from, "MG") {
+		form.Set("MessagingServiceSid", t.from)
+	} else {
+		form.Set("From", t.from)
+	}
+
+	endpoint := fmt.Sprintf("%s/2010-04-01/Accounts/%s/Messages.json", t.baseURL, url.PathEscape(t.accountSID))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(t.accountSID, t.authToken)
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("%s: unexpected status %d", op, resp.StatusCode)
+	}
+
+	return nil
+}
@@ -0,0 +1,88 @@
+package tarpit
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Rules flags login attempts that look like credential stuffing or probing: known
+// breached identifier/password pairs and user agents of known attack tools.
+type Rules struct {
+	breachedPairs map[string]struct{}
+	userAgents    []string
+}
+
+// New builds rules from the SHA-256 hex digests of breached pairs (see PairHash) and
+// user agent substrings, matched case-insensitively.
+func New(breachedPairs []string, userAgents []string) *Rules {
+	r := &Rules{
+		breachedPairs: make(map[string]struct{}, len(breachedPairs)),
+	}
+
+	for _, h := range breachedPairs {
+		r.breachedPairs[strings.ToLower(h)] = struct{}{}
+	}
+	for _, ua := range userAgents {
+		if ua != "" {
+			r.userAgents = append(r.userAgents, strings.ToLower(ua))
+		}
+	}
+
+	return r
+}
+
+// LoadBreachedPairs reads one PairHash digest per line, skipping blank lines and
+// lines starting with #.
+func LoadBreachedPairs(path string) ([]string, error) {
+	const op = "tarpit.LoadBreachedPairs"
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer f.Close()
+
+	var hashes []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		hashes = append(hashes, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return hashes, nil
+}
+
+// PairHash is the digest breached pairs are listed by: SHA-256 of the normalized
+// identifier, a NUL byte and the password, hex-encoded.
+func PairHash(identifier, password string) string {
+	sum := sha256.Sum256([]byte(identifier + "\x00" + password))
+	return hex.EncodeToString(sum[:])
+}
+
+// Flag reports whether the attempt matches a rule and which one.
+func (r *Rules) Flag(identifier, password, userAgent string) (string, bool) {
+	if len(r.breachedPairs) > 0 {
+		if _, ok := r.breachedPairs[PairHash(identifier, password)]; ok {
+			return "breached_credentials", true
+		}
+	}
+
+	ua := strings.ToLower(userAgent)
+	for _, pattern := range r.userAgents {
+		if strings.Contains(ua, pattern) {
+			return "probing_user_agent", true
+		}
+	}
+
+	return "", false
+}
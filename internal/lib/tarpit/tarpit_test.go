@@ -0,0 +1,62 @@
+package tarpit
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestFlag(t *testing.T) {
+	rules := New(
+		[]string{strings.ToUpper(PairHash("victim@example.com", "hunter2"))},
+		[]string{"", "sqlmap", "Hydra"},
+	)
+
+	tests := []struct {
+		name       string
+		identifier string
+		password   string
+		userAgent  string
+		wantReason string
+	}{
+		{name: "breached pair", identifier: "victim@example.com", password: "hunter2", userAgent: "Mozilla/5.0", wantReason: "breached_credentials"},
+		{name: "breached identifier, other password", identifier: "victim@example.com", password: "correct horse", userAgent: "Mozilla/5.0"},
+		{name: "attack tool", identifier: "user@example.com", password: "secret", userAgent: "sqlmap/1.7", wantReason: "probing_user_agent"},
+		{name: "attack tool in another case", identifier: "user@example.com", password: "secret", userAgent: "hydra v9.5", wantReason: "probing_user_agent"},
+		{name: "regular login", identifier: "user@example.com", password: "secret", userAgent: "Mozilla/5.0"},
+		{name: "empty user agent", identifier: "user@example.com", password: "secret"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reason, flagged := rules.Flag(tt.identifier, tt.password, tt.userAgent)
+			if flagged != (tt.wantReason != "") || reason != tt.wantReason {
+				t.Errorf("Flag() = %q, %v, want %q, %v", reason, flagged, tt.wantReason, tt.wantReason != "")
+			}
+		})
+	}
+}
+
+func TestLoadBreachedPairs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "breached.txt")
+	content := "# breached pairs\n\n" + PairHash("a@example.com", "1") + "\n  " + PairHash("b@example.com", "2") + "  \n"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+
+	got, err := LoadBreachedPairs(path)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	want := []string{PairHash("a@example.com", "1"), PairHash("b@example.com", "2")}
+	if !slices.Equal(got, want) {
+		t.Errorf("LoadBreachedPairs() = %v, want %v", got, want)
+	}
+
+	if _, err := LoadBreachedPairs(filepath.Join(t.TempDir(), "missing.txt")); err == nil {
+		t.Error("loading a missing file succeeded")
+	}
+}
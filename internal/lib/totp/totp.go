@@ -0,0 +1,75 @@
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"time"
+)
+
+const (
+	// SecretSize is the number of random bytes in a secret, as recommended by RFC 4226.
+	SecretSize = 20
+	// Digits is the length of the codes authenticator apps show.
+	Digits = 6
+	// Period is how long each code is valid.
+	Period = 30 * time.Second
+	// skew is how many periods before and after now are accepted, to absorb clock drift.
+	skew = 1
+)
+
+// NewSecret returns a random secret for a new enrollment.
+func NewSecret() ([]byte, error) {
+	secret := make([]byte, SecretSize)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+
+	return secret, nil
+}
+
+// Encode returns the secret in the unpadded base32 form authenticator apps expect.
+func Encode(secret []byte) string {
+	return base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(secret)
+}
+
+// Validate reports whether code matches secret at now, allowing one period of drift
+// in either direction.
+func Validate(secret []byte, code string, now time.Time) bool {
+	if len(code) != Digits {
+		return false
+	}
+
+	counter := now.Unix() / int64(Period.Seconds())
+	for i := int64(-skew); i <= skew; i++ {
+		if subtle.ConstantTimeCompare([]byte(hotp(secret, uint64(counter+i))), []byte(code)) == 1 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Code returns the code an authenticator app shows for secret at now.
+func Code(secret []byte, now time.Time) string {
+	return hotp(secret, uint64(now.Unix()/int64(Period.Seconds())))
+}
+
+// hotp is the RFC 4226 HMAC-SHA1 one-time password for counter.
+func hotp(secret []byte, counter uint64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], counter)
+
+	mac := hmac.New(sha1.New, secret)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	return fmt.Sprintf("%0*d", Digits, value%1_000_000)
+}
@@ -0,0 +1,93 @@
+// Package useragent extracts the operating system and browser from User-Agent
+// strings and fingerprints the device they describe.
+package useragent
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"regexp"
+	"strings"
+)
+
+// Info is what a user agent says about the device it was sent from. Fields are
+// empty when the user agent doesn't tell.
+type Info struct {
+	OS      string
+	Browser string
+}
+
+type pattern struct {
+	token string
+	name  string
+}
+
+// Patterns are tried in order; the first token found names the OS or browser.
+// Browsers built on another engine mention that engine too, so they come first.
+var (
+	osPatterns = []pattern{
+		{"Windows Phone", "Windows Phone"},
+		{"Windows", "Windows"},
+		{"iPhone", "iOS"},
+		{"iPad", "iPadOS"},
+		{"iPod", "iOS"},
+		{"Android", "Android"},
+		{"CrOS", "ChromeOS"},
+		{"Macintosh", "macOS"},
+		{"Mac OS X", "macOS"},
+		{"Ubuntu", "Ubuntu"},
+		{"Linux", "Linux"},
+	}
+	browserPatterns = []pattern{
+		{"Edg/", "Edge"},
+		{"EdgA/", "Edge"},
+		{"EdgiOS/", "Edge"},
+		{"Edge/", "Edge"},
+		{"OPR/", "Opera"},
+		{"Opera", "Opera"},
+		{"YaBrowser/", "Yandex Browser"},
+		{"SamsungBrowser/", "Samsung Internet"},
+		{"Firefox/", "Firefox"},
+		{"FxiOS/", "Firefox"},
+		{"CriOS/", "Chrome"},
+		{"Chrome/", "Chrome"},
+		{"Safari/", "Safari"},
+	}
+)
+
+// Parse reads the OS and browser from userAgent. A user agent that isn't a known
+// browser, such as an HTTP or gRPC library, is reported by its first product name,
+// e.g. grpc-go.
+func Parse(userAgent string) Info {
+	var info Info
+
+	for _, p := range osPatterns {
+		if strings.Contains(userAgent, p.token) {
+			info.OS = p.name
+			break
+		}
+	}
+
+	for _, p := range browserPatterns {
+		if strings.Contains(userAgent, p.token) {
+			info.Browser = p.name
+			break
+		}
+	}
+
+	if info.Browser == "" {
+		product, _, _ := strings.Cut(strings.TrimSpace(userAgent), " ")
+		info.Browser, _, _ = strings.Cut(product, "/")
+	}
+
+	return info
+}
+
+var versions = regexp.MustCompile(`\d+([._]\d+)*`)
+
+// Fingerprint identifies the device userAgent came from. Version numbers are left
+// out, so a browser or OS update doesn't make a device look new; two devices with
+// the same make, OS and browser can't be told apart.
+func Fingerprint(userAgent string) string {
+	sum := sha256.Sum256([]byte(versions.ReplaceAllString(userAgent, "")))
+	return hex.EncodeToString(sum[:8])
+}
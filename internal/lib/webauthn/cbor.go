@@ -0,0 +1,174 @@
+package webauthn
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+var errMalformedCBOR = errors.New("malformed cbor")
+
+// maxCBORDepth bounds nesting so a hostile payload can't exhaust the stack.
+const maxCBORDepth = 16
+
+// decodeCBOR decodes the first CBOR item in data and returns it along with the
+// number of bytes it took. It covers what attestation objects and COSE keys use:
+// integers come back as int64, byte strings as []byte, text as string, arrays as
+// []any and maps as map[any]any. Tags are dropped and floats are not supported.
+func decodeCBOR(data []byte) (any, int, error) {
+	d := cborDecoder{data: data}
+
+	v, err := d.item(0)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return v, d.pos, nil
+}
+
+type cborDecoder struct {
+	data []byte
+	pos  int
+}
+
+func (d *cborDecoder) item(depth int) (any, error) {
+	if depth > maxCBORDepth {
+		return nil, fmt.Errorf("%w: nested too deep", errMalformedCBOR)
+	}
+
+	major, arg, err := d.head()
+	if err != nil {
+		return nil, err
+	}
+
+	switch major {
+	case 0:
+		if arg > math.MaxInt64 {
+			return nil, fmt.Errorf("%w: integer overflow", errMalformedCBOR)
+		}
+		return int64(arg), nil
+	case 1:
+		if arg > math.MaxInt64 {
+			return nil, fmt.Errorf("%w: integer overflow", errMalformedCBOR)
+		}
+		return -1 - int64(arg), nil
+	case 2:
+		return d.bytes(arg)
+	case 3:
+		b, err := d.bytes(arg)
+		if err != nil {
+			return nil, err
+		}
+		return string(b), nil
+	case 4:
+		if arg > uint64(len(d.data)-d.pos) {
+			return nil, fmt.Errorf("%w: array too long", errMalformedCBOR)
+		}
+		items := make([]any, 0, arg)
+		for i := uint64(0); i < arg; i++ {
+			v, err := d.item(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, v)
+		}
+		return items, nil
+	case 5:
+		if arg > uint64(len(d.data)-d.pos) {
+			return nil, fmt.Errorf("%w: map too long", errMalformedCBOR)
+		}
+		m := make(map[any]any, arg)
+		for i := uint64(0); i < arg; i++ {
+			k, err := d.item(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			switch k.(type) {
+			case int64, string:
+			default:
+				return nil, fmt.Errorf("%w: unsupported map key", errMalformedCBOR)
+			}
+			v, err := d.item(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			m[k] = v
+		}
+		return m, nil
+	case 6:
+		return d.item(depth + 1)
+	default:
+		switch arg {
+		case 20:
+			return false, nil
+		case 21:
+			return true, nil
+		case 22, 23:
+			return nil, nil
+		}
+		return nil, fmt.Errorf("%w: unsupported simple value %d", errMalformedCBOR, arg)
+	}
+}
+
+// head reads an item's initial byte and argument. Indefinite lengths are rejected.
+func (d *cborDecoder) head() (byte, uint64, error) {
+	if d.pos >= len(d.data) {
+		return 0, 0, fmt.Errorf("%w: unexpected end", errMalformedCBOR)
+	}
+
+	b := d.data[d.pos]
+	d.pos++
+
+	major, info := b>>5, b&0x1f
+	if major == 7 && info > 24 {
+		return 0, 0, fmt.Errorf("%w: floats are not supported", errMalformedCBOR)
+	}
+
+	var size int
+	switch {
+	case info < 24:
+		return major, uint64(info), nil
+	case info == 24:
+		size = 1
+	case info == 25:
+		size = 2
+	case info == 26:
+		size = 4
+	case info == 27:
+		size = 8
+	default:
+		return 0, 0, fmt.Errorf("%w: indefinite or reserved length", errMalformedCBOR)
+	}
+
+	if len(d.data)-d.pos < size {
+		return 0, 0, fmt.Errorf("%w: unexpected end", errMalformedCBOR)
+	}
+
+	var arg uint64
+	switch size {
+	case 1:
+		arg = uint64(d.data[d.pos])
+	case 2:
+		arg = uint64(binary.BigEndian.Uint16(d.data[d.pos:]))
+	case 4:
+		arg = uint64(binary.BigEndian.Uint32(d.data[d.pos:]))
+	case 8:
+		arg = binary.BigEndian.Uint64(d.data[d.pos:])
+	}
+	d.pos += size
+
+	return major, arg, nil
+}
+
+func (d *cborDecoder) bytes(n uint64) ([]byte, error) {
+	if n > uint64(len(d.data)-d.pos) {
+		return nil, fmt.Errorf("%w: unexpected end", errMalformedCBOR)
+	}
+
+	b := make([]byte, n)
+	copy(b, d.data[d.pos:])
+	d.pos += int(n)
+
+	return b, nil
+}
@@ -0,0 +1,191 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sso/internal/domain/models"
+	"sso/internal/lib/jwt"
+	"sso/internal/lib/logger/sl"
+	"time"
+)
+
+// requireAdmin returns ErrPermissionDenied unless actorID belongs to an admin account.
+func (a *Auth) requireAdmin(ctx context.Context, actorID int64) error {
+	isAdmin, err := a.accountProvider.IsAdmin(ctx, actorID)
+	if err != nil {
+		return err
+	}
+
+	if !isAdmin {
+		return ErrPermissionDenied
+	}
+
+	return nil
+}
+
+// UnlockAccount lifts a login lockout on the account before it runs out and forgets
+// its failed attempts. Lockouts of client IPs are left alone. Admin only.
+func (a *Auth) UnlockAccount(ctx context.Context, actorID int64, accountID int64) error {
+	const op = "Auth.UnlockAccount"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int64("actor_id", actorID),
+		slog.Int64("account_id", accountID),
+	)
+
+	if err := a.requireAdmin(ctx, actorID); err != nil {
+		log.Warn("admin check failed", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	account, err := a.accountProvider.AccountById(ctx, accountID)
+	if err != nil {
+		log.Error("failed to get account", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if a.lockout == nil {
+		return nil
+	}
+
+	for _, identifier := range a.loginIdentifiers(account) {
+		if err := a.lockout.Unlock(ctx, identifier); err != nil {
+			log.Error("failed to unlock account", sl.Err(err))
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	log.Info("account unlocked")
+
+	return nil
+}
+
+// AccountStats returns account counts grouped by status and by role. Admin only.
+func (a *Auth) AccountStats(ctx context.Context, actorID int64) (models.AccountStats, error) {
+	const op = "Auth.AccountStats"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int64("actor_id", actorID),
+	)
+
+	if err := a.requireAdmin(ctx, actorID); err != nil {
+		log.Warn("admin check failed", sl.Err(err))
+		return models.AccountStats{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	stats, err := a.accountProvider.AccountStats(ctx)
+	if err != nil {
+		log.Error("failed to get account stats", sl.Err(err))
+		return models.AccountStats{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return stats, nil
+}
+
+// SessionsForAccounts returns the active sessions of several accounts at once,
+// keyed by account ID. Admin only.
+func (a *Auth) SessionsForAccounts(ctx context.Context, actorID int64, accountIDs []int64) (map[int64][]models.Session, error) {
+	const op = "Auth.SessionsForAccounts"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int64("actor_id", actorID),
+		slog.Int("accounts", len(accountIDs)),
+	)
+
+	if err := a.requireAdmin(ctx, actorID); err != nil {
+		log.Warn("admin check failed", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	sessions, err := a.sessionProvider.SessionsForAccounts(ctx, accountIDs)
+	if err != nil {
+		log.Error("failed to get sessions", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return sessions, nil
+}
+
+// RevokeAllAppSessions kills every token issued for the app, e.g. after its secret
+// leaked. The app's token version is bumped first, so tokens outside any session
+// stop validating too, then all of its active sessions are revoked. It returns the
+// number of revoked sessions. Admin only.
+func (a *Auth) RevokeAllAppSessions(ctx context.Context, actorID int64, appID int32) (int64, error) {
+	const op = "Auth.RevokeAllAppSessions"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int64("actor_id", actorID),
+		slog.Int64("app_id", int64(appID)),
+	)
+
+	if err := a.requireAdmin(ctx, actorID); err != nil {
+		log.Warn("admin check failed", sl.Err(err))
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.appSaver.IncrementAppTokenVersion(ctx, appID); err != nil {
+		log.Error("failed to bump app token version", sl.Err(err))
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	revoked, err := a.sessionSaver.RevokeAppSessions(ctx, appID, models.RevokedAppRevoked)
+	if err != nil {
+		log.Error("failed to revoke app sessions", sl.Err(err))
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("app sessions revoked", slog.Int64("count", revoked))
+
+	return revoked, nil
+}
+
+// PreviewTokenClaims returns the claims an access token for accountID in appID would
+// carry right now, for debugging and admin UIs, including those of the claims
+// enricher. Nothing is issued or stored; exp and auth_time are computed as if the
+// token were minted at the time of the call. Admin only.
+func (a *Auth) PreviewTokenClaims(ctx context.Context, actorID int64, accountID int64, appID int32) (map[string]any, error) {
+	const op = "Auth.PreviewTokenClaims"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int64("actor_id", actorID),
+		slog.Int64("account_id", accountID),
+		slog.Int64("app_id", int64(appID)),
+	)
+
+	if err := a.requireAdmin(ctx, actorID); err != nil {
+		log.Warn("admin check failed", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	account, err := a.accountProvider.AccountById(ctx, accountID)
+	if err != nil {
+		log.Error("failed to get account", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	app, err := a.appForLogin(ctx, appID)
+	if err != nil {
+		log.Warn("invalid app", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	account, err = a.accountForApp(ctx, account, appID)
+	if err != nil {
+		log.Warn("failed to resolve app role", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	claims, err := jwt.BuildClaims(ctx, account, app, a.tokenTTL, time.Now(), a.subjectFormat, a.tokenIssuer, "", a.claimsEnricher)
+	if err != nil {
+		log.Error("failed to build claims", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return claims, nil
+}
@@ -0,0 +1,252 @@
+package auth
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"sso/internal/domain/models"
+	"sso/internal/lib/jwt"
+)
+
+func TestAccountStats(t *testing.T) {
+	ctx := context.Background()
+	a, st := newTestAuth(t)
+	appID := newTestApp(t, st)
+	adminID := newTestAdmin(t, st, appID)
+	registerTestAccount(t, a, appID, "active@example.com")
+	suspendedID := registerTestAccount(t, a, appID, "suspended@example.com")
+	if err := st.UpdateStatus(ctx, suspendedID, models.SUSPENDED); err != nil {
+		t.Fatalf("suspend: %v", err)
+	}
+
+	if _, err := a.AccountStats(ctx, suspendedID); !errors.Is(err, ErrPermissionDenied) {
+		t.Fatalf("non-admin stats error = %v, want ErrPermissionDenied", err)
+	}
+
+	stats, err := a.AccountStats(ctx, adminID)
+	if err != nil {
+		t.Fatalf("stats: %v", err)
+	}
+
+	if stats.Total != 3 {
+		t.Errorf("total = %d, want 3", stats.Total)
+	}
+
+	tests := []struct {
+		name string
+		got  int64
+		want int64
+	}{
+		{name: "active", got: stats.ByStatus[models.ACTIVE], want: 2},
+		{name: "suspended", got: stats.ByStatus[models.SUSPENDED], want: 1},
+		{name: "banned", got: stats.ByStatus[models.BANNED], want: 0},
+		{name: "users", got: stats.ByRole[models.USER], want: 2},
+		{name: "admins", got: stats.ByRole[models.ADMIN], want: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.got != tt.want {
+				t.Errorf("count = %d, want %d", tt.got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSessionsForAccounts(t *testing.T) {
+	ctx := context.Background()
+	a, st := newTestAuth(t)
+	appID := newTestApp(t, st)
+	adminID := newTestAdmin(t, st, appID)
+	twiceID := registerTestAccount(t, a, appID, "twice@example.com")
+	onceID := registerTestAccount(t, a, appID, "once@example.com")
+	idleID := registerTestAccount(t, a, appID, "idle@example.com")
+	loginTestAccount(t, a, appID, "twice@example.com")
+	loginTestAccount(t, a, appID, "twice@example.com")
+	loginTestAccount(t, a, appID, "once@example.com")
+
+	if _, err := a.SessionsForAccounts(ctx, twiceID, []int64{twiceID}); !errors.Is(err, ErrPermissionDenied) {
+		t.Fatalf("non-admin error = %v, want ErrPermissionDenied", err)
+	}
+
+	sessions, err := a.SessionsForAccounts(ctx, adminID, []int64{twiceID, onceID, idleID})
+	if err != nil {
+		t.Fatalf("sessions: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		accountID int64
+		want      int
+	}{
+		{name: "two sessions", accountID: twiceID, want: 2},
+		{name: "one session", accountID: onceID, want: 1},
+		{name: "no sessions", accountID: idleID, want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := sessions[tt.accountID]
+			if len(got) != tt.want {
+				t.Fatalf("sessions = %d, want %d", len(got), tt.want)
+			}
+			for _, session := range got {
+				if session.AccountID != tt.accountID {
+					t.Errorf("session %s belongs to account %d", session.SID, session.AccountID)
+				}
+			}
+		})
+	}
+}
+
+func TestRevokeAllAppSessions(t *testing.T) {
+	ctx := context.Background()
+	a, st := newTestAuth(t)
+	appID := newTestApp(t, st)
+	otherAppID := newTestApp(t, st)
+	adminID := newTestAdmin(t, st, appID)
+	accountID := registerTestAccount(t, a, appID, "user@example.com")
+	otherID := registerTestAccount(t, a, otherAppID, "other@example.com")
+	revokedFirst := loginTestAccount(t, a, appID, "user@example.com")
+	revokedSecond := loginTestAccount(t, a, appID, "user@example.com")
+	kept := loginTestAccount(t, a, otherAppID, "other@example.com")
+
+	if _, err := a.RevokeAllAppSessions(ctx, accountID, appID); !errors.Is(err, ErrPermissionDenied) {
+		t.Fatalf("non-admin error = %v, want ErrPermissionDenied", err)
+	}
+
+	revoked, err := a.RevokeAllAppSessions(ctx, adminID, appID)
+	if err != nil {
+		t.Fatalf("revoke: %v", err)
+	}
+	if revoked != 2 {
+		t.Errorf("revoked = %d, want 2", revoked)
+	}
+
+	fresh := loginTestAccount(t, a, appID, "user@example.com")
+
+	tests := []struct {
+		name         string
+		accountID    int64
+		token        string
+		rejected     func(t *testing.T, a *Auth, accountID int64, token string) bool
+		wantRejected bool
+	}{
+		{name: "access token in app", accountID: accountID, token: revokedFirst.GetToken(), rejected: accessTokenRejected, wantRejected: true},
+		{name: "refresh token in app", accountID: accountID, token: revokedSecond.GetRefreshToken(), rejected: refreshTokenRejected, wantRejected: true},
+		{name: "access token in other app", accountID: otherID, token: kept.GetToken(), rejected: accessTokenRejected},
+		{name: "access token issued after", accountID: accountID, token: fresh.GetToken(), rejected: accessTokenRejected},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.rejected(t, a, tt.accountID, tt.token); got != tt.wantRejected {
+				t.Errorf("rejected = %v, want %v", got, tt.wantRejected)
+			}
+		})
+	}
+}
+
+func TestPreviewTokenClaims(t *testing.T) {
+	enricher := jwt.ClaimsEnricherFunc(func(context.Context, jwt.ClaimsContext) (map[string]any, error) {
+		return map[string]any{"tenant": "acme"}, nil
+	})
+
+	tests := []struct {
+		name   string
+		opts   []Option
+		scopes []string
+	}{
+		{name: "defaults"},
+		{
+			name:   "issuer, scopes and enriched claims",
+			opts:   []Option{WithTokenIssuer("https://sso.example.com"), WithClaimsEnricher(enricher)},
+			scopes: []string{"profile", "email"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			a, st := newTestAuth(t, tt.opts...)
+			appID := newTestApp(t, st)
+			adminID := newTestAdmin(t, st, appID)
+			accountID := registerTestAccount(t, a, appID, "user@example.com")
+			if tt.scopes != nil {
+				if err := st.SetMembershipScopes(ctx, accountID, appID, tt.scopes); err != nil {
+					t.Fatalf("set scopes: %v", err)
+				}
+			}
+
+			preview, err := a.PreviewTokenClaims(ctx, adminID, accountID, appID)
+			if err != nil {
+				t.Fatalf("preview: %v", err)
+			}
+			issued := tokenClaims(t, loginTestAccount(t, a, appID, "user@example.com").GetToken())
+
+			// Round-trip the preview through JSON so its values compare like the
+			// token's.
+			b, err := json.Marshal(preview)
+			if err != nil {
+				t.Fatalf("marshal preview: %v", err)
+			}
+			var want map[string]any
+			if err := json.Unmarshal(b, &want); err != nil {
+				t.Fatalf("unmarshal preview: %v", err)
+			}
+
+			// Time-based claims and the jti differ between any two tokens, and
+			// only a real session has a sid.
+			for _, claim := range []string{"jti", "iat", "nbf", "exp", "auth_time"} {
+				if _, ok := want[claim]; !ok {
+					t.Errorf("preview lacks %s", claim)
+				}
+				delete(want, claim)
+				delete(issued, claim)
+			}
+			delete(issued, "sid")
+
+			if !reflect.DeepEqual(want, issued) {
+				t.Errorf("preview = %v, issued token carries %v", want, issued)
+			}
+		})
+	}
+}
+
+func TestPreviewTokenClaimsAdminOnly(t *testing.T) {
+	ctx := context.Background()
+	a, st := newTestAuth(t)
+	appID := newTestApp(t, st)
+	accountID := registerTestAccount(t, a, appID, "user@example.com")
+
+	if _, err := a.PreviewTokenClaims(ctx, accountID, accountID, appID); !errors.Is(err, ErrPermissionDenied) {
+		t.Errorf("non-admin preview error = %v, want ErrPermissionDenied", err)
+	}
+}
+
+// tokenClaims decodes the claims of a signed token without verifying it.
+func tokenClaims(t *testing.T, token string) map[string]any {
+	t.Helper()
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		t.Fatalf("token has %d parts, want 3", len(parts))
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		t.Fatalf("decode payload: %v", err)
+	}
+
+	var claims map[string]any
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		t.Fatalf("unmarshal claims: %v", err)
+	}
+
+	return claims
+}
@@ -0,0 +1,62 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sso/internal/domain/models"
+	"sso/internal/lib/logger/sl"
+)
+
+const (
+	defaultAuditPageSize = 50
+	maxAuditPageSize     = 500
+)
+
+var ErrAuditLogDisabled = errors.New("audit log is not enabled")
+
+type AuditProvider interface {
+	QueryAuditLog(ctx context.Context, filter models.AuditFilter) ([]models.AuditEvent, error)
+}
+
+// QueryAuditLog returns one page of audit events matching filter, oldest first.
+// filter.Limit defaults to 50 and is capped at 500; pass the returned NextAfterID as
+// filter.AfterID for the next page. Admin only.
+func (a *Auth) QueryAuditLog(ctx context.Context, actorID int64, filter models.AuditFilter) (models.AuditPage, error) {
+	const op = "Auth.QueryAuditLog"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int64("actor_id", actorID),
+	)
+
+	if err := a.requireAdmin(ctx, actorID); err != nil {
+		log.Warn("admin check failed", sl.Err(err))
+		return models.AuditPage{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if a.auditProvider == nil {
+		return models.AuditPage{}, fmt.Errorf("%s: %w", op, ErrAuditLogDisabled)
+	}
+
+	if filter.Limit <= 0 {
+		filter.Limit = defaultAuditPageSize
+	}
+	if filter.Limit > maxAuditPageSize {
+		filter.Limit = maxAuditPageSize
+	}
+
+	events, err := a.auditProvider.QueryAuditLog(ctx, filter)
+	if err != nil {
+		log.Error("failed to query audit log", sl.Err(err))
+		return models.AuditPage{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	page := models.AuditPage{Events: events}
+	if len(events) == filter.Limit {
+		page.NextAfterID = events[len(events)-1].ID
+	}
+
+	return page, nil
+}
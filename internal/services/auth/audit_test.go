@@ -0,0 +1,125 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"sso/internal/domain/models"
+	"sso/internal/storage/sqlite/sqlitetest"
+)
+
+func TestQueryAuditLog(t *testing.T) {
+	ctx := context.Background()
+	audit := sqlitetest.New(t, nil)
+	a, st := newTestAuth(t, WithAuditProvider(audit))
+	appID := newTestApp(t, st)
+	adminID := newTestAdmin(t, st, appID)
+
+	start := time.Now().Add(-time.Hour)
+	entries := []models.AuditEvent{
+		{Type: "AccountLoggedIn", AccountID: 1, IPAddress: "203.0.113.1"},
+		{Type: "AccountLoggedIn", AccountID: 2, IPAddress: "203.0.113.2"},
+		{Type: "PasswordChanged", AccountID: 1, IPAddress: "203.0.113.1"},
+		{Type: "AccountStatusChanged", AccountID: 1, ActorID: adminID},
+		{Type: "AccountLoggedIn", AccountID: 1, IPAddress: "203.0.113.3"},
+	}
+	ids := make([]int64, len(entries))
+	for i, entry := range entries {
+		entry.Payload = "{}"
+		entry.OccurredAt = start.Add(time.Duration(i) * time.Minute)
+		id, err := audit.AppendAuditEvent(ctx, entry)
+		if err != nil {
+			t.Fatalf("append audit event: %v", err)
+		}
+		ids[i] = id
+	}
+
+	tests := []struct {
+		name   string
+		filter models.AuditFilter
+		want   []int64
+	}{
+		{name: "everything", want: ids},
+		{name: "by account", filter: models.AuditFilter{AccountID: 1}, want: []int64{ids[0], ids[2], ids[3], ids[4]}},
+		{name: "by event type", filter: models.AuditFilter{Type: "AccountLoggedIn"}, want: []int64{ids[0], ids[1], ids[4]}},
+		{name: "by account and event type", filter: models.AuditFilter{AccountID: 1, Type: "AccountLoggedIn"}, want: []int64{ids[0], ids[4]}},
+		{name: "by actor", filter: models.AuditFilter{ActorID: adminID}, want: []int64{ids[3]}},
+		{name: "by ip", filter: models.AuditFilter{IPAddress: "203.0.113.1"}, want: []int64{ids[0], ids[2]}},
+		{name: "by time range", filter: models.AuditFilter{From: start.Add(time.Minute), To: start.Add(3 * time.Minute)}, want: []int64{ids[1], ids[2]}},
+		{name: "no match", filter: models.AuditFilter{Type: "AccountDeleted"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := a.QueryAuditLog(ctx, adminID, tt.filter)
+			if err != nil {
+				t.Fatalf("query: %v", err)
+			}
+			if got := auditEventIDs(page.Events); !slices.Equal(got, tt.want) {
+				t.Errorf("events = %v, want %v", got, tt.want)
+			}
+			if page.NextAfterID != 0 {
+				t.Errorf("next after ID = %d, want 0 on the only page", page.NextAfterID)
+			}
+		})
+	}
+
+	t.Run("paginated", func(t *testing.T) {
+		filter := models.AuditFilter{AccountID: 1, Limit: 2}
+		var (
+			got   []int64
+			pages int
+		)
+		for {
+			page, err := a.QueryAuditLog(ctx, adminID, filter)
+			if err != nil {
+				t.Fatalf("query page %d: %v", pages+1, err)
+			}
+			pages++
+			if len(page.Events) > filter.Limit {
+				t.Fatalf("page %d has %d events, limit %d", pages, len(page.Events), filter.Limit)
+			}
+			got = append(got, auditEventIDs(page.Events)...)
+			if page.NextAfterID == 0 {
+				break
+			}
+			filter.AfterID = page.NextAfterID
+		}
+
+		// The fourth event fills the second page, so a third, empty page ends the
+		// listing.
+		if pages != 3 {
+			t.Errorf("pages = %d, want 3", pages)
+		}
+		if want := []int64{ids[0], ids[2], ids[3], ids[4]}; !slices.Equal(got, want) {
+			t.Errorf("events = %v, want %v", got, want)
+		}
+	})
+
+	t.Run("not an admin", func(t *testing.T) {
+		accountID := registerTestAccount(t, a, appID, "user@example.com")
+		if _, err := a.QueryAuditLog(ctx, accountID, models.AuditFilter{}); !errors.Is(err, ErrPermissionDenied) {
+			t.Errorf("error = %v, want ErrPermissionDenied", err)
+		}
+	})
+}
+
+func TestQueryAuditLogDisabled(t *testing.T) {
+	a, st := newTestAuth(t)
+	adminID := newTestAdmin(t, st, newTestApp(t, st))
+
+	if _, err := a.QueryAuditLog(context.Background(), adminID, models.AuditFilter{}); !errors.Is(err, ErrAuditLogDisabled) {
+		t.Errorf("error = %v, want ErrAuditLogDisabled", err)
+	}
+}
+
+func auditEventIDs(events []models.AuditEvent) []int64 {
+	ids := make([]int64, 0, len(events))
+	for _, event := range events {
+		ids = append(ids, event.ID)
+	}
+	return ids
+}
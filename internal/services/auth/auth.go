@@ -2,32 +2,130 @@ package auth
 
 import (
 	"context"
-	"encoding/base64"
 	"errors"
 	"fmt"
 	"log/slog"
+	"sso/internal/domain/events"
 	"sso/internal/domain/models"
+	"sso/internal/lib/captcha"
+	"sso/internal/lib/geoip"
 	"sso/internal/lib/jwt"
+	"sso/internal/lib/lockout"
 	"sso/internal/lib/logger/sl"
+	"sso/internal/lib/passhash"
+	"sso/internal/lib/passwordpolicy"
+	"sso/internal/lib/pwned"
+	"sso/internal/lib/ratelimit"
+	"sso/internal/lib/secret"
+	"sso/internal/lib/sessionid"
 	"sso/internal/storage"
+	"sync"
 	"time"
 
-	"crypto/rand"
+	mrand "math/rand/v2"
 
 	ssov1 "github.com/dariasmyr/protos/gen/go/sso"
-	"golang.org/x/crypto/bcrypt"
 )
 
 type Auth struct {
-	log             *slog.Logger
-	accountSaver    AccountSaver
-	accountProvider AccountProvider
-	appProvider     AppProvider
-	appSaver        AppSaver
-	sessionSaver    SessionSaver
-	sessionProvider SessionProvider
-	tokenTTL        time.Duration
-	refreshTokenTTL time.Duration
+	log                     *slog.Logger
+	accountSaver            AccountSaver
+	accountProvider         AccountProvider
+	appProvider             AppProvider
+	appSaver                AppSaver
+	sessionSaver            SessionSaver
+	sessionProvider         SessionProvider
+	securityFactorProvider  SecurityFactorProvider
+	membershipSaver         MembershipSaver
+	membershipProvider      MembershipProvider
+	oneTimeCodeStore        OneTimeCodeStore
+	ssoTicketStore          SSOTicketStore
+	tokenTTL                time.Duration
+	tokenTTLJitter          time.Duration
+	refreshTokenTTL         time.Duration
+	renewalWindow           time.Duration
+	eventPublisher          EventPublisher
+	hashSlots               chan struct{}
+	identifierNormalizer    IdentifierNormalizer
+	singleSession           bool
+	refreshFamilyMaxAge     time.Duration
+	failedLogins            *ratelimit.Limiter
+	failureDelay            time.Duration
+	throttleUnknownAccounts bool
+	newIPRefreshPolicy      NewIPRefreshPolicy
+	ssoTicketTTL            time.Duration
+	provisioner             EventPublisher
+	blockingProvisioning    bool
+	lenientStatusCheck      bool
+	sessionIDs              sessionid.Generator
+	secrets                 secret.Generator
+	lockout                 *lockout.Tracker
+	concealLockout          bool
+	ipLockout               *lockout.Tracker
+	rolePermissions         map[models.AccountRole][]string
+	perAppIdentifiers       bool
+	tarpitDetector          TarpitDetector
+	tarpitDelay             time.Duration
+	maxSessions             int
+	rejectOverQuota         bool
+	sessionQuotas           SessionQuotaProvider
+	sessionQuotaSaver       SessionQuotaSaver
+	instantRoleChange       bool
+	auditProvider           AuditProvider
+	totpStore               TOTPStore
+	idlePolicy              IdlePolicy
+	subjectFormat           jwt.SubjectFormat
+	claimsEnricher          jwt.ClaimsEnricher
+	tokenIssuer             string
+	clockSkewLeeway         time.Duration
+	revokedTokens           TokenRevocationStore
+	jwtRefreshTokens        bool
+	keyProvider             KeyProvider
+	passwordResets          EventPublisher
+	passwordResetTTL        time.Duration
+	resetRequests           *ratelimit.Limiter
+	passkeyStore            PasskeyStore
+	passwordPolicy          *passwordpolicy.Policy
+	passwordHistory         PasswordHistoryStore
+	passwordHistoryDepth    int
+	hasher                  passhash.Hasher
+	pepper                  []byte
+	breachChecker           pwned.Checker
+	enforceBreachCheck      bool
+	breachTimeout           time.Duration
+	deviceNames             DeviceNameStore
+	knownDevices            KnownDeviceStore
+	newDeviceNotifier       EventPublisher
+	deviceRevokeTTL         time.Duration
+	geoIP                   geoip.Resolver
+	loginRisk               LoginRiskPolicy
+	reauthWindow            time.Duration
+	recentAuthMaxAge        time.Duration
+	captcha                 captcha.Verifier
+	captchaFlags            *captcha.Flags
+	captchaOnRegister       bool
+	deletedAccounts         DeletedAccountStore
+	deletionRetention       time.Duration
+	accountData             AccountDataStore
+	smsSender               SMSSender
+	smsCodeTTL              time.Duration
+	smsRequests             *ratelimit.Limiter
+	profiles                ProfileStore
+	profileClaims           []string
+	profileMetadataMax      int
+	emailChanges            EventPublisher
+	emailChangeStore        EmailChangeStore
+	emailChangeTTL          time.Duration
+	emailChangeRequests     *ratelimit.Limiter
+	invites                 InviteStore
+	inviteTTL               time.Duration
+	rememberMeRefreshTTL    time.Duration
+	sessionBinding          SessionBinding
+	signingKeyOverlap       time.Duration
+	dummyHashOnce           sync.Once
+	dummyHash               []byte
+	reactivations           EventPublisher
+	reactivationTTL         time.Duration
 }
 
 // RegisterClient registers a new app in the system, creates an app, and returns app ID.
@@ -53,30 +151,60 @@ func (a *Auth) RegisterClient(ctx context.Context, request *ssov1.RegisterClient
 }
 
 // Register registers a new account in the system, creates a session, and returns account ID.
+// Self-registered accounts are always users: the role in the request is ignored,
+// and admins grant other roles with GrantAppRole.
 func (a *Auth) Register(ctx context.Context, request *ssov1.RegisterRequest) (*ssov1.RegisterResponse, error) {
+	return a.RegisterWithOptions(ctx, request, RegisterOptions{})
+}
+
+// RegisterOptions are the optional inputs of a registration.
+type RegisterOptions struct {
+	// Username lets the account also log in with it. Apps can require one, see
+	// SetAppUsernameRequired; registrations without it fail with
+	// ErrUsernameRequired then. See SetUsername for the format.
+	Username string
+	// PhoneNumber lets the account also log in with it, by password or SMS code.
+	// Numbers must be in international format, else ErrInvalidPhone.
+	PhoneNumber string
+	// IPAddress is the address of the registering client.
+	IPAddress string
+	// CaptchaToken is the token of a solved CAPTCHA. Registrations fail with
+	// ErrCaptchaRequired without a valid one if CAPTCHAs are required on
+	// registration or the IP ran into the failed-login limit.
+	CaptchaToken string
+}
+
+// RegisterWithOptions is Register with the optional inputs in opts.
+//
+// Invite-only apps, see SetAppSelfRegistration, refuse every registration with
+// ErrSelfRegistrationDisabled.
+func (a *Auth) RegisterWithOptions(ctx context.Context, request *ssov1.RegisterRequest, opts RegisterOptions) (*ssov1.RegisterResponse, error) {
 	const op = "Auth.RegisterNewAccount"
 
+	email := a.identifierNormalizer.Normalize(request.GetEmail())
+
 	log := a.log.With(
 		slog.String("op", op),
-		slog.String("email", request.GetEmail()),
+		slog.String("email", email),
 	)
 
 	log.Info("registering account")
 
-	passHash, err := bcrypt.GenerateFromPassword([]byte(request.GetPassword()), bcrypt.DefaultCost)
-	if err != nil {
-		log.Error("failed to generate password hash", sl.Err(err))
+	if err := a.checkSelfRegistration(ctx, request.GetAppId()); err != nil {
+		if errors.Is(err, ErrSelfRegistrationDisabled) {
+			log.Info("self-registration disabled")
+		} else {
+			log.Error("failed to check self-registration", sl.Err(err))
+		}
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
-	status := models.ACTIVE
-
-	modelRole := models.AccountRole(request.GetRole())
-
-	id, err := a.accountSaver.SaveAccount(ctx, request.GetEmail(), passHash, modelRole, status, request.GetAppId())
+	if _, err := a.checkCaptcha(ctx, log, "", opts.IPAddress, opts.CaptchaToken, a.captchaOnRegister); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
 
+	id, err := a.createAccount(ctx, log, email, request.GetPassword(), models.USER, request.GetAppId(), opts.Username, opts.PhoneNumber)
 	if err != nil {
-		log.Error("failed to save account", sl.Err(err))
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
@@ -85,69 +213,215 @@ func (a *Auth) Register(ctx context.Context, request *ssov1.RegisterRequest) (*s
 	}, nil
 }
 
+// createAccount saves a new account with email, already normalized, registered
+// with appID in role and provisions it. It checks that the identifiers are free
+// and the password meets the policy; the username and phone number are normalized
+// first. Errors are logged.
+func (a *Auth) createAccount(ctx context.Context, log *slog.Logger, email string, password string, role models.AccountRole, appID int32, username string, phoneNumber string) (int64, error) {
+	if err := a.checkIdentifierAvailable(ctx, email); err != nil {
+		log.Info("identifier not available", sl.Err(err))
+		return 0, err
+	}
+
+	username = a.identifierNormalizer.Normalize(username)
+	if err := a.checkUsername(ctx, appID, username); err != nil {
+		logUsernameRejected(log, err)
+		return 0, err
+	}
+
+	if phoneNumber != "" {
+		number, err := a.checkPhone(ctx, appID, phoneNumber)
+		if err != nil {
+			logPhoneRejected(log, err)
+			return 0, err
+		}
+		phoneNumber = number
+	}
+
+	if err := a.checkPasswordPolicy(appID, password); err != nil {
+		log.Info("password rejected by policy", sl.Err(err))
+		return 0, err
+	}
+
+	if err := a.checkBreachedPassword(ctx, log, password); err != nil {
+		return 0, err
+	}
+
+	passHash, err := a.hashPassword(ctx, password)
+	if err != nil {
+		log.Error("failed to generate password hash", sl.Err(err))
+		return 0, err
+	}
+
+	id, err := a.accountSaver.SaveAccount(ctx, email, passHash, role, models.ACTIVE, appID, "", username, phoneNumber)
+	if err != nil {
+		log.Error("failed to save account", sl.Err(err))
+		return 0, err
+	}
+
+	if err := a.provisionAccount(ctx, log, id, email, appID); err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
 // Login checks if account with given credentials exists in the system and returns access + refresh token.
 //
 // If account exists, but password is incorrect, returns error.
 // If account doesn't exist, returns error.
 func (a *Auth) Login(ctx context.Context, request *ssov1.LoginRequest) (*ssov1.LoginResponse, error) {
+	response, _, err := a.LoginWithOptions(ctx, request, LoginOptions{})
+	return response, err
+}
+
+// LoginOptions are the optional inputs of a login.
+type LoginOptions struct {
+	// Scopes narrow the session. They are intersected with the scopes the account
+	// may be granted in the app; anything beyond that is dropped rather than
+	// refused. Nil requests every allowed scope.
+	Scopes []string
+	// SecondFactor is a TOTP or recovery code for accounts that have TOTP
+	// enrolled; they fail with ErrSecondFactorRequired without one. A wrong code
+	// counts as a failed login.
+	SecondFactor string
+	// CaptchaToken is the token of a solved CAPTCHA. Identifiers and IPs that ran
+	// into the failed-login limit, and logins the risk check asks one for, fail
+	// with ErrCaptchaRequired without a valid token.
+	CaptchaToken string
+	// RememberMe gives the session's refresh tokens the long TTL set by
+	// WithRememberMe, on this login and every refresh that rotates them. The
+	// refresh family max age still caps the session.
+	RememberMe bool
+	// Nonce is echoed in the ID token.
+	Nonce string
+}
+
+// LoginWithOptions is Login with the optional inputs in opts. It also returns an
+// OpenID Connect ID token for OIDC-enabled apps, see SetAppOIDC, and an empty one
+// for the rest. The ID token tells how the account authenticated in its amr
+// claim: by password, and by TOTP or recovery code as a second factor.
+func (a *Auth) LoginWithOptions(ctx context.Context, request *ssov1.LoginRequest, opts LoginOptions) (*ssov1.LoginResponse, string, error) {
 	const op = "Auth.Login"
 
+	email := a.loginIdentifier(request.GetEmail())
+
 	log := a.log.With(
 		slog.String("op", op),
-		slog.String("username", request.GetEmail()),
+		slog.String("username", email),
 	)
 
 	log.Info("attempting to login user")
 
-	account, err := a.accountProvider.AccountByEmail(ctx, request.GetEmail())
+	// Resolve the app first so a bad app ID fails fast, without a password compare.
+	app, err := a.appForLogin(ctx, request.GetAppId())
+	if err != nil {
+		log.Warn("invalid app", slog.Int("app_id", int(request.GetAppId())), sl.Err(err))
+		return nil, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	if flagged, err := a.tarpit(ctx, log, email, request.GetPassword(), request.GetUserAgent(), request.GetIpAddress()); flagged {
+		logCredentialFailure(log, err)
+		return nil, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	captchaPassed, err := a.checkCaptcha(ctx, log, email, request.GetIpAddress(), opts.CaptchaToken, false)
+	if err != nil {
+		return nil, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.checkLockout(ctx, email, request.GetIpAddress()); err != nil {
+		if !errors.Is(err, ErrAccountLocked) {
+			log.Error("failed to check lockout", sl.Err(err))
+			return nil, "", fmt.Errorf("%s: %w", op, err)
+		}
+		log.Info("identifier locked out", sl.Err(err))
+		return nil, "", fmt.Errorf("%s: %w", op, a.lockedLogin(ctx, email, request.GetPassword(), err))
+	}
+
+	account, err := a.accountByIdentifier(ctx, email, request.GetAppId())
 	if err != nil {
 		if errors.Is(err, storage.ErrAccountNotFound) {
-			a.log.Warn("account not found", sl.Err(err))
-			return nil, fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
+			if err := a.compareDummyPassword(ctx, request.GetPassword()); err != nil {
+				return nil, "", fmt.Errorf("%s: %w", op, err)
+			}
+			err := a.unknownAccountLogin(ctx, email, request.GetIpAddress())
+			logCredentialFailure(log, err)
+			return nil, "", fmt.Errorf("%s: %w", op, err)
 		}
 
 		a.log.Error("failed to get account", sl.Err(err))
-		return nil, fmt.Errorf("%s: %w", op, err)
+		return nil, "", fmt.Errorf("%s: %w", op, err)
 	}
 
-	if err := bcrypt.CompareHashAndPassword(account.PassHash, []byte(request.GetPassword())); err != nil {
-		a.log.Info("invalid credentials", sl.Err(err))
-		return nil, fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
+	if err := a.comparePassword(ctx, account.PassHash, request.GetPassword()); err != nil {
+		if isContextErr(err) {
+			log.Error("failed to verify password", sl.Err(err))
+			return nil, "", fmt.Errorf("%s: %w", op, err)
+		}
+
+		err := a.failedLogin(ctx, email, request.GetIpAddress(), failureBadPassword)
+		logCredentialFailure(log.With(slog.Int64("account_id", account.ID)), err)
+		return nil, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	a.rehashPassword(ctx, log, account, request.GetPassword())
+
+	if err := loginStatusError(account.Status); err != nil {
+		log.Info("account status forbids login", slog.Int("status", int(account.Status)))
+		return nil, "", fmt.Errorf("%s: %w", op, err)
 	}
 
-	app, err := a.appProvider.App(ctx, request.GetAppId())
+	secondFactorVerified, err := a.checkSecondFactor(ctx, log, account.ID, opts.SecondFactor)
 	if err != nil {
-		return nil, fmt.Errorf("%s: %w", op, err)
+		switch {
+		case errors.Is(err, errBadSecondFactor):
+			err := a.failedLogin(ctx, email, request.GetIpAddress(), failureBadSecondFactor)
+			logCredentialFailure(log.With(slog.Int64("account_id", account.ID)), err)
+			return nil, "", fmt.Errorf("%s: %w", op, err)
+		case errors.Is(err, ErrSecondFactorRequired):
+			log.Info("second factor required")
+		default:
+			log.Error("failed to check second factor", sl.Err(err))
+		}
+		return nil, "", fmt.Errorf("%s: %w", op, err)
 	}
 
-	log.Info("user logged in successfully")
+	if err := a.checkMFAPolicy(ctx, account.ID, app); err != nil {
+		log.Info("mfa policy not satisfied", sl.Err(err))
+		return nil, "", fmt.Errorf("%s: %w", op, err)
+	}
 
-	token, err := jwt.NewToken(account, app, a.tokenTTL)
-	if err != nil {
-		a.log.Error("failed to generate token", sl.Err(err))
-		return nil, fmt.Errorf("%s: %w", op, err)
+	if err := a.checkLoginRisk(ctx, log, account.ID, request.GetIpAddress(), secondFactorVerified, captchaPassed); err != nil {
+		return nil, "", fmt.Errorf("%s: %w", op, err)
 	}
 
-	refreshToken, err := generateRefreshToken()
+	account, err = a.accountForApp(ctx, account, request.GetAppId())
 	if err != nil {
-		log.Error("failed to generate refresh token", sl.Err(err))
-		return nil, fmt.Errorf("%s: %w", op, err)
+		log.Warn("failed to resolve app role", sl.Err(err))
+		return nil, "", fmt.Errorf("%s: %w", op, err)
 	}
-	expiresAt := time.Now().Add(a.refreshTokenTTL)
 
-	sessionID, err := a.sessionSaver.SaveSession(ctx, account.ID, request.GetUserAgent(), request.GetIpAddress(), token, refreshToken, expiresAt)
-	if err != nil {
-		a.log.Error("failed to save session", sl.Err(err))
-		return nil, fmt.Errorf("%s: %w", op, err)
+	if opts.Scopes != nil {
+		account.Scopes = intersectScopes(opts.Scopes, account.Scopes)
 	}
 
-	log.Info("session created", slog.String("session_id", sessionID))
+	log.Info("user logged in successfully")
 
-	return &ssov1.LoginResponse{
-		AccountId:    account.ID,
-		Token:        token,
-		RefreshToken: refreshToken,
-	}, nil
+	if a.lockout != nil {
+		if err := a.lockout.Success(ctx, email); err != nil {
+			log.Warn("failed to record lockout success", sl.Err(err))
+		}
+	}
+
+	authn := authentication{methods: []string{jwt.AMRPassword}, nonce: opts.Nonce}.withSecondFactor(secondFactorVerified)
+
+	response, idToken, err := a.startSession(ctx, log, account, app, request.GetUserAgent(), request.GetIpAddress(), opts.RememberMe, &authn)
+	if err != nil {
+		return nil, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return response, idToken, nil
 }
 
 // Logout logs out a user by terminating their sessions.
@@ -169,17 +443,24 @@ func (a *Auth) Logout(ctx context.Context, request *ssov1.LogoutRequest) (*ssov1
 	}
 
 	for _, session := range sessions {
-		err := a.sessionSaver.RevokeSession(ctx, session.Token)
+		err := a.sessionSaver.RevokeSession(ctx, session.SID)
 		if err != nil {
 			log.Error("failed to revoke session", sl.Err(err))
 			return nil, fmt.Errorf("%s: %w", op, err)
 		}
 	}
 
+	if err := a.revokeAccountTokens(ctx, log, request.GetAccountId(), models.RevokedSignedOut); err != nil {
+		log.Error("failed to revoke access tokens", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
 	log.Info("user logged out successfully")
 	return &ssov1.LogoutResponse{Success: true}, nil
 }
 
+// ChangePassword sets a new password for the account after checking the old one.
+// It lifts a password change requirement, see SetPasswordChangeRequired.
 func (a *Auth) ChangePassword(ctx context.Context, request *ssov1.ChangePasswordRequest) (*ssov1.ChangePasswordResponse, error) {
 	const op = "Auth.ChangePassword"
 
@@ -196,51 +477,70 @@ func (a *Auth) ChangePassword(ctx context.Context, request *ssov1.ChangePassword
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
-	if err := bcrypt.CompareHashAndPassword(account.PassHash, []byte(request.GetOldPassword())); err != nil {
+	if err := a.comparePassword(ctx, account.PassHash, request.GetOldPassword()); err != nil {
+		if isContextErr(err) {
+			log.Error("failed to verify old password", sl.Err(err))
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
 		log.Info("invalid old password", sl.Err(err))
 		return nil, fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
 	}
 
-	newPassHash, err := bcrypt.GenerateFromPassword([]byte(request.GetNewPassword()), bcrypt.DefaultCost)
+	if err := a.checkPasswordPolicy(account.AppId, request.GetNewPassword()); err != nil {
+		log.Info("new password rejected by policy", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.checkPasswordReuse(ctx, account.ID, account.PassHash, request.GetNewPassword()); err != nil {
+		if errors.Is(err, ErrPasswordReused) {
+			log.Info("new password used before")
+		} else {
+			log.Error("failed to check password history", sl.Err(err))
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.checkBreachedPassword(ctx, log, request.GetNewPassword()); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	newPassHash, err := a.hashPassword(ctx, request.GetNewPassword())
 	if err != nil {
 		log.Error("failed to hash new password", sl.Err(err))
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
+	if err := a.retirePassword(ctx, account.ID, account.PassHash); err != nil {
+		log.Error("failed to record password history", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
 	err = a.accountSaver.UpdatePassword(ctx, request.GetAccountId(), newPassHash)
 	if err != nil {
 		log.Error("failed to update password", sl.Err(err))
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
+	if err := a.clearPasswordChangeRequired(ctx, account); err != nil {
+		log.Error("failed to clear password change requirement", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
 	log.Info("password changed successfully")
 	return &ssov1.ChangePasswordResponse{Success: true}, nil
 }
 
 // ChangeStatus changes the status of an account.
 func (a *Auth) ChangeStatus(ctx context.Context, request *ssov1.ChangeStatusRequest) (*ssov1.ChangeStatusResponse, error) {
-	const op = "Auth.ChangeStatus"
-
-	log := a.log.With(
-		slog.String("op", op),
-		slog.Int64("account_id", request.GetAccountId()),
-		slog.Int64("new_status", int64(request.GetStatus())),
-	)
-
-	log.Info("attempting to change account status")
-
-	modelStatus := request.GetStatus()
-
-	err := a.accountSaver.UpdateStatus(ctx, request.GetAccountId(), models.AccountStatus(modelStatus))
+	err := a.ChangeAccountStatus(ctx, 0, request.GetAccountId(), models.AccountStatus(request.GetStatus()), "")
 	if err != nil {
-		log.Error("failed to change status", sl.Err(err))
-		return nil, fmt.Errorf("%s: %w", op, err)
+		return nil, err
 	}
 
-	log.Info("status changed successfully")
 	return &ssov1.ChangeStatusResponse{
 		AccountId: request.GetAccountId(),
-		Status:    modelStatus,
+		Status:    request.GetStatus(),
 	}, nil
 }
 
@@ -266,8 +566,6 @@ func (a *Auth) GetActiveSessions(ctx context.Context, request *ssov1.GetActiveAc
 	for _, session := range sessions {
 		ssov1Session := &ssov1.Session{
 			AccountId:        session.AccountID,
-			Token:            session.Token,
-			RefreshToken:     session.RefreshToken,
 			UserAgent:        session.UserAgent,
 			IpAddress:        session.IPAddress,
 			ExpiresAt:        session.ExpiresAt.Unix(),
@@ -285,105 +583,33 @@ func (a *Auth) GetActiveSessions(ctx context.Context, request *ssov1.GetActiveAc
 }
 
 func (a *Auth) RefreshSession(ctx context.Context, request *ssov1.RefreshAccountSessionRequest) (*ssov1.RefreshAccountSessionResponse, error) {
-	const op = "Auth.RefreshAccountSession"
-
-	log := a.log.With(
-		slog.String("op", op),
-		slog.Int64("account_id", request.GetAccountId()),
-	)
-
-	log.Info("attempting to get account")
-
-	account, err := a.accountProvider.AccountById(ctx, request.GetAccountId())
-	if err != nil {
-		log.Error("invalid account id", sl.Err(err))
-		return nil, fmt.Errorf("%s: %w", op, err)
-	}
-
-	log.Info("attempting to get app")
-
-	app, err := a.appProvider.App(ctx, account.AppId)
-	if err != nil {
-		log.Error("invalid app id", sl.Err(err))
-		return nil, fmt.Errorf("%s: %w", op, err)
-	}
-
-	log.Info("attempting to refresh session")
-
-	session, err := a.sessionProvider.SessionByRefreshToken(ctx, request.GetRefreshToken())
-	if err != nil {
-		log.Error("invalid refresh token", sl.Err(err))
-		return nil, fmt.Errorf("%s: %w", op, err)
-	}
-
-	if session.RefreshExpiresAt.Before(time.Now()) {
-		log.Info("refresh token expired")
-		return nil, fmt.Errorf("%s: %w", op, err)
-	}
-
-	newToken, err := jwt.NewToken(account, app, a.tokenTTL)
-	if err != nil {
-		log.Error("failed to generate new token", sl.Err(err))
-		return nil, fmt.Errorf("%s: %w", op, err)
-	}
-
-	newRefreshToken, err := generateRefreshToken()
+	token, refreshToken, expiresAt, err := a.RefreshAccountSession(ctx, request.GetAccountId(), request.GetRefreshToken(), "", "")
 	if err != nil {
-		log.Error("failed to generate new refresh token", sl.Err(err))
-		return nil, fmt.Errorf("%s: %w", op, err)
+		return nil, err
 	}
 
-	expiresAt := time.Now().Add(a.refreshTokenTTL)
-
-	sessionID, err := a.sessionSaver.SaveSession(ctx, request.GetAccountId(), "", "", newToken, newRefreshToken, expiresAt)
-	if err != nil {
-		log.Error("failed to update session tokens", sl.Err(err))
-		return nil, fmt.Errorf("%s: %w", op, err)
-	}
-
-	log.Info("session created", slog.String("session_id", sessionID))
-
 	return &ssov1.RefreshAccountSessionResponse{
-		Token:        newToken,
-		RefreshToken: newRefreshToken,
-		ExpiresAt:    expiresAt.Unix(),
+		Token:        token,
+		RefreshToken: refreshToken,
+		ExpiresAt:    expiresAt,
 	}, nil
-
 }
 
 // ValidateSession validates if the token is still active.
 func (a *Auth) ValidateSession(ctx context.Context, request *ssov1.ValidateAccountSessionRequest) (*ssov1.ValidateAccountSessionResponse, error) {
-	const op = "Auth.ValidateAccountSession"
-
-	log := a.log.With(
-		slog.String("op", op),
-	)
-
-	log.Info("validating session")
-
-	session, err := a.sessionProvider.Session(ctx, request.GetToken())
+	validation, err := a.ValidateAccountSession(ctx, request.GetToken())
 	if err != nil {
-		log.Error("invalid token", sl.Err(err))
-		return nil, fmt.Errorf("%s: %w", op, err)
-	}
-
-	if session.ExpiresAt.Before(time.Now()) {
-		log.Info("session expired")
-		return &ssov1.ValidateAccountSessionResponse{
-			Valid:     false,
-			ExpiresAt: session.ExpiresAt.Unix(),
-		}, nil
+		return nil, err
 	}
 
-	log.Info("session is valid")
-
 	return &ssov1.ValidateAccountSessionResponse{
-		Valid:     true,
-		ExpiresAt: session.ExpiresAt.Unix(),
+		Valid:     validation.Valid,
+		ExpiresAt: validation.ExpiresAt.Unix(),
 	}, nil
 }
 
-// RevokeSession revokes the session associated with the given token.
+// RevokeSession revokes the session the given access token belongs to, expired or
+// not.
 func (a *Auth) RevokeSession(ctx context.Context, request *ssov1.RevokeAccountSessionRequest) (*ssov1.RevokeAccountSessionResponse, error) {
 	const op = "Auth.RevokeAccountSession"
 
@@ -393,30 +619,71 @@ func (a *Auth) RevokeSession(ctx context.Context, request *ssov1.RevokeAccountSe
 
 	log.Info("revoking session")
 
-	err := a.sessionProvider.RevokeSession(ctx, request.GetToken())
+	session, _, claims, err := a.sessionForToken(ctx, request.GetToken())
+	if err != nil {
+		log.Info("session not found", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = a.sessionProvider.RevokeSession(ctx, session.SID)
 	if err != nil {
 		log.Error("failed to revoke session", sl.Err(err))
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
+	if err := a.revokeSessionTokens(ctx, session, claims, models.RevokedSignedOut); err != nil {
+		log.Error("failed to revoke access tokens", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
 	log.Info("session revoked successfully")
 	return &ssov1.RevokeAccountSessionResponse{Success: true}, nil
 }
 
 var (
-	ErrInvalidCredentials = errors.New("invalid credentials")
+	ErrInvalidCredentials    = errors.New("invalid credentials")
+	ErrAccountDormant        = errors.New("account is dormant")
+	ErrAccountSuspended      = errors.New("account is suspended")
+	ErrAccountBanned         = errors.New("account is banned")
+	ErrPermissionDenied      = errors.New("permission denied")
+	ErrNoAppMembership       = errors.New("account is not a member of the app")
+	ErrRefreshFamilyExpired  = errors.New("refresh token family expired")
+	ErrMFAEnrollmentRequired = errors.New("app requires an enrolled second factor")
+	ErrSessionRevoked        = errors.New("session revoked")
+	ErrRefreshTokenReused    = errors.New("refresh token reused")
+	ErrAppNotFound           = errors.New("app not found")
 )
 
+type EventPublisher interface {
+	Publish(ctx context.Context, event any) error
+}
+
 type AccountSaver interface {
-	SaveAccount(ctx context.Context, email string, passHash []byte, role models.AccountRole, status models.AccountStatus, appId int32) (uid int64, err error)
+	SaveAccount(ctx context.Context, email string, passHash []byte, role models.AccountRole, status models.AccountStatus, appId int32, externalID string, username string, phone string) (uid int64, err error)
+	SetUsername(ctx context.Context, accountId int64, username string) (err error)
+	SetPhone(ctx context.Context, accountId int64, phone string) (err error)
 	UpdatePassword(ctx context.Context, accountId int64, newPassHash []byte) (err error)
+	SetPasswordChangeRequired(ctx context.Context, accountId int64, required bool) (err error)
+	RehashPassword(ctx context.Context, accountId int64, oldPassHash []byte, newPassHash []byte) (err error)
 	UpdateStatus(ctx context.Context, accountId int64, status models.AccountStatus) (err error)
+	DeactivateIdleAccount(ctx context.Context, accountId int64, before time.Time) (deactivated bool, err error)
+	UpdateLastLogin(ctx context.Context, accountId int64, at time.Time) (err error)
+	IncrementTokenVersion(ctx context.Context, accountId int64) (err error)
+	DeleteAccount(ctx context.Context, accountId int64) (err error)
 }
 
 type AccountProvider interface {
 	AccountByEmail(ctx context.Context, email string) (models.Account, error)
+	AccountByEmailInApp(ctx context.Context, email string, appID int32) (models.Account, error)
+	AccountByUsername(ctx context.Context, username string) (models.Account, error)
+	AccountByUsernameInApp(ctx context.Context, username string, appID int32) (models.Account, error)
+	AccountByPhone(ctx context.Context, phone string) (models.Account, error)
+	AccountByPhoneInApp(ctx context.Context, phone string, appID int32) (models.Account, error)
 	AccountById(ctx context.Context, accountId int64) (models.Account, error)
+	AccountByExternalID(ctx context.Context, externalID string) (models.Account, error)
 	IsAdmin(ctx context.Context, accountId int64) (bool, error)
+	IdleAccounts(ctx context.Context, before time.Time, afterID int64, limit int) ([]int64, error)
+	AccountStats(ctx context.Context) (models.AccountStats, error)
 }
 
 type AppProvider interface {
@@ -425,18 +692,40 @@ type AppProvider interface {
 
 type AppSaver interface {
 	SaveApp(ctx context.Context, appName string, secret string, redirectUrl string) (uid int64, err error)
+	IncrementAppTokenVersion(ctx context.Context, appId int32) (err error)
+	SetAppRelyingParty(ctx context.Context, appId int32, rpID string, origins []string) (err error)
+	SetAppUsernameRequired(ctx context.Context, appId int32, required bool) (err error)
+	SetAppSelfRegistration(ctx context.Context, appId int32, allowed bool) (err error)
+	RotateAppSigningKey(ctx context.Context, appId int32, alg models.SigningAlgorithm, key *models.SigningKey, retireAt time.Time) (err error)
+	AppsDueForKeyRotation(ctx context.Context, createdBefore time.Time) (appIds []int32, err error)
+	DeleteRetiredSigningKeys(ctx context.Context, before time.Time, limit int) (deleted int64, err error)
+	SetAppAudience(ctx context.Context, appId int32, audience string) (err error)
+	SetAppOIDCEnabled(ctx context.Context, appId int32, enabled bool) (err error)
 }
 
 type SessionSaver interface {
-	SaveSession(ctx context.Context, accountId int64, userAgent string, ipAddress string, token string, refreshToken string, expiresAt time.Time) (sessionID string, err error)
-	RevokeSession(ctx context.Context, token string) (err error)
+	SaveSession(ctx context.Context, sid string, accountId int64, appId int32, userAgent string, ipAddress string, device models.SessionDevice, refreshTokenHash string, expiresAt time.Time, familyStartedAt time.Time, familyID string, scopes []string, rememberMe bool) (sessionID string, err error)
+	RevokeSession(ctx context.Context, sid string) (err error)
+	RevokeSessionWithReason(ctx context.Context, sid string, reason models.RevocationReason) (err error)
+	RevokeRotatedSession(ctx context.Context, sid string) (err error)
+	RevokeSessionFamily(ctx context.Context, familyID string, reason models.RevocationReason) (revoked int64, err error)
+	RevokeAccountSessions(ctx context.Context, accountId int64, exceptSID string, reason models.RevocationReason) (err error)
+	RevokeAppSessions(ctx context.Context, appId int32, reason models.RevocationReason) (revoked int64, err error)
+	DeleteExpiredSessions(ctx context.Context, before time.Time, limit int) (deleted int64, err error)
+	DeleteRevokedSessions(ctx context.Context, before time.Time, limit int) (deleted int64, err error)
+	RevokeIdleSessions(ctx context.Context, before time.Time, limit int) (revoked int64, err error)
+	HashLegacyRefreshTokens(ctx context.Context, hash func(refreshToken string) string, limit int) (hashed int64, err error)
+	TouchSession(ctx context.Context, sid string, seenAt time.Time) (err error)
+	ElevateSession(ctx context.Context, sid string, until time.Time) (err error)
 }
 
 type SessionProvider interface {
 	Sessions(ctx context.Context, accountId int64) ([]models.Session, error)
-	Session(ctx context.Context, token string) (models.Session, error)
-	SessionByRefreshToken(ctx context.Context, refreshToken string) (models.Session, error)
-	RevokeSession(ctx context.Context, token string) (err error)
+	SessionsForAccounts(ctx context.Context, accountIds []int64) (map[int64][]models.Session, error)
+	SessionBySID(ctx context.Context, sid string) (models.Session, error)
+	SessionByRefreshToken(ctx context.Context, refreshTokenHash string) (models.Session, error)
+	SessionByLegacyRefreshToken(ctx context.Context, refreshToken string) (models.Session, error)
+	RevokeSession(ctx context.Context, sid string) (err error)
 }
 
 func New(
@@ -447,31 +736,44 @@ func New(
 	appSaver AppSaver,
 	sessionSaver SessionSaver,
 	sessionProvider SessionProvider,
+	securityFactorProvider SecurityFactorProvider,
+	membershipSaver MembershipSaver,
+	membershipProvider MembershipProvider,
+	oneTimeCodeStore OneTimeCodeStore,
+	ssoTicketStore SSOTicketStore,
 	tokenTTL time.Duration,
 	refreshTokenTTL time.Duration,
+	opts ...Option,
 ) *Auth {
-	return &Auth{
-		log:             log,
-		accountSaver:    accountSaver,
-		accountProvider: accountProvider,
-		appProvider:     appProvider,
-		appSaver:        appSaver,
-		sessionSaver:    sessionSaver,
-		sessionProvider: sessionProvider,
-		tokenTTL:        tokenTTL,
-		refreshTokenTTL: refreshTokenTTL,
+	a := &Auth{
+		log:                    log,
+		accountSaver:           accountSaver,
+		accountProvider:        accountProvider,
+		appProvider:            appProvider,
+		appSaver:               appSaver,
+		sessionSaver:           sessionSaver,
+		sessionProvider:        sessionProvider,
+		securityFactorProvider: securityFactorProvider,
+		membershipSaver:        membershipSaver,
+		membershipProvider:     membershipProvider,
+		oneTimeCodeStore:       oneTimeCodeStore,
+		ssoTicketStore:         ssoTicketStore,
+		tokenTTL:               tokenTTL,
+		refreshTokenTTL:        refreshTokenTTL,
+		identifierNormalizer:   DefaultIdentifierNormalizer,
+		ssoTicketTTL:           defaultSSOTicketTTL,
+		sessionIDs:             sessionid.NewULID(),
+		secrets:                secret.CryptoRand{},
+		subjectFormat:          jwt.RawSubject{},
+		hasher:                 passhash.Bcrypt{},
+		reauthWindow:           defaultReauthWindow,
 	}
-}
-
-func generateRefreshToken() (string, error) {
-	const tokenSize = 32
-	token := make([]byte, tokenSize)
 
-	if _, err := rand.Read(token); err != nil {
-		return "", err
+	for _, opt := range opts {
+		opt(a)
 	}
 
-	return base64.URLEncoding.EncodeToString(token), nil
+	return a
 }
 
 // GetActiveAccountSessions retrieves all active sessions for the given account ID.
@@ -497,8 +799,6 @@ func (a *Auth) GetActiveAccountSessions(ctx context.Context, accountID int64) ([
 	for _, session := range sessions {
 		ssov1Session := &ssov1.Session{
 			AccountId:        session.AccountID,
-			Token:            session.Token,
-			RefreshToken:     session.RefreshToken,
 			UserAgent:        session.UserAgent,
 			IpAddress:        session.IPAddress,
 			ExpiresAt:        session.ExpiresAt.Unix(),
@@ -516,6 +816,18 @@ func (a *Auth) GetActiveAccountSessions(ctx context.Context, accountID int64) ([
 }
 
 // RefreshAccountSession refreshes the account session by generating a new token and refresh token.
+//
+// The new access token is issued for the app the session was created for, carrying
+// the account's current role and scopes in that app: both are re-read on every
+// refresh, so a role change reaches the client with its next refresh without a
+// logout. Access tokens issued before the change keep the old role until they
+// expire; see WithInstantRoleChange to cut them off right away.
+//
+// Refreshing rotates the session out: its refresh token can't be used again. A
+// rotated-out refresh token presented again is taken as stolen, revokes every
+// session rotated from the same login and returns ErrRefreshTokenReused. Refresh
+// tokens of another account are rejected like unknown ones, and expired ones
+// return ErrSessionExpired.
 func (a *Auth) RefreshAccountSession(ctx context.Context, accountID int64, refreshToken string, userAgent string, ipAddress string) (string, string, int64, error) {
 	const op = "Auth.RefreshAccountSession"
 
@@ -532,48 +844,682 @@ func (a *Auth) RefreshAccountSession(ctx context.Context, accountID int64, refre
 		return "", "", 0, fmt.Errorf("%s: %w", op, err)
 	}
 
-	log.Info("attempting to get app")
+	log.Info("attempting to refresh session")
 
-	app, err := a.appProvider.App(ctx, account.AppId)
+	_, err = a.preValidateRefreshToken(ctx, refreshToken)
 	if err != nil {
-		log.Error("invalid app id", sl.Err(err))
+		log.Info("refresh token failed pre-validation", sl.Err(err))
 		return "", "", 0, fmt.Errorf("%s: %w", op, err)
 	}
 
-	log.Info("attempting to refresh session")
-
-	session, err := a.sessionProvider.SessionByRefreshToken(ctx, refreshToken)
+	session, err := a.sessionByRefreshToken(ctx, refreshToken)
 	if err != nil {
 		log.Error("invalid refresh token", sl.Err(err))
 		return "", "", 0, fmt.Errorf("%s: %w", op, err)
 	}
 
+	if session.AccountID != accountID {
+		log.Warn("refresh token belongs to another account", slog.Int64("session_account_id", session.AccountID))
+		return "", "", 0, fmt.Errorf("%s: %w", op, storage.ErrSessionNotFound)
+	}
+
 	if session.RefreshExpiresAt.Before(time.Now()) {
 		log.Info("refresh token expired")
+		return "", "", 0, fmt.Errorf("%s: %w", op, ErrSessionExpired)
+	}
+
+	if session.Revoked && session.RevokedReason == models.RevokedRotated {
+		return "", "", 0, fmt.Errorf("%s: %w", op, a.revokeReusedFamily(ctx, log, session))
+	}
+
+	if session.Revoked {
+		log.Info("session revoked", slog.String("reason", string(session.RevokedReason)))
+		return "", "", 0, fmt.Errorf("%s: %w", op, ErrSessionRevoked)
+	}
+
+	if err := loginStatusError(account.Status); err != nil {
+		log.Info("account status forbids refresh", slog.Int("status", int(account.Status)))
+		return "", "", 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if a.refreshFamilyMaxAge > 0 && time.Since(session.FamilyStartedAt) > a.refreshFamilyMaxAge {
+		log.Info("refresh family expired", slog.Time("family_started_at", session.FamilyStartedAt))
+		return "", "", 0, fmt.Errorf("%s: %w", op, ErrRefreshFamilyExpired)
+	}
+
+	if err := a.checkRefreshIP(ctx, session, ipAddress); err != nil {
+		log.Warn("refresh from new ip refused", slog.String("ip", ipAddress), sl.Err(err))
+		return "", "", 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if _, err := a.checkSessionBinding(ctx, log, session, userAgent, ipAddress); err != nil {
+		return "", "", 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Sessions created before per-app memberships have no app recorded.
+	appID := session.AppID
+	if appID == 0 {
+		appID = account.AppId
+	}
+
+	log.Info("attempting to get app")
+
+	app, err := a.appProvider.App(ctx, appID)
+	if err != nil {
+		if errors.Is(err, storage.ErrAppNotFound) {
+			log.Warn("session app no longer exists", slog.Int("app_id", int(appID)))
+			return "", "", 0, fmt.Errorf("%s: %w", op, ErrAppNotFound)
+		}
+		log.Error("invalid app id", sl.Err(err))
 		return "", "", 0, fmt.Errorf("%s: %w", op, err)
 	}
 
-	newToken, err := jwt.NewToken(account, app, a.tokenTTL)
+	account, err = a.accountForApp(ctx, account, appID)
 	if err != nil {
-		log.Error("failed to generate new token", sl.Err(err))
+		log.Warn("failed to resolve app role", sl.Err(err))
 		return "", "", 0, fmt.Errorf("%s: %w", op, err)
 	}
+	account.Scopes = sessionScopes(session, account.Scopes)
 
-	newRefreshToken, err := generateRefreshToken()
+	idle, err := a.checkIdle(ctx, log, session, appID, account.Role)
 	if err != nil {
-		log.Error("failed to generate new refresh token", sl.Err(err))
+		log.Error("failed to revoke idle session", sl.Err(err))
 		return "", "", 0, fmt.Errorf("%s: %w", op, err)
 	}
+	if idle {
+		return "", "", 0, fmt.Errorf("%s: %w", op, ErrSessionIdle)
+	}
 
-	expiresAt := time.Now().Add(a.refreshTokenTTL)
+	// Sessions from before refresh families start their own.
+	familyID := session.FamilyID
+	if familyID == "" {
+		familyID = session.SID
+	}
 
-	sessionID, err := a.sessionSaver.SaveSession(ctx, accountID, userAgent, ipAddress, newToken, newRefreshToken, expiresAt)
+	refreshed, err := a.saveNewSession(ctx, log, account, app, session.FamilyStartedAt, familyID, userAgent, ipAddress, session.RememberMe)
 	if err != nil {
-		log.Error("failed to update session tokens", sl.Err(err))
 		return "", "", 0, fmt.Errorf("%s: %w", op, err)
 	}
 
-	log.Info("session created", slog.String("session_id", sessionID))
+	// A concurrent refresh with the same token rotated the session out first: one
+	// of the two holds a copy of it.
+	if err := a.sessionSaver.RevokeRotatedSession(ctx, session.SID); err != nil {
+		if !errors.Is(err, storage.ErrSessionNotFound) {
+			log.Error("failed to revoke rotated session", sl.Err(err))
+			return "", "", 0, fmt.Errorf("%s: %w", op, err)
+		}
+		session.FamilyID = familyID
+		return "", "", 0, fmt.Errorf("%s: %w", op, a.revokeReusedFamily(ctx, log, session))
+	}
+
+	log.Info("session created", slog.String("session_id", refreshed.SID))
+
+	return refreshed.Token, refreshed.RefreshToken, refreshed.ExpiresAt.Unix(), nil
+}
+
+// ValidateAccountSession validates if the token is still active. The token is
+// verified on its own; the session it names is only looked up for whether it is
+// still live. Expired tokens are not valid, even if their session is.
+//
+// When a renewal window is configured and the access token expires within it while
+// the session is otherwise healthy, a fresh access token for the same session is
+// returned in RenewedToken. The presented one stays valid until it expires.
+func (a *Auth) ValidateAccountSession(ctx context.Context, token string) (models.SessionValidation, error) {
+	return a.ValidateAccountSessionFrom(ctx, token, "", "")
+}
+
+// ValidateAccountSessionFrom is ValidateAccountSession for a token presented by
+// the client with userAgent and ipAddress, which are checked against the session
+// under the session binding policy: a rejected mismatch makes the session invalid
+// for this call, a flagged one sets BindingMismatch. Empty values are not checked.
+func (a *Auth) ValidateAccountSessionFrom(ctx context.Context, token string, userAgent string, ipAddress string) (models.SessionValidation, error) {
+	const op = "Auth.ValidateAccountSession"
+
+	log := a.log.With(
+		slog.String("op", op),
+	)
+
+	log.Info("validating session")
+
+	session, app, claims, err := a.sessionForToken(ctx, token)
+	if err != nil {
+		log.Error("invalid token", sl.Err(err))
+		return models.SessionValidation{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if a.tokenExpired(claims) {
+		log.Info("token expired")
+		return models.SessionValidation{
+			Valid:     false,
+			ExpiresAt: claims.ExpiresAt,
+		}, nil
+	}
+
+	revoked, reason, err := a.tokenRevoked(ctx, claims)
+	if err != nil {
+		log.Error("failed to check revocation list", sl.Err(err))
+		return models.SessionValidation{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if revoked {
+		log.Info("token revoked", slog.String("reason", string(reason)))
+		return models.SessionValidation{
+			Valid:         false,
+			ExpiresAt:     session.ExpiresAt,
+			RevokedReason: reason,
+		}, nil
+	}
+
+	if session.Revoked {
+		log.Info("session revoked", slog.String("reason", string(session.RevokedReason)))
+		return models.SessionValidation{
+			Valid:         false,
+			ExpiresAt:     session.ExpiresAt,
+			RevokedReason: session.RevokedReason,
+		}, nil
+	}
+
+	if session.ExpiresAt.Before(time.Now()) {
+		log.Info("session expired")
+		return models.SessionValidation{
+			Valid:     false,
+			ExpiresAt: session.ExpiresAt,
+		}, nil
+	}
+
+	account, err := a.accountProvider.AccountById(ctx, session.AccountID)
+	if err != nil {
+		log.Error("failed to get account", sl.Err(err))
+		return models.SessionValidation{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if claims.TokenVersion < account.TokenVersion || claims.AppTokenVersion < app.TokenVersion {
+		log.Info("token version superseded", slog.Int64("token_version", claims.TokenVersion))
+		return models.SessionValidation{
+			Valid:     false,
+			ExpiresAt: session.ExpiresAt,
+		}, nil
+	}
+
+	idle, err := a.checkIdle(ctx, log, session, int32(app.ID), claims.Role)
+	if err != nil {
+		log.Error("failed to revoke idle session", sl.Err(err))
+		return models.SessionValidation{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if idle {
+		return models.SessionValidation{
+			Valid:         false,
+			ExpiresAt:     session.ExpiresAt,
+			RevokedReason: models.RevokedSessionIdle,
+		}, nil
+	}
+
+	bindingMismatch, err := a.checkSessionBinding(ctx, log, session, userAgent, ipAddress)
+	if err != nil {
+		return models.SessionValidation{
+			Valid:           false,
+			ExpiresAt:       session.ExpiresAt,
+			BindingMismatch: true,
+		}, nil
+	}
+
+	if account.Status != models.ACTIVE {
+		// Leniently, a live token outlasts a status flap, but is never renewed.
+		if a.lenientStatusCheck {
+			log.Info("account not active, token valid until expiry", slog.Int("status", int(account.Status)))
+			return models.SessionValidation{
+				Valid:     true,
+				ExpiresAt: claims.ExpiresAt,
+			}, nil
+		}
+
+		log.Info("account not active", slog.Int("status", int(account.Status)))
+		return models.SessionValidation{
+			Valid:     false,
+			ExpiresAt: session.ExpiresAt,
+		}, nil
+	}
+
+	if account.RequiresPasswordChange {
+		log.Info("password change required")
+		return models.SessionValidation{
+			Valid:                  false,
+			ExpiresAt:              session.ExpiresAt,
+			PasswordChangeRequired: true,
+		}, nil
+	}
+
+	log.Info("session is valid")
+
+	validation := models.SessionValidation{
+		Valid:           true,
+		ExpiresAt:       session.ExpiresAt,
+		BindingMismatch: bindingMismatch,
+	}
+
+	if a.rolePermissions != nil {
+		permissions, err := a.permissions(ctx, account, app)
+		if err != nil {
+			log.Error("failed to resolve permissions", sl.Err(err))
+			return models.SessionValidation{}, fmt.Errorf("%s: %w", op, err)
+		}
+		validation.Permissions = permissions
+	}
+
+	if a.renewalWindow <= 0 {
+		return validation, nil
+	}
+
+	renewed, expiresAt, err := a.renewNearExpiry(ctx, session, account, app, claims)
+	if err != nil {
+		log.Error("failed to renew access token", sl.Err(err))
+		return models.SessionValidation{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if renewed != "" {
+		log.Info("access token renewed")
+		validation.RenewedToken = renewed
+		validation.RenewedTokenExpiresAt = expiresAt
+	}
+
+	return validation, nil
+}
+
+// issueSession mints an access and refresh token for account in app and saves them
+// as a new session, starting a new refresh family. rememberMe selects the long
+// refresh token TTL for the whole family.
+func (a *Auth) issueSession(ctx context.Context, log *slog.Logger, account models.Account, app models.App, userAgent string, ipAddress string, rememberMe bool) (models.Session, error) {
+	session, err := a.saveNewSession(ctx, log, account, app, time.Now(), "", userAgent, ipAddress, rememberMe)
+	if err != nil {
+		return models.Session{}, err
+	}
+
+	log.Info("session created", slog.String("session_id", session.SID))
+
+	a.notifyNewDevice(ctx, log, account, app, session.SID, userAgent, ipAddress)
+
+	return session, nil
+}
+
+// maxSessionSaveAttempts bounds how often saveNewSession retries a session that
+// collides with an existing one.
+const maxSessionSaveAttempts = 3
+
+// saveNewSession mints a session ID, access token and refresh token for account in
+// app and saves them, with familyStartedAt as the token's auth_time. On
+// storage.ErrSessionExists it mints all three afresh and tries again, up to
+// maxSessionSaveAttempts times in total. ExpiresAt of the result is the refresh
+// token's expiry, see refreshTTL.
+func (a *Auth) saveNewSession(ctx context.Context, log *slog.Logger, account models.Account, app models.App, familyStartedAt time.Time, familyID string, userAgent string, ipAddress string, rememberMe bool) (models.Session, error) {
+	device := sessionDevice(userAgent)
+
+	if familyID == "" {
+		familyID = a.sessionIDs.NewID()
+	}
+
+	var err error
+	for attempt := 1; attempt <= maxSessionSaveAttempts; attempt++ {
+		sid := a.sessionIDs.NewID()
+
+		var token string
+		token, err = jwt.NewToken(ctx, account, app, a.accessTokenTTL(), familyStartedAt, a.subjectFormat, a.tokenIssuer, sid, a.claimsEnricher)
+		if err != nil {
+			log.Error("failed to generate token", sl.Err(err))
+			return models.Session{}, err
+		}
+
+		expiresAt := time.Now().Add(a.refreshTTL(rememberMe))
+
+		var refreshToken string
+		if a.jwtRefreshTokens {
+			refreshToken, err = jwt.NewRefreshToken(account, app, expiresAt, a.subjectFormat, a.tokenIssuer, sid, familyID)
+		} else {
+			refreshToken, err = a.secrets.Token()
+		}
+		if err != nil {
+			log.Error("failed to generate refresh token", sl.Err(err))
+			return models.Session{}, err
+		}
+
+		sid, err = a.sessionSaver.SaveSession(ctx, sid, account.ID, int32(app.ID), userAgent, ipAddress, device, hashCode(refreshToken), expiresAt, familyStartedAt, familyID, account.Scopes, rememberMe)
+		if err == nil {
+			if err := a.recordAccessToken(ctx, sid, token); err != nil {
+				log.Error("failed to record access token", sl.Err(err))
+				return models.Session{}, err
+			}
+
+			return models.Session{SID: sid, Token: token, RefreshToken: refreshToken, ExpiresAt: expiresAt, FamilyID: familyID, RememberMe: rememberMe}, nil
+		}
+		if !errors.Is(err, storage.ErrSessionExists) {
+			log.Error("failed to save session", sl.Err(err))
+			return models.Session{}, err
+		}
+
+		log.Warn("session collided with an existing one", slog.Int("attempt", attempt))
+	}
+
+	log.Error("failed to save session", sl.Err(err))
+
+	return models.Session{}, err
+}
+
+// revokeReusedFamily revokes every live session of the refresh family of session,
+// whose rotated-out refresh token was presented again, and returns
+// ErrRefreshTokenReused, or the error revoking them.
+func (a *Auth) revokeReusedFamily(ctx context.Context, log *slog.Logger, session models.Session) error {
+	familyID := session.FamilyID
+	if familyID == "" {
+		familyID = session.SID
+	}
+
+	revoked, err := a.sessionSaver.RevokeSessionFamily(ctx, familyID, models.RevokedRefreshReuse)
+	if err != nil {
+		log.Error("failed to revoke refresh family", sl.Err(err))
+		return err
+	}
+
+	log.Warn("rotated refresh token reused, family revoked",
+		slog.String("session_id", session.SID),
+		slog.Int64("revoked", revoked),
+	)
+
+	return ErrRefreshTokenReused
+}
+
+// sessionByRefreshToken returns the session of refreshToken, which is stored
+// hashed. Sessions saved before refresh tokens were hashed are found by the
+// plaintext token until session cleanup hashes it.
+func (a *Auth) sessionByRefreshToken(ctx context.Context, refreshToken string) (models.Session, error) {
+	session, err := a.sessionProvider.SessionByRefreshToken(ctx, hashCode(refreshToken))
+	if errors.Is(err, storage.ErrSessionNotFound) {
+		return a.sessionProvider.SessionByLegacyRefreshToken(ctx, refreshToken)
+	}
+
+	return session, err
+}
+
+// preValidateRefreshToken verifies the signature and expiry of a refresh token
+// issued as a JWT, so forged and expired ones are turned away before the session
+// lookup, and returns its claims. Opaque refresh tokens have nothing to check and
+// return empty claims. Rejected tokens wrap storage.ErrSessionNotFound, like
+// unknown ones.
+func (a *Auth) preValidateRefreshToken(ctx context.Context, refreshToken string) (jwt.RefreshClaims, error) {
+	if !jwt.IsJWT(refreshToken) {
+		return jwt.RefreshClaims{}, nil
+	}
+
+	appID, err := jwt.AppID(refreshToken)
+	if err != nil {
+		return jwt.RefreshClaims{}, fmt.Errorf("%w: %w", storage.ErrSessionNotFound, err)
+	}
+
+	app, err := a.appProvider.App(ctx, int32(appID))
+	if err != nil {
+		if errors.Is(err, storage.ErrAppNotFound) {
+			return jwt.RefreshClaims{}, fmt.Errorf("%w: %w", storage.ErrSessionNotFound, err)
+		}
+		return jwt.RefreshClaims{}, err
+	}
+
+	claims, err := jwt.ParseRefreshToken(refreshToken, app)
+	if err != nil {
+		return jwt.RefreshClaims{}, fmt.Errorf("%w: %w", storage.ErrSessionNotFound, err)
+	}
+
+	return claims, nil
+}
+
+// refreshTTL is how long the refresh token of a session lives: the long TTL set
+// by WithRememberMe for sessions that asked to be remembered, the refresh token
+// TTL otherwise.
+func (a *Auth) refreshTTL(rememberMe bool) time.Duration {
+	if rememberMe && a.rememberMeRefreshTTL > 0 {
+		return a.rememberMeRefreshTTL
+	}
+
+	return a.refreshTokenTTL
+}
+
+// appForLogin returns the app to log in to, or ErrAppNotFound for a non-positive or
+// unknown app ID.
+func (a *Auth) appForLogin(ctx context.Context, appID int32) (models.App, error) {
+	if appID <= 0 {
+		return models.App{}, ErrAppNotFound
+	}
+
+	app, err := a.appProvider.App(ctx, appID)
+	if err != nil {
+		if errors.Is(err, storage.ErrAppNotFound) {
+			return models.App{}, ErrAppNotFound
+		}
+		return models.App{}, err
+	}
+
+	return app, nil
+}
+
+// startSession finishes a login whose credentials were checked: it enforces the
+// session quota, issues the session, revokes the account's other sessions in
+// single-session mode and records the login. Logins that return an ID token pass
+// how they authenticated the account in authn; the ID token is empty otherwise.
+func (a *Auth) startSession(ctx context.Context, log *slog.Logger, account models.Account, app models.App, userAgent string, ipAddress string, rememberMe bool, authn *authentication) (*ssov1.LoginResponse, string, error) {
+	if !a.singleSession {
+		if err := a.enforceSessionQuota(ctx, log, account.ID); err != nil {
+			log.Warn("session quota not satisfied", sl.Err(err))
+			return nil, "", err
+		}
+	}
+
+	session, err := a.issueSession(ctx, log, account, app, userAgent, ipAddress, rememberMe)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var idToken string
+	if authn != nil {
+		idToken, err = a.idToken(ctx, account, app, session.SID, *authn)
+		if err != nil {
+			log.Error("failed to generate id token", sl.Err(err))
+			return nil, "", err
+		}
+	}
+
+	if a.singleSession {
+		if err := a.revokeOtherSessions(ctx, account.ID, session.SID, models.RevokedLoggedOutElsewhere); err != nil {
+			log.Error("failed to revoke previous sessions", sl.Err(err))
+			return nil, "", err
+		}
+	}
+
+	if err := a.accountSaver.UpdateLastLogin(ctx, account.ID, time.Now()); err != nil {
+		log.Warn("failed to record last login", sl.Err(err))
+	}
+
+	return &ssov1.LoginResponse{
+		AccountId:    account.ID,
+		Token:        session.Token,
+		RefreshToken: session.RefreshToken,
+	}, idToken, nil
+}
+
+// sessionAccount loads the account that owns session and the app it was issued for.
+func (a *Auth) sessionAccount(ctx context.Context, session models.Session) (models.Account, models.App, error) {
+	account, err := a.accountProvider.AccountById(ctx, session.AccountID)
+	if err != nil {
+		return models.Account{}, models.App{}, err
+	}
+
+	// Sessions created before per-app memberships have no app recorded.
+	appID := session.AppID
+	if appID == 0 {
+		appID = account.AppId
+	}
+
+	app, err := a.appProvider.App(ctx, appID)
+	if err != nil {
+		return models.Account{}, models.App{}, err
+	}
+
+	return account, app, nil
+}
+
+// renewNearExpiry mints a new access token for session if its current one expires
+// within the renewal window. It returns an empty token when no renewal is due.
+func (a *Auth) renewNearExpiry(ctx context.Context, session models.Session, account models.Account, app models.App, claims jwt.Claims) (string, time.Time, error) {
+	if a.tokenExpired(claims) {
+		return "", time.Time{}, jwt.ErrTokenExpired
+	}
+
+	account, err := a.accountForApp(ctx, account, int32(app.ID))
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	account.Scopes = sessionScopes(session, account.Scopes)
+
+	// Renewal would hand the actor an undelegated token.
+	if claims.Actor != nil || time.Until(claims.ExpiresAt) > a.renewalWindow {
+		return "", time.Time{}, nil
+	}
+
+	ttl := a.accessTokenTTL()
+
+	newToken, err := jwt.NewToken(ctx, account, app, ttl, session.FamilyStartedAt, a.subjectFormat, a.tokenIssuer, session.SID, a.claimsEnricher)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	if err := a.recordAccessToken(ctx, session.SID, newToken); err != nil {
+		return "", time.Time{}, err
+	}
+
+	return newToken, time.Now().Add(ttl), nil
+}
+
+// ChangeAccountStatus changes the status of an account on behalf of actorID and
+// publishes AccountStatusChanged. Setting the current status again is a no-op.
+// Unknown statuses and changes a deleted account can't make return
+// ErrInvalidStatusTransition, like in ChangeStatusBatch.
+// With account deletion configured, DELETED soft-deletes the account like
+// DeleteAccount.
+func (a *Auth) ChangeAccountStatus(ctx context.Context, actorID int64, accountID int64, status models.AccountStatus, reason string) error {
+	const op = "Auth.ChangeStatus"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int64("account_id", accountID),
+		slog.Int64("new_status", int64(status)),
+	)
+
+	log.Info("attempting to change account status")
+
+	account, err := a.accountProvider.AccountById(ctx, accountID)
+	if err != nil {
+		log.Error("failed to get account", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if account.Status == status {
+		log.Info("status unchanged")
+		return nil
+	}
+
+	if err := validateStatusTransition(account.Status, status); err != nil {
+		log.Warn("status transition refused", slog.Int64("old_status", int64(account.Status)), sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if status == models.DELETED && a.deletedAccounts != nil {
+		err = a.softDeleteAccount(ctx, log, accountID, actorID)
+	} else {
+		err = a.accountSaver.UpdateStatus(ctx, accountID, status)
+	}
+	if err != nil {
+		log.Error("failed to change status", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("status changed successfully")
+
+	// Leniently, live tokens outlast the change, see ValidateAccountSessionFrom.
+	if status != models.ACTIVE && !a.lenientStatusCheck {
+		if err := a.revokeAccountTokens(ctx, log, accountID, models.RevokedAccountDisabled); err != nil {
+			log.Error("failed to revoke access tokens", sl.Err(err))
+		}
+	}
+
+	a.publish(ctx, events.AccountStatusChanged{
+		AccountID:  accountID,
+		OldStatus:  account.Status,
+		NewStatus:  status,
+		ActorID:    actorID,
+		Reason:     reason,
+		OccurredAt: time.Now(),
+	})
+
+	return nil
+}
+
+// publish hands event to the configured publisher. Delivery failures are logged but
+// never fail the operation that produced the event.
+func (a *Auth) publish(ctx context.Context, event any) {
+	if a.eventPublisher == nil {
+		return
+	}
+
+	if err := a.eventPublisher.Publish(ctx, event); err != nil {
+		a.log.Error("failed to publish event", slog.String("type", fmt.Sprintf("%T", event)), sl.Err(err))
+	}
+}
+
+// revokeOtherSessions revokes every active session of the account except the one
+// with the ID keepSID and tells each revoked session why via SessionRevoked.
+func (a *Auth) revokeOtherSessions(ctx context.Context, accountID int64, keepSID string, reason models.RevocationReason) error {
+	sessions, err := a.sessionProvider.Sessions(ctx, accountID)
+	if err != nil {
+		return err
+	}
+
+	if err := a.sessionSaver.RevokeAccountSessions(ctx, accountID, keepSID, reason); err != nil {
+		return err
+	}
+
+	for _, session := range sessions {
+		if keepSID != "" && session.SID == keepSID {
+			continue
+		}
+
+		a.publish(ctx, events.SessionRevoked{
+			SessionID:  session.ID,
+			AccountID:  accountID,
+			Reason:     reason,
+			OccurredAt: time.Now(),
+		})
+	}
+
+	return nil
+}
+
+// accessTokenTTL is the lifetime for a newly issued access token: tokenTTL minus a
+// random share of the configured jitter.
+func (a *Auth) accessTokenTTL() time.Duration {
+	if a.tokenTTLJitter <= 0 {
+		return a.tokenTTL
+	}
+
+	return a.tokenTTL - mrand.N(a.tokenTTLJitter+1)
+}
+
+// permissions resolves the permissions of account's current role in app, so a role
+// change shows up on the next validation rather than the next login.
+func (a *Auth) permissions(ctx context.Context, account models.Account, app models.App) ([]string, error) {
+	account, err := a.accountForApp(ctx, account, int32(app.ID))
+	if err != nil {
+		return nil, err
+	}
+
+	permissions := a.rolePermissions[account.Role]
+	if permissions == nil {
+		return []string{}, nil
+	}
 
-	return newToken, newRefreshToken, expiresAt.Unix(), nil
+	return permissions, nil
 }
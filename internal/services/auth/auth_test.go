@@ -0,0 +1,197 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"sso/internal/domain/models"
+	"sso/internal/lib/encryption"
+	"sso/internal/lib/passhash"
+	"sso/internal/storage/sqlite"
+	"sso/internal/storage/sqlite/sqlitetest"
+
+	ssov1 "github.com/dariasmyr/protos/gen/go/sso"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	testPassword  = "correct-Horse-battery-9"
+	testUserAgent = "Mozilla/5.0 (X11; Linux x86_64) Firefox/131.0"
+	testIP        = "203.0.113.7"
+)
+
+// newTestAuth returns the service over fresh migrated storage, hashing passwords
+// at the lowest bcrypt cost to keep tests fast.
+func newTestAuth(t *testing.T, opts ...Option) (*Auth, *sqlite.Storage) {
+	t.Helper()
+
+	a, storage, _ := newTestAuthWithDB(t, opts...)
+
+	return a, storage
+}
+
+// newTestAuthWithDB is newTestAuth that also returns a raw connection to the
+// database, for setting up what the storage has no setter for.
+func newTestAuthWithDB(t *testing.T, opts ...Option) (*Auth, *sqlite.Storage, *sql.DB) {
+	t.Helper()
+
+	keyring, err := encryption.NewKeyring(1, map[byte][]byte{1: bytes.Repeat([]byte{7}, 32)})
+	if err != nil {
+		t.Fatalf("keyring: %v", err)
+	}
+
+	storage, db := sqlitetest.NewWithDB(t, keyring)
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	opts = append([]Option{WithPasswordHasher(passhash.Bcrypt{Cost: bcrypt.MinCost})}, opts...)
+	a := New(log, storage, storage, storage, storage, storage, storage, storage, storage, storage, storage, storage, time.Hour, 24*time.Hour, opts...)
+
+	return a, storage, db
+}
+
+// newTestApp saves an app and returns its ID.
+func newTestApp(t *testing.T, storage *sqlite.Storage) int32 {
+	t.Helper()
+
+	id, err := storage.SaveApp(context.Background(), fmt.Sprintf("app-%d", time.Now().UnixNano()), fmt.Sprintf("secret-%d", time.Now().UnixNano()), "")
+	if err != nil {
+		t.Fatalf("save app: %v", err)
+	}
+
+	return int32(id)
+}
+
+// registerTestAccount self-registers email with the app and returns its ID.
+func registerTestAccount(t *testing.T, a *Auth, appID int32, email string) int64 {
+	t.Helper()
+
+	resp, err := a.Register(context.Background(), &ssov1.RegisterRequest{Email: email, Password: testPassword, AppId: appID})
+	if err != nil {
+		t.Fatalf("register %s: %v", email, err)
+	}
+
+	return resp.GetAccountId()
+}
+
+// newTestAdmin saves an admin account in the app and returns its ID.
+func newTestAdmin(t *testing.T, storage *sqlite.Storage, appID int32) int64 {
+	t.Helper()
+
+	id, err := storage.SaveAccount(context.Background(), "admin@example.com", []byte("hash"), models.ADMIN, models.ACTIVE, appID, "", "", "")
+	if err != nil {
+		t.Fatalf("save admin: %v", err)
+	}
+
+	return id
+}
+
+// loginTestAccount logs email in to the app and returns the response.
+func loginTestAccount(t *testing.T, a *Auth, appID int32, email string) *ssov1.LoginResponse {
+	t.Helper()
+
+	resp, err := a.Login(context.Background(), &ssov1.LoginRequest{Email: email, Password: testPassword, AppId: appID, UserAgent: testUserAgent, IpAddress: testIP})
+	if err != nil {
+		t.Fatalf("login %s: %v", email, err)
+	}
+
+	return resp
+}
+
+func TestRegisterIgnoresRequestedRole(t *testing.T) {
+	tests := []struct {
+		name string
+		role models.AccountRole
+	}{
+		{"user", models.USER},
+		{"admin", models.ADMIN},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			a, storage := newTestAuth(t)
+			appID := newTestApp(t, storage)
+
+			resp, err := a.Register(ctx, &ssov1.RegisterRequest{Email: "user@example.com", Password: testPassword, AppId: appID, Role: ssov1.AccountRole(tt.role)})
+			if err != nil {
+				t.Fatalf("register: %v", err)
+			}
+
+			account, err := storage.AccountById(ctx, resp.GetAccountId())
+			if err != nil {
+				t.Fatalf("account: %v", err)
+			}
+			account, err = a.accountForApp(ctx, account, appID)
+			if err != nil {
+				t.Fatalf("account for app: %v", err)
+			}
+
+			if account.Role != models.USER {
+				t.Errorf("role = %v, want USER", account.Role)
+			}
+			if err := a.requireAdmin(ctx, account.ID); err == nil {
+				t.Error("self-registered account passed the admin check")
+			}
+		})
+	}
+}
+
+func TestAccessTokenTTL(t *testing.T) {
+	tests := []struct {
+		name    string
+		jitter  time.Duration
+		wantMin time.Duration
+	}{
+		{name: "no jitter", jitter: 0, wantMin: time.Hour},
+		{name: "negative jitter", jitter: -time.Minute, wantMin: time.Hour},
+		{name: "jitter", jitter: 10 * time.Minute, wantMin: 50 * time.Minute},
+		{name: "jitter not below ttl", jitter: time.Hour, wantMin: time.Hour},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, _ := newTestAuth(t, WithTokenTTLJitter(tt.jitter))
+
+			for range 100 {
+				if got := a.accessTokenTTL(); got < tt.wantMin || got > time.Hour {
+					t.Fatalf("accessTokenTTL = %v, want within [%v, %v]", got, tt.wantMin, time.Hour)
+				}
+			}
+		})
+	}
+}
+
+func TestLoginRejectsInvalidAppID(t *testing.T) {
+	tests := []struct {
+		name     string
+		appID    func(appID int32) int32
+		password string
+		wantErr  error
+	}{
+		{name: "zero", appID: func(int32) int32 { return 0 }, password: testPassword, wantErr: ErrAppNotFound},
+		{name: "negative", appID: func(int32) int32 { return -1 }, password: testPassword, wantErr: ErrAppNotFound},
+		{name: "unknown", appID: func(appID int32) int32 { return appID + 100 }, password: testPassword, wantErr: ErrAppNotFound},
+		{name: "unknown with wrong password", appID: func(appID int32) int32 { return appID + 100 }, password: "wrong-Password-1", wantErr: ErrAppNotFound},
+		{name: "valid", appID: func(appID int32) int32 { return appID }, password: testPassword},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, storage := newTestAuth(t)
+			appID := newTestApp(t, storage)
+			registerTestAccount(t, a, appID, "user@example.com")
+
+			_, err := a.Login(context.Background(), &ssov1.LoginRequest{Email: "user@example.com", Password: tt.password, AppId: tt.appID(appID), UserAgent: testUserAgent, IpAddress: testIP})
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("login error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
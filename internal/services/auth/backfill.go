@@ -0,0 +1,48 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+)
+
+// LegacyRefreshTokenStore hashes refresh tokens saved in plaintext, from before
+// refresh tokens were hashed.
+type LegacyRefreshTokenStore interface {
+	HashLegacyRefreshTokens(ctx context.Context, hash func(refreshToken string) string, limit int) (hashed int64, err error)
+	CountLegacyRefreshTokens(ctx context.Context) (count int64, err error)
+}
+
+// HashLegacyRefreshTokens hashes every refresh token store still keeps in
+// plaintext, batchSize sessions per transaction, and returns how many it hashed.
+// Each session is marked as migrated in the transaction that hashes its token, so
+// hashed sessions are left alone and a run cut short is simply repeated. It fails
+// if plaintext tokens remain afterwards. Session cleanup hashes them gradually as
+// well; this migrates them all at once, see cmd/hashrefreshtokens.
+func HashLegacyRefreshTokens(ctx context.Context, store LegacyRefreshTokenStore, batchSize int) (int64, error) {
+	const op = "auth.HashLegacyRefreshTokens"
+
+	if batchSize <= 0 {
+		batchSize = defaultCleanupBatchSize
+	}
+
+	var hashed int64
+	err := deleteInBatches(ctx, batchSize, &hashed, func(ctx context.Context) (int64, error) {
+		return store.HashLegacyRefreshTokens(ctx, hashCode, batchSize)
+	})
+	if err != nil {
+		return hashed, fmt.Errorf("%s: %w", op, err)
+	}
+	if err := ctx.Err(); err != nil {
+		return hashed, fmt.Errorf("%s: %w", op, err)
+	}
+
+	left, err := store.CountLegacyRefreshTokens(ctx)
+	if err != nil {
+		return hashed, fmt.Errorf("%s: %w", op, err)
+	}
+	if left > 0 {
+		return hashed, fmt.Errorf("%s: %d refresh tokens still in plaintext", op, left)
+	}
+
+	return hashed, nil
+}
@@ -0,0 +1,88 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"sso/internal/domain/models"
+	"sso/internal/storage/sqlite/sqlitetest"
+)
+
+func TestHashLegacyRefreshTokens(t *testing.T) {
+	tests := []struct {
+		name      string
+		legacy    int
+		hashed    int
+		batchSize int
+	}{
+		{name: "nothing to migrate", hashed: 3, batchSize: 2},
+		{name: "one batch", legacy: 2, hashed: 3, batchSize: 10},
+		{name: "exact batches", legacy: 4, hashed: 3, batchSize: 2},
+		{name: "partial last batch", legacy: 5, hashed: 3, batchSize: 2},
+		{name: "default batch size", legacy: 5, hashed: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			st, db := sqlitetest.NewWithDB(t, nil)
+
+			appID, err := st.SaveApp(ctx, "app", "secret", "")
+			if err != nil {
+				t.Fatalf("save app: %v", err)
+			}
+			accountID, err := st.SaveAccount(ctx, "user@example.com", []byte("hash"), models.USER, models.ACTIVE, int32(appID), "", "", "")
+			if err != nil {
+				t.Fatalf("save account: %v", err)
+			}
+
+			// save stores token as is; legacy marks it as a plaintext token.
+			save := func(sid string, token string, legacy bool) {
+				if _, err := st.SaveSession(ctx, sid, accountID, int32(appID), "", "", models.SessionDevice{}, token, time.Now().Add(time.Hour), time.Now(), sid, nil, false); err != nil {
+					t.Fatalf("save session: %v", err)
+				}
+				if legacy {
+					if _, err := db.ExecContext(ctx, "UPDATE sessions SET refresh_token_hashed = 0 WHERE sid = ?", sid); err != nil {
+						t.Fatalf("mark session legacy: %v", err)
+					}
+				}
+			}
+			for i := range tt.hashed {
+				save(fmt.Sprintf("hashed-%d", i), hashCode(fmt.Sprintf("hashed-token-%d", i)), false)
+			}
+			for i := range tt.legacy {
+				save(fmt.Sprintf("legacy-%d", i), fmt.Sprintf("legacy-token-%d", i), true)
+			}
+
+			hashed, err := HashLegacyRefreshTokens(ctx, st, tt.batchSize)
+			if err != nil {
+				t.Fatalf("migrate: %v", err)
+			}
+			if hashed != int64(tt.legacy) {
+				t.Errorf("hashed = %d, want %d", hashed, tt.legacy)
+			}
+
+			// Every session, migrated or not, is found by the hash of its token.
+			for prefix, count := range map[string]int{"hashed": tt.hashed, "legacy": tt.legacy} {
+				for i := range count {
+					sid := fmt.Sprintf("%s-%d", prefix, i)
+					session, err := st.SessionByRefreshToken(ctx, hashCode(fmt.Sprintf("%s-token-%d", prefix, i)))
+					if err != nil || session.SID != sid {
+						t.Errorf("session %s = %q, %v", sid, session.SID, err)
+					}
+				}
+			}
+
+			// A second run finds nothing left and changes nothing.
+			again, err := HashLegacyRefreshTokens(ctx, st, tt.batchSize)
+			if err != nil {
+				t.Fatalf("second run: %v", err)
+			}
+			if again != 0 {
+				t.Errorf("second run hashed %d, want 0", again)
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"sso/internal/domain/events"
+	"sso/internal/domain/models"
+	"sso/internal/lib/useragent"
+)
+
+var ErrSessionBindingMismatch = errors.New("session used from another client")
+
+// SessionBindingPolicy is what ValidateAccountSessionFrom and RefreshAccountSession
+// do when the caller's IP or user agent doesn't match the session's.
+type SessionBindingPolicy string
+
+const (
+	// BindingOff doesn't compare the caller with the session. It is the default.
+	BindingOff SessionBindingPolicy = "off"
+	// BindingFlag lets mismatches through, marks validations with BindingMismatch
+	// and publishes SessionBindingMismatch.
+	BindingFlag SessionBindingPolicy = "flag"
+	// BindingReject refuses mismatches: validation reports the session invalid and
+	// refresh fails with ErrSessionBindingMismatch. SessionBindingMismatch is
+	// published with Rejected set.
+	BindingReject SessionBindingPolicy = "reject"
+)
+
+// SessionBinding binds sessions to the client they were created from. With IP the
+// caller must be in the subnet the session was last used from, as for the new-IP
+// refresh policy; with UserAgent it must be on the same device fingerprint, so
+// browser and OS updates don't count as a mismatch.
+type SessionBinding struct {
+	Policy    SessionBindingPolicy
+	IP        bool
+	UserAgent bool
+}
+
+func (b SessionBinding) enabled() bool {
+	return (b.Policy == BindingFlag || b.Policy == BindingReject) && (b.IP || b.UserAgent)
+}
+
+// Attributes a session can be bound to, as reported in SessionBindingMismatch.
+const (
+	bindingIP        = "ip"
+	bindingUserAgent = "user_agent"
+)
+
+// bindingMismatches returns which bound attributes of the caller differ from the
+// session's. Attributes unknown on either side are not compared.
+func (b SessionBinding) bindingMismatches(session models.Session, userAgent string, ipAddress string) []string {
+	var mismatches []string
+
+	if b.IP && session.IPAddress != "" && ipAddress != "" && !sameSubnet(session.IPAddress, ipAddress) {
+		mismatches = append(mismatches, bindingIP)
+	}
+
+	if b.UserAgent && session.DeviceID != "" && userAgent != "" && useragent.Fingerprint(userAgent) != session.DeviceID {
+		mismatches = append(mismatches, bindingUserAgent)
+	}
+
+	return mismatches
+}
+
+// checkSessionBinding applies the session binding policy to a caller using
+// session. It reports whether the caller mismatched, and returns
+// ErrSessionBindingMismatch if the policy rejects it.
+func (a *Auth) checkSessionBinding(ctx context.Context, log *slog.Logger, session models.Session, userAgent string, ipAddress string) (bool, error) {
+	if !a.sessionBinding.enabled() {
+		return false, nil
+	}
+
+	mismatches := a.sessionBinding.bindingMismatches(session, userAgent, ipAddress)
+	if len(mismatches) == 0 {
+		return false, nil
+	}
+
+	rejected := a.sessionBinding.Policy == BindingReject
+
+	log.Warn("session used from another client",
+		slog.Any("mismatches", mismatches),
+		slog.String("ip", ipAddress),
+		slog.Bool("rejected", rejected),
+	)
+
+	a.publish(ctx, events.SessionBindingMismatch{
+		SessionID:  session.ID,
+		AccountID:  session.AccountID,
+		Mismatches: mismatches,
+		PreviousIP: session.IPAddress,
+		IPAddress:  ipAddress,
+		UserAgent:  userAgent,
+		Rejected:   rejected,
+		OccurredAt: time.Now(),
+	})
+
+	if rejected {
+		return true, ErrSessionBindingMismatch
+	}
+
+	return true, nil
+}
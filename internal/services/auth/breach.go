@@ -0,0 +1,45 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"sso/internal/lib/logger/sl"
+)
+
+var ErrBreachedPassword = errors.New("password appears in a known data breach")
+
+// checkBreachedPassword looks password up in known breaches. In enforce mode a
+// breached password returns ErrBreachedPassword; otherwise it is only logged. A
+// lookup that fails or runs past the timeout lets the password through, so an
+// outage of the breach service never blocks registration or password changes.
+func (a *Auth) checkBreachedPassword(ctx context.Context, log *slog.Logger, password string) error {
+	if a.breachChecker == nil {
+		return nil
+	}
+
+	if a.breachTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, a.breachTimeout)
+		defer cancel()
+	}
+
+	breached, err := a.breachChecker.Breached(ctx, password)
+	if err != nil {
+		log.Warn("breached password check failed, allowing password", sl.Err(err))
+		return nil
+	}
+	if !breached {
+		return nil
+	}
+
+	if !a.enforceBreachCheck {
+		log.Warn("password appears in a known data breach")
+		return nil
+	}
+
+	log.Info("password rejected as breached")
+
+	return ErrBreachedPassword
+}
@@ -0,0 +1,66 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"sso/internal/lib/logger/sl"
+)
+
+var ErrCaptchaRequired = errors.New("captcha required")
+
+// checkCaptcha verifies captchaToken, if one is given, and demands a valid one when
+// always is set or the identifier or client IP was flagged by flagForCaptcha,
+// returning ErrCaptchaRequired without it. passed tells whether a token was
+// verified, for checks further on that may ask for one. Without a verifier it does
+// nothing.
+func (a *Auth) checkCaptcha(ctx context.Context, log *slog.Logger, identifier string, ipAddress string, captchaToken string, always bool) (passed bool, err error) {
+	if a.captcha == nil {
+		return false, nil
+	}
+
+	required := always ||
+		(identifier != "" && a.captchaFlags.Flagged(identifier)) ||
+		(ipAddress != "" && a.captchaFlags.Flagged(ipLockoutKey(ipAddress)))
+
+	if captchaToken == "" {
+		if required {
+			log.Info("captcha required")
+			return false, ErrCaptchaRequired
+		}
+		return false, nil
+	}
+
+	ok, err := a.captcha.Verify(ctx, captchaToken, ipAddress)
+	if err != nil {
+		// Only a request that needs the CAPTCHA fails with the provider.
+		if required {
+			log.Error("failed to verify captcha", sl.Err(err))
+			return false, err
+		}
+		log.Warn("failed to verify captcha", sl.Err(err))
+		return false, nil
+	}
+
+	if !ok {
+		log.Info("invalid captcha")
+		if required {
+			return false, ErrCaptchaRequired
+		}
+		return false, nil
+	}
+
+	return true, nil
+}
+
+// flagForCaptcha makes the next attempts of identifier and from ipAddress solve a
+// CAPTCHA until the flags run out.
+func (a *Auth) flagForCaptcha(identifier string, ipAddress string) {
+	if identifier != "" {
+		a.captchaFlags.Flag(identifier)
+	}
+	if ipAddress != "" {
+		a.captchaFlags.Flag(ipLockoutKey(ipAddress))
+	}
+}
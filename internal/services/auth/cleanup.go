@@ -0,0 +1,133 @@
+package auth
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+	"log/slog"
+	"sso/internal/lib/logger/sl"
+	"time"
+)
+
+const defaultCleanupBatchSize = 500
+
+// cleanupPurged counts the rows cleanup runs deleted since start, by kind. It is
+// published with the other expvars at /debug/vars wherever that is served.
+var cleanupPurged = expvar.NewMap("sso_cleanup_purged")
+
+// CleanupStats counts the rows one cleanup run deleted, or revoked for idle sessions
+// and hashed for legacy refresh tokens.
+type CleanupStats struct {
+	IdleSessions    int64
+	ExpiredSessions int64
+	RevokedSessions int64
+	OneTimeCodes    int64
+	SSOTickets      int64
+	RevokeTokens    int64
+	// LegacyRefreshTokens counts plaintext refresh tokens hashed.
+	LegacyRefreshTokens int64
+	SigningKeys         int64
+	RevokedTokens       int64
+}
+
+// cleanupStep deletes one kind of row dated before a cutoff, limit at a time.
+type cleanupStep struct {
+	name    string
+	deleted *int64
+	delete  func(ctx context.Context, before time.Time, limit int) (int64, error)
+	before  time.Time
+}
+
+// Cleanup revokes sessions left unused for longer than the idle timeout, deletes
+// sessions whose refresh token has expired, sessions revoked more than
+// revokedRetention ago, expired one-time codes, SSO tickets and device revoke
+// tokens, retired signing keys, and revocation list entries of expired tokens.
+// Idle sessions are only swept when every session has an idle timeout, and then
+// by the longest one; shorter per-app and per-role timeouts are still enforced
+// when the session is next used. Refresh tokens stored in plaintext, from before
+// they were hashed, are hashed too.
+// Revoked sessions are kept for a while so a signed-out client can still learn
+// why; zero retention deletes them on the first run. Rows go batchSize per
+// statement so no single delete holds a table for long. A run cut short by ctx
+// simply leaves the remaining rows to the next one.
+func (a *Auth) Cleanup(ctx context.Context, batchSize int, revokedRetention time.Duration) (CleanupStats, error) {
+	const op = "Auth.Cleanup"
+
+	log := a.log.With(
+		slog.String("op", op),
+	)
+
+	if batchSize <= 0 {
+		batchSize = defaultCleanupBatchSize
+	}
+
+	now := time.Now()
+
+	var stats CleanupStats
+	var steps []cleanupStep
+	if cutoff, ok := a.idlePolicy.sweepCutoff(); ok {
+		steps = append(steps, cleanupStep{"idle_sessions", &stats.IdleSessions, a.sessionSaver.RevokeIdleSessions, now.Add(-cutoff)})
+	}
+	steps = append(steps, []cleanupStep{
+		{"expired_sessions", &stats.ExpiredSessions, a.sessionSaver.DeleteExpiredSessions, now},
+		{"revoked_sessions", &stats.RevokedSessions, a.sessionSaver.DeleteRevokedSessions, now.Add(-revokedRetention)},
+		{"one_time_codes", &stats.OneTimeCodes, a.oneTimeCodeStore.DeleteExpiredOneTimeCodes, now},
+		{"sso_tickets", &stats.SSOTickets, a.ssoTicketStore.DeleteExpiredSSOTickets, now},
+		{"signing_keys", &stats.SigningKeys, a.appSaver.DeleteRetiredSigningKeys, now},
+	}...)
+	// Expired sessions are gone by now, so only live refresh tokens get hashed.
+	steps = append(steps, cleanupStep{"legacy_refresh_tokens", &stats.LegacyRefreshTokens, func(ctx context.Context, _ time.Time, limit int) (int64, error) {
+		return a.sessionSaver.HashLegacyRefreshTokens(ctx, hashCode, limit)
+	}, now})
+	if a.knownDevices != nil {
+		steps = append(steps, cleanupStep{"device_revoke_tokens", &stats.RevokeTokens, a.knownDevices.DeleteExpiredDeviceRevokeTokens, now})
+	}
+	if a.revokedTokens != nil {
+		steps = append(steps, cleanupStep{"revoked_tokens", &stats.RevokedTokens, a.revokedTokens.DeleteExpiredRevokedTokens, now})
+	}
+
+	for _, step := range steps {
+		err := deleteInBatches(ctx, batchSize, step.deleted, func(ctx context.Context) (int64, error) {
+			return step.delete(ctx, step.before, batchSize)
+		})
+		cleanupPurged.Add(step.name, *step.deleted)
+		if err != nil {
+			log.Error("cleanup failed", sl.Err(err), slog.String("step", step.name), slog.Int64("deleted", *step.deleted))
+			return stats, fmt.Errorf("%s: %s: %w", op, step.name, err)
+		}
+	}
+
+	if stats != (CleanupStats{}) {
+		log.Info("cleanup done",
+			slog.Int64("idle_sessions", stats.IdleSessions),
+			slog.Int64("expired_sessions", stats.ExpiredSessions),
+			slog.Int64("revoked_sessions", stats.RevokedSessions),
+			slog.Int64("one_time_codes", stats.OneTimeCodes),
+			slog.Int64("sso_tickets", stats.SSOTickets),
+			slog.Int64("device_revoke_tokens", stats.RevokeTokens),
+			slog.Int64("legacy_refresh_tokens", stats.LegacyRefreshTokens),
+			slog.Int64("signing_keys", stats.SigningKeys),
+			slog.Int64("revoked_tokens", stats.RevokedTokens),
+		)
+	}
+
+	return stats, nil
+}
+
+// deleteInBatches calls del until it deletes fewer than batchSize rows or ctx is
+// done, adding up the deleted rows in total.
+func deleteInBatches(ctx context.Context, batchSize int, total *int64, del func(ctx context.Context) (int64, error)) error {
+	for ctx.Err() == nil {
+		deleted, err := del(ctx)
+		if err != nil {
+			return err
+		}
+
+		*total += deleted
+		if deleted < int64(batchSize) {
+			break
+		}
+	}
+
+	return nil
+}
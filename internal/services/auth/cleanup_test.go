@@ -0,0 +1,64 @@
+package auth
+
+import (
+	"context"
+	"testing"
+)
+
+func TestDeleteInBatches(t *testing.T) {
+	tests := []struct {
+		name      string
+		rows      int64
+		batchSize int
+		wantCalls int
+	}{
+		{name: "nothing to delete", rows: 0, batchSize: 10, wantCalls: 1},
+		{name: "less than a batch", rows: 3, batchSize: 10, wantCalls: 1},
+		{name: "exact batches", rows: 20, batchSize: 10, wantCalls: 3},
+		{name: "partial last batch", rows: 25, batchSize: 10, wantCalls: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			remaining := tt.rows
+			var calls int
+			var total int64
+
+			err := deleteInBatches(context.Background(), tt.batchSize, &total, func(context.Context) (int64, error) {
+				calls++
+				deleted := min(remaining, int64(tt.batchSize))
+				remaining -= deleted
+				return deleted, nil
+			})
+			if err != nil {
+				t.Fatalf("delete: %v", err)
+			}
+
+			if total != tt.rows {
+				t.Errorf("deleted %d rows, want %d", total, tt.rows)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("%d batches, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestDeleteInBatchesStopsWhenCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var calls int
+	var total int64
+	err := deleteInBatches(ctx, 10, &total, func(context.Context) (int64, error) {
+		calls++
+		cancel()
+		return 10, nil
+	})
+	if err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+
+	if calls != 1 {
+		t.Errorf("%d batches after cancellation, want 1", calls)
+	}
+}
@@ -0,0 +1,108 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sso/internal/domain/models"
+	"sso/internal/lib/logger/sl"
+	"sso/internal/storage"
+	"time"
+)
+
+var (
+	ErrCodeAlreadyUsed = errors.New("code already used")
+	ErrInvalidCode     = errors.New("invalid or expired code")
+)
+
+// OneTimeCodeStore keeps hashes of single-use codes. ConsumeOneTimeCode must be
+// atomic: of concurrent calls with the same code at most one may succeed.
+type OneTimeCodeStore interface {
+	SaveOneTimeCode(ctx context.Context, accountId int64, purpose models.CodePurpose, codeHash string, expiresAt time.Time) error
+	CheckOneTimeCode(ctx context.Context, accountId int64, purpose models.CodePurpose, codeHash string, now time.Time) error
+	ConsumeOneTimeCode(ctx context.Context, accountId int64, purpose models.CodePurpose, codeHash string, now time.Time) error
+	DeleteExpiredOneTimeCodes(ctx context.Context, before time.Time, limit int) (deleted int64, err error)
+}
+
+const otpDigits = 6
+
+// IssueOneTimeCode creates a single-use code for the account, replacing any earlier
+// code for the same purpose. OTPs and SMS login codes are short numeric codes,
+// magic-link codes are long URL-safe tokens. Only a hash of the code is stored.
+func (a *Auth) IssueOneTimeCode(ctx context.Context, accountID int64, purpose models.CodePurpose, ttl time.Duration) (string, error) {
+	const op = "Auth.IssueOneTimeCode"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int64("account_id", accountID),
+		slog.String("purpose", string(purpose)),
+	)
+
+	var (
+		code string
+		err  error
+	)
+	if purpose == models.CodePurposeOTP || purpose == models.CodePurposeSMSLogin {
+		code, err = a.secrets.NumericCode(otpDigits)
+	} else {
+		code, err = a.secrets.Token()
+	}
+	if err != nil {
+		log.Error("failed to generate code", sl.Err(err))
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.oneTimeCodeStore.SaveOneTimeCode(ctx, accountID, purpose, hashCode(code), time.Now().Add(ttl)); err != nil {
+		log.Error("failed to save code", sl.Err(err))
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return code, nil
+}
+
+// ConsumeOneTimeCode accepts a code exactly once. A code that was already accepted
+// returns ErrCodeAlreadyUsed; unknown and expired codes return ErrInvalidCode.
+func (a *Auth) ConsumeOneTimeCode(ctx context.Context, accountID int64, purpose models.CodePurpose, code string) error {
+	const op = "Auth.ConsumeOneTimeCode"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int64("account_id", accountID),
+		slog.String("purpose", string(purpose)),
+	)
+
+	err := a.oneTimeCodeStore.ConsumeOneTimeCode(ctx, accountID, purpose, hashCode(code), time.Now())
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, storage.ErrCodeUsed):
+		log.Warn("code replayed")
+		return fmt.Errorf("%s: %w", op, ErrCodeAlreadyUsed)
+	case errors.Is(err, storage.ErrCodeNotFound):
+		log.Info("invalid code")
+		return fmt.Errorf("%s: %w", op, ErrInvalidCode)
+	default:
+		log.Error("failed to consume code", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+}
+
+// checkOneTimeCode is ConsumeOneTimeCode without consuming the code, for callers
+// that validate more input before acting on it. Used, unknown and expired codes
+// all return ErrInvalidCode.
+func (a *Auth) checkOneTimeCode(ctx context.Context, accountID int64, purpose models.CodePurpose, code string) error {
+	err := a.oneTimeCodeStore.CheckOneTimeCode(ctx, accountID, purpose, hashCode(code), time.Now())
+	if errors.Is(err, storage.ErrCodeUsed) || errors.Is(err, storage.ErrCodeNotFound) {
+		return ErrInvalidCode
+	}
+
+	return err
+}
+
+func hashCode(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,67 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"sso/internal/domain/models"
+)
+
+func TestConsumeOneTimeCodeConcurrently(t *testing.T) {
+	const submissions = 8
+
+	tests := []struct {
+		name    string
+		purpose models.CodePurpose
+	}{
+		{name: "otp", purpose: models.CodePurposeOTP},
+		{name: "magic link", purpose: models.CodePurposeMagicLink},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			a, st := newTestAuth(t)
+			appID := newTestApp(t, st)
+			accountID := registerTestAccount(t, a, appID, "user@example.com")
+
+			code, err := a.IssueOneTimeCode(ctx, accountID, tt.purpose, time.Minute)
+			if err != nil {
+				t.Fatalf("issue code: %v", err)
+			}
+
+			var (
+				start sync.WaitGroup
+				done  sync.WaitGroup
+				errs  = make([]error, submissions)
+			)
+			start.Add(1)
+			for i := range submissions {
+				done.Add(1)
+				go func() {
+					defer done.Done()
+					start.Wait()
+					errs[i] = a.ConsumeOneTimeCode(ctx, accountID, tt.purpose, code)
+				}()
+			}
+			start.Done()
+			done.Wait()
+
+			var succeeded int
+			for _, err := range errs {
+				switch {
+				case err == nil:
+					succeeded++
+				case !errors.Is(err, ErrCodeAlreadyUsed):
+					t.Errorf("losing submission error = %v, want %v", err, ErrCodeAlreadyUsed)
+				}
+			}
+			if succeeded != 1 {
+				t.Errorf("%d of %d submissions succeeded, want exactly 1", succeeded, submissions)
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package auth
+
+import (
+	"errors"
+	"log/slog"
+	"sso/internal/lib/logger/sl"
+	"time"
+)
+
+// credentialFailure is the internal cause behind an ErrInvalidCredentials. It is
+// for logs and metrics only and never reaches the client.
+type credentialFailure string
+
+const (
+	failureUnknownAccount credentialFailure = "unknown_account"
+	failureBadPassword    credentialFailure = "bad_password"
+	failureTarpitted      credentialFailure = "tarpitted"
+	failureLockedOut      credentialFailure = "locked_out"
+	// failureBadSecondFactor is a correct password with a wrong TOTP or recovery code.
+	failureBadSecondFactor credentialFailure = "bad_second_factor"
+	// failureBadSMSCode is a wrong, used or expired SMS login code.
+	failureBadSMSCode credentialFailure = "bad_sms_code"
+)
+
+// credentialError is an ErrInvalidCredentials carrying why and when the check failed.
+// It prints and matches exactly like ErrInvalidCredentials, so the gRPC layer keeps
+// returning the same uniform error.
+type credentialError struct {
+	reason credentialFailure
+	at     time.Time
+}
+
+func (e *credentialError) Error() string {
+	return ErrInvalidCredentials.Error()
+}
+
+func (e *credentialError) Unwrap() error {
+	return ErrInvalidCredentials
+}
+
+func invalidCredentials(reason credentialFailure) error {
+	return &credentialError{reason: reason, at: time.Now()}
+}
+
+// CredentialFailureReason reports the internal cause of an ErrInvalidCredentials and
+// when it happened, for audit logging and metrics. ok is false for any other error.
+func CredentialFailureReason(err error) (reason string, at time.Time, ok bool) {
+	var credErr *credentialError
+	if !errors.As(err, &credErr) {
+		return "", time.Time{}, false
+	}
+
+	return string(credErr.reason), credErr.at, true
+}
+
+// logCredentialFailure logs a failed credential check with its internal reason.
+func logCredentialFailure(log *slog.Logger, err error) {
+	reason, at, ok := CredentialFailureReason(err)
+	if !ok {
+		log.Warn("login failed", sl.Err(err))
+		return
+	}
+
+	log.Info("invalid credentials",
+		slog.String("reason", reason),
+		slog.Time("failed_at", at),
+	)
+}
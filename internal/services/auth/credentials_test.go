@@ -0,0 +1,47 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestCredentialFailureReason(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantReason string
+		wantOK     bool
+	}{
+		{name: "bad password", err: invalidCredentials(failureBadPassword), wantReason: "bad_password", wantOK: true},
+		{name: "wrapped", err: fmt.Errorf("Auth.Login: %w", invalidCredentials(failureUnknownAccount)), wantReason: "unknown_account", wantOK: true},
+		{name: "plain sentinel", err: ErrInvalidCredentials},
+		{name: "other error", err: ErrAccountLocked},
+		{name: "nil", err: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := time.Now()
+			reason, at, ok := CredentialFailureReason(tt.err)
+			if ok != tt.wantOK || reason != tt.wantReason {
+				t.Fatalf("CredentialFailureReason = %q, %v; want %q, %v", reason, ok, tt.wantReason, tt.wantOK)
+			}
+			if ok && (at.IsZero() || at.After(time.Now()) || at.Before(before.Add(-time.Second))) {
+				t.Errorf("failure time = %v, want about now", at)
+			}
+		})
+	}
+}
+
+func TestCredentialErrorMatchesSentinel(t *testing.T) {
+	err := fmt.Errorf("Auth.Login: %w", invalidCredentials(failureTarpitted))
+
+	if !errors.Is(err, ErrInvalidCredentials) {
+		t.Error("credential error doesn't match ErrInvalidCredentials")
+	}
+	if got, want := err.Error(), "Auth.Login: "+ErrInvalidCredentials.Error(); got != want {
+		t.Errorf("message = %q, want %q", got, want)
+	}
+}
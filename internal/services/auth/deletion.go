@@ -0,0 +1,223 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"sso/internal/domain/events"
+	"sso/internal/domain/models"
+	"sso/internal/lib/logger/sl"
+	"sso/internal/storage"
+)
+
+var (
+	ErrAccountDeletionDisabled = errors.New("account deletion is not configured")
+	ErrRestoreWindowExpired    = errors.New("account can no longer be restored")
+)
+
+const defaultPurgeBatchSize = 100
+
+// DeletedAccountStore soft-deletes accounts and finds and restores them until they
+// are purged, which is AccountSaver.DeleteAccount.
+type DeletedAccountStore interface {
+	SoftDeleteAccount(ctx context.Context, accountId int64, anonymizedEmail string, at time.Time) error
+	DeletedAccount(ctx context.Context, accountId int64) (email string, deletedAt time.Time, err error)
+	RestoreDeletedAccount(ctx context.Context, accountId int64) error
+	DeletedAccounts(ctx context.Context, before time.Time, limit int) ([]int64, error)
+}
+
+// anonymizedEmail is what a deleted account's email is replaced with. The .invalid
+// TLD can't be registered, so it never collides with a real address.
+func anonymizedEmail(accountID int64) string {
+	return fmt.Sprintf("deleted-%d@account.invalid", accountID)
+}
+
+// DeleteAccount deletes the account owning the presented access token at its
+// holder's request. Returns ErrReauthRequired if recent authentication is required
+// for account management and the caller hasn't. See softDeleteAccount.
+func (a *Auth) DeleteAccount(ctx context.Context, token string) error {
+	const op = "Auth.DeleteAccount"
+
+	log := a.log.With(
+		slog.String("op", op),
+	)
+
+	if a.deletedAccounts == nil {
+		return fmt.Errorf("%s: %w", op, ErrAccountDeletionDisabled)
+	}
+
+	session, err := a.CurrentSession(ctx, token)
+	if err != nil {
+		log.Info("invalid session", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log = log.With(slog.Int64("account_id", session.AccountID))
+
+	if err := a.requireSensitiveAuth(ctx, token); err != nil {
+		log.Info("recent authentication required", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.softDeleteAccount(ctx, log, session.AccountID, session.AccountID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// softDeleteAccount moves the account to DELETED on behalf of actorID: its email is
+// anonymized, which frees the address, every token it holds is invalidated and its
+// sessions are revoked with RevokedAccountDeleted. It can be restored with
+// RestoreAccount until the purge job deletes it for good after the retention period.
+func (a *Auth) softDeleteAccount(ctx context.Context, log *slog.Logger, accountID int64, actorID int64) error {
+	now := time.Now()
+	if err := a.deletedAccounts.SoftDeleteAccount(ctx, accountID, anonymizedEmail(accountID), now); err != nil {
+		log.Error("failed to delete account", sl.Err(err))
+		return err
+	}
+
+	if err := a.revokeOtherSessions(ctx, accountID, "", models.RevokedAccountDeleted); err != nil {
+		log.Error("failed to revoke sessions", sl.Err(err))
+		return err
+	}
+
+	a.publish(ctx, events.AccountDeleted{
+		AccountID:  accountID,
+		ActorID:    actorID,
+		PurgeAfter: now.Add(a.deletionRetention),
+		OccurredAt: now,
+	})
+
+	log.Info("account deleted", slog.Time("purge_after", now.Add(a.deletionRetention)))
+
+	return nil
+}
+
+// RestoreAccount undoes the deletion of an account that hasn't been purged yet,
+// with the email and status it had. It fails with ErrRestoreWindowExpired once the
+// retention period is over, even if the purge job hasn't caught up, and with
+// storage.ErrAccountExists if the email has been registered again. Sessions revoked
+// by the deletion stay revoked. Admin only.
+func (a *Auth) RestoreAccount(ctx context.Context, actorID int64, accountID int64) error {
+	const op = "Auth.RestoreAccount"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int64("actor_id", actorID),
+		slog.Int64("account_id", accountID),
+	)
+
+	if a.deletedAccounts == nil {
+		return fmt.Errorf("%s: %w", op, ErrAccountDeletionDisabled)
+	}
+
+	if err := a.requireAdmin(ctx, actorID); err != nil {
+		log.Warn("admin check failed", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	email, deletedAt, err := a.deletedAccounts.DeletedAccount(ctx, accountID)
+	if err != nil {
+		if errors.Is(err, storage.ErrAccountNotFound) {
+			log.Info("account is not deleted")
+		} else {
+			log.Error("failed to get deleted account", sl.Err(err))
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if time.Since(deletedAt) > a.deletionRetention {
+		log.Info("restore window expired", slog.Time("deleted_at", deletedAt))
+		return fmt.Errorf("%s: %w", op, ErrRestoreWindowExpired)
+	}
+
+	if err := a.checkIdentifierAvailable(ctx, a.identifierNormalizer.Normalize(email)); err != nil {
+		log.Info("identifier not available", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.deletedAccounts.RestoreDeletedAccount(ctx, accountID); err != nil {
+		log.Error("failed to restore account", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	a.publish(ctx, events.AccountRestored{
+		AccountID:  accountID,
+		ActorID:    actorID,
+		OccurredAt: time.Now(),
+	})
+
+	log.Info("account restored")
+
+	return nil
+}
+
+// PurgeDeletedAccounts permanently deletes accounts deleted longer than the
+// retention period ago, with everything stored about them, and returns how many
+// were purged. Accounts go batchSize at a time; each is deleted on its own, so a run
+// cut short by ctx leaves the rest to the next one.
+func (a *Auth) PurgeDeletedAccounts(ctx context.Context, batchSize int) (int, error) {
+	const op = "Auth.PurgeDeletedAccounts"
+
+	log := a.log.With(
+		slog.String("op", op),
+	)
+
+	if a.deletedAccounts == nil {
+		return 0, nil
+	}
+
+	if batchSize <= 0 {
+		batchSize = defaultPurgeBatchSize
+	}
+
+	cutoff := time.Now().Add(-a.deletionRetention)
+
+	var (
+		purged int
+		errs   []error
+	)
+	for ctx.Err() == nil {
+		ids, err := a.deletedAccounts.DeletedAccounts(ctx, cutoff, batchSize)
+		if err != nil {
+			log.Error("failed to get deleted accounts", sl.Err(err))
+			errs = append(errs, err)
+			break
+		}
+
+		var failed int
+		for _, id := range ids {
+			if err := a.accountSaver.DeleteAccount(ctx, id); err != nil {
+				log.Error("failed to purge account", slog.Int64("account_id", id), sl.Err(err))
+				errs = append(errs, err)
+				failed++
+				continue
+			}
+			purged++
+
+			a.publish(ctx, events.AccountPurged{
+				AccountID:  id,
+				OccurredAt: time.Now(),
+			})
+		}
+
+		// A batch that failed entirely would be fetched again; leave it to the next run.
+		if len(ids) < batchSize || failed == len(ids) {
+			break
+		}
+	}
+
+	if purged > 0 {
+		log.Info("deleted accounts purged", slog.Int("count", purged))
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return purged, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return purged, nil
+}
@@ -0,0 +1,262 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"sso/internal/domain/events"
+	"sso/internal/domain/models"
+	"sso/internal/lib/logger/sl"
+	"sso/internal/lib/useragent"
+)
+
+var (
+	ErrDeviceNotFound      = errors.New("device not found")
+	ErrInvalidDeviceName   = errors.New("invalid device name")
+	ErrDeviceNamesDisabled = errors.New("device names are not configured")
+)
+
+// maxDeviceNameLength is the longest device name accepted, in characters.
+const maxDeviceNameLength = 64
+
+// DeviceNameStore keeps the names users gave their devices. An empty name removes
+// the device's name.
+type DeviceNameStore interface {
+	DeviceNames(ctx context.Context, accountId int64) (map[string]string, error)
+	SetDeviceName(ctx context.Context, accountId int64, deviceID string, name string) error
+}
+
+// ListDevices returns the devices the account owning the presented access token
+// has active sessions on, most recently seen first. The device of the token's own
+// session is marked Current. Tokens are cleared from the sessions.
+func (a *Auth) ListDevices(ctx context.Context, token string) ([]models.Device, error) {
+	const op = "Auth.ListDevices"
+
+	log := a.log.With(
+		slog.String("op", op),
+	)
+
+	current, err := a.CurrentSession(ctx, token)
+	if err != nil {
+		log.Info("invalid session", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log = log.With(slog.Int64("account_id", current.AccountID))
+
+	devices, err := a.accountDevices(ctx, current.AccountID)
+	if err != nil {
+		log.Error("failed to get devices", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if a.deviceNames != nil {
+		names, err := a.deviceNames.DeviceNames(ctx, current.AccountID)
+		if err != nil {
+			log.Error("failed to get device names", sl.Err(err))
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		for i := range devices {
+			devices[i].Name = names[devices[i].ID]
+		}
+	}
+
+	currentID := sessionDeviceID(current)
+	for i := range devices {
+		devices[i].Current = devices[i].ID == currentID
+		for j := range devices[i].Sessions {
+			devices[i].Sessions[j].RefreshToken = ""
+		}
+	}
+
+	return devices, nil
+}
+
+// RenameDevice names one of the caller's devices; an empty name clears it. Only
+// devices with an active session of the account can be named.
+func (a *Auth) RenameDevice(ctx context.Context, token string, deviceID string, name string) error {
+	const op = "Auth.RenameDevice"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.String("device_id", deviceID),
+	)
+
+	if a.deviceNames == nil {
+		return fmt.Errorf("%s: %w", op, ErrDeviceNamesDisabled)
+	}
+
+	name = strings.TrimSpace(name)
+	if utf8.RuneCountInString(name) > maxDeviceNameLength || !utf8.ValidString(name) {
+		return fmt.Errorf("%s: %w", op, ErrInvalidDeviceName)
+	}
+
+	current, err := a.CurrentSession(ctx, token)
+	if err != nil {
+		log.Info("invalid session", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log = log.With(slog.Int64("account_id", current.AccountID))
+
+	if _, err := a.accountDevice(ctx, current.AccountID, deviceID); err != nil {
+		log.Info("device not found", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.deviceNames.SetDeviceName(ctx, current.AccountID, deviceID, name); err != nil {
+		log.Error("failed to save device name", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("device renamed")
+
+	return nil
+}
+
+// RevokeDevice signs the caller out of every session on one of their devices with
+// RevokedSignedOut. That may be the device the request came from. Returns
+// ErrReauthRequired if recent authentication is required for account management
+// and the caller hasn't.
+func (a *Auth) RevokeDevice(ctx context.Context, token string, deviceID string) error {
+	const op = "Auth.RevokeDevice"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.String("device_id", deviceID),
+	)
+
+	current, err := a.CurrentSession(ctx, token)
+	if err != nil {
+		log.Info("invalid session", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log = log.With(slog.Int64("account_id", current.AccountID))
+
+	if err := a.requireSensitiveAuth(ctx, token); err != nil {
+		log.Info("recent authentication required", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	device, err := a.accountDevice(ctx, current.AccountID, deviceID)
+	if err != nil {
+		log.Info("device not found", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.revokeDeviceSessions(ctx, current.AccountID, device, models.RevokedSignedOut); err != nil {
+		log.Error("failed to revoke sessions", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("device signed out", slog.Int("sessions", len(device.Sessions)))
+
+	return nil
+}
+
+// revokeDeviceSessions revokes every session of the device with reason.
+func (a *Auth) revokeDeviceSessions(ctx context.Context, accountID int64, device models.Device, reason models.RevocationReason) error {
+	for _, session := range device.Sessions {
+		if err := a.sessionSaver.RevokeSessionWithReason(ctx, session.SID, reason); err != nil {
+			return err
+		}
+
+		a.publish(ctx, events.SessionRevoked{
+			SessionID:  session.ID,
+			AccountID:  accountID,
+			Reason:     reason,
+			OccurredAt: time.Now(),
+		})
+	}
+
+	return nil
+}
+
+// accountDevices groups the account's active sessions by device, most recently seen
+// device first.
+func (a *Auth) accountDevices(ctx context.Context, accountID int64) ([]models.Device, error) {
+	sessions, err := a.sessionProvider.Sessions(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	byID := make(map[string]*models.Device)
+	var devices []*models.Device
+	for _, session := range sessions {
+		if session.RefreshExpiresAt.Before(now) {
+			continue
+		}
+
+		id := sessionDeviceID(session)
+		device, ok := byID[id]
+		if !ok {
+			info := useragent.Parse(session.UserAgent)
+			device = &models.Device{ID: id, OS: info.OS, Browser: info.Browser}
+			byID[id] = device
+			devices = append(devices, device)
+		}
+
+		device.Sessions = append(device.Sessions, session)
+		if session.LastSeenAt.After(device.LastSeenAt) {
+			device.LastSeenAt = session.LastSeenAt
+			device.IPAddress = session.IPAddress
+		}
+	}
+
+	sort.Slice(devices, func(i, j int) bool {
+		return devices[i].LastSeenAt.After(devices[j].LastSeenAt)
+	})
+
+	result := make([]models.Device, 0, len(devices))
+	for _, device := range devices {
+		result = append(result, *device)
+	}
+
+	return result, nil
+}
+
+// accountDevice returns the account's device deviceID, or ErrDeviceNotFound if the
+// account has no active session on it.
+func (a *Auth) accountDevice(ctx context.Context, accountID int64, deviceID string) (models.Device, error) {
+	devices, err := a.accountDevices(ctx, accountID)
+	if err != nil {
+		return models.Device{}, err
+	}
+
+	for _, device := range devices {
+		if device.ID == deviceID {
+			return device, nil
+		}
+	}
+
+	return models.Device{}, ErrDeviceNotFound
+}
+
+// sessionDevice describes the device a session with userAgent is created from.
+func sessionDevice(userAgent string) models.SessionDevice {
+	info := useragent.Parse(userAgent)
+
+	return models.SessionDevice{
+		ID:      useragent.Fingerprint(userAgent),
+		OS:      info.OS,
+		Browser: info.Browser,
+	}
+}
+
+// sessionDeviceID is the session's device, fingerprinted from its user agent for
+// sessions created before device tracking.
+func sessionDeviceID(session models.Session) string {
+	if session.DeviceID != "" {
+		return session.DeviceID
+	}
+
+	return useragent.Fingerprint(session.UserAgent)
+}
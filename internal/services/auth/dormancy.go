@@ -0,0 +1,223 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sso/internal/domain/events"
+	"sso/internal/domain/models"
+	"sso/internal/lib/logger/sl"
+	"sso/internal/storage"
+	"time"
+)
+
+var ErrReactivationDisabled = errors.New("reactivation is not configured")
+
+// DeactivateDormantAccounts moves active accounts without a login for longer than
+// threshold to the DORMANT status and revokes their sessions. It returns the number
+// of deactivated accounts.
+//
+// Accounts are processed in batches of batchSize (all at once if batchSize <= 0).
+// Each account is updated on its own, so a run cut short by ctx leaves no partial
+// state and the next run picks up the remaining accounts.
+func (a *Auth) DeactivateDormantAccounts(ctx context.Context, threshold time.Duration, batchSize int) (int, error) {
+	const op = "Auth.DeactivateDormantAccounts"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Duration("threshold", threshold),
+	)
+
+	cutoff := time.Now().Add(-threshold)
+
+	var (
+		deactivated int
+		afterID     int64
+		errs        []error
+	)
+	for {
+		ids, err := a.accountProvider.IdleAccounts(ctx, cutoff, afterID, batchSize)
+		if err != nil {
+			log.Error("failed to get idle accounts", sl.Err(err))
+			errs = append(errs, err)
+			break
+		}
+
+		deactivated += a.deactivateDormant(ctx, log, ids, cutoff, &errs)
+
+		if batchSize <= 0 || len(ids) < batchSize || ctx.Err() != nil {
+			break
+		}
+
+		afterID = ids[len(ids)-1]
+		log.Debug("dormancy batch done", slog.Int64("last_id", afterID), slog.Int("deactivated", deactivated))
+	}
+
+	if deactivated > 0 {
+		log.Info("dormant accounts deactivated", slog.Int("count", deactivated))
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return deactivated, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return deactivated, nil
+}
+
+// deactivateDormant deactivates a batch of idle accounts, collecting failures in errs.
+// Accounts that logged in after cutoff or left ACTIVE since the batch was listed
+// are skipped.
+func (a *Auth) deactivateDormant(ctx context.Context, log *slog.Logger, ids []int64, cutoff time.Time, errs *[]error) int {
+	var deactivated int
+	for _, id := range ids {
+		// The update only ever replaces ACTIVE, so that is the old status.
+		ok, err := a.accountSaver.DeactivateIdleAccount(ctx, id, cutoff)
+		if err != nil {
+			log.Error("failed to deactivate account", slog.Int64("account_id", id), sl.Err(err))
+			*errs = append(*errs, err)
+			continue
+		}
+		if !ok {
+			log.Debug("account no longer idle", slog.Int64("account_id", id))
+			continue
+		}
+
+		a.publish(ctx, events.AccountStatusChanged{
+			AccountID:  id,
+			OldStatus:  models.ACTIVE,
+			NewStatus:  models.DORMANT,
+			Reason:     "dormancy",
+			OccurredAt: time.Now(),
+		})
+
+		if err := a.sessionSaver.RevokeAccountSessions(ctx, id, "", models.RevokedAccountDormant); err != nil {
+			log.Error("failed to revoke sessions", slog.Int64("account_id", id), sl.Err(err))
+			*errs = append(*errs, err)
+			continue
+		}
+
+		deactivated++
+	}
+
+	return deactivated
+}
+
+// RequestReactivation issues a single-use reactivation token for the dormant
+// account with email in appID and hands it to the reactivation publisher for
+// delivery. The result is the same for unknown and non-dormant accounts, so the
+// call can't be used to probe for accounts or their status.
+func (a *Auth) RequestReactivation(ctx context.Context, email string, appID int32) error {
+	const op = "Auth.RequestReactivation"
+
+	email = a.loginIdentifier(email)
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.String("email", email),
+	)
+
+	if a.reactivations == nil {
+		return fmt.Errorf("%s: %w", op, ErrReactivationDisabled)
+	}
+
+	account, err := a.accountByIdentifier(ctx, email, appID)
+	if err != nil {
+		if errors.Is(err, storage.ErrAccountNotFound) {
+			log.Info("reactivation requested for unknown account")
+			return nil
+		}
+		log.Error("failed to get account", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log = log.With(slog.Int64("account_id", account.ID))
+
+	if account.Status != models.DORMANT {
+		log.Info("reactivation requested for account that isn't dormant")
+		return nil
+	}
+
+	token, err := a.IssueOneTimeCode(ctx, account.ID, models.CodePurposeReactivation, a.reactivationTTL)
+	if err != nil {
+		log.Error("failed to issue reactivation token", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	now := time.Now()
+	err = a.reactivations.Publish(ctx, events.ReactivationRequested{
+		AccountID:  account.ID,
+		Email:      account.Email,
+		AppID:      appID,
+		Token:      token,
+		ExpiresAt:  now.Add(a.reactivationTTL),
+		OccurredAt: now,
+	})
+	if err != nil {
+		log.Error("failed to deliver reactivation token", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("reactivation requested")
+
+	return nil
+}
+
+// ReactivateAccount returns a dormant account to ACTIVE if token is its current,
+// unexpired reactivation token from RequestReactivation, which is consumed. Wrong,
+// used or expired tokens return ErrInvalidCode; Login refuses dormant accounts
+// with ErrAccountDormant until they are reactivated.
+func (a *Auth) ReactivateAccount(ctx context.Context, accountID int64, token string) error {
+	const op = "Auth.ReactivateAccount"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int64("account_id", accountID),
+	)
+
+	if a.reactivations == nil {
+		return fmt.Errorf("%s: %w", op, ErrReactivationDisabled)
+	}
+
+	account, err := a.accountProvider.AccountById(ctx, accountID)
+	if err != nil {
+		log.Error("failed to get account", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if account.Status != models.DORMANT {
+		return nil
+	}
+
+	if err := a.ConsumeOneTimeCode(ctx, accountID, models.CodePurposeReactivation, token); err != nil {
+		if errors.Is(err, ErrCodeAlreadyUsed) {
+			err = ErrInvalidCode
+		}
+		log.Info("invalid reactivation token", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.accountSaver.UpdateStatus(ctx, accountID, models.ACTIVE); err != nil {
+		log.Error("failed to reactivate account", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Restart the idle clock so the next sweep doesn't deactivate the account again
+	// before its owner gets to log in.
+	if err := a.accountSaver.UpdateLastLogin(ctx, accountID, time.Now()); err != nil {
+		log.Error("failed to reset idle clock", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("account reactivated")
+
+	a.publish(ctx, events.AccountStatusChanged{
+		AccountID:  accountID,
+		OldStatus:  models.DORMANT,
+		NewStatus:  models.ACTIVE,
+		Reason:     "reactivation",
+		OccurredAt: time.Now(),
+	})
+
+	return nil
+}
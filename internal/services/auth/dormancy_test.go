@@ -0,0 +1,243 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"sso/internal/domain/events"
+	"sso/internal/domain/models"
+	"sso/internal/storage/sqlite"
+)
+
+func TestReactivateAccount(t *testing.T) {
+	tests := []struct {
+		name string
+		// token returns the token to reactivate with, given the delivered one.
+		token      func(t *testing.T, a *Auth, accountID int64, delivered string) string
+		wantErr    error
+		wantStatus models.AccountStatus
+	}{
+		{
+			name:       "delivered token",
+			token:      func(_ *testing.T, _ *Auth, _ int64, delivered string) string { return delivered },
+			wantStatus: models.ACTIVE,
+		},
+		{
+			name:       "no token",
+			token:      func(*testing.T, *Auth, int64, string) string { return "" },
+			wantErr:    ErrInvalidCode,
+			wantStatus: models.DORMANT,
+		},
+		{
+			name:       "wrong token",
+			token:      func(*testing.T, *Auth, int64, string) string { return "not-the-token" },
+			wantErr:    ErrInvalidCode,
+			wantStatus: models.DORMANT,
+		},
+		{
+			name: "token of another purpose",
+			token: func(t *testing.T, a *Auth, accountID int64, _ string) string {
+				token, err := a.IssueOneTimeCode(context.Background(), accountID, models.CodePurposePasswordReset, time.Hour)
+				if err != nil {
+					t.Fatalf("issue code: %v", err)
+				}
+				return token
+			},
+			wantErr:    ErrInvalidCode,
+			wantStatus: models.DORMANT,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			reactivations := &capturingPublisher{}
+			a, storage := newTestAuth(t, WithReactivation(reactivations, time.Hour))
+			appID := newTestApp(t, storage)
+			accountID := registerTestAccount(t, a, appID, "user@example.com")
+			if err := storage.UpdateStatus(ctx, accountID, models.DORMANT); err != nil {
+				t.Fatalf("make dormant: %v", err)
+			}
+
+			if err := a.RequestReactivation(ctx, "user@example.com", appID); err != nil {
+				t.Fatalf("request reactivation: %v", err)
+			}
+			if len(reactivations.events) != 1 {
+				t.Fatalf("%d events delivered, want 1", len(reactivations.events))
+			}
+			delivered := reactivations.events[0].(events.ReactivationRequested).Token
+
+			err := a.ReactivateAccount(ctx, accountID, tt.token(t, a, accountID, delivered))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("reactivate error = %v, want %v", err, tt.wantErr)
+			}
+
+			account, err := storage.AccountById(ctx, accountID)
+			if err != nil {
+				t.Fatalf("account: %v", err)
+			}
+			if account.Status != tt.wantStatus {
+				t.Errorf("status = %v, want %v", account.Status, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestRequestReactivationSilentForActiveAccounts(t *testing.T) {
+	reactivations := &capturingPublisher{}
+	a, storage := newTestAuth(t, WithReactivation(reactivations, time.Hour))
+	appID := newTestApp(t, storage)
+	registerTestAccount(t, a, appID, "user@example.com")
+
+	for _, email := range []string{"user@example.com", "nobody@example.com"} {
+		if err := a.RequestReactivation(context.Background(), email, appID); err != nil {
+			t.Errorf("request for %s: %v", email, err)
+		}
+	}
+
+	if len(reactivations.events) != 0 {
+		t.Errorf("%d tokens delivered for accounts that aren't dormant", len(reactivations.events))
+	}
+}
+
+func TestDeactivateDormant(t *testing.T) {
+	tests := []struct {
+		name string
+		// change runs between listing the idle account and deactivating it.
+		change     func(t *testing.T, a *Auth, storage *sqlite.Storage, appID int32, accountID int64)
+		wantStatus models.AccountStatus
+		wantEvent  bool
+	}{
+		{
+			name:       "still idle",
+			change:     func(*testing.T, *Auth, *sqlite.Storage, int32, int64) {},
+			wantStatus: models.DORMANT,
+			wantEvent:  true,
+		},
+		{
+			name: "suspended meanwhile",
+			change: func(t *testing.T, _ *Auth, storage *sqlite.Storage, _ int32, accountID int64) {
+				if err := storage.UpdateStatus(context.Background(), accountID, models.SUSPENDED); err != nil {
+					t.Fatalf("suspend: %v", err)
+				}
+			},
+			wantStatus: models.SUSPENDED,
+		},
+		{
+			name: "logged in meanwhile",
+			change: func(t *testing.T, a *Auth, _ *sqlite.Storage, appID int32, _ int64) {
+				loginTestAccount(t, a, appID, "user@example.com")
+			},
+			wantStatus: models.ACTIVE,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			publisher := &capturingPublisher{}
+			a, storage := newTestAuth(t, WithEventPublisher(publisher))
+			appID := newTestApp(t, storage)
+			accountID := registerTestAccount(t, a, appID, "user@example.com")
+			if err := storage.UpdateLastLogin(ctx, accountID, time.Now().Add(-2*time.Hour)); err != nil {
+				t.Fatalf("backdate last login: %v", err)
+			}
+
+			cutoff := time.Now().Add(-time.Hour)
+			ids, err := storage.IdleAccounts(ctx, cutoff, 0, 0)
+			if err != nil {
+				t.Fatalf("idle accounts: %v", err)
+			}
+			if len(ids) != 1 {
+				t.Fatalf("%d idle accounts, want 1", len(ids))
+			}
+			tt.change(t, a, storage, appID, accountID)
+
+			var errs []error
+			a.deactivateDormant(ctx, a.log, ids, cutoff, &errs)
+			if len(errs) != 0 {
+				t.Fatalf("deactivate errors: %v", errs)
+			}
+
+			account, err := storage.AccountById(ctx, accountID)
+			if err != nil {
+				t.Fatalf("account: %v", err)
+			}
+			if account.Status != tt.wantStatus {
+				t.Errorf("status = %v, want %v", account.Status, tt.wantStatus)
+			}
+
+			var changes []events.AccountStatusChanged
+			for _, e := range publisher.events {
+				if e, ok := e.(events.AccountStatusChanged); ok {
+					changes = append(changes, e)
+				}
+			}
+			if !tt.wantEvent {
+				if len(changes) != 0 {
+					t.Errorf("%d status events published, want none", len(changes))
+				}
+				return
+			}
+			if len(changes) != 1 {
+				t.Fatalf("%d status events published, want 1", len(changes))
+			}
+			if changes[0].OldStatus != models.ACTIVE || changes[0].NewStatus != models.DORMANT {
+				t.Errorf("event = %v -> %v, want ACTIVE -> DORMANT", changes[0].OldStatus, changes[0].NewStatus)
+			}
+		})
+	}
+}
+
+func TestDeactivateDormantAccountsInBatches(t *testing.T) {
+	tests := []struct {
+		name      string
+		accounts  int
+		batchSize int
+	}{
+		{name: "all at once", accounts: 5, batchSize: 0},
+		{name: "partial last batch", accounts: 5, batchSize: 2},
+		{name: "exact batches", accounts: 4, batchSize: 2},
+		{name: "batch larger than accounts", accounts: 3, batchSize: 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			a, storage := newTestAuth(t)
+			appID := newTestApp(t, storage)
+
+			ids := make([]int64, tt.accounts)
+			for i := range ids {
+				ids[i] = registerTestAccount(t, a, appID, fmt.Sprintf("user%d@example.com", i))
+			}
+
+			// Accounts registered just now count as idle for a negative threshold.
+			deactivated, err := a.DeactivateDormantAccounts(ctx, -time.Minute, tt.batchSize)
+			if err != nil {
+				t.Fatalf("deactivate: %v", err)
+			}
+			if deactivated != tt.accounts {
+				t.Errorf("deactivated %d accounts, want %d", deactivated, tt.accounts)
+			}
+
+			for _, id := range ids {
+				account, err := storage.AccountById(ctx, id)
+				if err != nil {
+					t.Fatalf("account: %v", err)
+				}
+				if account.Status != models.DORMANT {
+					t.Errorf("account %d status = %v, want DORMANT", id, account.Status)
+				}
+			}
+
+			// A second run finds nothing left to do.
+			if again, err := a.DeactivateDormantAccounts(ctx, -time.Minute, tt.batchSize); err != nil || again != 0 {
+				t.Errorf("second run = %d, %v, want nothing deactivated", again, err)
+			}
+		})
+	}
+}
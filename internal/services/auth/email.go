@@ -0,0 +1,226 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"sso/internal/domain/events"
+	"sso/internal/domain/models"
+	"sso/internal/lib/logger/sl"
+	"sso/internal/storage"
+)
+
+var (
+	ErrEmailChangeDisabled  = errors.New("email change is not configured")
+	ErrEmailChangeThrottled = errors.New("too many email change requests")
+	ErrInvalidEmail         = errors.New("invalid email")
+)
+
+// EmailChangeStore keeps the address an account asked to change its email to until
+// the change is confirmed. ChangeEmail drops the pending address along with
+// setting the email.
+type EmailChangeStore interface {
+	SavePendingEmail(ctx context.Context, accountId int64, email string) error
+	PendingEmail(ctx context.Context, accountId int64) (string, error)
+	ChangeEmail(ctx context.Context, accountId int64, email string) error
+}
+
+// RequestEmailChange starts changing the email of the account owning the presented
+// access token to newEmail. A single-use confirmation token is handed to the email
+// change publisher as EmailChangeRequested, for delivery to newEmail and a notice
+// to the current email, which stays in use until ConfirmEmailChange. A new request
+// replaces a pending one.
+//
+// It returns ErrInvalidEmail for addresses without an @, storage.ErrAccountExists
+// if another account has newEmail and ErrEmailChangeThrottled when the account has
+// asked too often.
+func (a *Auth) RequestEmailChange(ctx context.Context, token string, newEmail string) error {
+	const op = "Auth.RequestEmailChange"
+
+	log := a.log.With(
+		slog.String("op", op),
+	)
+
+	if a.emailChanges == nil {
+		return fmt.Errorf("%s: %w", op, ErrEmailChangeDisabled)
+	}
+
+	session, err := a.CurrentSession(ctx, token)
+	if err != nil {
+		log.Info("invalid session", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log = log.With(slog.Int64("account_id", session.AccountID))
+
+	if err := a.requireSensitiveAuth(ctx, token); err != nil {
+		log.Info("recent authentication required", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if a.emailChangeRequests != nil && !a.emailChangeRequests.Allow(strconv.FormatInt(session.AccountID, 10)).Allowed {
+		log.Warn("email change requests throttled")
+		return fmt.Errorf("%s: %w", op, ErrEmailChangeThrottled)
+	}
+
+	account, err := a.accountProvider.AccountById(ctx, session.AccountID)
+	if err != nil {
+		log.Error("failed to get account", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Without an @ the email would be taken for a username on login.
+	newEmail = a.identifierNormalizer.Normalize(newEmail)
+	if !strings.Contains(newEmail, "@") {
+		log.Info("invalid email")
+		return fmt.Errorf("%s: %w", op, ErrInvalidEmail)
+	}
+	if newEmail == a.identifierNormalizer.Normalize(account.Email) {
+		return nil
+	}
+
+	if err := a.checkEmailAvailable(ctx, newEmail, account.AppId); err != nil {
+		if errors.Is(err, storage.ErrAccountExists) {
+			log.Info("email already taken")
+		} else {
+			log.Error("failed to check email", sl.Err(err))
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// The token is issued before the address is saved: the new token voids the old
+	// one first, so a token sent to an earlier pending address can never confirm a
+	// later one.
+	changeToken, err := a.IssueOneTimeCode(ctx, account.ID, models.CodePurposeEmailChange, a.emailChangeTTL)
+	if err != nil {
+		log.Error("failed to issue email change token", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.emailChangeStore.SavePendingEmail(ctx, account.ID, newEmail); err != nil {
+		log.Error("failed to save pending email", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	now := time.Now()
+	err = a.emailChanges.Publish(ctx, events.EmailChangeRequested{
+		AccountID:  account.ID,
+		OldEmail:   account.Email,
+		NewEmail:   newEmail,
+		AppID:      session.AppID,
+		Token:      changeToken,
+		ExpiresAt:  now.Add(a.emailChangeTTL),
+		OccurredAt: now,
+	})
+	if err != nil {
+		log.Error("failed to deliver email change token", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("email change requested")
+
+	return nil
+}
+
+// ConfirmEmailChange completes the email change of the account if token is its
+// current, unexpired email change token. The account's email becomes the pending
+// address, every session is revoked with RevokedEmailChanged and outstanding
+// access tokens stop validating, so nobody keeps access through tokens issued
+// under the old email. EmailChanged is handed to the email change publisher to
+// notify the old address. Unknown accounts and wrong, used or expired tokens all
+// return ErrInvalidCode; storage.ErrAccountExists means the address was taken
+// since the request.
+func (a *Auth) ConfirmEmailChange(ctx context.Context, accountID int64, token string) error {
+	const op = "Auth.ConfirmEmailChange"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int64("account_id", accountID),
+	)
+
+	if a.emailChanges == nil {
+		return fmt.Errorf("%s: %w", op, ErrEmailChangeDisabled)
+	}
+
+	account, err := a.accountProvider.AccountById(ctx, accountID)
+	if err != nil {
+		if errors.Is(err, storage.ErrAccountNotFound) {
+			log.Info("email change for unknown account")
+			return fmt.Errorf("%s: %w", op, ErrInvalidCode)
+		}
+		log.Error("failed to get account", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// A soft-deleted account's email is kept aside for restoring it.
+	if account.Status == models.DELETED {
+		log.Info("email change for deleted account")
+		return fmt.Errorf("%s: %w", op, ErrInvalidCode)
+	}
+
+	if err := a.ConsumeOneTimeCode(ctx, account.ID, models.CodePurposeEmailChange, token); err != nil {
+		if errors.Is(err, ErrCodeAlreadyUsed) {
+			err = ErrInvalidCode
+		}
+		log.Info("invalid email change token", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	newEmail, err := a.emailChangeStore.PendingEmail(ctx, account.ID)
+	if err != nil {
+		if errors.Is(err, storage.ErrEmailChangeNotFound) {
+			log.Info("no pending email change")
+			return fmt.Errorf("%s: %w", op, ErrInvalidCode)
+		}
+		log.Error("failed to get pending email", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.checkIdentifierAvailable(ctx, newEmail); err != nil {
+		if errors.Is(err, storage.ErrAccountExists) {
+			log.Info("email taken since the request")
+		} else {
+			log.Error("failed to check email", sl.Err(err))
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.emailChangeStore.ChangeEmail(ctx, account.ID, newEmail); err != nil {
+		if errors.Is(err, storage.ErrAccountExists) {
+			log.Info("email taken since the request")
+		} else {
+			log.Error("failed to change email", sl.Err(err))
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.accountSaver.IncrementTokenVersion(ctx, account.ID); err != nil {
+		log.Error("failed to bump token version", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.revokeOtherSessions(ctx, account.ID, "", models.RevokedEmailChanged); err != nil {
+		log.Error("failed to revoke sessions", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	changed := events.EmailChanged{
+		AccountID:  account.ID,
+		OldEmail:   account.Email,
+		NewEmail:   newEmail,
+		OccurredAt: time.Now(),
+	}
+	if err := a.emailChanges.Publish(ctx, changed); err != nil {
+		log.Warn("failed to notify old email", sl.Err(err))
+	}
+	a.publish(ctx, changed)
+
+	log.Info("email changed")
+
+	return nil
+}
@@ -0,0 +1,160 @@
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"sso/internal/domain/events"
+	"sso/internal/domain/models"
+	"sso/internal/lib/jwt"
+	"sso/internal/lib/logger/sl"
+	"sso/internal/storage"
+)
+
+// ErrInvalidClient reports token exchange by an unknown app, with the wrong
+// secret, or with an access token that wasn't issued to it.
+var ErrInvalidClient = errors.New("invalid client")
+
+// TokenExchange exchanges subjectToken, an access token the client app clientID
+// received from the account, for a token for the app appID, as OAuth 2.0 token
+// exchange (RFC 8693) does, so the client can call that app on the account's
+// behalf. The client authenticates with its secret and may only exchange tokens
+// issued to it. The new token carries the scopes of subjectToken that the account
+// also has in appID, narrowed further to scopes unless that is empty, and names
+// the client in its act claim, on top of the act claim of an exchanged
+// subjectToken, so the delegation chain stays visible. It belongs to the same
+// session, so revoking the session revokes it too, never outlives subjectToken,
+// and isn't renewed by validation. The account must be a member of appID.
+func (a *Auth) TokenExchange(ctx context.Context, clientID int32, clientSecret string, subjectToken string, appID int32, scopes []string) (models.ExchangedToken, error) {
+	const op = "Auth.TokenExchange"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int64("client_id", int64(clientID)),
+		slog.Int64("app_id", int64(appID)),
+	)
+
+	if err := a.authenticateClient(ctx, clientID, clientSecret); err != nil {
+		if errors.Is(err, ErrInvalidClient) {
+			log.Warn("client authentication failed")
+		} else {
+			log.Error("failed to authenticate client", sl.Err(err))
+		}
+		return models.ExchangedToken{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	session, err := a.CurrentSession(ctx, subjectToken)
+	if err != nil {
+		log.Info("invalid subject token", sl.Err(err))
+		return models.ExchangedToken{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	_, _, claims, err := a.sessionForToken(ctx, subjectToken)
+	if err != nil {
+		log.Info("invalid subject token", sl.Err(err))
+		return models.ExchangedToken{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if claims.AppID != int64(clientID) {
+		log.Warn("subject token issued to another app", slog.Int64("token_app_id", claims.AppID))
+		return models.ExchangedToken{}, fmt.Errorf("%s: %w", op, ErrInvalidClient)
+	}
+
+	app, err := a.appForLogin(ctx, appID)
+	if err != nil {
+		if !errors.Is(err, ErrAppNotFound) {
+			log.Error("failed to get app", sl.Err(err))
+		}
+		return models.ExchangedToken{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	account, err := a.accountProvider.AccountById(ctx, session.AccountID)
+	if err != nil {
+		log.Error("failed to get account", sl.Err(err))
+		return models.ExchangedToken{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := loginStatusError(account.Status); err != nil {
+		log.Info("account status forbids exchange", slog.Int("status", int(account.Status)))
+		return models.ExchangedToken{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if claims.TokenVersion < account.TokenVersion {
+		log.Info("subject token version superseded", slog.Int64("token_version", claims.TokenVersion))
+		return models.ExchangedToken{}, fmt.Errorf("%s: %w", op, ErrSessionRevoked)
+	}
+
+	account, err = a.accountForApp(ctx, account, appID)
+	if err != nil {
+		log.Info("account can't use app", sl.Err(err))
+		return models.ExchangedToken{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	account.Scopes = intersectScopes(claims.Scopes, account.Scopes)
+	if len(scopes) > 0 {
+		account.Scopes = intersectScopes(scopes, account.Scopes)
+	}
+
+	ttl := min(a.accessTokenTTL(), time.Until(claims.ExpiresAt))
+	if ttl <= 0 {
+		return models.ExchangedToken{}, fmt.Errorf("%s: %w", op, jwt.ErrTokenExpired)
+	}
+
+	actor := jwt.Actor{
+		Subject:  strconv.Itoa(int(clientID)),
+		ClientID: strconv.Itoa(int(clientID)),
+		Actor:    claims.Actor,
+	}
+
+	token, err := jwt.NewDelegatedToken(ctx, account, app, ttl, claims.AuthTime, a.subjectFormat, a.tokenIssuer, session.SID, actor, a.claimsEnricher)
+	if err != nil {
+		log.Error("failed to generate token", sl.Err(err))
+		return models.ExchangedToken{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("token exchanged", slog.Int64("account_id", account.ID), slog.Any("scopes", account.Scopes))
+
+	now := time.Now()
+
+	a.publish(ctx, events.TokenExchanged{
+		AccountID:  account.ID,
+		SessionID:  session.SID,
+		ClientID:   clientID,
+		AppID:      appID,
+		Scopes:     account.Scopes,
+		OccurredAt: now,
+	})
+
+	return models.ExchangedToken{
+		Token:     token,
+		AppID:     appID,
+		Scopes:    account.Scopes,
+		ExpiresAt: now.Add(ttl),
+	}, nil
+}
+
+// authenticateClient checks secret against the secret of the app clientID.
+func (a *Auth) authenticateClient(ctx context.Context, clientID int32, secret string) error {
+	if clientID <= 0 || secret == "" {
+		return ErrInvalidClient
+	}
+
+	app, err := a.appProvider.App(ctx, clientID)
+	if err != nil {
+		if errors.Is(err, storage.ErrAppNotFound) {
+			return ErrInvalidClient
+		}
+		return err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(app.Secret), []byte(secret)) != 1 {
+		return ErrInvalidClient
+	}
+
+	return nil
+}
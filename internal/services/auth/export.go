@@ -0,0 +1,372 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+
+	"sso/internal/domain/events"
+	"sso/internal/domain/models"
+	"sso/internal/lib/logger/sl"
+	"sso/internal/storage"
+)
+
+var ErrDataExportDisabled = errors.New("account data export is not configured")
+
+// exportFormatVersion is bumped whenever fields of the export change meaning or go
+// away, so consumers can tell which layout they are reading.
+const exportFormatVersion = 1
+
+// AccountDataStore reads the history kept about an account for ExportAccountData.
+type AccountDataStore interface {
+	SessionHistory(ctx context.Context, accountId int64) ([]models.Session, error)
+	DeviceLogins(ctx context.Context, accountId int64) ([]models.DeviceLogin, error)
+}
+
+type accountExport struct {
+	ID          int64                `json:"id"`
+	Email       string               `json:"email"`
+	Username    string               `json:"username,omitempty"`
+	Phone       string               `json:"phone,omitempty"`
+	Role        models.AccountRole   `json:"role"`
+	Status      models.AccountStatus `json:"status"`
+	AppID       int32                `json:"app_id"`
+	ExternalID  string               `json:"external_id,omitempty"`
+	CreatedAt   time.Time            `json:"created_at"`
+	UpdatedAt   time.Time            `json:"updated_at"`
+	LastLoginAt *time.Time           `json:"last_login_at,omitempty"`
+	DeletedAt   *time.Time           `json:"deleted_at,omitempty"`
+}
+
+type profileExport struct {
+	DisplayName string          `json:"display_name,omitempty"`
+	GivenName   string          `json:"given_name,omitempty"`
+	FamilyName  string          `json:"family_name,omitempty"`
+	AvatarURL   string          `json:"avatar_url,omitempty"`
+	Locale      string          `json:"locale,omitempty"`
+	Timezone    string          `json:"timezone,omitempty"`
+	Metadata    json.RawMessage `json:"metadata,omitempty"`
+	UpdatedAt   *time.Time      `json:"updated_at,omitempty"`
+}
+
+// sessionExport leaves out the session's tokens: they are credentials, not data
+// about the account holder.
+type sessionExport struct {
+	ID               string                  `json:"id,omitempty"`
+	AppID            int32                   `json:"app_id"`
+	UserAgent        string                  `json:"user_agent,omitempty"`
+	IPAddress        string                  `json:"ip_address,omitempty"`
+	DeviceID         string                  `json:"device_id,omitempty"`
+	OS               string                  `json:"os,omitempty"`
+	Browser          string                  `json:"browser,omitempty"`
+	Scopes           []string                `json:"scopes,omitempty"`
+	CreatedAt        time.Time               `json:"created_at"`
+	LastSeenAt       time.Time               `json:"last_seen_at"`
+	ExpiresAt        time.Time               `json:"expires_at"`
+	RefreshExpiresAt time.Time               `json:"refresh_expires_at"`
+	Revoked          bool                    `json:"revoked"`
+	RevokedReason    models.RevocationReason `json:"revoked_reason,omitempty"`
+}
+
+type loginExport struct {
+	DeviceID    string    `json:"device_id"`
+	IPAddress   string    `json:"ip_address"`
+	FirstSeenAt time.Time `json:"first_seen_at"`
+	LastSeenAt  time.Time `json:"last_seen_at"`
+}
+
+type auditEventExport struct {
+	ID         int64           `json:"id"`
+	Type       string          `json:"type"`
+	ActorID    int64           `json:"actor_id,omitempty"`
+	IPAddress  string          `json:"ip_address,omitempty"`
+	OccurredAt time.Time       `json:"occurred_at"`
+	Payload    json.RawMessage `json:"payload"`
+}
+
+// ExportAccountData writes everything stored about the account to w as one JSON
+// object, for data subject access requests: the account record, its profile, its
+// sessions, revoked and expired ones included, the devices and IPs it signed in
+// from and, with the audit log enabled, the audit events about it. Secrets such as the
+// password hash and tokens are left out. Soft-deleted accounts are exported with
+// their original email until they are purged.
+//
+// Audit events are streamed page by page, so the export may be partially written
+// when an error is returned; callers must discard it then. Admin only.
+func (a *Auth) ExportAccountData(ctx context.Context, actorID int64, accountID int64, w io.Writer) error {
+	const op = "Auth.ExportAccountData"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int64("actor_id", actorID),
+		slog.Int64("account_id", accountID),
+	)
+
+	if a.accountData == nil {
+		return fmt.Errorf("%s: %w", op, ErrDataExportDisabled)
+	}
+
+	if err := a.requireAdmin(ctx, actorID); err != nil {
+		log.Warn("admin check failed", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	account, err := a.accountProvider.AccountById(ctx, accountID)
+	if err != nil {
+		if errors.Is(err, storage.ErrAccountNotFound) {
+			log.Info("account not found")
+		} else {
+			log.Error("failed to get account", sl.Err(err))
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	record := accountExport{
+		ID:          account.ID,
+		Email:       account.Email,
+		Username:    account.Username,
+		Phone:       account.Phone,
+		Role:        account.Role,
+		Status:      account.Status,
+		AppID:       account.AppId,
+		ExternalID:  account.ExternalID,
+		CreatedAt:   account.CreatedAt,
+		UpdatedAt:   account.UpdatedAt,
+		LastLoginAt: account.LastLoginAt,
+	}
+
+	if account.Status == models.DELETED && a.deletedAccounts != nil {
+		email, deletedAt, err := a.deletedAccounts.DeletedAccount(ctx, accountID)
+		switch {
+		case err == nil:
+			record.Email = email
+			record.DeletedAt = &deletedAt
+		case !errors.Is(err, storage.ErrAccountNotFound):
+			log.Error("failed to get deleted account", sl.Err(err))
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	var profile *profileExport
+	if a.profiles != nil {
+		p, err := a.profile(ctx, accountID)
+		if err != nil {
+			log.Error("failed to get profile", sl.Err(err))
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		profile = exportProfile(p)
+	}
+
+	sessions, err := a.accountData.SessionHistory(ctx, accountID)
+	if err != nil {
+		log.Error("failed to get sessions", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	logins, err := a.accountData.DeviceLogins(ctx, accountID)
+	if err != nil {
+		log.Error("failed to get login history", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	now := time.Now()
+
+	out := &exportWriter{w: w}
+	out.field("version", exportFormatVersion)
+	out.field("exported_at", now.UTC())
+	out.field("account", record)
+	if profile != nil {
+		out.field("profile", profile)
+	}
+	out.field("sessions", exportSessions(sessions))
+	out.field("login_history", exportLogins(logins))
+	if a.auditProvider != nil {
+		out.key("audit_events")
+		if err := a.exportAuditEvents(ctx, out, accountID); err != nil {
+			log.Error("failed to export audit events", sl.Err(err))
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+	out.close()
+
+	if out.err != nil {
+		log.Error("failed to write export", sl.Err(out.err))
+		return fmt.Errorf("%s: %w", op, out.err)
+	}
+
+	a.publish(ctx, events.AccountDataExported{
+		AccountID:  accountID,
+		ActorID:    actorID,
+		OccurredAt: now,
+	})
+
+	log.Info("account data exported")
+
+	return nil
+}
+
+// exportAuditEvents writes the account's audit events to out as a JSON array, one
+// page at a time.
+func (a *Auth) exportAuditEvents(ctx context.Context, out *exportWriter, accountID int64) error {
+	filter := models.AuditFilter{
+		AccountID: accountID,
+		Limit:     maxAuditPageSize,
+	}
+
+	out.raw("[")
+	var n int
+	for {
+		page, err := a.auditProvider.QueryAuditLog(ctx, filter)
+		if err != nil {
+			return err
+		}
+
+		for _, event := range page {
+			if n > 0 {
+				out.raw(",")
+			}
+			out.value(auditEventExport{
+				ID:         event.ID,
+				Type:       event.Type,
+				ActorID:    event.ActorID,
+				IPAddress:  event.IPAddress,
+				OccurredAt: event.OccurredAt,
+				Payload:    auditPayload(event.Payload),
+			})
+			n++
+		}
+
+		if out.err != nil || len(page) < filter.Limit {
+			break
+		}
+		filter.AfterID = page[len(page)-1].ID
+	}
+	out.raw("]")
+
+	return nil
+}
+
+// auditPayload embeds an audit event's payload as is. Payloads are written as JSON,
+// but one that isn't is exported as a string rather than failing the export.
+func auditPayload(payload string) json.RawMessage {
+	if json.Valid([]byte(payload)) {
+		return json.RawMessage(payload)
+	}
+
+	quoted, _ := json.Marshal(payload)
+
+	return quoted
+}
+
+// exportProfile converts a profile for the export. UpdatedAt is left out for
+// accounts that never saved one.
+func exportProfile(p models.Profile) *profileExport {
+	profile := &profileExport{
+		DisplayName: p.DisplayName,
+		GivenName:   p.GivenName,
+		FamilyName:  p.FamilyName,
+		AvatarURL:   p.AvatarURL,
+		Locale:      p.Locale,
+		Timezone:    p.Timezone,
+		Metadata:    p.Metadata,
+	}
+	if !p.UpdatedAt.IsZero() {
+		profile.UpdatedAt = &p.UpdatedAt
+	}
+
+	return profile
+}
+
+func exportSessions(sessions []models.Session) []sessionExport {
+	exported := make([]sessionExport, 0, len(sessions))
+	for _, s := range sessions {
+		exported = append(exported, sessionExport{
+			ID:               s.SID,
+			AppID:            s.AppID,
+			UserAgent:        s.UserAgent,
+			IPAddress:        s.IPAddress,
+			DeviceID:         s.DeviceID,
+			OS:               s.OS,
+			Browser:          s.Browser,
+			Scopes:           s.Scopes,
+			CreatedAt:        s.CreatedAt,
+			LastSeenAt:       s.LastSeenAt,
+			ExpiresAt:        s.ExpiresAt,
+			RefreshExpiresAt: s.RefreshExpiresAt,
+			Revoked:          s.Revoked,
+			RevokedReason:    s.RevokedReason,
+		})
+	}
+
+	return exported
+}
+
+func exportLogins(logins []models.DeviceLogin) []loginExport {
+	exported := make([]loginExport, 0, len(logins))
+	for _, l := range logins {
+		exported = append(exported, loginExport(l))
+	}
+
+	return exported
+}
+
+// exportWriter writes a JSON object field by field, so long lists can be streamed
+// instead of built in memory. The first error is kept and later writes are skipped.
+type exportWriter struct {
+	w      io.Writer
+	fields int
+	err    error
+}
+
+// field writes the next field of the object.
+func (e *exportWriter) field(name string, v any) {
+	e.key(name)
+	e.value(v)
+}
+
+// key starts the next field of the object; its value is written next.
+func (e *exportWriter) key(name string) {
+	if e.fields == 0 {
+		e.raw("{")
+	} else {
+		e.raw(",")
+	}
+	e.fields++
+
+	e.value(name)
+	e.raw(":")
+}
+
+func (e *exportWriter) value(v any) {
+	if e.err != nil {
+		return
+	}
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		e.err = err
+		return
+	}
+
+	_, e.err = e.w.Write(b)
+}
+
+func (e *exportWriter) raw(s string) {
+	if e.err != nil {
+		return
+	}
+
+	_, e.err = io.WriteString(e.w, s)
+}
+
+// close ends the object.
+func (e *exportWriter) close() {
+	if e.fields == 0 {
+		e.raw("{")
+	}
+	e.raw("}\n")
+}
@@ -0,0 +1,99 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"sso/internal/domain/models"
+	"sso/internal/lib/logger/sl"
+	"sso/internal/lib/passhash"
+)
+
+// acquireHashSlot blocks until a password hashing slot is free or ctx is done.
+// Without a configured limit it never blocks.
+func (a *Auth) acquireHashSlot(ctx context.Context) (release func(), err error) {
+	if a.hashSlots == nil {
+		return func() {}, nil
+	}
+
+	select {
+	case a.hashSlots <- struct{}{}:
+		return func() { <-a.hashSlots }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (a *Auth) hashPassword(ctx context.Context, password string) ([]byte, error) {
+	release, err := a.acquireHashSlot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	return a.passwordHasher().Hash(password)
+}
+
+func (a *Auth) comparePassword(ctx context.Context, hash []byte, password string) error {
+	release, err := a.acquireHashSlot(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	return passhash.Compare(hash, password, a.pepper)
+}
+
+// passwordHasher is the configured hasher, peppered if a pepper is set.
+func (a *Auth) passwordHasher() passhash.Hasher {
+	if len(a.pepper) == 0 {
+		return a.hasher
+	}
+
+	return passhash.Peppered{Hasher: a.hasher, Pepper: a.pepper}
+}
+
+// rehashPassword moves the account to the configured hash algorithm and parameters
+// after a successful login with password, if its hash was made with others. The
+// swap is skipped if the password changed in the meantime, and failures are only
+// logged: the login itself already succeeded.
+func (a *Auth) rehashPassword(ctx context.Context, log *slog.Logger, account models.Account, password string) {
+	if !a.passwordHasher().NeedsRehash(account.PassHash) {
+		return
+	}
+
+	newHash, err := a.hashPassword(ctx, password)
+	if err != nil {
+		log.Warn("failed to rehash password", sl.Err(err))
+		return
+	}
+
+	if err := a.accountSaver.RehashPassword(ctx, account.ID, account.PassHash, newHash); err != nil {
+		log.Warn("failed to save rehashed password", sl.Err(err))
+		return
+	}
+
+	log.Info("password rehashed")
+}
+
+// isContextErr reports whether err came from giving up on a hashing slot rather
+// than from the hash comparison itself.
+func isContextErr(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// compareDummyPassword burns the same hashing work as a real comparison, so callers
+// can't tell a missing account from a wrong password by response time.
+func (a *Auth) compareDummyPassword(ctx context.Context, password string) error {
+	a.dummyHashOnce.Do(func() {
+		a.dummyHash, _ = a.passwordHasher().Hash("dummy-password")
+	})
+
+	err := a.comparePassword(ctx, a.dummyHash, password)
+	if isContextErr(err) {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,82 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"sso/internal/lib/passhash"
+)
+
+// slowHasher hashes with bcrypt after a delay, recording the most hashes it ran at once.
+type slowHasher struct {
+	passhash.Bcrypt
+	inFlight atomic.Int32
+	peak     atomic.Int32
+}
+
+func (h *slowHasher) Hash(password string) ([]byte, error) {
+	n := h.inFlight.Add(1)
+	defer h.inFlight.Add(-1)
+
+	for {
+		peak := h.peak.Load()
+		if n <= peak || h.peak.CompareAndSwap(peak, n) {
+			break
+		}
+	}
+
+	time.Sleep(10 * time.Millisecond)
+
+	return h.Bcrypt.Hash(password)
+}
+
+func TestHashConcurrencyBound(t *testing.T) {
+	const limit = 3
+
+	hasher := &slowHasher{Bcrypt: passhash.Bcrypt{Cost: bcrypt.MinCost}}
+	a, _ := newTestAuth(t, WithPasswordHasher(hasher), WithHashConcurrency(limit))
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := a.hashPassword(context.Background(), testPassword); err != nil {
+				errs <- err
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Errorf("hash: %v", err)
+	}
+	if peak := hasher.peak.Load(); peak > limit {
+		t.Errorf("%d hashes ran at once, want at most %d", peak, limit)
+	}
+}
+
+func TestHashConcurrencyQueueHonorsDeadline(t *testing.T) {
+	a, _ := newTestAuth(t, WithHashConcurrency(1))
+
+	release, err := a.acquireHashSlot(context.Background())
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	defer release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	if _, err := a.hashPassword(ctx, testPassword); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("queued hash error = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
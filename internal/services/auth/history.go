@@ -0,0 +1,48 @@
+package auth
+
+import (
+	"context"
+	"errors"
+)
+
+var ErrPasswordReused = errors.New("password was used recently")
+
+// PasswordHistoryStore keeps hashes of the passwords accounts used before.
+type PasswordHistoryStore interface {
+	RetirePassword(ctx context.Context, accountId int64, passHash []byte, keep int) error
+	PasswordHistory(ctx context.Context, accountId int64, limit int) ([][]byte, error)
+}
+
+// checkPasswordReuse returns ErrPasswordReused if password is the account's current
+// password or one of the ones it had before, as far back as the history depth.
+func (a *Auth) checkPasswordReuse(ctx context.Context, accountID int64, currentHash []byte, password string) error {
+	if a.passwordHistory == nil {
+		return nil
+	}
+
+	hashes, err := a.passwordHistory.PasswordHistory(ctx, accountID, a.passwordHistoryDepth-1)
+	if err != nil {
+		return err
+	}
+
+	for _, hash := range append([][]byte{currentHash}, hashes...) {
+		err := a.comparePassword(ctx, hash, password)
+		if err == nil {
+			return ErrPasswordReused
+		}
+		if isContextErr(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// retirePassword records the account's outgoing password hash in its history.
+func (a *Auth) retirePassword(ctx context.Context, accountID int64, passHash []byte) error {
+	if a.passwordHistory == nil || a.passwordHistoryDepth <= 1 {
+		return nil
+	}
+
+	return a.passwordHistory.RetirePassword(ctx, accountID, passHash, a.passwordHistoryDepth-1)
+}
@@ -0,0 +1,160 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sso/internal/domain/models"
+	"sso/internal/lib/phone"
+	"sso/internal/storage"
+	"strings"
+)
+
+// IdentifierNormalizer maps the identifiers a person may type (email variants,
+// unicode forms) to the canonical form accounts are stored and looked up by.
+type IdentifierNormalizer interface {
+	Normalize(identifier string) string
+}
+
+type IdentifierNormalizerFunc func(identifier string) string
+
+func (f IdentifierNormalizerFunc) Normalize(identifier string) string {
+	return f(identifier)
+}
+
+// DefaultIdentifierNormalizer trims surrounding whitespace and lowercases.
+var DefaultIdentifierNormalizer = IdentifierNormalizerFunc(func(identifier string) string {
+	return strings.ToLower(strings.TrimSpace(identifier))
+})
+
+// loginIdentifier normalizes an identifier typed to log in: phone numbers to E.164,
+// anything else with the identifier normalizer. Every way of writing a number then
+// shares one lockout and throttle key.
+func (a *Auth) loginIdentifier(identifier string) string {
+	if number, err := phone.Normalize(identifier); err == nil {
+		return number
+	}
+
+	return a.identifierNormalizer.Normalize(identifier)
+}
+
+// loginIdentifiers returns every identifier the account can log in with, as
+// loginIdentifier normalizes them, e.g. to lift lockouts kept per identifier.
+func (a *Auth) loginIdentifiers(account models.Account) []string {
+	identifiers := []string{a.identifierNormalizer.Normalize(account.Email)}
+	if account.Username != "" {
+		identifiers = append(identifiers, account.Username)
+	}
+	if account.Phone != "" {
+		identifiers = append(identifiers, account.Phone)
+	}
+
+	return identifiers
+}
+
+// accountByIdentifier looks up the account logging in to appID by the identifier
+// loginIdentifier returned: by phone number for E.164 numbers, by username for
+// other identifiers without an @ and by email otherwise. With per-app identifiers
+// the lookup is limited to accounts registered with that app.
+func (a *Auth) accountByIdentifier(ctx context.Context, identifier string, appID int32) (models.Account, error) {
+	if strings.HasPrefix(identifier, "+") {
+		account, err := a.accountByPhone(ctx, identifier, appID)
+		if !errors.Is(err, storage.ErrAccountNotFound) {
+			return account, err
+		}
+	} else if isUsername(identifier) {
+		account, err := a.accountByUsername(ctx, identifier, appID)
+		// Emails were never validated, so an account may have one without an @.
+		if !errors.Is(err, storage.ErrAccountNotFound) {
+			return account, err
+		}
+	}
+
+	if a.perAppIdentifiers {
+		return a.accountProvider.AccountByEmailInApp(ctx, identifier, appID)
+	}
+
+	return a.accountProvider.AccountByEmail(ctx, identifier)
+}
+
+func (a *Auth) accountByPhone(ctx context.Context, number string, appID int32) (models.Account, error) {
+	if a.perAppIdentifiers {
+		return a.accountProvider.AccountByPhoneInApp(ctx, number, appID)
+	}
+
+	return a.accountProvider.AccountByPhone(ctx, number)
+}
+
+func (a *Auth) accountByUsername(ctx context.Context, username string, appID int32) (models.Account, error) {
+	if a.perAppIdentifiers {
+		return a.accountProvider.AccountByUsernameInApp(ctx, username, appID)
+	}
+
+	return a.accountProvider.AccountByUsername(ctx, username)
+}
+
+// checkIdentifierAvailable enforces global identifier uniqueness, returning
+// storage.ErrAccountExists if any app already has an account with email. Per-app
+// uniqueness is left to the storage's (email, app_id) constraint.
+func (a *Auth) checkIdentifierAvailable(ctx context.Context, email string) error {
+	if a.perAppIdentifiers {
+		return nil
+	}
+
+	_, err := a.accountProvider.AccountByEmail(ctx, email)
+	switch {
+	case err == nil:
+		return storage.ErrAccountExists
+	case errors.Is(err, storage.ErrAccountNotFound):
+		return nil
+	default:
+		return fmt.Errorf("failed to check identifier: %w", err)
+	}
+}
+
+// checkEmailAvailable returns storage.ErrAccountExists if email is taken: by any
+// account or, with per-app identifiers, by one registered with appID.
+func (a *Auth) checkEmailAvailable(ctx context.Context, email string, appID int32) error {
+	var err error
+	if a.perAppIdentifiers {
+		_, err = a.accountProvider.AccountByEmailInApp(ctx, email, appID)
+	} else {
+		_, err = a.accountProvider.AccountByEmail(ctx, email)
+	}
+	switch {
+	case err == nil:
+		return storage.ErrAccountExists
+	case errors.Is(err, storage.ErrAccountNotFound):
+		return nil
+	default:
+		return fmt.Errorf("failed to check email: %w", err)
+	}
+}
+
+// checkUsernameAvailable returns storage.ErrUsernameExists if username is taken: by
+// any account or, with per-app identifiers, by one registered with appID.
+func (a *Auth) checkUsernameAvailable(ctx context.Context, username string, appID int32) error {
+	_, err := a.accountByUsername(ctx, username, appID)
+	switch {
+	case err == nil:
+		return storage.ErrUsernameExists
+	case errors.Is(err, storage.ErrAccountNotFound):
+		return nil
+	default:
+		return fmt.Errorf("failed to check username: %w", err)
+	}
+}
+
+// checkPhoneAvailable returns storage.ErrPhoneExists if the number is taken: by any
+// account or, with per-app identifiers, by one registered with appID.
+func (a *Auth) checkPhoneAvailable(ctx context.Context, number string, appID int32) error {
+	_, err := a.accountByPhone(ctx, number, appID)
+	switch {
+	case err == nil:
+		return storage.ErrPhoneExists
+	case errors.Is(err, storage.ErrAccountNotFound):
+		return nil
+	default:
+		return fmt.Errorf("failed to check phone number: %w", err)
+	}
+}
@@ -0,0 +1,115 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	ssov1 "github.com/dariasmyr/protos/gen/go/sso"
+
+	"sso/internal/storage"
+)
+
+func TestLoginNormalizesIdentifier(t *testing.T) {
+	stripDots := IdentifierNormalizerFunc(func(identifier string) string {
+		identifier = DefaultIdentifierNormalizer.Normalize(identifier)
+		local, domain, found := strings.Cut(identifier, "@")
+		if !found {
+			return identifier
+		}
+		return strings.ReplaceAll(local, ".", "") + "@" + domain
+	})
+
+	tests := []struct {
+		name       string
+		normalizer IdentifierNormalizer
+		identifier string
+		wantErr    error
+	}{
+		{name: "exact", identifier: "jane.doe@example.com"},
+		{name: "case", identifier: "Jane.Doe@Example.COM"},
+		{name: "whitespace", identifier: "  jane.doe@example.com\t"},
+		{name: "dots kept by default", identifier: "janedoe@example.com", wantErr: ErrInvalidCredentials},
+		{name: "custom normalizer", normalizer: stripDots, identifier: "J.aneDoe@example.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var opts []Option
+			if tt.normalizer != nil {
+				opts = append(opts, WithIdentifierNormalizer(tt.normalizer))
+			}
+			a, st := newTestAuth(t, opts...)
+			appID := newTestApp(t, st)
+			registerTestAccount(t, a, appID, " Jane.Doe@example.com")
+
+			_, err := a.Login(context.Background(), &ssov1.LoginRequest{Email: tt.identifier, Password: testPassword, AppId: appID, UserAgent: testUserAgent, IpAddress: testIP})
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("login error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRegisterRejectsNormalizedDuplicate(t *testing.T) {
+	ctx := context.Background()
+	a, st := newTestAuth(t)
+	appID := newTestApp(t, st)
+	registerTestAccount(t, a, appID, "jane@example.com")
+
+	_, err := a.Register(ctx, &ssov1.RegisterRequest{Email: " JANE@example.com ", Password: testPassword, AppId: appID})
+	if err == nil {
+		t.Fatal("registered a case variant of an existing email")
+	}
+}
+
+func TestIdentifierScope(t *testing.T) {
+	tests := []struct {
+		name   string
+		perApp bool
+		// wantErr is the error registering the same email with a second app gives.
+		wantErr error
+	}{
+		{name: "global-unique", wantErr: storage.ErrAccountExists},
+		{name: "per-app-unique", perApp: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			a, st := newTestAuth(t, WithPerAppIdentifiers(tt.perApp))
+			firstApp := newTestApp(t, st)
+			secondApp := newTestApp(t, st)
+			accounts := map[int32]int64{firstApp: registerTestAccount(t, a, firstApp, "user@example.com")}
+
+			resp, err := a.Register(ctx, &ssov1.RegisterRequest{Email: "user@example.com", Password: testPassword, AppId: secondApp})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("second register error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			accounts[secondApp] = resp.GetAccountId()
+			if accounts[secondApp] == accounts[firstApp] {
+				t.Fatalf("both apps got account %d", accounts[firstApp])
+			}
+
+			_, err = a.Register(ctx, &ssov1.RegisterRequest{Email: "user@example.com", Password: testPassword, AppId: secondApp})
+			if !errors.Is(err, storage.ErrAccountExists) {
+				t.Errorf("register again with the same app: error = %v, want %v", err, storage.ErrAccountExists)
+			}
+
+			for appID, accountID := range accounts {
+				token := loginTestAccount(t, a, appID, "user@example.com").GetToken()
+				session, err := a.CurrentSession(ctx, token)
+				if err != nil {
+					t.Fatalf("current session: %v", err)
+				}
+				if session.AccountID != accountID {
+					t.Errorf("login to app %d got account %d, want %d", appID, session.AccountID, accountID)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sso/internal/domain/models"
+	"sso/internal/lib/logger/sl"
+	"time"
+)
+
+var ErrSessionIdle = errors.New("session idle for too long")
+
+// idleTouchInterval bounds how often validating a session writes its LastSeenAt.
+// Idle timeouts are therefore only accurate to about this much.
+const idleTouchInterval = time.Minute
+
+// IdlePolicy expires sessions that haven't been used for a while, independent of
+// their absolute TTL. Apps and Roles override Timeout for sessions in that app or
+// of accounts with that role in it; when both apply the shorter limit wins. A zero
+// limit means no idle timeout.
+type IdlePolicy struct {
+	Timeout time.Duration
+	Apps    map[int32]time.Duration
+	Roles   map[models.AccountRole]time.Duration
+}
+
+func (p IdlePolicy) enabled() bool {
+	return p.Timeout > 0 || len(p.Apps) > 0 || len(p.Roles) > 0
+}
+
+// limit returns the idle timeout for a session in appID of an account with role.
+func (p IdlePolicy) limit(appID int32, role models.AccountRole) time.Duration {
+	appLimit, appSet := p.Apps[appID]
+	roleLimit, roleSet := p.Roles[role]
+
+	switch {
+	case appSet && roleSet:
+		return shorterLimit(appLimit, roleLimit)
+	case appSet:
+		return appLimit
+	case roleSet:
+		return roleLimit
+	default:
+		return p.Timeout
+	}
+}
+
+// shorterLimit returns the stricter of two limits, where zero means unlimited.
+func shorterLimit(a, b time.Duration) time.Duration {
+	if a <= 0 || (b > 0 && b < a) {
+		return b
+	}
+
+	return a
+}
+
+// sweepCutoff returns the longest idle timeout any session can have, for sweeping
+// idle sessions that are never presented again. It returns false if some sessions
+// have no idle timeout, so none can be swept by last activity alone.
+func (p IdlePolicy) sweepCutoff() (time.Duration, bool) {
+	if p.Timeout <= 0 {
+		return 0, false
+	}
+
+	longest := p.Timeout
+	for _, limit := range p.Apps {
+		if limit <= 0 {
+			return 0, false
+		}
+		longest = max(longest, limit)
+	}
+	for _, limit := range p.Roles {
+		if limit <= 0 {
+			return 0, false
+		}
+		longest = max(longest, limit)
+	}
+
+	return longest, true
+}
+
+// checkIdle revokes session with RevokedSessionIdle and returns true if it has
+// been unused for longer than the idle limit of its app and the account's role.
+// Otherwise it records the session as seen now, at most once per idleTouchInterval.
+func (a *Auth) checkIdle(ctx context.Context, log *slog.Logger, session models.Session, appID int32, role models.AccountRole) (bool, error) {
+	if !a.idlePolicy.enabled() {
+		return false, nil
+	}
+
+	idleFor := time.Since(session.LastSeenAt)
+
+	if limit := a.idlePolicy.limit(appID, role); limit > 0 && idleFor > limit {
+		log.Info("session idle", slog.Time("last_seen_at", session.LastSeenAt), slog.Duration("limit", limit))
+		if err := a.sessionSaver.RevokeSessionWithReason(ctx, session.SID, models.RevokedSessionIdle); err != nil {
+			return true, err
+		}
+		return true, nil
+	}
+
+	if idleFor >= idleTouchInterval {
+		// A missed touch only makes the session look idle a little early.
+		if err := a.sessionSaver.TouchSession(ctx, session.SID, time.Now()); err != nil {
+			log.Warn("failed to record session activity", sl.Err(err))
+		}
+	}
+
+	return false, nil
+}
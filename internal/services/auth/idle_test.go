@@ -0,0 +1,38 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"sso/internal/domain/models"
+)
+
+func TestIdlePolicyLimit(t *testing.T) {
+	policy := IdlePolicy{
+		Timeout: time.Hour,
+		Apps:    map[int32]time.Duration{1: 30 * time.Minute, 2: 0},
+		Roles:   map[models.AccountRole]time.Duration{models.ADMIN: 10 * time.Minute},
+	}
+
+	tests := []struct {
+		name  string
+		appID int32
+		role  models.AccountRole
+		want  time.Duration
+	}{
+		{name: "default", appID: 9, role: models.USER, want: time.Hour},
+		{name: "app override", appID: 1, role: models.USER, want: 30 * time.Minute},
+		{name: "role override", appID: 9, role: models.ADMIN, want: 10 * time.Minute},
+		{name: "shorter of app and role", appID: 1, role: models.ADMIN, want: 10 * time.Minute},
+		{name: "app disables timeout", appID: 2, role: models.USER, want: 0},
+		{name: "role wins over disabled app", appID: 2, role: models.ADMIN, want: 10 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := policy.limit(tt.appID, tt.role); got != tt.want {
+				t.Errorf("limit = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,172 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"sso/internal/domain/models"
+	"sso/internal/lib/jwt"
+	"sso/internal/lib/logger/sl"
+	"sso/internal/storage"
+)
+
+// Introspect tells whether token, an access or refresh token issued by the
+// service, is active and describes it, with the semantics of OAuth 2.0 token
+// introspection (RFC 7662), for resource servers that can't verify tokens
+// themselves. tokenTypeHint, models.TokenTypeAccess or models.TokenTypeRefresh,
+// says which kind to try first; both are tried either way. Unknown, expired and
+// revoked tokens, and tokens whose account may no longer use them, are reported
+// inactive rather than failing. Introspection has no side effects: it neither
+// renews tokens nor counts as session activity.
+func (a *Auth) Introspect(ctx context.Context, token string, tokenTypeHint string) (models.TokenIntrospection, error) {
+	const op = "Auth.Introspect"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.String("token_type_hint", tokenTypeHint),
+	)
+
+	introspectors := []func(context.Context, string) (models.TokenIntrospection, error){a.introspectAccessToken, a.introspectRefreshToken}
+	if tokenTypeHint == models.TokenTypeRefresh {
+		introspectors[0], introspectors[1] = introspectors[1], introspectors[0]
+	}
+
+	for _, introspect := range introspectors {
+		introspection, err := introspect(ctx, token)
+		if err != nil {
+			log.Error("failed to introspect token", sl.Err(err))
+			return models.TokenIntrospection{}, fmt.Errorf("%s: %w", op, err)
+		}
+		if introspection.Active {
+			log.Info("token active", slog.String("token_type", introspection.TokenType))
+			return introspection, nil
+		}
+	}
+
+	log.Info("token inactive")
+
+	return models.TokenIntrospection{}, nil
+}
+
+// introspectAccessToken describes token if it is an active access token. It
+// applies the checks of ValidateAccountSessionFrom that don't change state.
+func (a *Auth) introspectAccessToken(ctx context.Context, token string) (models.TokenIntrospection, error) {
+	session, app, claims, err := a.sessionForToken(ctx, token)
+	if err != nil {
+		if errors.Is(err, storage.ErrSessionNotFound) {
+			return models.TokenIntrospection{}, nil
+		}
+		return models.TokenIntrospection{}, err
+	}
+
+	if a.tokenExpired(claims) || session.Revoked || session.ExpiresAt.Before(time.Now()) {
+		return models.TokenIntrospection{}, nil
+	}
+
+	revoked, _, err := a.tokenRevoked(ctx, claims)
+	if err != nil {
+		return models.TokenIntrospection{}, err
+	}
+	if revoked {
+		return models.TokenIntrospection{}, nil
+	}
+
+	account, err := a.accountProvider.AccountById(ctx, session.AccountID)
+	if err != nil {
+		return models.TokenIntrospection{}, err
+	}
+
+	if claims.TokenVersion < account.TokenVersion || claims.AppTokenVersion < app.TokenVersion {
+		return models.TokenIntrospection{}, nil
+	}
+	if account.Status != models.ACTIVE && !a.lenientStatusCheck {
+		return models.TokenIntrospection{}, nil
+	}
+	if account.RequiresPasswordChange {
+		return models.TokenIntrospection{}, nil
+	}
+
+	audience := claims.Audience
+	if len(audience) == 0 {
+		audience = []string{jwt.Audience(app)}
+	}
+
+	return models.TokenIntrospection{
+		Active:    true,
+		TokenType: models.TokenTypeAccess,
+		Subject:   claims.Subject,
+		Audience:  audience,
+		Scopes:    claims.Scopes,
+		ClientID:  strconv.FormatInt(app.ID, 10),
+		Issuer:    claims.Issuer,
+		SessionID: session.SID,
+		TokenID:   claims.ID,
+		ExpiresAt: claims.ExpiresAt,
+		IssuedAt:  claims.IssuedAt,
+		NotBefore: claims.NotBefore,
+	}, nil
+}
+
+// introspectRefreshToken describes token if it is a refresh token that
+// RefreshAccountSession would accept, leaving aside the caller's IP and client.
+func (a *Auth) introspectRefreshToken(ctx context.Context, token string) (models.TokenIntrospection, error) {
+	if _, err := a.preValidateRefreshToken(ctx, token); err != nil {
+		if errors.Is(err, storage.ErrSessionNotFound) {
+			return models.TokenIntrospection{}, nil
+		}
+		return models.TokenIntrospection{}, err
+	}
+
+	session, err := a.sessionByRefreshToken(ctx, token)
+	if err != nil {
+		if errors.Is(err, storage.ErrSessionNotFound) {
+			return models.TokenIntrospection{}, nil
+		}
+		return models.TokenIntrospection{}, err
+	}
+
+	now := time.Now()
+	if session.Revoked || session.ExpiresAt.Before(now) || session.RefreshExpiresAt.Before(now) {
+		return models.TokenIntrospection{}, nil
+	}
+	if a.refreshFamilyMaxAge > 0 && now.Sub(session.FamilyStartedAt) > a.refreshFamilyMaxAge {
+		return models.TokenIntrospection{}, nil
+	}
+
+	account, app, err := a.sessionAccount(ctx, session)
+	if err != nil {
+		if errors.Is(err, storage.ErrAppNotFound) {
+			return models.TokenIntrospection{}, nil
+		}
+		return models.TokenIntrospection{}, err
+	}
+
+	if loginStatusError(account.Status) != nil {
+		return models.TokenIntrospection{}, nil
+	}
+
+	account, err = a.accountForApp(ctx, account, int32(app.ID))
+	if err != nil {
+		if errors.Is(err, ErrNoAppMembership) {
+			return models.TokenIntrospection{}, nil
+		}
+		return models.TokenIntrospection{}, err
+	}
+
+	return models.TokenIntrospection{
+		Active:    true,
+		TokenType: models.TokenTypeRefresh,
+		Subject:   a.subjectFormat.Subject(account.ID),
+		Audience:  []string{jwt.Audience(app)},
+		Scopes:    sessionScopes(session, account.Scopes),
+		ClientID:  strconv.FormatInt(app.ID, 10),
+		Issuer:    a.tokenIssuer,
+		SessionID: session.SID,
+		ExpiresAt: session.ExpiresAt,
+		IssuedAt:  session.CreatedAt,
+	}, nil
+}
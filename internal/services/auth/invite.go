@@ -0,0 +1,308 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	ssov1 "github.com/dariasmyr/protos/gen/go/sso"
+
+	"sso/internal/domain/events"
+	"sso/internal/domain/models"
+	"sso/internal/lib/logger/sl"
+	"sso/internal/storage"
+)
+
+var (
+	ErrInvitesDisabled          = errors.New("invites are not configured")
+	ErrInvalidInvite            = errors.New("invalid or expired invite")
+	ErrSelfRegistrationDisabled = errors.New("app does not allow self-registration")
+)
+
+// InviteStore keeps invites by the hash of their token. ClaimInvite must be
+// atomic: of concurrent claims of one invite at most one may succeed.
+type InviteStore interface {
+	SaveInvite(ctx context.Context, tokenHash string, email string, role models.AccountRole, appId int32, createdBy int64, expiresAt time.Time) (int64, error)
+	InviteByToken(ctx context.Context, tokenHash string) (models.Invite, error)
+	PendingInvites(ctx context.Context, appId int32, now time.Time) ([]models.Invite, error)
+	ClaimInvite(ctx context.Context, id int64, accountId int64, now time.Time) error
+	RevokeInvite(ctx context.Context, id int64, now time.Time) error
+}
+
+// CreateInvite invites email to register with appID in role and returns the
+// invite with its token, for the admin to pass on to the invitee. The invite
+// expires after ttl, or the configured default for a non-positive ttl. Only a
+// hash of the token is stored. Returns storage.ErrAccountExists if an account
+// already has the email. Admin only.
+func (a *Auth) CreateInvite(ctx context.Context, actorID int64, email string, role models.AccountRole, appID int32, ttl time.Duration) (models.Invite, string, error) {
+	const op = "Auth.CreateInvite"
+
+	email = a.identifierNormalizer.Normalize(email)
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int64("actor_id", actorID),
+		slog.Int64("app_id", int64(appID)),
+		slog.String("email", email),
+	)
+
+	if a.invites == nil {
+		return models.Invite{}, "", fmt.Errorf("%s: %w", op, ErrInvitesDisabled)
+	}
+
+	if err := a.requireAdmin(ctx, actorID); err != nil {
+		log.Warn("admin check failed", sl.Err(err))
+		return models.Invite{}, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	if !strings.Contains(email, "@") {
+		log.Info("invalid email")
+		return models.Invite{}, "", fmt.Errorf("%s: %w", op, ErrInvalidEmail)
+	}
+
+	if _, err := a.appForLogin(ctx, appID); err != nil {
+		log.Warn("invalid app", sl.Err(err))
+		return models.Invite{}, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.checkEmailAvailable(ctx, email, appID); err != nil {
+		if errors.Is(err, storage.ErrAccountExists) {
+			log.Info("email already registered")
+		} else {
+			log.Error("failed to check email", sl.Err(err))
+		}
+		return models.Invite{}, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	if ttl <= 0 {
+		ttl = a.inviteTTL
+	}
+
+	token, err := a.secrets.Token()
+	if err != nil {
+		log.Error("failed to generate invite token", sl.Err(err))
+		return models.Invite{}, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	now := time.Now()
+	invite := models.Invite{
+		Email:     email,
+		Role:      role,
+		AppID:     appID,
+		CreatedBy: actorID,
+		ExpiresAt: now.Add(ttl),
+		CreatedAt: now,
+	}
+
+	invite.ID, err = a.invites.SaveInvite(ctx, hashCode(token), email, role, appID, actorID, invite.ExpiresAt)
+	if err != nil {
+		log.Error("failed to save invite", sl.Err(err))
+		return models.Invite{}, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	a.publish(ctx, events.InviteCreated{
+		InviteID:   invite.ID,
+		Email:      email,
+		AppID:      appID,
+		Role:       role,
+		ActorID:    actorID,
+		ExpiresAt:  invite.ExpiresAt,
+		OccurredAt: now,
+	})
+
+	log.Info("invite created", slog.Int64("invite_id", invite.ID))
+
+	return invite, token, nil
+}
+
+// ListInvites returns the pending invites of appID, or of all apps for appID 0,
+// oldest first. Admin only.
+func (a *Auth) ListInvites(ctx context.Context, actorID int64, appID int32) ([]models.Invite, error) {
+	const op = "Auth.ListInvites"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int64("actor_id", actorID),
+		slog.Int64("app_id", int64(appID)),
+	)
+
+	if a.invites == nil {
+		return nil, fmt.Errorf("%s: %w", op, ErrInvitesDisabled)
+	}
+
+	if err := a.requireAdmin(ctx, actorID); err != nil {
+		log.Warn("admin check failed", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	invites, err := a.invites.PendingInvites(ctx, appID, time.Now())
+	if err != nil {
+		log.Error("failed to list invites", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return invites, nil
+}
+
+// RevokeInvite revokes a pending invite, so its token can no longer be accepted.
+// Returns storage.ErrInviteNotFound if there is no such pending invite. Admin only.
+func (a *Auth) RevokeInvite(ctx context.Context, actorID int64, inviteID int64) error {
+	const op = "Auth.RevokeInvite"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int64("actor_id", actorID),
+		slog.Int64("invite_id", inviteID),
+	)
+
+	if a.invites == nil {
+		return fmt.Errorf("%s: %w", op, ErrInvitesDisabled)
+	}
+
+	if err := a.requireAdmin(ctx, actorID); err != nil {
+		log.Warn("admin check failed", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	now := time.Now()
+	if err := a.invites.RevokeInvite(ctx, inviteID, now); err != nil {
+		if errors.Is(err, storage.ErrInviteNotFound) {
+			log.Info("no pending invite")
+		} else {
+			log.Error("failed to revoke invite", sl.Err(err))
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	a.publish(ctx, events.InviteRevoked{
+		InviteID:   inviteID,
+		ActorID:    actorID,
+		OccurredAt: now,
+	})
+
+	log.Info("invite revoked")
+
+	return nil
+}
+
+// checkSelfRegistration returns ErrSelfRegistrationDisabled if accounts can only
+// join the app by invite. Unknown apps are left to the rest of registration.
+func (a *Auth) checkSelfRegistration(ctx context.Context, appID int32) error {
+	if appID == 0 {
+		return nil
+	}
+
+	app, err := a.appProvider.App(ctx, appID)
+	if err != nil {
+		if errors.Is(err, storage.ErrAppNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	if !app.SelfRegistration {
+		return ErrSelfRegistrationDisabled
+	}
+
+	return nil
+}
+
+// SetAppSelfRegistration sets whether accounts may register with the app on their
+// own. Apps that don't allow it are invite-only: Register fails with
+// ErrSelfRegistrationDisabled and accounts join with AcceptInvite. Admin only.
+func (a *Auth) SetAppSelfRegistration(ctx context.Context, actorID int64, appID int32, allowed bool) error {
+	const op = "Auth.SetAppSelfRegistration"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int64("actor_id", actorID),
+		slog.Int64("app_id", int64(appID)),
+		slog.Bool("allowed", allowed),
+	)
+
+	if err := a.requireAdmin(ctx, actorID); err != nil {
+		log.Warn("admin check failed", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.appSaver.SetAppSelfRegistration(ctx, appID, allowed); err != nil {
+		log.Error("failed to set self-registration", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("app self-registration updated")
+
+	return nil
+}
+
+// AcceptInvite registers the invited email with the invite's app and role, with
+// password and, optionally, username, as registration does. The invite is used
+// up; unknown, accepted, revoked and expired invites return ErrInvalidInvite.
+// Invites don't need a CAPTCHA: the token is proof enough.
+func (a *Auth) AcceptInvite(ctx context.Context, inviteToken string, password string, username string) (*ssov1.RegisterResponse, error) {
+	const op = "Auth.AcceptInvite"
+
+	log := a.log.With(
+		slog.String("op", op),
+	)
+
+	if a.invites == nil {
+		return nil, fmt.Errorf("%s: %w", op, ErrInvitesDisabled)
+	}
+
+	invite, err := a.invites.InviteByToken(ctx, hashCode(inviteToken))
+	if err != nil {
+		if errors.Is(err, storage.ErrInviteNotFound) {
+			log.Info("unknown invite")
+			return nil, fmt.Errorf("%s: %w", op, ErrInvalidInvite)
+		}
+		log.Error("failed to get invite", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log = log.With(
+		slog.Int64("invite_id", invite.ID),
+		slog.String("email", invite.Email),
+	)
+
+	if invite.AcceptedAt != nil || invite.RevokedAt != nil || !invite.ExpiresAt.After(time.Now()) {
+		log.Info("invite no longer pending")
+		return nil, fmt.Errorf("%s: %w", op, ErrInvalidInvite)
+	}
+
+	id, err := a.createAccount(ctx, log, invite.Email, password, invite.Role, invite.AppID, username, "")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Claimed only now, so an invitee whose password was rejected can try again.
+	// Losing a race for the invite undoes the registration.
+	if err := a.invites.ClaimInvite(ctx, invite.ID, id, time.Now()); err != nil {
+		if errors.Is(err, storage.ErrInviteNotFound) {
+			log.Warn("invite accepted concurrently, rolling back")
+			err = ErrInvalidInvite
+		} else {
+			log.Error("failed to claim invite, rolling back", sl.Err(err))
+		}
+		if err := a.accountSaver.DeleteAccount(context.WithoutCancel(ctx), id); err != nil {
+			log.Error("failed to roll back account", sl.Err(err))
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	a.publish(ctx, events.InviteAccepted{
+		InviteID:   invite.ID,
+		AccountID:  id,
+		AppID:      invite.AppID,
+		OccurredAt: time.Now(),
+	})
+
+	log.Info("invite accepted", slog.Int64("account_id", id))
+
+	return &ssov1.RegisterResponse{
+		AccountId: id,
+	}, nil
+}
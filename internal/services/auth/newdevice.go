@@ -0,0 +1,138 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"sso/internal/domain/events"
+	"sso/internal/domain/models"
+	"sso/internal/lib/logger/sl"
+	"sso/internal/storage"
+)
+
+// KnownDeviceStore remembers the devices and IPs accounts signed in from and keeps
+// the tokens that sign a new device out again. ConsumeDeviceRevokeToken must be
+// atomic: of concurrent calls with the same token at most one may succeed.
+type KnownDeviceStore interface {
+	RecordDeviceLogin(ctx context.Context, accountId int64, deviceID string, ipAddress string, at time.Time) (unseen bool, err error)
+	SaveDeviceRevokeToken(ctx context.Context, tokenHash string, accountId int64, deviceID string, expiresAt time.Time) error
+	ConsumeDeviceRevokeToken(ctx context.Context, tokenHash string, now time.Time) (accountId int64, deviceID string, err error)
+	DeleteExpiredDeviceRevokeTokens(ctx context.Context, before time.Time, limit int) (deleted int64, err error)
+}
+
+// notifyNewDevice records the login of session and, if the account hasn't signed in
+// from its device and IP before, sends NewDeviceLogin to the notifier in the
+// background. Failures are logged and never fail the login.
+func (a *Auth) notifyNewDevice(ctx context.Context, log *slog.Logger, account models.Account, app models.App, sid string, userAgent string, ipAddress string) {
+	if a.newDeviceNotifier == nil {
+		return
+	}
+
+	now := time.Now()
+	device := sessionDevice(userAgent)
+
+	unseen, err := a.knownDevices.RecordDeviceLogin(ctx, account.ID, device.ID, ipAddress, now)
+	if err != nil {
+		log.Error("failed to record device login", sl.Err(err))
+		return
+	}
+	if !unseen {
+		return
+	}
+
+	log = log.With(slog.String("device_id", device.ID))
+	log.Info("login from new device")
+
+	revokeToken, err := a.secrets.Token()
+	if err != nil {
+		log.Error("failed to generate device revoke token", sl.Err(err))
+		return
+	}
+
+	expiresAt := now.Add(a.deviceRevokeTTL)
+	if err := a.knownDevices.SaveDeviceRevokeToken(ctx, hashCode(revokeToken), account.ID, device.ID, expiresAt); err != nil {
+		log.Error("failed to save device revoke token", sl.Err(err))
+		return
+	}
+
+	event := events.NewDeviceLogin{
+		AccountID:       account.ID,
+		Email:           account.Email,
+		AppID:           int32(app.ID),
+		SessionID:       sid,
+		DeviceID:        device.ID,
+		IPAddress:       ipAddress,
+		UserAgent:       userAgent,
+		OS:              device.OS,
+		Browser:         device.Browser,
+		RevokeToken:     revokeToken,
+		RevokeExpiresAt: expiresAt,
+		OccurredAt:      now,
+	}
+
+	if a.geoIP != nil {
+		location, ok, err := a.geoIP.Resolve(ipAddress)
+		if err != nil {
+			log.Warn("failed to locate ip", sl.Err(err))
+		}
+		if ok {
+			event.Location = location.String()
+		}
+	}
+
+	go func(ctx context.Context) {
+		if err := a.newDeviceNotifier.Publish(ctx, event); err != nil {
+			log.Error("failed to send new device notification", sl.Err(err))
+		}
+	}(context.WithoutCancel(ctx))
+}
+
+// RevokeDeviceByToken signs the account out of the device a NewDeviceLogin
+// notification was about, given the notification's revoke token, with
+// RevokedDeviceRejected. It needs no session, so the link works from the mail the
+// notification became. Unknown, used and expired tokens return ErrInvalidCode.
+func (a *Auth) RevokeDeviceByToken(ctx context.Context, revokeToken string) error {
+	const op = "Auth.RevokeDeviceByToken"
+
+	log := a.log.With(
+		slog.String("op", op),
+	)
+
+	if a.knownDevices == nil {
+		return fmt.Errorf("%s: %w", op, ErrInvalidCode)
+	}
+
+	accountID, deviceID, err := a.knownDevices.ConsumeDeviceRevokeToken(ctx, hashCode(revokeToken), time.Now())
+	if err != nil {
+		if errors.Is(err, storage.ErrCodeNotFound) {
+			log.Info("invalid device revoke token")
+			return fmt.Errorf("%s: %w", op, ErrInvalidCode)
+		}
+		log.Error("failed to consume device revoke token", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log = log.With(slog.Int64("account_id", accountID), slog.String("device_id", deviceID))
+
+	device, err := a.accountDevice(ctx, accountID, deviceID)
+	if err != nil {
+		if errors.Is(err, ErrDeviceNotFound) {
+			log.Info("device has no active sessions")
+			return nil
+		}
+		log.Error("failed to get device", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.revokeDeviceSessions(ctx, accountID, device, models.RevokedDeviceRejected); err != nil {
+		log.Error("failed to revoke sessions", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("device signed out from notification", slog.Int("sessions", len(device.Sessions)))
+
+	return nil
+}
@@ -0,0 +1,91 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"sso/internal/domain/models"
+	"sso/internal/lib/jwt"
+	"sso/internal/lib/logger/sl"
+)
+
+// authentication is how a login authenticated the account, as its ID token tells
+// the app.
+type authentication struct {
+	// methods are the amr values, see jwt.AMRPassword and the rest.
+	methods []string
+	nonce   string
+}
+
+// withSecondFactor adds a verified TOTP or recovery code to the methods.
+func (authn authentication) withSecondFactor(verified bool) authentication {
+	if verified {
+		authn.methods = append(authn.methods, jwt.AMROTP, jwt.AMRMultiFactor)
+	}
+
+	return authn
+}
+
+// SetAppOIDC sets whether the app is OIDC-enabled: logins to it that can return
+// one, see LoginWithOptions, get an ID token alongside the access token. Admin
+// only.
+func (a *Auth) SetAppOIDC(ctx context.Context, actorID int64, appID int32, enabled bool) error {
+	const op = "Auth.SetAppOIDC"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int64("actor_id", actorID),
+		slog.Int64("app_id", int64(appID)),
+		slog.Bool("enabled", enabled),
+	)
+
+	if err := a.requireAdmin(ctx, actorID); err != nil {
+		log.Warn("admin check failed", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.appSaver.SetAppOIDCEnabled(ctx, appID, enabled); err != nil {
+		log.Error("failed to set oidc", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("app oidc updated")
+
+	return nil
+}
+
+// idToken issues the ID token of a login to an OIDC-enabled app, empty for other
+// apps. It carries the account's whole profile, whatever profile claims access
+// tokens are configured with.
+func (a *Auth) idToken(ctx context.Context, account models.Account, app models.App, sid string, authn authentication) (string, error) {
+	if !app.OIDCEnabled {
+		return "", nil
+	}
+
+	var profile map[string]string
+	if a.profiles != nil {
+		p, err := a.profile(ctx, account.ID)
+		if err != nil {
+			return "", err
+		}
+
+		profile = make(map[string]string, len(profileClaims))
+		for name, value := range profileClaims {
+			profile[name] = value(p)
+		}
+	}
+
+	return jwt.NewIDToken(jwt.IDToken{
+		Account:   account,
+		App:       app,
+		Issuer:    a.tokenIssuer,
+		Subject:   a.subjectFormat,
+		SessionID: sid,
+		AuthTime:  time.Now(),
+		Nonce:     authn.nonce,
+		Methods:   authn.methods,
+		Profile:   profile,
+	}, a.tokenTTL)
+}
@@ -0,0 +1,542 @@
+package auth
+
+import (
+	"time"
+
+	"sso/internal/domain/models"
+	"sso/internal/lib/captcha"
+	"sso/internal/lib/geoip"
+	"sso/internal/lib/jwt"
+	"sso/internal/lib/lockout"
+	"sso/internal/lib/passhash"
+	"sso/internal/lib/passwordpolicy"
+	"sso/internal/lib/pwned"
+	"sso/internal/lib/ratelimit"
+	"sso/internal/lib/secret"
+	"sso/internal/lib/sessionid"
+)
+
+// Option configures optional Auth behaviour.
+type Option func(*Auth)
+
+// WithRenewalWindow makes ValidateAccountSession mint a fresh access token when the
+// presented one expires within window. Zero disables renewal.
+func WithRenewalWindow(window time.Duration) Option {
+	return func(a *Auth) {
+		a.renewalWindow = window
+	}
+}
+
+// WithEventPublisher sets where domain events such as AccountStatusChanged go.
+// Without it events are dropped.
+func WithEventPublisher(publisher EventPublisher) Option {
+	return func(a *Auth) {
+		a.eventPublisher = publisher
+	}
+}
+
+// WithHashConcurrency bounds how many password hashes and comparisons run at once.
+// Excess callers wait until a slot frees up or their context is done. Zero or a
+// negative limit leaves hashing unbounded.
+func WithHashConcurrency(limit int) Option {
+	return func(a *Auth) {
+		if limit > 0 {
+			a.hashSlots = make(chan struct{}, limit)
+		}
+	}
+}
+
+// WithIdentifierNormalizer replaces DefaultIdentifierNormalizer for registration,
+// login and account lookups.
+func WithIdentifierNormalizer(normalizer IdentifierNormalizer) Option {
+	return func(a *Auth) {
+		a.identifierNormalizer = normalizer
+	}
+}
+
+// WithSingleSession makes each login revoke the account's other sessions. Revoked
+// sessions report RevokedLoggedOutElsewhere on validation.
+func WithSingleSession(enabled bool) Option {
+	return func(a *Auth) {
+		a.singleSession = enabled
+	}
+}
+
+// WithRefreshFamilyMaxAge caps how long a refresh chain may be extended by rotation,
+// counted from the login that started it. Zero leaves the chain unbounded.
+func WithRefreshFamilyMaxAge(maxAge time.Duration) Option {
+	return func(a *Auth) {
+		a.refreshFamilyMaxAge = maxAge
+	}
+}
+
+// WithNewIPRefreshPolicy sets what happens when a session is refreshed from an IP
+// outside the subnet it was last used from.
+func WithNewIPRefreshPolicy(policy NewIPRefreshPolicy) Option {
+	return func(a *Auth) {
+		a.newIPRefreshPolicy = policy
+	}
+}
+
+// WithSSOTicketTTL sets how long SSO tickets stay redeemable. Defaults to a minute.
+func WithSSOTicketTTL(ttl time.Duration) Option {
+	return func(a *Auth) {
+		if ttl > 0 {
+			a.ssoTicketTTL = ttl
+		}
+	}
+}
+
+// WithProvisioner sends AccountRegistered events for new accounts to provisioner
+// instead of the event publisher. With blocking, registration waits for it and is
+// rolled back if it fails; otherwise it is sent in the background.
+func WithProvisioner(provisioner EventPublisher, blocking bool) Option {
+	return func(a *Auth) {
+		a.provisioner = provisioner
+		a.blockingProvisioning = blocking
+	}
+}
+
+// WithLenientStatusCheck makes ValidateAccountSession accept tokens of accounts
+// that are no longer active until the token expires, instead of rejecting them
+// right away. Such tokens are not renewed.
+func WithLenientStatusCheck(lenient bool) Option {
+	return func(a *Auth) {
+		a.lenientStatusCheck = lenient
+	}
+}
+
+// WithSessionIDGenerator sets how session IDs are generated. Defaults to ULIDs,
+// which sort by creation time.
+func WithSessionIDGenerator(generator sessionid.Generator) Option {
+	return func(a *Auth) {
+		a.sessionIDs = generator
+	}
+}
+
+// WithSecretGenerator replaces the crypto/rand generator behind refresh tokens,
+// one-time codes and SSO tickets, e.g. with a deterministic one in tests.
+func WithSecretGenerator(generator secret.Generator) Option {
+	return func(a *Auth) {
+		a.secrets = generator
+	}
+}
+
+// WithLockout locks identifiers out of login per tracker's escalating schedule.
+// Locked identifiers get ErrAccountLocked without a password check, or with conceal
+// an ErrInvalidCredentials timed like a wrong password, so the response doesn't
+// reveal that the account exists and is locked.
+func WithLockout(tracker *lockout.Tracker, conceal bool) Option {
+	return func(a *Auth) {
+		a.lockout = tracker
+		a.concealLockout = conceal
+	}
+}
+
+// WithIPLockout locks client IPs out of login per tracker's schedule, whichever
+// identifiers they try. Failures for unknown identifiers count too.
+func WithIPLockout(tracker *lockout.Tracker) Option {
+	return func(a *Auth) {
+		a.ipLockout = tracker
+	}
+}
+
+// WithRolePermissions maps roles to their permissions, which ValidateAccountSession
+// then reports for the session's account. Roles missing from the map have none.
+func WithRolePermissions(permissions map[models.AccountRole][]string) Option {
+	return func(a *Auth) {
+		a.rolePermissions = permissions
+	}
+}
+
+// WithPerAppIdentifiers lets the same email register separately with each app.
+// Logins then only find the account registered with the app being logged in to.
+// By default emails are unique across all apps.
+func WithPerAppIdentifiers(perApp bool) Option {
+	return func(a *Auth) {
+		a.perAppIdentifiers = perApp
+	}
+}
+
+// WithTarpit delays logins flagged by detector by delay and then always fails them
+// with ErrInvalidCredentials. Unflagged logins are unaffected.
+func WithTarpit(detector TarpitDetector, delay time.Duration) Option {
+	return func(a *Auth) {
+		a.tarpitDetector = detector
+		a.tarpitDelay = delay
+	}
+}
+
+// WithMaxSessions limits how many active sessions an account may hold; zero means
+// unlimited. When a login would exceed it, the oldest sessions are revoked, or with
+// reject the login fails with ErrSessionLimitReached. Ignored in single-session mode.
+func WithMaxSessions(limit int, reject bool) Option {
+	return func(a *Auth) {
+		a.maxSessions = limit
+		a.rejectOverQuota = reject
+	}
+}
+
+// WithSessionQuotaProvider looks up per-account session limits, e.g. by plan, that
+// take precedence over the WithMaxSessions default. saver backs
+// SetAccountSessionQuota and may be nil.
+func WithSessionQuotaProvider(provider SessionQuotaProvider, saver SessionQuotaSaver) Option {
+	return func(a *Auth) {
+		a.sessionQuotas = provider
+		a.sessionQuotaSaver = saver
+	}
+}
+
+// WithInstantRoleChange makes GrantAppRole invalidate the account's outstanding
+// access tokens, so a role change takes effect at once instead of at the next
+// refresh. Refresh tokens stay valid and yield tokens with the new role.
+func WithInstantRoleChange(enabled bool) Option {
+	return func(a *Auth) {
+		a.instantRoleChange = enabled
+	}
+}
+
+// WithAuditProvider enables QueryAuditLog over provider.
+func WithAuditProvider(provider AuditProvider) Option {
+	return func(a *Auth) {
+		a.auditProvider = provider
+	}
+}
+
+// WithTOTPStore enables TOTP enrollment, rotation and recovery codes over store, and
+// makes Login require a second factor from accounts with TOTP enrolled.
+func WithTOTPStore(store TOTPStore) Option {
+	return func(a *Auth) {
+		a.totpStore = store
+	}
+}
+
+// WithPasskeyStore enables passkey registration and sign-in over store, for apps
+// that have a relying party configured.
+func WithPasskeyStore(store PasskeyStore) Option {
+	return func(a *Auth) {
+		a.passkeyStore = store
+	}
+}
+
+// WithPasswordPolicy makes registration, password changes and resets reject
+// passwords that break policy.
+func WithPasswordPolicy(policy *passwordpolicy.Policy) Option {
+	return func(a *Auth) {
+		a.passwordPolicy = policy
+	}
+}
+
+// WithPasswordHistory makes password changes and resets refuse the account's last
+// depth passwords, the current one included. Depth below one disables the check.
+func WithPasswordHistory(store PasswordHistoryStore, depth int) Option {
+	return func(a *Auth) {
+		if depth > 0 {
+			a.passwordHistory = store
+			a.passwordHistoryDepth = depth
+		}
+	}
+}
+
+// WithPasswordHasher sets how new passwords are hashed. Existing hashes of any
+// supported algorithm keep verifying and are rehashed with hasher on the next
+// successful login. Defaults to bcrypt.
+func WithPasswordHasher(hasher passhash.Hasher) Option {
+	return func(a *Auth) {
+		a.hasher = hasher
+	}
+}
+
+// WithPasswordPepper mixes pepper into every password hashed from now on. Hashes
+// made without it keep verifying and are peppered on the next successful login.
+// Once set, the pepper must be kept: peppered hashes don't verify without it.
+func WithPasswordPepper(pepper []byte) Option {
+	return func(a *Auth) {
+		a.pepper = pepper
+	}
+}
+
+// WithBreachedPasswordCheck looks passwords up in known breaches on registration,
+// password change and reset. With enforce a breached password is refused,
+// otherwise it is only logged. Lookups are cut off after timeout, zero for none,
+// and fail open. Login never checks.
+func WithBreachedPasswordCheck(checker pwned.Checker, enforce bool, timeout time.Duration) Option {
+	return func(a *Auth) {
+		a.breachChecker = checker
+		a.enforceBreachCheck = enforce
+		a.breachTimeout = timeout
+	}
+}
+
+// WithDeviceNameStore lets users name their devices with RenameDevice.
+func WithDeviceNameStore(store DeviceNameStore) Option {
+	return func(a *Auth) {
+		a.deviceNames = store
+	}
+}
+
+// WithNewDeviceNotifier sends NewDeviceLogin to notifier, e.g. a mailer webhook,
+// when an account signs in from a device and IP it hasn't used before. The
+// notification's revoke token is valid for revokeTTL.
+func WithNewDeviceNotifier(notifier EventPublisher, store KnownDeviceStore, revokeTTL time.Duration) Option {
+	return func(a *Auth) {
+		a.newDeviceNotifier = notifier
+		a.knownDevices = store
+		a.deviceRevokeTTL = revokeTTL
+	}
+}
+
+// WithGeoIP locates login IPs, for new-device notifications and login risk checks.
+func WithGeoIP(resolver geoip.Resolver) Option {
+	return func(a *Auth) {
+		a.geoIP = resolver
+	}
+}
+
+// WithLoginRiskPolicy checks password logins for anomalous locations, see
+// LoginRiskPolicy. It needs WithGeoIP; without it every login passes.
+func WithLoginRiskPolicy(policy LoginRiskPolicy) Option {
+	return func(a *Auth) {
+		a.loginRisk = policy
+	}
+}
+
+// WithReauthWindow sets how long Reauthenticate elevates a session for. Zero keeps
+// the default of 10 minutes.
+func WithReauthWindow(window time.Duration) Option {
+	return func(a *Auth) {
+		if window > 0 {
+			a.reauthWindow = window
+		}
+	}
+}
+
+// WithRecentAuthRequired makes account management through an access token, such as
+// LogoutEverywhere and RevokeDevice, require a login within maxAge or an elevated
+// session, see RequireRecentAuth. Zero doesn't require it.
+func WithRecentAuthRequired(maxAge time.Duration) Option {
+	return func(a *Auth) {
+		a.recentAuthMaxAge = maxAge
+	}
+}
+
+// WithCaptcha lets logins and registrations prove with a solved CAPTCHA that a
+// person is making them. Identifiers and IPs that go over the failed-login limit
+// must solve one for flagTTL, and with onRegister every registration must.
+func WithCaptcha(verifier captcha.Verifier, flagTTL time.Duration, onRegister bool) Option {
+	return func(a *Auth) {
+		a.captcha = verifier
+		a.captchaFlags = captcha.NewFlags(flagTTL)
+		a.captchaOnRegister = onRegister
+	}
+}
+
+// WithAccountDeletion enables DeleteAccount and RestoreAccount. Deleted accounts
+// can be restored, and are purged by PurgeDeletedAccounts, after retention.
+func WithAccountDeletion(store DeletedAccountStore, retention time.Duration) Option {
+	return func(a *Auth) {
+		a.deletedAccounts = store
+		a.deletionRetention = retention
+	}
+}
+
+// WithAccountDataExport enables ExportAccountData, reading the account's sessions
+// and login history from store.
+func WithAccountDataExport(store AccountDataStore) Option {
+	return func(a *Auth) {
+		a.accountData = store
+	}
+}
+
+// WithSMSLogin enables RequestSMSCode and LoginWithSMSCode. Login codes are valid
+// for codeTTL and texted through sender. A non-nil limiter caps code requests per
+// phone number.
+func WithSMSLogin(sender SMSSender, codeTTL time.Duration, limiter *ratelimit.Limiter) Option {
+	return func(a *Auth) {
+		a.smsSender = sender
+		a.smsCodeTTL = codeTTL
+		a.smsRequests = limiter
+	}
+}
+
+// WithProfiles enables GetProfile and UpdateProfile, keeping profiles in store.
+// Tokens carry the named profile claims, see IsProfileClaim, for fields that are
+// set. Metadata is capped at maxMetadataSize bytes, zero meaning no cap.
+func WithProfiles(store ProfileStore, claims []string, maxMetadataSize int) Option {
+	return func(a *Auth) {
+		a.profiles = store
+		a.profileClaims = claims
+		a.profileMetadataMax = maxMetadataSize
+	}
+}
+
+// WithEmailChange enables RequestEmailChange and ConfirmEmailChange, keeping
+// pending addresses in store. Confirmation tokens are valid for ttl and delivered
+// as EmailChangeRequested events to publisher, e.g. a mailer webhook, which is
+// also sent EmailChanged to notify the old address. A non-nil limiter caps change
+// requests per account.
+func WithEmailChange(publisher EventPublisher, store EmailChangeStore, ttl time.Duration, limiter *ratelimit.Limiter) Option {
+	return func(a *Auth) {
+		a.emailChanges = publisher
+		a.emailChangeStore = store
+		a.emailChangeTTL = ttl
+		a.emailChangeRequests = limiter
+	}
+}
+
+// WithInvites enables CreateInvite, AcceptInvite and the other invite methods,
+// keeping invites in store. Invites created without a ttl of their own expire
+// after ttl.
+func WithInvites(store InviteStore, ttl time.Duration) Option {
+	return func(a *Auth) {
+		a.invites = store
+		a.inviteTTL = ttl
+	}
+}
+
+// WithRememberMe sets the refresh token TTL of sessions whose login asked to be
+// remembered, see LoginOptions.RememberMe. Zero gives them the regular refresh token
+// TTL.
+func WithRememberMe(refreshTTL time.Duration) Option {
+	return func(a *Auth) {
+		a.rememberMeRefreshTTL = refreshTTL
+	}
+}
+
+// WithSessionBinding binds sessions to the IP and user agent of their client, see
+// SessionBinding.
+func WithSessionBinding(binding SessionBinding) Option {
+	return func(a *Auth) {
+		a.sessionBinding = binding
+	}
+}
+
+// WithSigningKeyOverlap sets how long an app's previous signing key keeps
+// verifying tokens after a rotation. It is never shorter than the access token
+// TTL, so tokens signed just before a rotation verify until they expire.
+func WithSigningKeyOverlap(overlap time.Duration) Option {
+	return func(a *Auth) {
+		a.signingKeyOverlap = overlap
+	}
+}
+
+// WithIdleTimeout expires sessions left unused for longer than policy allows, both
+// on validation and on refresh.
+func WithIdleTimeout(policy IdlePolicy) Option {
+	return func(a *Auth) {
+		a.idlePolicy = policy
+	}
+}
+
+// WithSubjectFormat sets how account IDs are rendered in the sub claim of issued
+// tokens and resolved by AccountBySubject. The default is jwt.RawSubject.
+func WithSubjectFormat(format jwt.SubjectFormat) Option {
+	return func(a *Auth) {
+		a.subjectFormat = format
+	}
+}
+
+// WithClaimsEnricher adds the claims of enricher to every access token issued, see
+// jwt.ClaimsEnricher.
+func WithClaimsEnricher(enricher jwt.ClaimsEnricher) Option {
+	return func(a *Auth) {
+		a.claimsEnricher = enricher
+	}
+}
+
+// WithTokenIssuer sets the iss claim of issued tokens, which services verifying
+// them with jwt.ParseAndValidate should expect. Without it tokens carry none.
+func WithTokenIssuer(issuer string) Option {
+	return func(a *Auth) {
+		a.tokenIssuer = issuer
+	}
+}
+
+// WithClockSkewLeeway lets access tokens through validation for leeway past their
+// expiry, for instances whose clocks drift apart from the one that issued them.
+func WithClockSkewLeeway(leeway time.Duration) Option {
+	return func(a *Auth) {
+		a.clockSkewLeeway = leeway
+	}
+}
+
+// WithTokenRevocationList keeps a revocation list of access tokens by jti in
+// store. Logout, RevokeSession and status changes that disable an account put
+// the tokens they cut off on it, session validation rejects listed tokens, and
+// IsTokenRevoked lets services verifying tokens themselves check it.
+func WithTokenRevocationList(store TokenRevocationStore) Option {
+	return func(a *Auth) {
+		a.revokedTokens = store
+	}
+}
+
+// WithJWTRefreshTokens issues refresh tokens as JWTs signed with the app's key,
+// carrying the session's sid and its refresh family's ID in a fam claim, instead
+// of opaque random strings. Refreshes verify their signature and expiry before
+// looking the session up, which is still required, so revoking a session keeps
+// revoking its refresh token. Opaque refresh tokens issued before keep working.
+func WithJWTRefreshTokens() Option {
+	return func(a *Auth) {
+		a.jwtRefreshTokens = true
+	}
+}
+
+// WithKeyProvider signs the tokens of apps registered with SetAppSigningKeyRef
+// with the keys provider holds, which are resolved whenever an app is loaded.
+func WithKeyProvider(provider KeyProvider) Option {
+	return func(a *Auth) {
+		a.keyProvider = provider
+		a.appProvider = keyProviderApps{AppProvider: a.appProvider, keys: provider}
+	}
+}
+
+// WithReactivation enables RequestReactivation and ReactivateAccount. Reactivation
+// tokens are valid for ttl and delivered as ReactivationRequested events to
+// publisher, e.g. a mailer webhook.
+func WithReactivation(publisher EventPublisher, ttl time.Duration) Option {
+	return func(a *Auth) {
+		a.reactivations = publisher
+		a.reactivationTTL = ttl
+	}
+}
+
+// WithPasswordReset enables RequestPasswordReset and ResetPassword. Reset tokens
+// are valid for ttl and delivered as PasswordResetRequested events to publisher,
+// e.g. a mailer webhook, or texted through the WithSMSLogin sender when the caller
+// prefers SMS. A non-nil limiter caps reset requests per email.
+func WithPasswordReset(publisher EventPublisher, ttl time.Duration, limiter *ratelimit.Limiter) Option {
+	return func(a *Auth) {
+		a.passwordResets = publisher
+		a.passwordResetTTL = ttl
+		a.resetRequests = limiter
+	}
+}
+
+// WithFailedLoginThrottle counts failed logins per identifier in limiter and delays
+// every failure response by delay. Identifiers over the limit get ErrLoginThrottled.
+func WithFailedLoginThrottle(limiter *ratelimit.Limiter, delay time.Duration) Option {
+	return func(a *Auth) {
+		a.failedLogins = limiter
+		a.failureDelay = delay
+	}
+}
+
+// WithUniformUnknownAccountThrottle makes logins for unknown identifiers consume a
+// failure slot and wait out the failure delay like a wrong password does.
+func WithUniformUnknownAccountThrottle(enabled bool) Option {
+	return func(a *Auth) {
+		a.throttleUnknownAccounts = enabled
+	}
+}
+
+// WithTokenTTLJitter shortens each access token's lifetime by a random amount of up
+// to jitter, so tokens issued together don't all expire together. Lifetimes never
+// exceed the configured TTL. Zero, negative values and values not below the TTL
+// disable jitter.
+func WithTokenTTLJitter(jitter time.Duration) Option {
+	return func(a *Auth) {
+		if jitter > 0 && jitter < a.tokenTTL {
+			a.tokenTTLJitter = jitter
+		}
+	}
+}
@@ -0,0 +1,428 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	ssov1 "github.com/dariasmyr/protos/gen/go/sso"
+
+	"sso/internal/domain/models"
+	"sso/internal/lib/logger/sl"
+	"sso/internal/lib/webauthn"
+	"sso/internal/storage"
+)
+
+var (
+	ErrPasskeysDisabled = errors.New("passkeys are not configured for the app")
+	ErrInvalidPasskey   = errors.New("invalid passkey response")
+	ErrPasskeyExists    = errors.New("passkey already registered")
+	// ErrPasskeyCloned means the authenticator's signature counter went backwards,
+	// which happens when a credential is used from a copy of the authenticator.
+	ErrPasskeyCloned = errors.New("passkey signature counter regressed")
+)
+
+// PasskeyStore keeps registered WebAuthn credentials. UpdatePasskeySignCount must
+// only ever move the counter forward.
+type PasskeyStore interface {
+	SavePasskey(ctx context.Context, passkey models.Passkey) (int64, error)
+	Passkey(ctx context.Context, credentialID []byte) (models.Passkey, error)
+	PasskeyCredentialIDs(ctx context.Context, accountId int64) ([][]byte, error)
+	UpdatePasskeySignCount(ctx context.Context, id int64, signCount uint32, usedAt time.Time) error
+}
+
+const (
+	// passkeyChallengeTTL is how long a begun ceremony may take to finish.
+	passkeyChallengeTTL = 5 * time.Minute
+	// passkeyRegistrationMaxAge is how recently the caller must have signed in to
+	// add a passkey.
+	passkeyRegistrationMaxAge = 5 * time.Minute
+)
+
+// PasskeyCreationOptions is what the client passes to navigator.credentials.create().
+type PasskeyCreationOptions struct {
+	// Challenge is base64url-encoded.
+	Challenge string
+	RPID      string
+	RPName    string
+	UserID    []byte
+	UserName  string
+	// Algorithms are the accepted COSE algorithm identifiers.
+	Algorithms []int64
+	// ExcludeCredentials are the account's existing credentials, so the same
+	// authenticator isn't registered twice.
+	ExcludeCredentials [][]byte
+}
+
+// PasskeyAttestation is the client's answer to PasskeyCreationOptions.
+type PasskeyAttestation struct {
+	ClientDataJSON    []byte
+	AttestationObject []byte
+	// Name labels the passkey in the security settings view.
+	Name string
+}
+
+// PasskeyRequestOptions is what the client passes to navigator.credentials.get().
+type PasskeyRequestOptions struct {
+	// Challenge is base64url-encoded.
+	Challenge        string
+	RPID             string
+	AllowCredentials [][]byte
+}
+
+// PasskeyAssertion is the client's answer to PasskeyRequestOptions.
+type PasskeyAssertion struct {
+	CredentialID      []byte
+	ClientDataJSON    []byte
+	AuthenticatorData []byte
+	Signature         []byte
+}
+
+// BeginPasskeyRegistration starts adding a passkey to the account that owns the
+// presented access token, for the relying party of the token's app. Returns
+// ErrReauthRequired unless the caller signed in within the last few minutes, and
+// ErrPasskeysDisabled if the app has no relying party configured.
+func (a *Auth) BeginPasskeyRegistration(ctx context.Context, token string) (PasskeyCreationOptions, error) {
+	const op = "Auth.BeginPasskeyRegistration"
+
+	log := a.log.With(
+		slog.String("op", op),
+	)
+
+	if a.passkeyStore == nil {
+		return PasskeyCreationOptions{}, fmt.Errorf("%s: %w", op, ErrPasskeysDisabled)
+	}
+
+	account, app, err := a.passkeyRegistrant(ctx, token)
+	if err != nil {
+		log.Info("step-up check failed", sl.Err(err))
+		return PasskeyCreationOptions{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log = log.With(slog.Int64("account_id", account.ID), slog.Int64("app_id", app.ID))
+
+	if app.RPID == "" {
+		log.Info("app has no relying party")
+		return PasskeyCreationOptions{}, fmt.Errorf("%s: %w", op, ErrPasskeysDisabled)
+	}
+
+	existing, err := a.passkeyStore.PasskeyCredentialIDs(ctx, account.ID)
+	if err != nil {
+		log.Error("failed to get passkeys", sl.Err(err))
+		return PasskeyCreationOptions{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	challenge, err := a.passkeyChallenge(ctx, account.ID, models.CodePurposePasskeyRegistration)
+	if err != nil {
+		log.Error("failed to issue challenge", sl.Err(err))
+		return PasskeyCreationOptions{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("passkey registration started")
+
+	return PasskeyCreationOptions{
+		Challenge:          challenge,
+		RPID:               app.RPID,
+		RPName:             app.Name,
+		UserID:             []byte(strconv.FormatInt(account.ID, 10)),
+		UserName:           account.Email,
+		Algorithms:         webauthn.SupportedAlgorithms,
+		ExcludeCredentials: existing,
+	}, nil
+}
+
+// FinishPasskeyRegistration verifies the authenticator's response to
+// BeginPasskeyRegistration and stores the new credential. Each begun registration
+// can be finished once. Returns the passkey's ID.
+func (a *Auth) FinishPasskeyRegistration(ctx context.Context, token string, attestation PasskeyAttestation) (int64, error) {
+	const op = "Auth.FinishPasskeyRegistration"
+
+	log := a.log.With(
+		slog.String("op", op),
+	)
+
+	if a.passkeyStore == nil {
+		return 0, fmt.Errorf("%s: %w", op, ErrPasskeysDisabled)
+	}
+
+	account, app, err := a.passkeyRegistrant(ctx, token)
+	if err != nil {
+		log.Info("step-up check failed", sl.Err(err))
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log = log.With(slog.Int64("account_id", account.ID), slog.Int64("app_id", app.ID))
+
+	rp, err := relyingParty(app)
+	if err != nil {
+		log.Info("app has no relying party")
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	credential, challenge, err := rp.VerifyRegistration(attestation.ClientDataJSON, attestation.AttestationObject)
+	if err != nil {
+		log.Info("invalid attestation", sl.Err(err))
+		return 0, fmt.Errorf("%s: %w: %w", op, ErrInvalidPasskey, err)
+	}
+
+	if err := a.ConsumeOneTimeCode(ctx, account.ID, models.CodePurposePasskeyRegistration, challenge); err != nil {
+		log.Info("challenge rejected", sl.Err(err))
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	id, err := a.passkeyStore.SavePasskey(ctx, models.Passkey{
+		AccountID:    account.ID,
+		CredentialID: credential.ID,
+		PublicKey:    credential.PublicKey,
+		SignCount:    credential.SignCount,
+		Name:         attestation.Name,
+	})
+	if err != nil {
+		if errors.Is(err, storage.ErrPasskeyExists) {
+			log.Info("credential already registered")
+			return 0, fmt.Errorf("%s: %w", op, ErrPasskeyExists)
+		}
+		log.Error("failed to save passkey", sl.Err(err))
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("passkey registered", slog.Int64("passkey_id", id))
+
+	return id, nil
+}
+
+// BeginPasskeyLogin starts a passkey sign-in to the app for the account with the
+// given email. Unknown accounts and accounts without passkeys get a challenge
+// too, with no allowed credentials, so the response doesn't reveal which
+// accounts exist.
+func (a *Auth) BeginPasskeyLogin(ctx context.Context, email string, appID int32) (PasskeyRequestOptions, error) {
+	const op = "Auth.BeginPasskeyLogin"
+
+	email = a.loginIdentifier(email)
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.String("username", email),
+		slog.Int64("app_id", int64(appID)),
+	)
+
+	if a.passkeyStore == nil {
+		return PasskeyRequestOptions{}, fmt.Errorf("%s: %w", op, ErrPasskeysDisabled)
+	}
+
+	app, err := a.appForLogin(ctx, appID)
+	if err != nil {
+		log.Warn("invalid app", sl.Err(err))
+		return PasskeyRequestOptions{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if app.RPID == "" {
+		log.Info("app has no relying party")
+		return PasskeyRequestOptions{}, fmt.Errorf("%s: %w", op, ErrPasskeysDisabled)
+	}
+
+	account, err := a.accountByIdentifier(ctx, email, appID)
+	if err != nil {
+		if !errors.Is(err, storage.ErrAccountNotFound) {
+			log.Error("failed to get account", sl.Err(err))
+			return PasskeyRequestOptions{}, fmt.Errorf("%s: %w", op, err)
+		}
+
+		challenge, err := webauthn.NewChallenge()
+		if err != nil {
+			log.Error("failed to generate challenge", sl.Err(err))
+			return PasskeyRequestOptions{}, fmt.Errorf("%s: %w", op, err)
+		}
+
+		return PasskeyRequestOptions{Challenge: challenge, RPID: app.RPID}, nil
+	}
+
+	log = log.With(slog.Int64("account_id", account.ID))
+
+	credentials, err := a.passkeyStore.PasskeyCredentialIDs(ctx, account.ID)
+	if err != nil {
+		log.Error("failed to get passkeys", sl.Err(err))
+		return PasskeyRequestOptions{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	challenge, err := a.passkeyChallenge(ctx, account.ID, models.CodePurposePasskeyLogin)
+	if err != nil {
+		log.Error("failed to issue challenge", sl.Err(err))
+		return PasskeyRequestOptions{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return PasskeyRequestOptions{
+		Challenge:        challenge,
+		RPID:             app.RPID,
+		AllowCredentials: credentials,
+	}, nil
+}
+
+// FinishPasskeyLogin verifies the authenticator's response to BeginPasskeyLogin and
+// signs the account in to the app. The authenticator must have verified the user,
+// so the passkey stands in for both the password and the second factor. A
+// signature counter that went backwards fails with ErrPasskeyCloned.
+func (a *Auth) FinishPasskeyLogin(ctx context.Context, appID int32, assertion PasskeyAssertion, userAgent string, ipAddress string) (*ssov1.LoginResponse, error) {
+	const op = "Auth.FinishPasskeyLogin"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int64("app_id", int64(appID)),
+	)
+
+	if a.passkeyStore == nil {
+		return nil, fmt.Errorf("%s: %w", op, ErrPasskeysDisabled)
+	}
+
+	app, err := a.appForLogin(ctx, appID)
+	if err != nil {
+		log.Warn("invalid app", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	rp, err := relyingParty(app)
+	if err != nil {
+		log.Info("app has no relying party")
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	rp.RequireUserVerification = true
+
+	passkey, err := a.passkeyStore.Passkey(ctx, assertion.CredentialID)
+	if err != nil {
+		if errors.Is(err, storage.ErrPasskeyNotFound) {
+			log.Info("unknown credential")
+			return nil, fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
+		}
+		log.Error("failed to get passkey", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log = log.With(slog.Int64("account_id", passkey.AccountID), slog.Int64("passkey_id", passkey.ID))
+
+	signCount, challenge, err := rp.VerifyAssertion(webauthn.Credential{
+		ID:        passkey.CredentialID,
+		PublicKey: passkey.PublicKey,
+		SignCount: passkey.SignCount,
+	}, assertion.ClientDataJSON, assertion.AuthenticatorData, assertion.Signature)
+	if err != nil {
+		if errors.Is(err, webauthn.ErrSignCountRegressed) {
+			log.Warn("signature counter regressed, authenticator may be cloned",
+				slog.Int64("stored_sign_count", int64(passkey.SignCount)),
+			)
+			return nil, fmt.Errorf("%s: %w", op, ErrPasskeyCloned)
+		}
+		log.Info("invalid assertion", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
+	}
+
+	if err := a.ConsumeOneTimeCode(ctx, passkey.AccountID, models.CodePurposePasskeyLogin, challenge); err != nil {
+		log.Info("challenge rejected", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.passkeyStore.UpdatePasskeySignCount(ctx, passkey.ID, signCount, time.Now()); err != nil {
+		if errors.Is(err, storage.ErrPasskeyCounterStale) {
+			log.Warn("signature counter already advanced, authenticator may be cloned")
+			return nil, fmt.Errorf("%s: %w", op, ErrPasskeyCloned)
+		}
+		log.Error("failed to record passkey use", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	account, err := a.accountProvider.AccountById(ctx, passkey.AccountID)
+	if err != nil {
+		log.Error("failed to get account", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := loginStatusError(account.Status); err != nil {
+		log.Info("account status forbids login", slog.Int("status", int(account.Status)))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	account, err = a.accountForApp(ctx, account, appID)
+	if err != nil {
+		log.Warn("failed to resolve app role", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	response, _, err := a.startSession(ctx, log, account, app, userAgent, ipAddress, false, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("user logged in with passkey")
+
+	return response, nil
+}
+
+// SetAppRelyingParty configures passkeys for the app: rpID is the WebAuthn relying
+// party ID, a domain the app's origins belong to, and origins are the exact
+// origins responses may come from. An empty rpID turns passkeys off; existing
+// passkeys stay registered but can't be used. Admin only.
+func (a *Auth) SetAppRelyingParty(ctx context.Context, actorID int64, appID int32, rpID string, origins []string) error {
+	const op = "Auth.SetAppRelyingParty"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int64("actor_id", actorID),
+		slog.Int64("app_id", int64(appID)),
+		slog.String("rp_id", rpID),
+	)
+
+	if err := a.requireAdmin(ctx, actorID); err != nil {
+		log.Warn("admin check failed", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.appSaver.SetAppRelyingParty(ctx, appID, rpID, origins); err != nil {
+		log.Error("failed to set relying party", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("app relying party updated")
+
+	return nil
+}
+
+// passkeyRegistrant returns the account behind token, scoped to the token's app,
+// after checking that it authenticated recently.
+func (a *Auth) passkeyRegistrant(ctx context.Context, token string) (models.Account, models.App, error) {
+	if err := a.RequireRecentAuth(ctx, token, passkeyRegistrationMaxAge); err != nil {
+		return models.Account{}, models.App{}, err
+	}
+
+	session, err := a.CurrentSession(ctx, token)
+	if err != nil {
+		return models.Account{}, models.App{}, err
+	}
+
+	return a.sessionAccount(ctx, session)
+}
+
+// passkeyChallenge issues a ceremony challenge for the account. It is stored like a
+// one-time code, so finishing the ceremony consumes it and a replayed response is
+// refused; beginning another ceremony of the same kind replaces it.
+func (a *Auth) passkeyChallenge(ctx context.Context, accountID int64, purpose models.CodePurpose) (string, error) {
+	challenge, err := webauthn.NewChallenge()
+	if err != nil {
+		return "", err
+	}
+
+	if err := a.oneTimeCodeStore.SaveOneTimeCode(ctx, accountID, purpose, hashCode(challenge), time.Now().Add(passkeyChallengeTTL)); err != nil {
+		return "", err
+	}
+
+	return challenge, nil
+}
+
+func relyingParty(app models.App) (webauthn.RelyingParty, error) {
+	if app.RPID == "" {
+		return webauthn.RelyingParty{}, ErrPasskeysDisabled
+	}
+
+	return webauthn.RelyingParty{ID: app.RPID, Origins: app.RPOrigins}, nil
+}
@@ -0,0 +1,49 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sso/internal/lib/logger/sl"
+	"sso/internal/storage"
+)
+
+// CheckPassword reports whether password matches the account's current password,
+// for step-up and other sensitive flows. It has no session side effects, and an
+// unknown account takes as long as a wrong password. Mismatches return
+// ErrInvalidCredentials.
+func (a *Auth) CheckPassword(ctx context.Context, accountID int64, password string) (bool, error) {
+	const op = "Auth.CheckPassword"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int64("account_id", accountID),
+	)
+
+	account, err := a.accountProvider.AccountById(ctx, accountID)
+	if err != nil {
+		if errors.Is(err, storage.ErrAccountNotFound) {
+			log.Warn("account not found", sl.Err(err))
+			if err := a.compareDummyPassword(ctx, password); err != nil {
+				return false, fmt.Errorf("%s: %w", op, err)
+			}
+			return false, fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
+		}
+
+		log.Error("failed to get account", sl.Err(err))
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.comparePassword(ctx, account.PassHash, password); err != nil {
+		if isContextErr(err) {
+			log.Error("failed to verify password", sl.Err(err))
+			return false, fmt.Errorf("%s: %w", op, err)
+		}
+
+		log.Info("password mismatch")
+		return false, fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
+	}
+
+	return true, nil
+}
@@ -0,0 +1,50 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestCheckPassword(t *testing.T) {
+	tests := []struct {
+		name     string
+		unknown  bool
+		password string
+		want     bool
+		wantErr  error
+	}{
+		{name: "match", password: testPassword, want: true},
+		{name: "mismatch", password: "wrong-Password-1", wantErr: ErrInvalidCredentials},
+		{name: "empty", password: "", wantErr: ErrInvalidCredentials},
+		{name: "unknown account", unknown: true, password: testPassword, wantErr: ErrInvalidCredentials},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			a, st := newTestAuth(t)
+			appID := newTestApp(t, st)
+			accountID := registerTestAccount(t, a, appID, "user@example.com")
+			if tt.unknown {
+				accountID += 100
+			}
+
+			got, err := a.CheckPassword(ctx, accountID, tt.password)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("check error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("CheckPassword = %v, want %v", got, tt.want)
+			}
+
+			sessions, err := st.Sessions(ctx, accountID)
+			if err != nil {
+				t.Fatalf("sessions: %v", err)
+			}
+			if len(sessions) != 0 {
+				t.Errorf("CheckPassword created %d sessions", len(sessions))
+			}
+		})
+	}
+}
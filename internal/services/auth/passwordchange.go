@@ -0,0 +1,143 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"sso/internal/domain/events"
+	"sso/internal/domain/models"
+	"sso/internal/lib/logger/sl"
+)
+
+var ErrPasswordChangeRequired = errors.New("password change required")
+
+// clearPasswordChangeRequired lifts the account's password change requirement once
+// it set a new password of its own.
+func (a *Auth) clearPasswordChangeRequired(ctx context.Context, account models.Account) error {
+	if !account.RequiresPasswordChange {
+		return nil
+	}
+
+	return a.accountSaver.SetPasswordChangeRequired(ctx, account.ID, false)
+}
+
+// SetPasswordChangeRequired makes the account change its password at next login,
+// or lifts the requirement. Until it changes it, logins still succeed, but their
+// tokens carry the pwd_change claim, don't validate and are refused by methods
+// acting on the caller's session with ErrPasswordChangeRequired; ChangePassword
+// is all they are good for. Admin only.
+func (a *Auth) SetPasswordChangeRequired(ctx context.Context, actorID int64, accountID int64, required bool) error {
+	const op = "Auth.SetPasswordChangeRequired"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int64("actor_id", actorID),
+		slog.Int64("account_id", accountID),
+		slog.Bool("required", required),
+	)
+
+	if err := a.requireAdmin(ctx, actorID); err != nil {
+		log.Warn("admin check failed", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.accountSaver.SetPasswordChangeRequired(ctx, accountID, required); err != nil {
+		log.Error("failed to set password change requirement", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	a.publish(ctx, events.PasswordChangeRequirementChanged{
+		AccountID:  accountID,
+		ActorID:    actorID,
+		Required:   required,
+		OccurredAt: time.Now(),
+	})
+
+	log.Info("password change requirement updated")
+
+	return nil
+}
+
+// AdminResetPassword sets a temporary password for the account, e.g. for a user
+// locked out without access to their email. The password must meet the app's
+// policy. Every session of the account is revoked with RevokedPasswordReset, and
+// the account has to change the password at next login, see
+// SetPasswordChangeRequired. Admin only.
+func (a *Auth) AdminResetPassword(ctx context.Context, actorID int64, accountID int64, newPassword string) error {
+	const op = "Auth.AdminResetPassword"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int64("actor_id", actorID),
+		slog.Int64("account_id", accountID),
+	)
+
+	if err := a.requireAdmin(ctx, actorID); err != nil {
+		log.Warn("admin check failed", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	account, err := a.accountProvider.AccountById(ctx, accountID)
+	if err != nil {
+		log.Error("failed to get account", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.checkPasswordPolicy(account.AppId, newPassword); err != nil {
+		log.Info("new password rejected by policy", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.checkBreachedPassword(ctx, log, newPassword); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	passHash, err := a.hashPassword(ctx, newPassword)
+	if err != nil {
+		log.Error("failed to hash new password", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.retirePassword(ctx, account.ID, account.PassHash); err != nil {
+		log.Error("failed to record password history", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Required before the password is in place, so there is no moment in which it
+	// logs in to an unrestricted session.
+	if err := a.accountSaver.SetPasswordChangeRequired(ctx, account.ID, true); err != nil {
+		log.Error("failed to require password change", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.accountSaver.UpdatePassword(ctx, account.ID, passHash); err != nil {
+		log.Error("failed to update password", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.revokeOtherSessions(ctx, account.ID, "", models.RevokedPasswordReset); err != nil {
+		log.Error("failed to revoke sessions", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if a.lockout != nil {
+		for _, identifier := range a.loginIdentifiers(account) {
+			if err := a.lockout.Unlock(ctx, identifier); err != nil {
+				log.Warn("failed to lift lockout", sl.Err(err))
+			}
+		}
+	}
+
+	a.publish(ctx, events.PasswordResetByAdmin{
+		AccountID:  account.ID,
+		ActorID:    actorID,
+		OccurredAt: time.Now(),
+	})
+
+	log.Info("password reset by admin")
+
+	return nil
+}
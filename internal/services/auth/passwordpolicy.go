@@ -0,0 +1,43 @@
+package auth
+
+import (
+	"errors"
+	"strings"
+
+	"sso/internal/lib/passwordpolicy"
+)
+
+var ErrWeakPassword = errors.New("password does not meet the password policy")
+
+// PasswordPolicyError lists the rules a rejected password broke, so clients can
+// tell the user exactly what to fix. It matches ErrWeakPassword.
+type PasswordPolicyError struct {
+	Violations []passwordpolicy.Violation
+}
+
+func (e *PasswordPolicyError) Error() string {
+	rules := make([]string, 0, len(e.Violations))
+	for _, v := range e.Violations {
+		rules = append(rules, v.Rule)
+	}
+
+	return ErrWeakPassword.Error() + ": " + strings.Join(rules, ", ")
+}
+
+func (e *PasswordPolicyError) Unwrap() error {
+	return ErrWeakPassword
+}
+
+// checkPasswordPolicy returns a *PasswordPolicyError if password breaks the policy
+// of the app. Without a policy every password passes.
+func (a *Auth) checkPasswordPolicy(appID int32, password string) error {
+	if a.passwordPolicy == nil {
+		return nil
+	}
+
+	if violations := a.passwordPolicy.Check(appID, password); len(violations) > 0 {
+		return &PasswordPolicyError{Violations: violations}
+	}
+
+	return nil
+}
@@ -0,0 +1,66 @@
+package auth
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"sso/internal/domain/models"
+)
+
+func TestValidateReportsPermissions(t *testing.T) {
+	rolePermissions := map[models.AccountRole][]string{
+		models.USER:  {"profile:read"},
+		models.ADMIN: {"profile:read", "accounts:write"},
+	}
+
+	tests := []struct {
+		name string
+		opts []Option
+		// promote makes the account an admin after it logs in.
+		promote bool
+		want    []string
+	}{
+		{name: "not configured"},
+		{
+			name: "current role",
+			opts: []Option{WithRolePermissions(rolePermissions)},
+			want: []string{"profile:read"},
+		},
+		{
+			name: "role missing from the map",
+			opts: []Option{WithRolePermissions(map[models.AccountRole][]string{models.ADMIN: {"accounts:write"}})},
+			want: []string{},
+		},
+		{
+			name:    "after a role change",
+			opts:    []Option{WithRolePermissions(rolePermissions)},
+			promote: true,
+			want:    []string{"profile:read", "accounts:write"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			a, st := newTestAuth(t, tt.opts...)
+			appID := newTestApp(t, st)
+			accountID := registerTestAccount(t, a, appID, "user@example.com")
+			token := loginTestAccount(t, a, appID, "user@example.com").GetToken()
+
+			if tt.promote {
+				if err := a.GrantAppRole(ctx, newTestAdmin(t, st, appID), accountID, appID, models.ADMIN); err != nil {
+					t.Fatalf("grant role: %v", err)
+				}
+			}
+
+			validation, err := a.ValidateAccountSession(ctx, token)
+			if err != nil {
+				t.Fatalf("validate: %v", err)
+			}
+			if !slices.Equal(validation.Permissions, tt.want) || (validation.Permissions == nil) != (tt.want == nil) {
+				t.Errorf("permissions = %#v, want %#v", validation.Permissions, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"sso/internal/domain/events"
+	"sso/internal/lib/logger/sl"
+	"sso/internal/lib/phone"
+	"sso/internal/storage"
+)
+
+var ErrInvalidPhone = errors.New("invalid phone number")
+
+// checkPhone normalizes a phone number for an account of appID to E.164. It returns
+// ErrInvalidPhone if it isn't a number in international format and
+// storage.ErrPhoneExists if it is taken.
+func (a *Auth) checkPhone(ctx context.Context, appID int32, number string) (string, error) {
+	number, err := phone.Normalize(number)
+	if err != nil {
+		return "", ErrInvalidPhone
+	}
+
+	if err := a.checkPhoneAvailable(ctx, number, appID); err != nil {
+		return "", err
+	}
+
+	return number, nil
+}
+
+// logPhoneRejected logs why checkPhone rejected a number at the level the reason
+// deserves: a bad number is info, a failure to check is an error.
+func logPhoneRejected(log *slog.Logger, err error) {
+	switch {
+	case errors.Is(err, ErrInvalidPhone), errors.Is(err, storage.ErrPhoneExists):
+		log.Info("phone number rejected", sl.Err(err))
+	default:
+		log.Error("failed to check phone number", sl.Err(err))
+	}
+}
+
+// SetPhone sets the phone number of the account owning the presented access token,
+// which it can then log in with, by password or SMS code. Numbers must be in
+// international format, else ErrInvalidPhone; an empty one removes it. Returns
+// storage.ErrPhoneExists if another account has it.
+func (a *Auth) SetPhone(ctx context.Context, token string, phoneNumber string) error {
+	const op = "Auth.SetPhone"
+
+	log := a.log.With(
+		slog.String("op", op),
+	)
+
+	session, err := a.CurrentSession(ctx, token)
+	if err != nil {
+		log.Info("invalid session", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log = log.With(slog.Int64("account_id", session.AccountID))
+
+	if err := a.requireSensitiveAuth(ctx, token); err != nil {
+		log.Info("recent authentication required", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	account, err := a.accountProvider.AccountById(ctx, session.AccountID)
+	if err != nil {
+		log.Error("failed to get account", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if phoneNumber == "" && account.Phone == "" {
+		return nil
+	}
+
+	if phoneNumber != "" {
+		number, err := phone.Normalize(phoneNumber)
+		if err != nil {
+			log.Info("invalid phone number")
+			return fmt.Errorf("%s: %w", op, ErrInvalidPhone)
+		}
+		if number == account.Phone {
+			return nil
+		}
+
+		if _, err := a.checkPhone(ctx, account.AppId, number); err != nil {
+			logPhoneRejected(log, err)
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		phoneNumber = number
+	}
+
+	if err := a.accountSaver.SetPhone(ctx, account.ID, phoneNumber); err != nil {
+		if errors.Is(err, storage.ErrPhoneExists) {
+			logPhoneRejected(log, err)
+		} else {
+			log.Error("failed to set phone number", sl.Err(err))
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	a.publish(ctx, events.PhoneChanged{
+		AccountID:  account.ID,
+		Removed:    phoneNumber == "",
+		OccurredAt: time.Now(),
+	})
+
+	log.Info("phone number changed")
+
+	return nil
+}
@@ -0,0 +1,231 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+	// Timezones are checked against the embedded database, so hosts without
+	// zoneinfo files accept the same ones.
+	_ "time/tzdata"
+	"unicode/utf8"
+
+	"sso/internal/domain/events"
+	"sso/internal/domain/models"
+	"sso/internal/lib/logger/sl"
+	"sso/internal/storage"
+)
+
+var (
+	ErrProfilesDisabled = errors.New("profiles are not configured")
+	ErrInvalidProfile   = errors.New("invalid profile")
+)
+
+// ProfileStore keeps the profiles of accounts.
+type ProfileStore interface {
+	Profile(ctx context.Context, accountId int64) (models.Profile, error)
+	SaveProfile(ctx context.Context, profile models.Profile) error
+}
+
+const (
+	// maxProfileNameLength caps display, given and family names, in characters.
+	maxProfileNameLength = 100
+	maxAvatarURLLength   = 2048
+)
+
+// localePattern is a loose BCP 47 check: a 2 or 3 letter language followed by
+// subtags such as a script or region, e.g. "en", "en-US" or "zh-Hant-TW".
+var localePattern = regexp.MustCompile(`^[A-Za-z]{2,3}(-[A-Za-z0-9]{1,8})*$`)
+
+// profileClaims are the token claims profile fields can be carried in, named as
+// the standard OpenID Connect claims for them.
+var profileClaims = map[string]func(models.Profile) string{
+	"name":        func(p models.Profile) string { return p.DisplayName },
+	"given_name":  func(p models.Profile) string { return p.GivenName },
+	"family_name": func(p models.Profile) string { return p.FamilyName },
+	"picture":     func(p models.Profile) string { return p.AvatarURL },
+	"locale":      func(p models.Profile) string { return p.Locale },
+	"zoneinfo":    func(p models.Profile) string { return p.Timezone },
+}
+
+// IsProfileClaim reports whether WithProfiles can fill the named token claim from
+// profiles.
+func IsProfileClaim(name string) bool {
+	_, ok := profileClaims[name]
+	return ok
+}
+
+// GetProfile returns the profile of the account owning the presented access
+// token. Accounts that never saved one get an empty profile.
+func (a *Auth) GetProfile(ctx context.Context, token string) (models.Profile, error) {
+	const op = "Auth.GetProfile"
+
+	log := a.log.With(
+		slog.String("op", op),
+	)
+
+	if a.profiles == nil {
+		return models.Profile{}, fmt.Errorf("%s: %w", op, ErrProfilesDisabled)
+	}
+
+	session, err := a.CurrentSession(ctx, token)
+	if err != nil {
+		log.Info("invalid session", sl.Err(err))
+		return models.Profile{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	profile, err := a.profile(ctx, session.AccountID)
+	if err != nil {
+		log.Error("failed to get profile", slog.Int64("account_id", session.AccountID), sl.Err(err))
+		return models.Profile{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return profile, nil
+}
+
+// UpdateProfile replaces the profile of the account owning the presented access
+// token; fields left empty are cleared. Invalid fields are rejected with
+// ErrInvalidProfile. Tokens pick changed claims up when they are next renewed or
+// refreshed.
+func (a *Auth) UpdateProfile(ctx context.Context, token string, profile models.Profile) error {
+	const op = "Auth.UpdateProfile"
+
+	log := a.log.With(
+		slog.String("op", op),
+	)
+
+	if a.profiles == nil {
+		return fmt.Errorf("%s: %w", op, ErrProfilesDisabled)
+	}
+
+	session, err := a.CurrentSession(ctx, token)
+	if err != nil {
+		log.Info("invalid session", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log = log.With(slog.Int64("account_id", session.AccountID))
+
+	profile, err = a.normalizeProfile(profile)
+	if err != nil {
+		log.Info("profile rejected", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	profile.AccountID = session.AccountID
+
+	if err := a.profiles.SaveProfile(ctx, profile); err != nil {
+		log.Error("failed to save profile", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	a.publish(ctx, events.ProfileUpdated{
+		AccountID:  session.AccountID,
+		OccurredAt: time.Now(),
+	})
+
+	log.Info("profile updated")
+
+	return nil
+}
+
+// profile returns the account's profile, or an empty one if it never saved one.
+func (a *Auth) profile(ctx context.Context, accountID int64) (models.Profile, error) {
+	profile, err := a.profiles.Profile(ctx, accountID)
+	if err != nil {
+		if errors.Is(err, storage.ErrProfileNotFound) {
+			return models.Profile{AccountID: accountID}, nil
+		}
+		return models.Profile{}, err
+	}
+
+	return profile, nil
+}
+
+// normalizeProfile trims the profile's text fields and checks them: names must fit
+// maxProfileNameLength, the avatar must be an absolute http(s) URL, the locale a
+// language tag, the timezone an IANA name and metadata a JSON object of at most
+// the configured size, which is stored compacted.
+func (a *Auth) normalizeProfile(profile models.Profile) (models.Profile, error) {
+	for _, name := range []*string{&profile.DisplayName, &profile.GivenName, &profile.FamilyName} {
+		*name = strings.TrimSpace(*name)
+		if !utf8.ValidString(*name) || utf8.RuneCountInString(*name) > maxProfileNameLength {
+			return models.Profile{}, fmt.Errorf("%w: name longer than %d characters", ErrInvalidProfile, maxProfileNameLength)
+		}
+	}
+
+	profile.AvatarURL = strings.TrimSpace(profile.AvatarURL)
+	if profile.AvatarURL != "" {
+		u, err := url.Parse(profile.AvatarURL)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" || len(profile.AvatarURL) > maxAvatarURLLength {
+			return models.Profile{}, fmt.Errorf("%w: avatar url", ErrInvalidProfile)
+		}
+	}
+
+	profile.Locale = strings.TrimSpace(profile.Locale)
+	if profile.Locale != "" && !localePattern.MatchString(profile.Locale) {
+		return models.Profile{}, fmt.Errorf("%w: locale", ErrInvalidProfile)
+	}
+
+	profile.Timezone = strings.TrimSpace(profile.Timezone)
+	if profile.Timezone != "" {
+		// LoadLocation also takes "Local", the server's own zone.
+		if _, err := time.LoadLocation(profile.Timezone); err != nil || profile.Timezone == "Local" {
+			return models.Profile{}, fmt.Errorf("%w: timezone", ErrInvalidProfile)
+		}
+	}
+
+	metadata, err := a.normalizeProfileMetadata(profile.Metadata)
+	if err != nil {
+		return models.Profile{}, err
+	}
+	profile.Metadata = metadata
+
+	return profile, nil
+}
+
+// normalizeProfileMetadata compacts metadata, which must be a JSON object. Empty
+// metadata and null clear it.
+func (a *Auth) normalizeProfileMetadata(metadata json.RawMessage) (json.RawMessage, error) {
+	metadata = bytes.TrimSpace(metadata)
+	if len(metadata) == 0 || string(metadata) == "null" {
+		return nil, nil
+	}
+
+	if metadata[0] != '{' || !json.Valid(metadata) {
+		return nil, fmt.Errorf("%w: metadata must be a JSON object", ErrInvalidProfile)
+	}
+
+	var compacted bytes.Buffer
+	if err := json.Compact(&compacted, metadata); err != nil {
+		return nil, fmt.Errorf("%w: metadata must be a JSON object", ErrInvalidProfile)
+	}
+	if a.profileMetadataMax > 0 && compacted.Len() > a.profileMetadataMax {
+		return nil, fmt.Errorf("%w: metadata larger than %d bytes", ErrInvalidProfile, a.profileMetadataMax)
+	}
+
+	return compacted.Bytes(), nil
+}
+
+// tokenProfileClaims returns the configured profile claims of the account for its
+// tokens, leaving out empty fields.
+func (a *Auth) tokenProfileClaims(ctx context.Context, accountID int64) (map[string]string, error) {
+	profile, err := a.profile(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	claims := make(map[string]string, len(a.profileClaims))
+	for _, name := range a.profileClaims {
+		if value := profileClaims[name](profile); value != "" {
+			claims[name] = value
+		}
+	}
+
+	return claims, nil
+}
@@ -0,0 +1,70 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sso/internal/domain/models"
+	"sso/internal/lib/logger/sl"
+	"sso/internal/storage"
+)
+
+// ProvisionAccount creates an account linked to an upstream system's ID, or returns
+// the existing one if that ID is already linked, so upstream sync jobs can call it
+// repeatedly. created reports whether a new account was made.
+func (a *Auth) ProvisionAccount(ctx context.Context, externalID string, email string, password string, appID int32) (accountID int64, created bool, err error) {
+	const op = "Auth.ProvisionAccount"
+
+	email = a.identifierNormalizer.Normalize(email)
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.String("external_id", externalID),
+	)
+
+	if externalID == "" {
+		return 0, false, fmt.Errorf("%s: external id is required", op)
+	}
+
+	account, err := a.accountProvider.AccountByExternalID(ctx, externalID)
+	if err == nil {
+		return account.ID, false, nil
+	}
+	if !errors.Is(err, storage.ErrAccountNotFound) {
+		log.Error("failed to look up account", sl.Err(err))
+		return 0, false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.checkIdentifierAvailable(ctx, email); err != nil {
+		log.Info("identifier not available", sl.Err(err))
+		return 0, false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	passHash, err := a.hashPassword(ctx, password)
+	if err != nil {
+		log.Error("failed to generate password hash", sl.Err(err))
+		return 0, false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	id, err := a.accountSaver.SaveAccount(ctx, email, passHash, models.USER, models.ACTIVE, appID, externalID, "", "")
+	if err != nil {
+		if errors.Is(err, storage.ErrExternalIDExists) {
+			// Lost a race with a concurrent provisioning of the same ID.
+			account, err := a.accountProvider.AccountByExternalID(ctx, externalID)
+			if err == nil {
+				return account.ID, false, nil
+			}
+		}
+		log.Error("failed to save account", sl.Err(err))
+		return 0, false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.provisionAccount(ctx, log, id, email, appID); err != nil {
+		return 0, false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("account provisioned", slog.Int64("account_id", id))
+
+	return id, true, nil
+}
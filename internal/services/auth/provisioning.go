@@ -0,0 +1,54 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sso/internal/domain/events"
+	"sso/internal/lib/logger/sl"
+	"time"
+)
+
+var ErrProvisioningFailed = errors.New("account provisioning failed")
+
+// provisionAccount announces a newly created account to downstream systems with
+// AccountRegistered.
+//
+// Without a provisioner the event goes to the regular event publisher. In
+// fire-and-forget mode it is sent in the background and failures are only logged.
+// In blocking mode a failure deletes the account again and returns
+// ErrProvisioningFailed, so registration only succeeds once provisioning did.
+func (a *Auth) provisionAccount(ctx context.Context, log *slog.Logger, accountID int64, email string, appID int32) error {
+	event := events.AccountRegistered{
+		AccountID:  accountID,
+		Email:      email,
+		AppID:      appID,
+		OccurredAt: time.Now(),
+	}
+
+	if a.provisioner == nil {
+		a.publish(ctx, event)
+		return nil
+	}
+
+	if !a.blockingProvisioning {
+		go func(ctx context.Context) {
+			if err := a.provisioner.Publish(ctx, event); err != nil {
+				log.Error("failed to provision account", sl.Err(err))
+			}
+		}(context.WithoutCancel(ctx))
+		return nil
+	}
+
+	if err := a.provisioner.Publish(ctx, event); err != nil {
+		log.Error("failed to provision account, rolling back", sl.Err(err))
+
+		if err := a.accountSaver.DeleteAccount(context.WithoutCancel(ctx), accountID); err != nil {
+			log.Error("failed to roll back account", sl.Err(err))
+		}
+
+		return errors.Join(ErrProvisioningFailed, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,75 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"sso/internal/domain/events"
+	"sso/internal/storage"
+
+	ssov1 "github.com/dariasmyr/protos/gen/go/sso"
+)
+
+// failingProvisioner fails every delivery, handing the events to delivered.
+type failingProvisioner struct {
+	delivered chan any
+}
+
+func (p *failingProvisioner) Publish(_ context.Context, event any) error {
+	p.delivered <- event
+	return errors.New("provisioning webhook returned 503")
+}
+
+func TestProvisioningFailure(t *testing.T) {
+	tests := []struct {
+		name        string
+		blocking    bool
+		wantErr     error
+		wantAccount bool
+	}{
+		{name: "async keeps the account", blocking: false, wantAccount: true},
+		{name: "blocking rolls back", blocking: true, wantErr: ErrProvisioningFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			provisioner := &failingProvisioner{delivered: make(chan any, 1)}
+			a, st := newTestAuth(t, WithProvisioner(provisioner, tt.blocking))
+			appID := newTestApp(t, st)
+
+			_, err := a.Register(ctx, &ssov1.RegisterRequest{Email: "user@example.com", Password: testPassword, AppId: appID})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("register error = %v, want %v", err, tt.wantErr)
+			}
+
+			select {
+			case event := <-provisioner.delivered:
+				if e, ok := event.(events.AccountRegistered); !ok || e.Email != "user@example.com" {
+					t.Errorf("provisioned %+v, want AccountRegistered for the new account", event)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("account never provisioned")
+			}
+
+			_, err = st.AccountByEmail(ctx, "user@example.com")
+			if tt.wantAccount {
+				if err != nil {
+					t.Errorf("account after failed provisioning: %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, storage.ErrAccountNotFound) {
+				t.Fatalf("account after rollback error = %v, want %v", err, storage.ErrAccountNotFound)
+			}
+
+			// The email is free again once the registration is rolled back.
+			a.provisioner = nil
+			if _, err := a.Register(ctx, &ssov1.RegisterRequest{Email: "user@example.com", Password: testPassword, AppId: appID}); err != nil {
+				t.Errorf("register again after rollback: %v", err)
+			}
+		})
+	}
+}
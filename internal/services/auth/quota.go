@@ -0,0 +1,129 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sso/internal/domain/events"
+	"sso/internal/domain/models"
+	"sso/internal/lib/logger/sl"
+	"time"
+)
+
+var ErrSessionLimitReached = errors.New("session limit reached")
+
+// SessionQuotaProvider returns an account's own session limit, e.g. derived from its
+// subscription plan. found is false when the account has none and the configured
+// default applies.
+type SessionQuotaProvider interface {
+	SessionQuota(ctx context.Context, accountId int64) (maxSessions int, found bool, err error)
+}
+
+type SessionQuotaSaver interface {
+	SetSessionQuota(ctx context.Context, accountId int64, maxSessions int) error
+}
+
+// sessionLimit is the effective session limit for the account; zero means unlimited.
+func (a *Auth) sessionLimit(ctx context.Context, accountID int64) (int, error) {
+	if a.sessionQuotas == nil {
+		return a.maxSessions, nil
+	}
+
+	limit, found, err := a.sessionQuotas.SessionQuota(ctx, accountID)
+	if err != nil {
+		return 0, err
+	}
+	if !found {
+		return a.maxSessions, nil
+	}
+
+	return limit, nil
+}
+
+// enforceSessionQuota makes room for one more session of the account. Once the
+// account is at its limit, the oldest sessions are revoked, or with
+// rejectOverQuota the login fails with ErrSessionLimitReached.
+func (a *Auth) enforceSessionQuota(ctx context.Context, log *slog.Logger, accountID int64) error {
+	limit, err := a.sessionLimit(ctx, accountID)
+	if err != nil {
+		return err
+	}
+	if limit <= 0 {
+		return nil
+	}
+
+	sessions, err := a.sessionProvider.Sessions(ctx, accountID)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	active := sessions[:0]
+	for _, session := range sessions {
+		if session.ExpiresAt.After(now) {
+			active = append(active, session)
+		}
+	}
+
+	excess := len(active) - limit + 1
+	if excess <= 0 {
+		return nil
+	}
+
+	if a.rejectOverQuota {
+		log.Info("session limit reached", slog.Int("limit", limit))
+		return ErrSessionLimitReached
+	}
+
+	sort.Slice(active, func(i, j int) bool { return active[i].CreatedAt.Before(active[j].CreatedAt) })
+
+	for _, session := range active[:excess] {
+		if err := a.sessionSaver.RevokeSessionWithReason(ctx, session.SID, models.RevokedSessionLimit); err != nil {
+			return err
+		}
+
+		a.publish(ctx, events.SessionRevoked{
+			SessionID:  session.ID,
+			AccountID:  accountID,
+			Reason:     models.RevokedSessionLimit,
+			OccurredAt: now,
+		})
+	}
+
+	log.Info("evicted sessions over limit", slog.Int("limit", limit), slog.Int("evicted", excess))
+
+	return nil
+}
+
+// SetAccountSessionQuota sets how many concurrent sessions the account may hold,
+// overriding the configured default, e.g. when its plan changes. Zero means
+// unlimited and a negative value restores the default. Admin only.
+func (a *Auth) SetAccountSessionQuota(ctx context.Context, actorID int64, accountID int64, maxSessions int) error {
+	const op = "Auth.SetAccountSessionQuota"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int64("actor_id", actorID),
+		slog.Int64("account_id", accountID),
+	)
+
+	if err := a.requireAdmin(ctx, actorID); err != nil {
+		log.Warn("admin check failed", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if a.sessionQuotaSaver == nil {
+		return fmt.Errorf("%s: session quotas are not configured", op)
+	}
+
+	if err := a.sessionQuotaSaver.SetSessionQuota(ctx, accountID, maxSessions); err != nil {
+		log.Error("failed to set session quota", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("session quota set", slog.Int("max_sessions", maxSessions))
+
+	return nil
+}
@@ -0,0 +1,61 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	ssov1 "github.com/dariasmyr/protos/gen/go/sso"
+)
+
+func TestSessionQuota(t *testing.T) {
+	tests := []struct {
+		name         string
+		maxSessions  int
+		reject       bool
+		quota        int
+		setQuota     bool
+		wantSessions int
+		wantErr      error
+	}{
+		{name: "unlimited", wantSessions: 3},
+		{name: "default evicts oldest", maxSessions: 2, wantSessions: 2},
+		{name: "default rejects", maxSessions: 2, reject: true, wantSessions: 2, wantErr: ErrSessionLimitReached},
+		{name: "account quota overrides default", maxSessions: 2, quota: 1, setQuota: true, wantSessions: 1},
+		{name: "account quota unlimited", maxSessions: 1, quota: 0, setQuota: true, wantSessions: 3},
+		{name: "negative quota restores default", maxSessions: 1, quota: -1, setQuota: true, wantSessions: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			a, st := newTestAuth(t, WithMaxSessions(tt.maxSessions, tt.reject))
+			WithSessionQuotaProvider(st, st)(a)
+			appID := newTestApp(t, st)
+			accountID := registerTestAccount(t, a, appID, "user@example.com")
+
+			if tt.setQuota {
+				adminID := newTestAdmin(t, st, appID)
+				if err := a.SetAccountSessionQuota(ctx, adminID, accountID, tt.quota); err != nil {
+					t.Fatalf("set quota: %v", err)
+				}
+			}
+
+			var err error
+			for range 3 {
+				_, err = a.Login(ctx, &ssov1.LoginRequest{Email: "user@example.com", Password: testPassword, AppId: appID, UserAgent: testUserAgent, IpAddress: testIP})
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("last login error = %v, want %v", err, tt.wantErr)
+			}
+
+			sessions, err := st.Sessions(ctx, accountID)
+			if err != nil {
+				t.Fatalf("sessions: %v", err)
+			}
+			if len(sessions) != tt.wantSessions {
+				t.Errorf("active sessions = %d, want %d", len(sessions), tt.wantSessions)
+			}
+		})
+	}
+}
@@ -0,0 +1,229 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sso/internal/domain/events"
+	"sso/internal/domain/models"
+	"sso/internal/lib/jwt"
+	"sso/internal/lib/logger/sl"
+	"time"
+)
+
+var ErrReauthRequired = errors.New("recent authentication required")
+
+// defaultReauthWindow is how long Reauthenticate elevates a session for by default.
+const defaultReauthWindow = 10 * time.Minute
+
+// ForceReauthentication signs the account out everywhere, e.g. after it reports a
+// compromise. Every session is revoked with RevokedReauthRequired, so neither its
+// access token validates nor its refresh token rotates any more, and the token
+// version is bumped so tokens held outside a session are rejected as well.
+func (a *Auth) ForceReauthentication(ctx context.Context, accountID int64) error {
+	const op = "Auth.ForceReauthentication"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int64("account_id", accountID),
+	)
+
+	if _, err := a.accountProvider.AccountById(ctx, accountID); err != nil {
+		log.Error("failed to get account", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.accountSaver.IncrementTokenVersion(ctx, accountID); err != nil {
+		log.Error("failed to bump token version", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.revokeOtherSessions(ctx, accountID, "", models.RevokedReauthRequired); err != nil {
+		log.Error("failed to revoke sessions", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("account forced to re-authenticate")
+
+	return nil
+}
+
+// RequireFreshAuth checks that the presented access token is valid and that its
+// auth_time is no older than maxAge, returning ErrReauthRequired otherwise. Tokens
+// issued before auth_time was introduced carry none and always need reauthentication.
+func (a *Auth) RequireFreshAuth(ctx context.Context, token string, maxAge time.Duration) error {
+	const op = "Auth.RequireFreshAuth"
+
+	log := a.log.With(
+		slog.String("op", op),
+	)
+
+	session, err := a.CurrentSession(ctx, token)
+	if err != nil {
+		log.Info("invalid session", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	_, app, err := a.sessionAccount(ctx, session)
+	if err != nil {
+		log.Error("failed to load session account", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// CurrentSession has checked the expiry, with the clock skew leeway.
+	claims, err := jwt.ParseIgnoringExpiry(token, app)
+	if err != nil {
+		log.Info("invalid token", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if claims.AuthTime.IsZero() || time.Since(claims.AuthTime) > maxAge {
+		log.Info("authentication too old",
+			slog.Int64("account_id", claims.UID),
+			slog.Time("auth_time", claims.AuthTime),
+			slog.Duration("max_age", maxAge),
+		)
+		return fmt.Errorf("%s: %w", op, ErrReauthRequired)
+	}
+
+	return nil
+}
+
+// Reauthenticate confirms that the holder of the presented access token is still
+// the account holder and elevates the session for the reauth window, during which
+// RequireRecentAuth lets it through sensitive operations. It takes the account's
+// password, with a second factor if the account has TOTP, or for accounts with
+// TOTP the second factor alone. Failures count towards the login lockout. Returns
+// when the elevation runs out. Elevation stays with the session row, so a refresh,
+// which starts a new one, ends it.
+func (a *Auth) Reauthenticate(ctx context.Context, token string, password string, secondFactor string) (time.Time, error) {
+	const op = "Auth.Reauthenticate"
+
+	log := a.log.With(
+		slog.String("op", op),
+	)
+
+	session, err := a.CurrentSession(ctx, token)
+	if err != nil {
+		log.Info("invalid session", sl.Err(err))
+		return time.Time{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log = log.With(slog.Int64("account_id", session.AccountID))
+
+	account, _, err := a.sessionAccount(ctx, session)
+	if err != nil {
+		log.Error("failed to load session account", sl.Err(err))
+		return time.Time{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	identifier := a.identifierNormalizer.Normalize(account.Email)
+
+	if err := a.checkLockout(ctx, identifier, ""); err != nil {
+		if !errors.Is(err, ErrAccountLocked) {
+			log.Error("failed to check lockout", sl.Err(err))
+		}
+		return time.Time{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if password == "" && secondFactor == "" {
+		return time.Time{}, fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
+	}
+
+	if password != "" {
+		if err := a.comparePassword(ctx, account.PassHash, password); err != nil {
+			if isContextErr(err) {
+				log.Error("failed to verify password", sl.Err(err))
+				return time.Time{}, fmt.Errorf("%s: %w", op, err)
+			}
+
+			err := a.failedLogin(ctx, identifier, "", failureBadPassword)
+			logCredentialFailure(log, err)
+			return time.Time{}, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if err := loginStatusError(account.Status); err != nil {
+		log.Info("account status forbids reauthentication", slog.Int("status", int(account.Status)))
+		return time.Time{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	verified, err := a.checkSecondFactor(ctx, log, account.ID, secondFactor)
+	if err != nil {
+		switch {
+		case errors.Is(err, errBadSecondFactor):
+			err := a.failedLogin(ctx, identifier, "", failureBadSecondFactor)
+			logCredentialFailure(log, err)
+			return time.Time{}, fmt.Errorf("%s: %w", op, err)
+		case errors.Is(err, ErrSecondFactorRequired):
+			log.Info("second factor required")
+		default:
+			log.Error("failed to check second factor", sl.Err(err))
+		}
+		return time.Time{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Without a password only a verified second factor proves anything.
+	if password == "" && !verified {
+		err := a.failedLogin(ctx, identifier, "", failureBadSecondFactor)
+		logCredentialFailure(log, err)
+		return time.Time{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if a.lockout != nil {
+		if err := a.lockout.Success(ctx, identifier); err != nil {
+			log.Warn("failed to record lockout success", sl.Err(err))
+		}
+	}
+
+	now := time.Now()
+	until := now.Add(a.reauthWindow)
+	if err := a.sessionSaver.ElevateSession(ctx, session.SID, until); err != nil {
+		log.Error("failed to elevate session", sl.Err(err))
+		return time.Time{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	a.publish(ctx, events.SessionElevated{
+		SessionID:  session.ID,
+		AccountID:  account.ID,
+		Until:      until,
+		OccurredAt: now,
+	})
+
+	log.Info("session elevated", slog.Time("until", until))
+
+	return until, nil
+}
+
+// RequireRecentAuth is RequireFreshAuth that also lets through a session elevated
+// by Reauthenticate until its elevation runs out. Sensitive operations enforce it
+// so a user can reauthenticate in place rather than log in again.
+func (a *Auth) RequireRecentAuth(ctx context.Context, token string, maxAge time.Duration) error {
+	const op = "Auth.RequireRecentAuth"
+
+	session, err := a.CurrentSession(ctx, token)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if session.ElevatedUntil.After(time.Now()) {
+		return nil
+	}
+
+	if err := a.RequireFreshAuth(ctx, token, maxAge); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// requireSensitiveAuth enforces RequireRecentAuth with the configured max age on
+// account management through an access token. It does nothing unless one is set.
+func (a *Auth) requireSensitiveAuth(ctx context.Context, token string) error {
+	if a.recentAuthMaxAge <= 0 {
+		return nil
+	}
+
+	return a.RequireRecentAuth(ctx, token, a.recentAuthMaxAge)
+}
@@ -0,0 +1,152 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	ssov1 "github.com/dariasmyr/protos/gen/go/sso"
+)
+
+func TestForceReauthenticationRejectsAllTokens(t *testing.T) {
+	const appSecret = "exchange-client-secret"
+
+	tests := []struct {
+		name string
+		opts []Option
+		// token returns a token of the kind under test, issued before the account is
+		// forced to re-authenticate.
+		token func(t *testing.T, a *Auth, appID int32, login *ssov1.LoginResponse) string
+		// rejected tells whether the token stopped working.
+		rejected func(t *testing.T, a *Auth, accountID int64, token string) bool
+	}{
+		{
+			name:     "access token",
+			token:    func(_ *testing.T, _ *Auth, _ int32, login *ssov1.LoginResponse) string { return login.GetToken() },
+			rejected: accessTokenRejected,
+		},
+		{
+			name: "refresh token",
+			token: func(_ *testing.T, _ *Auth, _ int32, login *ssov1.LoginResponse) string {
+				return login.GetRefreshToken()
+			},
+			rejected: refreshTokenRejected,
+		},
+		{
+			name: "jwt refresh token",
+			opts: []Option{WithJWTRefreshTokens()},
+			token: func(_ *testing.T, _ *Auth, _ int32, login *ssov1.LoginResponse) string {
+				return login.GetRefreshToken()
+			},
+			rejected: refreshTokenRejected,
+		},
+		{
+			name: "exchanged token",
+			token: func(t *testing.T, a *Auth, appID int32, login *ssov1.LoginResponse) string {
+				exchanged, err := a.TokenExchange(context.Background(), appID, appSecret, login.GetToken(), appID, nil)
+				if err != nil {
+					t.Fatalf("exchange: %v", err)
+				}
+				return exchanged.Token
+			},
+			rejected: accessTokenRejected,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			a, storage := newTestAuth(t, tt.opts...)
+			id, err := storage.SaveApp(ctx, "app", appSecret, "")
+			if err != nil {
+				t.Fatalf("save app: %v", err)
+			}
+			appID := int32(id)
+			accountID := registerTestAccount(t, a, appID, "user@example.com")
+			token := tt.token(t, a, appID, loginTestAccount(t, a, appID, "user@example.com"))
+
+			if tt.rejected(t, a, accountID, token) {
+				t.Fatal("token rejected before the account was forced to re-authenticate")
+			}
+
+			if err := a.ForceReauthentication(ctx, accountID); err != nil {
+				t.Fatalf("force reauthentication: %v", err)
+			}
+
+			if !tt.rejected(t, a, accountID, token) {
+				t.Error("token still accepted after the account was forced to re-authenticate")
+			}
+		})
+	}
+}
+
+// accessTokenRejected tells whether the access token neither validates nor
+// introspects as active.
+func accessTokenRejected(t *testing.T, a *Auth, _ int64, token string) bool {
+	t.Helper()
+
+	validation, err := a.ValidateAccountSession(context.Background(), token)
+	valid := err == nil && validation.Valid
+
+	introspection, err := a.Introspect(context.Background(), token, "")
+	if err != nil {
+		t.Fatalf("introspect: %v", err)
+	}
+
+	if valid != introspection.Active {
+		t.Fatalf("validation says valid = %v, introspection active = %v", valid, introspection.Active)
+	}
+
+	return !valid
+}
+
+// refreshTokenRejected tells whether the refresh token no longer introspects as
+// active, checking a refresh with it fails as revoked then. It doesn't refresh a
+// live token, which would rotate it out.
+func refreshTokenRejected(t *testing.T, a *Auth, accountID int64, token string) bool {
+	t.Helper()
+
+	introspection, err := a.Introspect(context.Background(), token, "")
+	if err != nil {
+		t.Fatalf("introspect: %v", err)
+	}
+	if introspection.Active {
+		return false
+	}
+
+	_, _, _, err = a.RefreshAccountSession(context.Background(), accountID, token, testUserAgent, testIP)
+	if !errors.Is(err, ErrSessionRevoked) {
+		t.Fatalf("refresh error = %v, want ErrSessionRevoked", err)
+	}
+
+	return true
+}
+
+func TestRequireRecentAuthWindow(t *testing.T) {
+	ctx := context.Background()
+	a, st := newTestAuth(t)
+	appID := newTestApp(t, st)
+	registerTestAccount(t, a, appID, "user@example.com")
+	token := loginTestAccount(t, a, appID, "user@example.com").GetToken()
+
+	// auth_time has second precision, so let the login age past a second.
+	time.Sleep(1100 * time.Millisecond)
+
+	tests := []struct {
+		name    string
+		maxAge  time.Duration
+		wantErr error
+	}{
+		{name: "just inside", maxAge: 5 * time.Second},
+		{name: "just outside", maxAge: time.Second, wantErr: ErrReauthRequired},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := a.RequireRecentAuth(ctx, token, tt.maxAge); !errors.Is(err, tt.wantErr) {
+				t.Errorf("require recent auth error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
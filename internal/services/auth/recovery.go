@@ -0,0 +1,152 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sso/internal/lib/logger/sl"
+	"sso/internal/lib/totp"
+	"sso/internal/storage"
+	"strings"
+	"time"
+)
+
+var (
+	ErrSecondFactorRequired = errors.New("second factor required")
+
+	// errBadSecondFactor is a wrong TOTP or recovery code at login. It is reported to
+	// the client as ErrInvalidCredentials.
+	errBadSecondFactor = errors.New("invalid second factor")
+)
+
+// RecoveryCodeStore keeps hashes of the account's MFA recovery codes.
+// ConsumeRecoveryCode must be atomic: of concurrent calls with the same code at
+// most one may succeed.
+type RecoveryCodeStore interface {
+	ReplaceRecoveryCodes(ctx context.Context, accountId int64, codeHashes []string) error
+	ConsumeRecoveryCode(ctx context.Context, accountId int64, codeHash string, now time.Time) error
+}
+
+const (
+	recoveryCodeCount  = 10
+	recoveryCodeDigits = 10
+)
+
+// RegenerateRecoveryCodes replaces all of the account's recovery codes, used or
+// not, with a fresh set and returns it. Only the hashes are kept, so this is the
+// one chance to show the codes. The account must have TOTP enrolled and the
+// caller must have signed in within the last few minutes.
+func (a *Auth) RegenerateRecoveryCodes(ctx context.Context, token string) ([]string, error) {
+	const op = "Auth.RegenerateRecoveryCodes"
+
+	log := a.log.With(
+		slog.String("op", op),
+	)
+
+	if a.totpStore == nil {
+		return nil, fmt.Errorf("%s: %w", op, ErrTOTPDisabled)
+	}
+
+	accountID, err := a.stepUpAccount(ctx, token, totpEnrollmentMaxAge)
+	if err != nil {
+		log.Info("step-up check failed", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log = log.With(slog.Int64("account_id", accountID))
+
+	_, confirmed, err := a.totpStore.TOTPSecret(ctx, accountID)
+	if err != nil {
+		log.Info("totp not enrolled", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if !confirmed {
+		log.Info("totp enrollment not confirmed")
+		return nil, fmt.Errorf("%s: %w", op, storage.ErrTOTPNotEnrolled)
+	}
+
+	codes, hashes, err := a.newRecoveryCodes()
+	if err != nil {
+		log.Error("failed to generate recovery codes", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.totpStore.ReplaceRecoveryCodes(ctx, accountID, hashes); err != nil {
+		log.Error("failed to save recovery codes", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("recovery codes regenerated")
+
+	return codes, nil
+}
+
+// newRecoveryCodes returns a set of recovery codes formatted for display, e.g.
+// 01234-56789, along with the hashes to store.
+func (a *Auth) newRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+
+	for i := 0; i < recoveryCodeCount; i++ {
+		code, err := a.secrets.NumericCode(recoveryCodeDigits)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		codes = append(codes, code[:recoveryCodeDigits/2]+"-"+code[recoveryCodeDigits/2:])
+		hashes = append(hashes, hashCode(code))
+	}
+
+	return codes, hashes, nil
+}
+
+// checkSecondFactor enforces TOTP for accounts that have it enrolled. code may be a
+// current TOTP code or one of the account's unused recovery codes, which is used
+// up. Accounts without confirmed TOTP pass with any code. An empty code returns
+// ErrSecondFactorRequired, a wrong one errBadSecondFactor. verified tells whether
+// a second factor was actually checked.
+func (a *Auth) checkSecondFactor(ctx context.Context, log *slog.Logger, accountID int64, code string) (verified bool, err error) {
+	if a.totpStore == nil {
+		return false, nil
+	}
+
+	secret, confirmed, err := a.totpStore.TOTPSecret(ctx, accountID)
+	if err != nil {
+		if errors.Is(err, storage.ErrTOTPNotEnrolled) {
+			return false, nil
+		}
+		return false, err
+	}
+	if !confirmed {
+		return false, nil
+	}
+
+	if code == "" {
+		return false, ErrSecondFactorRequired
+	}
+
+	if totp.Validate(secret, code, time.Now()) {
+		if err := a.totpStore.TouchTOTPSecret(ctx, accountID, time.Now()); err != nil {
+			log.Warn("failed to record totp use", sl.Err(err))
+		}
+		return true, nil
+	}
+
+	// Recovery codes are shown with a dash and may be typed with spaces.
+	normalized := strings.NewReplacer("-", "", " ", "").Replace(code)
+	if len(normalized) != recoveryCodeDigits {
+		return false, errBadSecondFactor
+	}
+
+	err = a.totpStore.ConsumeRecoveryCode(ctx, accountID, hashCode(normalized), time.Now())
+	switch {
+	case err == nil:
+		log.Info("recovery code used")
+		return true, nil
+	case errors.Is(err, storage.ErrCodeNotFound):
+		return false, errBadSecondFactor
+	default:
+		return false, err
+	}
+}
@@ -0,0 +1,88 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sso/internal/domain/events"
+	"sso/internal/domain/models"
+	"time"
+)
+
+var (
+	ErrStepUpRequired = errors.New("re-authentication required")
+	ErrRefreshDenied  = errors.New("refresh from a new ip denied")
+)
+
+// NewIPRefreshPolicy is what RefreshAccountSession does when the refresh comes from
+// an IP outside the subnet the session was last used from.
+type NewIPRefreshPolicy string
+
+const (
+	// NewIPAllow lets the refresh through silently. It is the default.
+	NewIPAllow NewIPRefreshPolicy = "allow"
+	// NewIPAlert lets the refresh through and publishes RefreshFromNewIP.
+	NewIPAlert NewIPRefreshPolicy = "alert"
+	// NewIPStepUp refuses with ErrStepUpRequired so the client logs in again.
+	NewIPStepUp NewIPRefreshPolicy = "step_up"
+	// NewIPDeny refuses with ErrRefreshDenied and publishes RefreshFromNewIP.
+	NewIPDeny NewIPRefreshPolicy = "deny"
+)
+
+// Prefix lengths that count as the same network for IPv4 and IPv6 addresses.
+const (
+	ipv4SubnetBits = 24
+	ipv6SubnetBits = 64
+)
+
+// checkRefreshIP applies the new-IP refresh policy. Refreshes with an unknown IP on
+// either side are let through, as there's nothing to compare.
+func (a *Auth) checkRefreshIP(ctx context.Context, session models.Session, ipAddress string) error {
+	switch a.newIPRefreshPolicy {
+	case NewIPAlert, NewIPStepUp, NewIPDeny:
+	default:
+		return nil
+	}
+
+	if session.IPAddress == "" || ipAddress == "" || sameSubnet(session.IPAddress, ipAddress) {
+		return nil
+	}
+
+	event := events.RefreshFromNewIP{
+		SessionID:  session.ID,
+		AccountID:  session.AccountID,
+		PreviousIP: session.IPAddress,
+		IPAddress:  ipAddress,
+		OccurredAt: time.Now(),
+	}
+
+	switch a.newIPRefreshPolicy {
+	case NewIPStepUp:
+		return ErrStepUpRequired
+	case NewIPDeny:
+		event.Denied = true
+		a.publish(ctx, event)
+		return ErrRefreshDenied
+	default:
+		a.publish(ctx, event)
+		return nil
+	}
+}
+
+func sameSubnet(a, b string) bool {
+	ipA, ipB := net.ParseIP(a), net.ParseIP(b)
+	if ipA == nil || ipB == nil {
+		return a == b
+	}
+
+	if v4A, v4B := ipA.To4(), ipB.To4(); v4A != nil || v4B != nil {
+		if v4A == nil || v4B == nil {
+			return false
+		}
+		mask := net.CIDRMask(ipv4SubnetBits, 32)
+		return v4A.Mask(mask).Equal(v4B.Mask(mask))
+	}
+
+	mask := net.CIDRMask(ipv6SubnetBits, 128)
+	return ipA.Mask(mask).Equal(ipB.Mask(mask))
+}
@@ -0,0 +1,67 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"sso/internal/domain/events"
+)
+
+func TestRefreshFromNewIP(t *testing.T) {
+	const (
+		newIP      = "198.51.100.9"
+		sameSubnet = "203.0.113.99"
+	)
+
+	tests := []struct {
+		name       string
+		policy     NewIPRefreshPolicy
+		ip         string
+		wantErr    error
+		wantEvent  bool
+		wantDenied bool
+	}{
+		{name: "allow", policy: NewIPAllow, ip: newIP},
+		{name: "alert", policy: NewIPAlert, ip: newIP, wantEvent: true},
+		{name: "step up", policy: NewIPStepUp, ip: newIP, wantErr: ErrStepUpRequired},
+		{name: "deny", policy: NewIPDeny, ip: newIP, wantErr: ErrRefreshDenied, wantEvent: true, wantDenied: true},
+		{name: "deny from same subnet", policy: NewIPDeny, ip: sameSubnet},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			publisher := &capturingPublisher{}
+			a, st := newTestAuth(t, WithNewIPRefreshPolicy(tt.policy), WithEventPublisher(publisher))
+			appID := newTestApp(t, st)
+			accountID := registerTestAccount(t, a, appID, "user@example.com")
+			login := loginTestAccount(t, a, appID, "user@example.com")
+			publisher.events = nil
+
+			_, _, _, err := a.RefreshAccountSession(context.Background(), accountID, login.GetRefreshToken(), testUserAgent, tt.ip)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("refresh error = %v, want %v", err, tt.wantErr)
+			}
+
+			var alerts []events.RefreshFromNewIP
+			for _, e := range publisher.events {
+				if e, ok := e.(events.RefreshFromNewIP); ok {
+					alerts = append(alerts, e)
+				}
+			}
+
+			if !tt.wantEvent {
+				if len(alerts) != 0 {
+					t.Errorf("%d new-IP alerts, want none", len(alerts))
+				}
+				return
+			}
+			if len(alerts) != 1 {
+				t.Fatalf("%d new-IP alerts, want 1", len(alerts))
+			}
+			if alerts[0].PreviousIP != testIP || alerts[0].IPAddress != tt.ip || alerts[0].Denied != tt.wantDenied {
+				t.Errorf("alert = %+v, want %s -> %s denied %v", alerts[0], testIP, tt.ip, tt.wantDenied)
+			}
+		})
+	}
+}
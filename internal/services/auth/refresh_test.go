@@ -0,0 +1,179 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"sso/internal/domain/models"
+	"sso/internal/storage"
+)
+
+func TestRefreshAccountSession(t *testing.T) {
+	tests := []struct {
+		name string
+		// refresh presents the login's refresh token, possibly after other refreshes,
+		// and returns the error of the refresh under test.
+		refresh func(t *testing.T, a *Auth, accountID, otherID int64, refreshToken string) error
+		wantErr error
+	}{
+		{
+			name: "rotates",
+			refresh: func(t *testing.T, a *Auth, accountID, _ int64, refreshToken string) error {
+				_, _, _, err := a.RefreshAccountSession(context.Background(), accountID, refreshToken, testUserAgent, testIP)
+				return err
+			},
+		},
+		{
+			name: "token of another account",
+			refresh: func(t *testing.T, a *Auth, _, otherID int64, refreshToken string) error {
+				_, _, _, err := a.RefreshAccountSession(context.Background(), otherID, refreshToken, testUserAgent, testIP)
+				return err
+			},
+			wantErr: storage.ErrSessionNotFound,
+		},
+		{
+			name: "rotated-out token reused",
+			refresh: func(t *testing.T, a *Auth, accountID, _ int64, refreshToken string) error {
+				if _, _, _, err := a.RefreshAccountSession(context.Background(), accountID, refreshToken, testUserAgent, testIP); err != nil {
+					t.Fatalf("first refresh: %v", err)
+				}
+				_, _, _, err := a.RefreshAccountSession(context.Background(), accountID, refreshToken, testUserAgent, testIP)
+				return err
+			},
+			wantErr: ErrRefreshTokenReused,
+		},
+		{
+			name: "expired",
+			refresh: func(t *testing.T, a *Auth, accountID, _ int64, refreshToken string) error {
+				_, _, _, err := a.RefreshAccountSession(context.Background(), accountID, refreshToken, testUserAgent, testIP)
+				return err
+			},
+			wantErr: ErrSessionExpired,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, storage := newTestAuth(t)
+			appID := newTestApp(t, storage)
+			accountID := registerTestAccount(t, a, appID, "user@example.com")
+			otherID := registerTestAccount(t, a, appID, "other@example.com")
+
+			if tt.wantErr == ErrSessionExpired {
+				// Sessions keep their refresh token a week past the refresh TTL.
+				a.refreshTokenTTL = -8 * 24 * time.Hour
+			}
+			login := loginTestAccount(t, a, appID, "user@example.com")
+
+			err := tt.refresh(t, a, accountID, otherID, login.GetRefreshToken())
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("refresh error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRefreshAccountSessionReuseRevokesFamily(t *testing.T) {
+	ctx := context.Background()
+	a, storage := newTestAuth(t)
+	appID := newTestApp(t, storage)
+	accountID := registerTestAccount(t, a, appID, "user@example.com")
+	stolen := loginTestAccount(t, a, appID, "user@example.com").GetRefreshToken()
+
+	_, current, _, err := a.RefreshAccountSession(ctx, accountID, stolen, testUserAgent, testIP)
+	if err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+
+	if _, _, _, err := a.RefreshAccountSession(ctx, accountID, stolen, testUserAgent, testIP); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("reuse error = %v, want ErrRefreshTokenReused", err)
+	}
+
+	session, err := a.sessionByRefreshToken(ctx, current)
+	if err != nil {
+		t.Fatalf("current session: %v", err)
+	}
+	if !session.Revoked || session.RevokedReason != models.RevokedRefreshReuse {
+		t.Errorf("current session revoked = %v (%q), want revoked for reuse", session.Revoked, session.RevokedReason)
+	}
+
+	sessions, err := storage.Sessions(ctx, accountID)
+	if err != nil {
+		t.Fatalf("sessions: %v", err)
+	}
+	if len(sessions) != 0 {
+		t.Errorf("%d sessions still active after reuse", len(sessions))
+	}
+}
+
+func TestRefreshAccountSessionFamilyMaxAge(t *testing.T) {
+	ctx := context.Background()
+	const maxAge = 300 * time.Millisecond
+
+	a, storage := newTestAuth(t, WithRefreshFamilyMaxAge(maxAge))
+	appID := newTestApp(t, storage)
+	accountID := registerTestAccount(t, a, appID, "user@example.com")
+	refreshToken := loginTestAccount(t, a, appID, "user@example.com").GetRefreshToken()
+
+	// Every rotation keeps the login's family start, so refreshing doesn't extend it.
+	deadline := time.Now().Add(maxAge)
+	for time.Now().Before(deadline.Add(-50 * time.Millisecond)) {
+		var err error
+		_, refreshToken, _, err = a.RefreshAccountSession(ctx, accountID, refreshToken, testUserAgent, testIP)
+		if err != nil {
+			t.Fatalf("refresh within max age: %v", err)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	time.Sleep(time.Until(deadline) + 10*time.Millisecond)
+
+	if _, _, _, err := a.RefreshAccountSession(ctx, accountID, refreshToken, testUserAgent, testIP); !errors.Is(err, ErrRefreshFamilyExpired) {
+		t.Fatalf("refresh past max age error = %v, want ErrRefreshFamilyExpired", err)
+	}
+}
+
+func TestRefreshPicksUpRoleChange(t *testing.T) {
+	tests := []struct {
+		name    string
+		instant bool
+		// wantOldRejected tells whether the access token issued before the role
+		// change stops validating.
+		wantOldRejected bool
+	}{
+		{name: "on next refresh"},
+		{name: "instant", instant: true, wantOldRejected: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			a, st := newTestAuth(t, WithInstantRoleChange(tt.instant))
+			appID := newTestApp(t, st)
+			adminID := newTestAdmin(t, st, appID)
+			accountID := registerTestAccount(t, a, appID, "user@example.com")
+			login := loginTestAccount(t, a, appID, "user@example.com")
+
+			if err := a.GrantAppRole(ctx, adminID, accountID, appID, models.ADMIN); err != nil {
+				t.Fatalf("grant role: %v", err)
+			}
+
+			if got := accessTokenRejected(t, a, accountID, login.GetToken()); got != tt.wantOldRejected {
+				t.Errorf("old access token rejected = %v, want %v", got, tt.wantOldRejected)
+			}
+
+			token, _, _, err := a.RefreshAccountSession(ctx, accountID, login.GetRefreshToken(), testUserAgent, testIP)
+			if err != nil {
+				t.Fatalf("refresh: %v", err)
+			}
+			if role := tokenClaims(t, token)["role"]; role != float64(models.ADMIN) {
+				t.Errorf("refreshed token role = %v, want %v", role, models.ADMIN)
+			}
+			if accessTokenRejected(t, a, accountID, token) {
+				t.Error("refreshed access token rejected")
+			}
+		})
+	}
+}
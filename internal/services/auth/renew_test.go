@@ -0,0 +1,83 @@
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestValidateRenewsNearExpiry(t *testing.T) {
+	// Test tokens live for an hour.
+	tests := []struct {
+		name        string
+		window      time.Duration
+		wantRenewed bool
+	}{
+		{name: "renewal off", window: 0},
+		{name: "fresh token", window: time.Minute},
+		{name: "near expiry", window: 2 * time.Hour, wantRenewed: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			a, st := newTestAuth(t, WithRenewalWindow(tt.window))
+			appID := newTestApp(t, st)
+			registerTestAccount(t, a, appID, "user@example.com")
+			token := loginTestAccount(t, a, appID, "user@example.com").GetToken()
+
+			validation, err := a.ValidateAccountSession(ctx, token)
+			if err != nil {
+				t.Fatalf("validate: %v", err)
+			}
+			if !validation.Valid {
+				t.Fatalf("presented token not valid")
+			}
+
+			if renewed := validation.RenewedToken != ""; renewed != tt.wantRenewed {
+				t.Fatalf("renewed = %v, want %v", renewed, tt.wantRenewed)
+			}
+			if !tt.wantRenewed {
+				return
+			}
+
+			if validation.RenewedToken == token {
+				t.Errorf("renewed token is the presented one")
+			}
+			if !validation.RenewedTokenExpiresAt.After(time.Now()) {
+				t.Errorf("renewed token expires at %v, want in the future", validation.RenewedTokenExpiresAt)
+			}
+
+			renewed, err := a.ValidateAccountSession(ctx, validation.RenewedToken)
+			if err != nil {
+				t.Fatalf("validate renewed: %v", err)
+			}
+			if !renewed.Valid {
+				t.Errorf("renewed token not valid")
+			}
+			if sid, renewedSID := currentSID(t, a, token), currentSID(t, a, validation.RenewedToken); renewedSID != sid {
+				t.Errorf("renewed token is for session %q, want %q", renewedSID, sid)
+			}
+
+			// The presented token stays valid until it expires.
+			again, err := a.ValidateAccountSession(ctx, token)
+			if err != nil {
+				t.Fatalf("validate presented again: %v", err)
+			}
+			if !again.Valid {
+				t.Errorf("presented token no longer valid after renewal")
+			}
+		})
+	}
+}
+
+func currentSID(t *testing.T, a *Auth, token string) string {
+	t.Helper()
+
+	session, err := a.CurrentSession(context.Background(), token)
+	if err != nil {
+		t.Fatalf("current session: %v", err)
+	}
+
+	return session.SID
+}
@@ -0,0 +1,226 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sso/internal/domain/events"
+	"sso/internal/domain/models"
+	"sso/internal/lib/logger/sl"
+	"sso/internal/storage"
+	"time"
+)
+
+var (
+	ErrPasswordResetDisabled  = errors.New("password reset is not configured")
+	ErrPasswordResetThrottled = errors.New("too many password reset requests")
+)
+
+// ResetChannel is how a password reset token reaches the account holder.
+type ResetChannel string
+
+const (
+	// ResetChannelEmail publishes the token to the password reset publisher. It is
+	// the default.
+	ResetChannelEmail ResetChannel = "email"
+	// ResetChannelSMS texts the token to the account's phone number.
+	ResetChannelSMS ResetChannel = "sms"
+)
+
+// RequestPasswordReset issues a single-use reset token for the account with email
+// in appID and delivers it over the preferred channel: texted to the account's
+// phone number for ResetChannelSMS, handed to the password reset publisher
+// otherwise. Accounts without a phone number, a missing SMS sender and unknown
+// channels fall back to the publisher. Only a hash of the token is stored. The
+// result is the same whether or not such an account exists, so the call can't be
+// used to probe for accounts; it only fails with ErrPasswordResetThrottled when
+// email has asked too often, regardless of whether it belongs to an account.
+func (a *Auth) RequestPasswordReset(ctx context.Context, email string, appID int32, channel ResetChannel) error {
+	const op = "Auth.RequestPasswordReset"
+
+	email = a.loginIdentifier(email)
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.String("email", email),
+		slog.String("channel", string(channel)),
+	)
+
+	if a.passwordResets == nil {
+		return fmt.Errorf("%s: %w", op, ErrPasswordResetDisabled)
+	}
+
+	if a.resetRequests != nil && !a.resetRequests.Allow(email).Allowed {
+		log.Warn("password reset requests throttled")
+		return fmt.Errorf("%s: %w", op, ErrPasswordResetThrottled)
+	}
+
+	account, err := a.accountByIdentifier(ctx, email, appID)
+	if err != nil {
+		if errors.Is(err, storage.ErrAccountNotFound) {
+			log.Info("password reset requested for unknown account")
+			return nil
+		}
+		log.Error("failed to get account", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log = log.With(slog.Int64("account_id", account.ID))
+
+	token, err := a.IssueOneTimeCode(ctx, account.ID, models.CodePurposePasswordReset, a.passwordResetTTL)
+	if err != nil {
+		log.Error("failed to issue reset token", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if a.resetChannel(account, channel) == ResetChannelSMS {
+		if err := a.smsSender.SendSMS(ctx, account.Phone, fmt.Sprintf("%s is your password reset code.", token)); err != nil {
+			log.Error("failed to text reset token", sl.Err(err))
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		log.Info("password reset requested", slog.String("delivered_by", string(ResetChannelSMS)))
+
+		return nil
+	}
+
+	now := time.Now()
+	err = a.passwordResets.Publish(ctx, events.PasswordResetRequested{
+		AccountID:  account.ID,
+		Email:      account.Email,
+		AppID:      appID,
+		Token:      token,
+		ExpiresAt:  now.Add(a.passwordResetTTL),
+		OccurredAt: now,
+	})
+	if err != nil {
+		log.Error("failed to deliver reset token", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("password reset requested", slog.String("delivered_by", string(ResetChannelEmail)))
+
+	return nil
+}
+
+// resetChannel returns the channel a reset token for account actually goes out
+// on: preferred if the account and the service can use it, ResetChannelEmail
+// otherwise.
+func (a *Auth) resetChannel(account models.Account, preferred ResetChannel) ResetChannel {
+	if preferred == ResetChannelSMS && account.Phone != "" && a.smsSender != nil {
+		return ResetChannelSMS
+	}
+
+	return ResetChannelEmail
+}
+
+// ResetPassword sets a new password for the account with email in appID if token
+// is its current, unexpired reset token. The token is checked before the new
+// password, so nothing is learned about the password without one, and consumed
+// only once the password is accepted, so a rejected password can be retried with
+// the same token. Every session of the account is revoked with
+// RevokedPasswordReset and outstanding access tokens stop validating. Unknown
+// accounts and wrong, used or expired tokens all return ErrInvalidCode.
+func (a *Auth) ResetPassword(ctx context.Context, email string, appID int32, token string, newPassword string) error {
+	const op = "Auth.ResetPassword"
+
+	email = a.loginIdentifier(email)
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.String("email", email),
+	)
+
+	account, err := a.accountByIdentifier(ctx, email, appID)
+	if err != nil {
+		if errors.Is(err, storage.ErrAccountNotFound) {
+			log.Info("password reset for unknown account")
+			return fmt.Errorf("%s: %w", op, ErrInvalidCode)
+		}
+		log.Error("failed to get account", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log = log.With(slog.Int64("account_id", account.ID))
+
+	if err := a.checkOneTimeCode(ctx, account.ID, models.CodePurposePasswordReset, token); err != nil {
+		if errors.Is(err, ErrInvalidCode) {
+			log.Info("invalid reset token")
+		} else {
+			log.Error("failed to check reset token", sl.Err(err))
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.checkPasswordPolicy(appID, newPassword); err != nil {
+		log.Info("new password rejected by policy", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.checkPasswordReuse(ctx, account.ID, account.PassHash, newPassword); err != nil {
+		if errors.Is(err, ErrPasswordReused) {
+			log.Info("new password used before")
+		} else {
+			log.Error("failed to check password history", sl.Err(err))
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.checkBreachedPassword(ctx, log, newPassword); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.ConsumeOneTimeCode(ctx, account.ID, models.CodePurposePasswordReset, token); err != nil {
+		if errors.Is(err, ErrCodeAlreadyUsed) {
+			err = ErrInvalidCode
+		}
+		log.Info("invalid reset token", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	passHash, err := a.hashPassword(ctx, newPassword)
+	if err != nil {
+		log.Error("failed to hash new password", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.retirePassword(ctx, account.ID, account.PassHash); err != nil {
+		log.Error("failed to record password history", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.accountSaver.UpdatePassword(ctx, account.ID, passHash); err != nil {
+		log.Error("failed to update password", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.clearPasswordChangeRequired(ctx, account); err != nil {
+		log.Error("failed to clear password change requirement", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.accountSaver.IncrementTokenVersion(ctx, account.ID); err != nil {
+		log.Error("failed to bump token version", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.revokeOtherSessions(ctx, account.ID, "", models.RevokedPasswordReset); err != nil {
+		log.Error("failed to revoke sessions", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Whoever holds the reset token proved control of the account, so a lockout
+	// piled up by someone guessing the old password no longer applies.
+	if a.lockout != nil {
+		if err := a.lockout.Unlock(ctx, email); err != nil {
+			log.Warn("failed to lift lockout", sl.Err(err))
+		}
+	}
+
+	a.publish(ctx, events.PasswordReset{AccountID: account.ID, OccurredAt: time.Now()})
+
+	log.Info("password reset")
+
+	return nil
+}
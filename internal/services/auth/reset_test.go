@@ -0,0 +1,190 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"sso/internal/domain/events"
+	"sso/internal/lib/passwordpolicy"
+)
+
+// capturingPublisher keeps every event published to it.
+type capturingPublisher struct {
+	events []any
+}
+
+func (p *capturingPublisher) Publish(_ context.Context, event any) error {
+	p.events = append(p.events, event)
+	return nil
+}
+
+// capturingSMSSender keeps every text message sent through it.
+type capturingSMSSender struct {
+	to     []string
+	bodies []string
+}
+
+func (s *capturingSMSSender) SendSMS(_ context.Context, to string, body string) error {
+	s.to = append(s.to, to)
+	s.bodies = append(s.bodies, body)
+	return nil
+}
+
+// resetToken requests a password reset for email and returns the delivered token.
+func resetToken(t *testing.T, a *Auth, resets *capturingPublisher, appID int32, email string) string {
+	t.Helper()
+
+	if err := a.RequestPasswordReset(context.Background(), email, appID, ResetChannelEmail); err != nil {
+		t.Fatalf("request reset: %v", err)
+	}
+
+	event, ok := resets.events[len(resets.events)-1].(events.PasswordResetRequested)
+	if !ok {
+		t.Fatalf("published %T, want PasswordResetRequested", resets.events[len(resets.events)-1])
+	}
+
+	return event.Token
+}
+
+func TestResetPassword(t *testing.T) {
+	const newPassword = "another-Horse-battery-7"
+
+	tests := []struct {
+		name        string
+		wrongToken  bool
+		password    string
+		wantErr     error
+		tokenIsLive bool
+	}{
+		{name: "resets", password: newPassword},
+		{name: "wrong token with reused password", wrongToken: true, password: testPassword, wantErr: ErrInvalidCode, tokenIsLive: true},
+		{name: "wrong token with weak password", wrongToken: true, password: "weak", wantErr: ErrInvalidCode, tokenIsLive: true},
+		{name: "reused password", password: testPassword, wantErr: ErrPasswordReused, tokenIsLive: true},
+		{name: "weak password", password: "weak", wantErr: ErrWeakPassword, tokenIsLive: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			resets := &capturingPublisher{}
+			policy := passwordpolicy.New(passwordpolicy.Rules{MinLength: 8}, nil, nil)
+
+			a, storage := newTestAuth(t,
+				WithPasswordReset(resets, time.Hour, nil),
+				WithPasswordPolicy(policy),
+			)
+			WithPasswordHistory(storage, 3)(a)
+
+			appID := newTestApp(t, storage)
+			registerTestAccount(t, a, appID, "user@example.com")
+			token := resetToken(t, a, resets, appID, "user@example.com")
+
+			presented := token
+			if tt.wrongToken {
+				presented = "not-the-token"
+			}
+
+			err := a.ResetPassword(ctx, "user@example.com", appID, presented, tt.password)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("reset error = %v, want %v", err, tt.wantErr)
+			}
+
+			// A rejected reset leaves the token for another try.
+			err = a.ResetPassword(ctx, "user@example.com", appID, token, newPassword)
+			if tt.tokenIsLive && err != nil {
+				t.Errorf("retry with the token: %v", err)
+			}
+			if !tt.tokenIsLive && !errors.Is(err, ErrInvalidCode) {
+				t.Errorf("second use of the token error = %v, want ErrInvalidCode", err)
+			}
+		})
+	}
+}
+
+func TestRequestPasswordResetChannel(t *testing.T) {
+	const number = "+14155550123"
+
+	tests := []struct {
+		name        string
+		channel     ResetChannel
+		phone       string
+		smsEnabled  bool
+		wantChannel ResetChannel
+	}{
+		{name: "email", channel: ResetChannelEmail, phone: number, smsEnabled: true, wantChannel: ResetChannelEmail},
+		{name: "sms", channel: ResetChannelSMS, phone: number, smsEnabled: true, wantChannel: ResetChannelSMS},
+		{name: "sms without phone", channel: ResetChannelSMS, smsEnabled: true, wantChannel: ResetChannelEmail},
+		{name: "sms not configured", channel: ResetChannelSMS, phone: number, wantChannel: ResetChannelEmail},
+		{name: "unknown channel", channel: "carrier-pigeon", phone: number, smsEnabled: true, wantChannel: ResetChannelEmail},
+		{name: "no preference", channel: "", phone: number, smsEnabled: true, wantChannel: ResetChannelEmail},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			resets := &capturingPublisher{}
+			sender := &capturingSMSSender{}
+
+			opts := []Option{WithPasswordReset(resets, time.Hour, nil)}
+			if tt.smsEnabled {
+				opts = append(opts, WithSMSLogin(sender, time.Minute, nil))
+			}
+			a, storage := newTestAuth(t, opts...)
+			appID := newTestApp(t, storage)
+			accountID := registerTestAccount(t, a, appID, "user@example.com")
+			if tt.phone != "" {
+				if err := storage.SetPhone(ctx, accountID, tt.phone); err != nil {
+					t.Fatalf("set phone: %v", err)
+				}
+			}
+
+			if err := a.RequestPasswordReset(ctx, "user@example.com", appID, tt.channel); err != nil {
+				t.Fatalf("request reset: %v", err)
+			}
+
+			var token string
+			switch tt.wantChannel {
+			case ResetChannelSMS:
+				if len(resets.events) != 0 {
+					t.Errorf("published %d reset events, want none", len(resets.events))
+				}
+				if len(sender.to) != 1 || sender.to[0] != tt.phone {
+					t.Fatalf("texted %v, want %s once", sender.to, tt.phone)
+				}
+				token, _, _ = strings.Cut(sender.bodies[0], " ")
+			default:
+				if len(sender.to) != 0 {
+					t.Errorf("texted %v, want nothing", sender.to)
+				}
+				if len(resets.events) != 1 {
+					t.Fatalf("published %d reset events, want 1", len(resets.events))
+				}
+				token = resets.events[0].(events.PasswordResetRequested).Token
+			}
+
+			if err := a.ResetPassword(ctx, "user@example.com", appID, token, "another-Horse-battery-7"); err != nil {
+				t.Errorf("reset with delivered token: %v", err)
+			}
+		})
+	}
+}
+
+func TestRequestPasswordResetUnknownAccount(t *testing.T) {
+	resets := &capturingPublisher{}
+	sender := &capturingSMSSender{}
+	a, storage := newTestAuth(t, WithPasswordReset(resets, time.Hour, nil), WithSMSLogin(sender, time.Minute, nil))
+	appID := newTestApp(t, storage)
+
+	for _, channel := range []ResetChannel{ResetChannelEmail, ResetChannelSMS} {
+		if err := a.RequestPasswordReset(context.Background(), "nobody@example.com", appID, channel); err != nil {
+			t.Errorf("%s: request reset error = %v, want nil", channel, err)
+		}
+	}
+
+	if len(resets.events) != 0 || len(sender.to) != 0 {
+		t.Errorf("delivered %d events and %d texts for an unknown account", len(resets.events), len(sender.to))
+	}
+}
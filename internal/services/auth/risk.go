@@ -0,0 +1,192 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"sso/internal/domain/events"
+	"sso/internal/domain/models"
+	"sso/internal/lib/geoip"
+	"sso/internal/lib/logger/sl"
+)
+
+var (
+	ErrLoginDenied = errors.New("login denied as risky")
+	// ErrLoginVerificationRequired asks for the login to be retried with a second
+	// factor, or with a passkey for accounts without TOTP.
+	ErrLoginVerificationRequired = errors.New("additional verification required")
+)
+
+// RiskAction is what happens to a login that looks anomalous.
+type RiskAction string
+
+const (
+	RiskActionLog    RiskAction = "log"
+	RiskActionStepUp RiskAction = "step_up"
+	RiskActionDeny   RiskAction = "deny"
+	// RiskActionCaptcha lets the login through only with a solved CAPTCHA.
+	RiskActionCaptcha RiskAction = "captcha"
+)
+
+// Risk reasons reported in LoginRiskDetected.
+const (
+	riskImpossibleTravel = "impossible_travel"
+	riskNewCountry       = "new_country"
+)
+
+// LoginRiskPolicy flags logins whose location doesn't fit the account's sessions
+// seen within Window: one that would have meant travelling faster than
+// MaxSpeedKmh from where a session was last seen, ignoring jumps under
+// MinDistanceKm, which geolocation can't resolve reliably; and, with NewCountry,
+// one from a country none of them was in.
+type LoginRiskPolicy struct {
+	Action        RiskAction
+	Window        time.Duration
+	MaxSpeedKmh   float64
+	MinDistanceKm float64
+	NewCountry    bool
+}
+
+// riskFinding is an anomaly found in a login, with the session it was measured
+// against.
+type riskFinding struct {
+	reason   string
+	location geoip.Location
+	previous models.Session
+	prevLoc  geoip.Location
+}
+
+// checkLoginRisk evaluates a login to accountID from ipAddress that has passed the
+// password check. An anomalous login is reported in LoginRiskDetected and, by the
+// policy's action, only logged, let through only if secondFactor says a second
+// factor was verified (ErrLoginVerificationRequired otherwise) or captcha that a
+// CAPTCHA was solved (ErrCaptchaRequired otherwise), or refused with
+// ErrLoginDenied. Lookup failures are logged and let the login through.
+func (a *Auth) checkLoginRisk(ctx context.Context, log *slog.Logger, accountID int64, ipAddress string, secondFactor bool, captcha bool) error {
+	if a.geoIP == nil || a.loginRisk.Action == "" {
+		return nil
+	}
+
+	finding, err := a.evaluateLoginRisk(ctx, accountID, ipAddress, time.Now())
+	if err != nil {
+		log.Warn("failed to evaluate login risk", sl.Err(err))
+		return nil
+	}
+	if finding == nil {
+		return nil
+	}
+
+	log = log.With(
+		slog.String("risk", finding.reason),
+		slog.String("location", finding.location.String()),
+		slog.String("previous_location", finding.prevLoc.String()),
+	)
+
+	a.publish(ctx, events.LoginRiskDetected{
+		AccountID:         accountID,
+		IPAddress:         ipAddress,
+		Location:          finding.location.String(),
+		PreviousIPAddress: finding.previous.IPAddress,
+		PreviousLocation:  finding.prevLoc.String(),
+		Reason:            finding.reason,
+		Action:            string(a.loginRisk.Action),
+		OccurredAt:        time.Now(),
+	})
+
+	switch a.loginRisk.Action {
+	case RiskActionDeny:
+		log.Warn("risky login denied")
+		return ErrLoginDenied
+	case RiskActionStepUp:
+		if secondFactor {
+			log.Info("risky login passed with second factor")
+			return nil
+		}
+		log.Warn("risky login needs step-up")
+		return ErrLoginVerificationRequired
+	case RiskActionCaptcha:
+		if captcha {
+			log.Info("risky login passed with captcha")
+			return nil
+		}
+		log.Warn("risky login needs captcha")
+		return ErrCaptchaRequired
+	default:
+		log.Warn("risky login")
+		return nil
+	}
+}
+
+// evaluateLoginRisk compares the location of ipAddress with the account's recent
+// sessions and returns the first anomaly, or nil.
+func (a *Auth) evaluateLoginRisk(ctx context.Context, accountID int64, ipAddress string, now time.Time) (*riskFinding, error) {
+	current, ok, err := a.geoIP.Resolve(ipAddress)
+	if err != nil || !ok {
+		return nil, err
+	}
+
+	sessions, err := a.sessionProvider.Sessions(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		sameCountry bool
+		other       *riskFinding
+	)
+	for _, session := range sessions {
+		if session.IPAddress == "" {
+			continue
+		}
+		if session.IPAddress == ipAddress {
+			sameCountry = true
+			continue
+		}
+		if a.loginRisk.Window > 0 && now.Sub(session.LastSeenAt) > a.loginRisk.Window {
+			continue
+		}
+
+		prev, ok, err := a.geoIP.Resolve(session.IPAddress)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+
+		if prev.Country == current.Country {
+			sameCountry = true
+		} else if other == nil {
+			other = &riskFinding{reason: riskNewCountry, location: current, previous: session, prevLoc: prev}
+		}
+
+		if a.impossibleTravel(prev, current, now.Sub(session.LastSeenAt)) {
+			return &riskFinding{reason: riskImpossibleTravel, location: current, previous: session, prevLoc: prev}, nil
+		}
+	}
+
+	if a.loginRisk.NewCountry && !sameCountry && other != nil {
+		return other, nil
+	}
+
+	return nil, nil
+}
+
+// impossibleTravel reports whether getting from one location to the other within
+// elapsed would have been faster than the policy allows.
+func (a *Auth) impossibleTravel(from, to geoip.Location, elapsed time.Duration) bool {
+	if a.loginRisk.MaxSpeedKmh <= 0 || !from.Coordinates || !to.Coordinates {
+		return false
+	}
+
+	distance := geoip.Distance(from, to)
+	if distance < a.loginRisk.MinDistanceKm {
+		return false
+	}
+
+	hours := max(elapsed.Hours(), time.Minute.Hours())
+
+	return distance/hours > a.loginRisk.MaxSpeedKmh
+}
@@ -0,0 +1,61 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sso/internal/domain/models"
+	"sso/internal/lib/logger/sl"
+)
+
+// intersectScopes returns the requested scopes that are also allowed, in request
+// order and without duplicates. The result is never nil.
+func intersectScopes(requested []string, allowed []string) []string {
+	scopes := make([]string, 0, len(requested))
+	for _, scope := range requested {
+		if slices.Contains(allowed, scope) && !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+
+	return scopes
+}
+
+// sessionScopes returns the scopes a session's tokens carry: its own narrowed set,
+// clamped to what the account is still allowed, or everything allowed if the session
+// isn't narrowed.
+func sessionScopes(session models.Session, allowed []string) []string {
+	if session.Scopes == nil {
+		return allowed
+	}
+
+	return intersectScopes(session.Scopes, allowed)
+}
+
+// SetAppScopes replaces the scopes the account may be granted in the app. Existing
+// sessions lose removed scopes on their next refresh. Admin only.
+func (a *Auth) SetAppScopes(ctx context.Context, actorID int64, accountID int64, appID int32, scopes []string) error {
+	const op = "Auth.SetAppScopes"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int64("actor_id", actorID),
+		slog.Int64("account_id", accountID),
+		slog.Int64("app_id", int64(appID)),
+	)
+
+	if err := a.requireAdmin(ctx, actorID); err != nil {
+		log.Warn("admin check failed", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.membershipSaver.SetMembershipScopes(ctx, accountID, appID, scopes); err != nil {
+		log.Error("failed to set scopes", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("app scopes updated", slog.Any("scopes", scopes))
+
+	return nil
+}
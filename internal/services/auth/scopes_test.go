@@ -0,0 +1,59 @@
+package auth
+
+import (
+	"slices"
+	"testing"
+
+	"sso/internal/domain/models"
+)
+
+func TestIntersectScopes(t *testing.T) {
+	tests := []struct {
+		name      string
+		requested []string
+		allowed   []string
+		want      []string
+	}{
+		{name: "nothing requested", requested: nil, allowed: []string{"read"}, want: []string{}},
+		{name: "nothing allowed", requested: []string{"read"}, allowed: nil, want: []string{}},
+		{name: "subset", requested: []string{"read"}, allowed: []string{"read", "write"}, want: []string{"read"}},
+		{name: "drops disallowed", requested: []string{"admin", "write"}, allowed: []string{"read", "write"}, want: []string{"write"}},
+		{name: "keeps request order", requested: []string{"write", "read"}, allowed: []string{"read", "write"}, want: []string{"write", "read"}},
+		{name: "drops duplicates", requested: []string{"read", "read"}, allowed: []string{"read"}, want: []string{"read"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := intersectScopes(tt.requested, tt.allowed)
+			if got == nil {
+				t.Fatal("intersectScopes returned nil")
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("intersectScopes = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSessionScopes(t *testing.T) {
+	allowed := []string{"read", "write"}
+
+	tests := []struct {
+		name    string
+		session models.Session
+		want    []string
+	}{
+		{name: "not narrowed", session: models.Session{}, want: []string{"read", "write"}},
+		{name: "narrowed", session: models.Session{Scopes: []string{"read"}}, want: []string{"read"}},
+		{name: "scope since removed", session: models.Session{Scopes: []string{"read", "admin"}}, want: []string{"read"}},
+		{name: "narrowed to nothing", session: models.Session{Scopes: []string{}}, want: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sessionScopes(tt.session, allowed); !slices.Equal(got, tt.want) {
+				t.Errorf("sessionScopes = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"sso/internal/domain/events"
+	"sso/internal/domain/models"
+	"sso/internal/storage/sqlite"
+)
+
+// fixedSecrets is a deterministic secret.Generator that remembers every secret it
+// hands out.
+type fixedSecrets struct {
+	mu     sync.Mutex
+	n      int
+	issued map[string]bool
+}
+
+func (g *fixedSecrets) Token() (string, error) {
+	return g.issue(func(n int) string { return fmt.Sprintf("fixed-token-%d", n) }), nil
+}
+
+func (g *fixedSecrets) NumericCode(digits int) (string, error) {
+	return g.issue(func(n int) string { return fmt.Sprintf("%0*d", digits, n) }), nil
+}
+
+func (g *fixedSecrets) issue(format func(n int) string) string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.n++
+	s := format(g.n)
+	if g.issued == nil {
+		g.issued = make(map[string]bool)
+	}
+	g.issued[s] = true
+
+	return s
+}
+
+func TestFlowsUseSecretGenerator(t *testing.T) {
+	tests := []struct {
+		name string
+		// secret runs the flow and returns the secret it handed out.
+		secret func(t *testing.T, a *Auth, st *sqlite.Storage, resets *capturingPublisher, appID int32, accountID int64) string
+	}{
+		{
+			name: "refresh token",
+			secret: func(t *testing.T, a *Auth, _ *sqlite.Storage, _ *capturingPublisher, appID int32, accountID int64) string {
+				return loginTestAccount(t, a, appID, "user@example.com").GetRefreshToken()
+			},
+		},
+		{
+			name: "password reset",
+			secret: func(t *testing.T, a *Auth, _ *sqlite.Storage, resets *capturingPublisher, appID int32, accountID int64) string {
+				if err := a.RequestPasswordReset(context.Background(), "user@example.com", appID, ResetChannelEmail); err != nil {
+					t.Fatalf("request reset: %v", err)
+				}
+				return resets.events[len(resets.events)-1].(events.PasswordResetRequested).Token
+			},
+		},
+		{
+			name: "magic link",
+			secret: func(t *testing.T, a *Auth, st *sqlite.Storage, _ *capturingPublisher, _ int32, accountID int64) string {
+				return issueTestCode(t, a, accountID, models.CodePurposeMagicLink)
+			},
+		},
+		{
+			name: "otp",
+			secret: func(t *testing.T, a *Auth, st *sqlite.Storage, _ *capturingPublisher, _ int32, accountID int64) string {
+				return issueTestCode(t, a, accountID, models.CodePurposeOTP)
+			},
+		},
+		{
+			name: "sso ticket",
+			secret: func(t *testing.T, a *Auth, st *sqlite.Storage, _ *capturingPublisher, appID int32, accountID int64) string {
+				ctx := context.Background()
+				targetID := newTestApp(t, st)
+				if err := st.SaveMembership(ctx, accountID, targetID, models.USER); err != nil {
+					t.Fatalf("save membership: %v", err)
+				}
+				token := loginTestAccount(t, a, appID, "user@example.com").GetToken()
+
+				ticket, err := a.CreateSSOTicket(ctx, token, targetID)
+				if err != nil {
+					t.Fatalf("create ticket: %v", err)
+				}
+				return ticket
+			},
+		},
+		{
+			name: "invite",
+			secret: func(t *testing.T, a *Auth, st *sqlite.Storage, _ *capturingPublisher, appID int32, accountID int64) string {
+				WithInvites(st, time.Hour)(a)
+				adminID := newTestAdmin(t, st, appID)
+
+				_, token, err := a.CreateInvite(context.Background(), adminID, "invitee@example.com", models.USER, appID, 0)
+				if err != nil {
+					t.Fatalf("create invite: %v", err)
+				}
+				return token
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			secrets := &fixedSecrets{}
+			resets := &capturingPublisher{}
+			a, st := newTestAuth(t, WithSecretGenerator(secrets), WithPasswordReset(resets, time.Hour, nil))
+			appID := newTestApp(t, st)
+			accountID := registerTestAccount(t, a, appID, "user@example.com")
+
+			got := tt.secret(t, a, st, resets, appID, accountID)
+			if !secrets.issued[got] {
+				t.Errorf("flow handed out %q, which the generator never issued", got)
+			}
+		})
+	}
+}
+
+// issueTestCode issues a one-time code for purpose to the account.
+func issueTestCode(t *testing.T, a *Auth, accountID int64, purpose models.CodePurpose) string {
+	t.Helper()
+
+	code, err := a.IssueOneTimeCode(context.Background(), accountID, purpose, time.Minute)
+	if err != nil {
+		t.Fatalf("issue code: %v", err)
+	}
+	return code
+}
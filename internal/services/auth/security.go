@@ -0,0 +1,62 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sso/internal/domain/models"
+	"sso/internal/lib/logger/sl"
+)
+
+type SecurityFactorProvider interface {
+	SecurityFactors(ctx context.Context, accountId int64) (models.SecurityFactors, error)
+}
+
+// ListSecurityFactors returns the enrolled MFA methods and the devices of the
+// account owning the presented access token, for a security settings view. Devices
+// are the ones it has sessions from or signed in from, named as in ListDevices.
+// Secret material is never included.
+func (a *Auth) ListSecurityFactors(ctx context.Context, token string) (models.SecurityFactors, error) {
+	const op = "Auth.ListSecurityFactors"
+
+	log := a.log.With(
+		slog.String("op", op),
+	)
+
+	current, err := a.CurrentSession(ctx, token)
+	if err != nil {
+		log.Info("invalid session", sl.Err(err))
+		return models.SecurityFactors{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log = log.With(slog.Int64("account_id", current.AccountID))
+
+	log.Info("listing security factors")
+
+	factors, err := a.securityFactorProvider.SecurityFactors(ctx, current.AccountID)
+	if err != nil {
+		log.Error("failed to get security factors", sl.Err(err))
+		return models.SecurityFactors{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return factors, nil
+}
+
+// checkMFAPolicy returns ErrMFAEnrollmentRequired when the app requires MFA and the
+// account has neither TOTP nor a passkey enrolled.
+func (a *Auth) checkMFAPolicy(ctx context.Context, accountID int64, app models.App) error {
+	if app.MFAPolicy != models.MFA_REQUIRED {
+		return nil
+	}
+
+	factors, err := a.securityFactorProvider.SecurityFactors(ctx, accountID)
+	if err != nil {
+		return err
+	}
+
+	if factors.TOTP == nil && len(factors.Passkeys) == 0 {
+		return ErrMFAEnrollmentRequired
+	}
+
+	return nil
+}
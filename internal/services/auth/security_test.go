@@ -0,0 +1,152 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"sso/internal/domain/models"
+	"sso/internal/lib/totp"
+
+	ssov1 "github.com/dariasmyr/protos/gen/go/sso"
+)
+
+func TestListSecurityFactors(t *testing.T) {
+	tests := []struct {
+		name string
+		// token returns the access token to list with, given the login's.
+		token   func(t *testing.T, a *Auth, login *ssov1.LoginResponse) string
+		wantErr error
+	}{
+		{
+			name:  "own session",
+			token: func(_ *testing.T, _ *Auth, login *ssov1.LoginResponse) string { return login.GetToken() },
+		},
+		{
+			name: "signed out session",
+			token: func(t *testing.T, a *Auth, login *ssov1.LoginResponse) string {
+				session, err := a.CurrentSession(context.Background(), login.GetToken())
+				if err != nil {
+					t.Fatalf("current session: %v", err)
+				}
+				if err := a.sessionSaver.RevokeSession(context.Background(), session.SID); err != nil {
+					t.Fatalf("revoke session: %v", err)
+				}
+				return login.GetToken()
+			},
+			wantErr: ErrSessionRevoked,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, storage := newTestAuth(t)
+			appID := newTestApp(t, storage)
+			registerTestAccount(t, a, appID, "user@example.com")
+			login := loginTestAccount(t, a, appID, "user@example.com")
+
+			factors, err := a.ListSecurityFactors(context.Background(), tt.token(t, a, login))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("list error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+
+			if len(factors.TrustedDevices) != 1 {
+				t.Fatalf("%d devices, want the login's", len(factors.TrustedDevices))
+			}
+			if got := factors.TrustedDevices[0]; got.UserAgent != testUserAgent || got.IPAddress != testIP {
+				t.Errorf("device = %+v, want the login's user agent and ip", got)
+			}
+		})
+	}
+}
+
+func TestCheckSecondFactorRecordsTOTPUse(t *testing.T) {
+	ctx := context.Background()
+	a, storage := newTestAuth(t)
+	WithTOTPStore(storage)(a)
+	appID := newTestApp(t, storage)
+	accountID := registerTestAccount(t, a, appID, "user@example.com")
+
+	secret, err := totp.NewSecret()
+	if err != nil {
+		t.Fatalf("new secret: %v", err)
+	}
+	if err := storage.SaveTOTPSecret(ctx, accountID, secret); err != nil {
+		t.Fatalf("save secret: %v", err)
+	}
+	if err := storage.ConfirmTOTPSecret(ctx, accountID, nil); err != nil {
+		t.Fatalf("confirm secret: %v", err)
+	}
+
+	verified, err := a.checkSecondFactor(ctx, a.log, accountID, totp.Code(secret, time.Now()))
+	if err != nil || !verified {
+		t.Fatalf("check second factor = %v, %v, want verified", verified, err)
+	}
+
+	factors, err := storage.SecurityFactors(ctx, accountID)
+	if err != nil {
+		t.Fatalf("security factors: %v", err)
+	}
+	if factors.TOTP == nil || factors.TOTP.LastUsedAt == nil {
+		t.Errorf("totp use not recorded: %+v", factors.TOTP)
+	}
+}
+
+func TestLoginMFAPolicy(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   models.MFAPolicy
+		enrolled bool
+		wantErr  error
+	}{
+		{name: "off", policy: models.MFA_OFF},
+		{name: "optional without factor", policy: models.MFA_OPTIONAL},
+		{name: "optional with factor", policy: models.MFA_OPTIONAL, enrolled: true},
+		{name: "required without factor", policy: models.MFA_REQUIRED, wantErr: ErrMFAEnrollmentRequired},
+		{name: "required with factor", policy: models.MFA_REQUIRED, enrolled: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			a, storage, db := newTestAuthWithDB(t)
+			WithTOTPStore(storage)(a)
+			appID := newTestApp(t, storage)
+			accountID := registerTestAccount(t, a, appID, "user@example.com")
+
+			if _, err := db.Exec("UPDATE apps SET mfa_policy = ? WHERE id = ?", tt.policy, appID); err != nil {
+				t.Fatalf("set mfa policy: %v", err)
+			}
+
+			var secondFactor string
+			if tt.enrolled {
+				secret, err := totp.NewSecret()
+				if err != nil {
+					t.Fatalf("new secret: %v", err)
+				}
+				if err := storage.SaveTOTPSecret(ctx, accountID, secret); err != nil {
+					t.Fatalf("save secret: %v", err)
+				}
+				if err := storage.ConfirmTOTPSecret(ctx, accountID, nil); err != nil {
+					t.Fatalf("confirm secret: %v", err)
+				}
+				secondFactor = totp.Code(secret, time.Now())
+			}
+
+			_, _, err := a.LoginWithOptions(ctx, &ssov1.LoginRequest{
+				Email:     "user@example.com",
+				Password:  testPassword,
+				AppId:     appID,
+				UserAgent: testUserAgent,
+				IpAddress: testIP,
+			}, LoginOptions{SecondFactor: secondFactor})
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("login error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,254 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sso/internal/domain/events"
+	"sso/internal/domain/models"
+	"sso/internal/lib/jwt"
+	"sso/internal/lib/logger/sl"
+	"sso/internal/storage"
+	"time"
+)
+
+var ErrSessionExpired = errors.New("session expired")
+
+// CurrentSession returns the session the presented access token belongs to, so a
+// client can see its own device, IP and expiry without admin rights. The session is
+// the one named by the verified token, so it can never be another account's.
+// Expired access tokens return jwt.ErrTokenExpired. The refresh token is cleared
+// from the result. Sessions of accounts that must change
+// their password return ErrPasswordChangeRequired, which keeps them from every
+// method that acts on the caller's session. Like validation, it counts as activity
+// for the idle timeout, and revokes sessions idle for too long with ErrSessionIdle.
+func (a *Auth) CurrentSession(ctx context.Context, token string) (models.Session, error) {
+	const op = "Auth.CurrentSession"
+
+	log := a.log.With(
+		slog.String("op", op),
+	)
+
+	session, app, claims, err := a.sessionForToken(ctx, token)
+	if err != nil {
+		log.Info("session not found", sl.Err(err))
+		return models.Session{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if a.tokenExpired(claims) {
+		return models.Session{}, fmt.Errorf("%s: %w", op, jwt.ErrTokenExpired)
+	}
+
+	revoked, _, err := a.tokenRevoked(ctx, claims)
+	if err != nil {
+		log.Error("failed to check revocation list", sl.Err(err))
+		return models.Session{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if revoked {
+		return models.Session{}, fmt.Errorf("%s: %w", op, ErrSessionRevoked)
+	}
+
+	if session.Revoked {
+		return models.Session{}, fmt.Errorf("%s: %w", op, ErrSessionRevoked)
+	}
+
+	if session.ExpiresAt.Before(time.Now()) {
+		return models.Session{}, fmt.Errorf("%s: %w", op, ErrSessionExpired)
+	}
+
+	account, err := a.accountProvider.AccountById(ctx, session.AccountID)
+	if err != nil {
+		log.Error("failed to get account", sl.Err(err))
+		return models.Session{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	idle, err := a.checkIdle(ctx, log, session, int32(app.ID), claims.Role)
+	if err != nil {
+		log.Error("failed to revoke idle session", sl.Err(err))
+		return models.Session{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if idle {
+		return models.Session{}, fmt.Errorf("%s: %w", op, ErrSessionIdle)
+	}
+
+	if account.RequiresPasswordChange {
+		return models.Session{}, fmt.Errorf("%s: %w", op, ErrPasswordChangeRequired)
+	}
+
+	session.RefreshToken = ""
+
+	return session, nil
+}
+
+// sessionForToken verifies the signature of an access token, not its expiry, and
+// returns the session named by its sid claim along with the token's app and
+// claims. Access tokens aren't stored; the session row only tells whether the
+// session is still live, which is left to the caller. Tokens that don't verify,
+// carry no sid or name a session of another account return
+// storage.ErrSessionNotFound, as for an unknown session.
+func (a *Auth) sessionForToken(ctx context.Context, token string) (models.Session, models.App, jwt.Claims, error) {
+	appID, err := jwt.AppID(token)
+	if err != nil {
+		return models.Session{}, models.App{}, jwt.Claims{}, fmt.Errorf("%w: %w", storage.ErrSessionNotFound, err)
+	}
+
+	app, err := a.appProvider.App(ctx, int32(appID))
+	if err != nil {
+		if errors.Is(err, storage.ErrAppNotFound) {
+			return models.Session{}, models.App{}, jwt.Claims{}, storage.ErrSessionNotFound
+		}
+		return models.Session{}, models.App{}, jwt.Claims{}, err
+	}
+
+	claims, err := jwt.ParseIgnoringExpiry(token, app)
+	if err != nil {
+		return models.Session{}, models.App{}, jwt.Claims{}, fmt.Errorf("%w: %w", storage.ErrSessionNotFound, err)
+	}
+
+	// Tokens minted before sessions had IDs name no session.
+	if claims.SessionID == "" {
+		return models.Session{}, models.App{}, jwt.Claims{}, storage.ErrSessionNotFound
+	}
+
+	session, err := a.sessionProvider.SessionBySID(ctx, claims.SessionID)
+	if err != nil {
+		return models.Session{}, models.App{}, jwt.Claims{}, err
+	}
+
+	if session.AccountID != claims.UID {
+		return models.Session{}, models.App{}, jwt.Claims{}, storage.ErrSessionNotFound
+	}
+
+	return session, app, claims, nil
+}
+
+// tokenExpired reports whether an access token is past its expiry by more than
+// the clock skew leeway.
+func (a *Auth) tokenExpired(claims jwt.Claims) bool {
+	return claims.ExpiresAt.Add(a.clockSkewLeeway).Before(time.Now())
+}
+
+// LogoutEverywhere revokes the sessions of the account that owns the presented access
+// token. The account is taken from the token's session, never from the caller, so a
+// user can only sign out their own devices. With keepCurrent the calling session
+// stays signed in. Returns ErrReauthRequired if recent authentication is required
+// for account management and the caller hasn't.
+func (a *Auth) LogoutEverywhere(ctx context.Context, token string, keepCurrent bool) error {
+	const op = "Auth.LogoutEverywhere"
+
+	log := a.log.With(
+		slog.String("op", op),
+	)
+
+	session, err := a.CurrentSession(ctx, token)
+	if err != nil {
+		log.Info("invalid session", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log = log.With(slog.Int64("account_id", session.AccountID))
+
+	if err := a.requireSensitiveAuth(ctx, token); err != nil {
+		log.Info("recent authentication required", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	keepSID := ""
+	if keepCurrent {
+		keepSID = session.SID
+	}
+
+	if err := a.revokeOtherSessions(ctx, session.AccountID, keepSID, models.RevokedSignedOutEverywhere); err != nil {
+		log.Error("failed to revoke sessions", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("account logged out everywhere", slog.Bool("kept_current", keepCurrent))
+
+	return nil
+}
+
+// LogoutSession signs one device of the account out: the active session with the
+// public session ID sessionID is revoked with RevokedSignedOut. Sessions of other
+// accounts, and sessions created before session IDs, return
+// storage.ErrSessionNotFound.
+func (a *Auth) LogoutSession(ctx context.Context, accountID int64, sessionID string) error {
+	const op = "Auth.LogoutSession"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int64("account_id", accountID),
+		slog.String("session_id", sessionID),
+	)
+
+	session, err := a.activeSessionBySID(ctx, accountID, sessionID)
+	if err != nil {
+		log.Info("session not found", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.sessionSaver.RevokeSessionWithReason(ctx, session.SID, models.RevokedSignedOut); err != nil {
+		log.Error("failed to revoke session", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	a.publish(ctx, events.SessionRevoked{
+		SessionID:  session.ID,
+		AccountID:  accountID,
+		Reason:     models.RevokedSignedOut,
+		OccurredAt: time.Now(),
+	})
+
+	log.Info("session logged out")
+
+	return nil
+}
+
+// LogoutOtherSessions revokes every session of the account except the one with the
+// public session ID currentSessionID. If that session isn't an active session of
+// the account, nothing is revoked and storage.ErrSessionNotFound is returned.
+func (a *Auth) LogoutOtherSessions(ctx context.Context, accountID int64, currentSessionID string) error {
+	const op = "Auth.LogoutOtherSessions"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int64("account_id", accountID),
+		slog.String("session_id", currentSessionID),
+	)
+
+	current, err := a.activeSessionBySID(ctx, accountID, currentSessionID)
+	if err != nil {
+		log.Info("current session not found", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.revokeOtherSessions(ctx, accountID, current.SID, models.RevokedSignedOutEverywhere); err != nil {
+		log.Error("failed to revoke sessions", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("other sessions logged out")
+
+	return nil
+}
+
+// activeSessionBySID finds the account's active session with the public session ID sid.
+func (a *Auth) activeSessionBySID(ctx context.Context, accountID int64, sid string) (models.Session, error) {
+	if sid == "" {
+		return models.Session{}, storage.ErrSessionNotFound
+	}
+
+	sessions, err := a.sessionProvider.Sessions(ctx, accountID)
+	if err != nil {
+		return models.Session{}, err
+	}
+
+	for _, session := range sessions {
+		if session.SID == sid {
+			return session, nil
+		}
+	}
+
+	return models.Session{}, storage.ErrSessionNotFound
+}
@@ -0,0 +1,267 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"sso/internal/domain/events"
+	"sso/internal/domain/models"
+	"sso/internal/storage"
+)
+
+// collidingIDs hands out a taken session ID for the first collisions calls, then
+// fresh ones.
+type collidingIDs struct {
+	taken      string
+	collisions int
+	calls      int
+}
+
+func (g *collidingIDs) NewID() string {
+	g.calls++
+	if g.calls <= g.collisions {
+		return g.taken
+	}
+
+	return fmt.Sprintf("fresh-%d", g.calls)
+}
+
+func TestSaveNewSessionRetriesCollisions(t *testing.T) {
+	tests := []struct {
+		name       string
+		collisions int
+		wantErr    error
+	}{
+		{name: "no collision", collisions: 0},
+		{name: "one collision", collisions: 1},
+		{name: "collides until the last attempt", collisions: maxSessionSaveAttempts - 1},
+		{name: "collides every attempt", collisions: maxSessionSaveAttempts, wantErr: storage.ErrSessionExists},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			a, st := newTestAuth(t)
+			appID := newTestApp(t, st)
+			accountID := registerTestAccount(t, a, appID, "user@example.com")
+			loginTestAccount(t, a, appID, "user@example.com")
+
+			sessions, err := st.Sessions(ctx, accountID)
+			if err != nil || len(sessions) != 1 {
+				t.Fatalf("sessions = %v, %v; want one", sessions, err)
+			}
+			taken := sessions[0].SID
+
+			ids := &collidingIDs{taken: taken, collisions: tt.collisions}
+			WithSessionIDGenerator(ids)(a)
+
+			account, err := st.AccountById(ctx, accountID)
+			if err != nil {
+				t.Fatalf("account: %v", err)
+			}
+			app, err := st.App(ctx, appID)
+			if err != nil {
+				t.Fatalf("app: %v", err)
+			}
+
+			log := slog.New(slog.NewTextHandler(io.Discard, nil))
+			session, err := a.saveNewSession(ctx, log, account, app, time.Now(), "family", testUserAgent, testIP, false)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("save error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+
+			if session.SID == taken {
+				t.Fatal("new session reused the taken session ID")
+			}
+			if _, err := st.SessionBySID(ctx, session.SID); err != nil {
+				t.Errorf("saved session: %v", err)
+			}
+			if want := tt.collisions + 1; ids.calls != want {
+				t.Errorf("attempts = %d, want %d", ids.calls, want)
+			}
+		})
+	}
+}
+
+func TestSingleSessionKicksWithReason(t *testing.T) {
+	tests := []struct {
+		name       string
+		single     bool
+		wantKicked bool
+	}{
+		{name: "multiple sessions", single: false},
+		{name: "single session", single: true, wantKicked: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			publisher := &capturingPublisher{}
+			a, st := newTestAuth(t, WithSingleSession(tt.single), WithEventPublisher(publisher))
+			appID := newTestApp(t, st)
+			registerTestAccount(t, a, appID, "user@example.com")
+
+			first := loginTestAccount(t, a, appID, "user@example.com").GetToken()
+			publisher.events = nil
+			second := loginTestAccount(t, a, appID, "user@example.com").GetToken()
+
+			old, err := a.ValidateAccountSession(ctx, first)
+			if err != nil {
+				t.Fatalf("validate first: %v", err)
+			}
+			current, err := a.ValidateAccountSession(ctx, second)
+			if err != nil {
+				t.Fatalf("validate second: %v", err)
+			}
+			if !current.Valid {
+				t.Errorf("new session not valid")
+			}
+
+			var revoked []events.SessionRevoked
+			for _, e := range publisher.events {
+				if e, ok := e.(events.SessionRevoked); ok {
+					revoked = append(revoked, e)
+				}
+			}
+
+			if !tt.wantKicked {
+				if !old.Valid {
+					t.Errorf("old session invalid with reason %q, want valid", old.RevokedReason)
+				}
+				if len(revoked) != 0 {
+					t.Errorf("%d sessions revoked, want none", len(revoked))
+				}
+				return
+			}
+
+			if old.Valid {
+				t.Fatalf("old session still valid")
+			}
+			if old.RevokedReason != models.RevokedLoggedOutElsewhere {
+				t.Errorf("old session reason = %q, want %q", old.RevokedReason, models.RevokedLoggedOutElsewhere)
+			}
+			if len(revoked) != 1 || revoked[0].Reason != models.RevokedLoggedOutElsewhere {
+				t.Errorf("revocation events = %+v, want one with reason %q", revoked, models.RevokedLoggedOutElsewhere)
+			}
+		})
+	}
+}
+
+func TestCurrentSession(t *testing.T) {
+	ctx := context.Background()
+	a, st := newTestAuth(t)
+	appID := newTestApp(t, st)
+	aliceID := registerTestAccount(t, a, appID, "alice@example.com")
+	bobID := registerTestAccount(t, a, appID, "bob@example.com")
+
+	aliceLaptop := loginTestAccount(t, a, appID, "alice@example.com").GetToken()
+	alicePhone := loginTestAccount(t, a, appID, "alice@example.com").GetToken()
+	bob := loginTestAccount(t, a, appID, "bob@example.com").GetToken()
+
+	sids := make(map[string]string)
+	for _, tt := range []struct {
+		name      string
+		token     string
+		accountID int64
+	}{
+		{name: "alice laptop", token: aliceLaptop, accountID: aliceID},
+		{name: "alice phone", token: alicePhone, accountID: aliceID},
+		{name: "bob", token: bob, accountID: bobID},
+	} {
+		session, err := a.CurrentSession(ctx, tt.token)
+		if err != nil {
+			t.Fatalf("%s: current session: %v", tt.name, err)
+		}
+		if session.AccountID != tt.accountID {
+			t.Errorf("%s: session of account %d, want %d", tt.name, session.AccountID, tt.accountID)
+		}
+		if session.UserAgent != testUserAgent || session.IPAddress != testIP {
+			t.Errorf("%s: device = %q from %q, want the login's", tt.name, session.UserAgent, session.IPAddress)
+		}
+		if session.RefreshToken != "" {
+			t.Errorf("%s: refresh token not cleared", tt.name)
+		}
+		if other, ok := sids[session.SID]; ok {
+			t.Errorf("%s: got the session of %s", tt.name, other)
+		}
+		sids[session.SID] = tt.name
+	}
+
+	if _, err := a.CurrentSession(ctx, "not-a-token"); err == nil {
+		t.Error("current session of a garbage token succeeded")
+	}
+
+	laptop, err := a.CurrentSession(ctx, aliceLaptop)
+	if err != nil {
+		t.Fatalf("current session: %v", err)
+	}
+	if err := a.LogoutSession(ctx, aliceID, laptop.SID); err != nil {
+		t.Fatalf("logout laptop: %v", err)
+	}
+	if _, err := a.CurrentSession(ctx, aliceLaptop); !errors.Is(err, ErrSessionRevoked) {
+		t.Errorf("current session after logout error = %v, want %v", err, ErrSessionRevoked)
+	}
+	if _, err := a.CurrentSession(ctx, alicePhone); err != nil {
+		t.Errorf("other session after logout: %v", err)
+	}
+}
+
+func TestLogoutEverywhere(t *testing.T) {
+	tests := []struct {
+		name        string
+		keepCurrent bool
+	}{
+		{name: "all sessions", keepCurrent: false},
+		{name: "keep current", keepCurrent: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			a, st := newTestAuth(t)
+			appID := newTestApp(t, st)
+			registerTestAccount(t, a, appID, "alice@example.com")
+			registerTestAccount(t, a, appID, "bob@example.com")
+
+			current := loginTestAccount(t, a, appID, "alice@example.com").GetToken()
+			others := []string{
+				loginTestAccount(t, a, appID, "alice@example.com").GetToken(),
+				loginTestAccount(t, a, appID, "alice@example.com").GetToken(),
+			}
+			bob := loginTestAccount(t, a, appID, "bob@example.com").GetToken()
+
+			if err := a.LogoutEverywhere(ctx, current, tt.keepCurrent); err != nil {
+				t.Fatalf("logout everywhere: %v", err)
+			}
+
+			valid := func(token string) bool {
+				t.Helper()
+				validation, err := a.ValidateAccountSession(ctx, token)
+				if err != nil {
+					t.Fatalf("validate: %v", err)
+				}
+				return validation.Valid
+			}
+
+			for i, token := range others {
+				if valid(token) {
+					t.Errorf("other session %d still valid", i)
+				}
+			}
+			if got := valid(current); got != tt.keepCurrent {
+				t.Errorf("current session valid = %v, want %v", got, tt.keepCurrent)
+			}
+			if !valid(bob) {
+				t.Error("another account's session was revoked")
+			}
+		})
+	}
+}
@@ -0,0 +1,210 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"sso/internal/domain/events"
+	"sso/internal/domain/models"
+	"sso/internal/lib/jwt"
+	"sso/internal/lib/logger/sl"
+	"sso/internal/storage"
+)
+
+// SetAppSigningAlgorithm sets the algorithm the app's tokens are signed with. For
+// the asymmetric algorithms a new key pair is generated, whose public key
+// AppPublicKey and AppJWKS hand out to services verifying the tokens without the
+// app secret; setting the same algorithm again rotates the key. The private key
+// is stored encrypted, so they need at-rest encryption configured. The previous
+// key keeps verifying for the signing key overlap; tokens signed with the app
+// secret stop validating as soon as the app switches away from it and are
+// replaced on the next refresh. Admin only.
+func (a *Auth) SetAppSigningAlgorithm(ctx context.Context, actorID int64, appID int32, alg models.SigningAlgorithm) error {
+	const op = "Auth.SetAppSigningAlgorithm"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int64("actor_id", actorID),
+		slog.Int64("app_id", int64(appID)),
+		slog.String("alg", string(alg)),
+	)
+
+	if err := a.requireAdmin(ctx, actorID); err != nil {
+		log.Warn("admin check failed", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.rotateSigningKey(ctx, appID, alg, actorID); err != nil {
+		if errors.Is(err, jwt.ErrUnsupportedAlgorithm) {
+			log.Info("unsupported algorithm")
+		} else {
+			log.Error("failed to set signing key", sl.Err(err))
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("app signing algorithm updated")
+
+	return nil
+}
+
+// RotateSigningKeys gives every app signing with a key pair whose current key is
+// older than maxAge a new one of the same algorithm. The previous keys keep
+// verifying for the signing key overlap. It returns how many apps got a new key;
+// an app that fails is logged and left to the next run.
+func (a *Auth) RotateSigningKeys(ctx context.Context, maxAge time.Duration) (int, error) {
+	const op = "Auth.RotateSigningKeys"
+
+	log := a.log.With(
+		slog.String("op", op),
+	)
+
+	appIDs, err := a.appSaver.AppsDueForKeyRotation(ctx, time.Now().Add(-maxAge))
+	if err != nil {
+		log.Error("failed to list apps due for rotation", sl.Err(err))
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	rotated := 0
+	for _, appID := range appIDs {
+		if ctx.Err() != nil {
+			break
+		}
+
+		app, err := a.appProvider.App(ctx, appID)
+		if err != nil {
+			log.Error("failed to get app", sl.Err(err), slog.Int64("app_id", int64(appID)))
+			continue
+		}
+
+		if err := a.rotateSigningKey(ctx, appID, app.SigningAlgorithm, 0); err != nil {
+			log.Error("failed to rotate signing key", sl.Err(err), slog.Int64("app_id", int64(appID)))
+			continue
+		}
+
+		rotated++
+	}
+
+	if rotated > 0 {
+		log.Info("signing keys rotated", slog.Int("apps", rotated))
+	}
+
+	return rotated, nil
+}
+
+// rotateSigningKey makes a new key for alg the app's current signing key, or just
+// retires the current one for SigningHS256, and publishes SigningKeyRotated.
+func (a *Auth) rotateSigningKey(ctx context.Context, appID int32, alg models.SigningAlgorithm, actorID int64) error {
+	var key *models.SigningKey
+	if alg != models.SigningHS256 {
+		generated, err := jwt.GenerateSigningKey(alg)
+		if err != nil {
+			return err
+		}
+		key = &generated
+	}
+
+	return a.setSigningKey(ctx, appID, alg, key, actorID)
+}
+
+// setSigningKey makes key the app's current signing key for alg, retiring the
+// previous one after the overlap, and publishes SigningKeyRotated.
+func (a *Auth) setSigningKey(ctx context.Context, appID int32, alg models.SigningAlgorithm, key *models.SigningKey, actorID int64) error {
+	now := time.Now()
+	retireAt := now.Add(a.signingKeyRetention())
+
+	if err := a.appSaver.RotateAppSigningKey(ctx, appID, alg, key, retireAt); err != nil {
+		return err
+	}
+
+	rotated := events.SigningKeyRotated{
+		AppID:      appID,
+		Algorithm:  string(alg),
+		ActorID:    actorID,
+		RetiresAt:  retireAt,
+		OccurredAt: now,
+	}
+	if key != nil {
+		rotated.KID = key.KID
+		rotated.KeyRef = key.KeyRef
+	}
+	a.publish(ctx, rotated)
+
+	return nil
+}
+
+// signingKeyRetention is how long a rotated out key keeps verifying: the
+// configured overlap, but at least as long as an access token lives.
+func (a *Auth) signingKeyRetention() time.Duration {
+	return max(a.signingKeyOverlap, a.tokenTTL)
+}
+
+// AppPublicKey returns the algorithm the app's tokens are signed with and the PEM
+// encoded public key of its current signing key. Apps signing with their secret
+// return jwt.ErrSymmetricAlgorithm, unknown apps ErrAppNotFound.
+func (a *Auth) AppPublicKey(ctx context.Context, appID int32) (models.SigningAlgorithm, string, error) {
+	const op = "Auth.AppPublicKey"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int64("app_id", int64(appID)),
+	)
+
+	app, err := a.signingApp(ctx, appID)
+	if err != nil {
+		if !errors.Is(err, ErrAppNotFound) {
+			log.Error("failed to get app", sl.Err(err))
+		}
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	publicKey, err := jwt.PublicKeyPEM(app)
+	if err != nil {
+		if !errors.Is(err, jwt.ErrSymmetricAlgorithm) {
+			log.Error("failed to read public key", sl.Err(err))
+		}
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return app.SigningAlgorithm, publicKey, nil
+}
+
+// AppJWKS returns the JWK Set of the keys verifying the app's tokens: its current
+// signing key and the previous ones that haven't retired yet. Apps signing with
+// their secret have an empty set, unknown apps return ErrAppNotFound.
+func (a *Auth) AppJWKS(ctx context.Context, appID int32) (jwt.JWKS, error) {
+	const op = "Auth.AppJWKS"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int64("app_id", int64(appID)),
+	)
+
+	app, err := a.signingApp(ctx, appID)
+	if err != nil {
+		if !errors.Is(err, ErrAppNotFound) {
+			log.Error("failed to get app", sl.Err(err))
+		}
+		return jwt.JWKS{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	set, err := jwt.PublicJWKS(app)
+	if err != nil {
+		log.Error("failed to build key set", sl.Err(err))
+		return jwt.JWKS{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return set, nil
+}
+
+func (a *Auth) signingApp(ctx context.Context, appID int32) (models.App, error) {
+	app, err := a.appProvider.App(ctx, appID)
+	if errors.Is(err, storage.ErrAppNotFound) {
+		return models.App{}, ErrAppNotFound
+	}
+
+	return app, err
+}
@@ -0,0 +1,205 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	ssov1 "github.com/dariasmyr/protos/gen/go/sso"
+
+	"sso/internal/domain/models"
+	"sso/internal/lib/logger/sl"
+	"sso/internal/lib/phone"
+	"sso/internal/storage"
+)
+
+var (
+	ErrSMSLoginDisabled = errors.New("sms login is not configured")
+	ErrSMSCodeThrottled = errors.New("too many sms code requests")
+)
+
+// SMSSender delivers text messages to phone numbers in E.164 form, see package sms
+// for providers.
+type SMSSender interface {
+	SendSMS(ctx context.Context, to string, body string) error
+}
+
+// RequestSMSCode texts a single-use login code to the account with phoneNumber in
+// appID, for LoginWithSMSCode. Only a hash of the code is stored and a new request
+// replaces the previous code. As with RequestPasswordReset, the result is the same
+// whether or not such an account exists; it fails with ErrSMSCodeThrottled when the
+// number has asked too often and ErrInvalidPhone for numbers not in international
+// format.
+func (a *Auth) RequestSMSCode(ctx context.Context, phoneNumber string, appID int32) error {
+	const op = "Auth.RequestSMSCode"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int64("app_id", int64(appID)),
+	)
+
+	if a.smsSender == nil {
+		return fmt.Errorf("%s: %w", op, ErrSMSLoginDisabled)
+	}
+
+	number, err := phone.Normalize(phoneNumber)
+	if err != nil {
+		log.Info("invalid phone number")
+		return fmt.Errorf("%s: %w", op, ErrInvalidPhone)
+	}
+
+	if a.smsRequests != nil && !a.smsRequests.Allow(number).Allowed {
+		log.Warn("sms code requests throttled")
+		return fmt.Errorf("%s: %w", op, ErrSMSCodeThrottled)
+	}
+
+	app, err := a.appForLogin(ctx, appID)
+	if err != nil {
+		log.Warn("invalid app", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	account, err := a.accountByPhone(ctx, number, appID)
+	if err != nil {
+		if errors.Is(err, storage.ErrAccountNotFound) {
+			log.Info("sms code requested for unknown number")
+			return nil
+		}
+		log.Error("failed to get account", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log = log.With(slog.Int64("account_id", account.ID))
+
+	if err := loginStatusError(account.Status); err != nil {
+		log.Info("account status forbids login", slog.Int("status", int(account.Status)))
+		return nil
+	}
+
+	code, err := a.IssueOneTimeCode(ctx, account.ID, models.CodePurposeSMSLogin, a.smsCodeTTL)
+	if err != nil {
+		log.Error("failed to issue sms code", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.smsSender.SendSMS(ctx, number, fmt.Sprintf("%s is your %s login code.", code, app.Name)); err != nil {
+		log.Error("failed to send sms code", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("sms code sent")
+
+	return nil
+}
+
+// LoginWithSMSCode logs the account with phoneNumber in to appID with the code
+// RequestSMSCode texted to it. Wrong, used and expired codes count as failed
+// logins and return ErrInvalidCredentials, as do unknown numbers. The code replaces
+// the password only: accounts with TOTP enrolled still need secondFactor, as with
+// LoginOptions.SecondFactor, and apps requiring MFA still require it to be enrolled.
+func (a *Auth) LoginWithSMSCode(ctx context.Context, phoneNumber string, appID int32, code string, secondFactor string, userAgent string, ipAddress string) (*ssov1.LoginResponse, error) {
+	const op = "Auth.LoginWithSMSCode"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int64("app_id", int64(appID)),
+	)
+
+	if a.smsSender == nil {
+		return nil, fmt.Errorf("%s: %w", op, ErrSMSLoginDisabled)
+	}
+
+	number, err := phone.Normalize(phoneNumber)
+	if err != nil {
+		log.Info("invalid phone number")
+		return nil, fmt.Errorf("%s: %w", op, ErrInvalidPhone)
+	}
+
+	app, err := a.appForLogin(ctx, appID)
+	if err != nil {
+		log.Warn("invalid app", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.checkLockout(ctx, number, ipAddress); err != nil {
+		if !errors.Is(err, ErrAccountLocked) {
+			log.Error("failed to check lockout", sl.Err(err))
+		} else {
+			log.Info("identifier locked out", sl.Err(err))
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	account, err := a.accountByPhone(ctx, number, appID)
+	if err != nil {
+		if errors.Is(err, storage.ErrAccountNotFound) {
+			err := a.unknownAccountLogin(ctx, number, ipAddress)
+			logCredentialFailure(log, err)
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		log.Error("failed to get account", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log = log.With(slog.Int64("account_id", account.ID))
+
+	if err := a.ConsumeOneTimeCode(ctx, account.ID, models.CodePurposeSMSLogin, code); err != nil {
+		if !errors.Is(err, ErrInvalidCode) && !errors.Is(err, ErrCodeAlreadyUsed) {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		err := a.failedLogin(ctx, number, ipAddress, failureBadSMSCode)
+		logCredentialFailure(log, err)
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := loginStatusError(account.Status); err != nil {
+		log.Info("account status forbids login", slog.Int("status", int(account.Status)))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	secondFactorVerified, err := a.checkSecondFactor(ctx, log, account.ID, secondFactor)
+	if err != nil {
+		switch {
+		case errors.Is(err, errBadSecondFactor):
+			err := a.failedLogin(ctx, number, ipAddress, failureBadSecondFactor)
+			logCredentialFailure(log, err)
+			return nil, fmt.Errorf("%s: %w", op, err)
+		case errors.Is(err, ErrSecondFactorRequired):
+			log.Info("second factor required")
+		default:
+			log.Error("failed to check second factor", sl.Err(err))
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.checkMFAPolicy(ctx, account.ID, app); err != nil {
+		log.Info("mfa policy not satisfied", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.checkLoginRisk(ctx, log, account.ID, ipAddress, secondFactorVerified, false); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	account, err = a.accountForApp(ctx, account, appID)
+	if err != nil {
+		log.Warn("failed to resolve app role", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if a.lockout != nil {
+		if err := a.lockout.Success(ctx, number); err != nil {
+			log.Warn("failed to record lockout success", sl.Err(err))
+		}
+	}
+
+	response, _, err := a.startSession(ctx, log, account, app, userAgent, ipAddress, false, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("user logged in with sms code")
+
+	return response, nil
+}
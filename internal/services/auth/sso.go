@@ -0,0 +1,141 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sso/internal/domain/models"
+	"sso/internal/lib/logger/sl"
+	"sso/internal/storage"
+	"time"
+)
+
+const defaultSSOTicketTTL = time.Minute
+
+var ErrInvalidSSOTicket = errors.New("invalid or expired sso ticket")
+
+type SSOTicketStore interface {
+	SaveSSOTicket(ctx context.Context, ticketHash string, ticket models.SSOTicket) error
+	ConsumeSSOTicket(ctx context.Context, ticketHash string, now time.Time) (models.SSOTicket, error)
+	DeleteExpiredSSOTickets(ctx context.Context, before time.Time, limit int) (deleted int64, err error)
+}
+
+// CreateSSOTicket issues a single-use ticket that RedeemSSOTicket exchanges for a
+// session in targetAppID. The caller proves its login with an active access token;
+// the account must be a member of both the token's app and the target app.
+func (a *Auth) CreateSSOTicket(ctx context.Context, token string, targetAppID int32) (string, error) {
+	const op = "Auth.CreateSSOTicket"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int64("target_app_id", int64(targetAppID)),
+	)
+
+	session, err := a.CurrentSession(ctx, token)
+	if err != nil {
+		log.Info("no active session", sl.Err(err))
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	log = log.With(slog.Int64("account_id", session.AccountID))
+
+	account, err := a.accountProvider.AccountById(ctx, session.AccountID)
+	if err != nil {
+		log.Error("failed to get account", sl.Err(err))
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	sourceAppID := session.AppID
+	if sourceAppID == 0 {
+		sourceAppID = account.AppId
+	}
+
+	for _, appID := range []int32{sourceAppID, targetAppID} {
+		if _, err := a.accountForApp(ctx, account, appID); err != nil {
+			log.Warn("account is not a member of both apps", slog.Int64("app_id", int64(appID)), sl.Err(err))
+			return "", fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	ticket, err := a.secrets.Token()
+	if err != nil {
+		log.Error("failed to generate ticket", sl.Err(err))
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = a.ssoTicketStore.SaveSSOTicket(ctx, hashCode(ticket), models.SSOTicket{
+		AccountID:   account.ID,
+		SourceAppID: sourceAppID,
+		TargetAppID: targetAppID,
+		ExpiresAt:   time.Now().Add(a.ssoTicketTTL),
+	})
+	if err != nil {
+		log.Error("failed to save ticket", sl.Err(err))
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("sso ticket issued")
+
+	return ticket, nil
+}
+
+// RedeemSSOTicket exchanges a ticket from CreateSSOTicket for a new session in the
+// ticket's target app. The ticket is consumed even if the session can't be issued.
+func (a *Auth) RedeemSSOTicket(ctx context.Context, ticket string, userAgent string, ipAddress string) (token string, refreshToken string, expiresAt int64, err error) {
+	const op = "Auth.RedeemSSOTicket"
+
+	log := a.log.With(
+		slog.String("op", op),
+	)
+
+	t, err := a.ssoTicketStore.ConsumeSSOTicket(ctx, hashCode(ticket), time.Now())
+	if err != nil {
+		if errors.Is(err, storage.ErrCodeNotFound) {
+			log.Info("invalid ticket")
+			return "", "", 0, fmt.Errorf("%s: %w", op, ErrInvalidSSOTicket)
+		}
+		log.Error("failed to consume ticket", sl.Err(err))
+		return "", "", 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log = log.With(
+		slog.Int64("account_id", t.AccountID),
+		slog.Int64("app_id", int64(t.TargetAppID)),
+	)
+
+	account, err := a.accountProvider.AccountById(ctx, t.AccountID)
+	if err != nil {
+		log.Error("failed to get account", sl.Err(err))
+		return "", "", 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := loginStatusError(account.Status); err != nil {
+		log.Info("account status forbids login", slog.Int("status", int(account.Status)))
+		return "", "", 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	app, err := a.appForLogin(ctx, t.TargetAppID)
+	if err != nil {
+		log.Warn("invalid app", sl.Err(err))
+		return "", "", 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.checkMFAPolicy(ctx, account.ID, app); err != nil {
+		log.Info("mfa policy not satisfied", sl.Err(err))
+		return "", "", 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	account, err = a.accountForApp(ctx, account, t.TargetAppID)
+	if err != nil {
+		log.Warn("failed to resolve app role", sl.Err(err))
+		return "", "", 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	session, err := a.issueSession(ctx, log, account, app, userAgent, ipAddress, false)
+	if err != nil {
+		return "", "", 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return session.Token, session.RefreshToken, session.ExpiresAt.Unix(), nil
+}
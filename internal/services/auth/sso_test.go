@@ -0,0 +1,87 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"sso/internal/domain/models"
+)
+
+func TestSSOTicket(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+		// member tells whether the account is a member of the target app.
+		member bool
+		// before runs between issuing and redeeming the ticket.
+		before        func(t *testing.T, a *Auth, ticket string)
+		wantCreateErr bool
+		wantRedeemErr error
+	}{
+		{name: "redeemed once", member: true},
+		{
+			name:   "redeemed twice",
+			member: true,
+			before: func(t *testing.T, a *Auth, ticket string) {
+				if _, _, _, err := a.RedeemSSOTicket(context.Background(), ticket, testUserAgent, testIP); err != nil {
+					t.Fatalf("first redeem: %v", err)
+				}
+			},
+			wantRedeemErr: ErrInvalidSSOTicket,
+		},
+		{
+			name:          "expired",
+			opts:          []Option{WithSSOTicketTTL(time.Millisecond)},
+			member:        true,
+			before:        func(*testing.T, *Auth, string) { time.Sleep(20 * time.Millisecond) },
+			wantRedeemErr: ErrInvalidSSOTicket,
+		},
+		{name: "not a member of the target app", wantCreateErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			a, st := newTestAuth(t, tt.opts...)
+			sourceID := newTestApp(t, st)
+			targetID := newTestApp(t, st)
+			accountID := registerTestAccount(t, a, sourceID, "user@example.com")
+			if tt.member {
+				if err := st.SaveMembership(ctx, accountID, targetID, models.USER); err != nil {
+					t.Fatalf("save membership: %v", err)
+				}
+			}
+			token := loginTestAccount(t, a, sourceID, "user@example.com").GetToken()
+
+			ticket, err := a.CreateSSOTicket(ctx, token, targetID)
+			if (err != nil) != tt.wantCreateErr {
+				t.Fatalf("create error = %v, want error %v", err, tt.wantCreateErr)
+			}
+			if tt.wantCreateErr {
+				return
+			}
+
+			if tt.before != nil {
+				tt.before(t, a, ticket)
+			}
+
+			targetToken, _, _, err := a.RedeemSSOTicket(ctx, ticket, testUserAgent, testIP)
+			if !errors.Is(err, tt.wantRedeemErr) {
+				t.Fatalf("redeem error = %v, want %v", err, tt.wantRedeemErr)
+			}
+			if tt.wantRedeemErr != nil {
+				return
+			}
+
+			session, err := a.CurrentSession(ctx, targetToken)
+			if err != nil {
+				t.Fatalf("current session: %v", err)
+			}
+			if session.AccountID != accountID || session.AppID != targetID {
+				t.Errorf("session of account %d in app %d, want %d in %d", session.AccountID, session.AppID, accountID, targetID)
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sso/internal/domain/events"
+	"sso/internal/domain/models"
+	"sso/internal/lib/logger/sl"
+	"time"
+)
+
+var ErrInvalidStatusTransition = errors.New("invalid account status transition")
+
+// validateStatusTransition rejects unknown statuses, any change to a deleted account,
+// and making an account dormant unless it is active.
+func validateStatusTransition(from, to models.AccountStatus) error {
+	switch to {
+	case models.ACTIVE, models.INACTIVE, models.DELETED, models.DORMANT, models.SUSPENDED, models.BANNED:
+	default:
+		return fmt.Errorf("%w: unknown status %d", ErrInvalidStatusTransition, to)
+	}
+
+	if from == models.DELETED && to != models.DELETED {
+		return fmt.Errorf("%w: account is deleted", ErrInvalidStatusTransition)
+	}
+
+	if to == models.DORMANT && from != models.ACTIVE && from != models.DORMANT {
+		return fmt.Errorf("%w: only active accounts can become dormant", ErrInvalidStatusTransition)
+	}
+
+	return nil
+}
+
+// loginStatusError returns the error for statuses that may not obtain tokens:
+// ErrAccountDormant, ErrAccountSuspended or ErrAccountBanned.
+func loginStatusError(status models.AccountStatus) error {
+	switch status {
+	case models.DORMANT:
+		return ErrAccountDormant
+	case models.SUSPENDED:
+		return ErrAccountSuspended
+	case models.BANNED:
+		return ErrAccountBanned
+	default:
+		return nil
+	}
+}
+
+// ChangeStatusBatch moves every account in accountIDs to status on behalf of actorID,
+// e.g. for a compliance action. Each transition is validated, and accounts leaving
+// ACTIVE have their sessions revoked. A failing account doesn't stop the batch: its
+// error is recorded in its result and joined into the returned error. Admin only.
+func (a *Auth) ChangeStatusBatch(ctx context.Context, actorID int64, accountIDs []int64, status models.AccountStatus, reason string) ([]models.StatusChangeResult, error) {
+	const op = "Auth.ChangeStatusBatch"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int64("actor_id", actorID),
+		slog.Int64("new_status", int64(status)),
+		slog.Int("accounts", len(accountIDs)),
+	)
+
+	if err := a.requireAdmin(ctx, actorID); err != nil {
+		log.Warn("admin check failed", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	results := make([]models.StatusChangeResult, 0, len(accountIDs))
+	var errs []error
+
+	for _, accountID := range accountIDs {
+		res := a.changeStatusInBatch(ctx, actorID, accountID, status, reason)
+		if res.Err != nil {
+			log.Warn("status change failed", slog.Int64("account_id", accountID), sl.Err(res.Err))
+			errs = append(errs, fmt.Errorf("account %d: %w", accountID, res.Err))
+		}
+		results = append(results, res)
+	}
+
+	log.Info("batch status change done", slog.Int("failed", len(errs)))
+
+	if len(errs) > 0 {
+		return results, fmt.Errorf("%s: %w", op, errors.Join(errs...))
+	}
+
+	return results, nil
+}
+
+func (a *Auth) changeStatusInBatch(ctx context.Context, actorID int64, accountID int64, status models.AccountStatus, reason string) models.StatusChangeResult {
+	res := models.StatusChangeResult{AccountID: accountID}
+
+	account, err := a.accountProvider.AccountById(ctx, accountID)
+	if err != nil {
+		res.Err = err
+		return res
+	}
+	res.OldStatus = account.Status
+
+	if account.Status == status {
+		return res
+	}
+
+	if err := validateStatusTransition(account.Status, status); err != nil {
+		res.Err = err
+		return res
+	}
+
+	if status == models.DELETED && a.deletedAccounts != nil {
+		err = a.softDeleteAccount(ctx, a.log, accountID, actorID)
+	} else {
+		err = a.accountSaver.UpdateStatus(ctx, accountID, status)
+	}
+	if err != nil {
+		res.Err = err
+		return res
+	}
+	res.Changed = true
+
+	a.publish(ctx, events.AccountStatusChanged{
+		AccountID:  accountID,
+		OldStatus:  account.Status,
+		NewStatus:  status,
+		ActorID:    actorID,
+		Reason:     reason,
+		OccurredAt: time.Now(),
+	})
+
+	if status != models.ACTIVE {
+		if err := a.revokeOtherSessions(ctx, accountID, "", models.RevokedAccountDisabled); err != nil {
+			res.Err = fmt.Errorf("status changed but revoking sessions failed: %w", err)
+		}
+	}
+
+	return res
+}
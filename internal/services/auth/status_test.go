@@ -0,0 +1,170 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"sso/internal/domain/events"
+	"sso/internal/domain/models"
+	"sso/internal/storage"
+)
+
+func TestChangeAccountStatus(t *testing.T) {
+	tests := []struct {
+		name    string
+		from    models.AccountStatus
+		to      models.AccountStatus
+		wantErr error
+	}{
+		{name: "suspend", from: models.ACTIVE, to: models.SUSPENDED},
+		{name: "reinstate", from: models.BANNED, to: models.ACTIVE},
+		{name: "unchanged", from: models.INACTIVE, to: models.INACTIVE},
+		{name: "unknown status", from: models.ACTIVE, to: models.AccountStatus(42), wantErr: ErrInvalidStatusTransition},
+		{name: "undelete", from: models.DELETED, to: models.ACTIVE, wantErr: ErrInvalidStatusTransition},
+		{name: "inactive to dormant", from: models.INACTIVE, to: models.DORMANT, wantErr: ErrInvalidStatusTransition},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			publisher := &capturingPublisher{}
+			a, st := newTestAuth(t, WithEventPublisher(publisher))
+			appID := newTestApp(t, st)
+			accountID := registerTestAccount(t, a, appID, "user@example.com")
+			if err := st.UpdateStatus(ctx, accountID, tt.from); err != nil {
+				t.Fatalf("set status: %v", err)
+			}
+			publisher.events = nil
+
+			err := a.ChangeAccountStatus(ctx, 7, accountID, tt.to, "support ticket")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("change error = %v, want %v", err, tt.wantErr)
+			}
+
+			account, err := st.AccountById(ctx, accountID)
+			if err != nil {
+				t.Fatalf("account: %v", err)
+			}
+			want := tt.to
+			if tt.wantErr != nil {
+				want = tt.from
+			}
+			if account.Status != want {
+				t.Errorf("status = %v, want %v", account.Status, want)
+			}
+
+			// Only an actual change is published.
+			if want == tt.from {
+				if len(publisher.events) != 0 {
+					t.Errorf("%d events published, want none", len(publisher.events))
+				}
+				return
+			}
+			if len(publisher.events) != 1 {
+				t.Fatalf("%d events published, want 1", len(publisher.events))
+			}
+			wantEvent := events.AccountStatusChanged{
+				AccountID: accountID,
+				OldStatus: tt.from,
+				NewStatus: tt.to,
+				ActorID:   7,
+				Reason:    "support ticket",
+			}
+			got, ok := publisher.events[0].(events.AccountStatusChanged)
+			if !ok {
+				t.Fatalf("event = %T, want AccountStatusChanged", publisher.events[0])
+			}
+			got.OccurredAt = time.Time{}
+			if got != wantEvent {
+				t.Errorf("event = %+v, want %+v", got, wantEvent)
+			}
+		})
+	}
+}
+
+func TestChangeStatusBatch(t *testing.T) {
+	ctx := context.Background()
+	a, st := newTestAuth(t)
+	appID := newTestApp(t, st)
+	adminID := newTestAdmin(t, st, appID)
+
+	active := registerTestAccount(t, a, appID, "active@example.com")
+	deleted := registerTestAccount(t, a, appID, "deleted@example.com")
+	if err := st.UpdateStatus(ctx, deleted, models.DELETED); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	const missing = int64(9999)
+
+	results, err := a.ChangeStatusBatch(ctx, adminID, []int64{active, missing, deleted}, models.SUSPENDED, "compliance")
+	if err == nil {
+		t.Fatal("batch with failing accounts returned no error")
+	}
+
+	tests := []struct {
+		name        string
+		accountID   int64
+		wantChanged bool
+		wantErr     error
+	}{
+		{name: "active account", accountID: active, wantChanged: true},
+		{name: "nonexistent account", accountID: missing, wantErr: storage.ErrAccountNotFound},
+		{name: "deleted account", accountID: deleted, wantErr: ErrInvalidStatusTransition},
+	}
+
+	if len(results) != len(tests) {
+		t.Fatalf("%d results, want %d", len(results), len(tests))
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := results[i]
+			if res.AccountID != tt.accountID {
+				t.Fatalf("result for account %d, want %d", res.AccountID, tt.accountID)
+			}
+			if res.Changed != tt.wantChanged {
+				t.Errorf("changed = %v, want %v", res.Changed, tt.wantChanged)
+			}
+			if !errors.Is(res.Err, tt.wantErr) {
+				t.Errorf("error = %v, want %v", res.Err, tt.wantErr)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("batch error %v doesn't carry %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLenientStatusCheck(t *testing.T) {
+	tests := []struct {
+		name      string
+		lenient   bool
+		wantValid bool
+	}{
+		{name: "strict", lenient: false, wantValid: false},
+		{name: "lenient", lenient: true, wantValid: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			a, st := newTestAuth(t, WithLenientStatusCheck(tt.lenient))
+			appID := newTestApp(t, st)
+			accountID := registerTestAccount(t, a, appID, "user@example.com")
+			token := loginTestAccount(t, a, appID, "user@example.com").GetToken()
+
+			if err := a.ChangeAccountStatus(ctx, 0, accountID, models.SUSPENDED, ""); err != nil {
+				t.Fatalf("suspend: %v", err)
+			}
+
+			validation, err := a.ValidateAccountSessionFrom(ctx, token, testUserAgent, testIP)
+			if err != nil {
+				t.Fatalf("validate: %v", err)
+			}
+			if validation.Valid != tt.wantValid {
+				t.Errorf("valid = %v, want %v", validation.Valid, tt.wantValid)
+			}
+		})
+	}
+}
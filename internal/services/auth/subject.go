@@ -0,0 +1,35 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sso/internal/domain/models"
+	"sso/internal/lib/logger/sl"
+)
+
+// AccountBySubject resolves the sub claim of a token issued by this service back
+// to its account, using the configured subject format. Subjects that don't match
+// the format return jwt.ErrInvalidSubject.
+func (a *Auth) AccountBySubject(ctx context.Context, sub string) (models.Account, error) {
+	const op = "Auth.AccountBySubject"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.String("sub", sub),
+	)
+
+	accountID, err := a.subjectFormat.AccountID(sub)
+	if err != nil {
+		log.Info("invalid subject", sl.Err(err))
+		return models.Account{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	account, err := a.accountProvider.AccountById(ctx, accountID)
+	if err != nil {
+		log.Error("failed to get account", sl.Err(err))
+		return models.Account{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return account, nil
+}
@@ -0,0 +1,44 @@
+package auth
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// TarpitDetector flags login attempts to tarpit, returning the matched rule.
+type TarpitDetector interface {
+	Flag(identifier, password, userAgent string) (reason string, flagged bool)
+}
+
+// tarpit holds a flagged login for the configured delay and then fails it with
+// ErrInvalidCredentials, whether or not the password was right. It reports false
+// for attempts that aren't flagged, which continue normally.
+func (a *Auth) tarpit(ctx context.Context, log *slog.Logger, identifier, password, userAgent, ipAddress string) (bool, error) {
+	if a.tarpitDetector == nil {
+		return false, nil
+	}
+
+	reason, flagged := a.tarpitDetector.Flag(identifier, password, userAgent)
+	if !flagged {
+		return false, nil
+	}
+
+	log.Warn("login attempt tarpitted",
+		slog.String("rule", reason),
+		slog.String("user_agent", userAgent),
+		slog.String("ip_address", ipAddress),
+		slog.Duration("delay", a.tarpitDelay),
+	)
+
+	t := time.NewTimer(a.tarpitDelay)
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		return true, ctx.Err()
+	case <-t.C:
+	}
+
+	return true, invalidCredentials(failureTarpitted)
+}
@@ -0,0 +1,71 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	ssov1 "github.com/dariasmyr/protos/gen/go/sso"
+
+	"sso/internal/lib/tarpit"
+)
+
+func TestLoginTarpit(t *testing.T) {
+	const delay = time.Second
+
+	tests := []struct {
+		name string
+		// breached lists the account's credentials as a breached pair.
+		breached  bool
+		password  string
+		userAgent string
+		wantErr   error
+		wantDelay bool
+	}{
+		{name: "regular login", password: testPassword, userAgent: testUserAgent},
+		{name: "regular wrong password", password: "wrong password", userAgent: testUserAgent, wantErr: ErrInvalidCredentials},
+		{name: "breached pair", breached: true, password: testPassword, userAgent: testUserAgent, wantErr: ErrInvalidCredentials, wantDelay: true},
+		{name: "attack tool", password: testPassword, userAgent: "sqlmap/1.7", wantErr: ErrInvalidCredentials, wantDelay: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var breached []string
+			if tt.breached {
+				breached = []string{tarpit.PairHash("user@example.com", testPassword)}
+			}
+			a, st := newTestAuth(t, WithTarpit(tarpit.New(breached, []string{"sqlmap"}), delay))
+			appID := newTestApp(t, st)
+			registerTestAccount(t, a, appID, "user@example.com")
+
+			start := time.Now()
+			_, err := a.Login(context.Background(), &ssov1.LoginRequest{Email: "user@example.com", Password: tt.password, AppId: appID, UserAgent: tt.userAgent, IpAddress: testIP})
+			elapsed := time.Since(start)
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("login error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantDelay && elapsed < delay {
+				t.Errorf("flagged login took %v, want at least %v", elapsed, delay)
+			}
+			if !tt.wantDelay && elapsed >= delay {
+				t.Errorf("unflagged login took %v, want less than %v", elapsed, delay)
+			}
+		})
+	}
+}
+
+func TestLoginTarpitHonorsDeadline(t *testing.T) {
+	a, st := newTestAuth(t, WithTarpit(tarpit.New(nil, []string{"sqlmap"}), time.Hour))
+	appID := newTestApp(t, st)
+	registerTestAccount(t, a, appID, "user@example.com")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err := a.Login(ctx, &ssov1.LoginRequest{Email: "user@example.com", Password: testPassword, AppId: appID, UserAgent: "sqlmap/1.7", IpAddress: testIP})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("login error = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
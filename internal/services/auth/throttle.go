@@ -0,0 +1,143 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sso/internal/lib/lockout"
+	"sso/internal/lib/logger/sl"
+	"time"
+)
+
+var (
+	ErrLoginThrottled = errors.New("too many failed login attempts")
+	ErrAccountLocked  = errors.New("account temporarily locked")
+)
+
+// failedLogin records a failed attempt for the identifier and client IP, waits out
+// the configured failure delay and returns the error the caller should report:
+// ErrLoginThrottled once the identifier is over its limit, ErrInvalidCredentials
+// otherwise. With CAPTCHAs configured, going over the limit instead flags the
+// identifier and IP to solve one and returns ErrCaptchaRequired.
+//
+// Without a failure limiter it returns ErrInvalidCredentials straight away.
+func (a *Auth) failedLogin(ctx context.Context, identifier string, ipAddress string, reason credentialFailure) error {
+	if a.lockout != nil {
+		a.lockoutFailure(ctx, a.lockout, identifier)
+	}
+	a.ipLockoutFailure(ctx, ipAddress)
+
+	err := a.failureResponse(ctx, identifier, reason)
+	if errors.Is(err, ErrLoginThrottled) && a.captcha != nil {
+		a.flagForCaptcha(identifier, ipAddress)
+		return ErrCaptchaRequired
+	}
+
+	return err
+}
+
+// failureResponse is failedLogin without the lockout bookkeeping.
+func (a *Auth) failureResponse(ctx context.Context, identifier string, reason credentialFailure) error {
+	if a.failedLogins == nil {
+		return invalidCredentials(reason)
+	}
+
+	res := a.failedLogins.Allow(identifier)
+
+	if a.failureDelay > 0 {
+		t := time.NewTimer(a.failureDelay)
+		defer t.Stop()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+
+	if !res.Allowed {
+		return ErrLoginThrottled
+	}
+
+	return invalidCredentials(reason)
+}
+
+// unknownAccountLogin is the failure path for identifiers with no account. By default
+// it only reports ErrInvalidCredentials; with uniform throttling it goes through the
+// same limiter and delay as a wrong password, so probing unknown identifiers costs
+// as much as probing real ones. Either way the failure counts against the client IP.
+func (a *Auth) unknownAccountLogin(ctx context.Context, identifier string, ipAddress string) error {
+	if !a.throttleUnknownAccounts {
+		a.ipLockoutFailure(ctx, ipAddress)
+		return invalidCredentials(failureUnknownAccount)
+	}
+
+	return a.failedLogin(ctx, identifier, ipAddress, failureUnknownAccount)
+}
+
+// ipLockoutKey keeps client IPs apart from identifiers in a shared lockout store.
+func ipLockoutKey(ipAddress string) string {
+	return "ip:" + ipAddress
+}
+
+// checkLockout returns ErrAccountLocked while the identifier or the client IP is
+// locked out.
+func (a *Auth) checkLockout(ctx context.Context, identifier string, ipAddress string) error {
+	if a.lockout != nil {
+		if err := lockoutError(ctx, a.lockout, identifier); err != nil {
+			return err
+		}
+	}
+
+	if a.ipLockout != nil && ipAddress != "" {
+		if err := lockoutError(ctx, a.ipLockout, ipLockoutKey(ipAddress)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func lockoutError(ctx context.Context, tracker *lockout.Tracker, key string) error {
+	remaining, locked, err := tracker.Locked(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	if locked {
+		return fmt.Errorf("%w for %s", ErrAccountLocked, remaining.Round(time.Second))
+	}
+
+	return nil
+}
+
+// lockoutFailure counts a failed login against key. A store failure is only
+// logged: the login has failed either way.
+func (a *Auth) lockoutFailure(ctx context.Context, tracker *lockout.Tracker, key string) {
+	if _, err := tracker.Failure(ctx, key); err != nil {
+		a.log.Error("failed to record lockout failure", sl.Err(err))
+	}
+}
+
+func (a *Auth) ipLockoutFailure(ctx context.Context, ipAddress string) {
+	if a.ipLockout != nil && ipAddress != "" {
+		a.lockoutFailure(ctx, a.ipLockout, ipLockoutKey(ipAddress))
+	}
+}
+
+// lockedLogin is the failure path for a locked-out identifier. By default it
+// returns lockErr as is. With a concealed lockout it pays for a password compare
+// and goes through the same limiter and delay as a wrong password, then reports
+// ErrInvalidCredentials, so clients can't tell a locked account from a bad password.
+// The attempt doesn't count towards the lockout, which would only extend the lock.
+func (a *Auth) lockedLogin(ctx context.Context, identifier string, password string, lockErr error) error {
+	if !a.concealLockout {
+		return lockErr
+	}
+
+	if err := a.compareDummyPassword(ctx, password); err != nil {
+		return err
+	}
+
+	return a.failureResponse(ctx, identifier, failureLockedOut)
+}
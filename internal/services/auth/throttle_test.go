@@ -0,0 +1,94 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"sso/internal/lib/lockout"
+	"sso/internal/lib/ratelimit"
+
+	ssov1 "github.com/dariasmyr/protos/gen/go/sso"
+)
+
+func TestLockedLogin(t *testing.T) {
+	tests := []struct {
+		name       string
+		conceal    bool
+		password   string
+		wantErr    error
+		wantReason string
+	}{
+		{name: "reported", password: testPassword, wantErr: ErrAccountLocked},
+		{name: "reported on wrong password", password: "wrong-Password-1", wantErr: ErrAccountLocked},
+		{name: "concealed", conceal: true, password: testPassword, wantErr: ErrInvalidCredentials, wantReason: string(failureLockedOut)},
+		{name: "concealed on wrong password", conceal: true, password: "wrong-Password-1", wantErr: ErrInvalidCredentials, wantReason: string(failureLockedOut)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			tracker := lockout.New([]lockout.Tier{{Failures: 2, Duration: time.Hour}}, time.Hour, 0, nil)
+			a, st := newTestAuth(t, WithLockout(tracker, tt.conceal))
+			appID := newTestApp(t, st)
+			registerTestAccount(t, a, appID, "user@example.com")
+
+			login := func(password string) error {
+				_, err := a.Login(ctx, &ssov1.LoginRequest{Email: "user@example.com", Password: password, AppId: appID, UserAgent: testUserAgent, IpAddress: testIP})
+				return err
+			}
+
+			for range 2 {
+				if err := login("wrong-Password-1"); !errors.Is(err, ErrInvalidCredentials) {
+					t.Fatalf("failed login error = %v, want ErrInvalidCredentials", err)
+				}
+			}
+
+			err := login(tt.password)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("locked login error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.conceal && errors.Is(err, ErrAccountLocked) {
+				t.Error("concealed lockout still reports ErrAccountLocked")
+			}
+			if reason, _, _ := CredentialFailureReason(err); reason != tt.wantReason {
+				t.Errorf("failure reason = %q, want %q", reason, tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestUnknownAccountThrottle(t *testing.T) {
+	tests := []struct {
+		name       string
+		uniform    bool
+		identifier string
+		wantLast   error
+	}{
+		{name: "wrong password", identifier: "user@example.com", wantLast: ErrLoginThrottled},
+		{name: "unknown account", identifier: "nobody@example.com", wantLast: ErrInvalidCredentials},
+		{name: "uniform wrong password", uniform: true, identifier: "user@example.com", wantLast: ErrLoginThrottled},
+		{name: "uniform unknown account", uniform: true, identifier: "nobody@example.com", wantLast: ErrLoginThrottled},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			a, st := newTestAuth(t,
+				WithFailedLoginThrottle(ratelimit.New(2, time.Hour), 0),
+				WithUniformUnknownAccountThrottle(tt.uniform),
+			)
+			appID := newTestApp(t, st)
+			registerTestAccount(t, a, appID, "user@example.com")
+
+			var err error
+			for range 3 {
+				_, err = a.Login(ctx, &ssov1.LoginRequest{Email: tt.identifier, Password: "wrong-Password-1", AppId: appID, UserAgent: testUserAgent, IpAddress: testIP})
+			}
+			if !errors.Is(err, tt.wantLast) {
+				t.Errorf("third login error = %v, want %v", err, tt.wantLast)
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package auth
+
+import (
+	"context"
+	"testing"
+)
+
+func TestAccountTokenVersion(t *testing.T) {
+	const appSecret = "token-version-secret"
+
+	ctx := context.Background()
+	a, st := newTestAuth(t)
+	id, err := st.SaveApp(ctx, "app", appSecret, "")
+	if err != nil {
+		t.Fatalf("save app: %v", err)
+	}
+	appID := int32(id)
+	accountID := registerTestAccount(t, a, appID, "user@example.com")
+	otherID := registerTestAccount(t, a, appID, "other@example.com")
+	superseded := loginTestAccount(t, a, appID, "user@example.com")
+	other := loginTestAccount(t, a, appID, "other@example.com")
+
+	exchanged, err := a.TokenExchange(ctx, appID, appSecret, superseded.GetToken(), appID, nil)
+	if err != nil {
+		t.Fatalf("exchange: %v", err)
+	}
+
+	// Bumping the version alone, without revoking sessions, must be enough.
+	if err := st.IncrementTokenVersion(ctx, accountID); err != nil {
+		t.Fatalf("increment token version: %v", err)
+	}
+
+	current := loginTestAccount(t, a, appID, "user@example.com")
+
+	tests := []struct {
+		name         string
+		accountID    int64
+		token        string
+		wantRejected bool
+	}{
+		{name: "superseded access token", accountID: accountID, token: superseded.GetToken(), wantRejected: true},
+		{name: "superseded exchanged token", accountID: accountID, token: exchanged.Token, wantRejected: true},
+		{name: "current access token", accountID: accountID, token: current.GetToken()},
+		{name: "other account", accountID: otherID, token: other.GetToken()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := accessTokenRejected(t, a, tt.accountID, tt.token); got != tt.wantRejected {
+				t.Errorf("rejected = %v, want %v", got, tt.wantRejected)
+			}
+		})
+	}
+}
@@ -0,0 +1,245 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sso/internal/lib/logger/sl"
+	"sso/internal/lib/totp"
+	"sso/internal/storage"
+	"time"
+)
+
+var (
+	ErrInvalidTOTPCode      = errors.New("invalid totp code")
+	ErrTOTPRotationDisabled = errors.New("totp rotation is not configured")
+	ErrTOTPDisabled         = errors.New("totp is not configured")
+	ErrTOTPAlreadyEnrolled  = errors.New("totp already enrolled")
+)
+
+// TOTPStore keeps the account's TOTP secret, a pending replacement for it and its
+// recovery codes. PromotePendingTOTPSecret must swap the secrets and delete the
+// unused recovery codes atomically; ConfirmTOTPSecret must confirm the secret and
+// store the recovery codes atomically.
+type TOTPStore interface {
+	SaveTOTPSecret(ctx context.Context, accountId int64, secret []byte) error
+	TOTPSecret(ctx context.Context, accountId int64) ([]byte, bool, error)
+	ConfirmTOTPSecret(ctx context.Context, accountId int64, recoveryCodeHashes []string) error
+	SavePendingTOTPSecret(ctx context.Context, accountId int64, secret []byte) error
+	PendingTOTPSecret(ctx context.Context, accountId int64) ([]byte, error)
+	PromotePendingTOTPSecret(ctx context.Context, accountId int64, secret []byte) (int64, error)
+	TouchTOTPSecret(ctx context.Context, accountId int64, usedAt time.Time) error
+	RecoveryCodeStore
+}
+
+// totpEnrollmentMaxAge is how recently the caller must have signed in to enroll TOTP.
+const totpEnrollmentMaxAge = 5 * time.Minute
+
+// EnrollTOTP starts TOTP enrollment for the account that owns the presented access
+// token and returns the secret, base32-encoded for the authenticator app. TOTP is
+// not enforced until ConfirmTOTPEnrollment accepts a code; calling EnrollTOTP
+// again before that replaces the secret. Accounts with confirmed TOTP get
+// ErrTOTPAlreadyEnrolled and should use RotateTOTP instead.
+func (a *Auth) EnrollTOTP(ctx context.Context, token string) (string, error) {
+	const op = "Auth.EnrollTOTP"
+
+	log := a.log.With(
+		slog.String("op", op),
+	)
+
+	if a.totpStore == nil {
+		return "", fmt.Errorf("%s: %w", op, ErrTOTPDisabled)
+	}
+
+	accountID, err := a.stepUpAccount(ctx, token, totpEnrollmentMaxAge)
+	if err != nil {
+		log.Info("step-up check failed", sl.Err(err))
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	log = log.With(slog.Int64("account_id", accountID))
+
+	_, confirmed, err := a.totpStore.TOTPSecret(ctx, accountID)
+	switch {
+	case err == nil && confirmed:
+		log.Info("totp already enrolled")
+		return "", fmt.Errorf("%s: %w", op, ErrTOTPAlreadyEnrolled)
+	case err != nil && !errors.Is(err, storage.ErrTOTPNotEnrolled):
+		log.Error("failed to get totp secret", sl.Err(err))
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	secret, err := totp.NewSecret()
+	if err != nil {
+		log.Error("failed to generate secret", sl.Err(err))
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.totpStore.SaveTOTPSecret(ctx, accountID, secret); err != nil {
+		log.Error("failed to save secret", sl.Err(err))
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("totp enrollment started")
+
+	return totp.Encode(secret), nil
+}
+
+// ConfirmTOTPEnrollment finishes an enrollment started by EnrollTOTP. code must be
+// valid for the new secret. On success TOTP is required at login from then on and
+// the account's recovery codes are returned; they are shown this once and only
+// their hashes are kept.
+func (a *Auth) ConfirmTOTPEnrollment(ctx context.Context, token string, code string) ([]string, error) {
+	const op = "Auth.ConfirmTOTPEnrollment"
+
+	log := a.log.With(
+		slog.String("op", op),
+	)
+
+	if a.totpStore == nil {
+		return nil, fmt.Errorf("%s: %w", op, ErrTOTPDisabled)
+	}
+
+	accountID, err := a.stepUpAccount(ctx, token, totpEnrollmentMaxAge)
+	if err != nil {
+		log.Info("step-up check failed", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log = log.With(slog.Int64("account_id", accountID))
+
+	secret, confirmed, err := a.totpStore.TOTPSecret(ctx, accountID)
+	if err != nil {
+		log.Info("no pending enrollment", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if confirmed {
+		log.Info("totp already enrolled")
+		return nil, fmt.Errorf("%s: %w", op, ErrTOTPAlreadyEnrolled)
+	}
+
+	if !totp.Validate(secret, code, time.Now()) {
+		log.Info("invalid confirmation code")
+		return nil, fmt.Errorf("%s: %w", op, ErrInvalidTOTPCode)
+	}
+
+	codes, hashes, err := a.newRecoveryCodes()
+	if err != nil {
+		log.Error("failed to generate recovery codes", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.totpStore.ConfirmTOTPSecret(ctx, accountID, hashes); err != nil {
+		log.Error("failed to confirm secret", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("totp enrolled")
+
+	return codes, nil
+}
+
+// totpRotationMaxAge is how recently the caller must have signed in to rotate TOTP.
+const totpRotationMaxAge = 5 * time.Minute
+
+// RotateTOTP starts re-enrolling TOTP for the account that owns the presented
+// access token and returns the new secret, base32-encoded for the authenticator
+// app. The old secret keeps working until ConfirmTOTPRotation accepts a code from
+// the new one, so the account is never left without MFA. Calling it again
+// replaces the pending secret. Returns ErrReauthRequired unless the caller signed
+// in within the last few minutes.
+func (a *Auth) RotateTOTP(ctx context.Context, token string) (string, error) {
+	const op = "Auth.RotateTOTP"
+
+	log := a.log.With(
+		slog.String("op", op),
+	)
+
+	if a.totpStore == nil {
+		return "", fmt.Errorf("%s: %w", op, ErrTOTPRotationDisabled)
+	}
+
+	accountID, err := a.stepUpAccount(ctx, token, totpRotationMaxAge)
+	if err != nil {
+		log.Info("step-up check failed", sl.Err(err))
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	log = log.With(slog.Int64("account_id", accountID))
+
+	secret, err := totp.NewSecret()
+	if err != nil {
+		log.Error("failed to generate secret", sl.Err(err))
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.totpStore.SavePendingTOTPSecret(ctx, accountID, secret); err != nil {
+		log.Error("failed to save pending secret", sl.Err(err))
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("totp rotation started")
+
+	return totp.Encode(secret), nil
+}
+
+// ConfirmTOTPRotation finishes a rotation started by RotateTOTP. code must be
+// valid for the new secret; on success it replaces the old one and every unused
+// recovery code is invalidated, so codes printed for the old enrollment no longer
+// work. Returns ErrInvalidTOTPCode for a wrong code, leaving the rotation pending.
+func (a *Auth) ConfirmTOTPRotation(ctx context.Context, token string, code string) error {
+	const op = "Auth.ConfirmTOTPRotation"
+
+	log := a.log.With(
+		slog.String("op", op),
+	)
+
+	if a.totpStore == nil {
+		return fmt.Errorf("%s: %w", op, ErrTOTPRotationDisabled)
+	}
+
+	accountID, err := a.stepUpAccount(ctx, token, totpRotationMaxAge)
+	if err != nil {
+		log.Info("step-up check failed", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log = log.With(slog.Int64("account_id", accountID))
+
+	secret, err := a.totpStore.PendingTOTPSecret(ctx, accountID)
+	if err != nil {
+		log.Info("no pending rotation", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if !totp.Validate(secret, code, time.Now()) {
+		log.Info("invalid confirmation code")
+		return fmt.Errorf("%s: %w", op, ErrInvalidTOTPCode)
+	}
+
+	invalidated, err := a.totpStore.PromotePendingTOTPSecret(ctx, accountID, secret)
+	if err != nil {
+		log.Error("failed to replace secret", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("totp secret rotated", slog.Int64("recovery_codes_invalidated", invalidated))
+
+	return nil
+}
+
+// stepUpAccount returns the account that owns token after checking that it
+// authenticated within maxAge or reauthenticated, see RequireRecentAuth.
+func (a *Auth) stepUpAccount(ctx context.Context, token string, maxAge time.Duration) (int64, error) {
+	if err := a.RequireRecentAuth(ctx, token, maxAge); err != nil {
+		return 0, err
+	}
+
+	session, err := a.CurrentSession(ctx, token)
+	if err != nil {
+		return 0, err
+	}
+
+	return session.AccountID, nil
+}
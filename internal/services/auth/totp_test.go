@@ -0,0 +1,140 @@
+package auth
+
+import (
+	"context"
+	"encoding/base32"
+	"errors"
+	"testing"
+	"time"
+
+	"sso/internal/lib/totp"
+	"sso/internal/storage"
+)
+
+// enrollTestTOTP enrolls TOTP for the account behind token and returns its secret
+// and recovery codes.
+func enrollTestTOTP(t *testing.T, a *Auth, token string) ([]byte, []string) {
+	t.Helper()
+
+	ctx := context.Background()
+	encoded, err := a.EnrollTOTP(ctx, token)
+	if err != nil {
+		t.Fatalf("enroll: %v", err)
+	}
+	secret := decodeTOTPSecret(t, encoded)
+
+	codes, err := a.ConfirmTOTPEnrollment(ctx, token, totp.Code(secret, time.Now()))
+	if err != nil {
+		t.Fatalf("confirm enrollment: %v", err)
+	}
+
+	return secret, codes
+}
+
+func decodeTOTPSecret(t *testing.T, encoded string) []byte {
+	t.Helper()
+
+	secret, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(encoded)
+	if err != nil {
+		t.Fatalf("decode secret: %v", err)
+	}
+
+	return secret
+}
+
+func TestRotateTOTP(t *testing.T) {
+	tests := []struct {
+		name string
+		// confirm returns the code to confirm the rotation with, or "" to leave it
+		// unconfirmed.
+		confirm     func(oldSecret, newSecret []byte) string
+		wantErr     error
+		wantRotated bool
+	}{
+		{
+			name:        "confirmed with a code from the new secret",
+			confirm:     func(_, newSecret []byte) string { return totp.Code(newSecret, time.Now()) },
+			wantRotated: true,
+		},
+		{
+			name:    "confirmed with a code from the old secret",
+			confirm: func(oldSecret, _ []byte) string { return totp.Code(oldSecret, time.Now()) },
+			wantErr: ErrInvalidTOTPCode,
+		},
+		{
+			name:    "confirmed with a wrong code",
+			confirm: func(_, _ []byte) string { return "12345" },
+			wantErr: ErrInvalidTOTPCode,
+		},
+		{name: "not confirmed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			a, st := newTestAuth(t)
+			WithTOTPStore(st)(a)
+			appID := newTestApp(t, st)
+			accountID := registerTestAccount(t, a, appID, "user@example.com")
+			token := loginTestAccount(t, a, appID, "user@example.com").GetToken()
+			oldSecret, recoveryCodes := enrollTestTOTP(t, a, token)
+
+			encoded, err := a.RotateTOTP(ctx, token)
+			if err != nil {
+				t.Fatalf("rotate: %v", err)
+			}
+			newSecret := decodeTOTPSecret(t, encoded)
+
+			if tt.confirm != nil {
+				err := a.ConfirmTOTPRotation(ctx, token, tt.confirm(oldSecret, newSecret))
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("confirm rotation error = %v, want %v", err, tt.wantErr)
+				}
+			}
+
+			// The secret in force and the recovery codes flip together, only once
+			// the rotation is confirmed.
+			checks := []struct {
+				name       string
+				code       string
+				wantPasses bool
+			}{
+				{name: "old secret", code: totp.Code(oldSecret, time.Now()), wantPasses: !tt.wantRotated},
+				{name: "new secret", code: totp.Code(newSecret, time.Now()), wantPasses: tt.wantRotated},
+				{name: "old recovery code", code: recoveryCodes[0], wantPasses: !tt.wantRotated},
+			}
+			for _, check := range checks {
+				verified, err := a.checkSecondFactor(ctx, a.log, accountID, check.code)
+				if passes := err == nil && verified; passes != check.wantPasses {
+					t.Errorf("%s passes = %v (error %v), want %v", check.name, passes, err, check.wantPasses)
+				}
+			}
+		})
+	}
+}
+
+func TestConfirmTOTPRotationWithoutRotating(t *testing.T) {
+	ctx := context.Background()
+	a, st := newTestAuth(t)
+	WithTOTPStore(st)(a)
+	appID := newTestApp(t, st)
+	registerTestAccount(t, a, appID, "user@example.com")
+	token := loginTestAccount(t, a, appID, "user@example.com").GetToken()
+	secret, _ := enrollTestTOTP(t, a, token)
+
+	err := a.ConfirmTOTPRotation(ctx, token, totp.Code(secret, time.Now()))
+	if !errors.Is(err, storage.ErrTOTPNotPending) {
+		t.Errorf("confirm rotation error = %v, want %v", err, storage.ErrTOTPNotPending)
+	}
+}
+
+func TestRotateTOTPDisabled(t *testing.T) {
+	a, st := newTestAuth(t)
+	appID := newTestApp(t, st)
+	registerTestAccount(t, a, appID, "user@example.com")
+	token := loginTestAccount(t, a, appID, "user@example.com").GetToken()
+
+	if _, err := a.RotateTOTP(context.Background(), token); !errors.Is(err, ErrTOTPRotationDisabled) {
+		t.Errorf("rotate error = %v, want %v", err, ErrTOTPRotationDisabled)
+	}
+}
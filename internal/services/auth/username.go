@@ -0,0 +1,171 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+	"time"
+
+	"sso/internal/domain/events"
+	"sso/internal/lib/logger/sl"
+	"sso/internal/storage"
+)
+
+var (
+	ErrInvalidUsername  = errors.New("invalid username")
+	ErrUsernameRequired = errors.New("username required")
+)
+
+// usernamePattern allows 3 to 32 lowercase letters, digits, dots, underscores and
+// hyphens, starting with a letter. Without an @ a username is never mistaken for an
+// email on login.
+var usernamePattern = regexp.MustCompile(`^[a-z][a-z0-9._-]{2,31}$`)
+
+// isUsername reports whether a login identifier is a username rather than an email.
+func isUsername(identifier string) bool {
+	return !strings.Contains(identifier, "@")
+}
+
+// checkUsername checks a normalized username for an account of appID: it returns
+// ErrUsernameRequired if it is empty but the app requires one, ErrInvalidUsername
+// if it doesn't match usernamePattern and storage.ErrUsernameExists if it is taken.
+func (a *Auth) checkUsername(ctx context.Context, appID int32, username string) error {
+	if username == "" {
+		required, err := a.usernameRequired(ctx, appID)
+		if err != nil {
+			return err
+		}
+		if required {
+			return ErrUsernameRequired
+		}
+		return nil
+	}
+
+	if !usernamePattern.MatchString(username) {
+		return ErrInvalidUsername
+	}
+
+	return a.checkUsernameAvailable(ctx, username, appID)
+}
+
+// usernameRequired reports whether the app requires accounts to have a username.
+// Unknown apps don't.
+func (a *Auth) usernameRequired(ctx context.Context, appID int32) (bool, error) {
+	if appID == 0 {
+		return false, nil
+	}
+
+	app, err := a.appProvider.App(ctx, appID)
+	if err != nil {
+		if errors.Is(err, storage.ErrAppNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return app.RequireUsername, nil
+}
+
+// SetUsername sets the username of the account owning the presented access token,
+// which it can then log in with instead of its email. Usernames are normalized like
+// other identifiers and must match usernamePattern, else ErrInvalidUsername; an
+// empty one removes it, unless the account's app requires one. Returns
+// storage.ErrUsernameExists if another account has it.
+func (a *Auth) SetUsername(ctx context.Context, token string, username string) error {
+	const op = "Auth.SetUsername"
+
+	log := a.log.With(
+		slog.String("op", op),
+	)
+
+	session, err := a.CurrentSession(ctx, token)
+	if err != nil {
+		log.Info("invalid session", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log = log.With(slog.Int64("account_id", session.AccountID))
+
+	if err := a.requireSensitiveAuth(ctx, token); err != nil {
+		log.Info("recent authentication required", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	account, err := a.accountProvider.AccountById(ctx, session.AccountID)
+	if err != nil {
+		log.Error("failed to get account", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	username = a.identifierNormalizer.Normalize(username)
+	if username == account.Username {
+		return nil
+	}
+
+	if err := a.checkUsername(ctx, account.AppId, username); err != nil {
+		logUsernameRejected(log, err)
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.accountSaver.SetUsername(ctx, account.ID, username); err != nil {
+		if errors.Is(err, storage.ErrUsernameExists) {
+			logUsernameRejected(log, err)
+		} else {
+			log.Error("failed to set username", sl.Err(err))
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	a.publish(ctx, events.UsernameChanged{
+		AccountID:   account.ID,
+		OldUsername: account.Username,
+		NewUsername: username,
+		OccurredAt:  time.Now(),
+	})
+
+	log.Info("username changed")
+
+	return nil
+}
+
+// logUsernameRejected logs why checkUsername rejected a username at the level the
+// reason deserves: a bad choice is info, a failure to check is an error.
+func logUsernameRejected(log *slog.Logger, err error) {
+	switch {
+	case errors.Is(err, ErrUsernameRequired), errors.Is(err, ErrInvalidUsername), errors.Is(err, storage.ErrUsernameExists):
+		log.Info("username rejected", sl.Err(err))
+	default:
+		log.Error("failed to check username", sl.Err(err))
+	}
+}
+
+// SetAppUsernameRequired sets whether accounts registering with the app must pick a
+// username. Accounts that already exist without one keep logging in with their
+// email, but can't remove one they set. Admin only.
+func (a *Auth) SetAppUsernameRequired(ctx context.Context, actorID int64, appID int32, required bool) error {
+	const op = "Auth.SetAppUsernameRequired"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int64("actor_id", actorID),
+		slog.Int64("app_id", int64(appID)),
+		slog.Bool("required", required),
+	)
+
+	if err := a.requireAdmin(ctx, actorID); err != nil {
+		log.Warn("admin check failed", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.appSaver.SetAppUsernameRequired(ctx, appID, required); err != nil {
+		log.Error("failed to set username requirement", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("app username requirement updated")
+
+	return nil
+}
@@ -0,0 +1,28 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+
+	"sso/internal/storage"
+)
+
+// SetAppAudience sets the aud claim of the app's tokens; empty uses the app ID.
+func (s *Storage) SetAppAudience(ctx context.Context, appId int32, audience string) error {
+	const op = "storage.sqlite.SetAppAudience"
+
+	res, err := s.db.ExecContext(ctx, "UPDATE apps SET audience = ? WHERE id = ?", audience, appId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrAppNotFound)
+	}
+
+	return nil
+}
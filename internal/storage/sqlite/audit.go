@@ -0,0 +1,114 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sso/internal/domain/models"
+	"strings"
+)
+
+// AppendAuditEvent writes event to the audit log and returns its ID.
+func (s *Storage) AppendAuditEvent(ctx context.Context, event models.AuditEvent) (int64, error) {
+	const op = "storage.sqlite.AppendAuditEvent"
+
+	stmt, err := s.db.Prepare(`
+		INSERT INTO audit_log (event_type, account_id, actor_id, ip_address, payload, occurred_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer stmt.Close()
+
+	res, err := stmt.ExecContext(ctx,
+		event.Type,
+		sql.NullInt64{Int64: event.AccountID, Valid: event.AccountID != 0},
+		sql.NullInt64{Int64: event.ActorID, Valid: event.ActorID != 0},
+		sql.NullString{String: event.IPAddress, Valid: event.IPAddress != ""},
+		event.Payload,
+		event.OccurredAt,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return id, nil
+}
+
+// QueryAuditLog returns up to filter.Limit events matching filter, ordered by ID.
+func (s *Storage) QueryAuditLog(ctx context.Context, filter models.AuditFilter) ([]models.AuditEvent, error) {
+	const op = "storage.sqlite.QueryAuditLog"
+
+	conds := []string{"id > ?"}
+	args := []any{filter.AfterID}
+
+	if filter.AccountID != 0 {
+		conds = append(conds, "account_id = ?")
+		args = append(args, filter.AccountID)
+	}
+	if filter.ActorID != 0 {
+		conds = append(conds, "actor_id = ?")
+		args = append(args, filter.ActorID)
+	}
+	if filter.IPAddress != "" {
+		conds = append(conds, "ip_address = ?")
+		args = append(args, filter.IPAddress)
+	}
+	if filter.Type != "" {
+		conds = append(conds, "event_type = ?")
+		args = append(args, filter.Type)
+	}
+	if !filter.From.IsZero() {
+		conds = append(conds, "occurred_at >= ?")
+		args = append(args, filter.From)
+	}
+	if !filter.To.IsZero() {
+		conds = append(conds, "occurred_at < ?")
+		args = append(args, filter.To)
+	}
+	args = append(args, filter.Limit)
+
+	stmt, err := s.db.Prepare(`
+		SELECT id, event_type, account_id, actor_id, ip_address, payload, occurred_at
+		FROM audit_log WHERE ` + strings.Join(conds, " AND ") + `
+		ORDER BY id LIMIT ?
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var events []models.AuditEvent
+	for rows.Next() {
+		var (
+			event     models.AuditEvent
+			accountID sql.NullInt64
+			actorID   sql.NullInt64
+			ipAddress sql.NullString
+		)
+		if err := rows.Scan(&event.ID, &event.Type, &accountID, &actorID, &ipAddress, &event.Payload, &event.OccurredAt); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		event.AccountID = accountID.Int64
+		event.ActorID = actorID.Int64
+		event.IPAddress = ipAddress.String
+		events = append(events, event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return events, nil
+}
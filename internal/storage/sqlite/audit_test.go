@@ -0,0 +1,44 @@
+package sqlite_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"sso/internal/domain/models"
+	"sso/internal/storage/sqlite/sqlitetest"
+)
+
+func TestAuditLogImmutable(t *testing.T) {
+	ctx := context.Background()
+	s, db := sqlitetest.NewWithDB(t, nil)
+
+	id, err := s.AppendAuditEvent(ctx, models.AuditEvent{Type: "AccountLoggedIn", AccountID: 1, Payload: "{}", OccurredAt: time.Now()})
+	if err != nil {
+		t.Fatalf("append audit event: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		query string
+	}{
+		{name: "update", query: "UPDATE audit_log SET event_type = 'AccountDeleted' WHERE id = ?"},
+		{name: "delete", query: "DELETE FROM audit_log WHERE id = ?"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := db.ExecContext(ctx, tt.query, id); err == nil {
+				t.Errorf("%s succeeded, want it rejected", tt.name)
+			}
+		})
+	}
+
+	events, err := s.QueryAuditLog(ctx, models.AuditFilter{Limit: 10})
+	if err != nil {
+		t.Fatalf("query: %v", err)
+	}
+	if len(events) != 1 || events[0].Type != "AccountLoggedIn" {
+		t.Errorf("audit log = %+v, want the original event", events)
+	}
+}
@@ -0,0 +1,109 @@
+package sqlite_test
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	"sso/internal/domain/models"
+	"sso/internal/storage/sqlite"
+	"sso/internal/storage/sqlite/sqlitetest"
+)
+
+// saveTestSessions saves count sessions whose stored refresh token is
+// prefix-<n> and returns their SIDs. With legacy the tokens are marked as
+// plaintext, as sessions saved before refresh tokens were hashed are.
+func saveTestSessions(t *testing.T, s *sqlite.Storage, db *sql.DB, accountID int64, appID int32, prefix string, count int, legacy bool) []string {
+	t.Helper()
+
+	ctx := context.Background()
+	sids := make([]string, 0, count)
+	for i := range count {
+		sid := fmt.Sprintf("%s-sid-%d", prefix, i)
+		token := fmt.Sprintf("%s-%d", prefix, i)
+		if _, err := s.SaveSession(ctx, sid, accountID, appID, "", "", models.SessionDevice{}, token, time.Now().Add(time.Hour), time.Now(), sid, nil, false); err != nil {
+			t.Fatalf("save session: %v", err)
+		}
+		if legacy {
+			if _, err := db.ExecContext(ctx, "UPDATE sessions SET refresh_token_hashed = 0 WHERE sid = ?", sid); err != nil {
+				t.Fatalf("mark session legacy: %v", err)
+			}
+		}
+		sids = append(sids, sid)
+	}
+
+	return sids
+}
+
+func hashTestToken(token string) string {
+	return "hashed:" + token
+}
+
+func TestHashLegacyRefreshTokens(t *testing.T) {
+	tests := []struct {
+		name       string
+		legacy     int
+		hashed     int
+		limit      int
+		wantHashed int64
+		wantLeft   int64
+	}{
+		{name: "empty", limit: 10},
+		{name: "only hashed", hashed: 3, limit: 10},
+		{name: "only legacy", legacy: 3, limit: 10, wantHashed: 3},
+		{name: "mixed", legacy: 3, hashed: 3, limit: 10, wantHashed: 3},
+		{name: "mixed over limit", legacy: 5, hashed: 3, limit: 2, wantHashed: 2, wantLeft: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			s, db := sqlitetest.NewWithDB(t, nil)
+
+			appID, err := s.SaveApp(ctx, "app", "secret", "")
+			if err != nil {
+				t.Fatalf("save app: %v", err)
+			}
+			accountID, err := s.SaveAccount(ctx, "a@example.com", []byte("hash"), models.USER, models.ACTIVE, int32(appID), "", "", "")
+			if err != nil {
+				t.Fatalf("save account: %v", err)
+			}
+
+			hashedSIDs := saveTestSessions(t, s, db, accountID, int32(appID), "already-hashed", tt.hashed, false)
+			saveTestSessions(t, s, db, accountID, int32(appID), "plaintext", tt.legacy, true)
+
+			hashed, err := s.HashLegacyRefreshTokens(ctx, hashTestToken, tt.limit)
+			if err != nil {
+				t.Fatalf("hash: %v", err)
+			}
+			if hashed != tt.wantHashed {
+				t.Errorf("hashed = %d, want %d", hashed, tt.wantHashed)
+			}
+
+			left, err := s.CountLegacyRefreshTokens(ctx)
+			if err != nil {
+				t.Fatalf("count: %v", err)
+			}
+			if left != tt.wantLeft {
+				t.Errorf("left = %d, want %d", left, tt.wantLeft)
+			}
+
+			// Hashed sessions are found by the hash of their old plaintext token.
+			for i := range int(tt.wantHashed) {
+				if _, err := s.SessionByRefreshToken(ctx, hashTestToken(fmt.Sprintf("plaintext-%d", i))); err != nil {
+					t.Errorf("session of plaintext-%d: %v", i, err)
+				}
+			}
+
+			// Sessions that were hashed already are left as they were.
+			for i, sid := range hashedSIDs {
+				session, err := s.SessionByRefreshToken(ctx, fmt.Sprintf("already-hashed-%d", i))
+				if err != nil || session.SID != sid {
+					t.Errorf("already hashed session %s = %v, %v", sid, session.SID, err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sso/internal/domain/models"
+	"sso/internal/storage"
+	"time"
+)
+
+// SaveOneTimeCode stores the hash of a new single-use code, dropping any codes
+// previously issued to the account for the same purpose.
+func (s *Storage) SaveOneTimeCode(ctx context.Context, accountId int64, purpose models.CodePurpose, codeHash string, expiresAt time.Time) error {
+	const op = "storage.sqlite.SaveOneTimeCode"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, "DELETE FROM one_time_codes WHERE account_id = ? AND purpose = ?", accountId, purpose)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO one_time_codes (account_id, purpose, code_hash, expires_at)
+		VALUES (?, ?, ?, ?)
+	`, accountId, purpose, codeHash, expiresAt)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// CheckOneTimeCode reports whether the code is live without consuming it: unknown
+// and expired codes return storage.ErrCodeNotFound, used ones storage.ErrCodeUsed.
+func (s *Storage) CheckOneTimeCode(ctx context.Context, accountId int64, purpose models.CodePurpose, codeHash string, now time.Time) error {
+	const op = "storage.sqlite.CheckOneTimeCode"
+
+	var usedAt sql.NullTime
+	err := s.db.QueryRowContext(ctx, `
+		SELECT used_at FROM one_time_codes
+		WHERE account_id = ? AND purpose = ? AND code_hash = ? AND expires_at > ?
+	`, accountId, purpose, codeHash, now).Scan(&usedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%s: %w", op, storage.ErrCodeNotFound)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if usedAt.Valid {
+		return fmt.Errorf("%s: %w", op, storage.ErrCodeUsed)
+	}
+
+	return nil
+}
+
+// ConsumeOneTimeCode marks the code used in a single conditional update, so of two
+// concurrent submissions only one succeeds. The other gets storage.ErrCodeUsed.
+func (s *Storage) ConsumeOneTimeCode(ctx context.Context, accountId int64, purpose models.CodePurpose, codeHash string, now time.Time) error {
+	const op = "storage.sqlite.ConsumeOneTimeCode"
+
+	res, err := s.db.ExecContext(ctx, `
+		UPDATE one_time_codes SET used_at = ?
+		WHERE account_id = ? AND purpose = ? AND code_hash = ? AND used_at IS NULL AND expires_at > ?
+	`, now, accountId, purpose, codeHash, now)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 1 {
+		return nil
+	}
+
+	// Nothing was consumed: tell a replayed code apart from an unknown or expired one.
+	var usedAt sql.NullTime
+	err = s.db.QueryRowContext(ctx, `
+		SELECT used_at FROM one_time_codes WHERE account_id = ? AND purpose = ? AND code_hash = ?
+	`, accountId, purpose, codeHash).Scan(&usedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%s: %w", op, storage.ErrCodeNotFound)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if usedAt.Valid {
+		return fmt.Errorf("%s: %w", op, storage.ErrCodeUsed)
+	}
+
+	return fmt.Errorf("%s: %w", op, storage.ErrCodeNotFound)
+}
+
+// DeleteExpiredOneTimeCodes deletes up to limit codes, used or not, that expired
+// before the given time and returns how many were deleted.
+func (s *Storage) DeleteExpiredOneTimeCodes(ctx context.Context, before time.Time, limit int) (int64, error) {
+	const op = "storage.sqlite.DeleteExpiredOneTimeCodes"
+
+	res, err := s.db.ExecContext(ctx, `
+		DELETE FROM one_time_codes WHERE rowid IN (
+			SELECT rowid FROM one_time_codes WHERE expires_at < ? LIMIT ?
+		)
+	`, before, limit)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	deleted, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return deleted, nil
+}
@@ -0,0 +1,95 @@
+package sqlite_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"sso/internal/domain/models"
+	"sso/internal/storage"
+	"sso/internal/storage/sqlite/sqlitetest"
+)
+
+func TestConsumeOneTimeCode(t *testing.T) {
+	const hash = "code-hash"
+
+	tests := []struct {
+		name      string
+		expiresIn time.Duration
+		// consumed marks the code used before the consumption under test.
+		consumed bool
+		purpose  models.CodePurpose
+		hash     string
+		wantErr  error
+	}{
+		{name: "live code", expiresIn: time.Hour, purpose: models.CodePurposeOTP, hash: hash},
+		{name: "replayed code", expiresIn: time.Hour, consumed: true, purpose: models.CodePurposeOTP, hash: hash, wantErr: storage.ErrCodeUsed},
+		{name: "expired code", expiresIn: -time.Minute, purpose: models.CodePurposeOTP, hash: hash, wantErr: storage.ErrCodeNotFound},
+		{name: "wrong code", expiresIn: time.Hour, purpose: models.CodePurposeOTP, hash: "other-hash", wantErr: storage.ErrCodeNotFound},
+		{name: "other purpose", expiresIn: time.Hour, purpose: models.CodePurposeMagicLink, hash: hash, wantErr: storage.ErrCodeNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			s := sqlitetest.New(t, nil)
+
+			appID, err := s.SaveApp(ctx, "app", "secret", "")
+			if err != nil {
+				t.Fatalf("save app: %v", err)
+			}
+			accountID, err := s.SaveAccount(ctx, "a@example.com", []byte("hash"), models.USER, models.ACTIVE, int32(appID), "", "", "")
+			if err != nil {
+				t.Fatalf("save account: %v", err)
+			}
+
+			now := time.Now()
+			if err := s.SaveOneTimeCode(ctx, accountID, models.CodePurposeOTP, hash, now.Add(tt.expiresIn)); err != nil {
+				t.Fatalf("save code: %v", err)
+			}
+			if tt.consumed {
+				if err := s.ConsumeOneTimeCode(ctx, accountID, models.CodePurposeOTP, hash, now); err != nil {
+					t.Fatalf("first consumption: %v", err)
+				}
+			}
+
+			// Checking agrees with consuming and leaves the code as it was.
+			if err := s.CheckOneTimeCode(ctx, accountID, tt.purpose, tt.hash, now); !errors.Is(err, tt.wantErr) {
+				t.Errorf("check: got %v, want %v", err, tt.wantErr)
+			}
+			if err := s.ConsumeOneTimeCode(ctx, accountID, tt.purpose, tt.hash, now); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("consume: got %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSaveOneTimeCodeReplacesEarlierCode(t *testing.T) {
+	ctx := context.Background()
+	s := sqlitetest.New(t, nil)
+
+	appID, err := s.SaveApp(ctx, "app", "secret", "")
+	if err != nil {
+		t.Fatalf("save app: %v", err)
+	}
+	accountID, err := s.SaveAccount(ctx, "a@example.com", []byte("hash"), models.USER, models.ACTIVE, int32(appID), "", "", "")
+	if err != nil {
+		t.Fatalf("save account: %v", err)
+	}
+
+	expiresAt := time.Now().Add(time.Hour)
+	if err := s.SaveOneTimeCode(ctx, accountID, models.CodePurposeOTP, "first", expiresAt); err != nil {
+		t.Fatalf("save first code: %v", err)
+	}
+	if err := s.SaveOneTimeCode(ctx, accountID, models.CodePurposeOTP, "second", expiresAt); err != nil {
+		t.Fatalf("save second code: %v", err)
+	}
+
+	if err := s.ConsumeOneTimeCode(ctx, accountID, models.CodePurposeOTP, "first", time.Now()); !errors.Is(err, storage.ErrCodeNotFound) {
+		t.Errorf("consume replaced code: got %v, want %v", err, storage.ErrCodeNotFound)
+	}
+	if err := s.ConsumeOneTimeCode(ctx, accountID, models.CodePurposeOTP, "second", time.Now()); err != nil {
+		t.Errorf("consume current code: %v", err)
+	}
+}
@@ -0,0 +1,124 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+
+	"sso/internal/domain/models"
+	"sso/internal/storage"
+)
+
+// SoftDeleteAccount marks the account DELETED as of at and replaces its email with
+// anonymizedEmail, keeping the original email and status for RestoreDeletedAccount.
+// Its token version is bumped so no token issued before stays valid. Accounts that
+// are already deleted return storage.ErrAccountNotFound.
+func (s *Storage) SoftDeleteAccount(ctx context.Context, accountId int64, anonymizedEmail string, at time.Time) error {
+	const op = "storage.sqlite.SoftDeleteAccount"
+
+	res, err := s.db.ExecContext(ctx, `
+		UPDATE accounts
+		SET deleted_email = email, deleted_status = status, deleted_at = ?,
+			email = ?, status = ?, token_version = token_version + 1, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND deleted_at IS NULL
+	`, at.UTC(), anonymizedEmail, models.DELETED, accountId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrAccountNotFound)
+	}
+
+	return nil
+}
+
+// DeletedAccount returns the original email of a soft-deleted account and when it
+// was deleted, or storage.ErrAccountNotFound if the account isn't soft-deleted.
+func (s *Storage) DeletedAccount(ctx context.Context, accountId int64) (string, time.Time, error) {
+	const op = "storage.sqlite.DeletedAccount"
+
+	var (
+		email     string
+		deletedAt time.Time
+	)
+	err := s.db.QueryRowContext(ctx, `
+		SELECT deleted_email, deleted_at FROM accounts WHERE id = ? AND deleted_at IS NOT NULL
+	`, accountId).Scan(&email, &deletedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", time.Time{}, fmt.Errorf("%s: %w", op, storage.ErrAccountNotFound)
+		}
+		return "", time.Time{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return email, deletedAt, nil
+}
+
+// RestoreDeletedAccount undoes SoftDeleteAccount, bringing back the account's email
+// and status. It returns storage.ErrAccountExists if another account has taken the
+// email in the meantime and storage.ErrAccountNotFound if the account isn't
+// soft-deleted.
+func (s *Storage) RestoreDeletedAccount(ctx context.Context, accountId int64) error {
+	const op = "storage.sqlite.RestoreDeletedAccount"
+
+	res, err := s.db.ExecContext(ctx, `
+		UPDATE accounts
+		SET email = deleted_email, status = deleted_status,
+			deleted_email = NULL, deleted_status = NULL, deleted_at = NULL, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND deleted_at IS NOT NULL
+	`, accountId)
+	if err != nil {
+		var sqliteErr sqlite3.Error
+		if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
+			return fmt.Errorf("%s: %w", op, storage.ErrAccountExists)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrAccountNotFound)
+	}
+
+	return nil
+}
+
+// DeletedAccounts returns the IDs of up to limit accounts soft-deleted before the
+// given time.
+func (s *Storage) DeletedAccounts(ctx context.Context, before time.Time, limit int) ([]int64, error) {
+	const op = "storage.sqlite.DeletedAccounts"
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id FROM accounts WHERE deleted_at IS NOT NULL AND deleted_at < ? ORDER BY id LIMIT ?
+	`, before.UTC(), limit)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return ids, nil
+}
@@ -0,0 +1,165 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"sso/internal/storage"
+)
+
+// DeviceNames returns the names the account gave its devices, by device ID.
+func (s *Storage) DeviceNames(ctx context.Context, accountId int64) (map[string]string, error) {
+	const op = "storage.sqlite.DeviceNames"
+
+	rows, err := s.db.QueryContext(ctx, "SELECT device_id, name FROM device_names WHERE account_id = ?", accountId)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	names := make(map[string]string)
+	for rows.Next() {
+		var deviceID, name string
+		if err := rows.Scan(&deviceID, &name); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		names[deviceID] = name
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return names, nil
+}
+
+// SetDeviceName names the account's device, or removes its name if name is empty.
+func (s *Storage) SetDeviceName(ctx context.Context, accountId int64, deviceID string, name string) error {
+	const op = "storage.sqlite.SetDeviceName"
+
+	var err error
+	if name == "" {
+		_, err = s.db.ExecContext(ctx, "DELETE FROM device_names WHERE account_id = ? AND device_id = ?", accountId, deviceID)
+	} else {
+		_, err = s.db.ExecContext(ctx, `
+			INSERT INTO device_names (account_id, device_id, name) VALUES (?, ?, ?)
+			ON CONFLICT (account_id, device_id) DO UPDATE SET name = excluded.name, updated_at = CURRENT_TIMESTAMP
+		`, accountId, deviceID, name)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// RecordDeviceLogin remembers that the account signed in from deviceID at ipAddress.
+// unseen is true if it never had before but has signed in from some other device or
+// IP, so an account's very first login doesn't count as a new device.
+func (s *Storage) RecordDeviceLogin(ctx context.Context, accountId int64, deviceID string, ipAddress string, at time.Time) (bool, error) {
+	const op = "storage.sqlite.RecordDeviceLogin"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	var known bool
+	err = tx.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM known_devices WHERE account_id = ?)", accountId).Scan(&known)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	res, err := tx.ExecContext(ctx, `
+		INSERT INTO known_devices (account_id, device_id, ip_address, first_seen_at, last_seen_at) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (account_id, device_id, ip_address) DO NOTHING
+	`, accountId, deviceID, ipAddress, at, at)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	inserted, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if inserted == 0 {
+		_, err = tx.ExecContext(ctx, `
+			UPDATE known_devices SET last_seen_at = ? WHERE account_id = ? AND device_id = ? AND ip_address = ?
+		`, at, accountId, deviceID, ipAddress)
+		if err != nil {
+			return false, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return known && inserted == 1, nil
+}
+
+// SaveDeviceRevokeToken stores the hash of a token that signs the account out of
+// deviceID until expiresAt.
+func (s *Storage) SaveDeviceRevokeToken(ctx context.Context, tokenHash string, accountId int64, deviceID string, expiresAt time.Time) error {
+	const op = "storage.sqlite.SaveDeviceRevokeToken"
+
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO device_revoke_tokens (token_hash, account_id, device_id, expires_at) VALUES (?, ?, ?, ?)
+	`, tokenHash, accountId, deviceID, expiresAt)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// ConsumeDeviceRevokeToken marks an unexpired token used and returns the account and
+// device it is for, in one statement so a token works only once. Unknown, expired
+// and used tokens all return storage.ErrCodeNotFound.
+func (s *Storage) ConsumeDeviceRevokeToken(ctx context.Context, tokenHash string, now time.Time) (int64, string, error) {
+	const op = "storage.sqlite.ConsumeDeviceRevokeToken"
+
+	var (
+		accountID int64
+		deviceID  string
+	)
+	err := s.db.QueryRowContext(ctx, `
+		UPDATE device_revoke_tokens SET used_at = ?
+		WHERE token_hash = ? AND used_at IS NULL AND expires_at > ?
+		RETURNING account_id, device_id
+	`, now, tokenHash, now).Scan(&accountID, &deviceID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, "", fmt.Errorf("%s: %w", op, storage.ErrCodeNotFound)
+		}
+		return 0, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return accountID, deviceID, nil
+}
+
+// DeleteExpiredDeviceRevokeTokens deletes up to limit tokens, used or not, that
+// expired before the given time and returns how many were deleted.
+func (s *Storage) DeleteExpiredDeviceRevokeTokens(ctx context.Context, before time.Time, limit int) (int64, error) {
+	const op = "storage.sqlite.DeleteExpiredDeviceRevokeTokens"
+
+	res, err := s.db.ExecContext(ctx, `
+		DELETE FROM device_revoke_tokens WHERE token_hash IN (
+			SELECT token_hash FROM device_revoke_tokens WHERE expires_at < ? LIMIT ?
+		)
+	`, before, limit)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	deleted, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return deleted, nil
+}
@@ -0,0 +1,89 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/mattn/go-sqlite3"
+
+	"sso/internal/storage"
+)
+
+// SavePendingEmail records the address the account asked to change its email to,
+// replacing an earlier pending one.
+func (s *Storage) SavePendingEmail(ctx context.Context, accountId int64, email string) error {
+	const op = "storage.sqlite.SavePendingEmail"
+
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO email_changes (account_id, new_email, created_at)
+		VALUES (?, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT (account_id) DO UPDATE SET
+			new_email = excluded.new_email,
+			created_at = excluded.created_at
+	`, accountId, email)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// PendingEmail returns the address the account asked to change its email to, or
+// storage.ErrEmailChangeNotFound if there is none.
+func (s *Storage) PendingEmail(ctx context.Context, accountId int64) (string, error) {
+	const op = "storage.sqlite.PendingEmail"
+
+	var email string
+	err := s.db.QueryRowContext(ctx, "SELECT new_email FROM email_changes WHERE account_id = ?", accountId).Scan(&email)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", fmt.Errorf("%s: %w", op, storage.ErrEmailChangeNotFound)
+		}
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return email, nil
+}
+
+// ChangeEmail sets the account's email and drops its pending email change. It
+// returns storage.ErrAccountExists if another account of the same app has the email.
+func (s *Storage) ChangeEmail(ctx context.Context, accountId int64, email string) error {
+	const op = "storage.sqlite.ChangeEmail"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `
+		UPDATE accounts SET email = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?
+	`, email, accountId)
+	if err != nil {
+		var sqliteErr sqlite3.Error
+		if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
+			return fmt.Errorf("%s: %w", op, storage.ErrAccountExists)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrAccountNotFound)
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM email_changes WHERE account_id = ?", accountId); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,68 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+
+	"sso/internal/domain/models"
+)
+
+// SessionHistory returns all sessions of the account still stored, revoked and
+// expired ones included, oldest first.
+func (s *Storage) SessionHistory(ctx context.Context, accountId int64) ([]models.Session, error) {
+	const op = "storage.sqlite.SessionHistory"
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+sessionColumns+`
+		FROM sessions WHERE account_id = ?
+		ORDER BY created_at, id
+	`, accountId)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var sessions []models.Session
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		sessions = append(sessions, session)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return sessions, nil
+}
+
+// DeviceLogins returns the devices and IPs the account has signed in from, as
+// recorded by RecordDeviceLogin, in the order they were first seen.
+func (s *Storage) DeviceLogins(ctx context.Context, accountId int64) ([]models.DeviceLogin, error) {
+	const op = "storage.sqlite.DeviceLogins"
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT device_id, ip_address, first_seen_at, last_seen_at
+		FROM known_devices WHERE account_id = ?
+		ORDER BY first_seen_at
+	`, accountId)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var logins []models.DeviceLogin
+	for rows.Next() {
+		var login models.DeviceLogin
+		if err := rows.Scan(&login.DeviceID, &login.IPAddress, &login.FirstSeenAt, &login.LastSeenAt); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		logins = append(logins, login)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return logins, nil
+}
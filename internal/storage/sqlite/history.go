@@ -0,0 +1,67 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+)
+
+// RetirePassword adds passHash to the account's password history and drops all but
+// the keep most recent entries.
+func (s *Storage) RetirePassword(ctx context.Context, accountId int64, passHash []byte, keep int) error {
+	const op = "storage.sqlite.RetirePassword"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, "INSERT INTO password_history (account_id, pass_hash) VALUES (?, ?)", accountId, passHash)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		DELETE FROM password_history
+		WHERE account_id = ? AND id NOT IN (
+			SELECT id FROM password_history WHERE account_id = ? ORDER BY id DESC LIMIT ?
+		)
+	`, accountId, accountId, keep)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// PasswordHistory returns up to limit of the account's retired password hashes,
+// most recent first.
+func (s *Storage) PasswordHistory(ctx context.Context, accountId int64, limit int) ([][]byte, error) {
+	const op = "storage.sqlite.PasswordHistory"
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT pass_hash FROM password_history WHERE account_id = ? ORDER BY id DESC LIMIT ?
+	`, accountId, limit)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var hashes [][]byte
+	for rows.Next() {
+		var hash []byte
+		if err := rows.Scan(&hash); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		hashes = append(hashes, hash)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return hashes, nil
+}
@@ -0,0 +1,176 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"sso/internal/domain/models"
+	"sso/internal/storage"
+)
+
+const inviteColumns = `id, email, role, app_id, created_by, expires_at, created_at, accepted_at, account_id, revoked_at`
+
+// SaveInvite stores an invite by the hash of its token and returns its ID.
+func (s *Storage) SaveInvite(ctx context.Context, tokenHash string, email string, role models.AccountRole, appId int32, createdBy int64, expiresAt time.Time) (int64, error) {
+	const op = "storage.sqlite.SaveInvite"
+
+	res, err := s.db.ExecContext(ctx, `
+		INSERT INTO invites (token_hash, email, role, app_id, created_by, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, tokenHash, email, role, appId, sql.NullInt64{Int64: createdBy, Valid: createdBy != 0}, expiresAt)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return id, nil
+}
+
+// InviteByToken returns the invite with the token hash, pending or not, or
+// storage.ErrInviteNotFound.
+func (s *Storage) InviteByToken(ctx context.Context, tokenHash string) (models.Invite, error) {
+	const op = "storage.sqlite.InviteByToken"
+
+	invite, err := scanInvite(s.db.QueryRowContext(ctx, `
+		SELECT `+inviteColumns+` FROM invites WHERE token_hash = ?
+	`, tokenHash))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Invite{}, fmt.Errorf("%s: %w", op, storage.ErrInviteNotFound)
+		}
+		return models.Invite{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return invite, nil
+}
+
+// PendingInvites returns the invites of the app, or of all apps for appId 0, that
+// are neither accepted nor revoked and don't expire before now, oldest first.
+func (s *Storage) PendingInvites(ctx context.Context, appId int32, now time.Time) ([]models.Invite, error) {
+	const op = "storage.sqlite.PendingInvites"
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+inviteColumns+` FROM invites
+		WHERE (? = 0 OR app_id = ?) AND accepted_at IS NULL AND revoked_at IS NULL AND expires_at > ?
+		ORDER BY id
+	`, appId, appId, now)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var invites []models.Invite
+	for rows.Next() {
+		invite, err := scanInvite(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		invites = append(invites, invite)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return invites, nil
+}
+
+// ClaimInvite marks a pending invite accepted by the account. Of concurrent claims
+// at most one succeeds; invites that aren't pending at now return
+// storage.ErrInviteNotFound.
+func (s *Storage) ClaimInvite(ctx context.Context, id int64, accountId int64, now time.Time) error {
+	const op = "storage.sqlite.ClaimInvite"
+
+	res, err := s.db.ExecContext(ctx, `
+		UPDATE invites SET accepted_at = ?, account_id = ?
+		WHERE id = ? AND accepted_at IS NULL AND revoked_at IS NULL AND expires_at > ?
+	`, now, accountId, id, now)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return requireInviteAffected(op, res)
+}
+
+// RevokeInvite revokes a pending invite, returning storage.ErrInviteNotFound if
+// there is no invite with the ID that is pending at now.
+func (s *Storage) RevokeInvite(ctx context.Context, id int64, now time.Time) error {
+	const op = "storage.sqlite.RevokeInvite"
+
+	res, err := s.db.ExecContext(ctx, `
+		UPDATE invites SET revoked_at = ?
+		WHERE id = ? AND accepted_at IS NULL AND revoked_at IS NULL AND expires_at > ?
+	`, now, id, now)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return requireInviteAffected(op, res)
+}
+
+// SetAppSelfRegistration sets whether accounts may register with the app on their
+// own.
+func (s *Storage) SetAppSelfRegistration(ctx context.Context, appId int32, allowed bool) error {
+	const op = "storage.sqlite.SetAppSelfRegistration"
+
+	res, err := s.db.ExecContext(ctx, "UPDATE apps SET self_registration = ? WHERE id = ?", allowed, appId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrAppNotFound)
+	}
+
+	return nil
+}
+
+func requireInviteAffected(op string, res sql.Result) error {
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrInviteNotFound)
+	}
+
+	return nil
+}
+
+func scanInvite(row rowScanner) (models.Invite, error) {
+	var (
+		invite     models.Invite
+		createdBy  sql.NullInt64
+		acceptedAt sql.NullTime
+		accountId  sql.NullInt64
+		revokedAt  sql.NullTime
+	)
+	err := row.Scan(
+		&invite.ID, &invite.Email, &invite.Role, &invite.AppID, &createdBy,
+		&invite.ExpiresAt, &invite.CreatedAt, &acceptedAt, &accountId, &revokedAt,
+	)
+	if err != nil {
+		return models.Invite{}, err
+	}
+
+	invite.CreatedBy = createdBy.Int64
+	invite.AccountID = accountId.Int64
+	if acceptedAt.Valid {
+		invite.AcceptedAt = &acceptedAt.Time
+	}
+	if revokedAt.Valid {
+		invite.RevokedAt = &revokedAt.Time
+	}
+
+	return invite, nil
+}
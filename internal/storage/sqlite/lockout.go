@@ -0,0 +1,85 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sso/internal/domain/models"
+	"time"
+)
+
+// LockoutState returns the failed-login record of key and whether there is one.
+func (s *Storage) LockoutState(ctx context.Context, key string) (models.LockoutState, bool, error) {
+	const op = "storage.sqlite.LockoutState"
+
+	stmt, err := s.db.Prepare("SELECT failures, last_failure, locked_until, reset_at FROM login_lockouts WHERE key = ?")
+	if err != nil {
+		return models.LockoutState{}, false, fmt.Errorf("%s: %w", op, err)
+	}
+	defer stmt.Close()
+
+	var (
+		state       models.LockoutState
+		lockedUntil sql.NullTime
+		resetAt     sql.NullTime
+	)
+	err = stmt.QueryRowContext(ctx, key).Scan(&state.Failures, &state.LastFailure, &lockedUntil, &resetAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.LockoutState{}, false, nil
+		}
+		return models.LockoutState{}, false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	state.LockedUntil = lockedUntil.Time
+	state.ResetAt = resetAt.Time
+
+	return state, true, nil
+}
+
+// SaveLockoutState stores the failed-login record of key, replacing any previous one.
+func (s *Storage) SaveLockoutState(ctx context.Context, key string, state models.LockoutState) error {
+	const op = "storage.sqlite.SaveLockoutState"
+
+	stmt, err := s.db.Prepare(`
+		INSERT INTO login_lockouts (key, failures, last_failure, locked_until, reset_at) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (key) DO UPDATE SET
+			failures = excluded.failures, last_failure = excluded.last_failure,
+			locked_until = excluded.locked_until, reset_at = excluded.reset_at
+	`)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer stmt.Close()
+
+	_, err = stmt.ExecContext(ctx, key, state.Failures, state.LastFailure, nullableTime(state.LockedUntil), nullableTime(state.ResetAt))
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// DeleteLockoutState forgets the failed-login record of key.
+func (s *Storage) DeleteLockoutState(ctx context.Context, key string) error {
+	const op = "storage.sqlite.DeleteLockoutState"
+
+	stmt, err := s.db.Prepare("DELETE FROM login_lockouts WHERE key = ?")
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer stmt.Close()
+
+	_, err = stmt.ExecContext(ctx, key)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// nullableTime stores zero times as NULL.
+func nullableTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
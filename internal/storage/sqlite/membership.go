@@ -0,0 +1,89 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sso/internal/domain/models"
+	"sso/internal/storage"
+	"strings"
+)
+
+// SaveMembership grants the account a role in the app, replacing any previous role.
+func (s *Storage) SaveMembership(ctx context.Context, accountId int64, appId int32, role models.AccountRole) error {
+	const op = "storage.sqlite.SaveMembership"
+
+	stmt, err := s.db.Prepare(`
+		INSERT INTO app_memberships (account_id, app_id, role) VALUES (?, ?, ?)
+		ON CONFLICT (account_id, app_id) DO UPDATE SET role = excluded.role, updated_at = CURRENT_TIMESTAMP
+	`)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer stmt.Close()
+
+	_, err = stmt.ExecContext(ctx, accountId, appId, role)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) Membership(ctx context.Context, accountId int64, appId int32) (models.AppMembership, error) {
+	const op = "storage.sqlite.Membership"
+
+	stmt, err := s.db.Prepare(`
+		SELECT account_id, app_id, role, scopes, created_at, updated_at FROM app_memberships
+		WHERE account_id = ? AND app_id = ?
+	`)
+	if err != nil {
+		return models.AppMembership{}, fmt.Errorf("%s: %w", op, err)
+	}
+	defer stmt.Close()
+
+	var (
+		membership models.AppMembership
+		scopes     string
+	)
+	err = stmt.QueryRowContext(ctx, accountId, appId).Scan(&membership.AccountID, &membership.AppID, &membership.Role, &scopes, &membership.CreatedAt, &membership.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.AppMembership{}, fmt.Errorf("%s: %w", op, storage.ErrMembershipNotFound)
+		}
+		return models.AppMembership{}, fmt.Errorf("%s: %w", op, err)
+	}
+	membership.Scopes = strings.Fields(scopes)
+
+	return membership, nil
+}
+
+// SetMembershipScopes replaces the scopes the account may be granted in the app.
+func (s *Storage) SetMembershipScopes(ctx context.Context, accountId int64, appId int32, scopes []string) error {
+	const op = "storage.sqlite.SetMembershipScopes"
+
+	stmt, err := s.db.Prepare(`
+		UPDATE app_memberships SET scopes = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE account_id = ? AND app_id = ?
+	`)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer stmt.Close()
+
+	res, err := stmt.ExecContext(ctx, strings.Join(scopes, " "), accountId, appId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrMembershipNotFound)
+	}
+
+	return nil
+}
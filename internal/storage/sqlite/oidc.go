@@ -0,0 +1,28 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+
+	"sso/internal/storage"
+)
+
+// SetAppOIDCEnabled sets whether logins to the app get an ID token.
+func (s *Storage) SetAppOIDCEnabled(ctx context.Context, appId int32, enabled bool) error {
+	const op = "storage.sqlite.SetAppOIDCEnabled"
+
+	res, err := s.db.ExecContext(ctx, "UPDATE apps SET oidc_enabled = ? WHERE id = ?", enabled, appId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrAppNotFound)
+	}
+
+	return nil
+}
@@ -0,0 +1,160 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/mattn/go-sqlite3"
+	"sso/internal/domain/models"
+	"sso/internal/storage"
+	"strings"
+	"time"
+)
+
+// SavePasskey registers a WebAuthn credential for the account. A credential ID that
+// is already registered, to any account, returns storage.ErrPasskeyExists.
+func (s *Storage) SavePasskey(ctx context.Context, passkey models.Passkey) (int64, error) {
+	const op = "storage.sqlite.SavePasskey"
+
+	stmt, err := s.db.Prepare(`
+		INSERT INTO passkeys (account_id, credential_id, public_key, sign_count, name)
+		VALUES (?, ?, ?, ?, ?)
+	`)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer stmt.Close()
+
+	res, err := stmt.ExecContext(ctx, passkey.AccountID, passkey.CredentialID, passkey.PublicKey, passkey.SignCount, passkey.Name)
+	if err != nil {
+		var sqliteErr sqlite3.Error
+		if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
+			return 0, fmt.Errorf("%s: %w", op, storage.ErrPasskeyExists)
+		}
+
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return id, nil
+}
+
+// Passkey looks up a credential by its WebAuthn credential ID.
+func (s *Storage) Passkey(ctx context.Context, credentialID []byte) (models.Passkey, error) {
+	const op = "storage.sqlite.Passkey"
+
+	stmt, err := s.db.Prepare(`
+		SELECT id, account_id, credential_id, public_key, sign_count, name, created_at, last_used_at
+		FROM passkeys WHERE credential_id = ?
+	`)
+	if err != nil {
+		return models.Passkey{}, fmt.Errorf("%s: %w", op, err)
+	}
+	defer stmt.Close()
+
+	var (
+		passkey    models.Passkey
+		lastUsedAt sql.NullTime
+	)
+	err = stmt.QueryRowContext(ctx, credentialID).Scan(
+		&passkey.ID, &passkey.AccountID, &passkey.CredentialID, &passkey.PublicKey,
+		&passkey.SignCount, &passkey.Name, &passkey.CreatedAt, &lastUsedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Passkey{}, fmt.Errorf("%s: %w", op, storage.ErrPasskeyNotFound)
+		}
+		return models.Passkey{}, fmt.Errorf("%s: %w", op, err)
+	}
+	passkey.LastUsedAt = nullTime(lastUsedAt)
+
+	return passkey, nil
+}
+
+// PasskeyCredentialIDs returns the credential IDs registered for the account.
+func (s *Storage) PasskeyCredentialIDs(ctx context.Context, accountId int64) ([][]byte, error) {
+	const op = "storage.sqlite.PasskeyCredentialIDs"
+
+	rows, err := s.db.QueryContext(ctx, "SELECT credential_id FROM passkeys WHERE account_id = ? ORDER BY created_at", accountId)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var ids [][]byte
+	for rows.Next() {
+		var id []byte
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return ids, nil
+}
+
+// UpdatePasskeySignCount records a successful assertion. The counter only moves
+// forward: if another assertion already stored signCount or a higher value,
+// storage.ErrPasskeyCounterStale is returned. Counters stuck at zero are left alone.
+func (s *Storage) UpdatePasskeySignCount(ctx context.Context, id int64, signCount uint32, usedAt time.Time) error {
+	const op = "storage.sqlite.UpdatePasskeySignCount"
+
+	stmt, err := s.db.Prepare(`
+		UPDATE passkeys SET sign_count = ?, last_used_at = ?
+		WHERE id = ? AND (sign_count < ? OR (sign_count = 0 AND ? = 0))
+	`)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer stmt.Close()
+
+	res, err := stmt.ExecContext(ctx, signCount, usedAt, id, signCount, signCount)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrPasskeyCounterStale)
+	}
+
+	return nil
+}
+
+// SetAppRelyingParty sets the WebAuthn relying party ID and allowed origins of the
+// app. An empty rpID turns passkeys off for it.
+func (s *Storage) SetAppRelyingParty(ctx context.Context, appId int32, rpID string, origins []string) error {
+	const op = "storage.sqlite.SetAppRelyingParty"
+
+	stmt, err := s.db.Prepare("UPDATE apps SET rp_id = ?, rp_origins = ? WHERE id = ?")
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer stmt.Close()
+
+	res, err := stmt.ExecContext(ctx, rpID, strings.Join(origins, " "), appId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrAppNotFound)
+	}
+
+	return nil
+}
@@ -0,0 +1,39 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/mattn/go-sqlite3"
+
+	"sso/internal/storage"
+)
+
+// SetPhone sets the account's phone number, or removes it if phone is empty. It
+// returns storage.ErrPhoneExists if another account of the same app has it.
+func (s *Storage) SetPhone(ctx context.Context, accountId int64, phone string) error {
+	const op = "storage.sqlite.SetPhone"
+
+	res, err := s.db.ExecContext(ctx, `
+		UPDATE accounts SET phone = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?
+	`, sql.NullString{String: phone, Valid: phone != ""}, accountId)
+	if err != nil {
+		var sqliteErr sqlite3.Error
+		if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
+			return fmt.Errorf("%s: %w", op, storage.ErrPhoneExists)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrAccountNotFound)
+	}
+
+	return nil
+}
@@ -0,0 +1,68 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"sso/internal/domain/models"
+	"sso/internal/storage"
+)
+
+// Profile returns the account's profile, or storage.ErrProfileNotFound if it has
+// never been saved.
+func (s *Storage) Profile(ctx context.Context, accountId int64) (models.Profile, error) {
+	const op = "storage.sqlite.Profile"
+
+	var (
+		profile  models.Profile
+		metadata sql.NullString
+	)
+	err := s.db.QueryRowContext(ctx, `
+		SELECT account_id, display_name, given_name, family_name, avatar_url, locale, timezone, metadata, updated_at
+		FROM account_profiles WHERE account_id = ?
+	`, accountId).Scan(
+		&profile.AccountID, &profile.DisplayName, &profile.GivenName, &profile.FamilyName,
+		&profile.AvatarURL, &profile.Locale, &profile.Timezone, &metadata, &profile.UpdatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Profile{}, fmt.Errorf("%s: %w", op, storage.ErrProfileNotFound)
+		}
+		return models.Profile{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if metadata.Valid {
+		profile.Metadata = []byte(metadata.String)
+	}
+
+	return profile, nil
+}
+
+// SaveProfile creates or replaces the profile of profile.AccountID.
+func (s *Storage) SaveProfile(ctx context.Context, profile models.Profile) error {
+	const op = "storage.sqlite.SaveProfile"
+
+	metadata := sql.NullString{String: string(profile.Metadata), Valid: profile.Metadata != nil}
+
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO account_profiles (account_id, display_name, given_name, family_name, avatar_url, locale, timezone, metadata, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT (account_id) DO UPDATE SET
+			display_name = excluded.display_name,
+			given_name = excluded.given_name,
+			family_name = excluded.family_name,
+			avatar_url = excluded.avatar_url,
+			locale = excluded.locale,
+			timezone = excluded.timezone,
+			metadata = excluded.metadata,
+			updated_at = excluded.updated_at
+	`, profile.AccountID, profile.DisplayName, profile.GivenName, profile.FamilyName,
+		profile.AvatarURL, profile.Locale, profile.Timezone, metadata)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
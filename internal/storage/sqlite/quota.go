@@ -0,0 +1,59 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// SessionQuota returns the account's session limit and whether one is set.
+func (s *Storage) SessionQuota(ctx context.Context, accountId int64) (int, bool, error) {
+	const op = "storage.sqlite.SessionQuota"
+
+	stmt, err := s.db.Prepare("SELECT max_sessions FROM session_quotas WHERE account_id = ?")
+	if err != nil {
+		return 0, false, fmt.Errorf("%s: %w", op, err)
+	}
+	defer stmt.Close()
+
+	var maxSessions int
+	err = stmt.QueryRowContext(ctx, accountId).Scan(&maxSessions)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, false, nil
+		}
+		return 0, false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return maxSessions, true, nil
+}
+
+// SetSessionQuota sets the account's session limit, replacing any previous one.
+// A negative maxSessions removes it, so the default applies again.
+func (s *Storage) SetSessionQuota(ctx context.Context, accountId int64, maxSessions int) error {
+	const op = "storage.sqlite.SetSessionQuota"
+
+	query := `
+		INSERT INTO session_quotas (account_id, max_sessions) VALUES (?, ?)
+		ON CONFLICT (account_id) DO UPDATE SET max_sessions = excluded.max_sessions, updated_at = CURRENT_TIMESTAMP
+	`
+	args := []any{accountId, maxSessions}
+	if maxSessions < 0 {
+		query = "DELETE FROM session_quotas WHERE account_id = ?"
+		args = args[:1]
+	}
+
+	stmt, err := s.db.Prepare(query)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer stmt.Close()
+
+	_, err = stmt.ExecContext(ctx, args...)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
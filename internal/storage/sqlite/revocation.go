@@ -0,0 +1,119 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"sso/internal/domain/models"
+)
+
+// SetSessionAccessToken records the jti and expiry of the access token last issued
+// for the session with the public session ID sid.
+func (s *Storage) SetSessionAccessToken(ctx context.Context, sid string, jti string, expiresAt time.Time) error {
+	const op = "storage.sqlite.SetSessionAccessToken"
+
+	_, err := s.db.ExecContext(ctx, "UPDATE sessions SET access_jti = ?, access_expires_at = ? WHERE sid = ?", jti, expiresAt.UTC(), sid)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// RevokeTokenID puts the access token with the given jti on the revocation list
+// until it expires. Revoking it again keeps the first reason.
+func (s *Storage) RevokeTokenID(ctx context.Context, jti string, expiresAt time.Time, reason models.RevocationReason) error {
+	const op = "storage.sqlite.RevokeTokenID"
+
+	_, err := s.db.ExecContext(ctx, "INSERT OR IGNORE INTO revoked_tokens (jti, reason, expires_at) VALUES (?, ?, ?)", jti, reason, expiresAt.UTC())
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// RevokeSessionTokenIDs puts the access token last issued for the session with
+// the public session ID sid on the revocation list, unless it expired before now.
+func (s *Storage) RevokeSessionTokenIDs(ctx context.Context, sid string, reason models.RevocationReason, now time.Time) error {
+	const op = "storage.sqlite.RevokeSessionTokenIDs"
+
+	_, err := s.db.ExecContext(ctx, `
+		INSERT OR IGNORE INTO revoked_tokens (jti, reason, expires_at)
+		SELECT access_jti, ?, access_expires_at FROM sessions
+		WHERE sid = ? AND access_jti IS NOT NULL AND datetime(access_expires_at) > datetime(?)
+	`, reason, sid, now.UTC())
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// RevokeAccountTokenIDs puts the access tokens last issued for each of the
+// account's sessions, revoked or not, on the revocation list, skipping those that
+// expired before now. It returns how many it added.
+func (s *Storage) RevokeAccountTokenIDs(ctx context.Context, accountId int64, reason models.RevocationReason, now time.Time) (int64, error) {
+	const op = "storage.sqlite.RevokeAccountTokenIDs"
+
+	res, err := s.db.ExecContext(ctx, `
+		INSERT OR IGNORE INTO revoked_tokens (jti, reason, expires_at)
+		SELECT access_jti, ?, access_expires_at FROM sessions
+		WHERE account_id = ? AND access_jti IS NOT NULL AND datetime(access_expires_at) > datetime(?)
+	`, reason, accountId, now.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	revoked, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return revoked, nil
+}
+
+// TokenRevoked reports whether the access token with the given jti is on the
+// revocation list, and why.
+func (s *Storage) TokenRevoked(ctx context.Context, jti string) (bool, models.RevocationReason, error) {
+	const op = "storage.sqlite.TokenRevoked"
+
+	var reason sql.NullString
+	err := s.db.QueryRowContext(ctx, "SELECT reason FROM revoked_tokens WHERE jti = ?", jti).Scan(&reason)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, "", nil
+		}
+
+		return false, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return true, models.RevocationReason(reason.String), nil
+}
+
+// DeleteExpiredRevokedTokens deletes up to limit revocation list entries of tokens
+// that expired before before and returns how many it deleted.
+func (s *Storage) DeleteExpiredRevokedTokens(ctx context.Context, before time.Time, limit int) (int64, error) {
+	const op = "storage.sqlite.DeleteExpiredRevokedTokens"
+
+	res, err := s.db.ExecContext(ctx, `
+		DELETE FROM revoked_tokens WHERE jti IN (
+			SELECT jti FROM revoked_tokens
+			WHERE datetime(expires_at) < datetime(?)
+			LIMIT ?
+		)
+	`, before.UTC(), limit)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	deleted, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return deleted, nil
+}
@@ -0,0 +1,511 @@
+package sqlite
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"sso/internal/domain/models"
+	"sso/internal/storage"
+	"time"
+)
+
+// SecurityFactors collects the account's enrolled MFA methods and trusted devices.
+// Secrets, public keys and code hashes are never selected.
+func (s *Storage) SecurityFactors(ctx context.Context, accountId int64) (models.SecurityFactors, error) {
+	const op = "storage.sqlite.SecurityFactors"
+
+	var factors models.SecurityFactors
+
+	var (
+		enrolledAt time.Time
+		lastUsedAt sql.NullTime
+	)
+	err := s.db.QueryRowContext(ctx, `
+		SELECT created_at, last_used_at FROM totp_secrets
+		WHERE account_id = ? AND confirmed = 1
+	`, accountId).Scan(&enrolledAt, &lastUsedAt)
+	switch {
+	case err == nil:
+		factors.TOTP = &models.TOTPFactor{EnrolledAt: enrolledAt, LastUsedAt: nullTime(lastUsedAt)}
+	case !errors.Is(err, sql.ErrNoRows):
+		return models.SecurityFactors{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	passkeys, err := s.db.QueryContext(ctx, `
+		SELECT id, name, created_at, last_used_at FROM passkeys
+		WHERE account_id = ? ORDER BY created_at
+	`, accountId)
+	if err != nil {
+		return models.SecurityFactors{}, fmt.Errorf("%s: %w", op, err)
+	}
+	defer passkeys.Close()
+
+	for passkeys.Next() {
+		var passkey models.PasskeyFactor
+		if err := passkeys.Scan(&passkey.ID, &passkey.Name, &passkey.CreatedAt, &lastUsedAt); err != nil {
+			return models.SecurityFactors{}, fmt.Errorf("%s: %w", op, err)
+		}
+		passkey.LastUsedAt = nullTime(lastUsedAt)
+		factors.Passkeys = append(factors.Passkeys, passkey)
+	}
+	if err := passkeys.Err(); err != nil {
+		return models.SecurityFactors{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = s.db.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM recovery_codes WHERE account_id = ? AND used_at IS NULL
+	`, accountId).Scan(&factors.RecoveryCodesRemaining)
+	if err != nil {
+		return models.SecurityFactors{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	factors.TrustedDevices, err = s.trustedDevices(ctx, accountId)
+	if err != nil {
+		return models.SecurityFactors{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return factors, nil
+}
+
+// trustedDevices merges the devices the account has sessions from with the
+// devices and IPs it signed in from, in the order they were first seen.
+func (s *Storage) trustedDevices(ctx context.Context, accountId int64) ([]models.TrustedDevice, error) {
+	var (
+		devices []models.TrustedDevice
+		index   = map[string]int{}
+	)
+
+	// see records one sighting of deviceID, keeping the earliest first sighting and
+	// the IP of the latest one. Sessions are seen in the order they were created, so
+	// the user agent is the latest session's.
+	see := func(deviceID, userAgent, ipAddress string, firstSeenAt, lastSeenAt time.Time) {
+		i, ok := index[deviceID]
+		if !ok {
+			index[deviceID] = len(devices)
+			devices = append(devices, models.TrustedDevice{ID: deviceID, UserAgent: userAgent, IPAddress: ipAddress, CreatedAt: firstSeenAt, LastUsedAt: &lastSeenAt})
+			return
+		}
+
+		device := &devices[i]
+		if firstSeenAt.Before(device.CreatedAt) {
+			device.CreatedAt = firstSeenAt
+		}
+		if lastSeenAt.After(*device.LastUsedAt) {
+			device.LastUsedAt = &lastSeenAt
+			if ipAddress != "" {
+				device.IPAddress = ipAddress
+			}
+		}
+		if userAgent != "" {
+			device.UserAgent = userAgent
+		}
+	}
+
+	sessions, err := s.db.QueryContext(ctx, `
+		SELECT device_id, user_agent, ip_address, created_at, last_seen_at FROM sessions
+		WHERE account_id = ? AND device_id IS NOT NULL AND device_id != '' ORDER BY created_at
+	`, accountId)
+	if err != nil {
+		return nil, err
+	}
+	defer sessions.Close()
+
+	for sessions.Next() {
+		var (
+			deviceID  string
+			userAgent sql.NullString
+			ipAddress sql.NullString
+			createdAt time.Time
+			seenAt    sql.NullTime
+		)
+		if err := sessions.Scan(&deviceID, &userAgent, &ipAddress, &createdAt, &seenAt); err != nil {
+			return nil, err
+		}
+		lastSeenAt := createdAt
+		if seenAt.Valid {
+			lastSeenAt = seenAt.Time
+		}
+		see(deviceID, userAgent.String, ipAddress.String, createdAt, lastSeenAt)
+	}
+	if err := sessions.Err(); err != nil {
+		return nil, err
+	}
+
+	known, err := s.db.QueryContext(ctx, `
+		SELECT device_id, ip_address, first_seen_at, last_seen_at FROM known_devices
+		WHERE account_id = ? ORDER BY first_seen_at
+	`, accountId)
+	if err != nil {
+		return nil, err
+	}
+	defer known.Close()
+
+	for known.Next() {
+		var (
+			deviceID    string
+			ipAddress   string
+			firstSeenAt time.Time
+			lastSeenAt  time.Time
+		)
+		if err := known.Scan(&deviceID, &ipAddress, &firstSeenAt, &lastSeenAt); err != nil {
+			return nil, err
+		}
+		see(deviceID, "", ipAddress, firstSeenAt, lastSeenAt)
+	}
+	if err := known.Err(); err != nil {
+		return nil, err
+	}
+
+	names, err := s.DeviceNames(ctx, accountId)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(devices, func(i, j int) bool {
+		return devices[i].CreatedAt.Before(devices[j].CreatedAt)
+	})
+	for i := range devices {
+		devices[i].Name = names[devices[i].ID]
+	}
+
+	return devices, nil
+}
+
+// TouchTOTPSecret records that a code of the account's TOTP secret was accepted.
+func (s *Storage) TouchTOTPSecret(ctx context.Context, accountId int64, usedAt time.Time) error {
+	const op = "storage.sqlite.TouchTOTPSecret"
+
+	stmt, err := s.db.Prepare("UPDATE totp_secrets SET last_used_at = ? WHERE account_id = ? AND confirmed = 1")
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer stmt.Close()
+
+	_, err = stmt.ExecContext(ctx, usedAt, accountId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// SaveTOTPSecret stores a new, unconfirmed TOTP secret for the account, replacing
+// any previous one. The secret is encrypted before it reaches the database.
+func (s *Storage) SaveTOTPSecret(ctx context.Context, accountId int64, secret []byte) error {
+	const op = "storage.sqlite.SaveTOTPSecret"
+
+	encrypted, err := s.encrypt(secret)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	stmt, err := s.db.Prepare(`
+		INSERT INTO totp_secrets (account_id, secret, confirmed) VALUES (?, ?, 0)
+		ON CONFLICT (account_id) DO UPDATE SET
+			secret = excluded.secret, confirmed = 0, created_at = CURRENT_TIMESTAMP, last_used_at = NULL
+	`)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer stmt.Close()
+
+	_, err = stmt.ExecContext(ctx, accountId, encrypted)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// TOTPSecret returns the decrypted TOTP secret of the account and whether the
+// enrollment was confirmed.
+func (s *Storage) TOTPSecret(ctx context.Context, accountId int64) ([]byte, bool, error) {
+	const op = "storage.sqlite.TOTPSecret"
+
+	stmt, err := s.db.Prepare("SELECT secret, confirmed FROM totp_secrets WHERE account_id = ?")
+	if err != nil {
+		return nil, false, fmt.Errorf("%s: %w", op, err)
+	}
+	defer stmt.Close()
+
+	var (
+		encrypted []byte
+		confirmed bool
+	)
+	err = stmt.QueryRowContext(ctx, accountId).Scan(&encrypted, &confirmed)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, false, fmt.Errorf("%s: %w", op, storage.ErrTOTPNotEnrolled)
+		}
+		return nil, false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	secret, err := s.decrypt(encrypted)
+	if err != nil {
+		return nil, false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return secret, confirmed, nil
+}
+
+// SavePendingTOTPSecret stores a replacement for the account's confirmed TOTP
+// secret. The current secret stays in use until PromotePendingTOTPSecret.
+func (s *Storage) SavePendingTOTPSecret(ctx context.Context, accountId int64, secret []byte) error {
+	const op = "storage.sqlite.SavePendingTOTPSecret"
+
+	encrypted, err := s.encrypt(secret)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	stmt, err := s.db.Prepare("UPDATE totp_secrets SET pending_secret = ? WHERE account_id = ? AND confirmed = 1")
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer stmt.Close()
+
+	res, err := stmt.ExecContext(ctx, encrypted, accountId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrTOTPNotEnrolled)
+	}
+
+	return nil
+}
+
+// PendingTOTPSecret returns the decrypted replacement secret saved by
+// SavePendingTOTPSecret.
+func (s *Storage) PendingTOTPSecret(ctx context.Context, accountId int64) ([]byte, error) {
+	const op = "storage.sqlite.PendingTOTPSecret"
+
+	stmt, err := s.db.Prepare("SELECT pending_secret FROM totp_secrets WHERE account_id = ? AND pending_secret IS NOT NULL")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer stmt.Close()
+
+	var encrypted []byte
+	err = stmt.QueryRowContext(ctx, accountId).Scan(&encrypted)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%s: %w", op, storage.ErrTOTPNotPending)
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	secret, err := s.decrypt(encrypted)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return secret, nil
+}
+
+// PromotePendingTOTPSecret replaces the account's TOTP secret with the pending one
+// and deletes its unused recovery codes in one transaction. secret must still be
+// the pending secret, so a rotation restarted in the meantime is not promoted with
+// a code checked against its predecessor. Returns the number of recovery codes
+// deleted.
+func (s *Storage) PromotePendingTOTPSecret(ctx context.Context, accountId int64, secret []byte) (int64, error) {
+	const op = "storage.sqlite.PromotePendingTOTPSecret"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	var encrypted []byte
+	err = tx.QueryRowContext(ctx, `
+		SELECT pending_secret FROM totp_secrets WHERE account_id = ? AND pending_secret IS NOT NULL
+	`, accountId).Scan(&encrypted)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, fmt.Errorf("%s: %w", op, storage.ErrTOTPNotPending)
+		}
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	pending, err := s.decrypt(encrypted)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	if !bytes.Equal(pending, secret) {
+		return 0, fmt.Errorf("%s: %w", op, storage.ErrTOTPNotPending)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE totp_secrets SET secret = pending_secret, pending_secret = NULL,
+			created_at = CURRENT_TIMESTAMP, last_used_at = NULL
+		WHERE account_id = ?
+	`, accountId)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	res, err := tx.ExecContext(ctx, "DELETE FROM recovery_codes WHERE account_id = ? AND used_at IS NULL", accountId)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	deleted, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return deleted, nil
+}
+
+// ConfirmTOTPSecret marks the account's TOTP enrollment confirmed and stores its
+// first set of recovery codes in one transaction. Returns storage.ErrTOTPNotEnrolled
+// if there is no unconfirmed secret.
+func (s *Storage) ConfirmTOTPSecret(ctx context.Context, accountId int64, recoveryCodeHashes []string) error {
+	const op = "storage.sqlite.ConfirmTOTPSecret"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `
+		UPDATE totp_secrets SET confirmed = 1, created_at = CURRENT_TIMESTAMP
+		WHERE account_id = ? AND confirmed = 0
+	`, accountId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrTOTPNotEnrolled)
+	}
+
+	if err := replaceRecoveryCodes(ctx, tx, accountId, recoveryCodeHashes); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// ReplaceRecoveryCodes deletes all of the account's recovery codes, used or not,
+// and stores the given hashes in their place.
+func (s *Storage) ReplaceRecoveryCodes(ctx context.Context, accountId int64, codeHashes []string) error {
+	const op = "storage.sqlite.ReplaceRecoveryCodes"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	if err := replaceRecoveryCodes(ctx, tx, accountId, codeHashes); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func replaceRecoveryCodes(ctx context.Context, tx *sql.Tx, accountId int64, codeHashes []string) error {
+	if _, err := tx.ExecContext(ctx, "DELETE FROM recovery_codes WHERE account_id = ?", accountId); err != nil {
+		return err
+	}
+
+	stmt, err := tx.PrepareContext(ctx, "INSERT INTO recovery_codes (account_id, code_hash) VALUES (?, ?)")
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, hash := range codeHashes {
+		if _, err := stmt.ExecContext(ctx, accountId, hash); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ConsumeRecoveryCode marks an unused recovery code of the account used. Unknown
+// and already used codes return storage.ErrCodeNotFound; of concurrent calls with
+// the same code at most one succeeds.
+func (s *Storage) ConsumeRecoveryCode(ctx context.Context, accountId int64, codeHash string, now time.Time) error {
+	const op = "storage.sqlite.ConsumeRecoveryCode"
+
+	stmt, err := s.db.Prepare(`
+		UPDATE recovery_codes SET used_at = ?
+		WHERE id = (
+			SELECT id FROM recovery_codes
+			WHERE account_id = ? AND code_hash = ? AND used_at IS NULL
+			LIMIT 1
+		) AND used_at IS NULL
+	`)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer stmt.Close()
+
+	res, err := stmt.ExecContext(ctx, now, accountId, codeHash)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrCodeNotFound)
+	}
+
+	return nil
+}
+
+func (s *Storage) encrypt(plaintext []byte) ([]byte, error) {
+	if s.keyring == nil {
+		return nil, storage.ErrEncryptionNotConfigured
+	}
+
+	return s.keyring.Encrypt(plaintext)
+}
+
+func (s *Storage) decrypt(ciphertext []byte) ([]byte, error) {
+	if s.keyring == nil {
+		return nil, storage.ErrEncryptionNotConfigured
+	}
+
+	return s.keyring.Decrypt(ciphertext)
+}
+
+func nullTime(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+
+	return &t.Time
+}
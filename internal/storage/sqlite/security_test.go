@@ -0,0 +1,133 @@
+package sqlite_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"sso/internal/domain/models"
+	"sso/internal/lib/encryption"
+	"sso/internal/storage/sqlite/sqlitetest"
+)
+
+func TestSecurityFactorsDevices(t *testing.T) {
+	ctx := context.Background()
+	s := sqlitetest.New(t, nil)
+
+	appID, err := s.SaveApp(ctx, "app", "secret", "")
+	if err != nil {
+		t.Fatalf("save app: %v", err)
+	}
+	accountID, err := s.SaveAccount(ctx, "a@example.com", []byte("hash"), models.USER, models.ACTIVE, int32(appID), "", "", "")
+	if err != nil {
+		t.Fatalf("save account: %v", err)
+	}
+
+	start := time.Now().Add(-time.Hour).UTC()
+	expiresAt := time.Now().Add(time.Hour)
+
+	// The laptop signs in twice, from two IPs, and is named; the phone only has a
+	// recorded sign-in left, its sessions long cleaned up.
+	if _, err := s.SaveSession(ctx, "sid-1", accountID, int32(appID), "laptop-ua-old", "198.51.100.1", models.SessionDevice{ID: "laptop"}, "rt-1", expiresAt, start, "fam-1", nil, false); err != nil {
+		t.Fatalf("save session: %v", err)
+	}
+	if _, err := s.SaveSession(ctx, "sid-2", accountID, int32(appID), "laptop-ua-new", "198.51.100.2", models.SessionDevice{ID: "laptop"}, "rt-2", expiresAt, start, "fam-2", nil, false); err != nil {
+		t.Fatalf("save session: %v", err)
+	}
+	if _, err := s.RecordDeviceLogin(ctx, accountID, "laptop", "198.51.100.2", time.Now().Add(time.Minute)); err != nil {
+		t.Fatalf("record laptop login: %v", err)
+	}
+	if _, err := s.RecordDeviceLogin(ctx, accountID, "phone", "203.0.113.9", start.Add(-time.Hour)); err != nil {
+		t.Fatalf("record phone login: %v", err)
+	}
+	if err := s.SetDeviceName(ctx, accountID, "laptop", "Work laptop"); err != nil {
+		t.Fatalf("name device: %v", err)
+	}
+
+	factors, err := s.SecurityFactors(ctx, accountID)
+	if err != nil {
+		t.Fatalf("security factors: %v", err)
+	}
+
+	tests := []struct {
+		id        string
+		name      string
+		userAgent string
+		ipAddress string
+	}{
+		{id: "phone", ipAddress: "203.0.113.9"},
+		{id: "laptop", name: "Work laptop", userAgent: "laptop-ua-new", ipAddress: "198.51.100.2"},
+	}
+
+	if len(factors.TrustedDevices) != len(tests) {
+		t.Fatalf("%d devices, want %d: %+v", len(factors.TrustedDevices), len(tests), factors.TrustedDevices)
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.id, func(t *testing.T) {
+			device := factors.TrustedDevices[i]
+			if device.ID != tt.id {
+				t.Fatalf("device %d = %q, want %q", i, device.ID, tt.id)
+			}
+			if device.Name != tt.name {
+				t.Errorf("name = %q, want %q", device.Name, tt.name)
+			}
+			if device.UserAgent != tt.userAgent {
+				t.Errorf("user agent = %q, want %q", device.UserAgent, tt.userAgent)
+			}
+			if device.IPAddress != tt.ipAddress {
+				t.Errorf("ip = %q, want %q", device.IPAddress, tt.ipAddress)
+			}
+			if device.LastUsedAt == nil || device.LastUsedAt.Before(device.CreatedAt) {
+				t.Errorf("last used %v before first seen %v", device.LastUsedAt, device.CreatedAt)
+			}
+		})
+	}
+}
+
+func TestTouchTOTPSecret(t *testing.T) {
+	ctx := context.Background()
+	keyring, err := encryption.NewKeyring(1, map[byte][]byte{1: bytes.Repeat([]byte{7}, 32)})
+	if err != nil {
+		t.Fatalf("keyring: %v", err)
+	}
+	s := sqlitetest.New(t, keyring)
+
+	appID, err := s.SaveApp(ctx, "app", "secret", "")
+	if err != nil {
+		t.Fatalf("save app: %v", err)
+	}
+	accountID, err := s.SaveAccount(ctx, "a@example.com", []byte("hash"), models.USER, models.ACTIVE, int32(appID), "", "", "")
+	if err != nil {
+		t.Fatalf("save account: %v", err)
+	}
+
+	if err := s.SaveTOTPSecret(ctx, accountID, []byte("secret")); err != nil {
+		t.Fatalf("save totp secret: %v", err)
+	}
+	if err := s.ConfirmTOTPSecret(ctx, accountID, nil); err != nil {
+		t.Fatalf("confirm totp secret: %v", err)
+	}
+
+	factors, err := s.SecurityFactors(ctx, accountID)
+	if err != nil {
+		t.Fatalf("security factors: %v", err)
+	}
+	if factors.TOTP == nil || factors.TOTP.LastUsedAt != nil {
+		t.Fatalf("totp before use = %+v, want enrolled and unused", factors.TOTP)
+	}
+
+	usedAt := time.Now().UTC().Truncate(time.Second)
+	if err := s.TouchTOTPSecret(ctx, accountID, usedAt); err != nil {
+		t.Fatalf("touch totp secret: %v", err)
+	}
+
+	factors, err = s.SecurityFactors(ctx, accountID)
+	if err != nil {
+		t.Fatalf("security factors: %v", err)
+	}
+	if factors.TOTP.LastUsedAt == nil || !factors.TOTP.LastUsedAt.Equal(usedAt) {
+		t.Errorf("totp last used = %v, want %v", factors.TOTP.LastUsedAt, usedAt)
+	}
+}
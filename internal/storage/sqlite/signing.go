@@ -0,0 +1,172 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"sso/internal/domain/models"
+	"sso/internal/storage"
+)
+
+// appSigningKeys returns the keys of the app that haven't retired by now, the
+// current one first, then the rest newest first, with their private keys
+// decrypted. Keys held by a key provider come with their KeyRef only.
+func (s *Storage) appSigningKeys(ctx context.Context, appId int32, now time.Time) ([]models.SigningKey, error) {
+	// retires_at carries a zone offset, so it is compared through datetime, in UTC.
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT kid, alg, private_key, key_ref, created_at, retires_at FROM app_signing_keys
+		WHERE app_id = ? AND (retires_at IS NULL OR datetime(retires_at) > datetime(?))
+		ORDER BY retires_at IS NOT NULL, id DESC
+	`, appId, now.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []models.SigningKey
+	for rows.Next() {
+		var (
+			key       models.SigningKey
+			encrypted []byte
+			keyRef    sql.NullString
+			retiresAt sql.NullTime
+		)
+		if err := rows.Scan(&key.KID, &key.Algorithm, &encrypted, &keyRef, &key.CreatedAt, &retiresAt); err != nil {
+			return nil, err
+		}
+
+		key.KeyRef = keyRef.String
+		if key.KeyRef == "" {
+			key.PrivateKey, err = s.decrypt(encrypted)
+			if err != nil {
+				return nil, fmt.Errorf("signing key %s: %w", key.KID, err)
+			}
+		}
+		key.RetiresAt = nullTime(retiresAt)
+
+		keys = append(keys, key)
+	}
+
+	return keys, rows.Err()
+}
+
+// RotateAppSigningKey sets the algorithm the app's tokens are signed with and
+// makes key its current signing key, encrypted before it reaches the database;
+// a key held by a key provider is stored by its KeyRef alone. The previous
+// current key retires at retireAt. A nil key, as for SigningHS256, only retires
+// the current one.
+func (s *Storage) RotateAppSigningKey(ctx context.Context, appId int32, alg models.SigningAlgorithm, key *models.SigningKey, retireAt time.Time) error {
+	const op = "storage.sqlite.RotateAppSigningKey"
+
+	encrypted := []byte{}
+	if key != nil && key.KeyRef == "" {
+		var err error
+		encrypted, err = s.encrypt(key.PrivateKey)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, "UPDATE apps SET signing_alg = ? WHERE id = ?", alg, appId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrAppNotFound)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE app_signing_keys SET retires_at = ? WHERE app_id = ? AND retires_at IS NULL
+	`, retireAt.UTC(), appId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if key != nil {
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO app_signing_keys (app_id, kid, alg, private_key, key_ref, created_at)
+			VALUES (?, ?, ?, ?, ?, ?)
+		`, appId, key.KID, key.Algorithm, encrypted, sql.NullString{String: key.KeyRef, Valid: key.KeyRef != ""}, key.CreatedAt.UTC())
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// AppsDueForKeyRotation returns the apps signing with a key pair whose current
+// key was created before createdBefore, or which have none. Apps whose current
+// key is held by a key provider are rotated there, so they are left out.
+func (s *Storage) AppsDueForKeyRotation(ctx context.Context, createdBefore time.Time) ([]int32, error) {
+	const op = "storage.sqlite.AppsDueForKeyRotation"
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id FROM apps
+		WHERE signing_alg != ? AND NOT EXISTS (
+			SELECT 1 FROM app_signing_keys
+			WHERE app_id = apps.id AND retires_at IS NULL
+				AND (key_ref IS NOT NULL OR datetime(created_at) >= datetime(?))
+		)
+		ORDER BY id
+	`, models.SigningHS256, createdBefore.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var ids []int32
+	for rows.Next() {
+		var id int32
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return ids, nil
+}
+
+// DeleteRetiredSigningKeys deletes up to limit signing keys that retired before
+// before and returns how many it deleted.
+func (s *Storage) DeleteRetiredSigningKeys(ctx context.Context, before time.Time, limit int) (int64, error) {
+	const op = "storage.sqlite.DeleteRetiredSigningKeys"
+
+	res, err := s.db.ExecContext(ctx, `
+		DELETE FROM app_signing_keys WHERE id IN (
+			SELECT id FROM app_signing_keys
+			WHERE retires_at IS NOT NULL AND datetime(retires_at) < datetime(?)
+			ORDER BY id LIMIT ?
+		)
+	`, before.UTC(), limit)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	deleted, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return deleted, nil
+}
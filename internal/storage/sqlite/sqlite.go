@@ -7,12 +7,17 @@ import (
 	"fmt"
 	"github.com/mattn/go-sqlite3"
 	"sso/internal/domain/models"
+	"sso/internal/lib/encryption"
 	"sso/internal/storage"
+	"strings"
 	"time"
 )
 
 type Storage struct {
 	db *sql.DB
+	// keyring encrypts sensitive columns such as TOTP secrets. Without it such
+	// values can't be stored.
+	keyring *encryption.Keyring
 }
 
 func (s *Storage) IsAdmin(ctx context.Context, accountId int64) (bool, error) {
@@ -24,7 +29,7 @@ func (s *Storage) IsAdmin(ctx context.Context, accountId int64) (bool, error) {
 	}
 	defer stmt.Close()
 
-	var role string
+	var role models.AccountRole
 	err = stmt.QueryRowContext(ctx, accountId).Scan(&role)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
@@ -33,12 +38,64 @@ func (s *Storage) IsAdmin(ctx context.Context, accountId int64) (bool, error) {
 		return false, fmt.Errorf("%s: %w", op, err)
 	}
 
-	isAdmin := role == "admin"
+	isAdmin := role == models.ADMIN
 
 	return isAdmin, nil
 }
 
-func New(storagePath string) (*Storage, error) {
+func (s *Storage) AccountStats(ctx context.Context) (models.AccountStats, error) {
+	const op = "storage.sqlite.AccountStats"
+
+	stats := models.AccountStats{
+		ByStatus: make(map[models.AccountStatus]int64),
+		ByRole:   make(map[models.AccountRole]int64),
+	}
+
+	rows, err := s.db.QueryContext(ctx, "SELECT status, COUNT(*) FROM accounts GROUP BY status")
+	if err != nil {
+		return models.AccountStats{}, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			status models.AccountStatus
+			count  int64
+		)
+		if err := rows.Scan(&status, &count); err != nil {
+			return models.AccountStats{}, fmt.Errorf("%s: %w", op, err)
+		}
+		stats.ByStatus[status] = count
+		stats.Total += count
+	}
+	if err := rows.Err(); err != nil {
+		return models.AccountStats{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	rows, err = s.db.QueryContext(ctx, "SELECT role, COUNT(*) FROM accounts GROUP BY role")
+	if err != nil {
+		return models.AccountStats{}, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			role  models.AccountRole
+			count int64
+		)
+		if err := rows.Scan(&role, &count); err != nil {
+			return models.AccountStats{}, fmt.Errorf("%s: %w", op, err)
+		}
+		stats.ByRole[role] = count
+	}
+	if err := rows.Err(); err != nil {
+		return models.AccountStats{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return stats, nil
+}
+
+func New(storagePath string, keyring *encryption.Keyring) (*Storage, error) {
 	const op = "storage.sqlite.New"
 
 	db, err := sql.Open("sqlite3", storagePath)
@@ -46,26 +103,50 @@ func New(storagePath string) (*Storage, error) {
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
-	return &Storage{db: db}, nil
+	return &Storage{db: db, keyring: keyring}, nil
+}
+
+// Ping checks that the database is reachable.
+func (s *Storage) Ping(ctx context.Context) error {
+	const op = "storage.sqlite.Ping"
+
+	if err := s.db.PingContext(ctx); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
 }
 
-func (s *Storage) SaveAccount(ctx context.Context, email string, passHash []byte, role models.AccountRole, status models.AccountStatus, appID int32) (int64, error) {
+// SaveAccount inserts the account together with its membership in the app it
+// registers with. An empty externalID, username or phone leaves the account without
+// one.
+func (s *Storage) SaveAccount(ctx context.Context, email string, passHash []byte, role models.AccountRole, status models.AccountStatus, appID int32, externalID string, username string, phone string) (int64, error) {
 	const op = "storage.sqlite.SaveAccount"
 
-	stmt, err := s.db.Prepare(`
-		INSERT INTO accounts (email, pass_hash, status, app_id, role) 
-		VALUES (?, ?, ?, ?, ?)
-	`)
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
 		return 0, fmt.Errorf("%s: %w", op, err)
 	}
-	defer stmt.Close()
+	defer tx.Rollback()
 
-	res, err := stmt.ExecContext(ctx, email, passHash, status, appID, role)
+	res, err := tx.ExecContext(ctx, `
+		INSERT INTO accounts (email, pass_hash, status, app_id, role, external_id, username, phone) 
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, email, passHash, status, appID, role, sql.NullString{String: externalID, Valid: externalID != ""},
+		sql.NullString{String: username, Valid: username != ""}, sql.NullString{String: phone, Valid: phone != ""})
 	if err != nil {
 		var sqliteErr sqlite3.Error
 
-		if errors.As(err, &sqliteErr) && errors.Is(sqliteErr, sqlite3.ErrConstraintUnique) {
+		if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
+			if strings.Contains(sqliteErr.Error(), "accounts.external_id") {
+				return 0, fmt.Errorf("%s: %w", op, storage.ErrExternalIDExists)
+			}
+			if strings.Contains(sqliteErr.Error(), "accounts.username") {
+				return 0, fmt.Errorf("%s: %w", op, storage.ErrUsernameExists)
+			}
+			if strings.Contains(sqliteErr.Error(), "accounts.phone") {
+				return 0, fmt.Errorf("%s: %w", op, storage.ErrPhoneExists)
+			}
 			return 0, fmt.Errorf("%s: %w", op, storage.ErrAccountExists)
 		}
 
@@ -77,6 +158,17 @@ func (s *Storage) SaveAccount(ctx context.Context, email string, passHash []byte
 		return 0, fmt.Errorf("%s: %w", op, err)
 	}
 
+	if appID != 0 {
+		_, err = tx.ExecContext(ctx, "INSERT INTO app_memberships (account_id, app_id, role) VALUES (?, ?, ?)", id, appID, role)
+		if err != nil {
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
 	return id, nil
 }
 
@@ -106,15 +198,18 @@ func (s *Storage) Account(ctx context.Context, email string) (models.Account, er
 func (s *Storage) App(ctx context.Context, appId int32) (models.App, error) {
 	const op = "storage.sqlite.App"
 
-	stmt, err := s.db.Prepare("SELECT id, name, secret FROM apps WHERE id = ?")
+	stmt, err := s.db.Prepare("SELECT id, name, secret, mfa_policy, token_version, rp_id, rp_origins, require_username, self_registration, signing_alg, audience, oidc_enabled FROM apps WHERE id = ?")
 	if err != nil {
 		return models.App{}, fmt.Errorf("%s: %w", op, err)
 	}
 
 	row := stmt.QueryRowContext(ctx, appId)
 
-	var app models.App
-	err = row.Scan(&app.ID, &app.Name, &app.Secret, &app.RedirectUrl)
+	var (
+		app       models.App
+		rpOrigins string
+	)
+	err = row.Scan(&app.ID, &app.Name, &app.Secret, &app.MFAPolicy, &app.TokenVersion, &app.RPID, &rpOrigins, &app.RequireUsername, &app.SelfRegistration, &app.SigningAlgorithm, &app.Audience, &app.OIDCEnabled)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return models.App{}, fmt.Errorf("%s: %w", op, storage.ErrAppNotFound)
@@ -122,6 +217,12 @@ func (s *Storage) App(ctx context.Context, appId int32) (models.App, error) {
 
 		return models.App{}, fmt.Errorf("%s: %w", op, err)
 	}
+	app.RPOrigins = strings.Fields(rpOrigins)
+
+	app.SigningKeys, err = s.appSigningKeys(ctx, appId, time.Now())
+	if err != nil {
+		return models.App{}, fmt.Errorf("%s: %w", op, err)
+	}
 
 	return app, nil
 }
@@ -130,7 +231,7 @@ func (s *Storage) SaveApp(ctx context.Context, appName string, secret string, re
 	const op = "storage.sqlite.SaveApp"
 
 	stmt, err := s.db.Prepare(`
-		INSERT INTO app (app_name, secret, redirect_url) 
+		INSERT INTO apps (name, secret, redirect_url)
 		VALUES (?, ?, ?)
 	`)
 	if err != nil {
@@ -142,7 +243,7 @@ func (s *Storage) SaveApp(ctx context.Context, appName string, secret string, re
 	if err != nil {
 		var sqliteErr sqlite3.Error
 
-		if errors.As(err, &sqliteErr) && errors.Is(sqliteErr, sqlite3.ErrConstraintUnique) {
+		if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
 			return 0, fmt.Errorf("%s: %w", op, storage.ErrAppExists)
 		}
 
@@ -160,55 +261,144 @@ func (s *Storage) SaveApp(ctx context.Context, appName string, secret string, re
 func (s *Storage) AccountByEmail(ctx context.Context, email string) (models.Account, error) {
 	const op = "storage.sqlite.AccountByEmail"
 
-	stmt, err := s.db.Prepare("SELECT id, email, pass_hash, role, status, app_id FROM accounts WHERE email = ?")
+	return s.accountBy(ctx, op, "email = ?", email)
+}
+
+func (s *Storage) AccountById(ctx context.Context, accountId int64) (models.Account, error) {
+	const op = "storage.sqlite.AccountById"
+
+	return s.accountBy(ctx, op, "id = ?", accountId)
+}
+
+// AccountByExternalID looks an account up by its ID in an upstream system.
+func (s *Storage) AccountByExternalID(ctx context.Context, externalID string) (models.Account, error) {
+	const op = "storage.sqlite.AccountByExternalID"
+
+	return s.accountBy(ctx, op, "external_id = ?", externalID)
+}
+
+// AccountByUsername looks an account up by username.
+func (s *Storage) AccountByUsername(ctx context.Context, username string) (models.Account, error) {
+	const op = "storage.sqlite.AccountByUsername"
+
+	return s.accountBy(ctx, op, "username = ?", username)
+}
+
+// AccountByUsernameInApp looks an account up by username among those registered
+// with appID, for per-app identifiers.
+func (s *Storage) AccountByUsernameInApp(ctx context.Context, username string, appID int32) (models.Account, error) {
+	const op = "storage.sqlite.AccountByUsernameInApp"
+
+	return s.accountBy(ctx, op, "username = ? AND app_id = ?", username, appID)
+}
+
+// AccountByPhone looks an account up by phone number.
+func (s *Storage) AccountByPhone(ctx context.Context, phone string) (models.Account, error) {
+	const op = "storage.sqlite.AccountByPhone"
+
+	return s.accountBy(ctx, op, "phone = ?", phone)
+}
+
+// AccountByPhoneInApp looks an account up by phone number among those registered
+// with appID, for per-app identifiers.
+func (s *Storage) AccountByPhoneInApp(ctx context.Context, phone string, appID int32) (models.Account, error) {
+	const op = "storage.sqlite.AccountByPhoneInApp"
+
+	return s.accountBy(ctx, op, "phone = ? AND app_id = ?", phone, appID)
+}
+
+// AccountByEmailInApp looks an account up by email among those registered with
+// appID, for per-app identifiers where the same email may exist once per app.
+func (s *Storage) AccountByEmailInApp(ctx context.Context, email string, appID int32) (models.Account, error) {
+	const op = "storage.sqlite.AccountByEmailInApp"
+
+	return s.accountBy(ctx, op, "email = ? AND app_id = ?", email, appID)
+}
+
+// accountBy loads the account matching where with args. where is always one of the
+// fixed conditions above, never user input.
+func (s *Storage) accountBy(ctx context.Context, op string, where string, args ...any) (models.Account, error) {
+	stmt, err := s.db.Prepare("SELECT id, email, username, phone, pass_hash, role, status, app_id, last_login_at, token_version, external_id, requires_password_change FROM accounts WHERE " + where)
 	if err != nil {
 		return models.Account{}, fmt.Errorf("%s: %w", op, err)
 	}
 	defer stmt.Close()
 
-	var account models.Account
-	err = stmt.QueryRowContext(ctx, email).Scan(&account.ID, &account.Email, &account.PassHash, &account.Role, &account.Status, &account.AppId)
+	var (
+		account     models.Account
+		lastLoginAt sql.NullTime
+		externalID  sql.NullString
+		username    sql.NullString
+		phone       sql.NullString
+	)
+	err = stmt.QueryRowContext(ctx, args...).Scan(&account.ID, &account.Email, &username, &phone, &account.PassHash, &account.Role, &account.Status, &account.AppId, &lastLoginAt, &account.TokenVersion, &externalID, &account.RequiresPasswordChange)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return models.Account{}, fmt.Errorf("%s: %w", op, storage.ErrAccountNotFound)
 		}
 		return models.Account{}, fmt.Errorf("%s: %w", op, err)
 	}
+	account.LastLoginAt = nullTime(lastLoginAt)
+	account.ExternalID = externalID.String
+	account.Username = username.String
+	account.Phone = phone.String
 
 	return account, nil
 }
 
-func (s *Storage) AccountById(ctx context.Context, accountId int64) (models.Account, error) {
-	const op = "storage.sqlite.AccountById"
+// UpdatePassword sets a new password hash and bumps the token version, so tokens
+// issued under the old password stop validating.
+func (s *Storage) UpdatePassword(ctx context.Context, accountId int64, newPassHash []byte) error {
+	const op = "storage.sqlite.UpdatePassword"
 
-	stmt, err := s.db.Prepare("SELECT id, email, pass_hash, role, status, app_id FROM accounts WHERE id = ?")
+	stmt, err := s.db.Prepare("UPDATE accounts SET pass_hash = ?, token_version = token_version + 1 WHERE id = ?")
 	if err != nil {
-		return models.Account{}, fmt.Errorf("%s: %w", op, err)
+		return fmt.Errorf("%s: %w", op, err)
 	}
 	defer stmt.Close()
 
-	var account models.Account
-	err = stmt.QueryRowContext(ctx, accountId).Scan(&account.ID, &account.Email, &account.PassHash, &account.Role, &account.Status, &account.AppId)
+	_, err = stmt.ExecContext(ctx, newPassHash, accountId)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return models.Account{}, fmt.Errorf("%s: %w", op, storage.ErrAccountNotFound)
-		}
-		return models.Account{}, fmt.Errorf("%s: %w", op, err)
+		return fmt.Errorf("%s: %w", op, err)
 	}
 
-	return account, nil
+	return nil
 }
 
-func (s *Storage) UpdatePassword(ctx context.Context, accountId int64, newPassHash []byte) error {
-	const op = "storage.sqlite.UpdatePassword"
+// SetPasswordChangeRequired sets whether the account must change its password
+// before its tokens are good for anything else.
+func (s *Storage) SetPasswordChangeRequired(ctx context.Context, accountId int64, required bool) error {
+	const op = "storage.sqlite.SetPasswordChangeRequired"
 
-	stmt, err := s.db.Prepare("UPDATE accounts SET pass_hash = ? WHERE id = ?")
+	res, err := s.db.ExecContext(ctx, "UPDATE accounts SET requires_password_change = ? WHERE id = ?", required, accountId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrAccountNotFound)
+	}
+
+	return nil
+}
+
+// RehashPassword replaces the account's password hash with an equivalent one made
+// with other parameters. Unlike UpdatePassword it keeps issued tokens valid, and
+// it does nothing if the hash is no longer oldPassHash.
+func (s *Storage) RehashPassword(ctx context.Context, accountId int64, oldPassHash []byte, newPassHash []byte) error {
+	const op = "storage.sqlite.RehashPassword"
+
+	stmt, err := s.db.Prepare("UPDATE accounts SET pass_hash = ? WHERE id = ? AND pass_hash = ?")
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
 	defer stmt.Close()
 
-	_, err = stmt.ExecContext(ctx, newPassHash, accountId)
+	_, err = stmt.ExecContext(ctx, newPassHash, accountId, oldPassHash)
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
@@ -233,12 +423,122 @@ func (s *Storage) UpdateStatus(ctx context.Context, accountId int64, status mode
 	return nil
 }
 
-func (s *Storage) SaveSession(ctx context.Context, accountId int64, userAgent, ipAddress, token, refreshToken string, expiresAt time.Time) (string, error) {
+// DeactivateIdleAccount moves the account to DORMANT if it is still ACTIVE and
+// hasn't logged in since before. It reports whether the account was deactivated,
+// so an account that logged in or changed status since IdleAccounts listed it is
+// left alone.
+func (s *Storage) DeactivateIdleAccount(ctx context.Context, accountId int64, before time.Time) (bool, error) {
+	const op = "storage.sqlite.DeactivateIdleAccount"
+
+	res, err := s.db.ExecContext(ctx, `
+		UPDATE accounts SET status = ?
+		WHERE id = ? AND status = ? AND COALESCE(last_login_at, created_at) < ?
+	`, models.DORMANT, accountId, models.ACTIVE, before.UTC())
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return affected == 1, nil
+}
+
+// IncrementTokenVersion bumps the account's token version, invalidating every token
+// issued before.
+func (s *Storage) IncrementTokenVersion(ctx context.Context, accountId int64) error {
+	const op = "storage.sqlite.IncrementTokenVersion"
+
+	stmt, err := s.db.Prepare("UPDATE accounts SET token_version = token_version + 1 WHERE id = ?")
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer stmt.Close()
+
+	res, err := stmt.ExecContext(ctx, accountId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrAccountNotFound)
+	}
+
+	return nil
+}
+
+func (s *Storage) UpdateLastLogin(ctx context.Context, accountId int64, at time.Time) error {
+	const op = "storage.sqlite.UpdateLastLogin"
+
+	stmt, err := s.db.Prepare("UPDATE accounts SET last_login_at = ? WHERE id = ?")
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer stmt.Close()
+
+	_, err = stmt.ExecContext(ctx, at.UTC(), accountId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// IdleAccounts returns IDs of active accounts with no login since before, in ID
+// order, starting after afterID and returning at most limit IDs (all if limit <= 0).
+// Accounts that never logged in are measured from their creation time.
+func (s *Storage) IdleAccounts(ctx context.Context, before time.Time, afterID int64, limit int) ([]int64, error) {
+	const op = "storage.sqlite.IdleAccounts"
+
+	if limit <= 0 {
+		limit = -1 // SQLite reads a negative LIMIT as no limit.
+	}
+
+	stmt, err := s.db.Prepare(`
+		SELECT id FROM accounts
+		WHERE status = ? AND COALESCE(last_login_at, created_at) < ? AND id > ?
+		ORDER BY id LIMIT ?
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx, models.ACTIVE, before.UTC(), afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return ids, nil
+}
+
+// SaveSession saves a new session. Only the hash of its refresh token is stored,
+// and its access tokens not at all: they name the session by sid.
+func (s *Storage) SaveSession(ctx context.Context, sid string, accountId int64, appId int32, userAgent, ipAddress string, device models.SessionDevice, refreshTokenHash string, expiresAt time.Time, familyStartedAt time.Time, familyID string, scopes []string, rememberMe bool) (string, error) {
 	const op = "storage.sqlite.SaveSession"
 
 	stmt, err := s.db.Prepare(`
-		INSERT INTO sessions (account_id, token, refresh_token, user_agent, ip_address, expires_at, refresh_expires_at) 
-		VALUES (?, ?, ?, ?, ?, ?, ?)
+		INSERT INTO sessions (sid, account_id, app_id, refresh_token, user_agent, ip_address, device_id, os, browser, expires_at, refresh_expires_at, family_started_at, family_id, scopes, remember_me, refresh_token_hashed) 
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, 1)
 	`)
 	if err != nil {
 		return "", fmt.Errorf("%s: %w", op, err)
@@ -247,19 +547,81 @@ func (s *Storage) SaveSession(ctx context.Context, accountId int64, userAgent, i
 
 	refreshExpiresAt := expiresAt.Add(7 * 24 * time.Hour)
 
-	_, err = stmt.ExecContext(ctx, accountId, token, refreshToken, userAgent, ipAddress, expiresAt, refreshExpiresAt)
+	_, err = stmt.ExecContext(ctx, sid, accountId, appId, refreshTokenHash, userAgent, ipAddress, device.ID, device.OS, device.Browser, expiresAt, refreshExpiresAt, familyStartedAt, familyID, strings.Join(scopes, " "), rememberMe)
 	if err != nil {
+		var sqliteErr sqlite3.Error
+
+		// The session ID or refresh token is already taken.
+		if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
+			return "", fmt.Errorf("%s: %w", op, storage.ErrSessionExists)
+		}
+
 		return "", fmt.Errorf("%s: %w", op, err)
 	}
 
-	return token, nil
+	return sid, nil
+}
+
+// sessionColumns lists the columns scanSession expects, in order.
+const sessionColumns = "id, sid, account_id, app_id, refresh_token, user_agent, ip_address, expires_at, refresh_expires_at, created_at, updated_at, revoked, revoked_reason, family_started_at, scopes, last_seen_at, device_id, os, browser, elevated_until, remember_me, family_id"
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanSession(row rowScanner) (models.Session, error) {
+	var (
+		session   models.Session
+		appID     sql.NullInt32
+		sid       sql.NullString
+		userAgent sql.NullString
+		ipAddress sql.NullString
+		reason    sql.NullString
+		familyAt  sql.NullTime
+		scopes    sql.NullString
+		seenAt    sql.NullTime
+		deviceID  sql.NullString
+		os        sql.NullString
+		browser   sql.NullString
+		elevated  sql.NullTime
+		familyID  sql.NullString
+	)
+
+	err := row.Scan(&session.ID, &sid, &session.AccountID, &appID, &session.RefreshToken, &userAgent, &ipAddress, &session.ExpiresAt, &session.RefreshExpiresAt, &session.CreatedAt, &session.UpdatedAt, &session.Revoked, &reason, &familyAt, &scopes, &seenAt, &deviceID, &os, &browser, &elevated, &session.RememberMe, &familyID)
+	if err != nil {
+		return models.Session{}, err
+	}
+
+	session.AppID = appID.Int32
+	session.SID = sid.String
+	session.UserAgent = userAgent.String
+	session.IPAddress = ipAddress.String
+	session.RevokedReason = models.RevocationReason(reason.String)
+	session.FamilyStartedAt = session.CreatedAt
+	if familyAt.Valid {
+		session.FamilyStartedAt = familyAt.Time
+	}
+	if scopes.Valid {
+		session.Scopes = strings.Fields(scopes.String)
+	}
+	session.LastSeenAt = session.CreatedAt
+	if seenAt.Valid {
+		session.LastSeenAt = seenAt.Time
+	}
+	session.DeviceID = deviceID.String
+	session.OS = os.String
+	session.Browser = browser.String
+	session.ElevatedUntil = elevated.Time
+	session.FamilyID = familyID.String
+
+	return session, nil
 }
 
 func (s *Storage) Sessions(ctx context.Context, accountId int64) ([]models.Session, error) {
 	const op = "storage.sqlite.Sessions"
 
 	stmt, err := s.db.Prepare(`
-		SELECT id, account_id, token, refresh_token, user_agent, ip_address, expires_at, refresh_expires_at, revoked 
+		SELECT ` + sessionColumns + `
 		FROM sessions WHERE account_id = ? AND revoked = 0
 	`)
 	if err != nil {
@@ -275,8 +637,7 @@ func (s *Storage) Sessions(ctx context.Context, accountId int64) ([]models.Sessi
 
 	var sessions []models.Session
 	for rows.Next() {
-		var session models.Session
-		err := rows.Scan(&session.ID, &session.AccountID, &session.Token, &session.RefreshToken, &session.UserAgent, &session.IPAddress, &session.ExpiresAt, &session.RefreshExpiresAt, &session.Revoked)
+		session, err := scanSession(rows)
 		if err != nil {
 			return nil, fmt.Errorf("%s: %w", op, err)
 		}
@@ -286,20 +647,60 @@ func (s *Storage) Sessions(ctx context.Context, accountId int64) ([]models.Sessi
 	return sessions, nil
 }
 
-func (s *Storage) Session(ctx context.Context, token string) (models.Session, error) {
-	const op = "storage.sqlite.Session"
+// SessionsForAccounts returns active sessions of all given accounts in one query,
+// grouped by account ID. Accounts without sessions are absent from the map.
+func (s *Storage) SessionsForAccounts(ctx context.Context, accountIds []int64) (map[int64][]models.Session, error) {
+	const op = "storage.sqlite.SessionsForAccounts"
+
+	result := make(map[int64][]models.Session, len(accountIds))
+	if len(accountIds) == 0 {
+		return result, nil
+	}
+
+	placeholders := strings.Repeat("?, ", len(accountIds)-1) + "?"
+	args := make([]any, len(accountIds))
+	for i, id := range accountIds {
+		args[i] = id
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+sessionColumns+`
+		FROM sessions WHERE account_id IN (`+placeholders+`) AND revoked = 0
+		ORDER BY account_id, created_at
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		result[session.AccountID] = append(result[session.AccountID], session)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return result, nil
+}
+
+// SessionBySID returns the session with the public session ID sid.
+func (s *Storage) SessionBySID(ctx context.Context, sid string) (models.Session, error) {
+	const op = "storage.sqlite.SessionBySID"
 
 	stmt, err := s.db.Prepare(`
-		SELECT id, account_id, token, refresh_token, user_agent, ip_address, expires_at, refresh_expires_at, revoked 
-		FROM sessions WHERE token = ?
+		SELECT ` + sessionColumns + `
+		FROM sessions WHERE sid = ?
 	`)
 	if err != nil {
 		return models.Session{}, fmt.Errorf("%s: %w", op, err)
 	}
 	defer stmt.Close()
 
-	var session models.Session
-	err = stmt.QueryRowContext(ctx, token).Scan(&session.ID, &session.AccountID, &session.Token, &session.RefreshToken, &session.UserAgent, &session.IPAddress, &session.ExpiresAt, &session.RefreshExpiresAt, &session.Revoked)
+	session, err := scanSession(stmt.QueryRowContext(ctx, sid))
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return models.Session{}, fmt.Errorf("%s: %w", op, storage.ErrSessionNotFound)
@@ -310,43 +711,476 @@ func (s *Storage) Session(ctx context.Context, token string) (models.Session, er
 	return session, nil
 }
 
-func (s *Storage) SessionByRefreshToken(ctx context.Context, refreshToken string) (models.Session, error) {
+// SessionByRefreshToken returns the session whose refresh token has the given hash.
+func (s *Storage) SessionByRefreshToken(ctx context.Context, refreshTokenHash string) (models.Session, error) {
 	const op = "storage.sqlite.SessionByRefreshToken"
 
+	session, err := s.sessionByRefreshToken(ctx, refreshTokenHash, true)
+	if err != nil {
+		return models.Session{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return session, nil
+}
+
+// SessionByLegacyRefreshToken returns the session saved with the plaintext
+// refresh token before refresh tokens were hashed, if it is not hashed yet.
+func (s *Storage) SessionByLegacyRefreshToken(ctx context.Context, refreshToken string) (models.Session, error) {
+	const op = "storage.sqlite.SessionByLegacyRefreshToken"
+
+	session, err := s.sessionByRefreshToken(ctx, refreshToken, false)
+	if err != nil {
+		return models.Session{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return session, nil
+}
+
+func (s *Storage) sessionByRefreshToken(ctx context.Context, refreshToken string, hashed bool) (models.Session, error) {
 	stmt, err := s.db.Prepare(`
-		SELECT id, account_id, token, refresh_token, user_agent, ip_address, expires_at, refresh_expires_at, revoked 
-		FROM sessions WHERE refresh_token = ?
+		SELECT ` + sessionColumns + `
+		FROM sessions WHERE refresh_token = ? AND refresh_token_hashed = ?
 	`)
 	if err != nil {
-		return models.Session{}, fmt.Errorf("%s: %w", op, err)
+		return models.Session{}, err
 	}
 	defer stmt.Close()
 
-	var session models.Session
-	err = stmt.QueryRowContext(ctx, refreshToken).Scan(&session.ID, &session.AccountID, &session.Token, &session.RefreshToken, &session.UserAgent, &session.IPAddress, &session.ExpiresAt, &session.RefreshExpiresAt, &session.Revoked)
+	session, err := scanSession(stmt.QueryRowContext(ctx, refreshToken, hashed))
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return models.Session{}, fmt.Errorf("%s: %w", op, storage.ErrSessionNotFound)
+			return models.Session{}, storage.ErrSessionNotFound
 		}
-		return models.Session{}, fmt.Errorf("%s: %w", op, err)
+		return models.Session{}, err
 	}
 
 	return session, nil
 }
 
-func (s *Storage) RevokeSession(ctx context.Context, token string) error {
+// HashLegacyRefreshTokens replaces up to limit plaintext refresh tokens, saved
+// before refresh tokens were hashed, with their hash and returns how many it
+// replaced. Each row is marked with refresh_token_hashed in the same update, and
+// rows already marked are never hashed again, so concurrent and repeated runs are
+// safe.
+func (s *Storage) HashLegacyRefreshTokens(ctx context.Context, hash func(refreshToken string) string, limit int) (int64, error) {
+	const op = "storage.sqlite.HashLegacyRefreshTokens"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, "SELECT id, refresh_token FROM sessions WHERE refresh_token_hashed = 0 ORDER BY id LIMIT ?", limit)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	legacy := make(map[int64]string)
+	for rows.Next() {
+		var (
+			id           int64
+			refreshToken string
+		)
+		if err := rows.Scan(&id, &refreshToken); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+		legacy[id] = refreshToken
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var hashed int64
+	for id, refreshToken := range legacy {
+		res, err := tx.ExecContext(ctx, "UPDATE sessions SET refresh_token = ?, refresh_token_hashed = 1 WHERE id = ? AND refresh_token_hashed = 0", hash(refreshToken), id)
+		if err != nil {
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+
+		n, err := res.RowsAffected()
+		if err != nil {
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+		hashed += n
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return hashed, nil
+}
+
+// CountLegacyRefreshTokens returns how many sessions still keep their refresh token
+// in plaintext.
+func (s *Storage) CountLegacyRefreshTokens(ctx context.Context) (int64, error) {
+	const op = "storage.sqlite.CountLegacyRefreshTokens"
+
+	var count int64
+	err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM sessions WHERE refresh_token_hashed = 0").Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return count, nil
+}
+
+// RevokeSession revokes the session with the public session ID sid.
+func (s *Storage) RevokeSession(ctx context.Context, sid string) error {
 	const op = "storage.sqlite.RevokeSession"
 
-	stmt, err := s.db.Prepare("UPDATE sessions SET revoked = 1 WHERE token = ?")
+	stmt, err := s.db.Prepare("UPDATE sessions SET revoked = 1, updated_at = CURRENT_TIMESTAMP WHERE sid = ?")
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer stmt.Close()
+
+	_, err = stmt.ExecContext(ctx, sid)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// RevokeSessionWithReason revokes the session with the public session ID sid,
+// recording reason.
+func (s *Storage) RevokeSessionWithReason(ctx context.Context, sid string, reason models.RevocationReason) error {
+	const op = "storage.sqlite.RevokeSessionWithReason"
+
+	stmt, err := s.db.Prepare(`
+		UPDATE sessions SET revoked = 1, revoked_reason = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE sid = ? AND revoked = 0
+	`)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer stmt.Close()
+
+	_, err = stmt.ExecContext(ctx, reason, sid)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// RevokeRotatedSession revokes the session with the public session ID sid after a
+// refresh replaced it. Only one refresh can rotate a session out: if it was already
+// revoked it returns storage.ErrSessionNotFound.
+func (s *Storage) RevokeRotatedSession(ctx context.Context, sid string) error {
+	const op = "storage.sqlite.RevokeRotatedSession"
+
+	stmt, err := s.db.Prepare(`
+		UPDATE sessions SET revoked = 1, revoked_reason = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE sid = ? AND revoked = 0
+	`)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer stmt.Close()
+
+	res, err := stmt.ExecContext(ctx, models.RevokedRotated, sid)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if n == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrSessionNotFound)
+	}
+
+	return nil
+}
+
+// RevokeSessionFamily revokes every active session of the refresh family
+// familyID, recording reason, and returns how many it revoked.
+func (s *Storage) RevokeSessionFamily(ctx context.Context, familyID string, reason models.RevocationReason) (int64, error) {
+	const op = "storage.sqlite.RevokeSessionFamily"
+
+	stmt, err := s.db.Prepare(`
+		UPDATE sessions SET revoked = 1, revoked_reason = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE family_id = ? AND revoked = 0
+	`)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer stmt.Close()
+
+	res, err := stmt.ExecContext(ctx, reason, familyID)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return n, nil
+}
+
+// RevokeAccountSessions revokes all active sessions of the account except the one
+// with the public session ID exceptSID (pass "" to revoke all), recording reason.
+func (s *Storage) RevokeAccountSessions(ctx context.Context, accountId int64, exceptSID string, reason models.RevocationReason) error {
+	const op = "storage.sqlite.RevokeAccountSessions"
+
+	stmt, err := s.db.Prepare(`
+		UPDATE sessions SET revoked = 1, revoked_reason = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE account_id = ? AND revoked = 0 AND sid != ?
+	`)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer stmt.Close()
+
+	_, err = stmt.ExecContext(ctx, reason, accountId, exceptSID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// TouchSession records that the session with the public session ID sid was used
+// at seenAt.
+func (s *Storage) TouchSession(ctx context.Context, sid string, seenAt time.Time) error {
+	const op = "storage.sqlite.TouchSession"
+
+	stmt, err := s.db.Prepare("UPDATE sessions SET last_seen_at = ? WHERE sid = ?")
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer stmt.Close()
+
+	_, err = stmt.ExecContext(ctx, seenAt, sid)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// ElevateSession lets the active session with the public session ID sid perform
+// sensitive operations until the given time.
+func (s *Storage) ElevateSession(ctx context.Context, sid string, until time.Time) error {
+	const op = "storage.sqlite.ElevateSession"
+
+	stmt, err := s.db.Prepare("UPDATE sessions SET elevated_until = ? WHERE sid = ? AND revoked = 0")
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer stmt.Close()
+
+	res, err := stmt.ExecContext(ctx, until, sid)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrSessionNotFound)
+	}
+
+	return nil
+}
+
+// DeleteExpiredSessions deletes up to limit sessions whose refresh token expired
+// before the given time and returns how many were deleted.
+func (s *Storage) DeleteExpiredSessions(ctx context.Context, before time.Time, limit int) (int64, error) {
+	const op = "storage.sqlite.DeleteExpiredSessions"
+
+	stmt, err := s.db.Prepare(`
+		DELETE FROM sessions WHERE id IN (
+			SELECT id FROM sessions WHERE refresh_expires_at < ? ORDER BY id LIMIT ?
+		)
+	`)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer stmt.Close()
+
+	res, err := stmt.ExecContext(ctx, before, limit)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	deleted, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return deleted, nil
+}
+
+// RevokeIdleSessions revokes up to limit active sessions last seen, or created if
+// never seen since, before the given time with RevokedSessionIdle and returns how
+// many were revoked.
+func (s *Storage) RevokeIdleSessions(ctx context.Context, before time.Time, limit int) (int64, error) {
+	const op = "storage.sqlite.RevokeIdleSessions"
+
+	// created_at is a CURRENT_TIMESTAMP string and last_seen_at carries a zone
+	// offset, so both are compared through datetime, in UTC.
+	stmt, err := s.db.Prepare(`
+		UPDATE sessions SET revoked = 1, revoked_reason = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id IN (
+			SELECT id FROM sessions
+			WHERE revoked = 0 AND datetime(COALESCE(last_seen_at, created_at)) < datetime(?)
+			ORDER BY id LIMIT ?
+		)
+	`)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer stmt.Close()
+
+	res, err := stmt.ExecContext(ctx, models.RevokedSessionIdle, before.UTC(), limit)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	revoked, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return revoked, nil
+}
+
+// DeleteRevokedSessions deletes up to limit revoked sessions last updated, which for
+// a revoked session is when it was revoked, before the given time and returns how
+// many were deleted.
+func (s *Storage) DeleteRevokedSessions(ctx context.Context, before time.Time, limit int) (int64, error) {
+	const op = "storage.sqlite.DeleteRevokedSessions"
+
+	// updated_at is set by CURRENT_TIMESTAMP, which is UTC.
+	stmt, err := s.db.Prepare(`
+		DELETE FROM sessions WHERE id IN (
+			SELECT id FROM sessions WHERE revoked = 1 AND updated_at < ? ORDER BY id LIMIT ?
+		)
+	`)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer stmt.Close()
+
+	res, err := stmt.ExecContext(ctx, before.UTC(), limit)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	deleted, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return deleted, nil
+}
+
+// IncrementAppTokenVersion bumps the app's token version, invalidating every token
+// issued for the app before.
+func (s *Storage) IncrementAppTokenVersion(ctx context.Context, appId int32) error {
+	const op = "storage.sqlite.IncrementAppTokenVersion"
+
+	stmt, err := s.db.Prepare("UPDATE apps SET token_version = token_version + 1 WHERE id = ?")
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
 	defer stmt.Close()
 
-	_, err = stmt.ExecContext(ctx, token)
+	res, err := stmt.ExecContext(ctx, appId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrAppNotFound)
+	}
+
+	return nil
+}
+
+// RevokeAppSessions revokes all active sessions issued for the app, recording reason,
+// and returns how many were revoked. Sessions from before per-app memberships count
+// toward the app their account registered with.
+func (s *Storage) RevokeAppSessions(ctx context.Context, appId int32, reason models.RevocationReason) (int64, error) {
+	const op = "storage.sqlite.RevokeAppSessions"
+
+	stmt, err := s.db.Prepare(`
+		UPDATE sessions SET revoked = 1, revoked_reason = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE revoked = 0 AND (
+			app_id = ? OR (app_id IS NULL AND account_id IN (SELECT id FROM accounts WHERE app_id = ?))
+		)
+	`)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer stmt.Close()
+
+	res, err := stmt.ExecContext(ctx, reason, appId, appId)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	revoked, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return revoked, nil
+}
+
+// DeleteAccount removes the account together with everything stored about it:
+// memberships, sessions, security factors, codes, password history, devices and
+// profile. Audit log entries are kept; they are immutable.
+func (s *Storage) DeleteAccount(ctx context.Context, accountId int64) error {
+	const op = "storage.sqlite.DeleteAccount"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	for _, query := range []string{
+		"DELETE FROM app_memberships WHERE account_id = ?",
+		"DELETE FROM sessions WHERE account_id = ?",
+		"DELETE FROM session_quotas WHERE account_id = ?",
+		"DELETE FROM sso_tickets WHERE account_id = ?",
+		"DELETE FROM one_time_codes WHERE account_id = ?",
+		"DELETE FROM totp_secrets WHERE account_id = ?",
+		"DELETE FROM recovery_codes WHERE account_id = ?",
+		"DELETE FROM passkeys WHERE account_id = ?",
+		"DELETE FROM trusted_devices WHERE account_id = ?",
+		"DELETE FROM password_history WHERE account_id = ?",
+		"DELETE FROM device_names WHERE account_id = ?",
+		"DELETE FROM known_devices WHERE account_id = ?",
+		"DELETE FROM device_revoke_tokens WHERE account_id = ?",
+		"DELETE FROM account_profiles WHERE account_id = ?",
+		"DELETE FROM email_changes WHERE account_id = ?",
+		"DELETE FROM accounts WHERE id = ?",
+	} {
+		if _, err := tx.ExecContext(ctx, query, accountId); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
 
 	return nil
 }
@@ -0,0 +1,124 @@
+package sqlite_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"sso/internal/domain/models"
+	"sso/internal/storage"
+	"sso/internal/storage/sqlite/sqlitetest"
+)
+
+type testAccount struct {
+	email      string
+	externalID string
+	username   string
+	phone      string
+}
+
+func TestSaveAccountDuplicates(t *testing.T) {
+	tests := []struct {
+		name    string
+		first   testAccount
+		second  testAccount
+		wantErr error
+	}{
+		{
+			name:    "email",
+			first:   testAccount{email: "a@example.com"},
+			second:  testAccount{email: "a@example.com"},
+			wantErr: storage.ErrAccountExists,
+		},
+		{
+			name:    "external id",
+			first:   testAccount{email: "a@example.com", externalID: "ext-1"},
+			second:  testAccount{email: "b@example.com", externalID: "ext-1"},
+			wantErr: storage.ErrExternalIDExists,
+		},
+		{
+			name:    "username in the same app",
+			first:   testAccount{email: "a@example.com", username: "alice"},
+			second:  testAccount{email: "b@example.com", username: "alice"},
+			wantErr: storage.ErrUsernameExists,
+		},
+		{
+			name:    "phone in the same app",
+			first:   testAccount{email: "a@example.com", phone: "+15550100"},
+			second:  testAccount{email: "b@example.com", phone: "+15550100"},
+			wantErr: storage.ErrPhoneExists,
+		},
+		{
+			name:   "distinct",
+			first:  testAccount{email: "a@example.com", externalID: "ext-1"},
+			second: testAccount{email: "b@example.com", externalID: "ext-2"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			s := sqlitetest.New(t, nil)
+
+			appID, err := s.SaveApp(ctx, "app", "secret", "")
+			if err != nil {
+				t.Fatalf("save app: %v", err)
+			}
+
+			save := func(a testAccount) error {
+				_, err := s.SaveAccount(ctx, a.email, []byte("hash"), models.USER, models.ACTIVE, int32(appID), a.externalID, a.username, a.phone)
+				return err
+			}
+
+			if err := save(tt.first); err != nil {
+				t.Fatalf("save first account: %v", err)
+			}
+
+			err = save(tt.second)
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Fatalf("save second account: %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("save second account: got %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSetPhoneDuplicate(t *testing.T) {
+	ctx := context.Background()
+	s := sqlitetest.New(t, nil)
+
+	appID, err := s.SaveApp(ctx, "app", "secret", "")
+	if err != nil {
+		t.Fatalf("save app: %v", err)
+	}
+
+	if _, err := s.SaveAccount(ctx, "a@example.com", []byte("hash"), models.USER, models.ACTIVE, int32(appID), "", "", "+15550100"); err != nil {
+		t.Fatalf("save first account: %v", err)
+	}
+	id, err := s.SaveAccount(ctx, "b@example.com", []byte("hash"), models.USER, models.ACTIVE, int32(appID), "", "", "")
+	if err != nil {
+		t.Fatalf("save second account: %v", err)
+	}
+
+	if err := s.SetPhone(ctx, id, "+15550100"); !errors.Is(err, storage.ErrPhoneExists) {
+		t.Fatalf("set taken phone: got %v, want %v", err, storage.ErrPhoneExists)
+	}
+}
+
+func TestSaveAppDuplicate(t *testing.T) {
+	ctx := context.Background()
+	s := sqlitetest.New(t, nil)
+
+	if _, err := s.SaveApp(ctx, "app", "secret", ""); err != nil {
+		t.Fatalf("save app: %v", err)
+	}
+
+	if _, err := s.SaveApp(ctx, "app", "other-secret", ""); !errors.Is(err, storage.ErrAppExists) {
+		t.Fatalf("save duplicate app: got %v, want %v", err, storage.ErrAppExists)
+	}
+}
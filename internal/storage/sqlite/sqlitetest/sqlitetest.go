@@ -0,0 +1,67 @@
+// Package sqlitetest opens migrated SQLite storage for tests.
+package sqlitetest
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"sso/internal/lib/encryption"
+	"sso/internal/storage/sqlite"
+
+	"github.com/golang-migrate/migrate/v4"
+	_ "github.com/golang-migrate/migrate/v4/database/sqlite3"
+	_ "github.com/golang-migrate/migrate/v4/source/file"
+)
+
+// New returns storage in a fresh database under t's temporary directory with
+// every migration applied. A non-nil keyring encrypts sensitive columns.
+func New(t testing.TB, keyring *encryption.Keyring) *sqlite.Storage {
+	t.Helper()
+
+	storage, _ := NewWithDB(t, keyring)
+
+	return storage
+}
+
+// NewWithDB is New that also returns a separate connection to the same database,
+// for setting up rows the storage no longer writes, such as legacy data. The
+// connection is closed when the test ends.
+func NewWithDB(t testing.TB, keyring *encryption.Keyring) (*sqlite.Storage, *sql.DB) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "sso.db")
+
+	m, err := migrate.New("file://"+migrationsPath(), fmt.Sprintf("sqlite3://%s?x-migrations-table=migrations", path))
+	if err != nil {
+		t.Fatalf("open migrations: %v", err)
+	}
+	if err := m.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		t.Fatalf("migrate: %v", err)
+	}
+	if srcErr, dbErr := m.Close(); srcErr != nil || dbErr != nil {
+		t.Fatalf("close migrations: %v, %v", srcErr, dbErr)
+	}
+
+	storage, err := sqlite.New(path, keyring)
+	if err != nil {
+		t.Fatalf("open storage: %v", err)
+	}
+
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	return storage, db
+}
+
+// migrationsPath is the repository's migrations directory.
+func migrationsPath() string {
+	_, file, _, _ := runtime.Caller(0)
+	return filepath.Join(filepath.Dir(file), "..", "..", "..", "..", "migrations")
+}
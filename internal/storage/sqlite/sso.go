@@ -0,0 +1,76 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sso/internal/domain/models"
+	"sso/internal/storage"
+	"time"
+)
+
+// SaveSSOTicket stores the hash of a new SSO ticket.
+func (s *Storage) SaveSSOTicket(ctx context.Context, ticketHash string, ticket models.SSOTicket) error {
+	const op = "storage.sqlite.SaveSSOTicket"
+
+	stmt, err := s.db.Prepare(`
+		INSERT INTO sso_tickets (ticket_hash, account_id, source_app_id, target_app_id, expires_at)
+		VALUES (?, ?, ?, ?, ?)
+	`)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer stmt.Close()
+
+	_, err = stmt.ExecContext(ctx, ticketHash, ticket.AccountID, ticket.SourceAppID, ticket.TargetAppID, ticket.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// ConsumeSSOTicket marks an unexpired ticket used and returns it, in one statement so
+// a ticket can be redeemed only once. Unknown, expired and used tickets all return
+// storage.ErrCodeNotFound.
+func (s *Storage) ConsumeSSOTicket(ctx context.Context, ticketHash string, now time.Time) (models.SSOTicket, error) {
+	const op = "storage.sqlite.ConsumeSSOTicket"
+
+	var ticket models.SSOTicket
+	err := s.db.QueryRowContext(ctx, `
+		UPDATE sso_tickets SET used_at = ?
+		WHERE ticket_hash = ? AND used_at IS NULL AND expires_at > ?
+		RETURNING account_id, source_app_id, target_app_id, expires_at
+	`, now, ticketHash, now).Scan(&ticket.AccountID, &ticket.SourceAppID, &ticket.TargetAppID, &ticket.ExpiresAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.SSOTicket{}, fmt.Errorf("%s: %w", op, storage.ErrCodeNotFound)
+		}
+		return models.SSOTicket{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return ticket, nil
+}
+
+// DeleteExpiredSSOTickets deletes up to limit tickets, redeemed or not, that expired
+// before the given time and returns how many were deleted.
+func (s *Storage) DeleteExpiredSSOTickets(ctx context.Context, before time.Time, limit int) (int64, error) {
+	const op = "storage.sqlite.DeleteExpiredSSOTickets"
+
+	res, err := s.db.ExecContext(ctx, `
+		DELETE FROM sso_tickets WHERE ticket_hash IN (
+			SELECT ticket_hash FROM sso_tickets WHERE expires_at < ? LIMIT ?
+		)
+	`, before, limit)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	deleted, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return deleted, nil
+}
@@ -0,0 +1,60 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/mattn/go-sqlite3"
+
+	"sso/internal/storage"
+)
+
+// SetUsername sets the account's username, or removes it if username is empty. It
+// returns storage.ErrUsernameExists if another account of the same app has it.
+func (s *Storage) SetUsername(ctx context.Context, accountId int64, username string) error {
+	const op = "storage.sqlite.SetUsername"
+
+	res, err := s.db.ExecContext(ctx, `
+		UPDATE accounts SET username = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?
+	`, sql.NullString{String: username, Valid: username != ""}, accountId)
+	if err != nil {
+		var sqliteErr sqlite3.Error
+		if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
+			return fmt.Errorf("%s: %w", op, storage.ErrUsernameExists)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrAccountNotFound)
+	}
+
+	return nil
+}
+
+// SetAppUsernameRequired sets whether accounts registering with the app must pick a
+// username.
+func (s *Storage) SetAppUsernameRequired(ctx context.Context, appId int32, required bool) error {
+	const op = "storage.sqlite.SetAppUsernameRequired"
+
+	res, err := s.db.ExecContext(ctx, "UPDATE apps SET require_username = ? WHERE id = ?", required, appId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrAppNotFound)
+	}
+
+	return nil
+}
@@ -3,9 +3,32 @@ package storage
 import "errors"
 
 var (
-	ErrAccountExists   = errors.New("account already exists")
-	ErrAccountNotFound = errors.New("account not found")
-	ErrAppNotFound     = errors.New("account not found")
-	ErrAppExists       = errors.New("app already exists")
-	ErrSessionNotFound = errors.New("session not found")
+	ErrAccountExists    = errors.New("account already exists")
+	ErrAccountNotFound  = errors.New("account not found")
+	ErrExternalIDExists = errors.New("external id already linked to another account")
+	ErrUsernameExists   = errors.New("username already taken")
+	ErrPhoneExists      = errors.New("phone number already linked to another account")
+	ErrAppNotFound      = errors.New("app not found")
+	ErrAppExists        = errors.New("app already exists")
+	ErrSessionNotFound  = errors.New("session not found")
+	ErrSessionExists    = errors.New("session already exists")
+
+	ErrMembershipNotFound = errors.New("app membership not found")
+
+	ErrProfileNotFound = errors.New("profile not found")
+
+	ErrEmailChangeNotFound = errors.New("no pending email change")
+
+	ErrInviteNotFound = errors.New("invite not found")
+
+	ErrTOTPNotEnrolled         = errors.New("totp not enrolled")
+	ErrTOTPNotPending          = errors.New("no pending totp secret")
+	ErrEncryptionNotConfigured = errors.New("encryption is not configured")
+
+	ErrCodeNotFound = errors.New("code not found")
+	ErrCodeUsed     = errors.New("code already used")
+
+	ErrPasskeyNotFound     = errors.New("passkey not found")
+	ErrPasskeyExists       = errors.New("passkey already registered")
+	ErrPasskeyCounterStale = errors.New("passkey sign count already advanced")
 )
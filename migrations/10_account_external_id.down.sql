@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_accounts_external_id;
+
+ALTER TABLE accounts DROP COLUMN external_id;
@@ -0,0 +1,3 @@
+ALTER TABLE accounts ADD COLUMN external_id TEXT;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_accounts_external_id ON accounts (external_id);
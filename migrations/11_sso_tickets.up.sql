@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS sso_tickets
+(
+    ticket_hash   TEXT PRIMARY KEY,
+    account_id    INTEGER NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    source_app_id INTEGER NOT NULL REFERENCES apps(id) ON DELETE CASCADE,
+    target_app_id INTEGER NOT NULL REFERENCES apps(id) ON DELETE CASCADE,
+    expires_at    TIMESTAMP NOT NULL,
+    used_at       TIMESTAMP,
+    created_at    TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
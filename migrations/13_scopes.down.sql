@@ -0,0 +1,3 @@
+ALTER TABLE sessions DROP COLUMN scopes;
+
+ALTER TABLE app_memberships DROP COLUMN scopes;
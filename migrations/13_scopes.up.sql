@@ -0,0 +1,4 @@
+-- Space-separated scope lists. NULL session scopes mean the session isn't narrowed.
+ALTER TABLE app_memberships ADD COLUMN scopes TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE sessions ADD COLUMN scopes TEXT;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_sessions_sid;
+
+ALTER TABLE sessions DROP COLUMN sid;
@@ -0,0 +1,3 @@
+ALTER TABLE sessions ADD COLUMN sid TEXT;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_sessions_sid ON sessions (sid);
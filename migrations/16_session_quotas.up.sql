@@ -0,0 +1,8 @@
+-- Per-account session limits, typically set from the account's subscription plan.
+-- Accounts without a row use the configured default.
+CREATE TABLE IF NOT EXISTS session_quotas
+(
+    account_id   INTEGER PRIMARY KEY REFERENCES accounts(id) ON DELETE CASCADE,
+    max_sessions INTEGER NOT NULL,
+    updated_at   TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,26 @@
+-- Append-only audit log. No foreign key to accounts, so entries outlive the
+-- accounts they describe; the triggers reject any change to written entries.
+CREATE TABLE IF NOT EXISTS audit_log
+(
+    id          INTEGER PRIMARY KEY,
+    event_type  TEXT NOT NULL,
+    account_id  INTEGER,
+    actor_id    INTEGER,
+    ip_address  TEXT,
+    payload     TEXT NOT NULL,
+    occurred_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_log_account_id ON audit_log (account_id);
+CREATE INDEX IF NOT EXISTS idx_audit_log_event_type ON audit_log (event_type);
+CREATE INDEX IF NOT EXISTS idx_audit_log_occurred_at ON audit_log (occurred_at);
+
+CREATE TRIGGER IF NOT EXISTS audit_log_no_update BEFORE UPDATE ON audit_log
+BEGIN
+    SELECT RAISE(ABORT, 'audit log entries are immutable');
+END;
+
+CREATE TRIGGER IF NOT EXISTS audit_log_no_delete BEFORE DELETE ON audit_log
+BEGIN
+    SELECT RAISE(ABORT, 'audit log entries are immutable');
+END;
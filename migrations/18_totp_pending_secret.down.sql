@@ -0,0 +1 @@
+ALTER TABLE totp_secrets DROP COLUMN pending_secret;
@@ -0,0 +1,3 @@
+-- A replacement secret waiting for its first code. The current secret stays in
+-- force until the pending one is confirmed.
+ALTER TABLE totp_secrets ADD COLUMN pending_secret BLOB;
@@ -0,0 +1,2 @@
+-- When the session was last validated. NULL means not since it was created.
+ALTER TABLE sessions ADD COLUMN last_seen_at TIMESTAMP;
@@ -0,0 +1,9 @@
+-- Failed-login records shared by all instances when lockout uses storage.
+CREATE TABLE IF NOT EXISTS login_lockouts
+(
+    key          TEXT PRIMARY KEY,
+    failures     INTEGER NOT NULL,
+    last_failure TIMESTAMP NOT NULL,
+    locked_until TIMESTAMP,
+    reset_at     TIMESTAMP
+);
@@ -0,0 +1,2 @@
+ALTER TABLE apps DROP COLUMN rp_origins;
+ALTER TABLE apps DROP COLUMN rp_id;
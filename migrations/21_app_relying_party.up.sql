@@ -0,0 +1,4 @@
+-- WebAuthn relying party of the app. Passkeys are disabled while rp_id is empty;
+-- rp_origins is a space-separated list of allowed origins.
+ALTER TABLE apps ADD COLUMN rp_id TEXT NOT NULL DEFAULT '';
+ALTER TABLE apps ADD COLUMN rp_origins TEXT NOT NULL DEFAULT '';
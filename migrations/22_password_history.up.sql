@@ -0,0 +1,10 @@
+-- Hashes of passwords an account used before, for refusing their reuse.
+CREATE TABLE IF NOT EXISTS password_history
+(
+    id         INTEGER PRIMARY KEY,
+    account_id INTEGER NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    pass_hash  BLOB NOT NULL,
+    retired_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_password_history_account_id ON password_history (account_id, id);
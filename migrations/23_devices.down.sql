@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS device_names;
+
+DROP INDEX IF EXISTS idx_sessions_device_id;
+
+ALTER TABLE sessions DROP COLUMN browser;
+ALTER TABLE sessions DROP COLUMN os;
+ALTER TABLE sessions DROP COLUMN device_id;
@@ -0,0 +1,17 @@
+-- Device the session was created from, parsed from its user agent. NULL for
+-- sessions from before device tracking.
+ALTER TABLE sessions ADD COLUMN device_id TEXT;
+ALTER TABLE sessions ADD COLUMN os TEXT;
+ALTER TABLE sessions ADD COLUMN browser TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_sessions_device_id ON sessions (account_id, device_id);
+
+-- Names users gave their devices.
+CREATE TABLE IF NOT EXISTS device_names
+(
+    account_id INTEGER NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    device_id  TEXT NOT NULL,
+    name       TEXT NOT NULL,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (account_id, device_id)
+);
@@ -0,0 +1,22 @@
+-- Device and IP combinations each account has signed in from, for spotting logins
+-- from new ones.
+CREATE TABLE IF NOT EXISTS known_devices
+(
+    account_id    INTEGER NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    device_id     TEXT NOT NULL,
+    ip_address    TEXT NOT NULL,
+    first_seen_at TIMESTAMP NOT NULL,
+    last_seen_at  TIMESTAMP NOT NULL,
+    PRIMARY KEY (account_id, device_id, ip_address)
+);
+
+-- Hashes of the one-click tokens in new-device notifications that sign the device out.
+CREATE TABLE IF NOT EXISTS device_revoke_tokens
+(
+    token_hash TEXT PRIMARY KEY,
+    account_id INTEGER NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    device_id  TEXT NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    used_at    TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
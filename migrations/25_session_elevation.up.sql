@@ -0,0 +1,3 @@
+-- Until when the session may perform sensitive operations after the account
+-- holder re-entered their credentials. NULL if never elevated.
+ALTER TABLE sessions ADD COLUMN elevated_until TIMESTAMP;
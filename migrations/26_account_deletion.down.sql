@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_accounts_deleted_at;
+
+ALTER TABLE accounts DROP COLUMN deleted_status;
+ALTER TABLE accounts DROP COLUMN deleted_email;
+ALTER TABLE accounts DROP COLUMN deleted_at;
@@ -0,0 +1,7 @@
+-- Soft-deleted accounts keep their original email and status for the grace period,
+-- so an admin can restore them, until the purge job deletes them for good.
+ALTER TABLE accounts ADD COLUMN deleted_at TIMESTAMP;
+ALTER TABLE accounts ADD COLUMN deleted_email TEXT;
+ALTER TABLE accounts ADD COLUMN deleted_status INTEGER;
+
+CREATE INDEX IF NOT EXISTS idx_accounts_deleted_at ON accounts (deleted_at);
@@ -0,0 +1,5 @@
+ALTER TABLE apps DROP COLUMN require_username;
+
+DROP INDEX IF EXISTS idx_accounts_username_app_id;
+
+ALTER TABLE accounts DROP COLUMN username;
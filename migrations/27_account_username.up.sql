@@ -0,0 +1,9 @@
+-- Optional username accounts can log in with instead of their email. Like emails,
+-- usernames are unique per app in the table and globally unless identifiers are
+-- per app, which the service enforces.
+ALTER TABLE accounts ADD COLUMN username TEXT;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_accounts_username_app_id ON accounts (username, app_id);
+
+-- Whether accounts registering with the app must pick a username.
+ALTER TABLE apps ADD COLUMN require_username INTEGER NOT NULL DEFAULT 0;
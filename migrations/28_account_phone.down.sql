@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_accounts_phone_app_id;
+
+ALTER TABLE accounts DROP COLUMN phone;
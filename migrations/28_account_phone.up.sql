@@ -0,0 +1,5 @@
+-- Optional phone number in E.164 form, to log in with by SMS code. Unique like
+-- emails and usernames.
+ALTER TABLE accounts ADD COLUMN phone TEXT;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_accounts_phone_app_id ON accounts (phone, app_id);
@@ -0,0 +1,15 @@
+-- Personal data of an account, kept apart from the auth fields in accounts. Rows are
+-- created on the first profile update.
+CREATE TABLE IF NOT EXISTS account_profiles
+(
+    account_id   INTEGER PRIMARY KEY REFERENCES accounts(id) ON DELETE CASCADE,
+    display_name TEXT NOT NULL DEFAULT '',
+    given_name   TEXT NOT NULL DEFAULT '',
+    family_name  TEXT NOT NULL DEFAULT '',
+    avatar_url   TEXT NOT NULL DEFAULT '',
+    locale       TEXT NOT NULL DEFAULT '',
+    timezone     TEXT NOT NULL DEFAULT '',
+    -- Free-form JSON object set by clients, NULL if unset.
+    metadata     TEXT,
+    updated_at   TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS trusted_devices;
+DROP TABLE IF EXISTS recovery_codes;
+DROP TABLE IF EXISTS passkeys;
+DROP TABLE IF EXISTS totp_secrets;
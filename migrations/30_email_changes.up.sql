@@ -0,0 +1,8 @@
+-- Addresses accounts asked to change their email to, until the change is confirmed
+-- with the token sent there. The account keeps its current email meanwhile.
+CREATE TABLE IF NOT EXISTS email_changes
+(
+    account_id INTEGER PRIMARY KEY REFERENCES accounts(id) ON DELETE CASCADE,
+    new_email  TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_invites_app_id;
+
+DROP TABLE IF EXISTS invites;
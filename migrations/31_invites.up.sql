@@ -0,0 +1,19 @@
+-- Invitations for an email to register with an app in a given role. Only a hash of
+-- the invite token is stored. An invite is pending until it is accepted, revoked
+-- or expires.
+CREATE TABLE IF NOT EXISTS invites
+(
+    id          INTEGER PRIMARY KEY AUTOINCREMENT,
+    token_hash  TEXT NOT NULL UNIQUE,
+    email       TEXT NOT NULL,
+    role        INTEGER NOT NULL, -- AccountRoles (0 - USER, 1 - ADMIN)
+    app_id      INTEGER NOT NULL REFERENCES apps(id) ON DELETE CASCADE,
+    created_by  INTEGER REFERENCES accounts(id) ON DELETE SET NULL,
+    expires_at  TIMESTAMP NOT NULL,
+    created_at  TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    accepted_at TIMESTAMP,
+    account_id  INTEGER REFERENCES accounts(id) ON DELETE SET NULL,
+    revoked_at  TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_invites_app_id ON invites (app_id);
@@ -0,0 +1,3 @@
+-- Whether accounts may register with the app on their own. Apps that don't allow it
+-- are invite-only.
+ALTER TABLE apps ADD COLUMN self_registration INTEGER NOT NULL DEFAULT 1;
@@ -0,0 +1 @@
+ALTER TABLE accounts DROP COLUMN requires_password_change;
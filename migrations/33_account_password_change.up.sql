@@ -0,0 +1,3 @@
+-- Accounts that must change their password before their tokens are good for
+-- anything else, e.g. after an admin set a temporary one.
+ALTER TABLE accounts ADD COLUMN requires_password_change INTEGER NOT NULL DEFAULT 0;
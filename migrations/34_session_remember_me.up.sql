@@ -0,0 +1,3 @@
+-- Sessions from logins that asked to be remembered, which get the long refresh
+-- token TTL on every rotation.
+ALTER TABLE sessions ADD COLUMN remember_me INTEGER NOT NULL DEFAULT 0;
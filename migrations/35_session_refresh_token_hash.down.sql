@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_sessions_refresh_token;
+
+ALTER TABLE sessions DROP COLUMN refresh_token_hashed;
@@ -0,0 +1,5 @@
+-- Refresh tokens are stored as SHA-256 hashes. Sessions saved before keep their
+-- plaintext token, with refresh_token_hashed 0, until session cleanup hashes it.
+ALTER TABLE sessions ADD COLUMN refresh_token_hashed INTEGER NOT NULL DEFAULT 0;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_sessions_refresh_token ON sessions (refresh_token);
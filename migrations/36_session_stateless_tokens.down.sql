@@ -0,0 +1,7 @@
+-- The access tokens are gone; sessions get their sid as a placeholder token, which
+-- no client holds, so they can only be refreshed.
+ALTER TABLE sessions ADD COLUMN token TEXT;
+
+UPDATE sessions SET token = sid;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_sessions_token ON sessions (token);
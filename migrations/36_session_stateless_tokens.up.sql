@@ -0,0 +1,42 @@
+-- Access tokens are no longer stored: they carry the sid of their session and are
+-- verified by signature, the session row only being consulted for revocation. The
+-- table is rebuilt to drop the token column, which SQLite can't drop while it is
+-- UNIQUE. Sessions from before SIDs get one; their access tokens have no sid claim,
+-- stop validating and are replaced on the next refresh.
+CREATE TABLE IF NOT EXISTS sessions_new
+(
+    id                   INTEGER PRIMARY KEY,
+    sid                  TEXT NOT NULL,
+    account_id           INTEGER NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    app_id               INTEGER REFERENCES apps(id),
+    refresh_token        TEXT NOT NULL,
+    refresh_token_hashed INTEGER NOT NULL DEFAULT 0,
+    user_agent           TEXT,
+    ip_address           TEXT,
+    device_id            TEXT,
+    os                   TEXT,
+    browser              TEXT,
+    expires_at           TIMESTAMP NOT NULL,
+    refresh_expires_at   TIMESTAMP NOT NULL,
+    family_started_at    TIMESTAMP,
+    scopes               TEXT,
+    remember_me          INTEGER NOT NULL DEFAULT 0,
+    last_seen_at         TIMESTAMP,
+    elevated_until       TIMESTAMP,
+    created_at           TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at           TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    revoked              BOOLEAN NOT NULL DEFAULT FALSE,
+    revoked_reason       TEXT
+);
+
+INSERT INTO sessions_new (id, sid, account_id, app_id, refresh_token, refresh_token_hashed, user_agent, ip_address, device_id, os, browser, expires_at, refresh_expires_at, family_started_at, scopes, remember_me, last_seen_at, elevated_until, created_at, updated_at, revoked, revoked_reason)
+SELECT id, COALESCE(sid, lower(hex(randomblob(16)))), account_id, app_id, refresh_token, refresh_token_hashed, user_agent, ip_address, device_id, os, browser, expires_at, refresh_expires_at, family_started_at, scopes, remember_me, last_seen_at, elevated_until, created_at, updated_at, revoked, revoked_reason FROM sessions;
+
+DROP TABLE sessions;
+
+ALTER TABLE sessions_new RENAME TO sessions;
+
+CREATE INDEX IF NOT EXISTS idx_account_id ON sessions (account_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_sessions_sid ON sessions (sid);
+CREATE INDEX IF NOT EXISTS idx_sessions_device_id ON sessions (account_id, device_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_sessions_refresh_token ON sessions (refresh_token);
@@ -0,0 +1,4 @@
+-- The algorithm tokens of the app are signed with. HS256 signs with the app secret;
+-- the asymmetric algorithms with the app's private key, stored encrypted as PKCS #8.
+ALTER TABLE apps ADD COLUMN signing_alg TEXT NOT NULL DEFAULT 'HS256';
+ALTER TABLE apps ADD COLUMN signing_key BLOB;
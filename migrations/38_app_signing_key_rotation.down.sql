@@ -0,0 +1,9 @@
+ALTER TABLE apps ADD COLUMN signing_key BLOB;
+
+UPDATE apps SET signing_key = (
+    SELECT private_key FROM app_signing_keys
+    WHERE app_signing_keys.app_id = apps.id AND retires_at IS NULL
+    ORDER BY created_at DESC LIMIT 1
+);
+
+DROP TABLE IF EXISTS app_signing_keys;
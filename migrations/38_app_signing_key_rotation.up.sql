@@ -0,0 +1,21 @@
+-- Apps keep their previous signing keys for a while after a rotation, so tokens
+-- signed with them still verify. Tokens name their key by kid. The current key of
+-- an app is the one that doesn't retire; retired keys verify until retires_at.
+CREATE TABLE IF NOT EXISTS app_signing_keys
+(
+    id          INTEGER PRIMARY KEY,
+    app_id      INTEGER NOT NULL REFERENCES apps(id) ON DELETE CASCADE,
+    kid         TEXT NOT NULL UNIQUE,
+    alg         TEXT NOT NULL,
+    private_key BLOB NOT NULL,
+    created_at  TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    retires_at  TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_app_signing_keys_app_id ON app_signing_keys (app_id);
+
+INSERT INTO app_signing_keys (app_id, kid, alg, private_key)
+SELECT id, lower(hex(randomblob(8))), signing_alg, signing_key
+FROM apps WHERE signing_alg != 'HS256' AND length(signing_key) > 0;
+
+ALTER TABLE apps DROP COLUMN signing_key;
@@ -0,0 +1,2 @@
+-- The aud claim of the app's tokens; empty uses the app ID.
+ALTER TABLE apps ADD COLUMN audience TEXT NOT NULL DEFAULT '';
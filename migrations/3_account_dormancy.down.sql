@@ -0,0 +1,22 @@
+CREATE TABLE IF NOT EXISTS accounts_old
+(
+    id           INTEGER PRIMARY KEY,
+    created_at   TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at   TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    email        TEXT NOT NULL UNIQUE,
+    pass_hash    BYTEA NOT NULL,
+    status       INTEGER NOT NULL, -- AccountStatus (0 - ACTIVE, 1 - INACTIVE, 2 - DELETED)
+    app_id       BIGINT REFERENCES apps(id),
+    role         INTEGER NOT NULL, -- AccountRoles (0 - USER, 1 - ADMIN)
+    CONSTRAINT valid_status CHECK (status IN (0, 1, 2))
+);
+
+-- Dormant accounts fall back to INACTIVE.
+INSERT INTO accounts_old (id, created_at, updated_at, email, pass_hash, status, app_id, role)
+SELECT id, created_at, updated_at, email, pass_hash, CASE WHEN status = 3 THEN 1 ELSE status END, app_id, role FROM accounts;
+
+DROP TABLE accounts;
+
+ALTER TABLE accounts_old RENAME TO accounts;
+
+CREATE INDEX IF NOT EXISTS idx_email ON accounts (email);
@@ -0,0 +1,25 @@
+-- SQLite can't alter a CHECK constraint in place, so the accounts table is rebuilt
+-- to allow the DORMANT status (3) and to track the last successful login.
+CREATE TABLE IF NOT EXISTS accounts_new
+(
+    id            INTEGER PRIMARY KEY,
+    created_at    TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at    TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    email         TEXT NOT NULL UNIQUE,
+    pass_hash     BYTEA NOT NULL,
+    status        INTEGER NOT NULL, -- AccountStatus (0 - ACTIVE, 1 - INACTIVE, 2 - DELETED, 3 - DORMANT)
+    app_id        BIGINT REFERENCES apps(id),
+    role          INTEGER NOT NULL, -- AccountRoles (0 - USER, 1 - ADMIN)
+    last_login_at TIMESTAMP,
+    CONSTRAINT valid_status CHECK (status IN (0, 1, 2, 3))
+);
+
+INSERT INTO accounts_new (id, created_at, updated_at, email, pass_hash, status, app_id, role)
+SELECT id, created_at, updated_at, email, pass_hash, status, app_id, role FROM accounts;
+
+DROP TABLE accounts;
+
+ALTER TABLE accounts_new RENAME TO accounts;
+
+CREATE INDEX IF NOT EXISTS idx_email ON accounts (email);
+CREATE INDEX IF NOT EXISTS idx_accounts_last_login_at ON accounts (last_login_at);
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_revoked_tokens_expires_at;
+DROP TABLE IF EXISTS revoked_tokens;
+
+ALTER TABLE sessions DROP COLUMN access_expires_at;
+ALTER TABLE sessions DROP COLUMN access_jti;
@@ -0,0 +1,16 @@
+-- The jti and expiry of the access token last issued for each session, so the
+-- token can be put on the revocation list when the session is revoked.
+ALTER TABLE sessions ADD COLUMN access_jti TEXT;
+ALTER TABLE sessions ADD COLUMN access_expires_at TIMESTAMP;
+
+-- Access tokens revoked before their expiry, by jti. Rows are only needed until
+-- the token expires.
+CREATE TABLE IF NOT EXISTS revoked_tokens
+(
+    jti        TEXT PRIMARY KEY,
+    reason     TEXT,
+    expires_at TIMESTAMP NOT NULL,
+    revoked_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_revoked_tokens_expires_at ON revoked_tokens (expires_at);
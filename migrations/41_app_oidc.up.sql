@@ -0,0 +1,2 @@
+-- OIDC-enabled apps get an ID token with every login.
+ALTER TABLE apps ADD COLUMN oidc_enabled INTEGER NOT NULL DEFAULT 0;
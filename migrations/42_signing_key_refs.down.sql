@@ -0,0 +1,2 @@
+DELETE FROM app_signing_keys WHERE key_ref IS NOT NULL;
+ALTER TABLE app_signing_keys DROP COLUMN key_ref;
@@ -0,0 +1,3 @@
+-- Signing keys held by a key provider, such as Vault or KMS, are stored by
+-- reference: key_ref names the key and private_key is left empty.
+ALTER TABLE app_signing_keys ADD COLUMN key_ref TEXT;
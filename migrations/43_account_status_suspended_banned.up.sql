@@ -0,0 +1,38 @@
+-- Suspended (4) and banned (5) accounts need the status check widened. SQLite
+-- can't alter a constraint, so the table is rebuilt.
+CREATE TABLE IF NOT EXISTS accounts_new
+(
+    id                       INTEGER PRIMARY KEY,
+    created_at               TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at               TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    email                    TEXT NOT NULL,
+    pass_hash                BYTEA NOT NULL,
+    status                   INTEGER NOT NULL, -- AccountStatus (0 - ACTIVE, 1 - INACTIVE, 2 - DELETED, 3 - DORMANT, 4 - SUSPENDED, 5 - BANNED)
+    app_id                   BIGINT REFERENCES apps(id),
+    role                     INTEGER NOT NULL, -- AccountRoles (0 - USER, 1 - ADMIN)
+    last_login_at            TIMESTAMP,
+    token_version            INTEGER NOT NULL DEFAULT 0,
+    external_id              TEXT,
+    deleted_at               TIMESTAMP,
+    deleted_email            TEXT,
+    deleted_status           INTEGER,
+    username                 TEXT,
+    phone                    TEXT,
+    requires_password_change INTEGER NOT NULL DEFAULT 0,
+    CONSTRAINT valid_status CHECK (status IN (0, 1, 2, 3, 4, 5))
+);
+
+INSERT INTO accounts_new (id, created_at, updated_at, email, pass_hash, status, app_id, role, last_login_at, token_version, external_id, deleted_at, deleted_email, deleted_status, username, phone, requires_password_change)
+SELECT id, created_at, updated_at, email, pass_hash, status, app_id, role, last_login_at, token_version, external_id, deleted_at, deleted_email, deleted_status, username, phone, requires_password_change FROM accounts;
+
+DROP TABLE accounts;
+
+ALTER TABLE accounts_new RENAME TO accounts;
+
+CREATE INDEX IF NOT EXISTS idx_email ON accounts (email);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_accounts_email_app_id ON accounts (email, app_id);
+CREATE INDEX IF NOT EXISTS idx_accounts_last_login_at ON accounts (last_login_at);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_accounts_external_id ON accounts (external_id);
+CREATE INDEX IF NOT EXISTS idx_accounts_deleted_at ON accounts (deleted_at);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_accounts_username_app_id ON accounts (username, app_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_accounts_phone_app_id ON accounts (phone, app_id);
@@ -0,0 +1,25 @@
+-- The ids are kept: there is no going back to apps without one.
+CREATE TABLE IF NOT EXISTS apps_old
+(
+    id                BIGSERIAL PRIMARY KEY,
+    created_at        TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at        TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    name              TEXT NOT NULL UNIQUE,
+    secret            TEXT NOT NULL UNIQUE,
+    redirect_url      TEXT,
+    mfa_policy        INTEGER NOT NULL DEFAULT 0,
+    token_version     INTEGER NOT NULL DEFAULT 0,
+    rp_id             TEXT NOT NULL DEFAULT '',
+    rp_origins        TEXT NOT NULL DEFAULT '',
+    require_username  INTEGER NOT NULL DEFAULT 0,
+    self_registration INTEGER NOT NULL DEFAULT 1,
+    signing_alg       TEXT NOT NULL DEFAULT 'HS256',
+    audience          TEXT NOT NULL DEFAULT '',
+    oidc_enabled      INTEGER NOT NULL DEFAULT 0
+);
+
+INSERT INTO apps_old SELECT * FROM apps;
+
+DROP TABLE apps;
+
+ALTER TABLE apps_old RENAME TO apps;
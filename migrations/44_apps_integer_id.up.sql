@@ -0,0 +1,30 @@
+-- apps.id was declared BIGSERIAL, which SQLite doesn't know: the column never
+-- became an alias of the rowid, so apps saved without an explicit id got a NULL
+-- one and couldn't be found by the id SaveApp returned. The table is rebuilt with
+-- an INTEGER PRIMARY KEY, keeping the ids apps already have and giving the rest
+-- their rowid.
+CREATE TABLE IF NOT EXISTS apps_new
+(
+    id                INTEGER PRIMARY KEY,
+    created_at        TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at        TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    name              TEXT NOT NULL UNIQUE,
+    secret            TEXT NOT NULL UNIQUE,
+    redirect_url      TEXT,
+    mfa_policy        INTEGER NOT NULL DEFAULT 0,
+    token_version     INTEGER NOT NULL DEFAULT 0,
+    rp_id             TEXT NOT NULL DEFAULT '',
+    rp_origins        TEXT NOT NULL DEFAULT '',
+    require_username  INTEGER NOT NULL DEFAULT 0,
+    self_registration INTEGER NOT NULL DEFAULT 1,
+    signing_alg       TEXT NOT NULL DEFAULT 'HS256',
+    audience          TEXT NOT NULL DEFAULT '',
+    oidc_enabled      INTEGER NOT NULL DEFAULT 0
+);
+
+INSERT INTO apps_new (id, created_at, updated_at, name, secret, redirect_url, mfa_policy, token_version, rp_id, rp_origins, require_username, self_registration, signing_alg, audience, oidc_enabled)
+SELECT COALESCE(id, rowid), created_at, updated_at, name, secret, redirect_url, mfa_policy, token_version, rp_id, rp_origins, require_username, self_registration, signing_alg, audience, oidc_enabled FROM apps;
+
+DROP TABLE apps;
+
+ALTER TABLE apps_new RENAME TO apps;
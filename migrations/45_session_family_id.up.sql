@@ -0,0 +1,8 @@
+-- Sessions rotated from the same login share a family ID, so reuse of a rotated-out
+-- refresh token can revoke the whole chain. Existing sessions each start their own
+-- family.
+ALTER TABLE sessions ADD COLUMN family_id TEXT;
+
+UPDATE sessions SET family_id = sid WHERE family_id IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_sessions_family_id ON sessions (family_id);
@@ -0,0 +1,3 @@
+ALTER TABLE sessions DROP COLUMN app_id;
+
+DROP TABLE IF EXISTS app_memberships;
@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS app_memberships
+(
+    account_id INTEGER NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    app_id     INTEGER NOT NULL REFERENCES apps(id) ON DELETE CASCADE,
+    role       INTEGER NOT NULL, -- AccountRoles (0 - USER, 1 - ADMIN)
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (account_id, app_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_app_memberships_app_id ON app_memberships (app_id);
+
+-- Every existing account is a member of the app it registered with, keeping its global role.
+INSERT OR IGNORE INTO app_memberships (account_id, app_id, role)
+SELECT id, app_id, role FROM accounts WHERE app_id IS NOT NULL;
+
+ALTER TABLE sessions ADD COLUMN app_id INTEGER REFERENCES apps(id);
@@ -0,0 +1,27 @@
+-- Rebuild sessions so id is a real rowid alias (BIGSERIAL doesn't auto-increment in
+-- SQLite) and record why a session was revoked.
+CREATE TABLE IF NOT EXISTS sessions_new
+(
+    id                 INTEGER PRIMARY KEY,
+    account_id         INTEGER NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    app_id             INTEGER REFERENCES apps(id),
+    token              TEXT NOT NULL UNIQUE,
+    refresh_token      TEXT NOT NULL,
+    user_agent         TEXT,
+    ip_address         TEXT,
+    expires_at         TIMESTAMP NOT NULL,
+    refresh_expires_at TIMESTAMP NOT NULL,
+    created_at         TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at         TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    revoked            BOOLEAN NOT NULL DEFAULT FALSE,
+    revoked_reason     TEXT
+);
+
+INSERT INTO sessions_new (id, account_id, app_id, token, refresh_token, user_agent, ip_address, expires_at, refresh_expires_at, created_at, updated_at, revoked)
+SELECT COALESCE(id, rowid), account_id, app_id, token, refresh_token, user_agent, ip_address, expires_at, refresh_expires_at, created_at, updated_at, revoked FROM sessions;
+
+DROP TABLE sessions;
+
+ALTER TABLE sessions_new RENAME TO sessions;
+
+CREATE INDEX IF NOT EXISTS idx_account_id ON sessions (account_id);
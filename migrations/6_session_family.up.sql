@@ -0,0 +1,3 @@
+ALTER TABLE sessions ADD COLUMN family_started_at TIMESTAMP;
+
+UPDATE sessions SET family_started_at = created_at;
@@ -0,0 +1 @@
+ALTER TABLE apps ADD COLUMN mfa_policy INTEGER NOT NULL DEFAULT 0; -- MFAPolicy (0 - OFF, 1 - OPTIONAL, 2 - REQUIRED)
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS one_time_codes
+(
+    account_id INTEGER NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    purpose    TEXT NOT NULL, -- CodePurpose (otp, magic_link)
+    code_hash  TEXT NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    used_at    TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (account_id, purpose, code_hash)
+);
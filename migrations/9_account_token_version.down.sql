@@ -0,0 +1 @@
+ALTER TABLE accounts DROP COLUMN token_version;